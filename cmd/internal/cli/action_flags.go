@@ -6,8 +6,7 @@
 package cli
 
 import (
-	"os"
-
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/pkg/cmdline"
 )
 
@@ -15,6 +14,7 @@ import (
 var (
 	AppName         string
 	BindPaths       []string
+	NoMount         []string
 	HomePath        string
 	OverlayPath     []string
 	ScratchPath     []string
@@ -32,6 +32,13 @@ var (
 	VMIP            string
 	ContainLibsPath []string
 	FuseMount       []string
+	EnvAllow        []string
+	EnvDeny         []string
+	InitMode        string
+
+	WritableTmpfsSize string
+	NetworkIPFamily   string
+	ResolvConfPath    string
 
 	IsBoot          bool
 	IsFakeroot      bool
@@ -43,6 +50,7 @@ var (
 	Nvidia          bool
 	Rocm            bool
 	NoHome          bool
+	NoHostCerts     bool
 	NoInit          bool
 	NoNvidia        bool
 	NoRocm          bool
@@ -51,6 +59,9 @@ var (
 	NoNet           bool
 	IsSyOS          bool
 	disableCache    bool
+	DumpConfig      bool
+	NoDeviceCgroup  bool
+	UsernsOnly      bool
 
 	NetNamespace  bool
 	UtsNamespace  bool
@@ -211,6 +222,18 @@ var actionNetworkArgsFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --network-ip-family
+var actionNetworkIPFamilyFlag = cmdline.Flag{
+	ID:           "actionNetworkIPFamilyFlag",
+	Value:        &NetworkIPFamily,
+	DefaultValue: "",
+	Name:         "network-ip-family",
+	Usage:        "request IP addresses of the given family to CNI plugins: ipv4, ipv6 or dual (default: addresses configured by the network)",
+	EnvKeys:      []string{"NETWORK_IP_FAMILY"},
+	Tag:          "<family>",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --dns
 var actionDNSFlag = cmdline.Flag{
 	ID:           "actionDnsFlag",
@@ -222,6 +245,18 @@ var actionDNSFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --resolv-conf
+var actionResolvConfFlag = cmdline.Flag{
+	ID:           "actionResolvConfFlag",
+	Value:        &ResolvConfPath,
+	DefaultValue: "",
+	Name:         "resolv-conf",
+	Usage:        "path of a resolv.conf file to use in the container instead of the host one",
+	EnvKeys:      []string{"RESOLV_CONF"},
+	Tag:          "<path>",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --security
 var actionSecurityFlag = cmdline.Flag{
 	ID:           "actionSecurityFlag",
@@ -233,6 +268,16 @@ var actionSecurityFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --dump-config
+var actionDumpConfigFlag = cmdline.Flag{
+	ID:           "actionDumpConfigFlag",
+	Value:        &DumpConfig,
+	DefaultValue: false,
+	Name:         "dump-config",
+	Usage:        "print the resolved runtime configuration as JSON and exit without running the container",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --apply-cgroups
 var actionApplyCgroupsFlag = cmdline.Flag{
 	ID:           "actionApplyCgroupsFlag",
@@ -244,6 +289,28 @@ var actionApplyCgroupsFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --no-device-cgroup
+var actionNoDeviceCgroupFlag = cmdline.Flag{
+	ID:           "actionNoDeviceCgroupFlag",
+	Value:        &NoDeviceCgroup,
+	DefaultValue: false,
+	Name:         "no-device-cgroup",
+	Usage:        "do not allow the device nodes bound from /dev in the devices cgroup of --apply-cgroups (root only)",
+	EnvKeys:      []string{"NO_DEVICE_CGROUP"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
+// --userns-only
+var actionUsernsOnlyFlag = cmdline.Flag{
+	ID:           "actionUsernsOnlyFlag",
+	Value:        &UsernsOnly,
+	DefaultValue: false,
+	Name:         "userns-only",
+	Usage:        "run the container with a user namespace only, never use the setuid workflow and fail if it's required",
+	EnvKeys:      []string{"USERNS_ONLY"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --vm-ram
 var actionVMRAMFlag = cmdline.Flag{
 	ID:           "actionVMRAMFlag",
@@ -313,7 +380,7 @@ var actionFuseMountFlag = cmdline.Flag{
 var actionTmpDirFlag = cmdline.Flag{
 	ID:           "actionTmpDirFlag",
 	Value:        &tmpDir,
-	DefaultValue: os.TempDir(),
+	DefaultValue: fs.TempDir(),
 	Name:         "tmpdir",
 	Usage:        "specify a temporary directory to use for build",
 	Hidden:       true,
@@ -355,6 +422,28 @@ var actionCleanEnvFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --env-allow
+var actionEnvAllowFlag = cmdline.Flag{
+	ID:           "actionEnvAllowFlag",
+	Value:        &EnvAllow,
+	DefaultValue: []string{},
+	Name:         "env-allow",
+	Usage:        "only pass the host environment variables matching these glob patterns (eg: 'LC_*') to the container",
+	EnvKeys:      []string{"ENV_ALLOW"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
+// --env-deny
+var actionEnvDenyFlag = cmdline.Flag{
+	ID:           "actionEnvDenyFlag",
+	Value:        &EnvDeny,
+	DefaultValue: []string{},
+	Name:         "env-deny",
+	Usage:        "never pass the host environment variables matching these glob patterns (eg: 'AWS_*') to the container, takes precedence over --env-allow",
+	EnvKeys:      []string{"ENV_DENY"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // -c|--contain
 var actionContainFlag = cmdline.Flag{
 	ID:           "actionContainFlag",
@@ -424,6 +513,18 @@ var actionWritableTmpfsFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --writable-tmpfs-size
+var actionWritableTmpfsSizeFlag = cmdline.Flag{
+	ID:           "actionWritableTmpfsSizeFlag",
+	Value:        &WritableTmpfsSize,
+	DefaultValue: "",
+	Name:         "writable-tmpfs-size",
+	Usage:        "limit the size of the data written with --writable-tmpfs (eg: 512m, 2g)",
+	Tag:          "<size>",
+	EnvKeys:      []string{"WRITABLE_TMPFS_SIZE"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --no-home
 var actionNoHomeFlag = cmdline.Flag{
 	ID:           "actionNoHomeFlag",
@@ -435,6 +536,29 @@ var actionNoHomeFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --no-host-certs
+var actionNoHostCertsFlag = cmdline.Flag{
+	ID:           "actionNoHostCertsFlag",
+	Value:        &NoHostCerts,
+	DefaultValue: false,
+	Name:         "no-host-certs",
+	Usage:        "do NOT bind the host CA certificates bundle into a container lacking one",
+	EnvKeys:      []string{"NO_HOST_CERTS"},
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
+// --no-mount
+var actionNoMountFlag = cmdline.Flag{
+	ID:           "actionNoMountFlag",
+	Value:        &NoMount,
+	DefaultValue: []string{},
+	Name:         "no-mount",
+	Usage:        "disable one or more default mounts, given as a comma separated list of proc, sys, dev, devpts, home, tmp, hostfs, cwd and bind-paths (the 'bind path' entries of singularity.conf). Explicit --bind mounts are not affected",
+	EnvKeys:      []string{"NO_MOUNT"},
+	Tag:          "<list>",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // --no-init
 var actionNoInitFlag = cmdline.Flag{
 	ID:           "actionNoInitFlag",
@@ -446,6 +570,18 @@ var actionNoInitFlag = cmdline.Flag{
 	ExcludedOS:   []string{cmdline.Darwin},
 }
 
+// --init
+var actionInitFlag = cmdline.Flag{
+	ID:           "actionInitFlag",
+	Value:        &InitMode,
+	DefaultValue: "",
+	Name:         "init",
+	Usage:        "init process run as PID 1 with --pid: builtin (the default, reaps orphaned processes and forwards signals), container (/sbin/init of the container, the command given as its arguments) or none (the command itself)",
+	EnvKeys:      []string{"INIT"},
+	Tag:          "<init>",
+	ExcludedOS:   []string{cmdline.Darwin},
+}
+
 // hidden flag to disable nvidia bindings when 'always use nv = yes'
 var actionNoNvidiaFlag = cmdline.Flag{
 	ID:           "actionNoNvidiaFlag",
@@ -586,7 +722,7 @@ var actionAddCapsFlag = cmdline.Flag{
 	Value:        &AddCaps,
 	DefaultValue: "",
 	Name:         "add-caps",
-	Usage:        "a comma separated capability list to add",
+	Usage:        "a comma separated capability list to add, unknown capabilities are an error",
 	EnvKeys:      []string{"ADD_CAPS"},
 	ExcludedOS:   []string{cmdline.Darwin},
 }
@@ -597,7 +733,7 @@ var actionDropCapsFlag = cmdline.Flag{
 	Value:        &DropCaps,
 	DefaultValue: "",
 	Name:         "drop-caps",
-	Usage:        "a comma separated capability list to drop",
+	Usage:        "a comma separated capability list to drop, unknown capabilities are an error",
 	EnvKeys:      []string{"DROP_CAPS"},
 	ExcludedOS:   []string{cmdline.Darwin},
 }
@@ -637,6 +773,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionAllowSetuidFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionAppFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionApplyCgroupsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNoDeviceCgroupFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionUsernsOnlyFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionBindFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionCleanEnvFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionContainAllFlag, actionsInstanceCmd...)
@@ -644,7 +782,11 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionContainLibsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDisableCacheFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDNSFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionDumpConfigFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionResolvConfFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionDropCapsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionEnvAllowFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionEnvDenyFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionFakerootFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionFuseMountFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionHomeFlag, actionsInstanceCmd...)
@@ -653,9 +795,13 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionKeepPrivsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetNamespaceFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetworkArgsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNetworkIPFamilyFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNetworkFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoHomeFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNoHostCertsFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoInitFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionInitFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionNoMountFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNONETFlag, actionsCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoNvidiaFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionNoRocmFlag, actionsInstanceCmd...)
@@ -682,9 +828,12 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&actionWorkdirFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&actionWritableTmpfsFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&actionWritableTmpfsSizeFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&commonNoHTTPSFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, actionsInstanceCmd...)
 		cmdManager.RegisterFlagForCmd(&dockerUsernameFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&proxyPasswordFlag, actionsInstanceCmd...)
+		cmdManager.RegisterFlagForCmd(&proxyUsernameFlag, actionsInstanceCmd...)
 	})
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/pkg/build/types"
 	net "github.com/sylabs/singularity/pkg/client/net"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	shub "github.com/sylabs/singularity/pkg/client/shub"
 )
 
@@ -66,6 +67,7 @@ func actionPreRun(cmd *cobra.Command, args []string) {
 
 	ctx := context.TODO()
 
+	setProxyConfig()
 	replaceURIWithImage(ctx, imgCache, cmd, args)
 }
 
@@ -149,6 +151,7 @@ func handleOCI(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command,
 				return "", fmt.Errorf("unable to build: %v", err)
 			}
 
+			imgCache.SetSource(imgabs, u)
 			sylog.Verbosef("Image cached as SIF at %s", imgabs)
 		}
 	}
@@ -184,6 +187,7 @@ func handleOras(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command,
 		} else if cacheFileHash != sum {
 			return "", fmt.Errorf("cached file hash(%s) and expected hash(%s) does not match", cacheFileHash, sum)
 		}
+		imgCache.SetSource(cacheImagePath, u)
 	}
 
 	return cacheImagePath, nil
@@ -191,8 +195,9 @@ func handleOras(ctx context.Context, imgCache *cache.Handle, cmd *cobra.Command,
 
 func handleLibrary(ctx context.Context, imgCache *cache.Handle, u, libraryURL string) (string, error) {
 	c, err := library.NewClient(&library.Config{
-		AuthToken: authToken,
-		BaseURL:   libraryURL,
+		AuthToken:  authToken,
+		BaseURL:    libraryURL,
+		HTTPClient: &http.Client{Transport: proxy.NewTransport()},
 	})
 	if err != nil {
 		return "", fmt.Errorf("unable to initialize client library: %v", err)
@@ -239,6 +244,7 @@ func handleLibrary(ctx context.Context, imgCache *cache.Handle, u, libraryURL st
 			} else if cacheFileHash != libraryImage.Hash {
 				return "", fmt.Errorf("cached file hash(%s) and expected hash(%s) does not match", cacheFileHash, libraryImage.Hash)
 			}
+			imgCache.SetSource(imagePath, "library://"+imageRef)
 		}
 	}
 
@@ -285,6 +291,7 @@ func handleShub(imgCache *cache.Handle, u string) (string, error) {
 			if err != nil {
 				sylog.Fatalf("%v\n", err)
 			}
+			imgCache.SetSource(imagePath, u)
 		} else {
 			sylog.Verbosef("Use image from cache")
 		}
@@ -332,6 +339,7 @@ func handleNet(imgCache *cache.Handle, u string) (string, error) {
 		if err != nil {
 			sylog.Fatalf("%v\n", err)
 		}
+		imgCache.SetSource(imagePath, u)
 	} else {
 		sylog.Verbosef("Using image from cache")
 	}
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/plugin"
@@ -26,6 +27,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/env"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/internal/pkg/util/starter"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	imgutil "github.com/sylabs/singularity/pkg/image"
@@ -70,12 +72,13 @@ func convertImage(filename string, unsquashfsPath string) (string, error) {
 		s.UnsquashfsPath = unsquashfsPath
 	}
 
+	tmpdir := fs.TempDir()
 	// keep compatibility with v2
-	tmpdir := os.Getenv("SINGULARITY_TMPDIR")
-	if tmpdir == "" {
-		tmpdir = os.Getenv("SINGULARITY_LOCALCACHEDIR")
-		if tmpdir == "" {
-			tmpdir = os.Getenv("SINGULARITY_CACHEDIR")
+	if os.Getenv("SINGULARITY_TMPDIR") == "" {
+		if dir := os.Getenv("SINGULARITY_LOCALCACHEDIR"); dir != "" {
+			tmpdir = dir
+		} else if dir := os.Getenv("SINGULARITY_CACHEDIR"); dir != "" {
+			tmpdir = dir
 		}
 	}
 
@@ -252,6 +255,20 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		}
 	}
 
+	// the setuid workflow is never used with --userns-only, the
+	// operations requiring it fail instead of falling back to it
+	if UsernsOnly {
+		if uid != 0 && engineConfig.GetInstanceJoin() && !UserNamespace {
+			sylog.Fatalf("Instance %s was started without user namespace, joining it requires the setuid workflow disabled by --userns-only", image)
+		}
+		if useSuid {
+			sylog.Verbosef("Setuid workflow disabled by --userns-only, using user namespace")
+		}
+		useSuid = false
+		UserNamespace = true
+		engineConfig.SetUsernsOnly(true)
+	}
+
 	var libs, bins, ipcs []string
 	var gpuConfFile, gpuPlatform string
 	userPath := os.Getenv("USER_PATH")
@@ -327,10 +344,15 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			sylog.Fatalf("while getting root filesystem in %s: %s", engineConfig.GetImage(), err)
 		}
 
-		// ensure we have decryption material
-		if part.Type == imgutil.ENCRYPTSQUASHFS {
+		// ensure we have decryption material, the key
+		// is not part of the dumped configuration
+		if part.Type == imgutil.ENCRYPTSQUASHFS && !DumpConfig {
 			sylog.Debugf("Encrypted container filesystem detected")
 
+			if UsernsOnly && uid != 0 {
+				sylog.Fatalf("Encrypted containers require the setuid workflow, disabled by --userns-only")
+			}
+
 			keyInfo, err := getEncryptionMaterial(cobraCmd)
 			if err != nil {
 				sylog.Fatalf("While handling encryption material: %v", err)
@@ -365,13 +387,42 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	}
 	engineConfig.SetNetwork(Network)
 	engineConfig.SetDNS(DNS)
+	if ResolvConfPath != "" {
+		if DNS != "" {
+			sylog.Fatalf("--resolv-conf and --dns are mutually exclusive")
+		}
+		// read with the user privileges
+		content, err := ioutil.ReadFile(ResolvConfPath)
+		if err != nil {
+			sylog.Fatalf("While reading %s: %s", ResolvConfPath, err)
+		}
+		if err := files.CheckResolvConf(content); err != nil {
+			sylog.Fatalf("Invalid resolv.conf %s: %s", ResolvConfPath, err)
+		}
+		engineConfig.SetResolvConf(content)
+	}
 	engineConfig.SetNetworkArgs(NetworkArgs)
+	engineConfig.SetNetworkIPFamily(NetworkIPFamily)
 	engineConfig.SetOverlayImage(OverlayPath)
 	engineConfig.SetWritableImage(IsWritable)
 	engineConfig.SetNoHome(NoHome)
+	engineConfig.SetNoHostCerts(NoHostCerts)
+	for i := range NoMount {
+		NoMount[i] = strings.TrimSpace(NoMount[i])
+	}
+	if err := singularityConfig.CheckNoMount(NoMount); err != nil {
+		sylog.Fatalf("Invalid --no-mount: %s", err)
+	}
+	engineConfig.SetNoMount(NoMount)
 	engineConfig.SetNv(Nvidia)
 	engineConfig.SetRocm(Rocm)
+	if _, err := capabilities.Parse(AddCaps); err != nil {
+		sylog.Fatalf("Invalid --add-caps: %s", err)
+	}
 	engineConfig.SetAddCaps(AddCaps)
+	if _, err := capabilities.Parse(DropCaps); err != nil {
+		sylog.Fatalf("Invalid --drop-caps: %s", err)
+	}
 	engineConfig.SetDropCaps(DropCaps)
 
 	checkPrivileges(AllowSUID, "--allow-setuid", func() {
@@ -394,6 +445,7 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 
 	checkPrivileges(CgroupsPath != "", "--apply-cgroups", func() {
 		engineConfig.SetCgroupsPath(CgroupsPath)
+		engineConfig.SetNoDeviceCgroup(NoDeviceCgroup)
 	})
 
 	if IsWritable && IsWritableTmpfs {
@@ -403,6 +455,17 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		engineConfig.SetWritableTmpfs(IsWritableTmpfs)
 	}
 
+	if WritableTmpfsSize != "" {
+		if !IsWritableTmpfs {
+			sylog.Fatalf("--writable-tmpfs-size requires --writable-tmpfs")
+		}
+		size, err := fs.ParseSize(WritableTmpfsSize)
+		if err != nil {
+			sylog.Fatalf("Invalid --writable-tmpfs-size: %s", err)
+		}
+		engineConfig.SetWritableTmpfsSize(size)
+	}
+
 	homeFlag := cobraCmd.Flag("home")
 	engineConfig.SetCustomHome(homeFlag.Changed)
 
@@ -483,6 +546,12 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			sylog.Fatalf("instance %s already exists", name)
 		}
 
+		hc, err := healthCheck()
+		if err != nil {
+			sylog.Fatalf("%s", err)
+		}
+		engineConfig.SetHealthCheck(hc)
+
 		if IsBoot {
 			UtsNamespace = true
 			NetNamespace = true
@@ -514,7 +583,9 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 			// unprivileged installation could not use fakeroot
 			// network because it requires a setuid installation
 			// so we fallback to none
-			if buildcfg.SINGULARITY_SUID_INSTALL == 0 || !engineConfig.File.AllowSetuid {
+			if UsernsOnly {
+				sylog.Fatalf("The 'fakeroot' network requires the setuid workflow, disabled by --userns-only, use --network none")
+			} else if buildcfg.SINGULARITY_SUID_INSTALL == 0 || !engineConfig.File.AllowSetuid {
 				sylog.Warningf(
 					"fakeroot with unprivileged installation or 'allow setuid = no' " +
 						"could not use 'fakeroot' network, fallback to 'none' network",
@@ -530,6 +601,17 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	if PidNamespace {
 		generator.AddOrReplaceLinuxNamespace("pid", "")
 		engineConfig.SetNoInit(NoInit)
+		if InitMode != "" {
+			if err := singularityConfig.CheckInit(InitMode); err != nil {
+				sylog.Fatalf("Invalid --init: %s", err)
+			}
+			if NoInit && InitMode != singularityConfig.InitNone {
+				sylog.Fatalf("--no-init can't be used with --init=%s", InitMode)
+			}
+			engineConfig.SetInit(InitMode)
+		}
+	} else if InitMode != "" {
+		sylog.Warningf("--init is ignored without --pid")
 	}
 	if IpcNamespace {
 		generator.AddOrReplaceLinuxNamespace("ipc", "")
@@ -544,7 +626,11 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 	}
 
 	// Copy and cache environment
-	environment := os.Environ()
+	filter := env.HostEnvFilter{Allow: EnvAllow, Deny: EnvDeny}
+	if err := filter.Check(); err != nil {
+		sylog.Fatalf("%s", err)
+	}
+	environment := env.FilterHostEnv(os.Environ(), filter)
 
 	// Clean environment
 	env.SetContainerEnv(generator, environment, IsCleanEnv, engineConfig.GetHomeDest())
@@ -574,8 +660,8 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 
 	// convert image file to sandbox if we are using user
 	// namespace or if we are currently running inside a
-	// user namespace
-	if (UserNamespace || insideUserNs) && fs.IsFile(image) {
+	// user namespace, unless the configuration is only dumped
+	if (UserNamespace || insideUserNs) && fs.IsFile(image) && !DumpConfig {
 		unsquashfsPath := ""
 		if engineConfig.File.MksquashfsPath != "" {
 			d := filepath.Dir(engineConfig.File.MksquashfsPath)
@@ -627,6 +713,13 @@ func execStarter(cobraCmd *cobra.Command, image string, args []string, name stri
 		c.(clicallback.SingularityEngineConfig)(cfg)
 	}
 
+	if DumpConfig {
+		if err := singularity.DumpRuntimeConfig(os.Stdout, cfg); err != nil {
+			sylog.Fatalf("While dumping runtime configuration: %s", err)
+		}
+		os.Exit(0)
+	}
+
 	if engineConfig.GetInstance() {
 		stdout, stderr, err := instance.SetLogFile(name, int(uid), instance.LogSubDir)
 		if err != nil {
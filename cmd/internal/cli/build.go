@@ -26,6 +26,7 @@ var buildArgs struct {
 	arch       string
 	builderURL string
 	libraryURL string
+	checksums  bool
 	detached   bool
 	encrypt    bool
 	fakeroot   bool
@@ -36,6 +37,9 @@ var buildArgs struct {
 	remote     bool
 	sandbox    bool
 	update     bool
+
+	squashfsComp      string
+	squashfsCompLevel int
 }
 
 // -s|--sandbox
@@ -91,6 +95,16 @@ var buildNoTestFlag = cmdline.Flag{
 	EnvKeys:      []string{"NOTEST"},
 }
 
+// --checksums
+var buildChecksumsFlag = cmdline.Flag{
+	ID:           "buildChecksumsFlag",
+	Value:        &buildArgs.checksums,
+	DefaultValue: false,
+	Name:         "checksums",
+	Usage:        "record the sha256 checksum of every file of the image in /.singularity.d/checksums.sha256",
+	EnvKeys:      []string{"CHECKSUMS"},
+}
+
 // -r|--remote
 var buildRemoteFlag = cmdline.Flag{
 	ID:           "buildRemoteFlag",
@@ -195,12 +209,33 @@ var buildFixPermsFlag = cmdline.Flag{
 	EnvKeys:      []string{"FIXPERMS"},
 }
 
+// --squashfs-comp
+var buildSquashfsCompFlag = cmdline.Flag{
+	ID:           "buildSquashfsCompFlag",
+	Value:        &buildArgs.squashfsComp,
+	DefaultValue: "",
+	Name:         "squashfs-comp",
+	Usage:        "compression algorithm of the image squashfs filesystem: gzip (default), lz4, xz or zstd",
+	EnvKeys:      []string{"SQUASHFS_COMP"},
+}
+
+// --squashfs-comp-level
+var buildSquashfsCompLevelFlag = cmdline.Flag{
+	ID:           "buildSquashfsCompLevelFlag",
+	Value:        &buildArgs.squashfsCompLevel,
+	DefaultValue: 0,
+	Name:         "squashfs-comp-level",
+	Usage:        "compression level of the image squashfs filesystem, 1 to 9 for gzip and 1 to 22 for zstd (default level of the algorithm)",
+	EnvKeys:      []string{"SQUASHFS_COMP_LEVEL"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(buildCmd)
 
 		cmdManager.RegisterFlagForCmd(&buildArchFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildBuilderFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildChecksumsFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildDetachedFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildDisableCacheFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildEncryptFlag, buildCmd)
@@ -213,6 +248,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&buildRemoteFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildSandboxFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildSectionFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildSquashfsCompFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&buildSquashfsCompLevelFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&buildUpdateFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonForceFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonNoHTTPSFlag, buildCmd)
@@ -222,6 +259,9 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, buildCmd)
 
+		cmdManager.RegisterFlagForCmd(&proxyUsernameFlag, buildCmd)
+		cmdManager.RegisterFlagForCmd(&proxyPasswordFlag, buildCmd)
+
 		cmdManager.RegisterFlagForCmd(&commonPromptForPassphraseFlag, buildCmd)
 		cmdManager.RegisterFlagForCmd(&commonPEMFlag, buildCmd)
 	})
@@ -95,6 +95,8 @@ func runBuild(cmd *cobra.Command, args []string) {
 	dest := args[0]
 	spec := args[1]
 
+	setProxyConfig()
+
 	// check if target collides with existing file
 	if err := checkBuildTarget(dest); err != nil {
 		sylog.Fatalf("%s", err)
@@ -113,6 +115,10 @@ func runBuildRemote(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 	if buildArgs.encrypt {
 		sylog.Fatalf("Building encrypted container with the remote builder is not currently supported.")
 	}
+	// the remote builder selects the squashfs compression
+	if buildArgs.squashfsComp != "" || buildArgs.squashfsCompLevel != 0 {
+		sylog.Fatalf("Selecting the squashfs compression with the remote builder is not currently supported.")
+	}
 
 	handleRemoteBuildFlags(cmd)
 
@@ -260,6 +266,7 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 				Force:             forceOverwrite,
 				Sections:          buildArgs.sections,
 				NoTest:            buildArgs.noTest,
+				ChecksumManifest:  buildArgs.checksums,
 				NoHTTPS:           noHTTPS,
 				LibraryURL:        buildArgs.libraryURL,
 				LibraryAuthToken:  authToken,
@@ -267,6 +274,9 @@ func runBuildLocal(ctx context.Context, cmd *cobra.Command, dst, spec string) {
 				EncryptionKeyInfo: keyInfo,
 				FixPerms:          buildArgs.fixPerms,
 				SandboxTarget:     sandboxTarget,
+
+				SquashfsCompression:      buildArgs.squashfsComp,
+				SquashfsCompressionLevel: buildArgs.squashfsCompLevel,
 			},
 		})
 	if err != nil {
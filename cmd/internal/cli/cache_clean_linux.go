@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
@@ -25,6 +26,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&cacheCleanNameFlag, cacheCleanCmd)
 		cmdManager.RegisterFlagForCmd(&cacheCleanDryFlag, cacheCleanCmd)
 		cmdManager.RegisterFlagForCmd(&cacheCleanForceFlag, cacheCleanCmd)
+		cmdManager.RegisterFlagForCmd(&cacheCleanPatternFlag, cacheCleanCmd)
+		cmdManager.RegisterFlagForCmd(&cacheCleanOlderThanFlag, cacheCleanCmd)
 	})
 }
 
@@ -34,6 +37,9 @@ var (
 	cacheCleanDry   bool
 	cacheCleanForce bool
 
+	cacheCleanPattern   string
+	cacheCleanOlderThan string
+
 	// -T|--type
 	cacheCleanTypesFlag = cmdline.Flag{
 		ID:           "cacheCleanTypes",
@@ -74,6 +80,24 @@ var (
 		Usage:        "suppress any prompts and clean the cache",
 	}
 
+	// --pattern
+	cacheCleanPatternFlag = cmdline.Flag{
+		ID:           "cacheCleanPatternFlag",
+		Value:        &cacheCleanPattern,
+		DefaultValue: "",
+		Name:         "pattern",
+		Usage:        "only clean the images whose name matches this shell pattern",
+	}
+
+	// --older-than
+	cacheCleanOlderThanFlag = cmdline.Flag{
+		ID:           "cacheCleanOlderThanFlag",
+		Value:        &cacheCleanOlderThan,
+		DefaultValue: "",
+		Name:         "older-than",
+		Usage:        "only clean the images not accessed for this duration (e.g. 72h, 30m)",
+	}
+
 	// cacheCleanCmd is 'singularity cache clean' and will clear your local singularity cache
 	cacheCleanCmd = &cobra.Command{
 		DisableFlagsInUseLine: true,
@@ -91,11 +115,24 @@ var (
 )
 
 func cleanCache() error {
+	var olderThan time.Duration
+	if cacheCleanOlderThan != "" {
+		d, err := time.ParseDuration(cacheCleanOlderThan)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q for --older-than", cacheCleanOlderThan)
+		}
+		olderThan = d
+	}
+	selective := cacheCleanPattern != "" || olderThan > 0
+	if selective && len(cacheCleanNames) > 0 {
+		return fmt.Errorf("--name can't be combined with --pattern or --older-than")
+	}
+
 	if cacheCleanDry {
 		fmt.Println("User requested a dry run. Not actually deleting any data!")
 	}
 	if !cacheCleanForce && !cacheCleanDry {
-		ok, err := cleanCachePrompt()
+		ok, err := cleanCachePrompt(selective)
 		if err != nil {
 			return fmt.Errorf("could not prompt user: %v", err)
 		}
@@ -107,17 +144,28 @@ func cleanCache() error {
 
 	// create a handle to access the current image cache
 	imgCache := getCacheHandle(cache.Config{})
-	err := singularity.CleanSingularityCache(imgCache, !cacheCleanDry, cacheCleanTypes, cacheCleanNames)
+	var err error
+	if selective {
+		err = singularity.CleanSingularityCacheEntries(imgCache, !cacheCleanDry, cacheCleanTypes, cacheCleanPattern, olderThan)
+	} else {
+		err = singularity.CleanSingularityCache(imgCache, !cacheCleanDry, cacheCleanTypes, cacheCleanNames)
+	}
 	if err != nil {
 		return fmt.Errorf("could not clean cache: %v", err)
 	}
 	return nil
 }
 
-func cleanCachePrompt() (bool, error) {
-	fmt.Print(`This will delete everything in your cache (containers from all sources and OCI blobs). 
+func cleanCachePrompt(selective bool) (bool, error) {
+	if selective {
+		fmt.Print(`This will delete the cached images matching the given pattern and age, except those in use.
 Hint: You can see exactly what would be deleted by canceling and using the --dry-run option.
 Do you want to continue? [N/y] `)
+	} else {
+		fmt.Print(`This will delete everything in your cache (containers from all sources and OCI blobs). 
+Hint: You can see exactly what would be deleted by canceling and using the --dry-run option.
+Do you want to continue? [N/y] `)
+	}
 
 	r := bufio.NewReader(os.Stdin)
 	input, err := r.ReadString('\n')
@@ -19,6 +19,7 @@ import (
 var (
 	cacheListTypes   []string
 	cacheListVerbose bool
+	cacheListLong    bool
 )
 
 // -T|--type
@@ -41,10 +42,21 @@ var cacheListVerboseFlag = cmdline.Flag{
 	Usage:        "include cache entries in the output",
 }
 
+// -l|--long
+var cacheListLongFlag = cmdline.Flag{
+	ID:           "cacheListLong",
+	Value:        &cacheListLong,
+	DefaultValue: false,
+	Name:         "long",
+	ShortHand:    "l",
+	Usage:        "list the cached images with their last access time, use and source",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterFlagForCmd(&cacheListTypesFlag, CacheListCmd)
 		cmdManager.RegisterFlagForCmd(&cacheListVerboseFlag, CacheListCmd)
+		cmdManager.RegisterFlagForCmd(&cacheListLongFlag, CacheListCmd)
 	})
 }
 
@@ -70,7 +82,12 @@ func cacheListCmd() error {
 		sylog.Fatalf("failed to create image cache handle")
 	}
 
-	err := singularity.ListSingularityCache(imgCache, cacheListTypes, cacheListVerbose)
+	var err error
+	if cacheListLong {
+		err = singularity.ListSingularityCacheEntries(imgCache, cacheListTypes)
+	} else {
+		err = singularity.ListSingularityCache(imgCache, cacheListTypes, cacheListVerbose)
+	}
 	if err != nil {
 		sylog.Fatalf("An error occurred while listing cache: %v", err)
 		return err
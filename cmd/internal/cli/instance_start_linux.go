@@ -6,9 +6,13 @@
 package cli
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/cmdline"
 )
@@ -16,6 +20,10 @@ import (
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterFlagForCmd(&instanceStartPidFileFlag, instanceStartCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStartHealthCmdFlag, instanceStartCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStartHealthPortFlag, instanceStartCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStartHealthIntervalFlag, instanceStartCmd)
+		cmdManager.RegisterFlagForCmd(&instanceStartHealthTimeoutFlag, instanceStartCmd)
 	})
 }
 
@@ -30,6 +38,82 @@ var instanceStartPidFileFlag = cmdline.Flag{
 	EnvKeys:      []string{"PID_FILE"},
 }
 
+// --health-cmd
+var instanceStartHealthCmd string
+var instanceStartHealthCmdFlag = cmdline.Flag{
+	ID:           "instanceStartHealthCmdFlag",
+	Value:        &instanceStartHealthCmd,
+	DefaultValue: "",
+	Name:         "health-cmd",
+	Usage:        "command run in the instance by a shell to check its health, a zero exit status reports a healthy instance",
+	Tag:          "<command>",
+	EnvKeys:      []string{"HEALTH_CMD"},
+}
+
+// --health-port
+var instanceStartHealthPort int
+var instanceStartHealthPortFlag = cmdline.Flag{
+	ID:           "instanceStartHealthPortFlag",
+	Value:        &instanceStartHealthPort,
+	DefaultValue: 0,
+	Name:         "health-port",
+	Usage:        "TCP port of the instance accepting connections while the instance is healthy",
+	Tag:          "<port>",
+	EnvKeys:      []string{"HEALTH_PORT"},
+}
+
+// --health-interval
+var instanceStartHealthInterval string
+var instanceStartHealthIntervalFlag = cmdline.Flag{
+	ID:           "instanceStartHealthIntervalFlag",
+	Value:        &instanceStartHealthInterval,
+	DefaultValue: instance.DefaultHealthInterval.String(),
+	Name:         "health-interval",
+	Usage:        "time between two health checks (eg: 30s, 5m)",
+	Tag:          "<duration>",
+	EnvKeys:      []string{"HEALTH_INTERVAL"},
+}
+
+// --health-timeout
+var instanceStartHealthTimeout string
+var instanceStartHealthTimeoutFlag = cmdline.Flag{
+	ID:           "instanceStartHealthTimeoutFlag",
+	Value:        &instanceStartHealthTimeout,
+	DefaultValue: instance.DefaultHealthTimeout.String(),
+	Name:         "health-timeout",
+	Usage:        "time after which a health check is failed",
+	Tag:          "<duration>",
+	EnvKeys:      []string{"HEALTH_TIMEOUT"},
+}
+
+// healthCheck returns the instance health check set by
+// the command line, or nil if there is none.
+func healthCheck() (*instance.HealthCheck, error) {
+	if instanceStartHealthCmd == "" && instanceStartHealthPort == 0 {
+		return nil, nil
+	}
+
+	interval, err := time.ParseDuration(instanceStartHealthInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health check interval: %s", err)
+	}
+	timeout, err := time.ParseDuration(instanceStartHealthTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid health check timeout: %s", err)
+	}
+
+	hc := &instance.HealthCheck{
+		Command:  instanceStartHealthCmd,
+		Port:     instanceStartHealthPort,
+		Interval: interval,
+		Timeout:  timeout,
+	}
+	if err := hc.Check(); err != nil {
+		return nil, err
+	}
+	return hc, nil
+}
+
 // singularity instance start
 var instanceStartCmd = &cobra.Command{
 	Args:                  cobra.MinimumNArgs(2),
@@ -7,10 +7,13 @@ package cli
 
 import (
 	"errors"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/cmdline"
+	"github.com/sylabs/singularity/pkg/sypgp"
 )
 
 const (
@@ -21,6 +24,9 @@ var (
 	keyServerURI        string // -u command line option
 	keySearchLongList   bool   // -l option for long-list
 	keyNewpairBitLength int    // -b option for bit length
+
+	keyServerRetries      int // --retries option
+	keyServerRetryBackoff int // --retry-backoff option
 )
 
 // -u|--url
@@ -30,7 +36,7 @@ var keyServerURIFlag = cmdline.Flag{
 	DefaultValue: defaultKeyServer,
 	Name:         "url",
 	ShortHand:    "u",
-	Usage:        "specify the key server URL",
+	Usage:        "specify the key server URL, or a comma separated list of URLs tried in order on failure",
 	EnvKeys:      []string{"URL"},
 }
 
@@ -54,6 +60,26 @@ var keyNewpairBitLengthFlag = cmdline.Flag{
 	Usage:        "specify key bit length",
 }
 
+// --retries
+var keyServerRetriesFlag = cmdline.Flag{
+	ID:           "keyServerRetriesFlag",
+	Value:        &keyServerRetries,
+	DefaultValue: sypgp.DefaultRetryPolicy.MaxAttempts - 1,
+	Name:         "retries",
+	Usage:        "number of retries of the key server requests failing with a transient error",
+	EnvKeys:      []string{"KEYSERVER_RETRIES"},
+}
+
+// --retry-backoff
+var keyServerRetryBackoffFlag = cmdline.Flag{
+	ID:           "keyServerRetryBackoffFlag",
+	Value:        &keyServerRetryBackoff,
+	DefaultValue: int(sypgp.DefaultRetryPolicy.InitialBackoff / time.Millisecond),
+	Name:         "retry-backoff",
+	Usage:        "delay in milliseconds before the first retry of a key server request, doubled after each retry",
+	EnvKeys:      []string{"KEYSERVER_RETRY_BACKOFF"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(KeyCmd)
@@ -74,6 +100,8 @@ func init() {
 		cmdManager.RegisterSubCmd(KeyCmd, KeyExportCmd)
 
 		cmdManager.RegisterFlagForCmd(&keyServerURIFlag, KeySearchCmd, KeyPushCmd, KeyPullCmd)
+		cmdManager.RegisterFlagForCmd(&keyServerRetriesFlag, KeySearchCmd, KeyPushCmd, KeyPullCmd, KeyNewPairCmd)
+		cmdManager.RegisterFlagForCmd(&keyServerRetryBackoffFlag, KeySearchCmd, KeyPushCmd, KeyPullCmd, KeyNewPairCmd)
 		cmdManager.RegisterFlagForCmd(&keySearchLongListFlag, KeySearchCmd)
 		cmdManager.RegisterFlagForCmd(&keyNewpairBitLengthFlag, KeyNewPairCmd)
 		cmdManager.RegisterFlagForCmd(&keyImportWithNewPasswordFlag, KeyImportCmd)
@@ -94,3 +122,19 @@ var KeyCmd = &cobra.Command{
 	Example:       docs.KeyExample,
 	SilenceErrors: true,
 }
+
+// setKeyServerRetryPolicy applies the retry flags to the
+// key server operations.
+func setKeyServerRetryPolicy() {
+	if keyServerRetries < 0 {
+		sylog.Fatalf("Invalid number of key server retries: %d", keyServerRetries)
+	}
+	if keyServerRetryBackoff < 0 {
+		sylog.Fatalf("Invalid key server retry backoff: %d", keyServerRetryBackoff)
+	}
+
+	p := sypgp.DefaultRetryPolicy
+	p.MaxAttempts = keyServerRetries + 1
+	p.InitialBackoff = time.Duration(keyServerRetryBackoff) * time.Millisecond
+	sypgp.SetRetryPolicy(p)
+}
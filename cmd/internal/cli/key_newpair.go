@@ -95,6 +95,8 @@ type keyNewPairOptions struct {
 func runNewPairCmd(cmd *cobra.Command, args []string) {
 	ctx := context.TODO()
 
+	setKeyServerRetryPolicy()
+
 	keyring := sypgp.NewHandle("")
 
 	opts, err := collectInput(cmd)
@@ -107,6 +109,8 @@ func runNewPairCmd(cmd *cobra.Command, args []string) {
 	fmt.Printf("Generating Entity and OpenPGP Key Pair... ")
 	key, err := keyring.GenKeyPair(opts.GenKeyPairOptions)
 	if err != nil {
+		// Print the missing newline if there’s an error
+		fmt.Printf("\n")
 		sylog.Errorf("creating newpair failed: %v", err)
 		os.Exit(2)
 	}
@@ -76,6 +76,8 @@ func doKeyPushCmd(ctx context.Context, fingerprint string, url string) error {
 }
 
 func handleKeyFlags(cmd *cobra.Command) {
+	setKeyServerRetryPolicy()
+
 	// if we can load config and if default endpoint is set, use that
 	// otherwise fall back on regular authtoken and URI behavior
 	endpoint, err := sylabsRemote(remoteConfig)
@@ -0,0 +1,32 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// PluginCompactCmd compresses the image stored for an installed plugin.
+//
+// singularity plugin compact <name>
+var PluginCompactCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.CompactPlugin(args[0]); err != nil {
+			sylog.Fatalf("Failed to compact plugin %q: %s.", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.PluginCompactUse,
+	Short:   docs.PluginCompactShort,
+	Long:    docs.PluginCompactLong,
+	Example: docs.PluginCompactExample,
+}
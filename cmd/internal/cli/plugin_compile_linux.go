@@ -38,10 +38,32 @@ var pluginCompileDisableMinorCheckFlag = cmdline.Flag{
 	Usage:        "disable minor package version check",
 }
 
+// --version
+var pluginCompileVersion string
+var pluginCompileVersionFlag = cmdline.Flag{
+	ID:           "pluginCompileVersionFlag",
+	Value:        &pluginCompileVersion,
+	DefaultValue: "",
+	Name:         "version",
+	Usage:        "semantic version stamped into the plugin manifest",
+}
+
+// --compress
+var pluginCompileCompress string
+var pluginCompileCompressFlag = cmdline.Flag{
+	ID:           "pluginCompileCompressFlag",
+	Value:        &pluginCompileCompress,
+	DefaultValue: "",
+	Name:         "compress",
+	Usage:        "compress the plugin object stored in the SIF with gzip or zstd",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterFlagForCmd(&pluginCompileOutFlag, PluginCompileCmd)
 		cmdManager.RegisterFlagForCmd(&pluginCompileDisableMinorCheckFlag, PluginCompileCmd)
+		cmdManager.RegisterFlagForCmd(&pluginCompileVersionFlag, PluginCompileCmd)
+		cmdManager.RegisterFlagForCmd(&pluginCompileCompressFlag, PluginCompileCmd)
 	})
 }
 
@@ -72,7 +94,7 @@ var PluginCompileCmd = &cobra.Command{
 		buildTags := buildcfg.GO_BUILD_TAGS
 
 		sylog.Debugf("sourceDir: %s; sifPath: %s", sourceDir, destSif)
-		err = singularity.CompilePlugin(sourceDir, destSif, buildTags, disableMinorCheck)
+		err = singularity.CompilePlugin(sourceDir, destSif, buildTags, pluginCompileVersion, pluginCompileCompress, disableMinorCheck)
 		if err != nil {
 			sylog.Fatalf("Plugin compile failed with error: %s", err)
 		}
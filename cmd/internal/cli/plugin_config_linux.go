@@ -0,0 +1,226 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+// --effective
+var pluginConfigGetEffective bool
+var pluginConfigGetEffectiveFlag = cmdline.Flag{
+	ID:           "pluginConfigGetEffectiveFlag",
+	Value:        &pluginConfigGetEffective,
+	DefaultValue: false,
+	Name:         "effective",
+	Usage:        "display the values overridden by the environment variables of the current environment",
+}
+
+// --user
+var pluginConfigGetUser bool
+var pluginConfigGetUserFlag = cmdline.Flag{
+	ID:           "pluginConfigGetUserFlag",
+	Value:        &pluginConfigGetUser,
+	DefaultValue: false,
+	Name:         "user",
+	Usage:        "display the values of the user configuration overlay merged over the system configuration",
+}
+
+// --show-origin
+var pluginConfigGetShowOrigin bool
+var pluginConfigGetShowOriginFlag = cmdline.Flag{
+	ID:           "pluginConfigGetShowOriginFlag",
+	Value:        &pluginConfigGetShowOrigin,
+	DefaultValue: false,
+	Name:         "show-origin",
+	Usage:        "display the configuration file setting each value, the configuration file or a drop-in file",
+}
+
+// --reset
+var pluginConfigReset bool
+var pluginConfigResetFlag = cmdline.Flag{
+	ID:           "pluginConfigResetFlag",
+	Value:        &pluginConfigReset,
+	DefaultValue: false,
+	Name:         "reset",
+	Usage:        "restore the default configuration, the current configuration file is saved next to it",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginConfigResetFlag, PluginConfigCmd)
+		cmdManager.RegisterFlagForCmd(&pluginConfigGetEffectiveFlag, PluginConfigGetCmd)
+		cmdManager.RegisterFlagForCmd(&pluginConfigGetShowOriginFlag, PluginConfigGetCmd)
+		cmdManager.RegisterFlagForCmd(&pluginConfigGetUserFlag, PluginConfigGetCmd)
+	})
+}
+
+// PluginConfigCmd sets configuration keys of the named plugin, or
+// validates its configuration file when no key is given, or restores
+// its default configuration with --reset.
+//
+// singularity plugin config [config options...] <name> [<key>=<value>...]
+var PluginConfigCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pluginConfigReset {
+			if len(args) > 1 {
+				sylog.Fatalf("Keys can't be set along with --reset.")
+			}
+			if err := singularity.ResetPluginConfig(args[0]); err != nil {
+				pluginConfigFatal("reset configuration of", args[0], err)
+			}
+			return
+		}
+
+		err := singularity.ConfigurePlugin(args[0], args[1:])
+		if err != nil {
+			if os.IsNotExist(err) {
+				sylog.Fatalf("Failed to configure plugin %q: plugin not found.", args[0])
+			}
+
+			// The above call to sylog.Fatalf terminates the
+			// program, so we are either printing the above
+			// or this, not both.
+			sylog.Fatalf("Failed to configure plugin %q: %s.", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+
+	Use:     docs.PluginConfigUse,
+	Short:   docs.PluginConfigShort,
+	Long:    docs.PluginConfigLong,
+	Example: docs.PluginConfigExample,
+}
+
+// pluginConfigFatal reports the failure of the configuration command
+// cmd of the named plugin.
+func pluginConfigFatal(cmd, name string, err error) {
+	if os.IsNotExist(err) {
+		sylog.Fatalf("Failed to %s plugin %q: plugin not found.", cmd, name)
+	}
+	sylog.Fatalf("Failed to %s plugin %q: %s.", cmd, name, err)
+}
+
+// PluginConfigGetCmd displays the configuration keys set for the
+// named plugin.
+//
+// singularity plugin config get <name> [<key>]
+var PluginConfigGetCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		n := 0
+		for _, set := range []bool{pluginConfigGetEffective, pluginConfigGetUser, pluginConfigGetShowOrigin} {
+			if set {
+				n++
+			}
+		}
+		if n > 1 {
+			sylog.Fatalf("Only one of --effective, --user and --show-origin can be used.")
+		}
+		key := ""
+		if len(args) > 1 {
+			key = args[1]
+		}
+		if err := singularity.ShowPluginConfig(args[0], key, pluginConfigGetEffective, pluginConfigGetUser, pluginConfigGetShowOrigin); err != nil {
+			pluginConfigFatal("get configuration of", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.RangeArgs(1, 2),
+
+	Use:     docs.PluginConfigGetUse,
+	Short:   docs.PluginConfigGetShort,
+	Long:    docs.PluginConfigGetLong,
+	Example: docs.PluginConfigGetExample,
+}
+
+// PluginConfigSetCmd sets a configuration key of the named plugin.
+//
+// singularity plugin config set <name> <key> <value>
+var PluginConfigSetCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ConfigurePlugin(args[0], []string{args[1] + "=" + args[2]}); err != nil {
+			pluginConfigFatal("configure", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(3),
+
+	Use:     docs.PluginConfigSetUse,
+	Short:   docs.PluginConfigSetShort,
+	Long:    docs.PluginConfigSetLong,
+	Example: docs.PluginConfigSetExample,
+}
+
+// PluginConfigUnsetCmd unsets configuration keys of the named plugin.
+//
+// singularity plugin config unset <name> <key>...
+var PluginConfigUnsetCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.UnsetPluginConfig(args[0], args[1:]); err != nil {
+			pluginConfigFatal("configure", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(2),
+
+	Use:     docs.PluginConfigUnsetUse,
+	Short:   docs.PluginConfigUnsetShort,
+	Long:    docs.PluginConfigUnsetLong,
+	Example: docs.PluginConfigUnsetExample,
+}
+
+// PluginConfigExportCmd writes the configuration of the named plugin
+// to a file or to the standard output.
+//
+// singularity plugin config export <name> [<file>]
+var PluginConfigExportCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		file := ""
+		if len(args) > 1 {
+			file = args[1]
+		}
+		if err := singularity.ExportPluginConfig(args[0], file); err != nil {
+			pluginConfigFatal("export configuration of", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.RangeArgs(1, 2),
+
+	Use:     docs.PluginConfigExportUse,
+	Short:   docs.PluginConfigExportShort,
+	Long:    docs.PluginConfigExportLong,
+	Example: docs.PluginConfigExportExample,
+}
+
+// PluginConfigImportCmd installs the configuration exported by
+// PluginConfigExportCmd as the configuration of the named plugin.
+//
+// singularity plugin config import <name> <file>
+var PluginConfigImportCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := singularity.ImportPluginConfig(args[0], args[1]); err != nil {
+			pluginConfigFatal("import configuration of", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.PluginConfigImportUse,
+	Short:   docs.PluginConfigImportShort,
+	Long:    docs.PluginConfigImportLong,
+	Example: docs.PluginConfigImportExample,
+}
@@ -12,15 +12,33 @@ import (
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
 )
 
+// -f|--force
+var pluginEnableForce bool
+var pluginEnableForceFlag = cmdline.Flag{
+	ID:           "pluginEnableForceFlag",
+	Value:        &pluginEnableForce,
+	DefaultValue: false,
+	Name:         "force",
+	ShortHand:    "f",
+	Usage:        "enable the plugin even if its configuration is invalid",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginEnableForceFlag, PluginEnableCmd)
+	})
+}
+
 // PluginEnableCmd enables the named plugin.
 //
-// singularity plugin enable <name>
+// singularity plugin enable [-f] <name>
 var PluginEnableCmd = &cobra.Command{
 	PreRun: CheckRootOrUnpriv,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := singularity.EnablePlugin(args[0])
+		err := singularity.EnablePlugin(args[0], pluginEnableForce)
 		if err != nil {
 			if os.IsNotExist(err) {
 				sylog.Fatalf("Failed to enable plugin %q: plugin not found.", args[0])
@@ -0,0 +1,29 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// PluginHostCmd runs a plugin object in an isolated process, it's
+// executed by singularity itself and is not meant to be called by users.
+var PluginHostCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := plugin.ServeHost(args[0]); err != nil {
+			sylog.Fatalf("Plugin host failed: %s", err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+
+	Hidden:  true,
+	Args:    cobra.ExactArgs(1),
+	Use:     "host <object path>",
+	Short:   "Serve the callbacks of a plugin running in an isolated process",
+	Example: "$ singularity plugin host /path/to/object.so",
+}
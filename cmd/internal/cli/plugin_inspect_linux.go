@@ -12,12 +12,67 @@ import (
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
 )
 
+// --history
+var pluginInspectHistory bool
+var pluginInspectHistoryFlag = cmdline.Flag{
+	ID:           "pluginInspectHistoryFlag",
+	Value:        &pluginInspectHistory,
+	DefaultValue: false,
+	Name:         "history",
+	Usage:        "display the installation history of an installed plugin",
+}
+
+// --changelog
+var pluginInspectChangelog bool
+var pluginInspectChangelogFlag = cmdline.Flag{
+	ID:           "pluginInspectChangelogFlag",
+	Value:        &pluginInspectChangelog,
+	DefaultValue: false,
+	Name:         "changelog",
+	Usage:        "display the release notes shipped with the plugin",
+}
+
+// --raw-urls
+var pluginInspectRawURLs bool
+var pluginInspectRawURLsFlag = cmdline.Flag{
+	ID:           "pluginInspectRawURLsFlag",
+	Value:        &pluginInspectRawURLs,
+	DefaultValue: false,
+	Name:         "raw-urls",
+	Usage:        "display invalid URLs as is, stripped of control characters, instead of (invalid URL)",
+}
+
+// --keyserver
+var pluginInspectKeyServer string
+var pluginInspectKeyServerFlag = cmdline.Flag{
+	ID:           "pluginInspectKeyServerFlag",
+	Value:        &pluginInspectKeyServer,
+	DefaultValue: "",
+	Name:         "keyserver",
+	Usage:        "fetch the signing keys missing from the local keyring from this key server URL",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginInspectHistoryFlag, PluginInspectCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInspectChangelogFlag, PluginInspectCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInspectRawURLsFlag, PluginInspectCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInspectKeyServerFlag, PluginInspectCmd)
+	})
+}
+
 // PluginInspectCmd displays information about a plugin.
 var PluginInspectCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
-		err := singularity.InspectPlugin(args[0])
+		var err error
+		if pluginInspectChangelog {
+			err = singularity.PluginChangelog(args[0])
+		} else {
+			err = singularity.InspectPlugin(args[0], pluginInspectHistory, pluginInspectRawURLs, pluginInspectKeyServer, authToken)
+		}
 		if err != nil {
 			if os.IsNotExist(err) {
 				sylog.Fatalf("Failed to inspect plugin %q: plugin not found.", args[0])
@@ -24,20 +24,54 @@ var pluginInstallNameFlag = cmdline.Flag{
 	Usage:        "name to install the plugin as, defaults to the value in the manifest",
 }
 
+// --compress
+var pluginInstallCompress bool
+var pluginInstallCompressFlag = cmdline.Flag{
+	ID:           "pluginInstallCompressFlag",
+	Value:        &pluginInstallCompress,
+	DefaultValue: false,
+	Name:         "compress",
+	Usage:        "store the installed plugin image compressed with zstd",
+}
+
+// --thorough
+var pluginInstallThorough bool
+var pluginInstallThoroughFlag = cmdline.Flag{
+	ID:           "pluginInstallThoroughFlag",
+	Value:        &pluginInstallThorough,
+	DefaultValue: false,
+	Name:         "thorough",
+	Usage:        "check the internal consistency of the plugin image before installing it",
+}
+
+// --dev
+var pluginInstallDev bool
+var pluginInstallDevFlag = cmdline.Flag{
+	ID:           "pluginInstallDevFlag",
+	Value:        &pluginInstallDev,
+	DefaultValue: false,
+	Name:         "dev",
+	Usage:        "install an unpacked plugin directory holding plugin.so and plugin.manifest, for development",
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterFlagForCmd(&pluginInstallNameFlag, PluginInstallCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInstallCompressFlag, PluginInstallCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInstallThoroughFlag, PluginInstallCmd)
+		cmdManager.RegisterFlagForCmd(&pluginInstallDevFlag, PluginInstallCmd)
 	})
 }
 
-// PluginInstallCmd takes a compiled plugin.sif file and installs it
-// in the appropriate location.
+// PluginInstallCmd takes a compiled plugin.sif file, or an unpacked
+// plugin directory with --dev, and installs it in the appropriate
+// location.
 //
-// singularity plugin install <path> [-n name]
+// singularity plugin install <path> [-n name] [--compress] [--thorough] [--dev]
 var PluginInstallCmd = &cobra.Command{
 	PreRun: CheckRootOrUnpriv,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := singularity.InstallPlugin(args[0], pluginName)
+		err := singularity.InstallPlugin(args[0], pluginName, pluginInstallCompress, pluginInstallThorough, pluginInstallDev)
 		if err != nil {
 			sylog.Fatalf("Failed to install plugin %q: %s.", args[0], err)
 		}
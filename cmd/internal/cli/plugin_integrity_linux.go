@@ -0,0 +1,43 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// PluginIntegrityCmd checks the internal consistency of a plugin image.
+//
+// singularity plugin integrity <path>
+var PluginIntegrityCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := singularity.CheckPluginIntegrity(args[0])
+		if err != nil {
+			sylog.Fatalf("Failed to check integrity of plugin image %q: %s.", args[0], err)
+		}
+
+		for _, f := range report.Findings {
+			fmt.Printf("Problem: %s\n", f)
+		}
+		if !report.OK() {
+			fmt.Printf("Result: failed\n")
+			sylog.Fatalf("Plugin image %q is inconsistent: %d problem(s) found.", args[0], len(report.Findings))
+		}
+		fmt.Printf("Result: passed\n")
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+
+	Use:     docs.PluginIntegrityUse,
+	Short:   docs.PluginIntegrityShort,
+	Long:    docs.PluginIntegrityLong,
+	Example: docs.PluginIntegrityExample,
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+// -r|--remove
+var pluginLabelRemove []string
+var pluginLabelRemoveFlag = cmdline.Flag{
+	ID:           "pluginLabelRemoveFlag",
+	Value:        &pluginLabelRemove,
+	DefaultValue: []string{},
+	Name:         "remove",
+	ShortHand:    "r",
+	Usage:        "remove the label key (can be specified multiple times)",
+	Tag:          "<key>",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginLabelRemoveFlag, PluginLabelCmd)
+	})
+}
+
+// PluginLabelCmd sets or removes labels on the named plugin.
+//
+// singularity plugin label [-r <key>] <name> [<key>=<value>...]
+var PluginLabelCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := singularity.LabelPlugin(args[0], args[1:], pluginLabelRemove)
+		if err != nil {
+			if os.IsNotExist(err) {
+				sylog.Fatalf("Failed to label plugin %q: plugin not found.", args[0])
+			}
+
+			// The above call to sylog.Fatalf terminates the
+			// program, so we are either printing the above
+			// or this, not both.
+			sylog.Fatalf("Failed to label plugin %q: %s.", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+
+	Use:     docs.PluginLabelUse,
+	Short:   docs.PluginLabelShort,
+	Long:    docs.PluginLabelLong,
+	Example: docs.PluginLabelExample,
+}
@@ -22,8 +22,21 @@ func init() {
 		cmdManager.RegisterSubCmd(PluginCmd, PluginEnableCmd)
 		cmdManager.RegisterSubCmd(PluginCmd, PluginDisableCmd)
 		cmdManager.RegisterSubCmd(PluginCmd, PluginCompileCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginRecompileCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginCompactCmd)
 		cmdManager.RegisterSubCmd(PluginCmd, PluginInspectCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginCheckCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginIntegrityCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginLabelCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginManifestCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginConfigCmd)
+		cmdManager.RegisterSubCmd(PluginConfigCmd, PluginConfigGetCmd)
+		cmdManager.RegisterSubCmd(PluginConfigCmd, PluginConfigSetCmd)
+		cmdManager.RegisterSubCmd(PluginConfigCmd, PluginConfigUnsetCmd)
+		cmdManager.RegisterSubCmd(PluginConfigCmd, PluginConfigExportCmd)
+		cmdManager.RegisterSubCmd(PluginConfigCmd, PluginConfigImportCmd)
 		cmdManager.RegisterSubCmd(PluginCmd, PluginCreateCmd)
+		cmdManager.RegisterSubCmd(PluginCmd, PluginHostCmd)
 	})
 }
 
@@ -10,12 +10,67 @@ import (
 	"github.com/sylabs/singularity/docs"
 	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
 )
 
+// -a|--all
+var pluginListAll bool
+var pluginListAllFlag = cmdline.Flag{
+	ID:           "pluginListAllFlag",
+	Value:        &pluginListAll,
+	DefaultValue: false,
+	Name:         "all",
+	ShortHand:    "a",
+	Usage:        "display installation details of each plugin",
+}
+
+// -l|--label
+var pluginListLabels []string
+var pluginListLabelFlag = cmdline.Flag{
+	ID:           "pluginListLabelFlag",
+	Value:        &pluginListLabels,
+	DefaultValue: []string{},
+	Name:         "label",
+	ShortHand:    "l",
+	Usage:        "only list plugins having the label key=value (can be specified multiple times)",
+	Tag:          "<key=value>",
+}
+
+// -k|--keyword
+var pluginListKeywords []string
+var pluginListKeywordFlag = cmdline.Flag{
+	ID:           "pluginListKeywordFlag",
+	Value:        &pluginListKeywords,
+	DefaultValue: []string{},
+	Name:         "keyword",
+	ShortHand:    "k",
+	Usage:        "only list plugins declaring the keyword in their manifest (can be specified multiple times)",
+	Tag:          "<keyword>",
+}
+
+// --raw-urls
+var pluginListRawURLs bool
+var pluginListRawURLsFlag = cmdline.Flag{
+	ID:           "pluginListRawURLsFlag",
+	Value:        &pluginListRawURLs,
+	DefaultValue: false,
+	Name:         "raw-urls",
+	Usage:        "display invalid URLs as is, stripped of control characters, instead of (invalid URL)",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginListAllFlag, PluginListCmd)
+		cmdManager.RegisterFlagForCmd(&pluginListLabelFlag, PluginListCmd)
+		cmdManager.RegisterFlagForCmd(&pluginListKeywordFlag, PluginListCmd)
+		cmdManager.RegisterFlagForCmd(&pluginListRawURLsFlag, PluginListCmd)
+	})
+}
+
 // PluginListCmd lists the plugins installed in the system.
 var PluginListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
-		err := singularity.ListPlugins()
+		err := singularity.ListPlugins(pluginListAll, pluginListRawURLs, pluginListLabels, pluginListKeywords)
 		if err != nil {
 			sylog.Fatalf("Failed to get a list of installed plugins: %s.", err)
 		}
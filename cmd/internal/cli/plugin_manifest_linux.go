@@ -0,0 +1,61 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+// -o|--out
+var pluginManifestOut string
+var pluginManifestOutFlag = cmdline.Flag{
+	ID:           "pluginManifestOutFlag",
+	Value:        &pluginManifestOut,
+	DefaultValue: "",
+	Name:         "out",
+	ShortHand:    "o",
+	Usage:        "path of the SIF output file, the image is updated in place by default",
+}
+
+// --strip-signatures
+var pluginManifestStripSignatures bool
+var pluginManifestStripSignaturesFlag = cmdline.Flag{
+	ID:           "pluginManifestStripSignaturesFlag",
+	Value:        &pluginManifestStripSignatures,
+	DefaultValue: false,
+	Name:         "strip-signatures",
+	Usage:        "remove the signatures invalidated by the new manifest",
+}
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginManifestOutFlag, PluginManifestCmd)
+		cmdManager.RegisterFlagForCmd(&pluginManifestStripSignaturesFlag, PluginManifestCmd)
+	})
+}
+
+// PluginManifestCmd replaces the manifest of a plugin image.
+//
+// singularity plugin manifest [-o <path>] [--strip-signatures] <image> <manifest>
+var PluginManifestCmd = &cobra.Command{
+	Run: func(cmd *cobra.Command, args []string) {
+		err := singularity.UpdatePluginManifest(args[0], args[1], pluginManifestOut, pluginManifestStripSignatures)
+		if err != nil {
+			sylog.Fatalf("Failed to update manifest of plugin image %s: %s.", args[0], err)
+		}
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(2),
+
+	Use:     docs.PluginManifestUse,
+	Short:   docs.PluginManifestShort,
+	Long:    docs.PluginManifestLong,
+	Example: docs.PluginManifestExample,
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/cmdline"
+)
+
+func init() {
+	addCmdInit(func(cmdManager *cmdline.CommandManager) {
+		cmdManager.RegisterFlagForCmd(&pluginCompileDisableMinorCheckFlag, PluginRecompileCmd)
+	})
+}
+
+// PluginRecompileCmd recompiles an installed plugin from its source
+// and reinstalls it.
+//
+// singularity plugin recompile <name> [<source>]
+var PluginRecompileCmd = &cobra.Command{
+	PreRun: CheckRootOrUnpriv,
+	Run: func(cmd *cobra.Command, args []string) {
+		sourceDir := ""
+		if len(args) > 1 {
+			sourceDir = args[1]
+		}
+
+		oldDigest, newDigest, err := singularity.RecompilePlugin(args[0], sourceDir, buildcfg.GO_BUILD_TAGS, disableMinorCheck)
+		if err != nil {
+			sylog.Fatalf("Failed to recompile plugin %q: %s.", args[0], err)
+		}
+		fmt.Printf("Plugin %s recompiled\n", args[0])
+		fmt.Printf("Previous binary sha256: %s\n", oldDigest)
+		fmt.Printf("New binary sha256:      %s\n", newDigest)
+	},
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.RangeArgs(1, 2),
+
+	Use:     docs.PluginRecompileUse,
+	Short:   docs.PluginRecompileShort,
+	Long:    docs.PluginRecompileLong,
+	Example: docs.PluginRecompileExample,
+}
@@ -7,6 +7,7 @@ package cli
 
 import (
 	"context"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -21,6 +22,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	net "github.com/sylabs/singularity/pkg/client/net"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	"github.com/sylabs/singularity/pkg/cmdline"
 )
 
@@ -146,6 +148,9 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&dockerPasswordFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&dockerLoginFlag, PullCmd)
 
+		cmdManager.RegisterFlagForCmd(&proxyUsernameFlag, PullCmd)
+		cmdManager.RegisterFlagForCmd(&proxyPasswordFlag, PullCmd)
+
 		cmdManager.RegisterFlagForCmd(&buildNoCleanupFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&pullAllowUnsignedFlag, PullCmd)
 		cmdManager.RegisterFlagForCmd(&pullAllowUnauthenticatedFlag, PullCmd)
@@ -168,6 +173,8 @@ var PullCmd = &cobra.Command{
 func pullRun(cmd *cobra.Command, args []string) {
 	ctx := context.TODO()
 
+	setProxyConfig()
+
 	imgCache := getCacheHandle(cache.Config{Disable: disableCache})
 	if imgCache == nil {
 		sylog.Fatalf("Failed to create an image cache handle")
@@ -208,8 +215,9 @@ func pullRun(cmd *cobra.Command, args []string) {
 		handlePullFlags(cmd)
 
 		libraryConfig := &client.Config{
-			BaseURL:   pullLibraryURI,
-			AuthToken: authToken,
+			BaseURL:    pullLibraryURI,
+			AuthToken:  authToken,
+			HTTPClient: &http.Client{Transport: proxy.NewTransport()},
 		}
 		lib, err := singularity.NewLibrary(libraryConfig, imgCache, keyServerURL)
 		if err != nil {
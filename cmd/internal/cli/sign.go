@@ -7,6 +7,7 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
@@ -18,6 +19,9 @@ import (
 var (
 	privKey int // -k encryption key (index from 'keys list') specification
 	signAll bool
+
+	pkcs11Module string
+	pkcs11Label  string
 )
 
 // -g|--group-id
@@ -80,6 +84,26 @@ var signAllFlag = cmdline.Flag{
 	Usage:        "sign all non-signature partitions",
 }
 
+// --pkcs11-module
+var signPKCS11ModuleFlag = cmdline.Flag{
+	ID:           "signPKCS11ModuleFlag",
+	Value:        &pkcs11Module,
+	DefaultValue: "",
+	Name:         "pkcs11-module",
+	Usage:        "sign with a PKCS#11 token key, using the provider library at this path",
+	EnvKeys:      []string{"PKCS11_MODULE"},
+}
+
+// --pkcs11-label
+var signPKCS11LabelFlag = cmdline.Flag{
+	ID:           "signPKCS11LabelFlag",
+	Value:        &pkcs11Label,
+	DefaultValue: "",
+	Name:         "pkcs11-label",
+	Usage:        "label of the PKCS#11 token key to sign with",
+	EnvKeys:      []string{"PKCS11_LABEL"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(SignCmd)
@@ -90,6 +114,8 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&signSifDescIDFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signKeyIdxFlag, SignCmd)
 		cmdManager.RegisterFlagForCmd(&signAllFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&signPKCS11ModuleFlag, SignCmd)
+		cmdManager.RegisterFlagForCmd(&signPKCS11LabelFlag, SignCmd)
 	})
 }
 
@@ -116,7 +142,19 @@ func doSignCmd(cmd *cobra.Command, cpath string) {
 	}
 
 	fmt.Printf("Signing image: %s\n", cpath)
-	if err := signing.Sign(cpath, id, isGroup, signAll, privKey); err != nil {
+	if pkcs11Module != "" || pkcs11Label != "" {
+		if cmd.Flags().Changed(signKeyIdxFlag.Name) {
+			sylog.Fatalf("--keyidx can't be used with a PKCS#11 token key")
+		}
+		cfg := signing.PKCS11Config{
+			Module: pkcs11Module,
+			Label:  pkcs11Label,
+			PIN:    os.Getenv("SINGULARITY_PKCS11_PIN"),
+		}
+		if err := signing.SignPKCS11(cpath, id, isGroup, signAll, cfg); err != nil {
+			sylog.Fatalf("Failed to sign container: %s", err)
+		}
+	} else if err := signing.Sign(cpath, id, isGroup, signAll, privKey); err != nil {
 		sylog.Fatalf("Failed to sign container: %s", err)
 	}
 	fmt.Printf("Signature created and applied to %s\n", cpath)
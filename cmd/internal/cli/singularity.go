@@ -7,6 +7,7 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -20,12 +21,14 @@ import (
 	ocitypes "github.com/containers/image/v5/types"
 	"github.com/spf13/cobra"
 	"github.com/sylabs/singularity/docs"
+	"github.com/sylabs/singularity/internal/app/singularity"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/plugin"
 	scs "github.com/sylabs/singularity/internal/pkg/remote"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/auth"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	"github.com/sylabs/singularity/pkg/cmdline"
 	clicallback "github.com/sylabs/singularity/pkg/plugin/callback/cli"
 	"github.com/sylabs/singularity/pkg/syfs"
@@ -55,6 +58,8 @@ var (
 	dockerAuthConfig ocitypes.DockerAuthConfig
 	dockerLogin      bool
 
+	proxyConfig proxy.Config
+
 	encryptionPEMPath   string
 	promptForPassphrase bool
 	forceOverwrite      bool
@@ -73,6 +78,8 @@ var (
 	silent  bool
 	verbose bool
 	quiet   bool
+
+	pluginMessageLevel string
 )
 
 // -d|--debug
@@ -124,6 +131,15 @@ var singVerboseFlag = cmdline.Flag{
 	Usage:        "print additional information",
 }
 
+// --plugin-messagelevel
+var singPluginMessageLevelFlag = cmdline.Flag{
+	ID:           "singPluginMessageLevelFlag",
+	Value:        &pluginMessageLevel,
+	DefaultValue: "",
+	Name:         "plugin-messagelevel",
+	Usage:        "set the message level of plugins by name, as a comma separated list of name=level (e.g. example.com/plugin=debug)",
+}
+
 var singTokenFileFlag = cmdline.Flag{
 	ID:           "singTokenFileFlag",
 	Value:        &tokenFile,
@@ -166,6 +182,28 @@ var dockerLoginFlag = cmdline.Flag{
 	EnvKeys:      []string{"DOCKER_LOGIN"},
 }
 
+// --proxy-username
+var proxyUsernameFlag = cmdline.Flag{
+	ID:           "proxyUsernameFlag",
+	Value:        &proxyConfig.Username,
+	DefaultValue: "",
+	Name:         "proxy-username",
+	Usage:        "specify a username for the HTTP_PROXY/HTTPS_PROXY proxy authentication",
+	Hidden:       true,
+	EnvKeys:      []string{"PROXY_USERNAME"},
+}
+
+// --proxy-password
+var proxyPasswordFlag = cmdline.Flag{
+	ID:           "proxyPasswordFlag",
+	Value:        &proxyConfig.Password,
+	DefaultValue: "",
+	Name:         "proxy-password",
+	Usage:        "specify a password for the HTTP_PROXY/HTTPS_PROXY proxy authentication",
+	Hidden:       true,
+	EnvKeys:      []string{"PROXY_PASSWORD"},
+}
+
 // --passphrase
 var commonPromptForPassphraseFlag = cmdline.Flag{
 	ID:           "commonPromptForPassphraseFlag",
@@ -209,7 +247,7 @@ var commonNoHTTPSFlag = cmdline.Flag{
 var commonTmpDirFlag = cmdline.Flag{
 	ID:           "commonTmpDirFlag",
 	Value:        &tmpDir,
-	DefaultValue: os.TempDir(),
+	DefaultValue: fs.TempDir(),
 	Hidden:       true,
 	Name:         "tmpdir",
 	Usage:        "specify a temporary directory to use for build",
@@ -248,6 +286,14 @@ func setSylogMessageLevel() {
 	}
 
 	sylog.SetLevel(level)
+
+	// the levels inherited from SINGULARITY_PLUGIN_MESSAGELEVEL
+	// are kept when the flag is not set
+	if pluginMessageLevel != "" {
+		if err := sylog.SetNamedLevels(pluginMessageLevel); err != nil {
+			sylog.Fatalf("While setting plugin message levels: %s", err)
+		}
+	}
 }
 
 func setSylogColor() {
@@ -326,7 +372,15 @@ func Init(loadPlugins bool) {
 	// set persistent pre run function here to avoid initialization loop error
 	singularityCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		persistentPreRun(cmd, args)
-		return cmdManager.UpdateCmdFlagFromEnv(cmd, envPrefix)
+		if err := cmdManager.UpdateCmdFlagFromEnv(cmd, envPrefix); err != nil {
+			return err
+		}
+		// the temporary directory of the commands with a --tmpdir
+		// flag applies to all the temporary files they create
+		if tmpDir != "" {
+			fs.SetTempDir(tmpDir)
+		}
+		return nil
 	}
 
 	cmdManager.RegisterFlagForCmd(&singDebugFlag, singularityCmd)
@@ -334,9 +388,11 @@ func Init(loadPlugins bool) {
 	cmdManager.RegisterFlagForCmd(&singSilentFlag, singularityCmd)
 	cmdManager.RegisterFlagForCmd(&singQuietFlag, singularityCmd)
 	cmdManager.RegisterFlagForCmd(&singVerboseFlag, singularityCmd)
+	cmdManager.RegisterFlagForCmd(&singPluginMessageLevelFlag, singularityCmd)
 	cmdManager.RegisterFlagForCmd(&singTokenFileFlag, singularityCmd)
 
 	cmdManager.RegisterCmd(VersionCmd)
+	cmdManager.RegisterFlagForCmd(&versionJSONFlag, VersionCmd)
 
 	// register all others commands/flags
 	for _, cmdInit := range cmdInits {
@@ -436,15 +492,43 @@ func TraverseParentsUses(cmd *cobra.Command) string {
 	return cmd.Use + " "
 }
 
+// --json
+var versionJSON bool
+var versionJSONFlag = cmdline.Flag{
+	ID:           "versionJSONFlag",
+	Value:        &versionJSON,
+	DefaultValue: false,
+	Name:         "json",
+	Usage:        "print the version along with the runtime features supported, in JSON",
+}
+
 // VersionCmd displays installed singularity version
 var VersionCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(buildcfg.PACKAGE_VERSION)
+		if !versionJSON {
+			fmt.Println(buildcfg.PACKAGE_VERSION)
+			return
+		}
+
+		features, err := singularity.GetFeatures()
+		if err != nil {
+			sylog.Fatalf("While probing the runtime features: %s", err)
+		}
+		data, err := json.MarshalIndent(features, "", "  ")
+		if err != nil {
+			sylog.Fatalf("While encoding the runtime features: %s", err)
+		}
+		fmt.Println(string(data))
 	},
 
-	Use:   "version",
+	Use:   "version [version options...]",
 	Short: "Show the version for Singularity",
+	Long: `Show the version for Singularity, with --json along with the report of the
+runtime features the current user can use: setuid workflow, user namespaces,
+overlay, fakeroot, encrypted containers, cgroups and security modules. A
+feature has an "available" boolean and an optional "detail" explaining its
+availability.`,
 }
 
 // sylabsToken process the authentication Token
@@ -461,6 +545,17 @@ func sylabsToken(cmd *cobra.Command, args []string) {
 	}
 }
 
+// setProxyConfig sets the proxy credentials of the image pull
+// clients, it must be called before any request is sent.
+func setProxyConfig() {
+	if proxyConfig.Username == "" {
+		return
+	}
+	if err := proxy.SetConfig(&proxyConfig); err != nil {
+		sylog.Fatalf("While setting proxy credentials: %s", err)
+	}
+}
+
 func loadRemoteConf(filepath string) (*scs.Config, error) {
 	f, err := os.OpenFile(filepath, os.O_RDONLY, 0600)
 	if err != nil {
@@ -24,6 +24,8 @@ var (
 	localVerify bool   // -l flag
 	jsonVerify  bool   // -j flag
 	verifyAll   bool
+
+	verifyFingerprint string // --fingerprint option
 )
 
 // -u|--url
@@ -33,7 +35,7 @@ var verifyServerURIFlag = cmdline.Flag{
 	DefaultValue: defaultKeyServer,
 	Name:         "url",
 	ShortHand:    "u",
-	Usage:        "specify a URL for a key server",
+	Usage:        "specify a URL for a key server, or a comma separated list of URLs tried in order on failure",
 	EnvKeys:      []string{"URL"},
 }
 
@@ -108,6 +110,16 @@ var verifyAllFlag = cmdline.Flag{
 	Usage:        "verify all non-signature partitions",
 }
 
+// --fingerprint
+var verifyFingerprintFlag = cmdline.Flag{
+	ID:           "verifyFingerprintFlag",
+	Value:        &verifyFingerprint,
+	DefaultValue: "",
+	Name:         "fingerprint",
+	Usage:        "require the verified partitions to be signed by the key with this fingerprint only",
+	EnvKeys:      []string{"VERIFY_FINGERPRINT"},
+}
+
 func init() {
 	addCmdInit(func(cmdManager *cmdline.CommandManager) {
 		cmdManager.RegisterCmd(VerifyCmd)
@@ -120,6 +132,7 @@ func init() {
 		cmdManager.RegisterFlagForCmd(&verifyLocalFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyJSONFlag, VerifyCmd)
 		cmdManager.RegisterFlagForCmd(&verifyAllFlag, VerifyCmd)
+		cmdManager.RegisterFlagForCmd(&verifyFingerprintFlag, VerifyCmd)
 	})
 }
 
@@ -166,6 +179,11 @@ func doVerifyCmd(ctx context.Context, cmd *cobra.Command, cpath, url string) {
 	} else if err != nil {
 		sylog.Fatalf("Failed to verify: %s: %s", cpath, err)
 	}
+	if verifyFingerprint != "" {
+		if err := signing.CheckSigners(cpath, id, isGroup, verifyAll, verifyFingerprint); err != nil {
+			sylog.Fatalf("Failed to verify: %s: %s", cpath, err)
+		}
+	}
 	sylog.Infof("Container verified: %s", cpath)
 }
 
@@ -18,6 +18,7 @@ import (
 	"unsafe"
 
 	"github.com/sylabs/singularity/internal/app/starter"
+	"github.com/sylabs/singularity/internal/pkg/plugin"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine"
 	starterConfig "github.com/sylabs/singularity/internal/pkg/runtime/engine/config/starter"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -44,6 +45,9 @@ func startup() {
 	sconfig := starterConfig.NewConfig(starterConfig.SConfig(csconf))
 	// get JSON configuration originally passed from CLI
 	jsonConfig := sconfig.GetJSONConfig()
+	// the plugins loaded by the engines of the setuid workflow
+	// restrict their configuration overrides in all the stages
+	plugin.SetPrivileged(sconfig.GetIsSUID())
 
 	// get engine operations previously registered
 	// by the above import
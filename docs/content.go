@@ -61,7 +61,16 @@ Enterprise Performance Computing (EPC)`
       library://  an image library (default https://cloud.sylabs.io/library)
       docker://   a Docker registry (default Docker Hub)
       shub://     a Singularity registry (default Singularity Hub)
-      oras://     a supporting OCI registry`
+      oras://     a supporting OCI registry
+
+  SQUASHFS COMPRESSION:
+
+  The squashfs filesystem of a SIF image is compressed with gzip by default.
+  Another algorithm can be selected with --squashfs-comp, among gzip, lz4, xz
+  and zstd, provided it's supported by mksquashfs, along with its level with
+  --squashfs-comp-level, from 1 to 9 for gzip and from 1 to 22 for zstd. The
+  algorithm must also be supported by the kernel or squashfuse of the hosts
+  running the image.`
 
 	BuildExample string = `
 
@@ -155,7 +164,10 @@ Enterprise Performance Computing (EPC)`
       Build a base sandbox from DockerHub, make changes to it, then build sif
           $ singularity build --sandbox /tmp/debian docker://debian:latest
           $ singularity exec --writable /tmp/debian apt-get install python
-          $ singularity build /tmp/debian2.sif /tmp/debian`
+          $ singularity build /tmp/debian2.sif /tmp/debian
+
+      Build a sif image with a zstd compressed filesystem:
+          $ singularity build --squashfs-comp zstd --squashfs-comp-level 19 /tmp/debian3.sif library://debian:latest`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Cache
@@ -181,12 +193,18 @@ Enterprise Performance Computing (EPC)`
   SINGULARITY_CACHEDIR is not set). By default the entire cache is cleaned, use
   --name or --type flags to override this behavior. Note: if you use Singularity
   as root, cache will be stored in '/root/.singularity/.cache', to clean that
-  cache, you will need to run 'cache clean --all' as root, or with 'sudo'.`
+  cache, you will need to run 'cache clean --all' as root, or with 'sudo'.
+
+  The --pattern and --older-than flags select the cached images whose name
+  matches a shell pattern and which were not accessed for a duration, when
+  both are given an image must match both. Images in use by a running
+  container or instance are never removed.`
 	CacheCleanExample string = `
   All group commands have their own help output:
 
   $ singularity help cache clean --name cache_name.sif
   $ singularity help cache clean --type=library,oci
+  $ singularity cache clean --pattern 'ubuntu_*' --older-than 720h
   $ singularity cache clean --help`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -196,12 +214,15 @@ Enterprise Performance Computing (EPC)`
 	CacheListShort string = `List your local Singularity cache`
 	CacheListLong  string = `
   This will list your local cache (stored at $HOME/.singularity/cache if
-  SINGULARITY_CACHEDIR is not set).`
+  SINGULARITY_CACHEDIR is not set). With --long, each cached image is listed
+  with its last access time, whether it is in use and the reference it was
+  fetched from.`
 	CacheListExample string = `
   All group commands have their own help output:
 
   $ singularity help cache list
   $ singularity help cache list --type=library,oci
+  $ singularity cache list --long
   $ singularity cache list --help`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
@@ -301,7 +322,10 @@ Enterprise Performance Computing (EPC)`
   download a public key. Key rings are stored into (e.g., 
   $HOME/.singularity/sypgp).`
 	KeyPullExample string = `
-  $ singularity key pull 8883491F4268F173C6E5DC49EDECE4F3F38D871E`
+  $ singularity key pull 8883491F4268F173C6E5DC49EDECE4F3F38D871E
+
+  Pull a key from a mirror when the primary key server is unavailable:
+  $ singularity key pull --url https://keys.example.com,https://mirror.example.com 8883491F4268F173C6E5DC49EDECE4F3F38D871E`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// key push
@@ -522,6 +546,11 @@ Enterprise Performance Computing (EPC)`
   will be executed with the instance start command as well. You can optionally
   pass arguments to startscript
 
+  A health check can be declared with --health-cmd, a command run in the
+  instance, or --health-port, a TCP port of the instance. The check is run every
+  --health-interval and the result of the last one is recorded in the instance
+  file, an unhealthy instance is reported in the instance logs.
+
   singularity instance start accepts the following container formats` + formats
 	InstanceStartExample string = `
   $ singularity instance start /tmp/my-sql.sif mysql
@@ -537,7 +566,9 @@ Enterprise Performance Computing (EPC)`
   Singularity my-sql.sif>
 
   $ singularity instance stop /tmp/my-sql.sif mysql
-  Stopping /tmp/my-sql.sif mysql`
+  Stopping /tmp/my-sql.sif mysql
+
+  $ singularity instance start --health-port 3306 --health-interval 1m /tmp/my-sql.sif mysql`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// instance stop
@@ -709,9 +740,19 @@ Enterprise Performance Computing (EPC)`
   default without parameters, the command searches for the primary partition and 
   creates a verification block that is then added to the SIF container file.
   
-  To generate a keypair, see 'singularity help key newpair'`
+  To generate a keypair, see 'singularity help key newpair'
+
+  With --pkcs11-module and --pkcs11-label, the signature is made by a key
+  stored on a PKCS#11 token, or hardware security module, through the
+  pkcs11-tool program of OpenSC. The private key never leaves the token and
+  the token PIN is prompted, or read from SINGULARITY_PKCS11_PIN. The OpenPGP
+  public key of the token key must be in the public keyring, see
+  'singularity help key import', and the signature is verified as usual.`
 	SignExample string = `
-  $ singularity sign container.sif`
+  $ singularity sign container.sif
+
+  Sign with the key labeled "release" of a hardware token:
+  $ singularity sign --pkcs11-module /usr/lib64/opensc-pkcs11.so --pkcs11-label release container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// verify
@@ -724,9 +765,14 @@ Enterprise Performance Computing (EPC)`
   multiple data objects signed. By default the command searches for the primary 
   partition signature. If found, a list of all verification blocks applied on 
   the primary partition is gathered so that data integrity (hashing) and 
-  signature verification is done for all those blocks.`
+  signature verification is done for all those blocks. With --fingerprint,
+  the verification also fails unless all these blocks were signed by the key
+  with the given fingerprint, even if the other signers are trusted.`
 	VerifyExample string = `
-  $ singularity verify container.sif`
+  $ singularity verify container.sif
+
+  Require the container to be signed by the release key only:
+  $ singularity verify --fingerprint 8883491F4268F173C6E5DC49EDECE4F3F38D871E container.sif`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// Run-help
@@ -965,7 +1011,8 @@ Enterprise Performance Computing (EPC)`
 	OciMountUse   string = `mount <sif_image> <bundle_path>`
 	OciMountShort string = `Mount create an OCI bundle from SIF image (root user only)`
 	OciMountLong  string = `
-  Mount will mount and create an OCI bundle from a SIF image.`
+  Mount will mount and create an OCI bundle from a SIF image. A bundle
+  already mounted is shared along with its configuration.`
 	OciMountExample string = `
   $ singularity oci mount /tmp/example.sif /var/lib/singularity/bundles/example`
 
@@ -973,7 +1020,8 @@ Enterprise Performance Computing (EPC)`
 	OciUmountShort string = `Umount delete bundle (root user only)`
 	OciUmountLong  string = `
   Umount will umount an OCI bundle previously mounted with singularity oci 
-  mount.`
+  mount. A bundle mounted several times is only unmounted and deleted once
+  each mount has been released by an umount.`
 	OciUmountExample string = `
   $ singularity oci umount /var/lib/singularity/bundles/example`
 
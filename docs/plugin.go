@@ -28,9 +28,45 @@ const (
 	PluginCompileLong  string = `
   The 'plugin compile' command allows a developer to compile a Singularity 
   plugin in the expected environment. The provided host directory is the 
-  location of the plugin's source code. A compiled plugin is packed into a SIF file.`
+  location of the plugin's source code. A compiled plugin is packed into a SIF file.
+  A CHANGELOG.md, or CHANGELOG, file found in the source directory is packed
+  as the plugin release notes, displayed as plain text when the plugin is
+  upgraded and by 'plugin inspect --changelog'. A config.default.yaml file
+  found in the source directory is packed as the template of the plugin
+  configuration file, it's validated against the configuration schema of
+  the manifest. Plugins declaring the json or toml configuration format in
+  their manifest ship a config.default.json or config.default.toml file.
+  The --compress option packs the plugin binary compressed with gzip or
+  zstd, it is decompressed and checked against its declared size when the
+  plugin is installed. The source directory is recorded in the SIF file to
+  recompile the plugin once installed with 'plugin recompile'.`
 	PluginCompileExample string = `
-  $ singularity plugin compile $HOME/singularity/test-plugin`
+  $ singularity plugin compile $HOME/singularity/test-plugin
+
+  To stamp the version of the plugin manifest:
+  $ singularity plugin compile --version v1.2.0 $HOME/singularity/test-plugin
+
+  To pack a zstd compressed plugin binary:
+  $ singularity plugin compile --compress zstd $HOME/singularity/test-plugin`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin recompile command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginRecompileUse   string = `recompile [recompile options...] <name> [<host_path>]`
+	PluginRecompileShort string = `Recompile an installed Singularity plugin`
+	PluginRecompileLong  string = `
+  The 'plugin recompile' command compiles the installed plugin again against
+  the running Singularity, typically after an upgrade which made the plugin
+  unloadable, and reinstalls it in place. The source code is taken from the
+  given host directory, or from the directory recorded by 'plugin compile'.
+  The manifest of the installed plugin is kept, the configuration and the
+  enable state of the plugin are preserved. The signatures of the plugin
+  image are removed as the new plugin binary invalidates them. On failure
+  the installed plugin is left untouched. The sha256 of the previous and of
+  the new plugin binary are displayed.`
+	PluginRecompileExample string = `
+  $ singularity plugin recompile example.com/test-plugin
+  $ singularity plugin recompile example.com/test-plugin $HOME/singularity/test-plugin`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin install command
@@ -39,9 +75,55 @@ const (
 	PluginInstallShort string = `Install a compiled Singularity plugin`
 	PluginInstallLong  string = `
   The 'plugin install' command installs the compiled plugin found at plugin_path
-  into the appropriate directory on the host.`
+  into the appropriate directory on the host.
+
+  Plugin names under the sylabs.io/ and singularity/ namespaces, and under the
+  namespaces reserved in singularity.conf, can only be installed from a plugin
+  image signed by a key trusted for the namespace in singularity.conf, with its
+  public key present in the local keyring.
+
+  The generic data objects of the plugin image listed in the manifest assets,
+  or named with the asset: prefix, are extracted into the assets directory of
+  the plugin, their total size is limited to 256 MiB.
+
+  The configuration file of the plugin is generated from the configuration
+  template packed in the plugin image, or documents the configuration schema
+  of the manifest with its default values. The default configuration of the
+  installed version is also written to config.default: when upgrading a
+  plugin only this file is refreshed, the configuration file is preserved.
+  The configuration file is config.yaml, or config.json and config.toml for
+  plugins declaring these configuration formats in their manifest. A
+  customized configuration is converted when an upgrade changes the format.
+
+  With --compress the plugin image kept in the installation directory is
+  stored compressed with zstd, see 'plugin compact'. With --thorough the
+  internal consistency of the plugin image is checked before installing it,
+  see 'plugin integrity'.
+
+  With --dev plugin_path is an unpacked plugin directory, holding the compiled
+  plugin object plugin.so and its JSON manifest plugin.manifest, installed
+  without building a plugin image first to speed up the development of the
+  plugin. The image is packaged at installation, it's unsigned, and the plugin
+  is flagged as a development install by 'plugin list' and 'plugin inspect'
+  until it's installed again from an image.`
 	PluginInstallExample string = `
-  $ singularity plugin install $HOME/singularity/test-plugin/test-plugin.sif`
+  $ singularity plugin install $HOME/singularity/test-plugin/test-plugin.sif
+  $ singularity plugin install --compress $HOME/singularity/test-plugin/test-plugin.sif
+  $ singularity plugin install --dev $HOME/singularity/test-plugin/build`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin compact command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginCompactUse   string = `compact <name>`
+	PluginCompactShort string = `Compress the image of an installed plugin`
+	PluginCompactLong  string = `
+  The 'plugin compact' command compresses with zstd the plugin image stored
+  for the named installed plugin to save space. The plugin binary is not
+  affected. The image is decompressed transparently when the plugin is
+  inspected, verified, recompiled or exported, and its sha256 is checked
+  then so the original image is always restored byte for byte.`
+	PluginCompactExample string = `
+  $ singularity plugin compact example.com/test-plugin`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin uninstall command
@@ -59,22 +141,48 @@ const (
 	PluginListUse   string = `list [list options...]`
 	PluginListShort string = `List installed Singularity plugins`
 	PluginListLong  string = `
-  The 'plugin list' command lists the Singularity plugins installed on the host.`
+  The 'plugin list' command lists the Singularity plugins installed on the host.
+  With --all, installation details are displayed for each plugin. With
+  --label key=value, only the plugins having this label are listed. With
+  --keyword, only the plugins declaring this keyword in their manifest are
+  listed. Deprecated plugins and development installs (see 'plugin install
+  --dev') are marked as such, as are the plugins with an
+  invalid configuration, which are loaded with their default configuration,
+  or not loaded with 'plugin config check = strict'. URLs which are not valid
+  http(s) URLs are displayed as (invalid URL), --raw-urls displays them
+  stripped of control characters instead.`
 	PluginListExample string = `
   $ singularity plugin list
-  ENABLED  NAME
-      yes  example.org/plugin`
+  ENABLED  ID            VERSION       NAME
+      yes  77cfc6dbc1e7  v1.0.0        example.org/plugin
+       no  3e1f5a9c04d2  v0.9.2        example.org/legacy (deprecated)
+
+  $ singularity plugin list --all
+  ENABLED  ID            VERSION       NAME
+      yes  77cfc6dbc1e7  v1.0.0        example.org/plugin
+                                       Health: ok
+                                       ID: 77cfc6dbc1e7eb5ccc5b51458b41503d83e5000ce28d9658b1f138fcdbe50e9f
+                                       Installed by: alice via sudo (uid=0, euid=0, sudo_uid=1000)
+                                       Last modified by: root (uid=0, euid=0)
+
+  $ singularity plugin list --keyword gpu`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin enable command
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
-	PluginEnableUse   string = `enable <name>`
+	PluginEnableUse   string = `enable [enable options...] <name>`
 	PluginEnableShort string = `Enable an installed Singularity plugin`
 	PluginEnableLong  string = `
   The 'plugin enable' command allows a user to enable a plugin that is already
-  installed in the system and which has been previously disabled.`
+  installed in the system and which has been previously disabled.
+
+  The plugin configuration file is validated first, against the schema
+  declared by the plugin if any, and the plugin is not enabled if it is
+  invalid, unless --force is given. The configuration can be validated
+  beforehand with 'plugin config <name>'.`
 	PluginEnableExample string = `
-  $ singularity plugin enable example.org/plugin`
+  $ singularity plugin enable example.org/plugin
+  $ singularity plugin enable --force example.org/plugin`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin disable command
@@ -87,20 +195,243 @@ const (
 	PluginDisableExample string = `
   $ singularity plugin disable example.org/plugin`
 
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin label command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginLabelUse   string = `label [label options...] <name> [<key>=<value>...]`
+	PluginLabelShort string = `Set or remove labels of an installed Singularity plugin`
+	PluginLabelLong  string = `
+  The 'plugin label' command allows a user to tag an installed plugin with
+  key=value labels, like the team owning it, and to remove them with --remove.
+  Labels are displayed by 'plugin inspect' and can be used to filter the
+  output of 'plugin list' with --label.`
+	PluginLabelExample string = `
+  $ singularity plugin label example.org/plugin owner=hpc-team tier=experimental
+  $ singularity plugin label --remove tier example.org/plugin
+  $ singularity plugin list --label owner=hpc-team`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin manifest command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginManifestUse   string = `manifest [manifest options...] <image> <manifest>`
+	PluginManifestShort string = `Replace the manifest of a Singularity plugin image`
+	PluginManifestLong  string = `
+  The 'plugin manifest' command replaces the manifest of a plugin image with
+  the JSON manifest file, or adds it if the image has none, without compiling
+  the plugin again. The manifest is validated as it would be at installation
+  against the plugin binaries of the image, which are left untouched. The
+  image is updated in place unless --out is given. The signatures of the image
+  no longer verify with the new manifest, they are kept with a warning or
+  removed with --strip-signatures.`
+	PluginManifestExample string = `
+  $ singularity plugin manifest plugin.sif manifest.json
+  $ singularity plugin manifest --strip-signatures -o fixed.sif plugin.sif manifest.json
+  $ singularity plugin inspect fixed.sif`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigUse   string = `config [config options...] <name> [<key>=<value>...]`
+	PluginConfigShort string = `Set the configuration of an installed Singularity plugin`
+	PluginConfigLong  string = `
+  The 'plugin config' command sets keys of the configuration file of an
+  installed plugin, nested keys being joined by a dot. The keys and values are
+  validated against the configuration options declared in the plugin manifest,
+  as displayed by 'plugin inspect', values are converted to the type of their
+  option. Plugins declaring no configuration options have a free-form
+  configuration whose values are typed as YAML scalars, "true" being a
+  boolean. A YAML configuration file is edited in place, keeping its
+  comments, JSON and TOML files are regenerated. The file is replaced
+  atomically, the changes of a plugin configuration being
+  serialized. Without key, the configuration file is validated only.
+
+  The files of the config.d directory of the plugin, with the extension of
+  the configuration file, are drop-in files merged over the configuration
+  file in lexical order, later files overriding the keys of earlier ones.
+  Sections are merged key by key, any other value, a list included, is
+  replaced as a whole, a null value unsets the key or the section. The
+  validation applies to the merged configuration, 'plugin config' only
+  edits the configuration file and warns when a key it sets is overridden
+  by a drop-in file.
+
+  With --reset, the default configuration is regenerated from the installed
+  plugin image, as done at installation, and replaces the configuration file,
+  which is saved next to it as config.bak.<time>. The plugin must be
+  reinstalled if its image is missing.`
+	PluginConfigExample string = `
+  $ singularity plugin config example.org/plugin server.port=8080 verbose=true
+  $ singularity plugin config example.org/plugin
+  $ singularity plugin config --reset example.org/plugin`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config get command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigGetUse   string = `get [get options...] <name> [<key>]`
+	PluginConfigGetShort string = `Display the configuration of an installed Singularity plugin`
+	PluginConfigGetLong  string = `
+  The 'plugin config get' command displays the keys set in the configuration
+  file of an installed plugin as key=value, or the value of the given key
+  only. Lists are displayed comma separated. The configuration file is merged
+  with the drop-in files of the config.d directory of the plugin, see 'plugin
+  config', --show-origin displays the file setting each value.
+
+  The options marked as overridable by the plugin manifest can be set by each
+  user in the configuration overlay ~/.singularity/plugins/<name>/config.yaml,
+  or config.json or config.toml along the format of the plugin configuration,
+  merged over the system configuration when the plugin loads it in
+  unprivileged processes. The other keys of the overlay are ignored with a
+  warning, an invalid overlay is ignored as a whole. --user displays the
+  values of the overlay, --effective the values merged with it.
+
+  With --effective the values are displayed as the plugin loads them in the
+  current environment, overridden by the SINGULARITY_PLUGINCFG_<NAME>_<KEY>
+  environment variables, or SINGULARITY_PLUGIN_<NAME>_<KEY> when those are
+  not set. The overrides are checked against the type of
+  their option, in privileged (setuid) processes only the options marked
+  as overridable by the plugin manifest are overridden.`
+	PluginConfigGetExample string = `
+  $ singularity plugin config get example.org/plugin
+  $ singularity plugin config get example.org/plugin server.port
+  $ singularity plugin config get --show-origin example.org/plugin
+  $ singularity plugin config get --user example.org/plugin
+  $ SINGULARITY_PLUGINCFG_EXAMPLE_ORG_PLUGIN_SERVER_PORT=9090 \
+      singularity plugin config get --effective example.org/plugin`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config set command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigSetUse   string = `set <name> <key> <value>`
+	PluginConfigSetShort string = `Set a configuration key of an installed Singularity plugin`
+	PluginConfigSetLong  string = `
+  The 'plugin config set' command sets a key of the configuration file of an
+  installed plugin, as done by 'plugin config <name> <key>=<value>'.`
+	PluginConfigSetExample string = `
+  $ singularity plugin config set example.org/plugin server.port 8080`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config unset command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigUnsetUse   string = `unset <name> <key>...`
+	PluginConfigUnsetShort string = `Unset configuration keys of an installed Singularity plugin`
+	PluginConfigUnsetLong  string = `
+  The 'plugin config unset' command removes keys from the configuration file
+  of an installed plugin, the sections left empty are removed as well. Keys
+  declared in the plugin manifest are commented out with their default value.
+  Unsetting a key which is not set does nothing.`
+	PluginConfigUnsetExample string = `
+  $ singularity plugin config unset example.org/plugin server.port`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config export command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigExportUse   string = `export <name> [<file>]`
+	PluginConfigExportShort string = `Export the configuration of an installed Singularity plugin`
+	PluginConfigExportLong  string = `
+  The 'plugin config export' command writes the configuration of an installed
+  plugin to the given file, or to the standard output without file or if it's
+  '-', to be installed on another host with 'plugin config import'. The
+  configuration file is exported merged with its drop-in files, without the
+  user configuration overlay and the environment overrides. The export is a
+  JSON document recording the plugin name and version, the format and schema
+  of its configuration along with the configuration itself.`
+	PluginConfigExportExample string = `
+  $ singularity plugin config export example.org/plugin plugin-config.json
+  $ singularity plugin config export example.org/plugin | ssh host \
+      singularity plugin config import example.org/plugin -`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin config import command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginConfigImportUse   string = `import <name> <file>`
+	PluginConfigImportShort string = `Import the configuration of an installed Singularity plugin`
+	PluginConfigImportLong  string = `
+  The 'plugin config import' command installs the configuration exported by
+  'plugin config export' read from the given file, or from the standard input
+  if it's '-', as the configuration file of an installed plugin. The current
+  configuration file is replaced atomically, it's saved next to it as
+  config.bak.<time>.
+
+  The configuration is converted to the configuration format of the installed
+  plugin and validated against its configuration schema, the keys unknown to
+  the schema are refused. A configuration exported by another version of the
+  plugin goes through the configuration migration declared by the installed
+  plugin, as done on upgrade, so the keys renamed or removed since are
+  migrated and the stale ones are refused. The drop-in files of the config.d
+  directory of the plugin still apply over the imported configuration.`
+	PluginConfigImportExample string = `
+  $ singularity plugin config import example.org/plugin plugin-config.json`
+
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin inspect command
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
-	PluginInspectUse   string = `inspect (<name>|<image>)`
+	PluginInspectUse   string = `inspect [inspect options...] (<name>|<image>)`
 	PluginInspectShort string = `Inspect a singularity plugin (either an installed one or an image)`
 	PluginInspectLong  string = `
   The 'plugin inspect' command allows a user to inspect a plugin that is already
-  installed in the system or an image containing a plugin that is yet to be installed.`
+  installed in the system or an image containing a plugin that is yet to be installed.
+  With --history, the installations and upgrades of an installed plugin are
+  displayed as well. With --changelog, only the release notes shipped with the
+  plugin are displayed, truncated beyond 64KiB. URLs which are not valid
+  http(s) URLs are displayed as (invalid URL), --raw-urls displays them
+  stripped of control characters instead. The binary objects of the plugin
+  image are listed with their architecture and size, the one selected for
+  the host being marked. The signatures of the plugin image are verified with
+  the local public keyring and reported as valid, partial when they don't
+  cover all the data objects, unknown key or invalid. With --keyserver, the
+  keys missing from the keyring are fetched from the key server. The SIF
+  header information of the plugin image is displayed: its unique ID, format
+  version, creation and modification times, creator and the fingerprints of
+  its signatures. For an installed plugin, an image ID differing from the one
+  recorded at installation reveals an image replaced since.`
 	PluginInspectExample string = `
   $ singularity plugin inspect sylabs.io/test-plugin
   Name: sylabs.io/test-plugin
   Description: A test Singularity plugin.
   Author: Sylabs
-  Version: 0.1.0`
+  Version: 0.1.0
+  API version: 1
+  Host API version: 1
+  Capabilities: cli-commands
+  Hooks: cli.Command
+  Keywords: example, cli`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin check command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginCheckUse   string = `check <name>`
+	PluginCheckShort string = `Verify the callbacks registered by an installed Singularity plugin`
+	PluginCheckLong  string = `
+  The 'plugin check' command loads an installed plugin in a separate process
+  and compares the callbacks it actually registers with the callbacks declared
+  in its manifest. The result is recorded and displayed by 'plugin inspect',
+  it's used when loading the plugin with 'plugin callback check = strict' in
+  singularity.conf. The command fails if the callbacks differ.`
+	PluginCheckExample string = `
+  $ singularity plugin check sylabs.io/test-plugin
+  Declared callbacks: cli.Command
+  Registered callbacks: cli.Command
+  Result: passed`
+
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	// plugin integrity command
+	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+	PluginIntegrityUse   string = `integrity <plugin_path>`
+	PluginIntegrityShort string = `Check the internal consistency of a plugin image`
+	PluginIntegrityLong  string = `
+  The 'plugin integrity' command checks the internal consistency of the plugin
+  image found at plugin_path, typically before distributing it, and reports
+  all the problems found:
+    - the SIF header, the descriptors and the data objects must lie within
+      the file, and the data objects must not overlap
+    - the data objects must match the hashes recorded by the signatures,
+      without verifying the signatures with the signing keys
+    - the manifest must be valid
+    - the plugin binary objects must be ELF shared objects of the
+      architecture recorded for them and declared in the manifest
+  The command fails if a problem is found.`
+	PluginIntegrityExample string = `
+  $ singularity plugin integrity $HOME/singularity/test-plugin/test-plugin.sif
+  Result: passed`
 
 	// ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
 	// plugin create command
@@ -16,14 +16,25 @@ import (
 	clicallback "github.com/sylabs/singularity/pkg/plugin/callback/cli"
 )
 
+const pluginName = "github.com/sylabs/singularity/cli-example-plugin"
+
+// log writes the plugin messages, which carry the plugin name and
+// can be filtered with singularity --plugin-messagelevel.
+var log = sylog.NewLogger(pluginName)
+
 // Plugin is the only variable which a plugin MUST export.
 // This symbol is accessed by the plugin framework to initialize the plugin.
 var Plugin = pluginapi.Plugin{
 	Manifest: pluginapi.Manifest{
-		Name:        "github.com/sylabs/singularity/cli-example-plugin",
+		Name:        pluginName,
 		Author:      "Sylabs Team",
 		Version:     "0.1.0",
 		Description: "This is a short example CLI plugin for Singularity",
+		Capabilities: []pluginapi.Capability{
+			pluginapi.CapabilityCLICommands,
+		},
+		Hooks:    []string{"cli.Command"},
+		Keywords: []string{"example", "cli"},
 	},
 	Callbacks: []pluginapi.Callback{
 		(clicallback.Command)(callbackVersion),
@@ -35,7 +46,7 @@ var Plugin = pluginapi.Plugin{
 func callbackVersion(manager *cmdline.CommandManager) {
 	versionCmd := manager.GetCmd("version")
 	if versionCmd == nil {
-		sylog.Warningf("Could not find version command")
+		log.Warningf("Could not find version command")
 		return
 	}
 
@@ -60,7 +71,7 @@ func callbackVersion(manager *cmdline.CommandManager) {
 func callbackVerify(manager *cmdline.CommandManager) {
 	verifyCmd := manager.GetCmd("verify")
 	if verifyCmd == nil {
-		sylog.Warningf("Could not find verify command")
+		log.Warningf("Could not find verify command")
 		return
 	}
 
@@ -25,6 +25,11 @@ var Plugin = pluginapi.Plugin{
 		Author:      "Sylabs Team",
 		Version:     "0.1.0",
 		Description: "This is a short example config plugin for Singularity",
+		Capabilities: []pluginapi.Capability{
+			pluginapi.CapabilityRuntimeConfig,
+		},
+		Hooks:    []string{"cli.SingularityEngineConfig"},
+		Keywords: []string{"example", "config"},
 	},
 	Callbacks: []pluginapi.Callback{
 		(clicallback.SingularityEngineConfig)(callbackCgroups),
@@ -25,6 +25,11 @@ var Plugin = pluginapi.Plugin{
 		Author:      "Sylabs Team",
 		Version:     "0.1.0",
 		Description: "Log executed commands to syslog",
+		Capabilities: []pluginapi.Capability{
+			pluginapi.CapabilityContainerMonitor,
+		},
+		Hooks:    []string{"singularity.PostStartProcess"},
+		Keywords: []string{"example", "logging"},
 	},
 	Callbacks: []pluginapi.Callback{
 		(singularitycallback.PostStartProcess)(logCommand),
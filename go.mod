@@ -31,6 +31,7 @@ require (
 	github.com/gorilla/websocket v1.4.1
 	github.com/juju/errors v0.0.0-20190207033735-e65537c515d7 // indirect
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0 // indirect
+	github.com/klauspost/compress v1.9.8
 	github.com/kr/pty v1.1.8
 	github.com/mattn/go-runewidth v0.0.2 // indirect
 	github.com/openSUSE/umoci v0.4.5
@@ -53,6 +54,7 @@ require (
 	github.com/sylabs/scs-key-client v0.4.1
 	github.com/sylabs/scs-library-client v0.4.4
 	github.com/sylabs/sif v1.0.9
+	github.com/ulikunitz/xz v0.5.6
 	github.com/urfave/cli v1.22.2 // indirect
 	github.com/vishvananda/netlink v1.0.1-0.20190618143317-99a56c251ae6 // indirect
 	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
@@ -63,7 +65,7 @@ require (
 	go.opencensus.io v0.22.2 // indirect
 	go4.org v0.0.0-20180417224846-9599cf28b011 // indirect
 	golang.org/x/crypto v0.0.0-20200128174031-69ecbb4d6d5d
-	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa // indirect
+	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa
 	golang.org/x/sys v0.0.0-20200124204421-9fbb57f87de9
 	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4 // indirect
 	google.golang.org/genproto v0.0.0-20200122232147-0452cf42e150 // indirect
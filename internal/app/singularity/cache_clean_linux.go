@@ -6,9 +6,11 @@
 package singularity
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/client/cache"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -130,7 +132,13 @@ func CleanSingularityCache(imgCache *cache.Handle, force bool, cacheCleanTypes [
 
 	if len(cacheName) > 0 {
 		if force {
-			remove = os.Remove
+			remove = func(path string) error {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				cache.RemoveSource(path)
+				return nil
+			}
 		}
 		// a name was specified, only clean matching entries
 		for _, name := range cacheName {
@@ -169,3 +177,51 @@ func CleanSingularityCache(imgCache *cache.Handle, force bool, cacheCleanTypes [
 
 	return nil
 }
+
+// CleanSingularityCacheEntries removes the images of the cache types
+// cacheCleanTypes whose name matches the shell pattern and which
+// weren't accessed for at least olderThan, an empty pattern or a zero
+// olderThan matching all the images. If force is false, only the
+// images which would be removed are shown. Images in use are never
+// removed.
+func CleanSingularityCacheEntries(imgCache *cache.Handle, force bool, cacheCleanTypes []string, pattern string, olderThan time.Duration) error {
+	if imgCache == nil {
+		return errInvalidCacheHandle
+	}
+
+	cacheTypes, err := normalizeCacheList(cacheCleanTypes)
+	if err != nil {
+		return err
+	}
+	types := imageCacheTypes(cacheTypes)
+	if len(types) == 0 {
+		return nil
+	}
+
+	entries, err := imgCache.Select(pattern, olderThan, types...)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		sylog.Infof("No cache entry selected")
+		return nil
+	}
+
+	for _, e := range entries {
+		if e.InUse {
+			sylog.Warningf("Skipping %s: in use", e.Path)
+			continue
+		}
+		fmt.Printf("Removing %s\n", e.Path)
+		if !force {
+			continue
+		}
+		if err := imgCache.RemoveEntry(e); errors.Is(err, cache.ErrEntryInUse) {
+			sylog.Warningf("Skipping %s: in use", e.Path)
+		} else if err != nil {
+			return fmt.Errorf("unable to remove entry %s from cache %s: %v", e.Name, e.Type, err)
+		}
+	}
+
+	return nil
+}
@@ -78,6 +78,10 @@ func listTypeCache(printList bool, name, cachePath string) (int, int64, error) {
 		}
 
 		for _, entry := range cacheEntries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				// source reference of an entry
+				continue
+			}
 			fileInfo, err := os.Stat(filepath.Join(cachePath, dir.Name(), entry.Name()))
 			if err != nil {
 				return 0, 0, fmt.Errorf("unable to get stat for: %s: %v", cachePath, err)
@@ -91,9 +95,8 @@ func listTypeCache(printList bool, name, cachePath string) (int, int64, error) {
 					name)
 			}
 			totalSize += fileInfo.Size()
+			count++
 		}
-
-		count += len(cacheEntries)
 	}
 
 	return count, totalSize, nil
@@ -178,3 +181,63 @@ func ListSingularityCache(imgCache *cache.Handle, cacheListTypes []string, cache
 
 	return nil
 }
+
+// imageCacheTypes returns the types of cache holding images among
+// cacheTypes, as normalized by normalizeCacheList.
+func imageCacheTypes(cacheTypes []string) []string {
+	var types []string
+	for _, t := range cacheTypes {
+		if t == "blob" {
+			// OCI blobs are layers, not images
+			sylog.Debugf("Skipping OCI blob cache, it doesn't hold images")
+			continue
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+// ListSingularityCacheEntries lists the images stored in the local
+// singularity cache for the types specified by cacheListTypes, with
+// their last access time, whether they are in use and the reference
+// they were fetched from.
+func ListSingularityCacheEntries(imgCache *cache.Handle, cacheListTypes []string) error {
+	if imgCache == nil {
+		return errInvalidCacheHandle
+	}
+
+	cacheTypes, err := normalizeCacheList(cacheListTypes)
+	if err != nil {
+		return err
+	}
+	types := imageCacheTypes(cacheTypes)
+	if len(types) == 0 {
+		return nil
+	}
+
+	entries, err := imgCache.Entries(types...)
+	if err != nil {
+		return fmt.Errorf("while listing cache entries: %s", err)
+	}
+
+	fmt.Printf("%-24s %-22s %-16s %-8s %-6s %s\n", "NAME", "LAST ACCESS", "SIZE", "TYPE", "IN USE", "SOURCE")
+	for _, e := range entries {
+		inUse := "no"
+		if e.InUse {
+			inUse = "yes"
+		}
+		source := e.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Printf("%-24.22s %-22s %-16s %-8s %-6s %s\n",
+			e.Name,
+			e.AccessTime.Format("2006-01-02 15:04:05"),
+			findSize(e.Size),
+			e.Type,
+			inUse,
+			source)
+	}
+
+	return nil
+}
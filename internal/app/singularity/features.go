@@ -0,0 +1,32 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+// Feature reports whether a runtime feature can be used by the current
+// user with the running Singularity binary.
+type Feature struct {
+	Available bool `json:"available"`
+	// Detail explains why the feature is unavailable, or how it's
+	// provided when available.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Features is the report of the runtime features supported by the
+// running Singularity binary, computed from its build options, the
+// configuration in singularity.conf and probes of the host.
+type Features struct {
+	Version       string  `json:"version"`
+	Setuid        Feature `json:"setuid"`
+	UserNamespace Feature `json:"userNamespace"`
+	Overlay       Feature `json:"overlay"`
+	Fakeroot      Feature `json:"fakeroot"`
+	Encryption    Feature `json:"encryption"`
+	Cgroups       Feature `json:"cgroups"`
+	CgroupsV2     Feature `json:"cgroupsV2"`
+	Seccomp       Feature `json:"seccomp"`
+	AppArmor      Feature `json:"apparmor"`
+	SELinux       Feature `json:"selinux"`
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/fakeroot"
+	"github.com/sylabs/singularity/internal/pkg/security/apparmor"
+	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
+	"github.com/sylabs/singularity/internal/pkg/security/selinux"
+	"github.com/sylabs/singularity/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/internal/pkg/util/env"
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+	"golang.org/x/sys/unix"
+)
+
+// featureProbe holds the sources the runtime features are computed
+// from, the tests replace the host files.
+type featureProbe struct {
+	conf *singularityconf.File
+	uid  uint32
+
+	// starterSuid is the path of the setuid starter.
+	starterSuid string
+	// procSys is the path of /proc/sys.
+	procSys string
+	// cgroupRoot is the mount point of the cgroups hierarchy.
+	cgroupRoot string
+	// subUIDFile and subGIDFile are the fakeroot mapping files.
+	subUIDFile string
+	subGIDFile string
+	// searchPath is searched for the newuidmap and newgidmap programs.
+	searchPath string
+
+	hasFilesystem func(string) (bool, error)
+	getIDRange    func(string, uint32) (*specs.LinuxIDMapping, error)
+	cryptsetup    func() (string, error)
+}
+
+// GetFeatures returns the runtime features supported by the running
+// Singularity binary for the current user.
+func GetFeatures() (*Features, error) {
+	conf, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s: %s", buildcfg.SINGULARITY_CONF_FILE, err)
+	}
+
+	p := &featureProbe{
+		conf:          conf,
+		uid:           uint32(os.Getuid()),
+		starterSuid:   filepath.Join(buildcfg.LIBEXECDIR, "singularity/bin/starter-suid"),
+		procSys:       "/proc/sys",
+		cgroupRoot:    "/sys/fs/cgroup",
+		subUIDFile:    fakeroot.SubUIDFile,
+		subGIDFile:    fakeroot.SubGIDFile,
+		searchPath:    env.DefaultPath,
+		hasFilesystem: proc.HasFilesystem,
+		getIDRange:    fakeroot.GetIDRange,
+		cryptsetup:    bin.Cryptsetup,
+	}
+	return p.features(), nil
+}
+
+// features probes the runtime features.
+func (p *featureProbe) features() *Features {
+	f := &Features{
+		Version:       buildcfg.PACKAGE_VERSION,
+		Setuid:        p.setuid(),
+		UserNamespace: p.userNamespace(),
+		Seccomp:       builtFeature(seccomp.Enabled(), "seccomp"),
+		AppArmor:      builtFeature(apparmor.Enabled(), "apparmor"),
+		SELinux:       builtFeature(selinux.Enabled(), "selinux"),
+	}
+	f.Overlay = p.overlay(f.Setuid.Available)
+	f.Fakeroot = p.fakeroot(f.Setuid.Available, f.UserNamespace.Available)
+	f.Encryption = p.encryption(f.Setuid.Available)
+	f.Cgroups, f.CgroupsV2 = p.cgroups()
+	return f
+}
+
+// builtFeature returns the feature of a security module, enabled when
+// the binary is built with its support and the host provides it.
+func builtFeature(enabled bool, name string) Feature {
+	if enabled {
+		return Feature{Available: true}
+	}
+	return Feature{Detail: fmt.Sprintf("not built with %s support or not enabled on the host", name)}
+}
+
+func (p *featureProbe) setuid() Feature {
+	if !p.conf.AllowSetuid {
+		return Feature{Detail: "disabled by 'allow setuid = no'"}
+	}
+
+	fi, err := os.Stat(p.starterSuid)
+	if os.IsNotExist(err) {
+		return Feature{Detail: "unprivileged installation without the setuid starter"}
+	} else if err != nil {
+		return Feature{Detail: err.Error()}
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if fi.Mode()&os.ModeSetuid == 0 || !ok || st.Uid != 0 {
+		return Feature{Detail: fmt.Sprintf("%s is not setuid root", p.starterSuid)}
+	}
+	return Feature{Available: true, Detail: p.starterSuid}
+}
+
+func (p *featureProbe) userNamespace() Feature {
+	data, err := ioutil.ReadFile(filepath.Join(p.procSys, "user/max_user_namespaces"))
+	if err != nil {
+		return Feature{Detail: "not supported by the kernel"}
+	} else if strings.TrimSpace(string(data)) == "0" {
+		return Feature{Detail: "disabled by user.max_user_namespaces = 0"}
+	}
+
+	// Debian and Ubuntu kernels can restrict them to root
+	data, err = ioutil.ReadFile(filepath.Join(p.procSys, "kernel/unprivileged_userns_clone"))
+	if err == nil && strings.TrimSpace(string(data)) == "0" && p.uid != 0 {
+		return Feature{Detail: "unprivileged user namespaces disabled by kernel.unprivileged_userns_clone = 0"}
+	}
+	return Feature{Available: true}
+}
+
+func (p *featureProbe) overlay(setuid bool) Feature {
+	if p.conf.EnableOverlay == "no" {
+		return Feature{Detail: "disabled by 'enable overlay = no'"}
+	}
+
+	if has, _ := p.hasFilesystem("overlay"); has {
+		return Feature{Available: true}
+	} else if setuid {
+		return Feature{Available: true, Detail: "the overlay module is loaded by the setuid starter"}
+	}
+	return Feature{Detail: "the overlay module is not loaded"}
+}
+
+func (p *featureProbe) fakeroot(setuid, userns bool) Feature {
+	if !userns {
+		return Feature{Detail: "requires user namespaces"}
+	}
+
+	if !setuid {
+		// the unprivileged workflow maps the IDs with newuidmap
+		// and newgidmap
+		for _, cmd := range []string{"newuidmap", "newgidmap"} {
+			if _, err := lookPath(p.searchPath, cmd); err != nil {
+				return Feature{Detail: fmt.Sprintf("requires the setuid workflow or %s", cmd)}
+			}
+		}
+	}
+
+	for _, path := range []string{p.subUIDFile, p.subGIDFile} {
+		if _, err := p.getIDRange(path, p.uid); err != nil {
+			return Feature{Detail: fmt.Sprintf("no mapping in %s: %s", path, err)}
+		}
+	}
+	return Feature{Available: true}
+}
+
+func (p *featureProbe) encryption(setuid bool) Feature {
+	if !p.conf.AllowContainerEncrypted {
+		return Feature{Detail: "disabled by 'allow container encrypted = no'"}
+	}
+
+	path, err := p.cryptsetup()
+	if err != nil {
+		return Feature{Detail: fmt.Sprintf("cryptsetup not found: %s", err)}
+	}
+	if !setuid && p.uid != 0 {
+		return Feature{Detail: "requires the setuid workflow"}
+	}
+	return Feature{Available: true, Detail: path}
+}
+
+// cgroups returns the cgroups features: the resource limits applied
+// by root through the cgroups v1 hierarchy, the unified cgroups v2
+// hierarchy being unsupported.
+func (p *featureProbe) cgroups() (Feature, Feature) {
+	unified := false
+	var st unix.Statfs_t
+	if err := unix.Statfs(p.cgroupRoot, &st); err == nil {
+		unified = st.Type == unix.CGROUP2_SUPER_MAGIC
+	}
+
+	v2 := Feature{Detail: "not supported"}
+	if unified {
+		v2.Detail = "not supported, the host uses the cgroups v2 unified hierarchy"
+	}
+
+	switch {
+	case unified:
+		return Feature{Detail: "the host has no cgroups v1 hierarchy"}, v2
+	case p.uid != 0:
+		return Feature{Detail: "requires root"}, v2
+	}
+	return Feature{Available: true, Detail: "cgroups v1"}, v2
+}
+
+// lookPath looks for the program cmd in the list of directories
+// searchPath, using the PATH environment variable format.
+func lookPath(searchPath, cmd string) (string, error) {
+	for _, dir := range filepath.SplitList(searchPath) {
+		if path, err := exec.LookPath(filepath.Join(dir, cmd)); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s", cmd, searchPath)
+}
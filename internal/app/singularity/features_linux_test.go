@@ -0,0 +1,201 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+func newTestFeatureProbe(t *testing.T) *featureProbe {
+	dir, err := ioutil.TempDir("", "features-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for path, content := range map[string]string{
+		"sys/user/max_user_namespaces": "1024\n",
+		"bin/newuidmap":                "",
+		"bin/newgidmap":                "",
+		"starter-suid":                 "",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("while creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatalf("while writing %s: %s", path, err)
+		}
+	}
+
+	conf, err := singularityconf.Parse("")
+	if err != nil {
+		t.Fatalf("while parsing default configuration: %s", err)
+	}
+
+	return &featureProbe{
+		conf:        conf,
+		uid:         1000,
+		starterSuid: filepath.Join(dir, "starter-suid"),
+		procSys:     filepath.Join(dir, "sys"),
+		cgroupRoot:  dir,
+		subUIDFile:  "subuid",
+		subGIDFile:  "subgid",
+		searchPath:  filepath.Join(dir, "bin"),
+		hasFilesystem: func(fs string) (bool, error) {
+			return fs == "overlay", nil
+		},
+		getIDRange: func(path string, uid uint32) (*specs.LinuxIDMapping, error) {
+			return &specs.LinuxIDMapping{ContainerID: 1, HostID: 100000, Size: 65536}, nil
+		},
+		cryptsetup: func() (string, error) {
+			return "/sbin/cryptsetup", nil
+		},
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	noMapping := func(path string, uid uint32) (*specs.LinuxIDMapping, error) {
+		return nil, errors.New("no mapping")
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(p *featureProbe)
+		check    func(f *Features) Feature
+		expected bool
+	}{
+		{
+			name:     "SetuidNotRoot",
+			check:    func(f *Features) Feature { return f.Setuid },
+			expected: false,
+		},
+		{
+			name:     "SetuidDisallowed",
+			setup:    func(p *featureProbe) { p.conf.AllowSetuid = false },
+			check:    func(f *Features) Feature { return f.Setuid },
+			expected: false,
+		},
+		{
+			name:     "UserNamespace",
+			check:    func(f *Features) Feature { return f.UserNamespace },
+			expected: true,
+		},
+		{
+			name: "UserNamespaceDisabled",
+			setup: func(p *featureProbe) {
+				ioutil.WriteFile(filepath.Join(p.procSys, "user/max_user_namespaces"), []byte("0\n"), 0644)
+			},
+			check:    func(f *Features) Feature { return f.UserNamespace },
+			expected: false,
+		},
+		{
+			name: "UserNamespaceRestricted",
+			setup: func(p *featureProbe) {
+				os.MkdirAll(filepath.Join(p.procSys, "kernel"), 0755)
+				ioutil.WriteFile(filepath.Join(p.procSys, "kernel/unprivileged_userns_clone"), []byte("0\n"), 0644)
+			},
+			check:    func(f *Features) Feature { return f.UserNamespace },
+			expected: false,
+		},
+		{
+			name:     "Overlay",
+			check:    func(f *Features) Feature { return f.Overlay },
+			expected: true,
+		},
+		{
+			name:     "OverlayDisabled",
+			setup:    func(p *featureProbe) { p.conf.EnableOverlay = "no" },
+			check:    func(f *Features) Feature { return f.Overlay },
+			expected: false,
+		},
+		{
+			name: "OverlayNotLoaded",
+			setup: func(p *featureProbe) {
+				p.hasFilesystem = func(string) (bool, error) { return false, nil }
+			},
+			check:    func(f *Features) Feature { return f.Overlay },
+			expected: false,
+		},
+		{
+			name:     "Fakeroot",
+			check:    func(f *Features) Feature { return f.Fakeroot },
+			expected: true,
+		},
+		{
+			name:     "FakerootNoMapping",
+			setup:    func(p *featureProbe) { p.getIDRange = noMapping },
+			check:    func(f *Features) Feature { return f.Fakeroot },
+			expected: false,
+		},
+		{
+			name:     "FakerootNoNewuidmap",
+			setup:    func(p *featureProbe) { p.searchPath = "/nonexistent" },
+			check:    func(f *Features) Feature { return f.Fakeroot },
+			expected: false,
+		},
+		{
+			name:     "EncryptionUnprivileged",
+			check:    func(f *Features) Feature { return f.Encryption },
+			expected: false,
+		},
+		{
+			name:     "EncryptionRoot",
+			setup:    func(p *featureProbe) { p.uid = 0 },
+			check:    func(f *Features) Feature { return f.Encryption },
+			expected: true,
+		},
+		{
+			name: "EncryptionNoCryptsetup",
+			setup: func(p *featureProbe) {
+				p.uid = 0
+				p.cryptsetup = func() (string, error) { return "", errors.New("not found") }
+			},
+			check:    func(f *Features) Feature { return f.Encryption },
+			expected: false,
+		},
+		{
+			name:     "CgroupsUnprivileged",
+			check:    func(f *Features) Feature { return f.Cgroups },
+			expected: false,
+		},
+		{
+			name:     "CgroupsRoot",
+			setup:    func(p *featureProbe) { p.uid = 0 },
+			check:    func(f *Features) Feature { return f.Cgroups },
+			expected: true,
+		},
+		{
+			name:     "CgroupsV2",
+			setup:    func(p *featureProbe) { p.uid = 0 },
+			check:    func(f *Features) Feature { return f.CgroupsV2 },
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestFeatureProbe(t)
+			if tt.setup != nil {
+				tt.setup(p)
+			}
+			f := tt.check(p.features())
+			if f.Available != tt.expected {
+				t.Errorf("got available %v (%s), expected %v", f.Available, f.Detail, tt.expected)
+			}
+			if !f.Available && f.Detail == "" {
+				t.Errorf("unavailable feature without detail")
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// +build !linux
+
+package singularity
+
+import (
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+)
+
+// GetFeatures returns the runtime features supported by the running
+// Singularity binary, containers can't be run on this platform.
+func GetFeatures() (*Features, error) {
+	unsupported := Feature{Detail: "not supported on this platform"}
+	return &Features{
+		Version:       buildcfg.PACKAGE_VERSION,
+		Setuid:        unsupported,
+		UserNamespace: unsupported,
+		Overlay:       unsupported,
+		Fakeroot:      unsupported,
+		Encryption:    unsupported,
+		Cgroups:       unsupported,
+		CgroupsV2:     unsupported,
+		Seccomp:       unsupported,
+		AppArmor:      unsupported,
+		SELinux:       unsupported,
+	}, nil
+}
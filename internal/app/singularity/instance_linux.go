@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,10 +15,11 @@ import (
 )
 
 type instanceInfo struct {
-	Instance string `json:"instance"`
-	Pid      int    `json:"pid"`
-	Image    string `json:"img"`
-	IP       string `json:"ip"`
+	Instance string   `json:"instance"`
+	Pid      int      `json:"pid"`
+	Image    string   `json:"img"`
+	IP       string   `json:"ip"`
+	IPs      []string `json:"ips,omitempty"`
 }
 
 // PrintInstanceList fetches instance list, applying name and
@@ -35,7 +37,11 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool) error {
 			return fmt.Errorf("could not write list header: %v", err)
 		}
 		for _, i := range ii {
-			_, err := fmt.Fprintf(w, "%-16s %-8d %-15s %s\n", i.Name, i.Pid, i.IP, i.Image)
+			ip := i.IP
+			if len(i.IPs) > 0 {
+				ip = strings.Join(i.IPs, ",")
+			}
+			_, err := fmt.Fprintf(w, "%-16s %-8d %-15s %s\n", i.Name, i.Pid, ip, i.Image)
 			if err != nil {
 				return fmt.Errorf("could not write instance info: %v", err)
 			}
@@ -49,6 +55,7 @@ func PrintInstanceList(w io.Writer, name, user string, formatJSON bool) error {
 		instances[i].Pid = ii[i].Pid
 		instances[i].Instance = ii[i].Name
 		instances[i].IP = ii[i].IP
+		instances[i].IPs = ii[i].IPs
 	}
 
 	enc := json.NewEncoder(w)
@@ -0,0 +1,40 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// CheckPlugin compares the callbacks registered by the named plugin with
+// the ones declared in its manifest and displays the result, an error
+// is returned if they differ.
+func CheckPlugin(name string) error {
+	check, err := plugin.Check(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Declared callbacks: %s\n", callbackList(check.Declared))
+	fmt.Printf("Registered callbacks: %s\n", callbackList(check.Registered))
+	fmt.Printf("Result: %s\n", check)
+
+	if len(check.Declared) > 0 && !check.Passed() {
+		return fmt.Errorf("callbacks differ from the manifest declaration")
+	}
+	return nil
+}
+
+// callbackList returns the comma separated callback names.
+func callbackList(callbacks []string) string {
+	if len(callbacks) == 0 {
+		return "none"
+	}
+	return strings.Join(callbacks, ", ")
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// CompactPlugin compresses the image stored for the installed plugin
+// name.
+func CompactPlugin(name string) error {
+	return plugin.Compact(name)
+}
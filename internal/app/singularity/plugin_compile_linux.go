@@ -7,10 +7,8 @@ package singularity
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -19,15 +17,29 @@ import (
 	"runtime/debug"
 	"strings"
 
-	uuid "github.com/satori/go.uuid"
-	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/plugin"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
 
 const version = "v0.0.0"
 
+// pluginChangelogFiles are the files of the plugin source directory
+// shipped as release notes in the plugin SIF, the first found is used.
+var pluginChangelogFiles = []string{"CHANGELOG.md", "CHANGELOG"}
+
+// pluginConfigFile returns the file of the plugin source directory
+// shipped as the template of the plugin configuration file, named
+// after the configuration format f declared by the plugin manifest.
+func pluginConfigFile(f pluginapi.ConfigFormat) string {
+	if f == "" {
+		f = pluginapi.ConfigFormatYAML
+	}
+	return "config.default." + string(f)
+}
+
 const goVersionFile = `package main
 import "fmt"
 import "runtime"
@@ -39,6 +51,9 @@ type buildToolchain struct {
 	pluginDir         string
 	buildTags         string
 	envs              []string
+	// pluginVersion is the version stamped
+	// into the plugin manifest when set
+	pluginVersion string
 }
 
 func getPackageName() string {
@@ -115,42 +130,76 @@ func pluginObjPath(sourceDir string) string {
 	return filepath.Join(sourceDir, "plugin.so")
 }
 
-// pluginManifestPath returns the path of the .manifest file created
-// in the container after the plugin object is built
-func pluginManifestPath(sourceDir string) string {
-	return filepath.Join(sourceDir, "plugin.manifest")
-}
-
 // CompilePlugin compiles a plugin. It takes as input: sourceDir, the path to the
 // plugin's source code directory; and destSif, the path to the intended final
-// location of the plugin SIF file.
-func CompilePlugin(sourceDir, destSif, buildTags string, disableMinorCheck bool) error {
-	singularitySrcDir, err := getSingularitySrcDir()
-	if err != nil {
-		return errors.New("singularity source directory not found")
-	}
-	goPath, err := exec.LookPath("go")
-	if err != nil {
-		return errors.New("go compiler not found")
+// location of the plugin SIF file. When pluginVersion is set, it's stamped as
+// the version of the plugin manifest. When compression is set, the plugin
+// object is stored compressed with it, either gzip or zstd. The source
+// directory is recorded in the plugin SIF to recompile the installed plugin.
+func CompilePlugin(sourceDir, destSif, buildTags, pluginVersion, compression string, disableMinorCheck bool) error {
+	if pluginVersion != "" {
+		if err := plugin.CheckVersion(pluginVersion); err != nil {
+			sylog.Warningf("Plugin version %s", err)
+		}
+	}
+	if err := plugin.CheckCompression(compression); err != nil {
+		return err
 	}
 
 	// copy plugin directory to apply modification on-the-fly
-	d, err := ioutil.TempDir("", "plugin-")
+	d, err := ioutil.TempDir(fs.TempDir(), "plugin-")
 	if err != nil {
 		return errors.New("temporary directory creation failed")
 	}
 	defer os.RemoveAll(d)
 
+	pluginDir, manifest, err := buildPluginObject(d, sourceDir, buildTags, pluginVersion, disableMinorCheck)
+	if err != nil {
+		return err
+	}
+
+	// convert the built plugin object into a sif
+	ops := []plugin.CreateOp{
+		plugin.WithCompression(compression),
+		plugin.WithSourceDir(sourceDir),
+	}
+	if changelog := pluginChangelogPath(pluginDir); changelog != "" {
+		ops = append(ops, plugin.WithChangelog(changelog))
+	}
+	if config := filepath.Join(pluginDir, pluginConfigFile(manifest.ConfigFormat)); fs.IsFile(config) {
+		ops = append(ops, plugin.WithConfig(config))
+	}
+	if err := plugin.CreateSIF(pluginObjPath(pluginDir), manifest, destSif, ops...); err != nil {
+		return fmt.Errorf("while making sif file: %s", err)
+	}
+
+	return nil
+}
+
+// buildPluginObject copies the plugin source directory sourceDir into
+// the temporary directory d and builds the plugin object against the
+// singularity source. It returns the directory holding the plugin
+// object, see pluginObjPath, along with the manifest generated from it.
+func buildPluginObject(d, sourceDir, buildTags, pluginVersion string, disableMinorCheck bool) (string, pluginapi.Manifest, error) {
+	singularitySrcDir, err := getSingularitySrcDir()
+	if err != nil {
+		return "", pluginapi.Manifest{}, errors.New("singularity source directory not found")
+	}
+	goPath, err := exec.LookPath("go")
+	if err != nil {
+		return "", pluginapi.Manifest{}, errors.New("go compiler not found")
+	}
+
 	// we need to use the exact same go runtime version used
 	// to compile Singularity
 	if err := checkGoVersion(d, goPath); err != nil {
-		return fmt.Errorf("while checking go version: %s", err)
+		return "", pluginapi.Manifest{}, fmt.Errorf("while checking go version: %s", err)
 	}
 
 	pluginDir := filepath.Join(d, "src")
 	cmd := exec.Command("cp", "-a", sourceDir, pluginDir)
 	if err := cmd.Run(); err != nil {
-		return err
+		return "", pluginapi.Manifest{}, err
 	}
 
 	sourceLink := filepath.Join(pluginDir, plugin.SingularitySource)
@@ -158,7 +207,7 @@ func CompilePlugin(sourceDir, destSif, buildTags string, disableMinorCheck bool)
 	os.Remove(sourceLink)
 
 	if err := os.Symlink(singularitySrcDir, sourceLink); err != nil {
-		return fmt.Errorf("while creating %s symlink: %s", sourceLink, err)
+		return "", pluginapi.Manifest{}, fmt.Errorf("while creating %s symlink: %s", sourceLink, err)
 	}
 
 	bTool := buildToolchain{
@@ -167,17 +216,18 @@ func CompilePlugin(sourceDir, destSif, buildTags string, disableMinorCheck bool)
 		pluginDir:         pluginDir,
 		goPath:            goPath,
 		envs:              append(os.Environ(), "GO111MODULE=on"),
+		pluginVersion:     pluginVersion,
 	}
 
 	// generating final go.mod file
 	modData, err := plugin.PrepareGoModules(sourceDir, disableMinorCheck)
 	if err != nil {
-		return err
+		return "", pluginapi.Manifest{}, err
 	}
 
 	goMod := filepath.Join(pluginDir, "go.mod")
 	if err := ioutil.WriteFile(goMod, modData, 0600); err != nil {
-		return fmt.Errorf("while generating %s: %s", goMod, err)
+		return "", pluginapi.Manifest{}, fmt.Errorf("while generating %s: %s", goMod, err)
 	}
 
 	// running go mod tidy for plugin go.sum and cleanup
@@ -186,25 +236,21 @@ func CompilePlugin(sourceDir, destSif, buildTags string, disableMinorCheck bool)
 	cmd.Stderr = &e
 	cmd.Dir = pluginDir
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("while verifying module: %s\nCommand error:\n%s", err, e.String())
+		return "", pluginapi.Manifest{}, fmt.Errorf("while verifying module: %s\nCommand error:\n%s", err, e.String())
 	}
 
 	// build plugin object using go build
 	if _, err := buildPlugin(pluginDir, bTool); err != nil {
-		return fmt.Errorf("while building plugin .so: %v", err)
+		return "", pluginapi.Manifest{}, fmt.Errorf("while building plugin .so: %v", err)
 	}
 
 	// generate plugin manifest from .so
-	if err := generateManifest(pluginDir, bTool); err != nil {
-		return fmt.Errorf("while generating plugin manifest: %s", err)
-	}
-
-	// convert the built plugin object into a sif
-	if err := makeSIF(pluginDir, destSif); err != nil {
-		return fmt.Errorf("while making sif file: %s", err)
+	manifest, err := generateManifest(pluginDir, bTool)
+	if err != nil {
+		return "", pluginapi.Manifest{}, fmt.Errorf("while generating plugin manifest: %s", err)
 	}
 
-	return nil
+	return pluginDir, manifest, nil
 }
 
 // buildPlugin takes sourceDir which is the string path the host which
@@ -225,8 +271,13 @@ func buildPlugin(sourceDir string, bTool buildToolchain) (string, error) {
 		"-trimpath",
 		"-buildmode=plugin",
 		"-tags", bTool.buildTags,
-		".",
 	}
+	// plugins declaring a main.version variable for
+	// their manifest get it stamped at link time
+	if bTool.pluginVersion != "" {
+		args = append(args, "-ldflags", "-X main.version="+bTool.pluginVersion)
+	}
+	args = append(args, ".")
 
 	sylog.Debugf("Running: %s %s", bTool.goPath, strings.Join(args, " "))
 
@@ -242,142 +293,40 @@ func buildPlugin(sourceDir string, bTool buildToolchain) (string, error) {
 }
 
 // generateManifest takes the path to the plugin source, extracts
-// plugin's manifest by loading it into memory and stores it's json
-// representation in a separate file.
-func generateManifest(sourceDir string, bTool buildToolchain) error {
+// plugin's manifest by loading it into memory and returns it.
+func generateManifest(sourceDir string, bTool buildToolchain) (pluginapi.Manifest, error) {
 	in := pluginObjPath(sourceDir)
-	out := pluginManifestPath(sourceDir)
 
 	p, err := plugin.LoadObject(in)
 	if err != nil {
-		return fmt.Errorf("while loading plugin %s: %s", in, err)
+		return pluginapi.Manifest{}, fmt.Errorf("while loading plugin %s: %s", in, err)
 	}
 
-	f, err := os.OpenFile(out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("while creating manifest %s: %s", out, err)
+	manifest := p.Manifest
+	if v := bTool.pluginVersion; v != "" && manifest.Version != v {
+		sylog.Debugf("Plugin doesn't stamp its version, overriding manifest version %q with %q", manifest.Version, v)
+		manifest.Version = v
 	}
-	defer f.Close()
+	// the plugin object was just loaded, it's
+	// compatible with the API of this singularity
+	manifest.APIVersion = pluginapi.APIVersion
 
-	if err := json.NewEncoder(f).Encode(p.Manifest); err != nil {
-		return fmt.Errorf("while writing manifest %s: %s", out, err)
+	if err := plugin.CheckProvenance(manifest); err != nil {
+		return pluginapi.Manifest{}, fmt.Errorf("invalid plugin manifest: %s", err)
 	}
 
-	return nil
+	return manifest, nil
 }
 
-// makeSIF takes in two arguments: sourceDir, the path to the plugin source directory;
-// and sifPath, the path to the final .sif file which is ready to be used.
-func makeSIF(sourceDir, sifPath string) error {
-	plCreateInfo := sif.CreateInfo{
-		Pathname:   sifPath,
-		Launchstr:  sif.HdrLaunch,
-		Sifversion: sif.HdrVersion,
-		ID:         uuid.NewV4(),
-	}
-
-	// create plugin object file descriptor
-	plObjInput, err := getPluginObjDescr(pluginObjPath(sourceDir))
-	if err != nil {
-		return err
-	}
-
-	if fp, ok := plObjInput.Fp.(io.Closer); ok {
-		defer fp.Close()
-	}
-
-	// add plugin object file descriptor to sif
-	plCreateInfo.InputDescr = append(plCreateInfo.InputDescr, plObjInput)
-
-	// create plugin manifest descriptor
-	plManifestInput, err := getPluginManifestDescr(pluginManifestPath(sourceDir))
-	if err != nil {
-		return err
-	}
-	if fp, ok := plManifestInput.Fp.(io.Closer); ok {
-		defer fp.Close()
-	}
-
-	// add plugin manifest descriptor to sif
-	plCreateInfo.InputDescr = append(plCreateInfo.InputDescr, plManifestInput)
-
-	os.RemoveAll(sifPath)
-
-	// create sif file
-	if _, err := sif.CreateContainer(plCreateInfo); err != nil {
-		return fmt.Errorf("while creating sif file: %s", err)
-	}
-
-	return nil
-}
-
-// getPluginObjDescr returns a sif.DescriptorInput which contains the raw
-// data of the .so file.
-//
-// Datatype: sif.DataPartition
-// Fstype:   sif.FsRaw
-// Parttype: sif.PartData
-func getPluginObjDescr(objPath string) (sif.DescriptorInput, error) {
-	var err error
-
-	objInput := sif.DescriptorInput{
-		Datatype: sif.DataPartition,
-		Groupid:  sif.DescrDefaultGroup,
-		Link:     sif.DescrUnusedLink,
-		Fname:    objPath,
-	}
-
-	// open plugin object file
-	fp, err := os.Open(objInput.Fname)
-	if err != nil {
-		return sif.DescriptorInput{}, fmt.Errorf("while opening plugin object file %s: %s", objInput.Fname, err)
-	}
-
-	// stat file to obtain size
-	fstat, err := fp.Stat()
-	if err != nil {
-		return sif.DescriptorInput{}, fmt.Errorf("while calling stat on plugin object file %s: %s", objInput.Fname, err)
-	}
-
-	objInput.Fp = fp
-	objInput.Size = fstat.Size()
-
-	// populate objInput.Extra with appropriate Fstype & Parttype
-	err = objInput.SetPartExtra(sif.FsRaw, sif.PartData, sif.GetSIFArch(runtime.GOARCH))
-	if err != nil {
-		return sif.DescriptorInput{}, err
-	}
-
-	return objInput, nil
-}
-
-// getPluginManifestDescr returns a sif.DescriptorInput which contains the manifest
-// in JSON form. Grabbing the Manifest is done by loading the .so using the plugin
-// package, which is performed inside the container during buildPlugin() function
-//
-// Datatype: sif.DataGenericJSON
-func getPluginManifestDescr(manifestPath string) (sif.DescriptorInput, error) {
-	manifestInput := sif.DescriptorInput{
-		Datatype: sif.DataGenericJSON,
-		Groupid:  sif.DescrDefaultGroup,
-		Link:     sif.DescrUnusedLink,
-		Fname:    manifestPath,
-	}
-
-	// open plugin object file
-	fp, err := os.Open(manifestInput.Fname)
-	if err != nil {
-		return sif.DescriptorInput{}, fmt.Errorf("while opening plugin object file %s: %s", manifestInput.Fname, err)
-	}
-
-	// stat file to obtain size
-	fstat, err := fp.Stat()
-	if err != nil {
-		return sif.DescriptorInput{}, fmt.Errorf("while calling stat on plugin object file %s: %s", manifestInput.Fname, err)
-	}
-
-	manifestInput.Fp = fp
-	manifestInput.Size = fstat.Size()
-
-	return manifestInput, nil
+// pluginChangelogPath returns the path of the release notes found in
+// the plugin source directory, as CHANGELOG.md or CHANGELOG, or an
+// empty string when there are none.
+func pluginChangelogPath(sourceDir string) string {
+	for _, name := range pluginChangelogFiles {
+		path := filepath.Join(sourceDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
 }
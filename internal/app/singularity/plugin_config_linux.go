@@ -0,0 +1,155 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// ConfigurePlugin sets the key=value configuration keys of the named
+// plugin, they are validated against the plugin configuration schema
+// if any. Without settings, the configuration file is validated only.
+func ConfigurePlugin(name string, settings []string) error {
+	if len(settings) == 0 {
+		return plugin.ValidateConfig(name)
+	}
+
+	for _, s := range settings {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid setting %q: must be key=value", s)
+		}
+		if err := plugin.SetConfigKey(name, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetPluginConfig restores the default configuration of the named
+// plugin, the current configuration file is saved next to it.
+func ResetPluginConfig(name string) error {
+	return plugin.ResetConfig(name)
+}
+
+// ShowPluginConfig prints the configuration keys set for the named
+// plugin as key=value, sorted by key, or the value of key only when
+// set. Lists are printed comma separated, as given to ConfigurePlugin.
+// When effective is true, the values are overridden by the environment
+// variables of the current environment, as done when the plugin loads
+// its configuration, the user configuration overlay included. When user
+// is true, only the values of the user configuration overlay are printed.
+// When showOrigin is true, each value is followed by the configuration
+// file setting it, the configuration file of the plugin or one of its
+// drop-in files.
+func ShowPluginConfig(name, key string, effective, user, showOrigin bool) error {
+	var values map[string]interface{}
+	var origins map[string]string
+	var err error
+	if effective {
+		values, err = plugin.GetEffectiveConfig(name, os.LookupEnv)
+	} else if user {
+		values, _, err = plugin.GetUserConfig(name)
+	} else if showOrigin {
+		values, origins, err = plugin.GetConfigOrigins(name)
+	} else {
+		values, err = plugin.GetConfig(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	format := func(k string) string {
+		if !showOrigin {
+			return formatConfigValue(values[k])
+		}
+		return fmt.Sprintf("%s (%s)", formatConfigValue(values[k]), origins[k])
+	}
+
+	if key != "" {
+		if _, ok := values[key]; !ok {
+			return fmt.Errorf("key %q is not set", key)
+		}
+		fmt.Println(format(key))
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s=%s\n", k, format(k))
+	}
+	return nil
+}
+
+// UnsetPluginConfig unsets the configuration keys of the named plugin.
+func UnsetPluginConfig(name string, keys []string) error {
+	for _, k := range keys {
+		if err := plugin.UnsetConfigKey(name, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportPluginConfig writes the configuration of the named plugin to
+// file, or to the standard output if file is empty or "-", in the JSON
+// format read by ImportPluginConfig.
+func ExportPluginConfig(name, file string) error {
+	if file == "" || file == "-" {
+		return plugin.ExportConfig(name, os.Stdout)
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %s", file, err)
+	}
+	if err := plugin.ExportConfig(name, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ImportPluginConfig installs the configuration exported by
+// ExportPluginConfig read from file, or from the standard input if
+// file is "-", as the configuration of the named plugin, the current
+// configuration file is saved next to it.
+func ImportPluginConfig(name, file string) error {
+	if file == "-" {
+		return plugin.ImportConfig(name, os.Stdin)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("while opening %s: %s", file, err)
+	}
+	defer f.Close()
+
+	return plugin.ImportConfig(name, f)
+}
+
+// formatConfigValue returns the value v of a configuration key with the
+// syntax of the environment variables overriding the keys.
+func formatConfigValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		elems := make([]string, len(list))
+		for i, e := range list {
+			elems[i] = fmt.Sprint(e)
+		}
+		return strings.Join(elems, ",")
+	}
+	return fmt.Sprint(v)
+}
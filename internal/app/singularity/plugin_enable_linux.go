@@ -7,7 +7,8 @@ package singularity
 
 import "github.com/sylabs/singularity/internal/pkg/plugin"
 
-// EnablePlugin enables the named plugin.
-func EnablePlugin(name string) error {
-	return plugin.Enable(name)
+// EnablePlugin enables the named plugin, force enables it even with an
+// invalid configuration.
+func EnablePlugin(name string, force bool) error {
+	return plugin.Enable(name, force)
 }
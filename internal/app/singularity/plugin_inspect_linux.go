@@ -1,4 +1,4 @@
-// Copyright (c) 2019, Sylabs Inc. All rights reserved.
+// Copyright (c) 2019-2020, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -6,13 +6,26 @@
 package singularity
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/plugin"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/user"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
 
-// InspectPlugin inspects the named plugin.
-func InspectPlugin(name string) error {
+// InspectPlugin inspects the named plugin. When history is true, the
+// installation history of an installed plugin is displayed as well.
+// The invalid URLs of the manifest are displayed as is, stripped of
+// control characters, when rawURLs is true. The keys of the signatures
+// missing from the local keyring are fetched from keyServerURI, unless
+// it's empty.
+func InspectPlugin(name string, history, rawURLs bool, keyServerURI, authToken string) error {
 	manifest, err := plugin.Inspect(name)
 	if err != nil {
 		return err
@@ -27,5 +40,343 @@ func InspectPlugin(name string) error {
 		manifest.Author,
 		manifest.Version)
 
+	apiVersion := "unknown"
+	if manifest.APIVersion != 0 {
+		apiVersion = strconv.Itoa(manifest.APIVersion)
+	}
+	fmt.Printf("API version: %s\n"+
+		"Host API version: %d\n",
+		apiVersion,
+		pluginapi.APIVersion)
+
+	printPluginDeprecation(manifest, rawURLs)
+	if manifest.Isolated {
+		fmt.Printf("Isolated: yes\n")
+	}
+	if len(manifest.Architectures) > 0 {
+		fmt.Printf("Architectures: %s\n", strings.Join(manifest.Architectures, ", "))
+	}
+	printPluginBinaries(name, manifest.BinaryCompression)
+	printPluginSignatures(name, keyServerURI, authToken)
+	printPluginImage(name)
+	printPluginCapabilities(manifest.Capabilities)
+	if len(manifest.Hooks) > 0 {
+		fmt.Printf("Hooks: %s\n", strings.Join(manifest.Hooks, ", "))
+	}
+	if len(manifest.Keywords) > 0 {
+		fmt.Printf("Keywords: %s\n", strings.Join(manifest.Keywords, ", "))
+	}
+	printPluginConfigOptions(manifest.Config)
+
+	printPluginProvenance(manifest.License, manifest.Homepage, manifest.Repository, manifest.MaintainerEmail, "", rawURLs)
+	printPluginDependencies(manifest)
+
+	meta, err := plugin.LookupImage(name)
+	if err != nil {
+		return err
+	} else if meta == nil {
+		// an image file was inspected, there is no
+		// installation information to display
+		return nil
+	}
+
+	printPluginMeta(meta, "")
+
+	if history {
+		printPluginHistory(meta)
+	}
+
+	return nil
+}
+
+// PluginChangelog displays the release notes shipped with
+// the named plugin.
+func PluginChangelog(name string) error {
+	notes, err := plugin.Changelog(name)
+	if err != nil {
+		return err
+	}
+	if notes == "" {
+		fmt.Printf("No release notes shipped with the plugin\n")
+		return nil
+	}
+	fmt.Printf("%s\n", notes)
 	return nil
 }
+
+// printPluginDeprecation displays the full deprecation
+// information found in the plugin manifest.
+func printPluginDeprecation(manifest pluginapi.Manifest, rawURLs bool) {
+	if !manifest.Deprecated.IsDeprecated() {
+		return
+	}
+	if msg := manifest.Deprecated.Message; msg != "" {
+		fmt.Printf("Deprecated: %s\n", msg)
+	} else {
+		fmt.Printf("Deprecated: yes\n")
+	}
+	if manifest.ReplacedBy != "" {
+		fmt.Printf("Replaced by: %s\n", displayReplacement(manifest.ReplacedBy, rawURLs))
+	}
+}
+
+// displayReplacement returns the replacement of a deprecated
+// plugin ready to be displayed, it's either a plugin name or a URL.
+func displayReplacement(replacedBy string, rawURLs bool) string {
+	if strings.Contains(replacedBy, "://") {
+		return plugin.DisplayURL(replacedBy, rawURLs)
+	}
+	return replacedBy
+}
+
+// printPluginProvenance displays the provenance information
+// of a plugin which are set, each line being prefixed by indent.
+// The URLs are validated before being displayed (see DisplayURL).
+func printPluginProvenance(license, homepage, repository, email, indent string, rawURLs bool) {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"License", license},
+		{"Homepage", plugin.DisplayURL(homepage, rawURLs)},
+		{"Repository", plugin.DisplayURL(repository, rawURLs)},
+		{"Maintainer email", email},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			fmt.Printf("%s%s: %s\n", indent, f.name, f.value)
+		}
+	}
+}
+
+// printPluginBinaries displays the binary objects of the plugin
+// image with their architecture and stored size.
+func printPluginBinaries(name, compression string) {
+	binaries, err := plugin.Binaries(name)
+	if err != nil {
+		sylog.Warningf("Could not list binaries of plugin %q: %s", name, err)
+		return
+	}
+
+	fmt.Printf("Binaries:\n")
+	for _, b := range binaries {
+		details := []string{b.Arch, fmt.Sprintf("%d bytes", b.Size)}
+		if compression != "" && b.Primary {
+			details[1] += " " + compression + " compressed"
+		}
+		if !b.Primary {
+			details = append(details, "helper")
+		}
+		if b.Selected {
+			details = append(details, "selected for this host")
+		}
+		fmt.Printf("  %s (%s)\n", b.Name, strings.Join(details, ", "))
+	}
+}
+
+// printPluginSignatures displays the verification result of the
+// signatures of the plugin image.
+func printPluginSignatures(name, keyServerURI, authToken string) {
+	opts := plugin.SignatureOptions{KeyServerURI: keyServerURI, AuthToken: authToken}
+	signatures, err := plugin.Signatures(context.TODO(), name, opts)
+	if err != nil {
+		sylog.Warningf("Could not verify signatures of plugin %q: %s", name, err)
+		return
+	}
+	if len(signatures) == 0 {
+		fmt.Printf("Signatures: none\n")
+		return
+	}
+
+	fmt.Printf("Signatures:\n")
+	for _, s := range signatures {
+		signer := s.Fingerprint
+		if s.Identity != "" {
+			signer += " " + s.Identity
+		}
+		if s.KeyRemote {
+			signer += " (remote key)"
+		}
+
+		status := s.Status.String()
+		switch s.Status {
+		case plugin.SignaturePartial:
+			status += ", objects " + formatIDs(s.Unsigned) + " not signed"
+		case plugin.SignatureInvalid:
+			status += ": " + s.Detail
+		}
+		fmt.Printf("  %d: %s [%s]\n", s.ID, signer, status)
+	}
+}
+
+// printPluginImage displays the SIF header information of the plugin
+// image, and whether an installed image was replaced since installation.
+func printPluginImage(name string) {
+	info, err := plugin.Image(name)
+	if err != nil {
+		sylog.Warningf("Could not read image information of plugin %q: %s", name, err)
+		return
+	}
+
+	fmt.Printf("Image:\n")
+	fmt.Printf("  ID: %s\n", info.ID)
+	if info.IDMismatch() {
+		sylog.Warningf("Image of plugin %q was replaced since its installation", name)
+		fmt.Printf("  Installed ID: %s (mismatch, the image was replaced)\n", info.InstalledID)
+	}
+	fmt.Printf("  SIF version: %s\n", info.Version)
+	if info.Arch != "" {
+		fmt.Printf("  Architecture: %s\n", info.Arch)
+	}
+	fmt.Printf("  Created: %s\n", info.Created.Format(time.RFC3339))
+	fmt.Printf("  Modified: %s\n", info.Modified.Format(time.RFC3339))
+
+	creator := fmt.Sprintf("uid %d", info.CreatorUID)
+	if pw, err := user.GetPwUID(uint32(info.CreatorUID)); err == nil {
+		creator += " (" + pw.Name + ")"
+	}
+	fmt.Printf("  Creator: %s, gid %d\n", creator, info.CreatorGID)
+	if len(info.Fingerprints) > 0 {
+		fmt.Printf("  Fingerprints: %s\n", strings.Join(info.Fingerprints, ", "))
+	}
+}
+
+// formatIDs returns the comma separated list of the descriptor IDs.
+func formatIDs(ids []uint32) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(s, ", ")
+}
+
+// printPluginCapabilities displays the capabilities declared
+// by a plugin, legacy plugins don't declare any.
+func printPluginCapabilities(capabilities []pluginapi.Capability) {
+	if len(capabilities) == 0 {
+		fmt.Printf("Capabilities: none declared\n")
+		return
+	}
+
+	names := make([]string, len(capabilities))
+	for i, c := range capabilities {
+		names[i] = string(c)
+	}
+	fmt.Printf("Capabilities: %s\n", strings.Join(names, ", "))
+}
+
+// printPluginConfigOptions displays the configuration options
+// documented by a plugin, plugins without a configuration schema
+// have a free-form configuration file.
+func printPluginConfigOptions(options []pluginapi.ConfigOption) {
+	if len(options) == 0 {
+		return
+	}
+
+	fmt.Printf("Config options:\n")
+	for _, o := range options {
+		fmt.Printf("  %s (%s", o.Key, o.Type)
+		if o.Default != "" {
+			fmt.Printf(", default: %s", o.Default)
+		}
+		if o.Overridable {
+			fmt.Printf(", overridable")
+		}
+		fmt.Printf(")\n")
+		if o.Description != "" {
+			for _, line := range strings.Split(strings.TrimSpace(o.Description), "\n") {
+				fmt.Printf("    %s\n", strings.TrimSpace(line))
+			}
+		}
+	}
+}
+
+// printPluginDependencies displays the plugins required by
+// manifest and whether the installed plugins satisfy them.
+func printPluginDependencies(manifest pluginapi.Manifest) {
+	if len(manifest.Dependencies) == 0 {
+		return
+	}
+
+	status, err := plugin.Dependencies(manifest)
+	if err != nil {
+		sylog.Warningf("Could not check dependencies of plugin %q: %s", manifest.Name, err)
+		return
+	}
+
+	fmt.Printf("Requires:\n")
+	for _, s := range status {
+		constraint := s.Version
+		if constraint == "" {
+			constraint = "any version"
+		}
+		fmt.Printf("  %s %s: %s\n", s.Name, constraint, s)
+	}
+}
+
+// printPluginHistory displays the installation history
+// of an installed plugin, oldest entry first.
+func printPluginHistory(meta *plugin.Meta) {
+	if len(meta.History) == 0 {
+		fmt.Printf("History: none recorded\n")
+		return
+	}
+
+	fmt.Printf("History:\n")
+	for _, e := range meta.History {
+		actor := "unknown"
+		if e.Actor != nil {
+			actor = e.Actor.String()
+		}
+		fmt.Printf("  %s %s version %q by %s\n", e.Time.Format(time.RFC3339), e.Action, e.Version, actor)
+		fmt.Printf("    Source: %s\n", e.Source)
+		if e.Digest != "" {
+			fmt.Printf("    Digest: sha256:%s\n", e.Digest)
+		}
+	}
+}
+
+// printPluginMeta displays the installation information recorded
+// for an installed plugin, each line being prefixed by indent.
+func printPluginMeta(meta *plugin.Meta, indent string) {
+	fmt.Printf("%sID: %s\n", indent, meta.ID())
+	if meta.IsDevInstall() {
+		fmt.Printf("%sDev install: %s (unsigned)\n", indent, meta.DevDir)
+	}
+	if meta.InstalledBy != nil {
+		fmt.Printf("%sInstalled by: %s\n", indent, meta.InstalledBy)
+	}
+	if meta.LastModifiedBy != nil {
+		fmt.Printf("%sLast modified by: %s\n", indent, meta.LastModifiedBy)
+	}
+	// plugins installed by previous versions don't have
+	// these times recorded
+	if meta.EnabledAt != nil {
+		fmt.Printf("%sEnabled at: %s\n", indent, meta.EnabledAt.Format(time.RFC3339))
+	}
+	if meta.DisabledAt != nil {
+		fmt.Printf("%sDisabled at: %s\n", indent, meta.DisabledAt.Format(time.RFC3339))
+	}
+	if len(meta.Labels) > 0 {
+		keys := make([]string, 0, len(meta.Labels))
+		for k := range meta.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("%sLabels:\n", indent)
+		for _, k := range keys {
+			fmt.Printf("%s  %s=%s\n", indent, k, meta.Labels[k])
+		}
+	}
+	if meta.CallbackCheck != nil {
+		fmt.Printf("%sCallback check: %s (%s)\n", indent, meta.CallbackCheck, meta.CallbackCheck.Time.Format(time.RFC3339))
+	}
+	if meta.ConfigPath != "" {
+		status, err := meta.VerifyConfig()
+		if err != nil {
+			sylog.Warningf("Could not verify configuration of plugin %q: %s", meta.Name, err)
+		}
+		fmt.Printf("%sConfig: %s (%s)\n", indent, meta.ConfigPath, status)
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -12,7 +12,21 @@ import (
 // InstallPlugin takes a plugin located at path and installs it into
 // the singularity plugin installation directory.
 //
-// Installing a plugin will also automatically enable it.
-func InstallPlugin(pluginPath, pluginName string) error {
-	return plugin.Install(pluginPath, pluginName)
+// Installing a plugin will also automatically enable it. The plugin
+// image is stored compressed if compress is set, its integrity is
+// checked before the installation if thorough is set. With dev set,
+// pluginPath is an unpacked plugin directory installed as a development
+// install, see plugin.InstallDir.
+func InstallPlugin(pluginPath, pluginName string, compress, thorough, dev bool) error {
+	var ops []plugin.InstallOp
+	if compress {
+		ops = append(ops, plugin.WithCompressedImage())
+	}
+	if thorough {
+		ops = append(ops, plugin.WithIntegrityCheck())
+	}
+	if dev {
+		return plugin.InstallDir(pluginPath, pluginName, ops...)
+	}
+	return plugin.Install(pluginPath, pluginName, ops...)
 }
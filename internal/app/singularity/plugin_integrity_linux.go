@@ -0,0 +1,16 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// CheckPluginIntegrity checks the internal consistency of the plugin
+// image located at path and returns the problems found.
+func CheckPluginIntegrity(path string) (*plugin.IntegrityReport, error) {
+	return plugin.CheckIntegrity(path)
+}
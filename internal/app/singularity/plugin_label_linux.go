@@ -0,0 +1,35 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// LabelPlugin sets the key=value labels and removes the labels
+// whose key is in remove on the named plugin.
+func LabelPlugin(name string, labels []string, remove []string) error {
+	for _, l := range labels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid label %q: must be key=value", l)
+		}
+		if err := plugin.SetLabel(name, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range remove {
+		if err := plugin.RemoveLabel(name, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,4 +1,4 @@
-// Copyright (c) 2018-2019, Sylabs Inc. All rights reserved.
+// Copyright (c) 2018-2020, Sylabs Inc. All rights reserved.
 // This software is licensed under a 3-clause BSD license. Please consult the
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
@@ -13,12 +13,27 @@ import (
 )
 
 // ListPlugins lists the singularity plugins installed in the plugin
-// plugin installation directory.
-func ListPlugins() error {
-	plugins, err := plugin.List()
+// plugin installation directory. When verbose is true, the installation
+// information of each plugin is displayed as well. Only the plugins
+// matching all the key=value label selectors and having all the
+// keywords are listed. The invalid URLs of the plugins are displayed as
+// is, stripped of control characters, when rawURLs is true. The plugins
+// with an invalid configuration are flagged, see plugin.Meta.ConfigHealth.
+func ListPlugins(verbose, rawURLs bool, selectors, keywords []string) error {
+	selector, err := plugin.ParseLabelSelector(selectors)
 	if err != nil {
 		return err
 	}
+	keywordSelector, err := plugin.ParseKeywordSelector(keywords)
+	if err != nil {
+		return err
+	}
+
+	plugins, err := plugin.ListByLabels(selector)
+	if err != nil {
+		return err
+	}
+	plugins = plugin.FilterByKeywords(plugins, keywordSelector)
 
 	if len(plugins) == 0 {
 		fmt.Println("There are no plugins installed.")
@@ -29,14 +44,43 @@ func ListPlugins() error {
 		return plugins[i].Name < plugins[j].Name
 	})
 
-	fmt.Printf("ENABLED  NAME\n")
+	fmt.Printf("ENABLED  ID            VERSION       NAME\n")
 
 	for _, p := range plugins {
 		enabled := "no"
 		if p.Enabled {
 			enabled = "yes"
 		}
-		fmt.Printf("%7s  %s\n", enabled, p.Name)
+		version := p.Version
+		if version == "" {
+			version = "-"
+		}
+		name := p.Name
+		if p.IsDeprecated() {
+			name += " (deprecated)"
+		}
+		if p.IsDevInstall() {
+			name += " (dev)"
+		}
+		health := p.ConfigHealth()
+		if health != nil {
+			name += " (invalid config)"
+		}
+		fmt.Printf("%7s  %-12s  %-12s  %s\n", enabled, p.ShortID(), version, name)
+
+		if verbose {
+			indent := "                                     "
+			if p.ReplacedBy != "" {
+				fmt.Printf("%sReplaced by: %s\n", indent, displayReplacement(p.ReplacedBy, rawURLs))
+			}
+			if health != nil {
+				fmt.Printf("%sHealth: %s\n", indent, health)
+			} else {
+				fmt.Printf("%sHealth: ok\n", indent)
+			}
+			printPluginProvenance(p.License, p.Homepage, p.Repository, p.MaintainerEmail, indent, rawURLs)
+			printPluginMeta(p, indent)
+		}
 	}
 
 	return nil
@@ -0,0 +1,25 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+)
+
+// UpdatePluginManifest replaces the manifest of the plugin image
+// sifPath with the JSON manifest read from manifestPath, writing the
+// result to outPath or updating sifPath in place when outPath is empty.
+// The signatures of the image are removed when stripSignatures is true.
+func UpdatePluginManifest(sifPath, manifestPath, outPath string, stripSignatures bool) error {
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("while reading plugin manifest: %s", err)
+	}
+	return plugin.UpdateManifest(sifPath, data, outPath, stripSignatures)
+}
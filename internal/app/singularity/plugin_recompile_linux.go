@@ -0,0 +1,68 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/plugin"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+// RecompilePlugin recompiles the installed plugin name from its source
+// directory against this singularity and reinstalls it in place, keeping
+// its manifest, configuration and enable state. The source directory is
+// sourceDir, or the one recorded when the plugin was compiled if empty.
+// The installed plugin is left untouched on failure. It returns the
+// sha256 of the previous and of the new plugin object.
+func RecompilePlugin(name, sourceDir, buildTags string, disableMinorCheck bool) (string, string, error) {
+	meta, err := plugin.Lookup(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	if sourceDir == "" {
+		sourceDir = meta.SourceDir
+		if sourceDir == "" {
+			return "", "", fmt.Errorf("no source directory recorded for plugin %q, it must be given", meta.Name)
+		}
+	}
+	sourceDir, err = filepath.Abs(sourceDir)
+	if err != nil {
+		return "", "", fmt.Errorf("while sanitizing source directory: %s", err)
+	}
+	if fi, err := os.Stat(sourceDir); err != nil {
+		return "", "", fmt.Errorf("plugin source directory: %s", err)
+	} else if !fi.IsDir() {
+		return "", "", fmt.Errorf("plugin source directory %s is not a directory", sourceDir)
+	}
+
+	installed, err := plugin.ManifestOf(meta.Name)
+	if err != nil {
+		return "", "", fmt.Errorf("while reading manifest of plugin %q: %s", meta.Name, err)
+	}
+
+	d, err := ioutil.TempDir(fs.TempDir(), "plugin-")
+	if err != nil {
+		return "", "", errors.New("temporary directory creation failed")
+	}
+	defer os.RemoveAll(d)
+
+	// the installed version is stamped again
+	pluginDir, manifest, err := buildPluginObject(d, sourceDir, buildTags, installed.Version, disableMinorCheck)
+	if err != nil {
+		return "", "", err
+	}
+	if manifest.Name != installed.Name {
+		return "", "", fmt.Errorf("source directory %s holds plugin %q instead of %q", sourceDir, manifest.Name, installed.Name)
+	}
+
+	return plugin.Rebuild(meta.Name, pluginObjPath(pluginDir), sourceDir)
+}
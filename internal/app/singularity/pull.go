@@ -64,6 +64,7 @@ func PullShub(imgCache *cache.Handle, filePath string, shubRef string, noHTTPS b
 			if err != nil {
 				return err
 			}
+			imgCache.SetSource(imagePath, shubRef)
 		} else {
 			sylog.Infof("Use image from cache")
 		}
@@ -147,6 +148,7 @@ func OrasPull(ctx context.Context, imgCache *cache.Handle, name, ref string, for
 		} else if cacheFileHash != sum {
 			return fmt.Errorf("cached file hash(%s) and expected hash(%s) does not match", cacheFileHash, sum)
 		}
+		imgCache.SetSource(cacheImagePath, "oras:"+ref)
 	} else {
 		sylog.Infof("Using cached image")
 	}
@@ -206,6 +208,7 @@ func OciPull(ctx context.Context, imgCache *cache.Handle, name, imageURI, tmpDir
 			if err := convertDockerToSIF(ctx, imgCache, imageURI, cachedImgPath, tmpDir, noHTTPS, noCleanUp, ociAuth); err != nil {
 				return fmt.Errorf("while building SIF from layers: %v", err)
 			}
+			imgCache.SetSource(cachedImgPath, imageURI)
 			sylog.Infof("Build complete: %s", name)
 		}
 
@@ -113,6 +113,9 @@ func (l *Library) Pull(ctx context.Context, from, to, arch string) error {
 
 		sylog.Debugf("Renaming temporary file %s to %s", tmpName, dst)
 		os.Rename(tmpName, dst)
+		if dst != to {
+			l.cache.SetSource(dst, "library://"+libraryPath)
+		}
 	}
 
 	// now we either have the image in the correct location (dst ==
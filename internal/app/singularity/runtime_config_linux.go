@@ -0,0 +1,82 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sylabs/singularity/internal/pkg/cgroups"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+// RuntimeConfig is the effective runtime configuration of a container
+// as passed to the starter, along with the configuration sources it
+// was resolved from.
+type RuntimeConfig struct {
+	*config.Common
+	// SingularityConf is the content of singularity.conf which
+	// provides the defaults of the runtime configuration.
+	SingularityConf *singularityconf.File `json:"singularityConf,omitempty"`
+	// Cgroups holds the resource limits of the cgroups profile.
+	Cgroups *cgroups.Config `json:"cgroups,omitempty"`
+}
+
+// NewRuntimeConfig returns the effective runtime configuration of cfg,
+// the configuration of a singularity engine resolved from the command
+// line, singularity.conf and plugins. The encryption key is left out.
+func NewRuntimeConfig(cfg *config.Common) (*RuntimeConfig, error) {
+	rc := &RuntimeConfig{Common: cfg}
+
+	engineConfig, ok := cfg.EngineConfig.(*singularityConfig.EngineConfig)
+	if !ok {
+		return rc, nil
+	}
+
+	// the key is never shown, a copy of the engine configuration
+	// without it is dumped
+	jsonConfig := *engineConfig.JSON
+	jsonConfig.EncryptionKey = nil
+	rc.Common = &config.Common{
+		EngineName:  cfg.EngineName,
+		ContainerID: cfg.ContainerID,
+		EngineConfig: &singularityConfig.EngineConfig{
+			JSON:      &jsonConfig,
+			OciConfig: engineConfig.OciConfig,
+			File:      engineConfig.File,
+		},
+	}
+	rc.SingularityConf = engineConfig.File
+
+	if path := engineConfig.GetCgroupsPath(); path != "" {
+		cg, err := cgroups.LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("while loading cgroups profile %s: %s", path, err)
+		}
+		rc.Cgroups = &cg
+	}
+
+	return rc, nil
+}
+
+// DumpRuntimeConfig writes to w the effective runtime configuration
+// of cfg in JSON, see NewRuntimeConfig.
+func DumpRuntimeConfig(w io.Writer, cfg *config.Common) error {
+	rc, err := NewRuntimeConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rc); err != nil {
+		return fmt.Errorf("while encoding runtime configuration: %s", err)
+	}
+	return nil
+}
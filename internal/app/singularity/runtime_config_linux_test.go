@@ -0,0 +1,66 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
+)
+
+func TestDumpRuntimeConfig(t *testing.T) {
+	engineConfig := singularityConfig.NewConfig()
+	engineConfig.SetImage("/images/test.sif")
+	engineConfig.SetEncryptionKey([]byte("secret"))
+	engineConfig.SetCgroupsPath(filepath.Join("..", "..", "pkg", "cgroups", "example", "cgroups.toml"))
+
+	cfg := &config.Common{
+		EngineName:   singularityConfig.Name,
+		ContainerID:  "test",
+		EngineConfig: engineConfig,
+	}
+
+	var b bytes.Buffer
+	if err := DumpRuntimeConfig(&b, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var dumped struct {
+		EngineName   string `json:"engineName"`
+		EngineConfig struct {
+			JSON map[string]interface{} `json:"jsonConfig"`
+		} `json:"engineConfig"`
+		Cgroups map[string]interface{} `json:"cgroups"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &dumped); err != nil {
+		t.Fatalf("while decoding dumped configuration: %s", err)
+	}
+
+	if dumped.EngineName != singularityConfig.Name {
+		t.Errorf("got engine name %q, expected %q", dumped.EngineName, singularityConfig.Name)
+	}
+	if dumped.EngineConfig.JSON["image"] != "/images/test.sif" {
+		t.Errorf("got image %v, expected /images/test.sif", dumped.EngineConfig.JSON["image"])
+	}
+	if _, ok := dumped.EngineConfig.JSON["encryptionKey"]; ok {
+		t.Errorf("encryption key dumped")
+	}
+	if len(engineConfig.GetEncryptionKey()) == 0 {
+		t.Errorf("encryption key removed from the engine configuration")
+	}
+	if len(dumped.Cgroups) == 0 {
+		t.Errorf("cgroups profile not dumped")
+	}
+
+	engineConfig.SetCgroupsPath("/not/a/real/file")
+	if err := DumpRuntimeConfig(&b, cfg); err == nil {
+		t.Errorf("unexpected success with a missing cgroups profile")
+	}
+}
@@ -28,6 +28,12 @@ import (
 type SIFAssembler struct {
 	GzipFlag       bool
 	MksquashfsPath string
+	// Compression is the squashfs compression algorithm,
+	// the mksquashfs default is used when empty.
+	Compression string
+	// CompressionLevel is the level of Compression,
+	// the algorithm default is used when zero.
+	CompressionLevel int
 }
 
 type encryptionOptions struct {
@@ -174,7 +180,13 @@ func (a *SIFAssembler) Assemble(b *types.Bundle, path string) error {
 		flags = append(flags, "-all-root")
 	}
 	// specify compression if needed
-	if a.GzipFlag {
+	if a.Compression != "" {
+		compFlags, err := packer.CompressionFlags(a.Compression, a.CompressionLevel)
+		if err != nil {
+			return err
+		}
+		flags = append(flags, compFlags...)
+	} else if a.GzipFlag {
 		flags = append(flags, "-comp", "gzip")
 	}
 
@@ -8,6 +8,7 @@ package build
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/signal"
@@ -26,6 +27,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/config/oci"
 	imgbuildConfig "github.com/sylabs/singularity/internal/pkg/runtime/engine/imgbuild/config"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/squashfs"
 	"github.com/sylabs/singularity/internal/pkg/util/starter"
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
@@ -47,6 +49,8 @@ type Build struct {
 	stages []stage
 	// Conf contains cross stage build configuration.
 	Conf Config
+	// events dispatches build progress events.
+	events *emitter
 }
 
 // Config defines how build is executed, including things like where final image is written.
@@ -60,6 +64,12 @@ type Config struct {
 	NoCleanUp bool
 	// Opts for bundles.
 	Opts types.Options
+	// EventHandler receives the build progress events. When nil,
+	// section scripts output is written to the standard output
+	// and error streams of the process and events are only traced
+	// at debug level. Otherwise the scripts output is delivered
+	// line by line through output events.
+	EventHandler EventHandler
 }
 
 // NewBuild creates a new Build struct from a spec (URI, definition file, etc...).
@@ -88,13 +98,21 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 	}
 	conf.Dest = dest
 
+	if conf.Opts.TmpDir == "" {
+		conf.Opts.TmpDir = fs.TempDir()
+	}
+
 	// always build a sandbox if updating an existing sandbox
 	if conf.Opts.Update {
 		conf.Format = "sandbox"
 	}
 
 	b := &Build{
-		Conf: conf,
+		Conf:   conf,
+		events: &emitter{handler: conf.EventHandler},
+	}
+	if b.events.handler == nil {
+		b.events.handler = defaultEventHandler{}
 	}
 
 	// look if there is mount options set which could conflict
@@ -187,6 +205,9 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 	// only need an assembler for last stage
 	switch conf.Format {
 	case "sandbox":
+		if conf.Opts.SquashfsCompression != "" || conf.Opts.SquashfsCompressionLevel != 0 {
+			return nil, fmt.Errorf("squashfs compression can't be selected for a sandbox build")
+		}
 		b.stages[lastStageIndex].a = &assemblers.SandboxAssembler{Copy: sandboxCopy}
 	case "sif":
 		mksquashfsPath, err := squashfs.GetPath()
@@ -194,14 +215,23 @@ func newBuild(defs []types.Definition, conf Config) (*Build, error) {
 			return nil, fmt.Errorf("while searching for mksquashfs: %v", err)
 		}
 
-		flag, err := ensureGzipComp(b.stages[lastStageIndex].b.TmpDir, mksquashfsPath)
+		a := &assemblers.SIFAssembler{
+			MksquashfsPath:   mksquashfsPath,
+			Compression:      conf.Opts.SquashfsCompression,
+			CompressionLevel: conf.Opts.SquashfsCompressionLevel,
+		}
+		if a.Compression == "" {
+			if conf.Opts.SquashfsCompressionLevel != 0 {
+				return nil, fmt.Errorf("squashfs compression level requires a compression algorithm")
+			}
+			a.GzipFlag, err = ensureGzipComp(b.stages[lastStageIndex].b.TmpDir, mksquashfsPath)
+		} else {
+			err = ensureComp(b.stages[lastStageIndex].b.TmpDir, mksquashfsPath, a.Compression, a.CompressionLevel)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("while ensuring correct compression algorithm: %v", err)
 		}
-		b.stages[lastStageIndex].a = &assemblers.SIFAssembler{
-			GzipFlag:       flag,
-			MksquashfsPath: mksquashfsPath,
-		}
+		b.stages[lastStageIndex].a = a
 	default:
 		return nil, fmt.Errorf("unrecognized output format %s", conf.Format)
 	}
@@ -277,6 +307,58 @@ func ensureGzipComp(tmpdir, mksquashfsPath string) (bool, error) {
 	return false, fmt.Errorf("could not build squashfs with required gzip compression")
 }
 
+// ensureComp builds a dummy squashfs image compressed with the algorithm
+// comp at level and checks the compression used. It returns an error if
+// the compression is not supported or not available with mksquashfs.
+func ensureComp(tmpdir, mksquashfsPath, comp string, level int) error {
+	sylog.Debugf("Ensuring %s compression for mksquashfs", comp)
+
+	flags, err := packer.CompressionFlags(comp, level)
+	if err != nil {
+		return err
+	}
+
+	s := packer.NewSquashfs()
+	s.MksquashfsPath = mksquashfsPath
+
+	srcf, err := ioutil.TempFile(tmpdir, "squashfs-comp-test-src")
+	if err != nil {
+		return fmt.Errorf("while creating temporary file for squashfs source: %v", err)
+	}
+	defer os.Remove(srcf.Name())
+
+	srcf.Write([]byte("Test File Content"))
+	srcf.Close()
+
+	f, err := ioutil.TempFile(tmpdir, "squashfs-comp-test-")
+	if err != nil {
+		return fmt.Errorf("while creating temporary file for squashfs: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	flags = append([]string{"-noappend"}, flags...)
+	if err := s.Create([]string{srcf.Name()}, f.Name(), flags); err != nil {
+		return fmt.Errorf("could not build squashfs with %s compression, it may not be supported by %s: %v", comp, mksquashfsPath, err)
+	}
+
+	content, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return fmt.Errorf("while reading test squashfs: %v", err)
+	}
+
+	found, err := image.GetSquashfsComp(content)
+	if err != nil {
+		return fmt.Errorf("could not verify squashfs compression type: %v", err)
+	}
+	if found != comp {
+		return fmt.Errorf("could not build squashfs with required %s compression, got %s compression", comp, found)
+	}
+
+	sylog.Debugf("%s compression ensured", comp)
+	return nil
+}
+
 // cleanUp removes remnants of build from file system unless NoCleanUp is specified.
 func (b Build) cleanUp() {
 	if b.Conf.NoCleanUp {
@@ -316,37 +398,24 @@ func (b *Build) Full(ctx context.Context) error {
 
 	// build each stage one after the other
 	for i, stage := range b.stages {
-		if err := stage.runPreScript(); err != nil {
-			return err
-		}
+		b.events.setStage(stage.name)
 
-		// only update last stage if specified
-		update := stage.b.Opts.Update && !stage.b.Opts.Force && i == len(b.stages)-1
-		if update {
-			// updating, extract dest container to bundle
-			sylog.Infof("Building into existing container: %s", b.Conf.Dest)
-			p, err := sources.GetLocalPacker(b.Conf.Dest, stage.b)
-			if err != nil {
+		if stage.b.RunSection("pre") && stage.b.Recipe.BuildData.Pre.Script != "" {
+			if err := b.runSection("pre", func(stdout, stderr io.Writer) error {
+				return stage.runPreScript(stdout, stderr)
+			}); err != nil {
 				return err
 			}
+		}
 
-			_, err = p.Pack(ctx)
-			if err != nil {
-				return err
-			}
-		} else {
-			// regular build or force, start build from scratch
-			if b.Conf.Opts.ImgCache == nil {
-				return fmt.Errorf("undefined image cache")
-			}
-			if err := stage.c.Get(ctx, stage.b); err != nil {
-				return fmt.Errorf("conveyor failed to get: %v", err)
-			}
+		// only update last stage if specified
+		update := stage.b.Opts.Update && !stage.b.Opts.Force && i == len(b.stages)-1
 
-			_, err := stage.c.Pack(ctx)
-			if err != nil {
-				return fmt.Errorf("packer failed to pack: %v", err)
-			}
+		b.events.emit(Event{Type: EventBootstrapStart})
+		err := stage.bootstrap(ctx, b, update)
+		b.events.emit(Event{Type: EventBootstrapEnd, Err: err})
+		if err != nil {
+			return err
 		}
 
 		// create apps in bundle
@@ -359,13 +428,15 @@ func (b *Build) Full(ctx context.Context) error {
 		stage.b.Recipe.BuildData.Post.Script += a.HandlePost()
 
 		if stage.b.RunSection("files") {
-			if err := stage.copyFiles(b); err != nil {
+			if err := b.runSection("files", func(io.Writer, io.Writer) error {
+				return stage.copyFiles(b)
+			}); err != nil {
 				return fmt.Errorf("unable to copy files a stage to container fs: %v", err)
 			}
 		}
 
 		if engineRequired(stage.b.Recipe) {
-			if err := runBuildEngine(stage.b); err != nil {
+			if err := b.runEngine(stage.b); err != nil {
 				return fmt.Errorf("while running engine: %v", err)
 			}
 		}
@@ -378,6 +449,10 @@ func (b *Build) Full(ctx context.Context) error {
 
 	syscall.Umask(oldumask)
 
+	if err := insertChecksumManifest(b.stages[len(b.stages)-1].b); err != nil {
+		return fmt.Errorf("while inserting checksum manifest to bundle: %v", err)
+	}
+
 	sylog.Debugf("Calling assembler")
 	if err := b.stages[len(b.stages)-1].Assemble(b.Conf.Dest); err != nil {
 		return err
@@ -387,13 +462,87 @@ func (b *Build) Full(ctx context.Context) error {
 	return nil
 }
 
+// bootstrap retrieves and packs the stage bootstrap source into the stage
+// bundle, or the existing destination container when updating.
+func (s *stage) bootstrap(ctx context.Context, b *Build, update bool) error {
+	if update {
+		// updating, extract dest container to bundle
+		sylog.Infof("Building into existing container: %s", b.Conf.Dest)
+		p, err := sources.GetLocalPacker(b.Conf.Dest, s.b)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.Pack(ctx)
+		return err
+	}
+
+	// regular build or force, start build from scratch
+	if b.Conf.Opts.ImgCache == nil {
+		return fmt.Errorf("undefined image cache")
+	}
+	if err := s.c.Get(ctx, s.b); err != nil {
+		return fmt.Errorf("conveyor failed to get: %v", err)
+	}
+
+	if _, err := s.c.Pack(ctx); err != nil {
+		return fmt.Errorf("packer failed to pack: %v", err)
+	}
+
+	return nil
+}
+
+// outputs returns the streams to which section scripts must write
+// their output and a function flushing them once scripts are done.
+func (b *Build) outputs() (stdout io.Writer, stderr io.Writer, flush func()) {
+	if _, ok := b.events.handler.(defaultEventHandler); ok {
+		return os.Stdout, os.Stderr, func() {}
+	}
+
+	outw := &lineWriter{em: b.events, stream: "stdout"}
+	errw := &lineWriter{em: b.events, stream: "stderr"}
+
+	return outw, errw, func() {
+		outw.Flush()
+		errw.Flush()
+	}
+}
+
+// runSection executes fn, which runs the named section in the build
+// process, between section start and end events.
+func (b *Build) runSection(name string, fn func(stdout, stderr io.Writer) error) error {
+	stdout, stderr, flush := b.outputs()
+
+	b.events.sectionStart(name)
+	err := fn(stdout, stderr)
+	flush()
+	b.events.sectionEnd(name, err)
+
+	return err
+}
+
+// runEngine runs the build engine for the bundle, the sections events
+// are reported by the engine itself through its output.
+func (b *Build) runEngine(bundle *types.Bundle) error {
+	stdout, stderr, flush := b.outputs()
+	_, sectionEvents := stdout.(*lineWriter)
+
+	err := runBuildEngine(bundle, stdout, stderr, sectionEvents)
+	flush()
+	if err != nil {
+		b.events.endOpenSection(err)
+	}
+
+	return err
+}
+
 // engineRequired returns true if build definition is requesting to run scripts or copy files
 func engineRequired(def types.Definition) bool {
 	return def.BuildData.Post.Script != "" || def.BuildData.Setup.Script != "" || def.BuildData.Test.Script != "" || len(def.BuildData.Files) != 0
 }
 
 // runBuildEngine creates an imgbuild engine and creates a container out of our bundle in order to execute %post %setup scripts in the bundle
-func runBuildEngine(b *types.Bundle) error {
+func runBuildEngine(b *types.Bundle, stdout, stderr io.Writer, sectionEvents bool) error {
 	if syscall.Getuid() != 0 {
 		return fmt.Errorf("attempted to build with scripts as non-root user or without --fakeroot")
 	}
@@ -402,8 +551,9 @@ func runBuildEngine(b *types.Bundle) error {
 	ociConfig := &oci.Config{}
 
 	engineConfig := &imgbuildConfig.EngineConfig{
-		Bundle:    *b,
-		OciConfig: ociConfig,
+		Bundle:        *b,
+		OciConfig:     ociConfig,
+		SectionEvents: sectionEvents,
 	}
 
 	// surface build specific environment variables for scripts
@@ -422,8 +572,8 @@ func runBuildEngine(b *types.Bundle) error {
 	return starter.Run(
 		"Singularity image-build",
 		config,
-		starter.WithStdout(os.Stdout),
-		starter.WithStderr(os.Stderr),
+		starter.WithStdout(stdout),
+		starter.WithStderr(stderr),
 	)
 }
 
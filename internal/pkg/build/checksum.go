@@ -0,0 +1,112 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/build/types"
+)
+
+// checksumManifestPath is the location of the checksum manifest
+// relative to the container root filesystem.
+const checksumManifestPath = "/.singularity.d/checksums.sha256"
+
+// insertChecksumManifest walks the bundle root filesystem and writes
+// the sha256 of every regular file into the checksum manifest.
+func insertChecksumManifest(b *types.Bundle) error {
+	if !b.Opts.ChecksumManifest {
+		return nil
+	}
+
+	sylog.Infof("Adding checksum manifest to container")
+
+	manifest := filepath.Join(b.RootfsPath, checksumManifestPath)
+
+	f, err := os.OpenFile(manifest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("while creating %s: %s", manifest, err)
+	}
+	defer f.Close()
+
+	if err := writeChecksumManifest(f, b.RootfsPath, checksumManifestPath); err != nil {
+		return fmt.Errorf("while writing %s: %s", manifest, err)
+	}
+
+	return f.Sync()
+}
+
+// writeChecksumManifest writes to w one line per regular file found
+// under root, in the format used by sha256sum, sorted by path so the
+// output doesn't depend on the directory walk order. Paths listed in
+// exclude, relative to root, are skipped.
+func writeChecksumManifest(w io.Writer, root string, exclude ...string) error {
+	skip := make(map[string]struct{}, len(exclude))
+	for _, e := range exclude {
+		skip[filepath.Clean("/"+e)] = struct{}{}
+	}
+
+	var paths []string
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.Join("/", rel)
+		if _, ok := skip[rel]; !ok {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(paths)
+
+	bw := bufio.NewWriter(w)
+
+	for _, p := range paths {
+		sum, err := fileSHA256(filepath.Join(root, p))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(bw, "%x  %s\n", sum, p); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("while hashing %s: %s", path, err)
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteChecksumManifest(t *testing.T) {
+	root, err := ioutil.TempDir("", "checksum-manifest-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"/z":                     "z",
+		"/a/b":                   "",
+		"/etc/hosts":             "127.0.0.1 localhost\n",
+		"/.singularity.d/labels": "{}",
+	}
+	for path, content := range files {
+		p := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("while creating %s: %s", filepath.Dir(p), err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("while creating %s: %s", p, err)
+		}
+	}
+	if err := os.Symlink("/etc/hosts", filepath.Join(root, "link")); err != nil {
+		t.Fatalf("while creating symlink: %s", err)
+	}
+
+	expected := "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a  /.singularity.d/labels\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  /a/b\n" +
+		"081ef9d5367595d16e30b4b4549d9f43537320508b4ce0788963e10e4f808857  /etc/hosts\n"
+
+	var b bytes.Buffer
+	if err := writeChecksumManifest(&b, root, "/z"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if b.String() != expected {
+		t.Errorf("unexpected manifest:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"time"
+
+	imgbuildConfig "github.com/sylabs/singularity/internal/pkg/runtime/engine/imgbuild/config"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// EventType identifies the kind of a build event.
+type EventType int
+
+const (
+	// EventBootstrapStart is emitted before a stage starts
+	// to retrieve and pack its bootstrap source.
+	EventBootstrapStart EventType = iota
+	// EventBootstrapEnd is emitted once the bootstrap of a
+	// stage is finished, Err is set if it failed.
+	EventBootstrapEnd
+	// EventSectionStart is emitted before a definition
+	// section is executed.
+	EventSectionStart
+	// EventSectionEnd is emitted once a definition section
+	// has been executed, Err is set if it failed.
+	EventSectionEnd
+	// EventOutput is emitted for each line written by a
+	// section script.
+	EventOutput
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventBootstrapStart:
+		return "bootstrap-start"
+	case EventBootstrapEnd:
+		return "bootstrap-end"
+	case EventSectionStart:
+		return "section-start"
+	case EventSectionEnd:
+		return "section-end"
+	case EventOutput:
+		return "output"
+	}
+	return "unknown"
+}
+
+// Event describes the progress of a build.
+type Event struct {
+	// Type is the kind of event.
+	Type EventType
+	// Time is the time at which the event occurred.
+	Time time.Time
+	// Stage is the name of the stage being built, it's
+	// empty for single stage builds.
+	Stage string
+	// Section is the name of the definition section
+	// for section and output events.
+	Section string
+	// Stream is either "stdout" or "stderr" for output events.
+	Stream string
+	// Line is the output line without its trailing newline
+	// for output events.
+	Line string
+	// Err is the error which ends a bootstrap or a section, if any.
+	Err error
+}
+
+// EventHandler receives the events emitted during a build. HandleEvent
+// is called sequentially, events of a stage are received in order.
+type EventHandler interface {
+	HandleEvent(Event)
+}
+
+// EventHandlerFunc is an adapter to use an ordinary function
+// as an EventHandler.
+type EventHandlerFunc func(Event)
+
+// HandleEvent calls f(e).
+func (f EventHandlerFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// defaultEventHandler is used when no event handler is set, the section
+// scripts output goes straight to the terminal like it always did, so it
+// only traces events at debug level.
+type defaultEventHandler struct{}
+
+func (defaultEventHandler) HandleEvent(e Event) {
+	switch e.Type {
+	case EventBootstrapStart, EventSectionStart, EventSectionEnd, EventBootstrapEnd:
+		if e.Err != nil {
+			sylog.Debugf("Build event %s stage=%q section=%q: %s", e.Type, e.Stage, e.Section, e.Err)
+			return
+		}
+		sylog.Debugf("Build event %s stage=%q section=%q", e.Type, e.Stage, e.Section)
+	}
+}
+
+// emitter dispatches build events to the configured handler.
+type emitter struct {
+	sync.Mutex
+
+	handler EventHandler
+	stage   string
+	section string
+}
+
+func (em *emitter) emit(e Event) {
+	em.Lock()
+	defer em.Unlock()
+
+	em.emitLocked(e)
+}
+
+func (em *emitter) emitLocked(e Event) {
+	e.Time = time.Now()
+	e.Stage = em.stage
+	if e.Section == "" {
+		e.Section = em.section
+	}
+	em.handler.HandleEvent(e)
+}
+
+func (em *emitter) setStage(name string) {
+	em.Lock()
+	em.stage = name
+	em.section = ""
+	em.Unlock()
+}
+
+func (em *emitter) sectionStart(name string) {
+	em.Lock()
+	defer em.Unlock()
+
+	em.section = name
+	em.emitLocked(Event{Type: EventSectionStart, Section: name})
+}
+
+func (em *emitter) sectionEnd(name string, err error) {
+	em.Lock()
+	defer em.Unlock()
+
+	em.emitLocked(Event{Type: EventSectionEnd, Section: name, Err: err})
+	em.section = ""
+}
+
+// endOpenSection terminates, with err, a section started by the build
+// engine which never reported its end because the engine failed.
+func (em *emitter) endOpenSection(err error) {
+	em.Lock()
+	defer em.Unlock()
+
+	if em.section == "" {
+		return
+	}
+	em.emitLocked(Event{Type: EventSectionEnd, Section: em.section, Err: err})
+	em.section = ""
+}
+
+// lineWriter splits the output of section scripts into output events
+// and turns the section markers written by the build engine into section
+// events.
+type lineWriter struct {
+	em     *emitter
+	stream string
+	buf    bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.line(strings.TrimSuffix(line, "\n"))
+	}
+
+	return len(p), nil
+}
+
+// Flush emits the remaining output not terminated by a newline.
+func (w *lineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.line(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *lineWriter) line(l string) {
+	if strings.HasPrefix(l, imgbuildConfig.SectionEventPrefix) {
+		fields := strings.Fields(strings.TrimPrefix(l, imgbuildConfig.SectionEventPrefix))
+		if len(fields) == 2 {
+			switch fields[0] {
+			case imgbuildConfig.SectionEventStart:
+				w.em.sectionStart(fields[1])
+				return
+			case imgbuildConfig.SectionEventEnd:
+				w.em.sectionEnd(fields[1], nil)
+				return
+			}
+		}
+	}
+	w.em.emit(Event{Type: EventOutput, Stream: w.stream, Line: l})
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package build
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	imgbuildConfig "github.com/sylabs/singularity/internal/pkg/runtime/engine/imgbuild/config"
+)
+
+func TestLineWriter(t *testing.T) {
+	var events []Event
+
+	em := &emitter{
+		handler: EventHandlerFunc(func(e Event) {
+			events = append(events, e)
+		}),
+	}
+	em.setStage("devel")
+
+	w := &lineWriter{em: em, stream: "stdout"}
+
+	fmt.Fprintf(w, "%s%s post\n", imgbuildConfig.SectionEventPrefix, imgbuildConfig.SectionEventStart)
+	// lines split across writes are reassembled
+	fmt.Fprintf(w, "+ apt-get ")
+	fmt.Fprintf(w, "update\nHit:1 http://deb.debian.org\n")
+	fmt.Fprintf(w, "%s%s post\n", imgbuildConfig.SectionEventPrefix, imgbuildConfig.SectionEventEnd)
+	fmt.Fprintf(w, "%s%s test\n", imgbuildConfig.SectionEventPrefix, imgbuildConfig.SectionEventStart)
+	fmt.Fprintf(w, "no newline")
+	w.Flush()
+	em.endOpenSection(errors.New("test failed"))
+
+	expected := []Event{
+		{Type: EventSectionStart, Stage: "devel", Section: "post"},
+		{Type: EventOutput, Stage: "devel", Section: "post", Stream: "stdout", Line: "+ apt-get update"},
+		{Type: EventOutput, Stage: "devel", Section: "post", Stream: "stdout", Line: "Hit:1 http://deb.debian.org"},
+		{Type: EventSectionEnd, Stage: "devel", Section: "post"},
+		{Type: EventSectionStart, Stage: "devel", Section: "test"},
+		{Type: EventOutput, Stage: "devel", Section: "test", Stream: "stdout", Line: "no newline"},
+		{Type: EventSectionEnd, Stage: "devel", Section: "test", Err: errors.New("test failed")},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("got %d events, expected %d: %+v", len(events), len(expected), events)
+	}
+	for i, e := range events {
+		x := expected[i]
+		if e.Type != x.Type || e.Stage != x.Stage || e.Section != x.Section || e.Stream != x.Stream || e.Line != x.Line {
+			t.Errorf("event %d: got %+v, expected %+v", i, e, x)
+		}
+		if (e.Err == nil) != (x.Err == nil) {
+			t.Errorf("event %d: got error %v, expected %v", i, e.Err, x.Err)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event %d: time not set", i)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"runtime"
 
 	"github.com/sylabs/scs-library-client/client"
@@ -16,6 +17,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/uri"
 	"github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 )
 
 // LibraryConveyorPacker only needs to hold a packer to pack the image it pulls
@@ -53,8 +55,9 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 	sylog.Debugf("LibraryRef: %v", b.Recipe.Header["from"])
 
 	libraryClient, err := client.NewClient(&client.Config{
-		BaseURL:   libraryURL,
-		AuthToken: authToken,
+		BaseURL:    libraryURL,
+		AuthToken:  authToken,
+		HTTPClient: &http.Client{Transport: proxy.NewTransport()},
 	})
 	if err != nil {
 		return err
@@ -103,6 +106,7 @@ func (cp *LibraryConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err
 			} else if cacheFileHash != libraryImage.Hash {
 				return fmt.Errorf("cached file hash(%s) and expected Hash(%s) does not match", cacheFileHash, libraryImage.Hash)
 			}
+			b.Opts.ImgCache.SetSource(imagePath, "library://"+imageRef)
 		}
 	}
 
@@ -23,16 +23,21 @@ import (
 	"github.com/containers/image/v5/docker"
 	dockerarchive "github.com/containers/image/v5/docker/archive"
 	dockerdaemon "github.com/containers/image/v5/docker/daemon"
+	ciimage "github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/manifest"
 	ociarchive "github.com/containers/image/v5/oci/archive"
 	oci "github.com/containers/image/v5/oci/layout"
 	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	ociclient "github.com/sylabs/singularity/internal/pkg/client/oci"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/shell"
 	buildTypes "github.com/sylabs/singularity/pkg/build/types"
 	sytypes "github.com/sylabs/singularity/pkg/build/types"
+	"github.com/sylabs/singularity/pkg/image"
 )
 
 // OCIConveyorPacker holds stuff that needs to be packed into the bundle
@@ -43,6 +48,9 @@ type OCIConveyorPacker struct {
 	policyCtx *signature.PolicyContext
 	imgConfig imgspecv1.ImageConfig
 	sysCtx    *types.SystemContext
+	// provenance identifies the source OCI image, it's
+	// nil if it couldn't be computed
+	provenance *image.OCIProvenance
 }
 
 // Get downloads container information from the specified source
@@ -120,6 +128,15 @@ func (cp *OCIConveyorPacker) Get(ctx context.Context, b *sytypes.Bundle) (err er
 		return fmt.Errorf("invalid image source: %v", err)
 	}
 
+	// the provenance is computed from the source, the cached
+	// image doesn't hold the multi-architecture manifest list
+	cp.provenance, err = OCIProvenance(ctx, cp.srcRef, cp.sysCtx)
+	if err != nil {
+		sylog.Warningf("Could not compute the OCI provenance of %s: %s", ref, err)
+	} else {
+		cp.provenance.Source = b.Recipe.Header["bootstrap"] + ":" + ref
+	}
+
 	if !cp.b.Opts.NoCache {
 		// Grab the modified source ref from the cache
 		cp.srcRef, err = ociclient.ConvertReference(ctx, b.Opts.ImgCache, cp.srcRef, cp.sysCtx)
@@ -172,6 +189,11 @@ func (cp *OCIConveyorPacker) Pack(ctx context.Context) (*sytypes.Bundle, error)
 		return nil, fmt.Errorf("while inserting oci config: %v", err)
 	}
 
+	err = cp.insertOCIProvenance()
+	if err != nil {
+		return nil, fmt.Errorf("while inserting oci provenance: %v", err)
+	}
+
 	return cp.b, nil
 }
 
@@ -209,6 +231,92 @@ func (cp *OCIConveyorPacker) insertOCIConfig() error {
 	return nil
 }
 
+// OCIProvenance returns the provenance of the OCI image ref: the digest
+// of its manifest and of its layers. The manifest of a multi-architecture
+// image is the one selected for the platform of sys, the digest of the
+// manifest list is recorded along with it. The Source of the provenance
+// is the transport name of ref.
+func OCIProvenance(ctx context.Context, ref types.ImageReference, sys *types.SystemContext) (*image.OCIProvenance, error) {
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	raw, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	d, err := manifest.Digest(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &image.OCIProvenance{
+		Source:         transports.ImageName(ref),
+		ManifestDigest: d.String(),
+	}
+
+	var instance *digest.Digest
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(raw, mimeType)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := list.ChooseInstance(sys)
+		if err != nil {
+			return nil, err
+		}
+		instance = &selected
+		p.IndexDigest = d.String()
+		p.ManifestDigest = selected.String()
+	}
+
+	img, err := ciimage.FromUnparsedImage(ctx, sys, ciimage.UnparsedInstance(src, instance))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range img.LayerInfos() {
+		p.Layers = append(p.Layers, l.Digest.String())
+	}
+
+	config, err := img.OCIConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.Platform = config.OS + "/" + config.Architecture
+
+	return p, nil
+}
+
+// insertOCIProvenance records the provenance of the OCI image in the
+// labels of the image, they are completed by the build labels.
+func (cp *OCIConveyorPacker) insertOCIProvenance() error {
+	if cp.provenance == nil {
+		return nil
+	}
+
+	path := filepath.Join(cp.b.RootfsPath, "/.singularity.d/labels.json")
+	labels := make(map[string]string)
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &labels); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for k, v := range cp.provenance.Labels() {
+		labels[k] = v
+	}
+
+	text, err := json.MarshalIndent(labels, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, text, 0644)
+}
+
 // Perform a dumb tar(gz) extraction with no chown, id remapping etc.
 // This is needed for non-root handling of `oci-archive` as the extraction
 // by containers/archive is failing when uid/gid don't match local machine
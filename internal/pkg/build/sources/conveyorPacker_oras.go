@@ -51,6 +51,7 @@ func (cp *OrasConveyorPacker) Get(ctx context.Context, b *types.Bundle) (err err
 		} else if cacheFileHash != sum {
 			return fmt.Errorf("cached file hash(%s) and expected hash(%s) does not match", cacheFileHash, sum)
 		}
+		b.Opts.ImgCache.SetSource(cacheImagePath, fullRef)
 	}
 
 	// insert base metadata before unpacking fs
@@ -0,0 +1,134 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sources_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sylabs/singularity/internal/pkg/build/sources"
+)
+
+// writeBlob stores the JSON encoding of v, or v itself if it's
+// a []byte, in the blobs of the OCI layout dir.
+func writeBlob(t *testing.T, dir, mediaType string, v interface{}) imgspecv1.Descriptor {
+	data, ok := v.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			t.Fatalf("while encoding blob: %s", err)
+		}
+	}
+	d := digest.FromBytes(data)
+	path := filepath.Join(dir, "blobs", d.Algorithm().String(), d.Hex())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("while creating blobs directory: %s", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("while writing blob: %s", err)
+	}
+	return imgspecv1.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+}
+
+// writeImage stores an image with a single fake layer for
+// the architecture arch in the OCI layout dir.
+func writeImage(t *testing.T, dir, arch string) (imgspecv1.Descriptor, digest.Digest) {
+	layer := writeBlob(t, dir, imgspecv1.MediaTypeImageLayerGzip, []byte("layer for "+arch))
+	config := writeBlob(t, dir, imgspecv1.MediaTypeImageConfig, imgspecv1.Image{
+		Architecture: arch,
+		OS:           "linux",
+		RootFS:       imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{layer.Digest}},
+	})
+	manifest := writeBlob(t, dir, imgspecv1.MediaTypeImageManifest, imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		Config:    config,
+		Layers:    []imgspecv1.Descriptor{layer},
+	})
+	manifest.Platform = &imgspecv1.Platform{Architecture: arch, OS: "linux"}
+	return manifest, layer.Digest
+}
+
+// writeLayout writes the OCI layout dir holding the image tagged
+// latest described by desc.
+func writeLayout(t *testing.T, dir string, desc imgspecv1.Descriptor) {
+	desc.Annotations = map[string]string{imgspecv1.AnnotationRefName: "latest"}
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		Manifests: []imgspecv1.Descriptor{desc},
+	})
+	if err != nil {
+		t.Fatalf("while encoding index: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), index, 0644); err != nil {
+		t.Fatalf("while writing index: %s", err)
+	}
+	layout := []byte(`{"imageLayoutVersion": "` + imgspecv1.ImageLayoutVersion + `"}`)
+	if err := ioutil.WriteFile(filepath.Join(dir, imgspecv1.ImageLayoutFile), layout, 0644); err != nil {
+		t.Fatalf("while writing layout: %s", err)
+	}
+}
+
+func TestOCIProvenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci-provenance-")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	amd64, amd64Layer := writeImage(t, dir, "amd64")
+	arm64, arm64Layer := writeImage(t, dir, "arm64")
+	list := writeBlob(t, dir, imgspecv1.MediaTypeImageIndex, imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		Manifests: []imgspecv1.Descriptor{amd64, arm64},
+	})
+
+	ref, err := oci.ParseReference(dir + ":latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sys := &types.SystemContext{OSChoice: "linux", ArchitectureChoice: "arm64"}
+
+	// multi-architecture image
+	writeLayout(t, dir, list)
+	p, err := sources.OCIProvenance(context.Background(), ref, sys)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.IndexDigest != list.Digest.String() || p.ManifestDigest != arm64.Digest.String() {
+		t.Errorf("unexpected digests: %+v", p)
+	}
+	if p.Platform != "linux/arm64" {
+		t.Errorf("got platform %q, expected linux/arm64", p.Platform)
+	}
+	if len(p.Layers) != 1 || p.Layers[0] != arm64Layer.String() {
+		t.Errorf("got layers %q, expected %s", p.Layers, arm64Layer)
+	}
+
+	// single architecture image
+	writeLayout(t, dir, amd64)
+	p, err = sources.OCIProvenance(context.Background(), ref, sys)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.IndexDigest != "" || p.ManifestDigest != amd64.Digest.String() {
+		t.Errorf("unexpected digests: %+v", p)
+	}
+	if p.Platform != "linux/amd64" {
+		t.Errorf("got platform %q, expected linux/amd64", p.Platform)
+	}
+	if len(p.Layers) != 1 || p.Layers[0] != amd64Layer.String() {
+		t.Errorf("got layers %q, expected %s", p.Layers, amd64Layer)
+	}
+}
@@ -7,7 +7,7 @@ package build
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"syscall"
 
@@ -33,7 +33,7 @@ func (s *stage) Assemble(path string) error {
 }
 
 // runPreScript executes the stage's pre script on host.
-func (s *stage) runPreScript() error {
+func (s *stage) runPreScript(stdout, stderr io.Writer) error {
 	if s.b.RunSection("pre") && s.b.Recipe.BuildData.Pre.Script != "" {
 		if syscall.Getuid() != 0 {
 			return fmt.Errorf("attempted to build with scripts as non-root user or without --fakeroot")
@@ -41,8 +41,8 @@ func (s *stage) runPreScript() error {
 
 		// Run %pre script here
 		pre := exec.Command("/bin/sh", "-cex", s.b.Recipe.BuildData.Pre.Script)
-		pre.Stdout = os.Stdout
-		pre.Stderr = os.Stderr
+		pre.Stdout = stdout
+		pre.Stderr = stderr
 
 		sylog.Infof("Running pre scriptlet")
 		if err := pre.Start(); err != nil {
@@ -8,11 +8,13 @@ package cgroups
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/containerd/cgroups"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 )
 
 // Manager manage container cgroup resources restriction
@@ -89,15 +91,52 @@ func (m *Manager) ApplyFromSpec(spec *specs.LinuxResources) (err error) {
 }
 
 // ApplyFromFile applies cgroups resources restriction from TOML configuration
-// file
-func (m *Manager) ApplyFromFile(path string) error {
+// file, the devices rules are allowed after the ones of the file
+func (m *Manager) ApplyFromFile(path string, devices ...specs.LinuxDeviceCgroup) error {
 	spec, err := readSpecFromFile(path)
 	if err != nil {
 		return err
 	}
+	spec.Devices = append(spec.Devices, devices...)
 	return m.ApplyFromSpec(&spec)
 }
 
+// DeviceRule returns the devices cgroup rule allowing read, write and
+// mknod access to the character or block device node path
+func DeviceRule(path string) (specs.LinuxDeviceCgroup, error) {
+	var st unix.Stat_t
+
+	if err := unix.Stat(path, &st); err != nil {
+		return specs.LinuxDeviceCgroup{}, fmt.Errorf("while getting device number of %s: %s", path, err)
+	}
+
+	var devType string
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		devType = "c"
+	case unix.S_IFBLK:
+		devType = "b"
+	default:
+		return specs.LinuxDeviceCgroup{}, fmt.Errorf("%s is not a character or block device (mode %s)", path, os.FileMode(st.Mode&^unix.S_IFMT))
+	}
+
+	major := int64(unix.Major(uint64(st.Rdev)))
+	minor := int64(unix.Minor(uint64(st.Rdev)))
+	if major == 0 {
+		// major 0 is reserved to unnamed devices, it
+		// doesn't identify a device driver
+		return specs.LinuxDeviceCgroup{}, fmt.Errorf("%s has the unnamed device number 0:%d, it can't be allowed in the devices cgroup", path, minor)
+	}
+
+	return specs.LinuxDeviceCgroup{
+		Allow:  true,
+		Type:   devType,
+		Major:  &major,
+		Minor:  &minor,
+		Access: "rwm",
+	}, nil
+}
+
 func (m *Manager) loadFromPid() (err error) {
 	if m.Pid == 0 {
 		return fmt.Errorf("no process ID specified")
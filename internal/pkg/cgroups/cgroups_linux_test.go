@@ -186,3 +186,30 @@ func TestPauseResume(t *testing.T) {
 
 	cmd.Wait()
 }
+
+func TestDeviceRule(t *testing.T) {
+	rule, err := DeviceRule("/dev/null")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !rule.Allow || rule.Type != "c" || rule.Access != "rwm" {
+		t.Errorf("got rule %+v, expected an allowed rwm character device", rule)
+	}
+	if rule.Major == nil || *rule.Major != 1 || rule.Minor == nil || *rule.Minor != 3 {
+		t.Errorf("got device number %v:%v, expected 1:3", rule.Major, rule.Minor)
+	}
+
+	tmpfile, err := ioutil.TempFile("", "cgroups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := DeviceRule(tmpfile.Name()); err == nil {
+		t.Errorf("unexpected success with a regular file")
+	}
+	if _, err := DeviceRule("/not/a/device"); err == nil {
+		t.Errorf("unexpected success with a missing device")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// ErrEntryInUse is returned when removing a cache entry in use.
+var ErrEntryInUse = errors.New("cache entry in use")
+
+// ImageTypes lists the types of cache holding images, as accepted
+// by Entries.
+var ImageTypes = []string{"library", "oci", "shub", "net", "oras"}
+
+// Entry describes an image stored in the cache.
+type Entry struct {
+	// Type is the type of cache holding the image, see ImageTypes.
+	Type string
+	// Name is the name of the image in the cache.
+	Name string
+	// Path is the location of the image.
+	Path string
+	// Size is the size of the image in bytes.
+	Size int64
+	// AccessTime is the last time the image was read, as recorded by
+	// the filesystem, which may update it lazily (e.g. relatime).
+	AccessTime time.Time
+	// Source is the reference the image was fetched from, empty when
+	// it wasn't recorded.
+	Source string
+	// InUse reports whether the image was open or attached to a loop
+	// device when the entries were listed.
+	InUse bool
+}
+
+// sourceName returns the path of the file recording the source
+// reference of the cache entry path.
+func sourceName(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".source")
+}
+
+// SetSource records ref as the source reference of the cache entry
+// path, stripped of the credentials of an URL. The source is only
+// informational, a failure is logged.
+func (c *Handle) SetSource(path, ref string) {
+	if c.disabled || path == "" {
+		return
+	}
+	if u, err := url.Parse(ref); err == nil && u.User != nil {
+		u.User = nil
+		ref = u.String()
+	}
+	// a record written by a previous version may be
+	// readable by others, it's not reused
+	RemoveSource(path)
+	if err := ioutil.WriteFile(sourceName(path), []byte(ref), 0600); err != nil {
+		sylog.Debugf("Could not record source %s of cache entry %s: %s", ref, path, err)
+	}
+}
+
+// RemoveSource removes the source record of the cache entry path,
+// a failure is logged.
+func RemoveSource(path string) {
+	if err := os.Remove(sourceName(path)); err != nil && !os.IsNotExist(err) {
+		sylog.Debugf("Could not remove source record of %s: %s", path, err)
+	}
+}
+
+// typeDir returns the directory of the cache type t.
+func (c *Handle) typeDir(t string) (string, error) {
+	switch t {
+	case "library":
+		return c.Library, nil
+	case "oci":
+		return c.OciTemp, nil
+	case "shub":
+		return c.Shub, nil
+	case "net":
+		return c.Net, nil
+	case "oras":
+		return c.Oras, nil
+	}
+	return "", fmt.Errorf("%s is not a cache type holding images", t)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Entries returns the images stored in the caches of types, or in all
+// the ImageTypes if none is given, sorted by type and name.
+func (c *Handle) Entries(types ...string) ([]Entry, error) {
+	if c.disabled {
+		return nil, nil
+	}
+	if len(types) == 0 {
+		types = ImageTypes
+	}
+
+	used := usedPaths()
+
+	var entries []Entry
+	for _, t := range types {
+		dir, err := c.typeDir(t)
+		if err != nil {
+			return nil, err
+		}
+		// entries are stored as <dir>/<sum>/<name>
+		paths, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			name := filepath.Base(path)
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			fi, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				// removed concurrently
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("unable to get stat for %s: %s", path, err)
+			}
+			if !fi.Mode().IsRegular() {
+				continue
+			}
+			e := Entry{
+				Type:       t,
+				Name:       name,
+				Path:       path,
+				Size:       fi.Size(),
+				AccessTime: accessTime(fi),
+				InUse:      inUse(used, path),
+			}
+			if b, err := ioutil.ReadFile(sourceName(path)); err == nil {
+				e.Source = string(b)
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// Select returns the entries of Entries whose name matches the shell
+// pattern and which weren't accessed for at least olderThan. An empty
+// pattern or a zero olderThan match all the entries.
+func (c *Handle) Select(pattern string, olderThan time.Duration, types ...string) ([]Entry, error) {
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+	}
+
+	entries, err := c.Entries(types...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var selected []Entry
+	for _, e := range entries {
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, e.Name); !ok {
+				continue
+			}
+		}
+		if olderThan > 0 && now.Sub(e.AccessTime) < olderThan {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	return selected, nil
+}
+
+// RemoveEntry removes the cache entry e along with its source record.
+// It returns ErrEntryInUse if the image is currently open or attached
+// to a loop device.
+func (c *Handle) RemoveEntry(e Entry) error {
+	if c.disabled {
+		return nil
+	}
+	if inUse(usedPaths(), e.Path) {
+		return fmt.Errorf("%s: %w", e.Path, ErrEntryInUse)
+	}
+	if err := os.Remove(e.Path); err != nil {
+		return err
+	}
+	RemoveSource(e.Path)
+	// the directory named after the image sum is
+	// only removed once empty
+	os.Remove(filepath.Dir(e.Path))
+	return nil
+}
+
+// accessTime returns the last access time recorded in fi.
+func accessTime(fi os.FileInfo) time.Time {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime()
+	}
+	return time.Unix(st.Atim.Unix())
+}
+
+// inUse returns whether path, once resolved, is in the set used
+// returned by usedPaths.
+func inUse(used map[string]bool, path string) bool {
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	return used[path]
+}
+
+// usedPaths returns the set of files open by the processes visible to
+// the caller, or attached to a loop device.
+func usedPaths() map[string]bool {
+	used := make(map[string]bool)
+
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	for _, fd := range fds {
+		// fds of processes of other users aren't readable
+		if path, err := os.Readlink(fd); err == nil {
+			used[path] = true
+		}
+	}
+
+	files, _ := filepath.Glob("/sys/block/loop*/loop/backing_file")
+	for _, f := range files {
+		if b, err := ioutil.ReadFile(f); err == nil {
+			used[strings.TrimSpace(string(b))] = true
+		}
+	}
+
+	return used
+}
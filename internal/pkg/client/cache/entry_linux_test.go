@@ -0,0 +1,125 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+func TestEntries(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "cache-entries-")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewHandle(Config{BaseDir: dir})
+	if err != nil {
+		t.Fatalf("failed to create new image cache handle: %s", err)
+	}
+	c.checkIfCacheDisabled(t)
+
+	now := time.Now()
+	images := []struct {
+		path   string
+		source string
+		atime  time.Time
+	}{
+		{c.LibraryImage("sum1", "alpine_latest.sif"), "library://alpine:latest", now.Add(-48 * time.Hour)},
+		{c.OciTempImage("sum2", "ubuntu_18.04.sif"), "docker://ubuntu:18.04", now.Add(-time.Hour)},
+		{c.OciTempImage("sum4", "private_latest.sif"), "docker://registry.example.org/private:latest", now.Add(-time.Hour)},
+		{c.NetImage("hash", "sum3"), "", now.Add(-72 * time.Hour)},
+	}
+	for _, img := range images {
+		if err := ioutil.WriteFile(img.path, []byte(img.source), 0644); err != nil {
+			t.Fatalf("failed to create cache entry: %s", err)
+		}
+		if strings.Contains(img.source, "private") {
+			// the credentials are not recorded
+			c.SetSource(img.path, strings.Replace(img.source, "//", "//user:secret@", 1))
+		} else if img.source != "" {
+			c.SetSource(img.path, img.source)
+		}
+		if err := os.Chtimes(img.path, img.atime, img.atime); err != nil {
+			t.Fatalf("failed to set times of cache entry: %s", err)
+		}
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(entries) != len(images) {
+		t.Fatalf("got %d entries, expected %d: %v", len(entries), len(images), entries)
+	}
+	for _, e := range entries {
+		found := false
+		for _, img := range images {
+			if img.path != e.Path {
+				continue
+			}
+			found = true
+			if e.Source != img.source {
+				t.Errorf("got source %q for %s, expected %q", e.Source, e.Name, img.source)
+			}
+			if fi, err := os.Stat(sourceName(e.Path)); err == nil && fi.Mode().Perm() != 0600 {
+				t.Errorf("got source record mode %o for %s, expected 600", fi.Mode().Perm(), e.Name)
+			}
+			if e.Size != int64(len(img.source)) {
+				t.Errorf("got size %d for %s, expected %d", e.Size, e.Name, len(img.source))
+			}
+			if e.AccessTime.Unix() != img.atime.Unix() {
+				t.Errorf("got access time %s for %s, expected %s", e.AccessTime, e.Name, img.atime)
+			}
+		}
+		if !found {
+			t.Errorf("unexpected entry %s", e.Path)
+		}
+	}
+
+	selected, err := c.Select("*.sif", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(selected) != 1 || selected[0].Name != "alpine_latest.sif" {
+		t.Fatalf("got %v, expected alpine_latest.sif only", selected)
+	}
+	if _, err := c.Select("[", 0); err == nil {
+		t.Errorf("unexpected success with an invalid pattern")
+	}
+
+	f, err := os.Open(selected[0].Path)
+	if err != nil {
+		t.Fatalf("failed to open cache entry: %s", err)
+	}
+	if entries, _ := c.Select("alpine*", 0); len(entries) != 1 || !entries[0].InUse {
+		t.Errorf("open entry not reported in use: %v", entries)
+	}
+	if err := c.RemoveEntry(selected[0]); !errors.Is(err, ErrEntryInUse) {
+		t.Errorf("got error %v removing an entry in use, expected %v", err, ErrEntryInUse)
+	}
+	f.Close()
+
+	if err := c.RemoveEntry(selected[0]); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(sourceName(selected[0].Path)); !os.IsNotExist(err) {
+		t.Errorf("source record of %s not removed", selected[0].Path)
+	}
+	if entries, _ := c.Entries(); len(entries) != len(images)-1 {
+		t.Errorf("got %d entries after removal, expected %d", len(entries), len(images)-1)
+	}
+}
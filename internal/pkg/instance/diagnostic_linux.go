@@ -0,0 +1,111 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+)
+
+// Mounts returns the mount table of the instance mount namespace.
+// The kernel reports the mounts of the namespace of a process in
+// /proc/<pid>/mounts with the paths seen from the process root, so
+// the namespace is not joined. The error wraps ErrStateUnavailable if
+// /proc doesn't expose the instance process.
+func (i *File) Mounts() ([]proc.MountEntry, error) {
+	if i.isStale() {
+		return nil, fmt.Errorf("instance %s is not running", i.Name)
+	}
+	path, err := procPath(i.Pid, "mounts")
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := proc.GetMounts(path)
+	if err != nil {
+		return nil, procStateError(err)
+	}
+	return mounts, nil
+}
+
+// OpenFiles returns the files opened by the processes of the instance,
+// the processes sharing the PID namespace of the instance process.
+// The processes whose files can't be read are skipped. If the PID
+// namespace can't be read from /proc, only the files of the instance
+// process recorded in the instance file are returned.
+func (i *File) OpenFiles() ([]proc.OpenFile, error) {
+	if i.isStale() {
+		return nil, fmt.Errorf("instance %s is not running", i.Name)
+	}
+
+	pids, err := namespacePids(i.Pid, "pid")
+	if errors.Is(err, ErrStateUnavailable) {
+		sylog.Warningf("Only listing the files of the instance %s process: %s", i.Name, err)
+		files, err := proc.GetOpenFiles(i.Pid)
+		if err != nil {
+			return nil, procStateError(err)
+		}
+		return files, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var files []proc.OpenFile
+	for _, pid := range pids {
+		f, err := proc.GetOpenFiles(pid)
+		if err != nil {
+			sylog.Debugf("Skipping process %d of instance %s: %s", pid, i.Name, err)
+			continue
+		}
+		files = append(files, f...)
+	}
+	return files, nil
+}
+
+// namespacePids returns the processes sharing the namespace nstype
+// of the process pid, in increasing order.
+func namespacePids(pid int, nstype string) ([]int, error) {
+	var ns syscall.Stat_t
+
+	nsPath, err := procPath(pid, "ns", nstype)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Stat(nsPath, &ns); err != nil {
+		return nil, fmt.Errorf("while getting %s namespace of process %d: %w", nstype, pid, procStateError(err))
+	}
+
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return nil, procStateError(err)
+	}
+
+	var pids []int
+	for _, e := range entries {
+		p, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		var st syscall.Stat_t
+		if err := syscall.Stat(filepath.Join(procRoot, e.Name(), "ns", nstype), &st); err != nil {
+			// gone or owned by another user
+			continue
+		}
+		if st.Dev == ns.Dev && st.Ino == ns.Ino {
+			pids = append(pids, p)
+		}
+	}
+	sort.Ints(pids)
+
+	return pids, nil
+}
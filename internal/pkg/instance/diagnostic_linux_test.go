@@ -0,0 +1,110 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+)
+
+func TestMounts(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	running := &File{Name: "running", PPid: fakeInstancePid, Pid: os.Getpid()}
+
+	mounts, err := running.Mounts()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected, err := proc.GetMounts("/proc/self/mounts")
+	if err != nil {
+		t.Fatalf("while reading mounts: %s", err)
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("got mounts %v, expected %v", mounts, expected)
+	}
+
+	exited := &File{Name: "exited", PPid: -1, Pid: -1}
+	if _, err := exited.Mounts(); err == nil {
+		t.Errorf("unexpected success for an exited instance")
+	}
+}
+
+func TestOpenFiles(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	f, err := ioutil.TempFile("", "instance-openfiles-")
+	if err != nil {
+		t.Fatalf("while creating temporary file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	running := &File{Name: "running", PPid: fakeInstancePid, Pid: os.Getpid()}
+
+	files, err := running.OpenFiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := proc.OpenFile{Pid: os.Getpid(), Fd: int(f.Fd()), Path: f.Name()}
+	found := false
+	for _, of := range files {
+		if of == expected {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("open file %+v not found in %+v", expected, files)
+	}
+
+	exited := &File{Name: "exited", PPid: -1, Pid: -1}
+	if _, err := exited.OpenFiles(); err == nil {
+		t.Errorf("unexpected success for an exited instance")
+	}
+}
+
+func TestProcUnavailable(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	dir, err := ioutil.TempDir("", "instance-proc-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// /proc exposing no process
+	orig := procRoot
+	procRoot = dir
+	defer func() { procRoot = orig }()
+
+	running := &File{Name: "running", PPid: fakeInstancePid, Pid: os.Getpid()}
+	if running.isStale() {
+		t.Errorf("running instance reported as stale")
+	}
+
+	if _, err := running.Mounts(); !errors.Is(err, ErrStateUnavailable) {
+		t.Errorf("got error %v, expected %v", err, ErrStateUnavailable)
+	}
+
+	// the files of the recorded instance process are listed
+	files, err := running.OpenFiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, of := range files {
+		if of.Pid != os.Getpid() {
+			t.Errorf("unexpected open file %+v", of)
+		}
+	}
+}
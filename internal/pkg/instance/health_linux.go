@@ -0,0 +1,250 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+const (
+	// DefaultHealthInterval is the default time between two
+	// health check probes.
+	DefaultHealthInterval = 30 * time.Second
+	// DefaultHealthTimeout is the default time after which
+	// a health check probe is considered failed.
+	DefaultHealthTimeout = 10 * time.Second
+	// maxHealthOutput is the maximum size of the probe command
+	// output recorded in the instance file.
+	maxHealthOutput = 4096
+)
+
+// HealthStatus is the health of an instance as reported by
+// its health check.
+type HealthStatus string
+
+const (
+	// HealthStarting is the status of an instance whose health
+	// check didn't run yet.
+	HealthStarting HealthStatus = "starting"
+	// HealthHealthy is the status of an instance whose last
+	// health check probe succeeded.
+	HealthHealthy HealthStatus = "healthy"
+	// HealthUnhealthy is the status of an instance whose last
+	// health check probe failed.
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// HealthCheck describes the probe periodically run to check an
+// instance is healthy, either a command executed in the instance
+// or a TCP port of the instance accepting connections.
+type HealthCheck struct {
+	// Command is executed in the instance by a shell, the probe
+	// succeeds if it exits with a zero status.
+	Command string `json:"command,omitempty"`
+	// Port is a TCP port of the instance, the probe succeeds
+	// if a connection is established.
+	Port int `json:"port,omitempty"`
+	// Interval is the time between two probes.
+	Interval time.Duration `json:"interval,omitempty"`
+	// Timeout is the time after which a probe is failed.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// Check returns an error if the health check is not valid.
+func (hc *HealthCheck) Check() error {
+	switch {
+	case hc.Command == "" && hc.Port == 0:
+		return fmt.Errorf("health check requires a command or a port")
+	case hc.Command != "" && hc.Port != 0:
+		return fmt.Errorf("health check command and port are mutually exclusive")
+	case hc.Port < 0 || hc.Port > 65535:
+		return fmt.Errorf("invalid health check port %d", hc.Port)
+	case hc.Interval < 0:
+		return fmt.Errorf("invalid health check interval %s", hc.Interval)
+	case hc.Timeout < 0:
+		return fmt.Errorf("invalid health check timeout %s", hc.Timeout)
+	}
+	return nil
+}
+
+// interval returns the time between two probes.
+func (hc *HealthCheck) interval() time.Duration {
+	if hc.Interval == 0 {
+		return DefaultHealthInterval
+	}
+	return hc.Interval
+}
+
+// timeout returns the time after which a probe is failed.
+func (hc *HealthCheck) timeout() time.Duration {
+	if hc.Timeout == 0 {
+		return DefaultHealthTimeout
+	}
+	return hc.Timeout
+}
+
+// Health is the result of the last health check probe of an
+// instance, it's recorded in the instance file.
+type Health struct {
+	Status HealthStatus `json:"status"`
+	// Time is the time of the last probe.
+	Time time.Time `json:"time,omitempty"`
+	// Output is the beginning of the last probe command output,
+	// or the reason why the last probe failed.
+	Output string `json:"output,omitempty"`
+	// FailingStreak is the number of consecutive failed probes.
+	FailingStreak int `json:"failingStreak,omitempty"`
+}
+
+// HealthNotifier is called by MonitorHealth when the health status
+// of the instance changes, with the previous status and the new health.
+type HealthNotifier func(file *File, previous HealthStatus, health Health)
+
+// probeCommand returns the command executing the probe command in
+// the instance, it's also used by unit tests for mocking.
+var probeCommand = func(file *File, command string) *exec.Cmd {
+	singularity := filepath.Join(buildcfg.BINDIR, "singularity")
+	return exec.Command(singularity, "exec", "instance://"+file.Name, "/bin/sh", "-c", command)
+}
+
+// probe runs the health check of the instance file once and returns
+// the probe output, an error is returned if the probe failed.
+func probe(ctx context.Context, file *File, hc HealthCheck) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, hc.timeout())
+	defer cancel()
+
+	if hc.Port != 0 {
+		host := file.IP
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(hc.Port)))
+		if err != nil {
+			return "", err
+		}
+		return "", conn.Close()
+	}
+
+	var b bytes.Buffer
+	cmd := probeCommand(file, hc.Command)
+	cmd.Stdout = &b
+	cmd.Stderr = &b
+	// the probe runs in its own process group so the whole
+	// group is killed on timeout, the processes it spawned
+	// would otherwise keep the output open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	waitDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-waitDone:
+		}
+	}()
+	err := cmd.Wait()
+	close(waitDone)
+
+	output := strings.TrimSpace(b.String())
+	if len(output) > maxHealthOutput {
+		output = output[:maxHealthOutput]
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("probe timed out after %s", hc.timeout())
+	}
+	return output, err
+}
+
+// checkHealth runs a probe and returns the new health of the
+// instance given its previous health.
+func checkHealth(ctx context.Context, file *File, hc HealthCheck, previous Health) Health {
+	health := Health{Time: time.Now()}
+
+	output, err := probe(ctx, file, hc)
+	if err != nil {
+		health.Status = HealthUnhealthy
+		health.FailingStreak = previous.FailingStreak + 1
+		health.Output = err.Error()
+		if output != "" {
+			health.Output = output
+		}
+		return health
+	}
+	health.Status = HealthHealthy
+	health.Output = output
+	return health
+}
+
+// MonitorHealth runs the health check of the instance file every
+// interval and records the result of each probe in the instance file
+// until ctx is done. The notifier, if any, is called on each change of
+// the health status.
+func MonitorHealth(ctx context.Context, file *File, hc HealthCheck, notify HealthNotifier) {
+	health := Health{Status: HealthStarting}
+	file.Health = &health
+	if err := file.Update(); err != nil {
+		sylog.Warningf("Could not record instance %s health: %s", file.Name, err)
+	}
+
+	ticker := time.NewTicker(hc.interval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		previous := health.Status
+		health = checkHealth(ctx, file, hc, health)
+		if ctx.Err() != nil {
+			return
+		}
+
+		file.Health = &health
+		if err := file.Update(); err != nil {
+			sylog.Warningf("Could not record instance %s health: %s", file.Name, err)
+		}
+		if health.Status != previous && notify != nil {
+			notify(file, previous, health)
+		}
+	}
+}
+
+// GetHealth returns the health of the singularity instance name
+// of the current user as recorded by its last health check probe.
+// An error is returned if the instance has no health check.
+func GetHealth(name string) (*Health, error) {
+	return getHealth(name, SingSubDir)
+}
+
+func getHealth(name string, subDir string) (*Health, error) {
+	file, err := Get(name, subDir)
+	if err != nil {
+		return nil, err
+	}
+	if file.Health == nil {
+		return nil, fmt.Errorf("instance %s has no health check", name)
+	}
+	return file.Health, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+// mockProbeCommand runs the probe commands on the host.
+func mockProbeCommand() func() {
+	orig := probeCommand
+	probeCommand = func(file *File, command string) *exec.Cmd {
+		return exec.Command("/bin/sh", "-c", command)
+	}
+	return func() { probeCommand = orig }
+}
+
+func TestHealthCheckCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		hc          HealthCheck
+		expectError bool
+	}{
+		{"Command", HealthCheck{Command: "true"}, false},
+		{"Port", HealthCheck{Port: 8080, Interval: time.Minute, Timeout: time.Second}, false},
+		{"Empty", HealthCheck{}, true},
+		{"Both", HealthCheck{Command: "true", Port: 8080}, true},
+		{"InvalidPort", HealthCheck{Port: 70000}, true},
+		{"NegativeInterval", HealthCheck{Command: "true", Interval: -time.Second}, true},
+		{"NegativeTimeout", HealthCheck{Command: "true", Timeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hc.Check()
+			if err != nil && !tt.expectError {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	defer mockProbeCommand()()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("while listening: %s", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	defer ln.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("while listening: %s", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		hc          HealthCheck
+		output      string
+		expectError bool
+	}{
+		{"CommandSuccess", HealthCheck{Command: "echo ok"}, "ok", false},
+		{"CommandFailure", HealthCheck{Command: "echo ko; exit 1"}, "ko", true},
+		{"CommandTimeout", HealthCheck{Command: "sleep 5", Timeout: 100 * time.Millisecond}, "", true},
+		{"PortOpen", HealthCheck{Port: port}, "", false},
+		{"PortClosed", HealthCheck{Port: closedPort}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := probe(context.Background(), &File{Name: "test"}, tt.hc)
+			if err != nil && !tt.expectError {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("unexpected success")
+			}
+			if output != tt.output {
+				t.Errorf("got output %q, expected %q", output, tt.output)
+			}
+		})
+	}
+}
+
+func TestMonitorHealth(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	defer mockProbeCommand()()
+
+	file, err := Add("health", testSubDir)
+	if err != nil {
+		t.Fatalf("while adding instance: %s", err)
+	}
+	file.User = "root"
+	file.PPid = fakeInstancePid
+	if err := file.Update(); err != nil {
+		t.Fatalf("while creating instance: %s", err)
+	}
+	defer file.Delete()
+
+	if _, err := getHealth("health", testSubDir); err == nil {
+		t.Errorf("unexpected health for an instance without health check")
+	}
+
+	// the probe succeeds twice, fails twice and then succeeds
+	hc := HealthCheck{
+		Command:  `n=$(cat ` + file.Path + `.count 2>/dev/null || echo 0); echo $((n+1)) > ` + file.Path + `.count; [ $n -lt 2 ] || [ $n -gt 3 ]`,
+		Interval: 20 * time.Millisecond,
+	}
+
+	var mutex sync.Mutex
+	var transitions []HealthStatus
+	var streak int
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		MonitorHealth(ctx, file, hc, func(f *File, previous HealthStatus, health Health) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			transitions = append(transitions, health.Status)
+			if health.Status == HealthUnhealthy {
+				streak = health.FailingStreak
+			}
+			if len(transitions) == 3 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cancel()
+		<-done
+		t.Fatalf("health check monitor timed out")
+	}
+
+	expected := []HealthStatus{HealthHealthy, HealthUnhealthy, HealthHealthy}
+	if !reflect.DeepEqual(transitions, expected) {
+		t.Errorf("got transitions %v, expected %v", transitions, expected)
+	}
+	if streak != 1 {
+		t.Errorf("got failing streak %d on the first failure, expected 1", streak)
+	}
+
+	health, err := getHealth("health", testSubDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if health.Status != HealthHealthy || health.FailingStreak != 0 {
+		t.Errorf("got recorded health %+v, expected a healthy instance", health)
+	}
+}
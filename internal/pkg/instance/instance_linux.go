@@ -12,8 +12,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	"github.com/sylabs/singularity/pkg/syfs"
@@ -47,6 +50,13 @@ type File struct {
 	Config []byte `json:"config"`
 	UserNs bool   `json:"userns"`
 	IP     string `json:"ip"`
+	// IPs holds all the IP addresses of the instance, IP
+	// being the first one for compatibility
+	IPs []string `json:"ips,omitempty"`
+	// HealthCheck is the health check of the instance, if any,
+	// and Health the result of its last probe
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+	Health      *Health      `json:"health,omitempty"`
 }
 
 // ProcName returns processus name based on instance name
@@ -211,12 +221,13 @@ func (i *File) isExited() bool {
 		// process is alive and is owned by you otherwise
 		// we would have obtained permission denied error,
 		// now check if it's an instance parent process
-		cmdline := fmt.Sprintf("/proc/%d/cmdline", i.PPid)
+		cmdline := filepath.Join(procRoot, strconv.Itoa(i.PPid), "cmdline")
 		d, err := ioutil.ReadFile(cmdline)
 		if err != nil {
 			// this is racy and not accurate but as the process
 			// may have exited during above read, check again
-			// for process presence
+			// for process presence, the instance file is trusted
+			// if /proc doesn't expose the process
 			return syscall.Kill(i.PPid, 0) == syscall.ESRCH
 		}
 		// not an instance master process
@@ -226,6 +237,89 @@ func (i *File) isExited() bool {
 	return false
 }
 
+// staleGracePeriod is the time during which an undecodable instance
+// file is kept, it may be in the middle of an update by its instance.
+const staleGracePeriod = time.Minute
+
+// isStale returns whether the instance processes are gone, either
+// the instance parent process or the container process and its
+// namespaces. When /proc doesn't expose the container process, the
+// instance is considered running as recorded in the instance file.
+func (i *File) isStale() bool {
+	if i.isExited() || i.Pid <= 0 {
+		return true
+	}
+	if syscall.Kill(i.Pid, 0) == syscall.ESRCH {
+		return true
+	}
+	if !procVisible(i.Pid) {
+		return false
+	}
+	// the process may be a zombie whose namespaces are released
+	if _, err := os.Lstat(filepath.Join(procRoot, strconv.Itoa(i.Pid), "ns", "mnt")); os.IsNotExist(err) {
+		return true
+	}
+	return false
+}
+
+// PruneStale removes the files of the singularity instances of the
+// current user which are not running anymore, like those left behind
+// by a node reboot, and returns the names of the removed instances.
+// Running instances are never removed so it's safe to call while
+// instances are started or stopped.
+func PruneStale() ([]string, error) {
+	return pruneStale(SingSubDir)
+}
+
+func pruneStale(subDir string) ([]string, error) {
+	path, err := getPath("", subDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+
+	for _, file := range files {
+		name := filepath.Base(filepath.Dir(file))
+		if filepath.Base(file) != name+".json" {
+			continue
+		}
+
+		f := &File{}
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return pruned, err
+		}
+		if err := json.Unmarshal(data, f); err != nil {
+			// a truncated file can be an update in progress,
+			// only remove it once it's old enough
+			fi, err := os.Stat(file)
+			if err != nil || time.Since(fi.ModTime()) < staleGracePeriod {
+				continue
+			}
+			f = &File{Name: name}
+		} else if !f.isStale() {
+			continue
+		}
+
+		f.Path = file
+		if err := f.Delete(); err != nil {
+			return pruned, fmt.Errorf("while removing instance %s files: %s", name, err)
+		}
+		pruned = append(pruned, name)
+	}
+
+	sort.Strings(pruned)
+
+	return pruned, nil
+}
+
 // Update stores instance information in associated instance file
 func (i *File) Update() error {
 	b, err := json.Marshal(i)
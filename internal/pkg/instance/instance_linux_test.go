@@ -6,9 +6,11 @@
 package instance
 
 import (
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/test"
@@ -251,6 +253,70 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestPruneStale(t *testing.T) {
+	test.EnsurePrivilege(t)
+
+	// a process which is not running anymore
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("while running process: %s", err)
+	}
+	exitedPid := cmd.Process.Pid
+
+	instances := []struct {
+		name string
+		ppid int
+		pid  int
+	}{
+		{name: "running", ppid: fakeInstancePid, pid: os.Getpid()},
+		{name: "exited", ppid: exitedPid, pid: exitedPid},
+		{name: "container_exited", ppid: fakeInstancePid, pid: exitedPid},
+		{name: "not_instance", ppid: os.Getpid(), pid: os.Getpid()},
+	}
+
+	for _, i := range instances {
+		file, err := Add(i.name, testSubDir)
+		if err != nil {
+			t.Fatalf("while adding instance %s: %s", i.name, err)
+		}
+		file.User = "root"
+		file.PPid = i.ppid
+		file.Pid = i.pid
+		if err := file.Update(); err != nil {
+			t.Fatalf("while creating instance %s: %s", i.name, err)
+		}
+		defer file.Delete()
+	}
+
+	// an instance file being written is not removed
+	file, err := Add("updating", testSubDir)
+	if err != nil {
+		t.Fatalf("while adding instance: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0700); err != nil {
+		t.Fatalf("while creating instance directory: %s", err)
+	}
+	if err := ioutil.WriteFile(file.Path, []byte("{\"name\":"), 0644); err != nil {
+		t.Fatalf("while writing instance file: %s", err)
+	}
+	defer file.Delete()
+
+	pruned, err := pruneStale(testSubDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"container_exited", "exited", "not_instance"}
+	if !reflect.DeepEqual(pruned, expected) {
+		t.Errorf("got pruned instances %v, expected %v", pruned, expected)
+	}
+
+	for _, name := range []string{"running", "updating"} {
+		if _, err := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(file.Path)), name)); err != nil {
+			t.Errorf("instance %s was removed", name)
+		}
+	}
+}
+
 func TestMain(m *testing.M) {
 	// spawn a fake instance process
 	cmd := exec.Command("cat")
@@ -0,0 +1,49 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrStateUnavailable is returned when the state of a running instance
+// can't be read from /proc, which restricted sandboxes and nested
+// containers don't always fully expose.
+var ErrStateUnavailable = errors.New("instance state unavailable in this environment")
+
+// procRoot is the mount point of the proc filesystem.
+var procRoot = "/proc"
+
+// procVisible returns whether the process pid is visible in /proc.
+func procVisible(pid int) bool {
+	_, err := os.Stat(filepath.Join(procRoot, strconv.Itoa(pid)))
+	return err == nil
+}
+
+// procPath returns the path of the entry elem of the running process
+// pid in /proc, the error wraps ErrStateUnavailable if the process
+// isn't visible there.
+func procPath(pid int, elem ...string) (string, error) {
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+	if _, err := os.Stat(dir); err != nil {
+		return "", procStateError(err)
+	}
+	return filepath.Join(append([]string{dir}, elem...)...), nil
+}
+
+// procStateError returns err, raised while reading the state of a
+// running process in /proc, wrapping ErrStateUnavailable if the entry
+// is missing or can't be accessed.
+func procStateError(err error) error {
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return fmt.Errorf("%w: %s", ErrStateUnavailable, err)
+	}
+	return err
+}
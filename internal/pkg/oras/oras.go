@@ -13,12 +13,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
 	"github.com/containerd/containerd/remotes/docker"
 	ocitypes "github.com/containers/image/v5/types"
 	"github.com/deislabs/oras/pkg/content"
@@ -27,6 +29,7 @@ import (
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	"github.com/sylabs/singularity/pkg/image"
 )
 
@@ -56,7 +59,7 @@ func DownloadImage(imagePath, ref string, ociAuth *ocitypes.DockerAuthConfig) er
 		sylog.Infof("No tag or digest found, using default: %s", SifDefaultTag)
 	}
 
-	resolver := docker.NewResolver(docker.ResolverOptions{Credentials: genCredfn(ociAuth)})
+	resolver := newResolver(ociAuth)
 
 	wd, err := os.Getwd()
 	if err != nil {
@@ -132,7 +135,7 @@ func UploadImage(path, ref string, ociAuth *ocitypes.DockerAuthConfig) error {
 		sylog.Infof("No tag or digest found, using default: %s", SifDefaultTag)
 	}
 
-	resolver := docker.NewResolver(docker.ResolverOptions{Credentials: genCredfn(ociAuth)})
+	resolver := newResolver(ociAuth)
 
 	store := content.NewFileStore("")
 	defer store.Close()
@@ -183,7 +186,7 @@ func ensureSIF(filepath string) error {
 func ImageSHA(ctx context.Context, uri string, ociAuth *ocitypes.DockerAuthConfig) (string, error) {
 	ref := strings.TrimPrefix(uri, "//")
 
-	resolver := docker.NewResolver(docker.ResolverOptions{Credentials: genCredfn(ociAuth)})
+	resolver := newResolver(ociAuth)
 
 	_, desc, err := resolver.Resolve(ctx, ref)
 	if err != nil {
@@ -257,6 +260,15 @@ func sha256sum(r io.Reader) (result string, nBytes int64, err error) {
 	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nBytes, nil
 }
 
+// newResolver returns a registry resolver authenticating with ociAuth
+// and sending the requests through the configured proxy.
+func newResolver(ociAuth *ocitypes.DockerAuthConfig) remotes.Resolver {
+	return docker.NewResolver(docker.ResolverOptions{
+		Credentials: genCredfn(ociAuth),
+		Client:      &http.Client{Transport: proxy.NewTransport()},
+	})
+}
+
 func genCredfn(ociAuth *ocitypes.DockerAuthConfig) func(string) (string, string, error) {
 	return func(_ string) (string, string, error) {
 		if ociAuth != nil {
@@ -0,0 +1,79 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/sylabs/singularity/internal/pkg/util/user"
+)
+
+// Actor identifies the user who performed an operation
+// on an installed plugin.
+type Actor struct {
+	// UID is the real user ID of the process.
+	UID int `json:"UID"`
+	// EUID is the effective user ID of the process.
+	EUID int `json:"EUID"`
+	// Username is the name of the user. When running
+	// under sudo this is the name of the invoking user.
+	Username string `json:"Username"`
+	// Sudo reports whether or not the operation was
+	// performed through sudo.
+	Sudo bool `json:"Sudo"`
+	// SudoUID is the user ID of the user invoking sudo.
+	SudoUID int `json:"SudoUID,omitempty"`
+}
+
+// String returns a human readable representation of the actor.
+func (a Actor) String() string {
+	name := a.Username
+	if name == "" {
+		name = "unknown"
+	}
+	if a.Sudo {
+		return fmt.Sprintf("%s via sudo (uid=%d, euid=%d, sudo_uid=%d)", name, a.UID, a.EUID, a.SudoUID)
+	}
+	return fmt.Sprintf("%s (uid=%d, euid=%d)", name, a.UID, a.EUID)
+}
+
+// currentActor returns the actor corresponding to the current process.
+func currentActor() *Actor {
+	a := &Actor{
+		UID:  os.Getuid(),
+		EUID: os.Geteuid(),
+	}
+
+	// SUDO_UID and SUDO_USER are only meaningful when sudo
+	// raised privileges, ignore them otherwise as anybody
+	// can set them in their environment.
+	if a.EUID == 0 {
+		sudoUID, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+		sudoUser := os.Getenv("SUDO_USER")
+		if err == nil {
+			a.SudoUID = sudoUID
+			a.Sudo = true
+			if sudoUser == "" {
+				if pw, err := user.GetPwUID(uint32(sudoUID)); err == nil {
+					sudoUser = pw.Name
+				}
+			}
+		}
+		if sudoUser != "" {
+			a.Username = sudoUser
+			a.Sudo = true
+			return a
+		}
+	}
+
+	if pw, err := user.GetPwUID(uint32(a.EUID)); err == nil {
+		a.Username = pw.Name
+	}
+
+	return a
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"os"
+	"testing"
+)
+
+func TestActorString(t *testing.T) {
+	cases := []struct {
+		description string
+		actor       Actor
+		expected    string
+	}{
+		{
+			description: "unknown user",
+			actor:       Actor{UID: 1000, EUID: 1000},
+			expected:    "unknown (uid=1000, euid=1000)",
+		},
+		{
+			description: "regular user",
+			actor:       Actor{UID: 1000, EUID: 1000, Username: "alice"},
+			expected:    "alice (uid=1000, euid=1000)",
+		},
+		{
+			description: "sudo user",
+			actor:       Actor{UID: 0, EUID: 0, Username: "alice", Sudo: true, SudoUID: 1000},
+			expected:    "alice via sudo (uid=0, euid=0, sudo_uid=1000)",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			if actual := tc.actor.String(); actual != tc.expected {
+				t.Errorf("got %q, expected %q", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCurrentActor(t *testing.T) {
+	defer os.Unsetenv("SUDO_USER")
+	defer os.Unsetenv("SUDO_UID")
+
+	os.Setenv("SUDO_USER", "sudo-test-user")
+	os.Setenv("SUDO_UID", "1234")
+
+	a := currentActor()
+	if a.UID != os.Getuid() || a.EUID != os.Geteuid() {
+		t.Errorf("unexpected uid/euid %d/%d", a.UID, a.EUID)
+	}
+
+	// SUDO_USER must only be honored for privileged processes
+	privileged := os.Geteuid() == 0
+	if a.Sudo != privileged {
+		t.Errorf("unexpected sudo %v for euid %d", a.Sudo, a.EUID)
+	}
+	if privileged && a.Username != "sudo-test-user" {
+		t.Errorf("unexpected username %q, expected the SUDO_USER value", a.Username)
+	}
+	if privileged && a.SudoUID != 1234 || !privileged && a.SudoUID != 0 {
+		t.Errorf("unexpected sudo uid %d", a.SudoUID)
+	}
+
+	// the invoking user is looked up when only SUDO_UID is set
+	os.Unsetenv("SUDO_USER")
+	os.Setenv("SUDO_UID", "0")
+	a = currentActor()
+	if privileged && (!a.Sudo || a.Username != "root") {
+		t.Errorf("unexpected actor %s, expected root via sudo", a)
+	}
+}
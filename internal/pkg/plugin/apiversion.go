@@ -0,0 +1,37 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+var (
+	// errAPITooNew is returned for a plugin compiled against a plugin
+	// API newer than the one implemented by this singularity.
+	errAPITooNew = errors.New("plugin API version not supported yet")
+	// errAPITooOld is returned for a plugin compiled against a plugin
+	// API older than the oldest one still supported.
+	errAPITooOld = errors.New("plugin API version not supported anymore")
+)
+
+// checkAPIVersion returns an error if the plugin API version is not
+// supported. An unset version is accepted, plugins compiled before
+// API versions were introduced don't record one.
+func checkAPIVersion(version int) error {
+	switch {
+	case version == 0:
+		return nil
+	case version > pluginapi.APIVersion:
+		return fmt.Errorf("%w: plugin requires API version %d but this singularity only supports up to version %d, upgrade singularity", errAPITooNew, version, pluginapi.APIVersion)
+	case version < pluginapi.MinAPIVersion:
+		return fmt.Errorf("%w: plugin was compiled against API version %d but this singularity requires version %d or later, recompile the plugin", errAPITooOld, version, pluginapi.MinAPIVersion)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestCheckAPIVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		expected error
+	}{
+		{name: "Unset", version: 0},
+		{name: "Current", version: pluginapi.APIVersion},
+		{name: "Oldest", version: pluginapi.MinAPIVersion},
+		{name: "Newer", version: pluginapi.APIVersion + 1, expected: errAPITooNew},
+		{name: "Older", version: -1, expected: errAPITooOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAPIVersion(tt.version)
+			if tt.expected == nil && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tt.expected != nil && !errors.Is(err, tt.expected) {
+				t.Errorf("got error %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInstallAPIVersion(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-api-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{Name: "example.com/future", APIVersion: pluginapi.APIVersion + 1}
+	sifPath := createTestPlugin(t, dir, manifest)
+
+	if err := Install(sifPath, ""); !errors.Is(err, errAPITooNew) {
+		t.Errorf("unexpected error for a plugin requiring a newer API: %v", err)
+	}
+	if _, err := os.Stat(metaPath(manifest.Name)); !os.IsNotExist(err) {
+		t.Errorf("plugin requiring a newer API was installed")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// pluginBinary is a plugin binary object of a plugin SIF.
+type pluginBinary struct {
+	descr *sif.Descriptor
+	name  string
+	arch  string
+}
+
+// pluginBinaries returns the plugin binary objects of fimg along with
+// their architecture with Go naming, see binaryArch.
+func pluginBinaries(fimg *sif.FileImage) []pluginBinary {
+	var binaries []pluginBinary
+
+	r := newSifFileImageReader(fimg)
+	for i := range fimg.DescrArr {
+		if !isBinaryDescriptor(r, i) {
+			continue
+		}
+		d := &fimg.DescrArr[i]
+		arch, err := binaryArch(r, d, i)
+		if err != nil {
+			continue
+		}
+		binaries = append(binaries, pluginBinary{
+			descr: d,
+			name:  d.GetName(),
+			arch:  arch,
+		})
+	}
+
+	return binaries
+}
+
+// binaryArch returns the architecture of the plugin binary object d,
+// the descriptor n of r, with Go naming. The descriptors without
+// partition information of historical layouts don't record it, it's
+// read from the ELF header of the object.
+func binaryArch(r sifReader, d *sif.Descriptor, n int) (string, error) {
+	if d.Datatype != sif.DataPartition {
+		return sharedObjectArch(r.GetData(n))
+	}
+	arch, err := d.GetArch()
+	if err != nil {
+		return "", err
+	}
+	return sif.GetGoArch(string(arch[:sif.HdrArchLen-1])), nil
+}
+
+// primaryBinaryName returns the descriptor name of the plugin
+// binary objects to load declared by manifest.
+func primaryBinaryName(manifest pluginapi.Manifest) string {
+	if manifest.PrimaryBinary != "" {
+		return manifest.PrimaryBinary
+	}
+	return pluginBinaryName
+}
+
+// primaryBinaries returns the plugin binary objects of fimg which can
+// be loaded, the other ones being helpers.
+func primaryBinaries(fimg *sif.FileImage) []pluginBinary {
+	var primaries []pluginBinary
+
+	name := primaryBinaryName(getManifest(newSifFileImageReader(fimg)))
+	for _, b := range pluginBinaries(fimg) {
+		if b.name == name {
+			primaries = append(primaries, b)
+		}
+	}
+
+	return primaries
+}
+
+// binaryArchitectures returns the sorted architectures of the plugin
+// binary objects of fimg which can be loaded.
+func binaryArchitectures(fimg *sif.FileImage) []string {
+	var archs []string
+
+	for _, b := range primaryBinaries(fimg) {
+		if !containsString(archs, b.arch) {
+			archs = append(archs, b.arch)
+		}
+	}
+	sort.Strings(archs)
+
+	return archs
+}
+
+// binaryForHost returns the descriptor of the plugin binary object of fimg
+// to install on the host. Only the binaries named after the primary binary
+// declared in the manifest, plugin.so by default, are candidates. The
+// architecture recorded in the descriptors is trusted over the one declared
+// in the manifest: the binary built for the host architecture is selected
+// and, for plugins with a single binary built for another architecture,
+// the binary is returned with a warning as the plugin would fail to load.
+// An error is returned when several binaries remain candidates.
+func binaryForHost(fimg *sif.FileImage) (*sif.Descriptor, error) {
+	name := primaryBinaryName(getManifest(newSifFileImageReader(fimg)))
+	binaries := primaryBinaries(fimg)
+	if len(binaries) == 0 {
+		return nil, fmt.Errorf("no plugin binary named %q found", name)
+	}
+
+	var host []pluginBinary
+	for _, b := range binaries {
+		if b.arch == runtime.GOARCH {
+			host = append(host, b)
+		}
+	}
+	switch {
+	case len(host) == 1:
+		return host[0].descr, nil
+	case len(host) > 1:
+		return nil, fmt.Errorf("%d plugin binaries named %q for the host architecture %s, the binary to load is ambiguous", len(host), name, runtime.GOARCH)
+	case len(binaries) > 1:
+		return nil, fmt.Errorf("no plugin binary for the host architecture %s, binaries are built for %s", runtime.GOARCH, strings.Join(binaryArchitectures(fimg), ", "))
+	}
+
+	sylog.Warningf("Plugin binary is built for %s, not for the host architecture %s", binaries[0].arch, runtime.GOARCH)
+	return binaries[0].descr, nil
+}
+
+// BinaryInfo describes a plugin binary object of a plugin image.
+type BinaryInfo struct {
+	// Name is the SIF descriptor name of the binary.
+	Name string
+	// Arch is the architecture of the binary, with Go naming.
+	Arch string
+	// Size is the size in bytes of the binary as stored in the
+	// image, it's the compressed size for compressed binaries.
+	Size int64
+	// Primary is true for the binaries which can be loaded,
+	// the other ones are helpers.
+	Primary bool
+	// Selected is true for the binary installed on the host.
+	Selected bool
+}
+
+// Binaries returns all the binary objects of the plugin image,
+// ordered as they are stored in the image.
+//
+// "name" can be either the name of plugin installed under rootDir
+// or the name of an image file corresponding to a plugin, see
+// LookupImage.
+func Binaries(name string) ([]BinaryInfo, error) {
+	path, release, err := imagePath(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer fimg.UnloadContainer()
+
+	if err := checkPluginFile(newSifFileImageReader(&fimg)); err != nil {
+		return nil, err
+	}
+
+	primary := primaryBinaryName(getManifest(newSifFileImageReader(&fimg)))
+	selected, err := binaryForHost(&fimg)
+	if err != nil {
+		sylog.Debugf("No plugin binary selected for the host: %s", err)
+	}
+
+	var infos []BinaryInfo
+	for _, b := range pluginBinaries(&fimg) {
+		infos = append(infos, BinaryInfo{
+			Name:     b.name,
+			Arch:     b.arch,
+			Size:     b.descr.Filelen,
+			Primary:  b.name == primary,
+			Selected: b.descr == selected,
+		})
+	}
+
+	return infos, nil
+}
+
+// archDiscrepancies compares the architectures declared in manifest to
+// the architectures of the binary objects present, it returns the
+// declared architectures missing a binary and the architectures of the
+// binaries not declared. There is no discrepancy when manifest doesn't
+// declare architectures.
+func archDiscrepancies(manifest pluginapi.Manifest, present []string) (missing, undeclared []string) {
+	if len(manifest.Architectures) == 0 {
+		return nil, nil
+	}
+
+	for _, arch := range manifest.Architectures {
+		if !containsString(present, arch) && !containsString(missing, arch) {
+			missing = append(missing, arch)
+		}
+	}
+	for _, arch := range present {
+		if !containsString(manifest.Architectures, arch) {
+			undeclared = append(undeclared, arch)
+		}
+	}
+
+	return missing, undeclared
+}
+
+// checkArchitectures returns an error if an architecture declared in
+// manifest is not in the architectures of the binaries present and
+// warns about the binaries whose architecture is not declared. When
+// strict is false, the missing architectures are only reported with
+// a warning.
+func checkArchitectures(manifest pluginapi.Manifest, present []string, strict bool) error {
+	missing, undeclared := archDiscrepancies(manifest, present)
+
+	if len(undeclared) > 0 {
+		sylog.Warningf("Plugin %q contains binaries for architectures not declared in its manifest: %s", manifest.Name, strings.Join(undeclared, ", "))
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("plugin %q declares architectures without binary: %s", manifest.Name, strings.Join(missing, ", "))
+	if !strict {
+		sylog.Warningf("%s", err)
+		return nil
+	}
+	return err
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// otherArch returns an architecture different from the host one.
+func otherArch() string {
+	if runtime.GOARCH == "arm64" {
+		return "amd64"
+	}
+	return "arm64"
+}
+
+func TestArchDiscrepancies(t *testing.T) {
+	other := otherArch()
+
+	tests := []struct {
+		name       string
+		declared   []string
+		present    []string
+		missing    []string
+		undeclared []string
+	}{
+		{
+			name:    "Undeclared",
+			present: []string{runtime.GOARCH, other},
+		},
+		{
+			name:     "Match",
+			declared: []string{other, runtime.GOARCH},
+			present:  []string{runtime.GOARCH, other},
+		},
+		{
+			name:     "Missing",
+			declared: []string{runtime.GOARCH, other, other},
+			present:  []string{runtime.GOARCH},
+			missing:  []string{other},
+		},
+		{
+			name:       "NotDeclared",
+			declared:   []string{runtime.GOARCH},
+			present:    []string{runtime.GOARCH, other},
+			undeclared: []string{other},
+		},
+		{
+			name:       "Both",
+			declared:   []string{other},
+			present:    []string{runtime.GOARCH},
+			missing:    []string{other},
+			undeclared: []string{runtime.GOARCH},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, undeclared := archDiscrepancies(pluginapi.Manifest{Architectures: tt.declared}, tt.present)
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("got missing architectures %v, expected %v", missing, tt.missing)
+			}
+			if !reflect.DeepEqual(undeclared, tt.undeclared) {
+				t.Errorf("got undeclared architectures %v, expected %v", undeclared, tt.undeclared)
+			}
+		})
+	}
+}
+
+func TestBinaryForHost(t *testing.T) {
+	other := otherArch()
+
+	tests := []struct {
+		name        string
+		primary     string
+		binaries    []testBinary
+		binary      testBinary
+		expectError bool
+	}{
+		{
+			name:     "Host",
+			binaries: []testBinary{{pluginBinaryName, runtime.GOARCH}},
+			binary:   testBinary{pluginBinaryName, runtime.GOARCH},
+		},
+		{
+			name:     "HostSecond",
+			binaries: []testBinary{{pluginBinaryName, other}, {pluginBinaryName, runtime.GOARCH}},
+			binary:   testBinary{pluginBinaryName, runtime.GOARCH},
+		},
+		{
+			name:     "SingleOther",
+			binaries: []testBinary{{pluginBinaryName, other}},
+			binary:   testBinary{pluginBinaryName, other},
+		},
+		{
+			name:        "NoHost",
+			binaries:    []testBinary{{pluginBinaryName, other}, {pluginBinaryName, "ppc64le"}},
+			expectError: true,
+		},
+		{
+			name:     "Helper",
+			binaries: []testBinary{{"helper.so", runtime.GOARCH}, {pluginBinaryName, runtime.GOARCH}},
+			binary:   testBinary{pluginBinaryName, runtime.GOARCH},
+		},
+		{
+			name:     "HelperOther",
+			binaries: []testBinary{{"helper.so", runtime.GOARCH}, {pluginBinaryName, other}},
+			binary:   testBinary{pluginBinaryName, other},
+		},
+		{
+			name:     "Primary",
+			primary:  "main.so",
+			binaries: []testBinary{{"helper.so", runtime.GOARCH}, {"main.so", runtime.GOARCH}},
+			binary:   testBinary{"main.so", runtime.GOARCH},
+		},
+		{
+			name:     "PrimaryAmongPlugins",
+			primary:  "main.so",
+			binaries: []testBinary{{pluginBinaryName, runtime.GOARCH}, {"main.so", other}, {"main.so", runtime.GOARCH}},
+			binary:   testBinary{"main.so", runtime.GOARCH},
+		},
+		{
+			name:        "PrimaryMissing",
+			primary:     "main.so",
+			binaries:    []testBinary{{pluginBinaryName, runtime.GOARCH}},
+			expectError: true,
+		},
+		{
+			name:        "HelperOnly",
+			binaries:    []testBinary{{"helper.so", runtime.GOARCH}},
+			expectError: true,
+		},
+		{
+			name:        "Ambiguous",
+			binaries:    []testBinary{{pluginBinaryName, runtime.GOARCH}, {pluginBinaryName, runtime.GOARCH}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-arch-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			manifest := pluginapi.Manifest{Name: "example.com/arch", PrimaryBinary: tt.primary}
+			sifPath := createBinariesTestPlugin(t, dir, manifest, tt.binaries...)
+			fimg, err := sif.LoadContainer(sifPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			if !isPluginFile(newSifFileImageReader(&fimg)) {
+				t.Fatalf("plugin image not recognized")
+			}
+
+			descr, err := binaryForHost(&fimg)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				return
+			}
+
+			data := fimg.Filedata[descr.Fileoff : descr.Fileoff+descr.Filelen]
+			if expected := tt.binary.content(); string(data) != expected {
+				t.Errorf("got binary %q, expected %q", data, expected)
+			}
+		})
+	}
+}
+
+func TestBinaries(t *testing.T) {
+	other := otherArch()
+
+	dir, err := ioutil.TempDir("", "plugin-arch-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	binaries := []testBinary{
+		{pluginBinaryName, other},
+		{"helper.so", runtime.GOARCH},
+		{pluginBinaryName, runtime.GOARCH},
+	}
+	sifPath := createBinariesTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/arch"}, binaries...)
+
+	infos, err := Binaries(sifPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []BinaryInfo{
+		{Name: pluginBinaryName, Arch: other, Primary: true},
+		{Name: "helper.so", Arch: runtime.GOARCH},
+		{Name: pluginBinaryName, Arch: runtime.GOARCH, Primary: true, Selected: true},
+	}
+	for i := range expected {
+		expected[i].Size = int64(len(binaries[i].content()))
+	}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("got binaries %+v, expected %+v", infos, expected)
+	}
+}
+
+func TestInstallMissingArchitecture(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-arch-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{
+		Name:          "example.com/arch",
+		Architectures: []string{runtime.GOARCH, otherArch()},
+	}
+	sifPath := createTestPlugin(t, dir, manifest)
+
+	if err := Install(sifPath, ""); err == nil {
+		t.Errorf("unexpected success with a declared architecture missing")
+	}
+	if _, err := os.Stat(metaPath(manifest.Name)); !os.IsNotExist(err) {
+		t.Errorf("plugin with a declared architecture missing was installed")
+	}
+
+	if err := checkArchitectures(manifest, []string{runtime.GOARCH}, false); err != nil {
+		t.Errorf("unexpected error with missing architectures only reported: %s", err)
+	}
+}
+
+func TestHistoricalBinaryArch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-arch-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sharedObject := testELFObject(t, elf.ET_DYN)
+	sifPath := createFixtureImage(t, dir, []fixtureDescriptor{
+		{name: pluginBinaryName, datatype: sif.DataGeneric, data: sharedObject},
+		{name: pluginManifestName, datatype: sif.DataGenericJSON, data: `{"name":"example.com/arch"}`},
+	})
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	// the descriptor records no architecture, the ELF header does
+	binaries := pluginBinaries(&fimg)
+	if len(binaries) != 1 || binaries[0].name != pluginBinaryName || binaries[0].arch != "amd64" {
+		t.Fatalf("got binaries %+v, expected one amd64 %s", binaries, pluginBinaryName)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// nameAssets is the name of the directory holding the
+	// assets extracted from the plugin image.
+	nameAssets = "assets"
+	// assetPrefix is the descriptor name prefix tagging the
+	// plugin assets within the SIF file.
+	assetPrefix = "asset:"
+	// maxAssetsSize is the maximum total size in bytes of
+	// the assets extracted from a plugin image.
+	maxAssetsSize = 256 << 20
+)
+
+// statfs is the function pointing to unix.Statfs and
+// also used by unit tests for mocking.
+var statfs = unix.Statfs
+
+// pluginAsset is an asset of a plugin SIF.
+type pluginAsset struct {
+	descr *sif.Descriptor
+	// path is the path of the asset relative
+	// to the assets directory.
+	path string
+}
+
+// sanitizeAssetPath returns the cleaned path p of an asset relative
+// to the assets directory, or an error if p is absolute, contains
+// '..' or doesn't name a file.
+func sanitizeAssetPath(p string) (string, error) {
+	if p == "" || path.IsAbs(p) {
+		return "", fmt.Errorf("asset path %q must be relative", p)
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("asset path %q must not contain '..'", p)
+		}
+	}
+	clean := path.Clean(p)
+	if clean == "." {
+		return "", fmt.Errorf("asset path %q must name a file", p)
+	}
+	return clean, nil
+}
+
+// pluginAssets returns the assets of fimg: the generic data objects
+// listed in the manifest assets, extracted to their declared path, and
+// those named with the asset: prefix, extracted to their name without
+// the prefix. An error is returned for an asset with an unsafe path,
+// for two assets extracted to the same path or when an asset listed in
+// the manifest is missing.
+func pluginAssets(fimg *sif.FileImage) ([]pluginAsset, error) {
+	var assets []pluginAsset
+
+	manifest := getManifest(newSifFileImageReader(fimg))
+	declared := make(map[string]string, len(manifest.Assets))
+	for _, a := range manifest.Assets {
+		declared[a.Descriptor] = a.Path
+	}
+
+	seen := make(map[string]bool)
+	found := make(map[string]bool)
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used || d.Datatype != sif.DataGeneric {
+			continue
+		}
+		name := d.GetName()
+		p, ok := declared[name]
+		if !ok {
+			if !strings.HasPrefix(name, assetPrefix) {
+				continue
+			}
+			p = strings.TrimPrefix(name, assetPrefix)
+		}
+		p, err := sanitizeAssetPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("asset %s: %s", name, err)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("asset %s: several assets extracted to %s", name, p)
+		}
+		seen[p] = true
+		found[name] = true
+		assets = append(assets, pluginAsset{descr: d, path: p})
+	}
+
+	for _, a := range manifest.Assets {
+		if !found[a.Descriptor] {
+			return nil, fmt.Errorf("asset %s declared in the manifest not found in the plugin image", a.Descriptor)
+		}
+	}
+
+	return assets, nil
+}
+
+// checkAssets returns the violations of the assets field of manifest.
+func checkAssets(manifest pluginapi.Manifest) []string {
+	var violations []string
+
+	for i, a := range manifest.Assets {
+		if a.Descriptor == "" {
+			violations = append(violations, fmt.Sprintf("asset %d: empty descriptor name", i))
+		} else if len(a.Descriptor) >= sif.DescrNameLen {
+			violations = append(violations, fmt.Sprintf("asset %d: descriptor name length exceeds %d bytes", i, sif.DescrNameLen-1))
+		}
+		if _, err := sanitizeAssetPath(a.Path); err != nil {
+			violations = append(violations, fmt.Sprintf("asset %d: %s", i, err))
+		}
+	}
+	return violations
+}
+
+// checkAssetsSpace returns an error if the total size of assets
+// exceeds maxAssetsSize or the space available in dir.
+func checkAssetsSpace(dir string, assets []pluginAsset) error {
+	var size int64
+
+	for _, a := range assets {
+		size += a.descr.Filelen
+	}
+	if size > maxAssetsSize {
+		return fmt.Errorf("plugin assets size of %d bytes exceeds %d bytes", size, maxAssetsSize)
+	}
+
+	stfs := &unix.Statfs_t{}
+	if err := statfs(dir, stfs); err != nil {
+		return fmt.Errorf("while getting free space of %s: %s", dir, err)
+	}
+	if free := int64(stfs.Bavail) * int64(stfs.Bsize); size > free {
+		return fmt.Errorf("plugin assets size of %d bytes exceeds the %d bytes available in %s", size, free, dir)
+	}
+	return nil
+}
+
+// installAssets extracts the assets of the plugin image into the
+// assets directory, replacing the assets of a previous installation.
+func (m *Meta) installAssets() error {
+	dir := m.assetsName()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	m.AssetsPath = ""
+
+	assets, err := pluginAssets(m.sifFile)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+	if err := checkAssetsSpace(m.path(), assets); err != nil {
+		return err
+	}
+
+	for _, a := range assets {
+		dest := filepath.Join(dir, filepath.FromSlash(a.path))
+		sylog.Debugf("Extracting plugin asset %s to %s", a.descr.GetName(), dest)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dest, a.descr.GetData(m.sifFile), 0644); err != nil {
+			return fmt.Errorf("while extracting asset %s: %s", a.descr.GetName(), err)
+		}
+	}
+	m.AssetsPath = dir
+
+	return nil
+}
+
+// assetsPath returns the assets directory of the plugin object
+// located at binary, or an empty string if the plugin has no assets.
+func assetsPath(binary string) string {
+	dir := filepath.Join(filepath.Dir(binary), nameAssets)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/sys/unix"
+)
+
+func TestSanitizeAssetPath(t *testing.T) {
+	tests := []struct {
+		path        string
+		expected    string
+		expectError bool
+	}{
+		{"table.csv", "table.csv", false},
+		{"templates/default.tmpl", "templates/default.tmpl", false},
+		{"./templates//default.tmpl", "templates/default.tmpl", false},
+		{"", "", true},
+		{"/etc/passwd", "", true},
+		{"../table.csv", "", true},
+		{"templates/../../table.csv", "", true},
+		{"templates/..", "", true},
+		{".", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := sanitizeAssetPath(tt.path)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if p != tt.expected {
+				t.Errorf("got path %q, expected %q", p, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInstallAssets(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	binary := fixtureDescriptor{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartData, "object"}
+	manifest := func(assets ...pluginapi.Asset) fixtureDescriptor {
+		data, err := json.Marshal(pluginapi.Manifest{Name: "example.com/assets", Assets: assets})
+		if err != nil {
+			t.Fatalf("while encoding manifest: %s", err)
+		}
+		return fixtureDescriptor{pluginManifestName, sif.DataGenericJSON, 0, 0, string(data)}
+	}
+	asset := func(name, data string) fixtureDescriptor {
+		return fixtureDescriptor{name, sif.DataGeneric, 0, 0, data}
+	}
+
+	tests := []struct {
+		name        string
+		descrs      []fixtureDescriptor
+		free        uint64
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:   "NoAssets",
+			descrs: []fixtureDescriptor{binary, manifest(), asset("notes.txt", "ignored")},
+			free:   1 << 20,
+		},
+		{
+			name: "Assets",
+			descrs: []fixtureDescriptor{
+				binary,
+				manifest(pluginapi.Asset{Descriptor: "default.tmpl", Path: "templates/default.tmpl"}),
+				asset("asset:table.csv", "a,b"),
+				asset("default.tmpl", "{{.}}"),
+			},
+			free: 1 << 20,
+			expected: map[string]string{
+				"table.csv":              "a,b",
+				"templates/default.tmpl": "{{.}}",
+			},
+		},
+		{
+			name:        "Missing",
+			descrs:      []fixtureDescriptor{binary, manifest(pluginapi.Asset{Descriptor: "default.tmpl", Path: "default.tmpl"})},
+			free:        1 << 20,
+			expectError: true,
+		},
+		{
+			name: "Traversal",
+			descrs: []fixtureDescriptor{
+				binary,
+				manifest(pluginapi.Asset{Descriptor: "default.tmpl", Path: "../meta.json"}),
+				asset("default.tmpl", "{{.}}"),
+			},
+			free:        1 << 20,
+			expectError: true,
+		},
+		{
+			name: "Duplicate",
+			descrs: []fixtureDescriptor{
+				binary,
+				manifest(pluginapi.Asset{Descriptor: "default.tmpl", Path: "table.csv"}),
+				asset("asset:table.csv", "a,b"),
+				asset("default.tmpl", "{{.}}"),
+			},
+			free:        1 << 20,
+			expectError: true,
+		},
+		{
+			name:        "NoSpace",
+			descrs:      []fixtureDescriptor{binary, manifest(), asset("asset:table.csv", "a,b")},
+			free:        2,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-assets-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			statfs = func(path string, st *unix.Statfs_t) error {
+				st.Bsize = 1
+				st.Bavail = tt.free
+				return nil
+			}
+			defer func() { statfs = unix.Statfs }()
+
+			fimg, err := sif.LoadContainer(createFixtureImage(t, dir, tt.descrs), true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			m := &Meta{Name: "example.com/assets", sifFile: &fimg}
+			if err := os.MkdirAll(m.path(), 0755); err != nil {
+				t.Fatalf("while creating plugin directory: %s", err)
+			}
+			defer os.RemoveAll(m.path())
+
+			err = m.installAssets()
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				return
+			}
+
+			if len(tt.expected) == 0 {
+				if m.AssetsPath != "" || assetsPath(m.binaryName()) != "" {
+					t.Errorf("unexpected assets directory for a plugin without assets")
+				}
+				return
+			}
+			if m.AssetsPath != m.assetsName() || assetsPath(m.binaryName()) != m.assetsName() {
+				t.Errorf("got assets path %q, expected %q", m.AssetsPath, m.assetsName())
+			}
+			for p, content := range tt.expected {
+				b, err := ioutil.ReadFile(filepath.Join(m.AssetsPath, p))
+				if err != nil {
+					t.Errorf("while reading asset %s: %s", p, err)
+				} else if string(b) != content {
+					t.Errorf("got asset %s content %q, expected %q", p, b, content)
+				}
+			}
+		})
+	}
+}
+
+func TestUninstallAssets(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-assets-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/assets"})
+	installTestPlugin(t, sifPath, "example.com/assets", true)
+
+	m, err := loadMetaByName("example.com/assets")
+	if err != nil {
+		t.Fatalf("while loading plugin meta: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Join(m.assetsName(), "templates"), 0755); err != nil {
+		t.Fatalf("while creating assets directory: %s", err)
+	}
+
+	if err := Uninstall("example.com/assets"); err != nil {
+		t.Fatalf("while uninstalling plugin: %s", err)
+	}
+	if _, err := os.Stat(m.assetsName()); !os.IsNotExist(err) {
+		t.Errorf("assets directory not removed: %v", err)
+	}
+}
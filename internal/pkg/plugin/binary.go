@@ -7,9 +7,12 @@ package plugin
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -17,43 +20,251 @@ import (
 )
 
 // Install installs a plugin from a SIF image under rootDir. It will:
-//     1. Check that the SIF is a valid plugin
+//     1. Check that the SIF is a valid plugin and its dependencies are installed
 //     2. Use name (or retrieve one from Manifest) and calculate the installation path
 //     3. Copy the SIF into the plugin path
 //     4. Extract the binary object into the path
-//     5. Generate a default config file in the path
-//     6. Write the Meta struct onto disk in dirRoot
-func Install(sifPath string, name string) error {
+//     5. Extract the assets into the assets directory of the path
+//     6. Generate a default config file in the path, unless a customized
+//        one is already present from a previous installation, which is
+//        migrated as declared in the manifest
+//     7. Write the Meta struct onto disk in the path
+func Install(sifPath string, name string, ops ...InstallOp) error {
+	var opts installOptions
+	for _, op := range ops {
+		op(&opts)
+	}
+	_, err := installSIF(sifPath, name, opts)
+	return err
+}
+
+// InstallOp is an option of Install.
+type InstallOp func(*installOptions)
+
+// WithCompressedImage stores the installed plugin image compressed
+// with zstd, see Compact.
+func WithCompressedImage() InstallOp {
+	return func(o *installOptions) {
+		o.compressImage = true
+	}
+}
+
+// WithIntegrityCheck checks the internal consistency of the plugin
+// image before installing it, see CheckIntegrity.
+func WithIntegrityCheck() InstallOp {
+	return func(o *installOptions) {
+		o.checkIntegrity = true
+	}
+}
+
+// installOptions are the options of installSIF, the installations
+// replacing the installed plugin without an upgrade also set the
+// options recording the replacement, see Rebuild.
+type installOptions struct {
+	// compressImage stores the plugin image compressed.
+	compressImage bool
+	// checkIntegrity checks the plugin image with CheckIntegrity.
+	checkIntegrity bool
+	// action is the history action recorded instead of HistoryUpgrade.
+	action string
+	// source is the history source recorded instead of the image path.
+	source string
+	// sourceDir is the plugin source directory recorded instead of
+	// the one found in the image.
+	sourceDir string
+	// keepState keeps the enable state of the installed plugin.
+	keepState bool
+	// devDir is the unpacked plugin directory the image was packaged
+	// from by InstallDir.
+	devDir string
+	// restored is the archived meta of a plugin restored by Import,
+	// the plugin is installed over its restored files and keeps its
+	// history and the users recorded on the exporting node.
+	restored *Meta
+}
+
+// installSIF installs the plugin image sifPath as name, see Install,
+// and returns the Meta of the installed plugin.
+func installSIF(sifPath string, name string, opts installOptions) (*Meta, error) {
 	sylog.Debugf("Installing plugin from SIF to %q", rootDir)
 
+	if opts.checkIntegrity {
+		report, err := CheckIntegrity(sifPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not check plugin integrity: %w", err)
+		}
+		if !report.OK() {
+			return nil, &IntegrityError{Report: report}
+		}
+	}
+
 	sifFile, err := sif.LoadContainer(sifPath, true)
 	if err != nil {
-		return fmt.Errorf("could not load plugin: %w", err)
+		return nil, fmt.Errorf("could not load plugin: %w", err)
 	}
 	defer sifFile.UnloadContainer()
 
 	sr := newSifFileImageReader(&sifFile)
-	if !isPluginFile(sr) {
-		return fmt.Errorf("not a valid plugin")
+	if err := checkPluginFile(sr); err != nil {
+		return nil, err
+	}
+	manifest, violations := readManifest(sr, allowUnknownFields())
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("invalid plugin manifest: %w", &ManifestError{Violations: violations})
+	}
+	checkManifestVersion(manifest)
+	if err := checkArchitectures(manifest, binaryArchitectures(&sifFile), true); err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+	if err := readCapabilityPolicy().check(manifest.Name, manifest.Capabilities); err != nil {
+		return nil, fmt.Errorf("could not install plugin: %w", err)
+	}
+
+	// a plugin compiled against an older API is installed
+	// anyway, it fails to load until recompiled
+	if err := checkAPIVersion(manifest.APIVersion); errors.Is(err, errAPITooNew) {
+		return nil, fmt.Errorf("could not install plugin: %w", err)
+	} else if err != nil {
+		sylog.Warningf("Plugin %q won't be loaded: %s", manifest.Name, err)
 	}
-	manifest := getManifest(sr)
 
 	if name == "" {
 		name = manifest.Name
 	}
+	name = normalizeName(name)
+	if name == "" {
+		return nil, fmt.Errorf("invalid plugin name")
+	}
+	// the plugin object and manifest are taken from any group,
+	// the signed group must hold all the data objects
+	if err := readNamespacePolicy().check(sifPath, manifest.Name, name); err != nil {
+		return nil, fmt.Errorf("could not install plugin: %w", err)
+	}
+
+	actor := currentActor()
+	now := time.Now()
 
 	m := &Meta{
-		Name:    name,
-		Enabled: true,
+		Name:           name,
+		Enabled:        true,
+		InstalledBy:    actor,
+		LastModifiedBy: actor,
+		ImageID:        sifFile.Header.ID.String(),
+		Isolated:       manifest.Isolated,
+		Version:        manifest.Version,
+		APIVersion:     manifest.APIVersion,
+		Capabilities:   manifest.Capabilities,
+		EnabledAt:      &now,
+
+		DeclaredCallbacks: manifest.Hooks,
+		Keywords:          manifest.Keywords,
+		ConfigSchema:      manifest.Config,
+		ConfigFormat:      manifest.ConfigFormat,
+
+		License:         manifest.License,
+		Homepage:        manifest.Homepage,
+		Repository:      manifest.Repository,
+		MaintainerEmail: manifest.MaintainerEmail,
+
+		sifFile:         &sifFile,
+		configMigration: manifest.ConfigMigration,
+	}
+	if opts.compressImage {
+		m.ImageCompression = CompressionZstd
+	}
+	if opts.devDir != "" {
+		m.DevDir = opts.devDir
+		sylog.Infof("Plugin %q is a development install from %s, its image is unsigned", name, opts.devDir)
+	}
+	if manifest.Deprecated.IsDeprecated() {
+		m.Deprecated = manifest.Deprecated
+		m.ReplacedBy = manifest.ReplacedBy
+		sylog.Warningf("%s", m.DeprecationNotice())
+	}
 
-		sifFile: &sifFile,
+	// an already installed plugin with the same name is upgraded
+	previous, err := loadMetaByName(name)
+	if errors.Is(err, errMetaChecksum) {
+		// nothing can be trusted from the broken meta,
+		// the plugin is installed from scratch
+		sylog.Warningf("Replacing broken plugin %q: %s", name, err)
+		previous = nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not load installed plugin %q: %w", name, err)
+	}
+	// names only differing by case would be ambiguous for lookups
+	if previous != nil && normalizeName(previous.Name) != name {
+		return nil, fmt.Errorf("plugin name %q collides with the installed plugin %q", name, previous.Name)
+	}
+	if opts.restored != nil {
+		previous = opts.restored
+	}
+
+	if err := checkDependencies(name, manifest); err != nil {
+		return nil, fmt.Errorf("could not install plugin %q: %w", name, err)
+	}
+	if previous != nil {
+		if err := checkDependents(name, manifest.Version); err != nil {
+			return nil, fmt.Errorf("could not upgrade plugin %q: %w", name, err)
+		}
+	}
+
+	entry := HistoryEntry{
+		Time:    now,
+		Action:  HistoryInstall,
+		Source:  sifPath,
+		Version: manifest.Version,
+		Actor:   actor,
+	}
+	if abs, err := filepath.Abs(sifPath); err == nil {
+		entry.Source = abs
+	}
+	if opts.source != "" {
+		entry.Source = opts.source
+	}
+	if digest, err := fileHash(sifPath); err == nil {
+		entry.Digest = digest
+	} else {
+		sylog.Debugf("Could not compute digest of %s: %s", sifPath, err)
+	}
+	if previous != nil {
+		checkDowngrade(previous, manifest.Version)
+		entry.Action = HistoryUpgrade
+		m.History = previous.History
+		m.SourceDir = previous.SourceDir
+		if opts.action != "" {
+			entry.Action = opts.action
+		} else if notes := readChangelog(sr); notes != "" && opts.restored == nil {
+			sylog.Infof("Release notes of plugin %q version %s:\n%s", name, manifest.Version, notes)
+		}
+		if opts.keepState {
+			m.Enabled = previous.Enabled
+			m.EnabledAt = previous.EnabledAt
+			m.DisabledAt = previous.DisabledAt
+		}
+	}
+	if opts.restored != nil {
+		// the restored plugin is not modified
+		m.InstalledBy = previous.InstalledBy
+		m.LastModifiedBy = previous.LastModifiedBy
+	} else {
+		m.History = m.History.add(entry, historySize())
 	}
 
-	err = m.install()
+	if dir := readSourceDir(sr); dir != "" {
+		m.SourceDir = dir
+	}
+	if opts.sourceDir != "" {
+		m.SourceDir = opts.sourceDir
+	}
+
+	err = m.install(previous)
 	if err != nil {
-		return fmt.Errorf("could not install plugin: %w", err)
+		return nil, fmt.Errorf("could not install plugin: %w", err)
 	}
-	return nil
+	// the image is unloaded on return
+	m.sifFile = nil
+	return m, nil
 }
 
 // Uninstall removes the plugin matching "name" from the singularity
@@ -68,19 +279,74 @@ func Uninstall(name string) error {
 
 	sylog.Debugf("Found plugin %q, meta=%#v", name, meta)
 
+	required, _, err := dependents(meta.Name)
+	if err != nil {
+		return err
+	}
+	if len(required) > 0 {
+		return fmt.Errorf("plugin %q is required by %s, uninstall them first", meta.Name, strings.Join(required, ", "))
+	}
+	sylog.Debugf("Plugin %q uninstalled by %s", name, currentActor())
+
 	return meta.uninstall()
 }
 
+// Lookup returns the Meta information of the plugin "name"
+// installed under rootDir.
+func Lookup(name string) (*Meta, error) {
+	return loadMetaByName(name)
+}
+
 // List returns all the singularity plugins installed in
 // rootDir in the form of a list of Meta information.
 func List() ([]*Meta, error) {
-	pattern := filepath.Join(rootDir, "*.meta")
-	entries, err := filepath.Glob(pattern)
+	migrateLayout()
+
+	var metas []*Meta
+	seen := make(map[string]bool)
+
+	err := filepath.Walk(rootDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if path == rootDir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			sylog.Debugf("Error stating %s: %s. Skip\n", path, err)
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() || fi.Name() != nameMeta {
+			return nil
+		}
+
+		meta, err := loadMetaByFilename(path)
+		if errors.Is(err, errMetaChecksum) {
+			sylog.Warningf("Skipping broken plugin: %s, reinstall it", err)
+			return nil
+		} else if err != nil {
+			sylog.Debugf("Error loading %s: %s. Skip", path, err)
+			return nil
+		}
+		// plugins may store a file with the same name
+		// in their directory, ignore it
+		if metaPath(meta.Name) != path {
+			return nil
+		}
+
+		seen[meta.Name] = true
+		metas = append(metas, meta)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list plugins in directory %q", rootDir)
+	}
+
+	// plugins not migrated to the per-plugin directory layout
+	entries, err := filepath.Glob(filepath.Join(rootDir, "*"+legacyMetaExt))
 	if err != nil {
 		return nil, fmt.Errorf("cannot list plugins in directory %q", rootDir)
 	}
 
-	var metas []*Meta
 	for _, entry := range entries {
 		fi, err := os.Stat(entry)
 		if err != nil {
@@ -98,14 +364,21 @@ func List() ([]*Meta, error) {
 			continue
 		}
 
+		if seen[meta.Name] {
+			continue
+		}
+
 		metas = append(metas, meta)
 	}
 
 	return metas, nil
 }
 
-// Enable enables the plugin named "name" found under rootDir.
-func Enable(name string) error {
+// Enable enables the plugin named "name" found under rootDir. The
+// plugin configuration is validated first, see ValidateConfig, an
+// invalid configuration prevents the plugin to be enabled unless force
+// is set.
+func Enable(name string, force bool) error {
 	sylog.Debugf("Enabling plugin %q in %q", name, rootDir)
 
 	meta, err := loadMetaByName(name)
@@ -120,10 +393,19 @@ func Enable(name string) error {
 		return nil
 	}
 
+	if err := meta.checkConfig(); err != nil {
+		if !force {
+			return fmt.Errorf("%w, fix it or force the plugin to be enabled", err)
+		}
+		sylog.Warningf("Enabling plugin %q despite its %s", name, err)
+	}
+
 	return meta.enable()
 }
 
-// Disable disables the plugin named "name" found under rootDir.
+// Disable disables the plugin named "name" found under rootDir, its
+// configuration isn't checked so a misconfigured plugin can always be
+// disabled.
 func Disable(name string) error {
 	sylog.Debugf("Disabling plugin %q in %q", name, rootDir)
 
@@ -145,34 +427,19 @@ func Disable(name string) error {
 // Inspect obtains information about the plugin "name".
 //
 // "name" can be either the name of plugin installed under rootDir
-// or the name of an image file corresponding to a plugin.
+// or the name of an image file corresponding to a plugin, see
+// LookupImage.
 func Inspect(name string) (pluginapi.Manifest, error) {
 	var manifest pluginapi.Manifest
 
-	// LoadContainer returns a decorated error, no it's not possible
-	// to ask whether the error happens because the file does not
-	// exist or something else. Check for the file _before_ trying
-	// to load it as a container.
-	if _, err := os.Stat(name); err != nil {
-		if os.IsNotExist(err) {
-			// no file, try to find the installed plugin
-			meta, err := loadMetaByName(name)
-			if err != nil {
-				// Metafile not found, or we cannot read
-				// it. There's nothing we can do.
-				return manifest, err
-			}
-
-			// Replace the original name, which seems to be
-			// the name of a plugin, by the path to the
-			// installed SIF file for that plugin.
-			name = meta.imageName()
-		} else {
-			// There seems to be a file here, but we cannot
-			// read it.
-			return manifest, err
-		}
+	// the name of an installed plugin is replaced
+	// by the path to its installed SIF file
+	path, release, err := imagePath(name)
+	if err != nil {
+		return manifest, err
 	}
+	defer release()
+	name = path
 
 	// at this point, either the file is there under the original
 	// name or we found one by looking at the metafile.
@@ -180,13 +447,64 @@ func Inspect(name string) (pluginapi.Manifest, error) {
 	if err != nil {
 		return manifest, err
 	}
+	defer fimg.UnloadContainer()
+
+	r := newSifFileImageReader(&fimg)
+	if err := checkPluginFile(r); err != nil {
+		return manifest, err
+	}
+
+	// the manifest is validated like at installation
+	// but violations are only reported
+	manifest, violations := readManifest(r, allowUnknownFields())
+	for _, v := range violations {
+		sylog.Warningf("Invalid manifest in plugin image %s: %s", name, v)
+	}
+	checkManifestVersion(manifest)
+	checkArchitectures(manifest, binaryArchitectures(&fimg), false)
+
+	return manifest, nil
+}
+
+// ManifestOf returns the manifest of the installed plugin "name" as
+// read from its image currently on disk. Unlike the information
+// recorded in the plugin meta file at installation time, it reflects
+// any replacement of the image done since.
+func ManifestOf(name string) (pluginapi.Manifest, error) {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return pluginapi.Manifest{}, err
+	}
+
+	return meta.manifest()
+}
+
+// manifest returns the manifest read from the installed image of m.
+func (m *Meta) manifest() (pluginapi.Manifest, error) {
+	path, release, err := m.openImage()
+	if err != nil {
+		return pluginapi.Manifest{}, err
+	}
+	defer release()
+
+	return manifestFromImage(path)
+}
+
+// manifestFromImage returns the manifest of the plugin image path.
+func manifestFromImage(path string) (pluginapi.Manifest, error) {
+	var manifest pluginapi.Manifest
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return manifest, err
+	}
 
 	defer fimg.UnloadContainer()
 
 	r := newSifFileImageReader(&fimg)
 
-	if !isPluginFile(r) {
-		return manifest, fmt.Errorf("not a valid plugin")
+	if err := checkPluginFile(r); err != nil {
+		return manifest, err
 	}
 
 	manifest = getManifest(r)
@@ -204,6 +522,21 @@ func pathFromName(name string) string {
 	return filepath.FromSlash(name)
 }
 
+// normalizeName returns the canonical form of the plugin name, used
+// to install and look up plugins: surrounding whitespaces and leading
+// or trailing slashes are removed and consecutive slashes are collapsed.
+// The case is preserved, lookups are case-insensitive when there is no
+// exact match.
+func normalizeName(name string) string {
+	var elems []string
+	for _, elem := range strings.Split(strings.TrimSpace(name), "/") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return strings.Join(elems, "/")
+}
+
 // pluginIDFromName returns a unique ID for the plugin given its name.
 func pluginIDFromName(name string) string {
 	sum := sha256.Sum256([]byte(name))
@@ -0,0 +1,158 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestManifestOf(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-manifest-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test", Version: "1.0.0"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	manifest, err := ManifestOf("example.org/test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if manifest.Version != "1.0.0" {
+		t.Errorf("got version %q, expected 1.0.0", manifest.Version)
+	}
+
+	// replace the installed image out-of-band
+	m, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if err := os.Remove(sifPath); err != nil {
+		t.Fatalf("while removing plugin image: %s", err)
+	}
+	sifPath = createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test", Version: "2.0.0"})
+	if err := os.Rename(sifPath, m.imageName()); err != nil {
+		t.Fatalf("while replacing plugin image: %s", err)
+	}
+
+	manifest, err = ManifestOf("example.org/test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if manifest.Version != "2.0.0" {
+		t.Errorf("got version %q, expected 2.0.0", manifest.Version)
+	}
+
+	if _, err := ManifestOf("example.org/unknown"); !os.IsNotExist(err) {
+		t.Errorf("unexpected error for an unknown plugin: %v", err)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"example.com/foo", "example.com/foo"},
+		{"  example.com/foo\t\n", "example.com/foo"},
+		{"example.com//foo", "example.com/foo"},
+		{"example.com///foo//bar", "example.com/foo/bar"},
+		{"example.com/foo/", "example.com/foo"},
+		{"/example.com/foo", "example.com/foo"},
+		{"Example.com/Foo", "Example.com/Foo"},
+		{"example.com/foo bar", "example.com/foo bar"},
+		{"", ""},
+		{"  ", ""},
+		{"//", ""},
+	}
+
+	for _, tc := range cases {
+		if n := normalizeName(tc.name); n != tc.expected {
+			t.Errorf("normalizeName(%q) = %q, expected %q", tc.name, n, tc.expected)
+		}
+	}
+}
+
+func TestLoadMetaByNameNormalized(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	for _, name := range []string{"example.com/foo", "example.com/Bar", "example.com/legacy/"} {
+		m := &Meta{Name: name}
+		if err := os.MkdirAll(m.path(), 0755); err != nil {
+			t.Fatalf("while creating plugin directory: %s", err)
+		}
+		if err := m.installMeta(); err != nil {
+			t.Fatalf("while installing meta: %s", err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{"example.com/foo", "example.com/foo"},
+		{" example.com/foo ", "example.com/foo"},
+		{"example.com//foo/", "example.com/foo"},
+		{"Example.com/Foo", "example.com/foo"},
+		{"example.com/Bar", "example.com/Bar"},
+		{"example.com/bar", "example.com/Bar"},
+		{"example.com/legacy", "example.com/legacy/"},
+		{"example.com/unknown", ""},
+		{"", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := loadMetaByName(tc.name)
+			if tc.expected == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("unexpected result: %v, %v", m, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if m.Name != tc.expected {
+				t.Errorf("got plugin %q, expected %q", m.Name, tc.expected)
+			}
+		})
+	}
+}
+
+func TestInstallNameCollision(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-collision-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/foo"})
+	installTestPlugin(t, sifPath, "example.com/foo", true)
+
+	err = Install(sifPath, "Example.com/Foo")
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Errorf("unexpected error for a colliding name: %v", err)
+	}
+	if _, err := os.Stat(metaPath("Example.com/Foo")); !os.IsNotExist(err) {
+		t.Errorf("colliding plugin was installed")
+	}
+
+	if err := Install(sifPath, " / "); err == nil {
+		t.Errorf("unexpected success with an empty name")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// CallbackCheckWarn is the callback check mode reporting the
+	// differences between the declared and registered callbacks
+	// with a warning.
+	CallbackCheckWarn = "warn"
+	// CallbackCheckStrict is the callback check mode refusing the
+	// plugins registering other callbacks than the declared ones.
+	CallbackCheckStrict = "strict"
+)
+
+// CallbackCheck is the result of the comparison of the callbacks
+// declared in a plugin manifest with the callbacks actually
+// registered by the plugin object.
+type CallbackCheck struct {
+	// Time is the time at which the check was done.
+	Time time.Time `json:"Time"`
+	// Digest is the sha256 of the plugin object checked.
+	Digest string `json:"Digest,omitempty"`
+	// Declared lists the callbacks declared in the manifest.
+	Declared []string `json:"Declared,omitempty"`
+	// Registered lists the callbacks registered by the object.
+	Registered []string `json:"Registered,omitempty"`
+}
+
+// Undeclared returns the callbacks registered but not declared.
+func (c *CallbackCheck) Undeclared() []string {
+	return missingStrings(c.Registered, c.Declared)
+}
+
+// Unregistered returns the callbacks declared but not registered.
+func (c *CallbackCheck) Unregistered() []string {
+	return missingStrings(c.Declared, c.Registered)
+}
+
+// Passed returns whether the registered callbacks are
+// exactly the declared ones.
+func (c *CallbackCheck) Passed() bool {
+	return len(c.Undeclared()) == 0 && len(c.Unregistered()) == 0
+}
+
+func (c *CallbackCheck) String() string {
+	if len(c.Declared) == 0 {
+		return "no callbacks declared"
+	}
+	if c.Passed() {
+		return "passed"
+	}
+
+	var s []string
+	if undeclared := c.Undeclared(); len(undeclared) > 0 {
+		s = append(s, "undeclared "+strings.Join(undeclared, ", "))
+	}
+	if unregistered := c.Unregistered(); len(unregistered) > 0 {
+		s = append(s, "unregistered "+strings.Join(unregistered, ", "))
+	}
+	return "failed: " + strings.Join(s, "; ")
+}
+
+// missingStrings returns the sorted elements of list which are not in ref.
+func missingStrings(list, ref []string) []string {
+	var missing []string
+	for _, s := range list {
+		if !containsString(ref, s) && !containsString(missing, s) {
+			missing = append(missing, s)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// callbackCheckMode returns the plugin callback check mode
+// set in singularity.conf.
+func callbackCheckMode() string {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, using %s plugin callback check: %s", buildcfg.SINGULARITY_CONF_FILE, CallbackCheckWarn, err)
+		return CallbackCheckWarn
+	}
+	return c.PluginCallbackCheck
+}
+
+// recordCallbackCheck compares the callbacks registered by the object
+// of m with the ones declared in its manifest and records the result
+// in m.
+func (m *Meta) recordCallbackCheck(registered []string) *CallbackCheck {
+	check := &CallbackCheck{
+		Time:       time.Now(),
+		Declared:   m.DeclaredCallbacks,
+		Registered: registered,
+	}
+	if m.BinaryDigest != "" {
+		check.Digest = m.BinaryDigest
+	} else if digest, err := fileHash(m.binaryName()); err == nil {
+		check.Digest = digest
+	} else {
+		sylog.Debugf("Could not compute digest of %s: %s", m.binaryName(), err)
+	}
+	m.CallbackCheck = check
+	return check
+}
+
+// enforceCallbackCheck returns an error if the check of the plugin
+// name failed in strict mode, otherwise the differences are reported
+// with a warning. Plugins declaring no callbacks are only refused in
+// strict mode.
+func enforceCallbackCheck(name string, check *CallbackCheck, mode string) error {
+	if len(check.Declared) == 0 {
+		if mode == CallbackCheckStrict {
+			return fmt.Errorf("plugin %q declares no callbacks in its manifest", name)
+		}
+		sylog.Debugf("Plugin %q declares no callbacks, registered callbacks not checked", name)
+		return nil
+	}
+
+	if check.Passed() {
+		return nil
+	}
+	if mode == CallbackCheckStrict {
+		return fmt.Errorf("plugin %q callbacks check %s", name, check)
+	}
+	sylog.Warningf("Plugin %q callbacks check %s", name, check)
+	return nil
+}
+
+// checkedCallbacks returns an error if the callbacks of m didn't pass
+// the last check in strict mode. The result recorded at installation
+// or by Check is trusted, the object is not examined again.
+func (m *Meta) checkedCallbacks(mode string) error {
+	if mode != CallbackCheckStrict {
+		return nil
+	}
+	if m.CallbackCheck == nil {
+		return fmt.Errorf("callbacks never checked, run 'singularity plugin check %s'", m.Name)
+	}
+	if len(m.CallbackCheck.Declared) == 0 {
+		return fmt.Errorf("no callbacks declared in the plugin manifest")
+	}
+	if !m.CallbackCheck.Passed() {
+		return fmt.Errorf("callbacks check %s", m.CallbackCheck)
+	}
+	return nil
+}
+
+// Check loads the object of the installed plugin "name" in an isolated
+// process, so that no plugin code runs in the singularity process, and
+// compares the callbacks it registers with the ones declared in its
+// manifest. The result is recorded in the plugin meta and returned.
+func Check(name string) (*CallbackCheck, error) {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := startHost(meta)
+	if err != nil {
+		return nil, err
+	}
+	defer h.close()
+
+	registered, err := h.callbacks()
+	if err != nil {
+		return nil, fmt.Errorf("while getting plugin callbacks: %s", err)
+	}
+
+	check := meta.recordCallbackCheck(registered)
+	if err := meta.installMeta(); err != nil {
+		return nil, err
+	}
+
+	return check, nil
+}
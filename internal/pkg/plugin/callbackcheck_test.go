@@ -0,0 +1,122 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallbackCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		check        CallbackCheck
+		undeclared   []string
+		unregistered []string
+		result       string
+	}{
+		{
+			name:   "NoDeclaration",
+			check:  CallbackCheck{Registered: []string{"cli.Command"}},
+			result: "no callbacks declared",
+			// everything registered is undeclared
+			undeclared: []string{"cli.Command"},
+		},
+		{
+			name: "Passed",
+			check: CallbackCheck{
+				Declared:   []string{"singularity.PostStartProcess", "cli.Command"},
+				Registered: []string{"cli.Command", "singularity.PostStartProcess"},
+			},
+			result: "passed",
+		},
+		{
+			name: "Failed",
+			check: CallbackCheck{
+				Declared:   []string{"cli.Command", "fakeroot.UserMapping"},
+				Registered: []string{"singularity.ContainerStartup", "cli.Command", "cli.SingularityEngineConfig"},
+			},
+			undeclared:   []string{"cli.SingularityEngineConfig", "singularity.ContainerStartup"},
+			unregistered: []string{"fakeroot.UserMapping"},
+			result:       "failed: undeclared cli.SingularityEngineConfig, singularity.ContainerStartup; unregistered fakeroot.UserMapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if u := tt.check.Undeclared(); !reflect.DeepEqual(u, tt.undeclared) {
+				t.Errorf("got undeclared %v, expected %v", u, tt.undeclared)
+			}
+			if u := tt.check.Unregistered(); !reflect.DeepEqual(u, tt.unregistered) {
+				t.Errorf("got unregistered %v, expected %v", u, tt.unregistered)
+			}
+			if s := tt.check.String(); s != tt.result {
+				t.Errorf("got result %q, expected %q", s, tt.result)
+			}
+		})
+	}
+}
+
+func TestEnforceCallbackCheck(t *testing.T) {
+	undeclared := &CallbackCheck{Registered: []string{"cli.Command"}}
+	passed := &CallbackCheck{Declared: []string{"cli.Command"}, Registered: []string{"cli.Command"}}
+	failed := &CallbackCheck{Declared: []string{"cli.Command"}, Registered: []string{"fakeroot.UserMapping"}}
+
+	tests := []struct {
+		name        string
+		check       *CallbackCheck
+		mode        string
+		expectError bool
+	}{
+		{"UndeclaredWarn", undeclared, CallbackCheckWarn, false},
+		{"UndeclaredStrict", undeclared, CallbackCheckStrict, true},
+		{"PassedStrict", passed, CallbackCheckStrict, false},
+		{"FailedWarn", failed, CallbackCheckWarn, false},
+		{"FailedStrict", failed, CallbackCheckStrict, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := enforceCallbackCheck("example.com/foo", tt.check, tt.mode)
+			if tt.expectError && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckedCallbacks(t *testing.T) {
+	passed := &CallbackCheck{Declared: []string{"cli.Command"}, Registered: []string{"cli.Command"}}
+	failed := &CallbackCheck{Declared: []string{"cli.Command"}}
+
+	tests := []struct {
+		name        string
+		check       *CallbackCheck
+		mode        string
+		expectError bool
+	}{
+		{"NotCheckedWarn", nil, CallbackCheckWarn, false},
+		{"NotCheckedStrict", nil, CallbackCheckStrict, true},
+		{"UndeclaredStrict", &CallbackCheck{}, CallbackCheckStrict, true},
+		{"PassedStrict", passed, CallbackCheckStrict, false},
+		{"FailedWarn", failed, CallbackCheckWarn, false},
+		{"FailedStrict", failed, CallbackCheckStrict, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Meta{Name: "example.com/foo", CallbackCheck: tt.check}
+			err := m.checkedCallbacks(tt.mode)
+			if tt.expectError && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/plugin/callback"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	clicallback "github.com/sylabs/singularity/pkg/plugin/callback/cli"
+	singularitycallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/engine/singularity"
+	fakerootcallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/fakeroot"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// undeclaredAllow is the "plugin undeclared capabilities"
+	// directive value loading plugins without restriction.
+	undeclaredAllow = "allow"
+	// undeclaredWarn is the "plugin undeclared capabilities"
+	// directive value loading plugins with a warning.
+	undeclaredWarn = "warn"
+	// undeclaredDeny is the "plugin undeclared capabilities"
+	// directive value refusing plugins.
+	undeclaredDeny = "deny"
+)
+
+// callbackCapabilities maps the callback names to the
+// capability a plugin must declare to register them.
+var callbackCapabilities = map[string]pluginapi.Capability{
+	callback.Name((clicallback.Command)(nil)):                  pluginapi.CapabilityCLICommands,
+	callback.Name((clicallback.SingularityEngineConfig)(nil)):  pluginapi.CapabilityRuntimeConfig,
+	callback.Name((fakerootcallback.UserMapping)(nil)):         pluginapi.CapabilityUserMapping,
+	callback.Name((singularitycallback.MonitorContainer)(nil)): pluginapi.CapabilityContainerMonitor,
+	callback.Name((singularitycallback.PostStartProcess)(nil)): pluginapi.CapabilityContainerMonitor,
+	callback.Name((singularitycallback.ContainerStartup)(nil)): pluginapi.CapabilityExecBinaries,
+}
+
+// capabilityPolicy is the plugin capability policy set in singularity.conf.
+type capabilityPolicy struct {
+	// allowed lists the capabilities plugins may declare,
+	// all capabilities are allowed when nil.
+	allowed []pluginapi.Capability
+	// undeclared is the treatment of the plugins declaring
+	// no capabilities.
+	undeclared string
+}
+
+// readCapabilityPolicy returns the plugin capability policy set
+// in singularity.conf.
+func readCapabilityPolicy() capabilityPolicy {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, allowing all plugin capabilities: %s", buildcfg.SINGULARITY_CONF_FILE, err)
+		return capabilityPolicy{undeclared: undeclaredAllow}
+	}
+	return newCapabilityPolicy(c)
+}
+
+// newCapabilityPolicy returns the plugin capability policy of c,
+// unknown capabilities are ignored with a warning.
+func newCapabilityPolicy(c *singularityconf.File) capabilityPolicy {
+	p := capabilityPolicy{undeclared: c.PluginUndeclaredCaps}
+	if len(c.PluginCapabilities) == 0 {
+		return p
+	}
+
+	p.allowed = make([]pluginapi.Capability, 0, len(c.PluginCapabilities))
+	for _, s := range c.PluginCapabilities {
+		capability := pluginapi.Capability(strings.TrimSpace(s))
+		if !capability.Valid() {
+			sylog.Warningf("Ignoring unknown plugin capability %q in %s", capability, buildcfg.SINGULARITY_CONF_FILE)
+			continue
+		}
+		p.allowed = append(p.allowed, capability)
+	}
+	return p
+}
+
+// check returns an error if the plugin name declaring capabilities
+// is refused by the policy, a warning is displayed for plugins
+// declaring no capabilities when the policy requests it.
+func (p capabilityPolicy) check(name string, capabilities []pluginapi.Capability) error {
+	if len(capabilities) == 0 {
+		switch p.undeclared {
+		case undeclaredDeny:
+			return fmt.Errorf("plugin %q declares no capabilities", name)
+		case undeclaredWarn:
+			sylog.Warningf("Plugin %q declares no capabilities, its callbacks are not restricted", name)
+		}
+		return nil
+	}
+
+	if p.allowed == nil {
+		return nil
+	}
+
+	var refused []string
+	for _, c := range capabilities {
+		if !hasCapability(p.allowed, c) {
+			refused = append(refused, string(c))
+		}
+	}
+	if len(refused) > 0 {
+		return fmt.Errorf("plugin %q declares capabilities not allowed by %s: %s", name, buildcfg.SINGULARITY_CONF_FILE, strings.Join(refused, ", "))
+	}
+	return nil
+}
+
+// hasCapability returns whether c is in the list.
+func hasCapability(list []pluginapi.Capability, c pluginapi.Capability) bool {
+	for _, l := range list {
+		if l == c {
+			return true
+		}
+	}
+	return false
+}
+
+// callbackAllowed returns an error if the capabilities don't cover
+// the callback name, callbacks of plugins declaring no capabilities
+// are not restricted.
+func callbackAllowed(capabilities []pluginapi.Capability, name string) error {
+	if len(capabilities) == 0 {
+		return nil
+	}
+	required, ok := callbackCapabilities[name]
+	if !ok {
+		return fmt.Errorf("callback %s is not covered by any capability", name)
+	}
+	if !hasCapability(capabilities, required) {
+		return fmt.Errorf("callback %s requires the undeclared capability %s", name, required)
+	}
+	return nil
+}
+
+// checkCallbacks returns an error listing the callbacks
+// which are not covered by the capabilities.
+func checkCallbacks(capabilities []pluginapi.Capability, callbacks []string) error {
+	var violations []string
+	for _, name := range callbacks {
+		if err := callbackAllowed(capabilities, name); err != nil {
+			violations = append(violations, err.Error())
+		}
+	}
+	if len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+func TestNewCapabilityPolicy(t *testing.T) {
+	c := &singularityconf.File{
+		PluginCapabilities:   []string{"cli-commands", " exec-binaries", "root"},
+		PluginUndeclaredCaps: undeclaredWarn,
+	}
+
+	p := newCapabilityPolicy(c)
+	expected := capabilityPolicy{
+		allowed:    []pluginapi.Capability{pluginapi.CapabilityCLICommands, pluginapi.CapabilityExecBinaries},
+		undeclared: undeclaredWarn,
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("got policy %+v, expected %+v", p, expected)
+	}
+
+	p = newCapabilityPolicy(&singularityconf.File{PluginUndeclaredCaps: undeclaredAllow})
+	if p.allowed != nil {
+		t.Errorf("unexpected restriction %v when no capability is configured", p.allowed)
+	}
+}
+
+func TestCapabilityPolicyCheck(t *testing.T) {
+	cli := []pluginapi.Capability{pluginapi.CapabilityCLICommands}
+	cliExec := []pluginapi.Capability{pluginapi.CapabilityCLICommands, pluginapi.CapabilityExecBinaries}
+
+	tests := []struct {
+		name         string
+		policy       capabilityPolicy
+		capabilities []pluginapi.Capability
+		expectError  bool
+	}{
+		{
+			name:         "AllAllowed",
+			policy:       capabilityPolicy{undeclared: undeclaredAllow},
+			capabilities: cliExec,
+		},
+		{
+			name:         "Allowed",
+			policy:       capabilityPolicy{allowed: cliExec, undeclared: undeclaredAllow},
+			capabilities: cli,
+		},
+		{
+			name:         "NotAllowed",
+			policy:       capabilityPolicy{allowed: cli, undeclared: undeclaredAllow},
+			capabilities: cliExec,
+			expectError:  true,
+		},
+		{
+			name:   "UndeclaredAllowed",
+			policy: capabilityPolicy{allowed: cli, undeclared: undeclaredAllow},
+		},
+		{
+			name:   "UndeclaredWarned",
+			policy: capabilityPolicy{allowed: cli, undeclared: undeclaredWarn},
+		},
+		{
+			name:        "UndeclaredDenied",
+			policy:      capabilityPolicy{undeclared: undeclaredDeny},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.check("example.com/foo", tt.capabilities)
+			if tt.expectError && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckCallbacks(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities []pluginapi.Capability
+		callbacks    []string
+		expectError  bool
+	}{
+		{
+			name:      "Undeclared",
+			callbacks: []string{"cli.Command", "singularity.ContainerStartup"},
+		},
+		{
+			name:         "Covered",
+			capabilities: []pluginapi.Capability{pluginapi.CapabilityContainerMonitor},
+			callbacks:    []string{"singularity.MonitorContainer", "singularity.PostStartProcess"},
+		},
+		{
+			name:         "NotCovered",
+			capabilities: []pluginapi.Capability{pluginapi.CapabilityCLICommands},
+			callbacks:    []string{"cli.Command", "cli.SingularityEngineConfig"},
+			expectError:  true,
+		},
+		{
+			name:         "UnknownCallback",
+			capabilities: pluginapi.Capabilities,
+			callbacks:    []string{"main.Unknown"},
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCallbacks(tt.capabilities, tt.callbacks)
+			if tt.expectError && err == nil {
+				t.Errorf("unexpected success")
+			} else if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
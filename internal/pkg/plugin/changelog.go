@@ -0,0 +1,137 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+// maxChangelogSize is the maximum size of the release notes
+// displayed, longer release notes are truncated.
+const maxChangelogSize = 64 * 1024
+
+// readChangelog returns the release notes of the plugin image fimg
+// ready to be displayed, or an empty string if the image has none.
+func readChangelog(fimg sifReader) string {
+	n := findDescriptor(fimg, pluginChangelogName)
+	if n < 0 || fimg.GetDatatype(n) != sif.DataGeneric {
+		return ""
+	}
+	return formatChangelog(fimg.GetData(n))
+}
+
+// formatChangelog returns the release notes data as plain text: the
+// content is truncated beyond maxChangelogSize with a notice, invalid
+// UTF-8 sequences are replaced and control characters, other than
+// newlines and tabs, are removed so that the notes can't drive the
+// terminal they are displayed on.
+func formatChangelog(data []byte) string {
+	var omitted int
+	if len(data) > maxChangelogSize {
+		// don't cut a multi-byte character
+		n := maxChangelogSize
+		for n > 0 && !utf8.RuneStart(data[n]) {
+			n--
+		}
+		omitted = len(data) - n
+		data = data[:n]
+	}
+
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	notes := strings.TrimRight(b.String(), "\n")
+	if omitted > 0 {
+		notes += fmt.Sprintf("\n[... release notes truncated, %d bytes omitted]", omitted)
+	}
+	return notes
+}
+
+// Changelog returns the release notes shipped in the plugin image,
+// they are empty when the image has none.
+//
+// "name" can be either the name of plugin installed under rootDir
+// or the name of an image file corresponding to a plugin, see
+// LookupImage.
+func Changelog(name string) (string, error) {
+	path, release, err := imagePath(name)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return "", err
+	}
+	defer fimg.UnloadContainer()
+
+	r := newSifFileImageReader(&fimg)
+	if err := checkPluginFile(r); err != nil {
+		return "", err
+	}
+
+	return readChangelog(r), nil
+}
+
+// imagePath returns the path of the plugin image "name", which is
+// either the name of plugin installed under rootDir or the name of
+// an image file corresponding to a plugin, along with a function
+// releasing it (see openImage).
+func imagePath(name string) (string, func(), error) {
+	meta, err := LookupImage(name)
+	if err != nil {
+		return "", nil, err
+	} else if meta == nil {
+		return name, func() {}, nil
+	}
+	return meta.openImage()
+}
+
+// LookupImage returns the Meta of the plugin installed under rootDir
+// designated by "name", or nil when "name" designates an image file.
+// An absolute or relative path starting with "./" or "../", or ending
+// with ".sif", designates an image file, otherwise the installed
+// plugins are looked up first and "name" designates an image file
+// only when no plugin is installed under that name.
+func LookupImage(name string) (*Meta, error) {
+	if isImageFilePath(name) {
+		_, err := os.Stat(name)
+		return nil, err
+	}
+
+	meta, err := loadMetaByName(name)
+	if os.IsNotExist(err) {
+		if _, serr := os.Stat(name); serr == nil {
+			return nil, nil
+		}
+	}
+	return meta, err
+}
+
+// isImageFilePath returns true if name is an explicit path to an
+// image file.
+func isImageFilePath(name string) bool {
+	return filepath.IsAbs(name) ||
+		strings.HasPrefix(name, "./") ||
+		strings.HasPrefix(name, "../") ||
+		strings.HasSuffix(name, ".sif")
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestFormatChangelog(t *testing.T) {
+	long := strings.Repeat("a", maxChangelogSize-1) + "é" + "tail"
+
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"Empty", "", ""},
+		{"PlainText", "v1.1.0\n\t- fix crash\n\n", "v1.1.0\n\t- fix crash"},
+		{"CRLF", "v1.1.0\r\n- fix crash\r\n", "v1.1.0\n- fix crash"},
+		{"EscapeSequences", "\x1b[2Jv1.1.0\x1b]0;title\x07", "[2Jv1.1.0]0;title"},
+		{"InvalidUTF8", "v1.1.0 \xff", "v1.1.0 �"},
+		{"Truncated", long, strings.Repeat("a", maxChangelogSize-1) + "\n[... release notes truncated, 6 bytes omitted]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if notes := formatChangelog([]byte(tt.data)); notes != tt.expected {
+				t.Errorf("got notes %q, expected %q", notes, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadChangelog(t *testing.T) {
+	tests := []struct {
+		name     string
+		fimg     testSifReader
+		expected string
+	}{
+		{
+			name: "NoChangelog",
+			fimg: testSifReader{
+				{name: pluginManifestName, used: true, datatype: sif.DataGenericJSON},
+			},
+		},
+		{
+			name: "Changelog",
+			fimg: testSifReader{
+				{name: pluginManifestName, used: true, datatype: sif.DataGenericJSON},
+				{name: pluginChangelogName, used: true, datatype: sif.DataGeneric, data: []byte("v1.1.0\n")},
+			},
+			expected: "v1.1.0",
+		},
+		{
+			name: "WrongDatatype",
+			fimg: testSifReader{
+				{name: pluginChangelogName, used: true, datatype: sif.DataPartition, data: []byte("v1.1.0\n")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if notes := readChangelog(tt.fimg); notes != tt.expected {
+				t.Errorf("got notes %q, expected %q", notes, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookupImage(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-lookup-image-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/lookup"})
+	installTestPlugin(t, sifPath, "example.org/lookup", true)
+
+	// files named like the installed plugin and like
+	// a plugin which is not installed
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("while getting working directory: %s", err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("while changing directory: %s", err)
+	}
+	for _, name := range []string{"example.org/lookup", "example.org/other"} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("while creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("while creating file: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		installed bool
+		wantErr   bool
+	}{
+		{name: "example.org/lookup", installed: true},
+		{name: "EXAMPLE.org/lookup", installed: true},
+		{name: "./example.org/lookup"},
+		{name: filepath.Join(dir, "example.org/lookup")},
+		{name: sifPath},
+		{name: "example.org/other"},
+		{name: "example.org/unknown", wantErr: true},
+		{name: "./example.org/unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := LookupImage(tt.name)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.wantErr {
+				t.Fatalf("unexpected success")
+			}
+			if installed := meta != nil; installed != tt.installed {
+				t.Errorf("got installed %v, expected %v", installed, tt.installed)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// checksumField is the JSON key of the meta checksum.
+const checksumField = "Checksum"
+
+// errMetaChecksum is returned when the content of a meta file
+// doesn't match its checksum.
+var errMetaChecksum = errors.New("checksum mismatch")
+
+// metaChecksum returns the checksum of the encoded meta data. It's
+// computed over the canonical form of all the fields, including the
+// unknown ones, except the checksum itself: keys are sorted and the
+// values compacted, so it doesn't depend on the version of the meta
+// encoder but only on the content.
+func metaChecksum(data []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	delete(fields, checksumField)
+
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(canonical)), nil
+}
+
+// marshalWithChecksum encodes the meta along with the checksum of
+// its content, the checksum field of m is updated accordingly.
+func (m *Meta) marshalWithChecksum() ([]byte, error) {
+	m.Checksum = ""
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := metaChecksum(data)
+	if err != nil {
+		return nil, err
+	}
+	m.Checksum = sum
+
+	return json.Marshal(m)
+}
+
+// loadVerifiedMeta decodes the meta read from filename and verifies
+// its content against the embedded checksum. Meta files written by
+// previous versions have no checksum and are loaded as is.
+func loadVerifiedMeta(filename string, data []byte) (*Meta, error) {
+	m, err := loadFromJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Checksum == "" {
+		sylog.Debugf("Meta file %s has no checksum, content not verified", filename)
+		return m, nil
+	}
+
+	sum, err := metaChecksum(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute meta checksum: %s", err)
+	}
+	if sum != m.Checksum {
+		return nil, fmt.Errorf("meta file %s is corrupted: %w", filename, errMetaChecksum)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMetaChecksum(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/test", Enabled: true}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(metaPath(m.Name))
+	if err != nil {
+		t.Fatalf("while reading meta: %s", err)
+	}
+
+	writeMeta := func(t *testing.T, data []byte) {
+		t.Helper()
+		if err := ioutil.WriteFile(metaPath(m.Name), data, 0644); err != nil {
+			t.Fatalf("while writing meta: %s", err)
+		}
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		t.Fatalf("while indenting meta: %s", err)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expectError error
+		enabled     bool
+	}{
+		{
+			name:    "Verified",
+			data:    data,
+			enabled: true,
+		},
+		{
+			name:    "Reformatted",
+			data:    indented.Bytes(),
+			enabled: true,
+		},
+		{
+			name:        "FlippedBoolean",
+			data:        bytes.Replace(data, []byte(`"Enabled":true`), []byte(`"Enabled":false`), 1),
+			expectError: errMetaChecksum,
+		},
+		{
+			name:        "ModifiedChecksum",
+			data:        bytes.Replace(data, []byte(`"Checksum":"`), []byte(`"Checksum":"0`), 1),
+			expectError: errMetaChecksum,
+		},
+		{
+			name:    "Legacy",
+			data:    []byte(`{"Name":"example.org/test","Enabled":false}`),
+			enabled: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeMeta(t, tt.data)
+
+			loaded, err := loadMetaByName(m.Name)
+			if !errors.Is(err, tt.expectError) {
+				t.Fatalf("got error %v, expected %v", err, tt.expectError)
+			}
+
+			metas, err := List()
+			if err != nil {
+				t.Fatalf("while listing plugins: %s", err)
+			}
+
+			if tt.expectError != nil {
+				if len(metas) != 0 {
+					t.Errorf("broken plugin listed")
+				}
+				return
+			}
+			if loaded.Enabled != tt.enabled {
+				t.Errorf("got enabled %v, expected %v", loaded.Enabled, tt.enabled)
+			}
+			if len(metas) != 1 {
+				t.Errorf("got %d plugins listed, expected 1", len(metas))
+			}
+		})
+	}
+}
+
+func TestMetaChecksumUnknownFields(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m, err := loadFromJSON(bytes.NewReader(readGolden(t, "meta_unknown.golden")))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(metaPath(m.Name))
+	if err != nil {
+		t.Fatalf("while reading meta: %s", err)
+	}
+
+	// unknown fields are covered by the checksum
+	modified := bytes.Replace(data, []byte(`"key":"value"`), []byte(`"key":"other"`), 1)
+	if err := ioutil.WriteFile(metaPath(m.Name), modified, 0644); err != nil {
+		t.Fatalf("while writing meta: %s", err)
+	}
+	if _, err := loadMetaByName(m.Name); !errors.Is(err, errMetaChecksum) {
+		t.Errorf("got error %v, expected %v", err, errMetaChecksum)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+// nameCompressedImage is the name of the zstd compressed SIF image
+// of the plugin, stored instead of nameImage (see Compact).
+const nameCompressedImage = nameImage + ".zst"
+
+// Compact compresses with zstd the image stored for the installed
+// plugin name. Once the plugin is installed, its image is only read
+// to inspect, verify or export the plugin, it's decompressed
+// transparently then. The plugin meta records the compression and the
+// digest of the uncompressed image, which is checked on decompression
+// so the original image is always restored byte for byte. Compacting
+// an already compressed image does nothing.
+func Compact(name string) error {
+	m, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	if m.ImageCompression != "" {
+		sylog.Infof("Image of plugin %q is already compressed", m.Name)
+		return nil
+	}
+
+	image := m.imageName()
+	digest, err := fileHash(image)
+	if err != nil {
+		return fmt.Errorf("while hashing plugin image: %s", err)
+	}
+
+	f, err := os.Open(image)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	compressed := filepath.Join(m.path(), nameCompressedImage)
+	err = writeFileAtomic(compressed, 0644, func(w io.Writer) error {
+		return CompressBinary(w, f, CompressionZstd)
+	})
+	if err != nil {
+		return fmt.Errorf("while compressing plugin image: %s", err)
+	}
+
+	// the uncompressed image is only removed once the meta
+	// refers to the compressed one
+	m.ImageCompression = CompressionZstd
+	m.ImageDigest = digest
+	if err := m.installMeta(); err != nil {
+		os.Remove(compressed)
+		return err
+	}
+	return os.Remove(image)
+}
+
+// storedImageName returns the path of the plugin image as stored,
+// compressed or not.
+func (m *Meta) storedImageName() string {
+	if m.ImageCompression != "" {
+		return filepath.Join(m.path(), nameCompressedImage)
+	}
+	return m.imageName()
+}
+
+// openImage returns the path of the uncompressed plugin image of m,
+// along with a function releasing it. A compressed image is
+// decompressed to a temporary file, removed by this function.
+func (m *Meta) openImage() (string, func(), error) {
+	if m.ImageCompression == "" {
+		return m.imageName(), func() {}, nil
+	}
+
+	f, err := ioutil.TempFile(fs.TempDir(), "plugin-image-")
+	if err != nil {
+		return "", nil, fmt.Errorf("while creating temporary file: %s", err)
+	}
+	path := f.Name()
+
+	err = decompressImage(f, m.storedImageName(), m.ImageCompression, m.ImageDigest)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", nil, err
+	}
+
+	return path, func() { os.Remove(path) }, nil
+}
+
+// compressImage writes to w the plugin image data compressed with
+// compression, and returns the sha256 of the uncompressed data.
+func compressImage(w io.Writer, data []byte, compression string) (string, error) {
+	if err := CompressBinary(w, bytes.NewReader(data), compression); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// decompressImage writes to w the plugin image path compressed with
+// compression, it fails unless the sha256 of the decompressed image
+// is digest.
+func decompressImage(w io.Writer, path, compression, digest string) error {
+	if compression != CompressionZstd {
+		return fmt.Errorf("unsupported plugin image compression %q", compression)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+	if err != nil {
+		return fmt.Errorf("while decompressing plugin image %s: %s", path, err)
+	}
+	defer zr.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyBuffer(io.MultiWriter(w, h), zr, make([]byte, extractBufferSize)); err != nil {
+		return fmt.Errorf("while decompressing plugin image %s: %s", path, err)
+	}
+	if d := fmt.Sprintf("%x", h.Sum(nil)); d != digest {
+		return fmt.Errorf("decompressed plugin image %s has sha256 %s instead of %s", path, d, digest)
+	}
+	return nil
+}
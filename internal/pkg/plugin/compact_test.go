@@ -0,0 +1,148 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// checkCompactImage checks that the image of the installed plugin name
+// is stored compressed and decompresses to data.
+func checkCompactImage(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	m, err := loadMetaByName(name)
+	if err != nil {
+		t.Fatalf("while loading plugin meta: %s", err)
+	}
+	if m.ImageCompression != CompressionZstd || m.ImageDigest == "" {
+		t.Errorf("got image compression %q and digest %q", m.ImageCompression, m.ImageDigest)
+	}
+	if _, err := os.Stat(filepath.Join(m.path(), nameCompressedImage)); err != nil {
+		t.Errorf("compressed plugin image not stored: %s", err)
+	}
+	if _, err := os.Stat(m.imageName()); !os.IsNotExist(err) {
+		t.Errorf("uncompressed plugin image not removed: %v", err)
+	}
+
+	path, release, err := m.openImage()
+	if err != nil {
+		t.Fatalf("while opening plugin image: %s", err)
+	}
+	defer release()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("while reading plugin image: %s", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Errorf("decompressed plugin image differs from the installed one")
+	}
+
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("decompressed plugin image %s not removed", path)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer stubInstallObject()()
+
+	dir, err := ioutil.TempDir("", "plugin-compact-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/compact", Version: "1.0.0"})
+	installTestPlugin(t, sifPath, "example.org/compact", true)
+
+	m, err := loadMetaByName("example.org/compact")
+	if err != nil {
+		t.Fatalf("while loading plugin meta: %s", err)
+	}
+	data, err := ioutil.ReadFile(m.imageName())
+	if err != nil {
+		t.Fatalf("while reading plugin image: %s", err)
+	}
+
+	if err := Compact("example.org/compact"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkCompactImage(t, "example.org/compact", data)
+
+	// compacting again does nothing
+	if err := Compact("example.org/compact"); err != nil {
+		t.Errorf("unexpected error compacting again: %s", err)
+	}
+	checkCompactImage(t, "example.org/compact", data)
+
+	manifest, err := ManifestOf("example.org/compact")
+	if err != nil {
+		t.Fatalf("while reading manifest: %s", err)
+	}
+	if manifest.Name != "example.org/compact" || manifest.Version != "1.0.0" {
+		t.Errorf("got manifest %s %s, expected example.org/compact 1.0.0", manifest.Name, manifest.Version)
+	}
+
+	// the image survives an export and an import compressed
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+	if err := Import(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+	checkCompactImage(t, "example.org/compact", data)
+
+	if err := Compact("example.org/missing"); err == nil {
+		t.Errorf("unexpected success compacting a plugin not installed")
+	}
+}
+
+func TestDecompressImageDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-compact-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("plugin image")
+	var b bytes.Buffer
+	digest, err := compressImage(&b, data, CompressionZstd)
+	if err != nil {
+		t.Fatalf("while compressing image: %s", err)
+	}
+	path := filepath.Join(dir, nameCompressedImage)
+	if err := ioutil.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatalf("while writing compressed image: %s", err)
+	}
+
+	var out bytes.Buffer
+	if err := decompressImage(&out, path, CompressionZstd, digest); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Errorf("got image %q, expected %q", out.Bytes(), data)
+	}
+
+	if err := decompressImage(ioutil.Discard, path, CompressionZstd, "0000"); err == nil {
+		t.Errorf("unexpected success with a wrong digest")
+	}
+	if err := decompressImage(ioutil.Discard, path, "gzip", digest); err == nil {
+		t.Errorf("unexpected success with an unsupported compression")
+	}
+}
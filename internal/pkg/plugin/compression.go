@@ -0,0 +1,204 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+const (
+	// CompressionGzip is the gzip compression of the plugin binary objects.
+	CompressionGzip = "gzip"
+	// CompressionZstd is the zstd compression of the plugin binary objects.
+	CompressionZstd = "zstd"
+)
+
+// extractBufferSize is the size of the chunks in which the plugin
+// binary object is copied, the memory used by the extraction doesn't
+// depend on the object size.
+const extractBufferSize = 64 * 1024
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CheckCompression returns an error if compression is not
+// a supported compression of the plugin binary objects, an empty
+// compression standing for uncompressed objects.
+func CheckCompression(compression string) error {
+	switch compression {
+	case "", CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unsupported binary compression %q, supported ones are %s and %s", compression, CompressionGzip, CompressionZstd)
+}
+
+// detectCompression returns the compression of the plugin binary
+// object data, identified by its magic number, or an empty string
+// for an uncompressed object.
+func detectCompression(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(data, zstdMagic):
+		return CompressionZstd
+	}
+	return ""
+}
+
+// CompressBinary writes the plugin binary object read from r to w
+// compressed with compression.
+func CompressBinary(w io.Writer, r io.Reader, compression string) error {
+	var cw io.WriteCloser
+
+	switch compression {
+	case CompressionGzip:
+		gw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		cw = gw
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if err != nil {
+			return err
+		}
+		cw = zw
+	default:
+		return CheckCompression(compression)
+	}
+
+	if _, err := io.Copy(cw, r); err != nil {
+		cw.Close()
+		return fmt.Errorf("while compressing plugin binary: %s", err)
+	}
+	return cw.Close()
+}
+
+// decompressBinary writes the plugin binary object read from cr and
+// compressed with compression to w. The decompression stops as soon as
+// it exceeds the declared size, and fails unless it produces exactly
+// size bytes.
+func decompressBinary(w io.Writer, cr io.Reader, compression string, size int64) error {
+	var r io.Reader
+
+	switch compression {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(cr)
+		if err != nil {
+			return fmt.Errorf("while decompressing plugin binary: %s", err)
+		}
+		defer gr.Close()
+		r = gr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(cr, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return fmt.Errorf("while decompressing plugin binary: %s", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return CheckCompression(compression)
+	}
+
+	n, err := io.CopyBuffer(w, io.LimitReader(r, size+1), make([]byte, extractBufferSize))
+	if err != nil {
+		return fmt.Errorf("while decompressing plugin binary: %s", err)
+	}
+	if n > size {
+		return fmt.Errorf("plugin binary decompresses beyond its declared size of %d bytes", size)
+	} else if n != size {
+		return fmt.Errorf("plugin binary decompresses to %d bytes instead of its declared size of %d bytes", n, size)
+	}
+	return nil
+}
+
+// checkBinaryCompression returns the violations of the binary
+// compression fields of manifest.
+func checkBinaryCompression(manifest pluginapi.Manifest) []string {
+	var violations []string
+
+	if err := CheckCompression(manifest.BinaryCompression); err != nil {
+		violations = append(violations, err.Error())
+	}
+	if manifest.BinaryCompression == "" && len(manifest.BinarySizes) > 0 {
+		violations = append(violations, "binary sizes declared for uncompressed binaries")
+	}
+	for arch, size := range manifest.BinarySizes {
+		if sif.GetSIFArch(arch) == sif.HdrArchUnknown {
+			violations = append(violations, fmt.Sprintf("binary size declared for unknown architecture %q", arch))
+		}
+		if size <= 0 {
+			violations = append(violations, fmt.Sprintf("invalid binary size %d for architecture %s", size, arch))
+		}
+	}
+	return violations
+}
+
+// objectReader returns a reader of the data object of the descriptor
+// d of fimg. The data is read from the image file in place when
+// possible rather than loaded in memory.
+func objectReader(fimg *sif.FileImage, d *sif.Descriptor) io.Reader {
+	if ra, ok := fimg.Fp.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, d.Fileoff, d.Filelen)
+	}
+	return bytes.NewReader(d.GetData(fimg))
+}
+
+// extractBinary writes the plugin binary object of fimg for the host
+// to w, decompressing it when the manifest declares a compression. The
+// compression is detected from the data to reject a binary compressed
+// differently than declared, or compressed without the size needed to
+// bound its decompression. The object is streamed in chunks of
+// extractBufferSize bytes, it's never entirely loaded in memory.
+func extractBinary(w io.Writer, fimg *sif.FileImage) error {
+	descr, err := binaryForHost(fimg)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(objectReader(fimg, descr), extractBufferSize)
+
+	manifest := getManifest(newSifFileImageReader(fimg))
+	// a short object is simply not compressed, Peek
+	// returns the data available along with io.EOF
+	magic, err := r.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("while reading plugin binary: %s", err)
+	}
+	compression := detectCompression(magic)
+
+	if compression != manifest.BinaryCompression {
+		if manifest.BinaryCompression == "" {
+			return fmt.Errorf("plugin binary is %s compressed but the manifest declares no compression", compression)
+		}
+		return fmt.Errorf("plugin binary is not %s compressed as declared by the manifest", manifest.BinaryCompression)
+	}
+	if compression == "" {
+		_, err := r.WriteTo(w)
+		return err
+	}
+
+	arch, err := descr.GetArch()
+	if err != nil {
+		return err
+	}
+	goArch := sif.GetGoArch(string(arch[:sif.HdrArchLen-1]))
+	size, ok := manifest.BinarySizes[goArch]
+	if !ok {
+		return fmt.Errorf("no binary size declared in the manifest for the compressed %s plugin binary", goArch)
+	}
+
+	return decompressBinary(w, r, compression, size)
+}
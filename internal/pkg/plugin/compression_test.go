@@ -0,0 +1,252 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// createObjectTestPlugin creates a plugin SIF image in dir whose
+// plugin object for the host architecture is obj and returns it.
+func createObjectTestPlugin(t testing.TB, dir string, manifest pluginapi.Manifest, obj []byte) string {
+	return createStreamTestPlugin(t, dir, manifest, bytes.NewReader(obj), int64(len(obj)))
+}
+
+// createStreamTestPlugin creates a plugin SIF image in dir whose
+// plugin object for the host architecture is the size bytes read
+// from r and returns it.
+func createStreamTestPlugin(t testing.TB, dir string, manifest pluginapi.Manifest, r io.Reader, size int64) string {
+	objInput := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    pluginBinaryName,
+		Fp:       r,
+		Size:     size,
+	}
+	if err := objInput.SetPartExtra(sif.FsRaw, sif.PartData, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+		t.Fatalf("while setting partition information: %s", err)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("while encoding manifest: %s", err)
+	}
+	manifestInput := sif.DescriptorInput{
+		Datatype: sif.DataGenericJSON,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    pluginManifestName,
+		Data:     data,
+		Size:     int64(len(data)),
+	}
+
+	sifPath := filepath.Join(dir, "plugin.sif")
+	_, err = sif.CreateContainer(sif.CreateInfo{
+		Pathname:   sifPath,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: []sif.DescriptorInput{objInput, manifestInput},
+	})
+	if err != nil {
+		t.Fatalf("while creating plugin image: %s", err)
+	}
+
+	return sifPath
+}
+
+func TestExtractBinary(t *testing.T) {
+	obj := bytes.Repeat([]byte("dummy plugin object "), 1024)
+	size := int64(len(obj))
+
+	compressed := make(map[string][]byte)
+	for _, c := range []string{CompressionGzip, CompressionZstd} {
+		var b bytes.Buffer
+		if err := CompressBinary(&b, bytes.NewReader(obj), c); err != nil {
+			t.Fatalf("while compressing with %s: %s", c, err)
+		}
+		if detectCompression(b.Bytes()) != c {
+			t.Fatalf("%s compression not detected", c)
+		}
+		compressed[c] = b.Bytes()
+	}
+
+	sizes := func(size int64) map[string]int64 {
+		return map[string]int64{runtime.GOARCH: size}
+	}
+
+	tests := []struct {
+		name        string
+		compression string
+		sizes       map[string]int64
+		data        []byte
+		expectError bool
+	}{
+		{"Uncompressed", "", nil, obj, false},
+		{"Gzip", CompressionGzip, sizes(size), compressed[CompressionGzip], false},
+		{"Zstd", CompressionZstd, sizes(size), compressed[CompressionZstd], false},
+		{"Bomb", CompressionGzip, sizes(size / 2), compressed[CompressionGzip], true},
+		{"Truncated", CompressionZstd, sizes(size * 2), compressed[CompressionZstd], true},
+		{"NoSize", CompressionGzip, nil, compressed[CompressionGzip], true},
+		{"Undeclared", "", nil, compressed[CompressionZstd], true},
+		{"Mismatch", CompressionZstd, sizes(size), compressed[CompressionGzip], true},
+		{"NotCompressed", CompressionGzip, sizes(size), obj, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-compression-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			manifest := pluginapi.Manifest{
+				Name:              "example.com/compressed",
+				BinaryCompression: tt.compression,
+				BinarySizes:       tt.sizes,
+			}
+			sifPath := createObjectTestPlugin(t, dir, manifest, tt.data)
+
+			fimg, err := sif.LoadContainer(sifPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			if !isPluginFile(newSifFileImageReader(&fimg)) {
+				t.Fatalf("plugin image not recognized")
+			}
+
+			var b bytes.Buffer
+			err = extractBinary(&b, &fimg)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if err == nil && !bytes.Equal(b.Bytes(), obj) {
+				t.Errorf("unexpected extracted binary")
+			}
+		})
+	}
+}
+
+// largeObjectSize is the size of the synthetic plugin object
+// used to check the extraction is streamed.
+const largeObjectSize = 64 << 20
+
+// largeObject returns a reader of a synthetic plugin object of
+// size bytes, the same object is returned for a given size.
+func largeObject(size int64) io.Reader {
+	return io.LimitReader(rand.New(rand.NewSource(size)), size)
+}
+
+func TestExtractLargeBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-large-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{Name: "example.com/large"}
+	sifPath := createStreamTestPlugin(t, dir, manifest, largeObject(largeObjectSize), largeObjectSize)
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	expected := sha256.New()
+	if _, err := io.Copy(expected, largeObject(largeObjectSize)); err != nil {
+		t.Fatalf("while hashing plugin object: %s", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	h := sha256.New()
+	if err := extractBinary(h, &fimg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if !bytes.Equal(h.Sum(nil), expected.Sum(nil)) {
+		t.Errorf("unexpected extracted binary")
+	}
+	// the object must not be loaded in memory
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > largeObjectSize/4 {
+		t.Errorf("extraction allocated %d bytes for a %d bytes object", alloc, largeObjectSize)
+	}
+}
+
+func BenchmarkExtractBinary(b *testing.B) {
+	dir, err := ioutil.TempDir("", "plugin-bench-")
+	if err != nil {
+		b.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const size = 16 << 20
+
+	manifest := pluginapi.Manifest{Name: "example.com/bench"}
+	sifPath := createStreamTestPlugin(b, dir, manifest, largeObject(size), size)
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		b.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := extractBinary(ioutil.Discard, &fimg); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestCheckBinaryCompression(t *testing.T) {
+	tests := []struct {
+		name       string
+		manifest   pluginapi.Manifest
+		violations int
+	}{
+		{"Uncompressed", pluginapi.Manifest{}, 0},
+		{"Zstd", pluginapi.Manifest{BinaryCompression: "zstd", BinarySizes: map[string]int64{"amd64": 10}}, 0},
+		{"Unknown", pluginapi.Manifest{BinaryCompression: "xz"}, 1},
+		{"SizesUncompressed", pluginapi.Manifest{BinarySizes: map[string]int64{"amd64": 10}}, 1},
+		{"InvalidSize", pluginapi.Manifest{BinaryCompression: "gzip", BinarySizes: map[string]int64{"amd64": 0}}, 1},
+		{"UnknownArch", pluginapi.Manifest{BinaryCompression: "gzip", BinarySizes: map[string]int64{"vax": 10}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := checkBinaryCompression(tt.manifest); len(v) != tt.violations {
+				t.Errorf("got violations %q, expected %d", v, tt.violations)
+			}
+		})
+	}
+}
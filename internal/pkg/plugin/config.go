@@ -0,0 +1,361 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// nameConfig is the name of the plugin configuration file.
+const nameConfig = "config.yaml"
+
+// nameConfigBackup is the prefix of the name of the copies of the
+// configuration file replaced by ResetConfig, it's followed by the
+// time of the reset.
+const nameConfigBackup = "config.bak."
+
+// nameConfigDefault is the name of the default configuration file
+// of the installed version of the plugin, refreshed on upgrade.
+const nameConfigDefault = "config.default"
+
+// defaultConfig is the generic configuration file content
+// generated for a plugin at install time.
+const defaultConfig = `# Configuration file of the %s plugin.
+#
+# This file is generated during plugin installation, once modified
+# it will be preserved when upgrading the plugin.
+#
+# Any key can be overridden by an environment variable named after
+# the key, upper-cased and prefixed by %s_, nested
+# keys are joined by an underscore.
+`
+
+// ConfigStatus reports the state of a plugin configuration
+// file relative to the default generated at install time.
+type ConfigStatus int
+
+const (
+	// ConfigUnknown is reported for plugins installed before
+	// the default configuration hash was recorded.
+	ConfigUnknown ConfigStatus = iota
+	// ConfigDefault is reported when the configuration file
+	// matches the generated default.
+	ConfigDefault
+	// ConfigCustomized is reported when the configuration file
+	// has been modified since it was generated.
+	ConfigCustomized
+	// ConfigMissing is reported when the configuration file
+	// doesn't exist.
+	ConfigMissing
+)
+
+// String returns a human readable representation of the status.
+func (s ConfigStatus) String() string {
+	switch s {
+	case ConfigDefault:
+		return "default"
+	case ConfigCustomized:
+		return "customized"
+	case ConfigMissing:
+		return "missing"
+	}
+	return "unknown"
+}
+
+// VerifyConfig reports whether the configuration file of the
+// installed plugin "name" is the default one, has been customized
+// or is missing.
+func VerifyConfig(name string) (ConfigStatus, error) {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return ConfigUnknown, err
+	}
+
+	return meta.VerifyConfig()
+}
+
+// VerifyConfig reports whether the configuration file of the plugin
+// is the default one, has been customized or is missing.
+func (m *Meta) VerifyConfig() (ConfigStatus, error) {
+	if m.ConfigPath == "" || m.ConfigDefaultHash == "" {
+		return ConfigUnknown, nil
+	}
+
+	sum, err := fileHash(m.ConfigPath)
+	if os.IsNotExist(err) {
+		return ConfigMissing, nil
+	} else if err != nil {
+		return ConfigUnknown, err
+	}
+
+	if sum != m.ConfigDefaultHash {
+		return ConfigCustomized, nil
+	}
+	return ConfigDefault, nil
+}
+
+// installConfig generates the default configuration file of the plugin,
+// from the configuration template shipped in the plugin image or from
+// the configuration schema, and records its path and hash. The default
+// is always written to config.default, the configuration file itself is
+// only created when it doesn't exist yet: it's never replaced when the
+// plugin is upgraded, the hash of the default it was generated from is
+// then kept. A customized configuration is converted when the upgrade
+// changes the configuration format, the configuration migrated by
+// migrateConfig, if any, replaces it. The drop-in directory config.d is
+// created empty, the drop-in files of an upgraded plugin are preserved.
+func (m *Meta) installConfig(previous *Meta, migration *configMigration) error {
+	c, err := m.configCodec()
+	if err != nil {
+		return err
+	}
+
+	data, err := m.defaultConfigData(c)
+	if err != nil {
+		return fmt.Errorf("while generating default configuration: %s", err)
+	}
+
+	m.ConfigPath = m.configName()
+	m.ConfigDefaultHash = fmt.Sprintf("%x", sha256.Sum256(data))
+
+	err = writeFileAtomic(m.configDefaultName(), 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("while writing default configuration: %s", err)
+	}
+
+	if err := os.MkdirAll(m.configDirName(), 0755); err != nil {
+		return fmt.Errorf("while creating drop-in configuration directory: %s", err)
+	}
+
+	if migration != nil {
+		return m.installMigratedConfig(previous, migration)
+	} else if previous != nil && previous.hasConfig() && previous.configName() != m.ConfigPath {
+		return m.convertConfig(c, previous, data)
+	} else if previous != nil && previous.hasConfig() {
+		sum, err := fileHash(m.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("while checking configuration of the installed plugin: %s", err)
+		}
+		if sum != m.ConfigDefaultHash {
+			m.ConfigDefaultHash = previous.ConfigDefaultHash
+			sylog.Infof("Preserving the configuration file %s, the default configuration of this version is %s", m.ConfigPath, m.configDefaultName())
+		}
+		if err := m.checkConfig(); err != nil {
+			sylog.Warningf("Plugin %q must be reconfigured: %s", m.Name, err)
+		}
+		return nil
+	}
+
+	return ioutil.WriteFile(m.ConfigPath, data, 0644)
+}
+
+// convertConfig replaces the configuration file of the previous version
+// of the plugin, in another format, by the configuration file in the
+// format c. The default configuration data is installed if the previous
+// configuration wasn't customized, otherwise its content is converted
+// to the new format.
+func (m *Meta) convertConfig(c configCodec, previous *Meta, data []byte) error {
+	status, err := previous.VerifyConfig()
+	if err != nil {
+		return fmt.Errorf("while checking configuration of the installed plugin: %s", err)
+	}
+
+	if status != ConfigDefault {
+		pc, err := previous.configCodec()
+		if err != nil {
+			return err
+		}
+		old, err := ioutil.ReadFile(previous.configName())
+		if err != nil {
+			return fmt.Errorf("while reading configuration of the installed plugin: %s", err)
+		}
+		root, err := pc.decode(old)
+		if err != nil {
+			return fmt.Errorf("invalid configuration %s: %w", previous.configName(), configSyntaxError(err))
+		}
+		if data, err = m.renderConfig(c, root); err != nil {
+			return fmt.Errorf("while converting configuration: %s", err)
+		}
+		m.ConfigDefaultHash = previous.ConfigDefaultHash
+		sylog.Infof("Converting the configuration file %s to %s, the default configuration of this version is %s", previous.configName(), m.ConfigPath, m.configDefaultName())
+	}
+
+	if err := ioutil.WriteFile(m.ConfigPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Remove(previous.configName()); err != nil {
+		sylog.Warningf("Could not remove the configuration file %s: %s", previous.configName(), err)
+	}
+	if err := m.checkConfig(); err != nil {
+		sylog.Warningf("Plugin %q must be reconfigured: %s", m.Name, err)
+	}
+	return nil
+}
+
+// ResetConfig restores the default configuration of the installed plugin
+// "name", regenerated from the plugin image as done at installation. The
+// current configuration file is kept as config.bak.<time> next to it. It
+// fails if the plugin image is missing, the plugin must be reinstalled.
+func ResetConfig(name string) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	c, err := meta.configCodec()
+	if err != nil {
+		return err
+	}
+
+	release, err := meta.lock()
+	if err != nil {
+		return fmt.Errorf("while locking plugin %q: %s", meta.Name, err)
+	}
+	defer release()
+
+	if _, err := os.Stat(meta.storedImageName()); os.IsNotExist(err) {
+		return fmt.Errorf("image of plugin %q is missing, the default configuration can't be regenerated: reinstall the plugin", meta.Name)
+	}
+	path, releaseImage, err := meta.openImage()
+	if err != nil {
+		return fmt.Errorf("while opening image of plugin %q: %s", meta.Name, err)
+	}
+	defer releaseImage()
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("while loading image of plugin %q: %s", meta.Name, err)
+	}
+	defer fimg.UnloadContainer()
+
+	meta.sifFile = &fimg
+	data, err := meta.defaultConfigData(c)
+	meta.sifFile = nil
+	if err != nil {
+		return fmt.Errorf("while generating default configuration: %s", err)
+	}
+
+	if err := meta.backupConfig(); err != nil {
+		return err
+	}
+
+	for _, name := range []string{meta.configDefaultName(), meta.configName()} {
+		err := writeFileAtomic(name, 0644, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("while writing default configuration: %s", err)
+		}
+	}
+
+	meta.ConfigPath = meta.configName()
+	meta.ConfigDefaultHash = fmt.Sprintf("%x", sha256.Sum256(data))
+	return meta.installMeta()
+}
+
+// backupConfig saves the configuration file of the plugin, if any, as
+// config.bak.<time> next to it before it's replaced.
+func (m *Meta) backupConfig() error {
+	current, err := ioutil.ReadFile(m.configName())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("while reading configuration file: %s", err)
+	}
+
+	backup := filepath.Join(m.path(), nameConfigBackup+time.Now().Format("20060102150405"))
+	if err := ioutil.WriteFile(backup, current, 0644); err != nil {
+		return fmt.Errorf("while saving configuration: %s", err)
+	}
+	sylog.Infof("Configuration of plugin %q saved to %s", m.Name, backup)
+	return nil
+}
+
+// defaultConfigData returns the default configuration file of the
+// plugin in the format c: the configuration template shipped in the
+// plugin image if any, otherwise the file documenting the configuration
+// schema, which is generic for plugins without schema.
+func (m *Meta) defaultConfigData(c configCodec) ([]byte, error) {
+	if m.sifFile != nil {
+		r := newSifFileImageReader(m.sifFile)
+		if n := findDescriptor(r, pluginConfigName); n >= 0 && r.GetDatatype(n) == sif.DataGeneric {
+			return renderConfigTemplate(c, m.Name, m.ConfigSchema, r.GetData(n))
+		}
+	}
+	return c.render(m.Name, m.ConfigSchema, defaultConfigValues(m.ConfigSchema))
+}
+
+// renderConfigTemplate returns the configuration file of the plugin
+// "name" made of the configuration template data, once checked against
+// the options, preceded by the generic header if the format c supports
+// comments.
+func renderConfigTemplate(c configCodec, name string, options []pluginapi.ConfigOption, data []byte) ([]byte, error) {
+	if err := checkConfigTemplate(c, options, data); err != nil {
+		return nil, fmt.Errorf("invalid configuration template: %w", err)
+	}
+
+	var b bytes.Buffer
+	if c.hasComments() {
+		fmt.Fprintf(&b, defaultConfig, name, ConfigEnvKey(name))
+		b.WriteString("\n")
+	}
+	b.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		b.WriteString("\n")
+	}
+	return b.Bytes(), nil
+}
+
+// checkConfigTemplate validates the configuration template data,
+// decoded by c, against the options, the unknown keys are violations.
+// Only the syntax is checked without options.
+func checkConfigTemplate(c configCodec, options []pluginapi.ConfigOption, data []byte) error {
+	if len(options) == 0 {
+		if _, err := c.decode(data); err != nil {
+			return configSyntaxError(err)
+		}
+		return nil
+	}
+	_, err := validateConfig(c, options, data, true)
+	return err
+}
+
+// hasConfig returns whether a configuration file exists for the plugin.
+func (m *Meta) hasConfig() bool {
+	_, err := os.Stat(m.configName())
+	return err == nil
+}
+
+// fileHash returns the hexadecimal sha256 of the file content.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// setTestRootDir points the plugin installation directory, and the
+// user configuration directory to its user subdirectory, to a temporary
+// directory and returns a function restoring them.
+func setTestRootDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "plugin-root-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+
+	orig, origUser := rootDir, userConfigDir
+	rootDir = dir
+	userConfigDir = func() string { return filepath.Join(dir, "user") }
+
+	return func() {
+		rootDir, userConfigDir = orig, origUser
+		os.RemoveAll(dir)
+	}
+}
+
+func checkConfigStatus(t *testing.T, m *Meta, expected ConfigStatus) {
+	t.Helper()
+
+	status, err := m.VerifyConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if status != expected {
+		t.Errorf("got config status %q, expected %q", status, expected)
+	}
+}
+
+func TestInstallConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/test"}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+
+	// legacy plugins don't have the configuration recorded
+	checkConfigStatus(t, m, ConfigUnknown)
+
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	checkConfigStatus(t, m, ConfigDefault)
+
+	custom := []byte("key: value\n")
+	if err := ioutil.WriteFile(m.ConfigPath, custom, 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+	checkConfigStatus(t, m, ConfigCustomized)
+
+	// upgrading must preserve the customized configuration
+	upgrade := &Meta{Name: m.Name}
+	if err := upgrade.installConfig(m, nil); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	if data, err := ioutil.ReadFile(upgrade.ConfigPath); err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	} else if string(data) != string(custom) {
+		t.Errorf("customized configuration was overwritten: %q", data)
+	}
+	checkConfigStatus(t, upgrade, ConfigCustomized)
+
+	if err := os.Remove(upgrade.ConfigPath); err != nil {
+		t.Fatalf("while removing configuration: %s", err)
+	}
+	checkConfigStatus(t, upgrade, ConfigMissing)
+
+	// a missing configuration is regenerated on upgrade
+	if err := m.installConfig(upgrade, nil); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	checkConfigStatus(t, m, ConfigDefault)
+
+	// a default configuration is not replaced on upgrade either,
+	// only the default of the new version is refreshed
+	previous, err := ioutil.ReadFile(m.ConfigPath)
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	upgrade = &Meta{Name: m.Name, ConfigSchema: testConfigSchema}
+	if err := upgrade.installConfig(m, nil); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(upgrade.ConfigPath); string(data) != string(previous) {
+		t.Errorf("default configuration was replaced: %q", data)
+	}
+	checkConfigStatus(t, upgrade, ConfigDefault)
+
+	data, err := ioutil.ReadFile(upgrade.configDefaultName())
+	if err != nil {
+		t.Fatalf("while reading default configuration: %s", err)
+	}
+	if !strings.Contains(string(data), "# Enable verbose logging\n") {
+		t.Errorf("default configuration not refreshed:\n%s", data)
+	}
+}
+
+func TestInstallConfigTemplate(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	binary := fixtureDescriptor{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartData, "object"}
+	template := func(data string) fixtureDescriptor {
+		return fixtureDescriptor{pluginConfigName, sif.DataGeneric, 0, 0, data}
+	}
+
+	tests := []struct {
+		name        string
+		schema      []pluginapi.ConfigOption
+		descrs      []fixtureDescriptor
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "NoTemplate",
+			descrs:   []fixtureDescriptor{binary},
+			expected: "# Any key can be overridden",
+		},
+		{
+			name:     "FreeForm",
+			descrs:   []fixtureDescriptor{binary, template("# upstream server\nurl: https://example.org")},
+			expected: "\n# upstream server\nurl: https://example.org\n",
+		},
+		{
+			name:     "Schema",
+			schema:   testConfigSchema,
+			descrs:   []fixtureDescriptor{binary, template("# listen on the default port\nserver:\n  port: 80\n")},
+			expected: "\n# listen on the default port\nserver:\n  port: 80\n",
+		},
+		{
+			name:        "InvalidSyntax",
+			descrs:      []fixtureDescriptor{binary, template("url: [")},
+			expectError: true,
+		},
+		{
+			name:        "InvalidValue",
+			schema:      testConfigSchema,
+			descrs:      []fixtureDescriptor{binary, template("server:\n  port: http\n")},
+			expectError: true,
+		},
+		{
+			name:        "UnknownKey",
+			schema:      testConfigSchema,
+			descrs:      []fixtureDescriptor{binary, template("unknown: true\n")},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-config-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			fimg, err := sif.LoadContainer(createFixtureImage(t, dir, tt.descrs), true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			m := &Meta{Name: "example.org/config", ConfigSchema: tt.schema, sifFile: &fimg}
+			if err := os.MkdirAll(m.path(), 0755); err != nil {
+				t.Fatalf("while creating plugin directory: %s", err)
+			}
+			defer os.RemoveAll(m.path())
+
+			err = m.installConfig(nil, nil)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				return
+			}
+
+			for _, path := range []string{m.ConfigPath, m.configDefaultName()} {
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatalf("while reading configuration: %s", err)
+				}
+				if !strings.HasPrefix(string(data), "# Configuration file of the example.org/config plugin.") {
+					t.Errorf("no generic header in %s:\n%s", path, data)
+				}
+				if !strings.Contains(string(data), tt.expected) {
+					t.Errorf("%s doesn't contain %q:\n%s", path, tt.expected, data)
+				}
+			}
+			checkConfigStatus(t, m, ConfigDefault)
+			if err := m.checkConfig(); err != nil {
+				t.Errorf("invalid configuration: %s", err)
+			}
+		})
+	}
+}
+
+func TestResetConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-reset-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	m, err := loadMetaByName("example.org/test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defaultData, err := ioutil.ReadFile(m.ConfigPath)
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	custom := []byte("key: [broken\n")
+	if err := ioutil.WriteFile(m.ConfigPath, custom, 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	if err := ResetConfig(m.Name); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if data, _ := ioutil.ReadFile(m.ConfigPath); string(data) != string(defaultData) {
+		t.Errorf("got configuration %q, expected %q", data, defaultData)
+	}
+	if m, err = loadMetaByName(m.Name); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkConfigStatus(t, m, ConfigDefault)
+
+	backups, err := filepath.Glob(filepath.Join(m.path(), nameConfigBackup+"*"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got configuration backups %q: %v", backups, err)
+	}
+	if data, _ := ioutil.ReadFile(backups[0]); string(data) != string(custom) {
+		t.Errorf("got backup %q, expected %q", data, custom)
+	}
+
+	// nothing to regenerate the configuration from
+	if err := os.Remove(m.storedImageName()); err != nil {
+		t.Fatalf("while removing plugin image: %s", err)
+	}
+	if err := ResetConfig(m.Name); err == nil || !strings.Contains(err.Error(), "reinstall") {
+		t.Errorf("unexpected error with a missing image: %v", err)
+	}
+
+	if err := ResetConfig("example.org/unknown"); err == nil {
+		t.Errorf("unexpected success with an unknown plugin")
+	}
+}
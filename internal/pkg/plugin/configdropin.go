@@ -0,0 +1,173 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// nameConfigDir is the name of the directory holding the drop-in
+// configuration files of the plugin.
+const nameConfigDir = "config.d"
+
+// configLayer is a configuration file of the plugin, the base
+// configuration file or a drop-in file, with its decoded content.
+type configLayer struct {
+	path string
+	root map[interface{}]interface{}
+}
+
+// configFileError reports the syntax error of a configuration
+// file of the plugin.
+type configFileError struct {
+	path string
+	err  *ConfigError
+}
+
+func (e *configFileError) Error() string {
+	return fmt.Sprintf("invalid configuration %s: %s", e.path, e.err)
+}
+
+func (e *configFileError) Unwrap() error {
+	return e.err
+}
+
+// configDropIns returns the paths of the drop-in configuration files of
+// the plugin in lexical order. They are the regular files of config.d
+// with the extension of the configuration format, .yml being accepted
+// for YAML, the hidden files are ignored.
+func (m *Meta) configDropIns() ([]string, error) {
+	files, err := ioutil.ReadDir(m.configDirName())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("while reading drop-in configuration directory: %s", err)
+	}
+
+	exts := []string{filepath.Ext(configFileName(m.ConfigFormat))}
+	if m.ConfigFormat == "" || m.ConfigFormat == pluginapi.ConfigFormatYAML {
+		exts = append(exts, ".yml")
+	}
+
+	var paths []string
+	for _, fi := range files {
+		name := fi.Name()
+		if strings.HasPrefix(name, ".") || !containsString(exts, filepath.Ext(name)) {
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			sylog.Debugf("Ignoring drop-in configuration %s: not a regular file", name)
+			continue
+		}
+		paths = append(paths, filepath.Join(m.configDirName(), name))
+	}
+	return paths, nil
+}
+
+// configLayers returns the configuration file of the plugin followed
+// by its drop-in files, decoded by c. A missing configuration file has
+// no content.
+func (m *Meta) configLayers(c configCodec) ([]configLayer, error) {
+	dropIns, err := m.configDropIns()
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]configLayer, 0, len(dropIns)+1)
+	for i, path := range append([]string{m.configName()}, dropIns...) {
+		data, err := ioutil.ReadFile(path)
+		if i == 0 && os.IsNotExist(err) {
+			data = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("while reading configuration file: %s", err)
+		}
+		root, err := c.decode(data)
+		if err != nil {
+			return nil, &configFileError{path: path, err: configSyntaxError(err)}
+		}
+		layers = append(layers, configLayer{path: path, root: root})
+	}
+	return layers, nil
+}
+
+// mergedConfig returns the content of the configuration file of the
+// plugin with its drop-in files merged over it, see mergeConfigRoot,
+// along with the configuration layers it's made of.
+func (m *Meta) mergedConfig(c configCodec) (map[interface{}]interface{}, []configLayer, error) {
+	layers, err := m.configLayers(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := make(map[interface{}]interface{})
+	for _, l := range layers {
+		mergeConfigRoot(root, l.root)
+	}
+	return root, layers, nil
+}
+
+// mergeConfigRoot merges the decoded configuration src over dst. The
+// mappings present in both are merged recursively, key by key, any
+// other value of src, a list included, replaces the value of dst. A
+// null value of src thus unsets the key, or the whole section, of dst.
+// The mappings of src are copied, src is never altered by later merges.
+func mergeConfigRoot(dst, src map[interface{}]interface{}) {
+	for k, v := range src {
+		if dk, ok := configNodeKey(dst, fmt.Sprint(k)); ok {
+			dm, dok := dst[dk].(map[interface{}]interface{})
+			sm, sok := v.(map[interface{}]interface{})
+			if dok && sok {
+				mergeConfigRoot(dm, sm)
+				continue
+			}
+			delete(dst, dk)
+		}
+		if v == nil {
+			continue
+		}
+		if sm, ok := v.(map[interface{}]interface{}); ok {
+			m := make(map[interface{}]interface{}, len(sm))
+			mergeConfigRoot(m, sm)
+			v = m
+		}
+		dst[k] = v
+	}
+}
+
+// configOrigins returns the path of the configuration file, among the
+// layers, setting each of keys: the last layer where the key is set.
+func configOrigins(layers []configLayer, keys []string) map[string]string {
+	origins := make(map[string]string, len(keys))
+	for _, k := range keys {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if layers[i].root == nil {
+				continue
+			}
+			if _, ok := configNodeValue(layers[i].root, strings.Split(k, ".")); ok {
+				origins[k] = layers[i].path
+				break
+			}
+		}
+	}
+	return origins
+}
+
+// configDescription returns the description of the configuration made
+// of layers in messages: the path of the configuration file, followed
+// by the drop-in directory if drop-in files are merged over it.
+func configDescription(layers []configLayer) string {
+	if len(layers) < 2 {
+		return layers[0].path
+	}
+	return fmt.Sprintf("%s merged with %s", layers[0].path, filepath.Dir(layers[1].path))
+}
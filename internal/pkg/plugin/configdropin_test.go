@@ -0,0 +1,197 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestMergeConfigRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      string
+		src      string
+		expected string
+	}{
+		{"Empty", "", "a: 1", "a: 1"},
+		{"Override", "a: 1\nb: 2", "a: 3", "a: 3\nb: 2"},
+		{"NestedMerge", "s:\n  a: 1\n  b: 2", "s:\n  b: 3\n  c: 4", "s:\n  a: 1\n  b: 3\n  c: 4"},
+		{"ListReplaced", "l: [a, b]", "l: [c]", "l: [c]"},
+		{"SectionReplaced", "s:\n  a: 1", "s: 2", "s: 2"},
+		{"ScalarReplaced", "s: 2", "s:\n  a: 1", "s:\n  a: 1"},
+		{"NullUnsets", "a: 1\ns:\n  b: 2\n  c: 3", "a: null\ns:\n  b: ~", "s:\n  c: 3"},
+		{"NullUnsetsSection", "a: 1\ns:\n  b: 2", "s: null", "a: 1"},
+		{"NullNotSet", "a: 1", "b: null", "a: 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst, src, expected map[interface{}]interface{}
+			for _, d := range []struct {
+				data string
+				root *map[interface{}]interface{}
+			}{{tt.dst, &dst}, {tt.src, &src}, {tt.expected, &expected}} {
+				if err := yaml.Unmarshal([]byte(d.data), d.root); err != nil {
+					t.Fatalf("while decoding %q: %s", d.data, err)
+				}
+			}
+
+			merged := make(map[interface{}]interface{})
+			mergeConfigRoot(merged, dst)
+			mergeConfigRoot(merged, src)
+			if !reflect.DeepEqual(merged, expected) {
+				t.Errorf("got %v, expected %v", merged, expected)
+			}
+
+			// the sections of src are copied
+			mergeConfigRoot(merged, map[interface{}]interface{}{
+				"s": map[interface{}]interface{}{"z": 0},
+			})
+			if s, ok := src["s"].(map[interface{}]interface{}); ok {
+				if _, ok := s["z"]; ok {
+					t.Errorf("source altered by a later merge")
+				}
+			}
+		})
+	}
+}
+
+func TestConfigDropIns(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = testConfigSchema
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	if fi, err := os.Stat(m.configDirName()); err != nil || !fi.IsDir() {
+		t.Fatalf("drop-in directory not created: %v", err)
+	}
+
+	writeDropIn := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(m.configDirName(), name), []byte(content), 0644); err != nil {
+			t.Fatalf("while writing drop-in %s: %s", name, err)
+		}
+	}
+	writeDropIn("20-port.yaml", "server:\n  port: 9090\n")
+	writeDropIn("10-server.yml", "server:\n  port: 8081\n  hosts: [c.example.com]\nverbose: true\n")
+	writeDropIn("30-ignored.json", "{\"verbose\": 1}")
+	writeDropIn(".hidden.yaml", "verbose: 1\n")
+	if err := os.Mkdir(filepath.Join(m.configDirName(), "dir.yaml"), 0755); err != nil {
+		t.Fatalf("while creating directory: %s", err)
+	}
+
+	values, origins, err := GetConfigOrigins(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"verbose":        true,
+		"name":           "yes",
+		"server.port":    9090,
+		"server.timeout": "30s",
+		"server.hosts":   []interface{}{"c.example.com"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v, expected %v", values, expected)
+	}
+	expectedOrigins := map[string]string{
+		"verbose":        filepath.Join(m.configDirName(), "10-server.yml"),
+		"name":           m.configName(),
+		"server.port":    filepath.Join(m.configDirName(), "20-port.yaml"),
+		"server.timeout": m.configName(),
+		"server.hosts":   filepath.Join(m.configDirName(), "10-server.yml"),
+	}
+	if !reflect.DeepEqual(origins, expectedOrigins) {
+		t.Errorf("got origins %v, expected %v", origins, expectedOrigins)
+	}
+
+	// the plugin loads the merged configuration
+	var config struct {
+		Verbose bool
+		Server  struct {
+			Port int
+		}
+	}
+	if err := LoadConfig(m.Name, &config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !config.Verbose || config.Server.Port != 9090 {
+		t.Errorf("unexpected configuration %+v", config)
+	}
+
+	// a key overridden by a drop-in keeps its value
+	if err := SetConfigKey(m.Name, "server.port", "80"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if values, _ := GetConfig(m.Name); values["server.port"] != 9090 {
+		t.Errorf("drop-in value overridden: %v", values["server.port"])
+	}
+
+	// the merged configuration is validated
+	if err := ValidateConfig(m.Name); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	writeDropIn("40-invalid.yaml", "server:\n  port: http\n")
+	if err := ValidateConfig(m.Name); err == nil {
+		t.Errorf("unexpected success with an invalid drop-in")
+	}
+	if err := SetConfigKey(m.Name, "verbose", "false"); err == nil {
+		t.Errorf("unexpected success with an invalid drop-in")
+	}
+	writeDropIn("40-invalid.yaml", "server: [\n")
+	if _, err := GetConfig(m.Name); err == nil {
+		t.Errorf("unexpected success with a drop-in syntax error")
+	}
+	if err := os.Remove(filepath.Join(m.configDirName(), "40-invalid.yaml")); err != nil {
+		t.Fatalf("while removing drop-in: %s", err)
+	}
+
+	// a null value unsets the key set by earlier files
+	writeDropIn("50-unset.yaml", "server:\n  hosts: null\n")
+	values, origins, err = GetConfigOrigins(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, ok := values["server.hosts"]; ok {
+		t.Errorf("server.hosts still set: %v", values)
+	}
+	if _, ok := origins["server.hosts"]; ok {
+		t.Errorf("server.hosts still attributed: %v", origins)
+	}
+
+	// the drop-in files are preserved on upgrade
+	upgrade := &Meta{Name: m.Name, ConfigSchema: testConfigSchema}
+	if err := upgrade.installConfig(m, nil); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.configDirName(), "20-port.yaml")); err != nil {
+		t.Errorf("drop-in not preserved: %s", err)
+	}
+
+	// and removed with the plugin
+	for _, f := range []string{m.binaryName(), m.storedImageName()} {
+		if err := ioutil.WriteFile(f, nil, 0644); err != nil {
+			t.Fatalf("while writing %s: %s", f, err)
+		}
+	}
+	if err := m.uninstall(); err != nil {
+		t.Fatalf("while uninstalling plugin: %s", err)
+	}
+	if _, err := os.Stat(m.configDirName()); !os.IsNotExist(err) {
+		t.Errorf("drop-in directory not removed: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// maxConfigExportSize is the maximum size of an exported configuration.
+const maxConfigExportSize = 1 << 20
+
+// ConfigExport is the configuration of a plugin as written by
+// ExportConfig, along with the information required to import it
+// into another version of the plugin.
+type ConfigExport struct {
+	// Name is the name of the exporting plugin.
+	Name string `json:"Name"`
+	// Version is the version of the exporting plugin.
+	Version string `json:"Version,omitempty"`
+	// ConfigFormat is the format of Config.
+	ConfigFormat pluginapi.ConfigFormat `json:"ConfigFormat,omitempty"`
+	// ConfigSchema is the configuration schema of the exporting
+	// plugin, the options it adds are set to their default when
+	// imported into a newer version.
+	ConfigSchema []pluginapi.ConfigOption `json:"ConfigSchema,omitempty"`
+	// Config is the configuration file of the exporting plugin
+	// merged with its drop-in files.
+	Config string `json:"Config"`
+}
+
+// ExportConfig writes to w the configuration of the installed plugin
+// "name", its configuration file merged with its drop-in files as the
+// plugin loads it, without the user overlay and the environment
+// overrides, in the ConfigExport JSON format read by ImportConfig.
+func ExportConfig(name string, w io.Writer) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	c, err := meta.configCodec()
+	if err != nil {
+		return err
+	}
+	root, layers, err := meta.mergedConfig(c)
+	if err != nil {
+		return err
+	}
+	data, err := meta.renderConfig(c, root)
+	if err != nil {
+		return fmt.Errorf("while rendering configuration %s: %s", configDescription(layers), err)
+	}
+
+	e := ConfigExport{
+		Name:         meta.Name,
+		Version:      meta.Version,
+		ConfigFormat: meta.ConfigFormat,
+		ConfigSchema: meta.ConfigSchema,
+		Config:       string(data),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// ImportConfig installs the configuration exported by ExportConfig read
+// from r as the configuration file of the installed plugin "name". An
+// exported configuration of another version of the plugin goes through
+// the configuration migration declared in the manifest of the installed
+// plugin, as done on upgrade. It's converted to the configuration format
+// of the installed plugin and validated against its configuration schema,
+// if any, the unknown keys being refused. The configuration file is replaced
+// atomically, the previous one is saved as config.bak.<time> next to it.
+func ImportConfig(name string, r io.Reader) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxConfigExportSize+1))
+	if err != nil {
+		return fmt.Errorf("while reading exported configuration: %s", err)
+	} else if len(data) > maxConfigExportSize {
+		return fmt.Errorf("exported configuration exceeds %d bytes", maxConfigExportSize)
+	}
+	var e ConfigExport
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("invalid exported configuration: %s", err)
+	}
+	if e.Name == "" {
+		return fmt.Errorf("invalid exported configuration: no plugin name")
+	}
+	if normalizeName(e.Name) != normalizeName(meta.Name) {
+		sylog.Warningf("Importing the configuration of plugin %q into plugin %q", e.Name, meta.Name)
+	}
+
+	ec, err := newConfigCodec(e.ConfigFormat)
+	if err != nil {
+		return fmt.Errorf("invalid exported configuration: %s", err)
+	}
+	root, err := ec.decode([]byte(e.Config))
+	if err != nil {
+		return fmt.Errorf("invalid exported configuration: %w", configSyntaxError(err))
+	}
+	if root == nil {
+		root = make(map[interface{}]interface{})
+	}
+
+	if e.Version != meta.Version {
+		if err := meta.migrateImportedConfig(&e, root); err != nil {
+			return err
+		}
+	}
+
+	c, err := meta.configCodec()
+	if err != nil {
+		return err
+	}
+
+	// the configuration is validated as written in the format of the
+	// installed plugin, the keys unknown to its schema included
+	if len(meta.ConfigSchema) > 0 {
+		data, err := c.encode(root)
+		if err == nil {
+			root, err = c.decode(data)
+		}
+		if err == nil {
+			_, err = validateConfigRoot(meta.ConfigSchema, root, true)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid exported configuration for plugin %q version %s: %w", meta.Name, meta.Version, err)
+		}
+	}
+
+	out, err := meta.renderConfig(c, root)
+	if err != nil {
+		return fmt.Errorf("while rendering configuration: %s", err)
+	}
+
+	release, err := meta.lock()
+	if err != nil {
+		return fmt.Errorf("while locking plugin %q: %s", meta.Name, err)
+	}
+	defer release()
+
+	if err := meta.backupConfig(); err != nil {
+		return err
+	}
+	err = writeFileAtomic(meta.configName(), 0644, func(w io.Writer) error {
+		_, err := w.Write(out)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("while writing configuration: %s", err)
+	}
+
+	// the drop-in files of this host still apply
+	if dropIns, err := meta.configDropIns(); err == nil && len(dropIns) > 0 {
+		sylog.Warningf("The drop-in files of %s are merged over the imported configuration of plugin %q", meta.configDirName(), meta.Name)
+		if err := meta.checkConfig(); err != nil {
+			sylog.Warningf("Plugin %q must be reconfigured: %s", meta.Name, err)
+		}
+	}
+	return nil
+}
+
+// migrateImportedConfig applies to the decoded configuration root
+// exported by another version of the plugin the configuration migration
+// declared in the manifest of the installed plugin, as done on upgrade.
+func (m *Meta) migrateImportedConfig(e *ConfigExport, root map[interface{}]interface{}) error {
+	manifest, err := m.manifest()
+	if err != nil {
+		sylog.Warningf("Could not read the configuration migration of plugin %q: %s", m.Name, err)
+		return nil
+	}
+	if manifest.ConfigMigration == nil {
+		return nil
+	}
+
+	m.configMigration = manifest.ConfigMigration
+	defer func() { m.configMigration = nil }()
+
+	previous := &Meta{Name: e.Name, Version: e.Version, ConfigSchema: e.ConfigSchema}
+	changes, err := m.migrateConfigRoot(previous, root)
+	if err != nil {
+		return fmt.Errorf("while migrating exported configuration: %s", err)
+	}
+	if len(changes) > 0 {
+		sylog.Infof("Migrated the exported configuration of plugin %q version %s:\n  - %s",
+			e.Name, e.Version, strings.Join(changes, "\n  - "))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestExportImportConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-config-export-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{
+		Name:    "example.org/test",
+		Version: "2.0.0",
+		Config:  testConfigSchema,
+		ConfigMigration: &pluginapi.ConfigMigration{
+			Renamed: map[string]string{"listen-port": "server.port"},
+			Removed: []string{"debug"},
+		},
+	})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	m, err := loadMetaByName("example.org/test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m.Version = "2.0.0"
+	m.ConfigSchema = testConfigSchema
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+	if err := ioutil.WriteFile(m.ConfigPath, []byte("server:\n  port: 9090\n"), 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	importConfig := func(e ConfigExport) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("while encoding exported configuration: %s", err)
+		}
+		return ImportConfig(m.Name, bytes.NewReader(data))
+	}
+	checkValue := func(key string, expected interface{}) {
+		t.Helper()
+		values, err := GetConfig(m.Name)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if values[key] != expected {
+			t.Errorf("got %s = %v, expected %v", key, values[key], expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ExportConfig(m.Name, &buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var e ConfigExport
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("while decoding exported configuration: %s", err)
+	}
+	if e.Name != m.Name || e.Version != "2.0.0" || !strings.Contains(e.Config, "9090") {
+		t.Errorf("unexpected exported configuration %+v", e)
+	}
+
+	// round trip
+	if err := ioutil.WriteFile(m.ConfigPath, []byte("verbose: true\n"), 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+	if err := ImportConfig(m.Name, &buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkValue("server.port", 9090)
+	checkValue("verbose", nil)
+	backups, err := filepath.Glob(filepath.Join(m.path(), nameConfigBackup+"*"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got configuration backups %q: %v", backups, err)
+	}
+	if data, _ := ioutil.ReadFile(backups[0]); string(data) != "verbose: true\n" {
+		t.Errorf("got backup %q, expected the previous configuration", data)
+	}
+
+	// converted to the format of the installed plugin
+	if err := importConfig(ConfigExport{Name: m.Name, Version: "2.0.0", ConfigFormat: pluginapi.ConfigFormatJSON, Config: `{"verbose": true}`}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkValue("verbose", true)
+	if data, _ := ioutil.ReadFile(m.ConfigPath); !strings.Contains(string(data), "verbose: true") {
+		t.Errorf("configuration not converted to YAML: %q", data)
+	}
+
+	// migrated from a previous version
+	previous := ConfigExport{
+		Name:    m.Name,
+		Version: "1.0.0",
+		ConfigSchema: []pluginapi.ConfigOption{
+			{Key: "listen-port", Type: pluginapi.ConfigTypeInt, Default: "8080"},
+			{Key: "debug", Type: pluginapi.ConfigTypeBool},
+		},
+		Config: "listen-port: 9091\ndebug: true\n",
+	}
+	if err := importConfig(previous); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checkValue("server.port", 9091)
+
+	// stale and unknown keys are refused, the configuration is left as is
+	current, err := ioutil.ReadFile(m.ConfigPath)
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	previous.Config = "stale: true\n"
+	if err := importConfig(previous); err == nil {
+		t.Errorf("unexpected success with a stale key")
+	}
+	if err := importConfig(ConfigExport{Name: m.Name, Version: "2.0.0", Config: "server:\n  port: http\n"}); err == nil {
+		t.Errorf("unexpected success with an invalid value")
+	}
+	if data, _ := ioutil.ReadFile(m.ConfigPath); string(data) != string(current) {
+		t.Errorf("configuration modified by a refused import: %q", data)
+	}
+
+	// invalid exports
+	if err := importConfig(ConfigExport{Version: "2.0.0", Config: "verbose: true\n"}); err == nil {
+		t.Errorf("unexpected success without a plugin name")
+	}
+	if err := importConfig(ConfigExport{Name: m.Name, Version: "2.0.0", ConfigFormat: "ini", Config: "verbose = true\n"}); err == nil {
+		t.Errorf("unexpected success with an unknown format")
+	}
+	if err := importConfig(ConfigExport{Name: m.Name, Version: "2.0.0", Config: "verbose: [true\n"}); err == nil {
+		t.Errorf("unexpected success with a syntax error")
+	}
+	if err := ImportConfig(m.Name, strings.NewReader("verbose: true\n")); err == nil {
+		t.Errorf("unexpected success with an invalid export")
+	}
+	if err := ImportConfig("example.org/unknown", strings.NewReader("{}")); err == nil {
+		t.Errorf("unexpected success with an unknown plugin")
+	}
+	if err := ExportConfig("example.org/unknown", ioutil.Discard); err == nil {
+		t.Errorf("unexpected success with an unknown plugin")
+	}
+}
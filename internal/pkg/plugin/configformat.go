@@ -0,0 +1,258 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"gopkg.in/yaml.v2"
+)
+
+// configCodec decodes and encodes the configuration file in the format
+// declared by the plugin manifest.
+type configCodec interface {
+	// decode returns the content of the configuration file data, the
+	// mappings are decoded to map[interface{}]interface{} and the
+	// integers to int, as done by the YAML decoder, whatever the format.
+	decode(data []byte) (map[interface{}]interface{}, error)
+	// encode returns the configuration file holding the content root.
+	encode(root map[interface{}]interface{}) ([]byte, error)
+	// render returns the configuration file of the plugin "name"
+	// documenting the options, see renderConfig.
+	render(name string, options []pluginapi.ConfigOption, values map[string]interface{}) ([]byte, error)
+	// hasComments returns whether the format supports comments, the
+	// configuration template is then preceded by the generic header.
+	hasComments() bool
+}
+
+// configFileName returns the name of the configuration file
+// in the format f, config.yaml being kept for YAML.
+func configFileName(f pluginapi.ConfigFormat) string {
+	if f == "" || f == pluginapi.ConfigFormatYAML {
+		return nameConfig
+	}
+	return "config." + string(f)
+}
+
+// newConfigCodec returns the codec of the configuration format f.
+func newConfigCodec(f pluginapi.ConfigFormat) (configCodec, error) {
+	switch f {
+	case "", pluginapi.ConfigFormatYAML:
+		return yamlConfigCodec{}, nil
+	case pluginapi.ConfigFormatJSON:
+		return jsonConfigCodec{}, nil
+	case pluginapi.ConfigFormatTOML:
+		return tomlConfigCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown config format %q", f)
+}
+
+// configCodec returns the codec of the configuration file of the plugin.
+func (m *Meta) configCodec() (configCodec, error) {
+	return newConfigCodec(m.ConfigFormat)
+}
+
+type yamlConfigCodec struct{}
+
+func (yamlConfigCodec) decode(data []byte) (map[interface{}]interface{}, error) {
+	var root map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (yamlConfigCodec) encode(root map[interface{}]interface{}) ([]byte, error) {
+	return yaml.Marshal(root)
+}
+
+func (yamlConfigCodec) render(name string, options []pluginapi.ConfigOption, values map[string]interface{}) ([]byte, error) {
+	return renderConfig(name, options, values)
+}
+
+func (yamlConfigCodec) hasComments() bool { return true }
+
+// jsonConfigCodec handles JSON configuration files, which have no
+// comments: the generated file only holds the keys set.
+type jsonConfigCodec struct{}
+
+func (jsonConfigCodec) decode(data []byte) (map[interface{}]interface{}, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var root map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&root); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("unexpected data after the top-level object")
+	}
+	return normalizeConfigValue(root).(map[interface{}]interface{}), nil
+}
+
+func (jsonConfigCodec) encode(root map[interface{}]interface{}) ([]byte, error) {
+	if root == nil {
+		root = map[interface{}]interface{}{}
+	}
+	data, err := json.MarshalIndent(stringKeys(root), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (c jsonConfigCodec) render(name string, options []pluginapi.ConfigOption, values map[string]interface{}) ([]byte, error) {
+	root := make(map[interface{}]interface{})
+	for k, v := range values {
+		if err := setConfigNode(root, strings.Split(k, "."), v); err != nil {
+			return nil, err
+		}
+	}
+	return c.encode(root)
+}
+
+func (jsonConfigCodec) hasComments() bool { return false }
+
+type tomlConfigCodec struct{}
+
+func (tomlConfigCodec) decode(data []byte) (map[interface{}]interface{}, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeConfigValue(tree.ToMap()).(map[interface{}]interface{}), nil
+}
+
+func (tomlConfigCodec) encode(root map[interface{}]interface{}) ([]byte, error) {
+	m, _ := stringKeys(root).(map[string]interface{})
+	tree, err := toml.TreeFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+	s, err := tree.ToTomlString()
+	return []byte(s), err
+}
+
+// render writes the options of each section after a table header,
+// the top-level options come first as TOML requires.
+func (c tomlConfigCodec) render(name string, options []pluginapi.ConfigOption, values map[string]interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, defaultConfig, name, ConfigEnvKey(name))
+
+	root := newConfigTree(options)
+
+	var render func(n *configNode, path []string) error
+	render = func(n *configNode, path []string) error {
+		if len(path) > 0 {
+			fmt.Fprintf(&b, "\n[%s]\n", strings.Join(path, "."))
+		}
+		for _, o := range n.children {
+			if o.option == nil {
+				continue
+			}
+			b.WriteString("\n")
+			writeConfigDescription(&b, "", name, append(path, o.name), o.option)
+
+			if v, ok := values[o.option.Key]; ok {
+				data, err := c.encode(map[interface{}]interface{}{o.name: v})
+				if err != nil {
+					return fmt.Errorf("while encoding key %q: %s", o.option.Key, err)
+				}
+				b.Write(data)
+			} else if d, err := parseConfigValue(o.option.Type, o.option.Default); o.option.Default != "" && err == nil {
+				data, err := c.encode(map[interface{}]interface{}{o.name: d})
+				if err != nil {
+					return fmt.Errorf("while encoding default of key %q: %s", o.option.Key, err)
+				}
+				fmt.Fprintf(&b, "# %s", data)
+			} else {
+				fmt.Fprintf(&b, "# %s =\n", o.name)
+			}
+		}
+		for _, s := range n.children {
+			if s.option != nil {
+				continue
+			}
+			if err := render(s, append(path[:len(path):len(path)], s.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := render(root, nil); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (tomlConfigCodec) hasComments() bool { return true }
+
+// normalizeConfigValue converts the value v decoded from a JSON or a
+// TOML configuration file, or parsed by parseConfigValue, to the types
+// returned by the YAML decoder.
+func normalizeConfigValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalizeConfigValue(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = normalizeConfigValue(e)
+		}
+		return list
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = e
+		}
+		return list
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return normalizeConfigValue(i)
+		}
+		f, _ := v.Float64()
+		return f
+	case int64:
+		if int64(int(v)) == v {
+			return int(v)
+		}
+	}
+	return v
+}
+
+// stringKeys converts the mappings of the value v decoded from the
+// configuration file to map[string]interface{}, as expected by the
+// JSON and TOML encoders.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = stringKeys(e)
+		}
+		return list
+	}
+	return v
+}
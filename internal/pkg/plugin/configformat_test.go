@@ -0,0 +1,191 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestConfigCodecs(t *testing.T) {
+	root := map[interface{}]interface{}{
+		"verbose": true,
+		"ratio":   0.5,
+		"server": map[interface{}]interface{}{
+			"port":  80,
+			"hosts": []interface{}{"a.example.com", "b.example.com"},
+		},
+	}
+
+	for _, f := range pluginapi.ConfigFormats {
+		t.Run(string(f), func(t *testing.T) {
+			c, err := newConfigCodec(f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			data, err := c.encode(root)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			decoded, err := c.decode(data)
+			if err != nil {
+				t.Fatalf("unexpected error decoding:\n%s\n%s", data, err)
+			}
+			if !reflect.DeepEqual(decoded, root) {
+				t.Errorf("got %#v, expected %#v", decoded, root)
+			}
+
+			data, err = c.render("example.org/test", testConfigSchema, defaultConfigValues(testConfigSchema))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, err := validateConfig(c, testConfigSchema, data, true); err != nil {
+				t.Errorf("invalid default configuration:\n%s\n%s", data, err)
+			}
+		})
+	}
+
+	if _, err := newConfigCodec("ini"); err == nil {
+		t.Errorf("unexpected success with an unknown format")
+	}
+	if _, err := (jsonConfigCodec{}).decode([]byte(`{"a": 1} {}`)); err == nil {
+		t.Errorf("unexpected success with trailing data")
+	}
+}
+
+func TestConfigFormat(t *testing.T) {
+	for _, f := range []pluginapi.ConfigFormat{pluginapi.ConfigFormatJSON, pluginapi.ConfigFormatTOML} {
+		t.Run(string(f), func(t *testing.T) {
+			defer setTestRootDir(t)()
+
+			m := installTestMeta(t, "example.org/test")
+			m.ConfigSchema = testConfigSchema
+			m.ConfigFormat = f
+			if err := m.installConfig(nil, nil); err != nil {
+				t.Fatalf("while installing configuration: %s", err)
+			}
+			if err := m.installMeta(); err != nil {
+				t.Fatalf("while installing meta: %s", err)
+			}
+			if filepath.Base(m.ConfigPath) != "config."+string(f) {
+				t.Errorf("unexpected configuration file %s", m.ConfigPath)
+			}
+			if err := ValidateConfig(m.Name); err != nil {
+				t.Errorf("invalid default configuration: %s", err)
+			}
+
+			for _, kv := range [][2]string{
+				{"verbose", "true"},
+				{"server.port", "9090"},
+				{"server.hosts", "c.example.com, d.example.com"},
+			} {
+				if err := SetConfigKey(m.Name, kv[0], kv[1]); err != nil {
+					t.Fatalf("unexpected error setting %s: %s", kv[0], err)
+				}
+			}
+			if err := SetConfigKey(m.Name, "server.port", "http"); err == nil {
+				t.Errorf("unexpected success with a value of the wrong type")
+			}
+			if err := UnsetConfigKey(m.Name, "verbose"); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			values, err := GetConfig(m.Name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := map[string]interface{}{
+				"name":           "yes",
+				"server.port":    9090,
+				"server.timeout": "30s",
+				"server.hosts":   []interface{}{"c.example.com", "d.example.com"},
+			}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("got %v, expected %v", values, expected)
+			}
+
+			var c struct {
+				Server struct {
+					Port  int
+					Hosts []string
+				}
+			}
+			if err := LoadConfig(m.Name, &c); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.Server.Port != 9090 || len(c.Server.Hosts) != 2 {
+				t.Errorf("unexpected configuration %+v", c)
+			}
+
+			data, err := ioutil.ReadFile(m.ConfigPath)
+			if err != nil {
+				t.Fatalf("while reading configuration: %s", err)
+			}
+			if f == pluginapi.ConfigFormatTOML && !strings.Contains(string(data), "# Port to listen on\n") {
+				t.Errorf("option description dropped:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestConfigFormatUpgrade(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = testConfigSchema
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := ioutil.WriteFile(m.ConfigPath, []byte("server:\n  port: 9090\n"), 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	// the customized configuration is converted
+	upgrade := &Meta{Name: m.Name, ConfigSchema: testConfigSchema, ConfigFormat: pluginapi.ConfigFormatTOML}
+	if err := upgrade.installConfig(m, nil); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	if _, err := os.Stat(m.ConfigPath); !os.IsNotExist(err) {
+		t.Errorf("previous configuration file %s not removed", m.ConfigPath)
+	}
+	checkConfigStatus(t, upgrade, ConfigCustomized)
+
+	data, err := ioutil.ReadFile(upgrade.ConfigPath)
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	root, err := (tomlConfigCodec{}).decode(data)
+	if err != nil {
+		t.Fatalf("invalid configuration:\n%s\n%s", data, err)
+	}
+	if port := newConfigSchema(testConfigSchema).entries(root)["server.port"]; port != 9090 {
+		t.Errorf("got port %v, expected 9090", port)
+	}
+
+	// the default configuration is replaced by the new default
+	back := &Meta{Name: m.Name, ConfigSchema: testConfigSchema}
+	if err := os.Remove(upgrade.ConfigPath); err != nil {
+		t.Fatalf("while removing configuration: %s", err)
+	}
+	if err := upgrade.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := back.installConfig(upgrade, nil); err != nil {
+		t.Fatalf("while downgrading configuration: %s", err)
+	}
+	checkConfigStatus(t, back, ConfigDefault)
+
+	// unknown formats are rejected
+	if err := (&Meta{Name: m.Name, ConfigFormat: "ini"}).installConfig(nil, nil); err == nil {
+		t.Errorf("unexpected success with an unknown format")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// ConfigFault reports the first error found in the configuration
+// of a plugin as it's loaded.
+type ConfigFault struct {
+	// Path is the configuration file with the error, or the
+	// description of the merged configuration for the values
+	// violating the configuration schema.
+	Path string
+	// Err is the first error found.
+	Err string
+}
+
+func (f *ConfigFault) Error() string {
+	return fmt.Sprintf("invalid configuration %s: %s", f.Path, f.Err)
+}
+
+// reportedConfigFaults records the plugins whose invalid configuration
+// has been reported, it's reported once per process.
+var reportedConfigFaults = struct {
+	names map[string]bool
+	sync.Mutex
+}{names: make(map[string]bool)}
+
+// newConfigFault returns the fault reporting err, found in the
+// configuration path, with the first violation of a ConfigError.
+func newConfigFault(path string, err error) *ConfigFault {
+	var ce *ConfigError
+	if errors.As(err, &ce) && len(ce.Violations) > 0 {
+		return &ConfigFault{Path: path, Err: ce.Violations[0]}
+	}
+	return &ConfigFault{Path: path, Err: err.Error()}
+}
+
+// configFault validates the configuration of the plugin as it's loaded:
+// the syntax of its configuration file and drop-in files, then the merged
+// configuration against the schema declared in the manifest, if any. The
+// unknown keys are violations when strict is true. It returns the first
+// error found, or nil when the configuration is valid.
+func (m *Meta) configFault(strict bool) *ConfigFault {
+	c, err := m.configCodec()
+	if err != nil {
+		return newConfigFault(m.configName(), err)
+	}
+
+	root, layers, err := m.mergedConfig(c)
+	if err != nil {
+		var fe *configFileError
+		if errors.As(err, &fe) {
+			return newConfigFault(fe.path, fe.err)
+		}
+		return newConfigFault(m.configName(), err)
+	}
+	if len(m.ConfigSchema) == 0 {
+		return nil
+	}
+
+	if _, err := validateConfigRoot(m.ConfigSchema, root, strict); err != nil {
+		return newConfigFault(configDescription(layers), err)
+	}
+	return nil
+}
+
+// loadConfigFault returns the fault of the configuration of the plugin
+// as it's loaded, see configFault, mode being the "plugin config check"
+// mode. The plugin isn't loaded in strict mode, it's otherwise loaded
+// with its default configuration, the fault is reported with a single
+// warning the first time.
+func (m *Meta) loadConfigFault(mode string) *ConfigFault {
+	f := m.configFault(mode == configCheckStrict)
+	if f == nil {
+		return nil
+	}
+
+	reportedConfigFaults.Lock()
+	defer reportedConfigFaults.Unlock()
+
+	if !reportedConfigFaults.names[m.Name] {
+		reportedConfigFaults.names[m.Name] = true
+		if mode == configCheckStrict {
+			sylog.Warningf("Plugin %q has an %s, not loading it", m.Name, f)
+		} else {
+			sylog.Warningf("Plugin %q has an %s, loading it with its default configuration", m.Name, f)
+		}
+	}
+	return f
+}
+
+// ConfigHealth returns the first error found in the configuration of
+// the plugin when loading it, with the "plugin config check" mode set
+// in singularity.conf, or nil when the configuration is valid.
+func (m *Meta) ConfigHealth() error {
+	if f := m.configFault(configCheckMode() == configCheckStrict); f != nil {
+		return f
+	}
+	return nil
+}
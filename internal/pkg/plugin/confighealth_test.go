@@ -0,0 +1,142 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// installCorruptedConfig installs the plugin with the corrupted
+// configuration file of testdata.
+func installCorruptedConfig(t *testing.T) *Meta {
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = []pluginapi.ConfigOption{
+		{Key: "log-level", Type: pluginapi.ConfigTypeString},
+		{Key: "server.host", Type: pluginapi.ConfigTypeString},
+		{Key: "server.port", Type: pluginapi.ConfigTypeInt},
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	data, err := ioutil.ReadFile("testdata/config_corrupted.yaml")
+	if err != nil {
+		t.Fatalf("while reading corrupted configuration: %s", err)
+	}
+	if err := ioutil.WriteFile(m.configName(), data, 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+	return m
+}
+
+func TestConfigFault(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installCorruptedConfig(t)
+
+	for _, strict := range []bool{false, true} {
+		f := m.configFault(strict)
+		if f == nil {
+			t.Fatalf("no fault reported for the corrupted configuration")
+		}
+		if f.Path != m.configName() || f.Err != "line 6: found a tab character that violates indentation" {
+			t.Errorf("unexpected fault %q", f)
+		}
+	}
+
+	if err := ioutil.WriteFile(m.configName(), []byte("log-level: debug\nserver:\n  port: 80\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+	if f := m.configFault(true); f != nil {
+		t.Errorf("unexpected fault %q", f)
+	}
+
+	// the schema violations of the drop-in files are reported
+	// against the merged configuration, the first one only
+	if err := os.MkdirAll(m.configDirName(), 0755); err != nil {
+		t.Fatalf("while creating drop-in directory: %s", err)
+	}
+	dropIn := filepath.Join(m.configDirName(), "10-server.yaml")
+	if err := ioutil.WriteFile(dropIn, []byte("log-level: [debug]\nserver:\n  port: http\n"), 0644); err != nil {
+		t.Fatalf("while writing drop-in: %s", err)
+	}
+	f := m.configFault(false)
+	if f == nil {
+		t.Fatalf("no fault reported for the invalid drop-in")
+	}
+	if f.Path != m.configName()+" merged with "+m.configDirName() || f.Err != `key "log-level": expected string, got a list` {
+		t.Errorf("unexpected fault %q", f)
+	}
+
+	// the unknown keys are faults in strict mode only
+	if err := ioutil.WriteFile(dropIn, []byte("server:\n  prot: 80\n"), 0644); err != nil {
+		t.Fatalf("while writing drop-in: %s", err)
+	}
+	if f := m.configFault(false); f != nil {
+		t.Errorf("unexpected fault %q", f)
+	}
+	if f := m.configFault(true); f == nil || f.Err != `unknown key "server.prot"` {
+		t.Errorf("unexpected fault %v for an unknown key in strict mode", f)
+	}
+}
+
+func TestLoadConfigFault(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer func(orig func() string) { configCheckMode = orig }(configCheckMode)
+
+	m := installCorruptedConfig(t)
+	defaults := testConfig{LogLevel: "info", Server: testServerConfig{Host: "default.example.org"}}
+
+	tests := []struct {
+		name    string
+		mode    string
+		wantErr bool
+	}{
+		{name: "Warn", mode: configCheckWarn},
+		{name: "Strict", mode: configCheckStrict, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configCheckMode = func() string { return tt.mode }
+			reportedConfigFaults.names = make(map[string]bool)
+
+			// the fault is reported once but returned each time
+			for i := 0; i < 2; i++ {
+				if f := m.loadConfigFault(tt.mode); f == nil {
+					t.Errorf("no fault reported for the corrupted configuration")
+				}
+			}
+			if !reportedConfigFaults.names[m.Name] {
+				t.Errorf("fault not recorded as reported")
+			}
+
+			c := defaults
+			err := m.LoadConfig(&c)
+			var f *ConfigFault
+			if tt.wantErr && !errors.As(err, &f) {
+				t.Errorf("unexpected error %v, expected a configuration fault", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			// the plugin never gets the values read before the error
+			if !reflect.DeepEqual(c, defaults) {
+				t.Errorf("got configuration %+v, expected the defaults %+v", c, defaults)
+			}
+
+			if err := m.ConfigHealth(); err == nil {
+				t.Errorf("corrupted configuration reported as healthy")
+			}
+		})
+	}
+}
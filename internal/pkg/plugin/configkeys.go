@@ -0,0 +1,677 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/fs/lock"
+	"gopkg.in/yaml.v2"
+)
+
+// configKeyLineRegexp matches a line of a block mapping of the
+// configuration file, along with its indentation and its value.
+var configKeyLineRegexp = regexp.MustCompile(`^( *)([A-Za-z0-9_][A-Za-z0-9_-]*):(?:[ \t]+(.*))?$`)
+
+// GetConfig returns the values set in the configuration of the installed
+// plugin "name" by dotted key, its configuration file merged with its
+// drop-in files. The sections of the schema declared in the plugin
+// manifest are walked through, the nested mappings of a free-form
+// configuration are all walked through.
+func GetConfig(name string) (map[string]interface{}, error) {
+	values, _, err := getConfig(name)
+	return values, err
+}
+
+// GetConfigOrigins returns the values set in the configuration of the
+// installed plugin "name" as GetConfig does, along with the path of the
+// file setting each of them, the configuration file or a drop-in file.
+func GetConfigOrigins(name string) (map[string]interface{}, map[string]string, error) {
+	return getConfig(name)
+}
+
+func getConfig(name string) (map[string]interface{}, map[string]string, error) {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := meta.configCodec()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, layers, err := meta.mergedConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries map[string]interface{}
+	if len(meta.ConfigSchema) > 0 {
+		entries = newConfigSchema(meta.ConfigSchema).entries(root)
+	} else {
+		entries = freeFormConfigEntries(root)
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k, v := range entries {
+		if v == nil {
+			// unset key or empty section
+			delete(entries, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return entries, configOrigins(layers, keys), nil
+}
+
+// SetConfigKey sets the configuration key of the installed plugin
+// "name" to value, given with the syntax of the environment variables
+// overriding the configuration keys. With a configuration schema, the
+// key must be declared and the value is converted to the type of the
+// option, a free-form value is decoded as a YAML scalar so "true" is
+// stored as a boolean. A YAML configuration file is edited in place,
+// preserving its comments, the other formats are regenerated. The file
+// is written back atomically once validated, merged with the drop-in
+// files, see ValidateConfig. A key set by a drop-in file keeps the value
+// of the drop-in file, which is warned about.
+func SetConfigKey(name, key, value string) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	if len(meta.ConfigSchema) > 0 {
+		o, ok := newConfigSchema(meta.ConfigSchema).options[key]
+		if !ok {
+			return fmt.Errorf("unknown key %q", key)
+		}
+		if v, err = parseConfigValue(o.Type, value); err != nil {
+			return fmt.Errorf("key %q: %s", key, err)
+		}
+	} else {
+		if err := checkConfigKey(key); err != nil {
+			return err
+		}
+		v = parseFreeFormValue(value)
+	}
+
+	sylog.Debugf("Setting configuration key %q of plugin %q", key, meta.Name)
+
+	err = meta.editConfig(key, func(f *configFile, root map[interface{}]interface{}) error {
+		if err := setConfigNode(root, strings.Split(key, "."), v); err != nil || f == nil {
+			return err
+		}
+		return f.set(strings.Split(key, "."), v)
+	})
+	if err != nil {
+		return err
+	}
+
+	meta.warnConfigOverride(key)
+	return nil
+}
+
+// GetEffectiveConfig returns the values of the configuration keys of
+// the installed plugin "name" as GetConfig does, overridden by the user
+// configuration overlay and by the environment variables returned by
+// lookup as LoadConfig does. The keys
+// overridden are the options of the schema and the keys set in the
+// configuration file, the values are converted as done by SetConfigKey.
+func GetEffectiveConfig(name string, lookup func(string) (string, bool)) (map[string]interface{}, error) {
+	values, err := GetConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return nil, err
+	}
+	overlay, _, err := GetUserConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range overlay {
+		values[k] = v
+	}
+
+	keys := make(map[string]bool, len(values)+len(meta.ConfigSchema))
+	for k := range values {
+		keys[k] = true
+	}
+	for _, o := range meta.ConfigSchema {
+		keys[o.Key] = true
+	}
+
+	e := meta.configEnv(lookup)
+	for k := range keys {
+		value, ok, err := e.value(k, ConfigEnvKey(meta.Name, strings.Split(k, ".")...))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if o, ok := e.schema.options[k]; ok {
+			// already checked by value
+			v, _ := parseConfigValue(o.Type, value)
+			values[k] = normalizeConfigValue(v)
+		} else {
+			values[k] = parseFreeFormValue(value)
+		}
+	}
+	return values, nil
+}
+
+// parseFreeFormValue decodes value as a YAML scalar, so "true" is a
+// boolean, value is kept as a string if it doesn't decode to a scalar.
+func parseFreeFormValue(value string) interface{} {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(value), &v); err != nil || v == nil || !isScalar(v) {
+		return value
+	}
+	return v
+}
+
+// UnsetConfigKey removes the configuration key of the installed plugin
+// "name" from its configuration file, the sections left empty are
+// removed as well. A key declared by the configuration schema is
+// commented out with its default value instead, as in the file
+// generated at installation. The file is edited as done by
+// SetConfigKey, unsetting a key which isn't set does nothing.
+func UnsetConfigKey(name, key string) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+	if err := checkConfigKey(key); err != nil {
+		return err
+	}
+
+	elems := strings.Split(key, ".")
+	placeholder := ""
+	if o, ok := newConfigSchema(meta.ConfigSchema).options[key]; ok {
+		placeholder = strings.TrimSpace(fmt.Sprintf("# %s: %s", elems[len(elems)-1], o.Default))
+	}
+
+	sylog.Debugf("Unsetting configuration key %q of plugin %q", key, meta.Name)
+
+	err = meta.editConfig(key, func(f *configFile, root map[interface{}]interface{}) error {
+		if !unsetConfigNode(root, elems) {
+			return errConfigUnchanged
+		} else if f == nil {
+			return nil
+		}
+		return f.unset(elems, placeholder)
+	})
+	if err != nil {
+		return err
+	}
+
+	meta.warnConfigOverride(key)
+	return nil
+}
+
+// warnConfigOverride warns when the key edited in the configuration
+// file of the plugin is set by a drop-in file, which overrides it.
+func (m *Meta) warnConfigOverride(key string) {
+	_, origins, err := getConfig(m.Name)
+	if err != nil {
+		return
+	}
+	if origin, ok := origins[key]; ok && origin != m.configName() {
+		sylog.Warningf("Key %q of plugin %q is overridden by the drop-in configuration %s", key, m.Name, origin)
+	}
+}
+
+// errConfigUnchanged is returned by the edit functions of editConfig
+// when the configuration is left untouched.
+var errConfigUnchanged = errors.New("configuration unchanged")
+
+// editConfig applies edit to the configuration file of the plugin
+// while holding the plugin lock. edit updates both the lines of the file
+// and its decoded content root, if the edited lines don't decode to the
+// updated content, which happens with YAML constructs the line editor
+// doesn't handle (e.g. flow mappings), the file is regenerated from the
+// content and its comments are dropped. The configuration files in
+// other formats than YAML aren't edited line by line, f is nil and the
+// file is always regenerated. The file is replaced only once validated.
+func (m *Meta) editConfig(key string, edit func(f *configFile, root map[interface{}]interface{}) error) error {
+	c, err := m.configCodec()
+	if err != nil {
+		return err
+	}
+
+	release, err := m.lock()
+	if err != nil {
+		return fmt.Errorf("while locking plugin %q: %s", m.Name, err)
+	}
+	defer release()
+
+	perm := os.FileMode(0644)
+	data, err := ioutil.ReadFile(m.configName())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("while reading configuration file: %s", err)
+	} else if err == nil {
+		if fi, err := os.Stat(m.configName()); err == nil {
+			perm = fi.Mode().Perm()
+		}
+	}
+
+	root, err := c.decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s: %w", m.configName(), configSyntaxError(err))
+	} else if root == nil {
+		root = make(map[interface{}]interface{})
+	}
+
+	var f *configFile
+	if _, ok := c.(yamlConfigCodec); ok {
+		f = newConfigFile(data)
+	}
+	ferr := edit(f, root)
+	if ferr == errConfigUnchanged {
+		sylog.Infof("Key %q is not set in the configuration of plugin %q", key, m.Name)
+		return nil
+	} else if _, ok := ferr.(*configEditError); ferr != nil && !ok {
+		return ferr
+	}
+
+	if f != nil && ferr == nil {
+		expected, err := c.encode(root)
+		if err != nil {
+			return fmt.Errorf("while encoding configuration: %s", err)
+		}
+		edited := f.bytes()
+		if sameConfig(edited, expected) {
+			data = edited
+		} else {
+			ferr = fmt.Errorf("edited file doesn't match the configuration")
+		}
+	}
+	if f == nil || ferr != nil {
+		if ferr != nil {
+			sylog.Debugf("Could not edit %s in place: %s", m.configName(), ferr)
+		}
+		rendered, err := m.renderConfig(c, root)
+		if err != nil {
+			return err
+		}
+		if droppedComments(data, rendered) {
+			sylog.Warningf("Regenerating the configuration file %s of plugin %q, its comments are dropped", m.configName(), m.Name)
+		}
+		data = rendered
+	}
+
+	if len(m.ConfigSchema) > 0 {
+		// the edited file is validated merged with the drop-in files
+		layers, err := m.configLayers(c)
+		if err != nil {
+			return err
+		}
+		if layers[0].root, err = c.decode(data); err != nil {
+			return fmt.Errorf("invalid configuration: %w", configSyntaxError(err))
+		}
+		merged := make(map[interface{}]interface{})
+		for _, l := range layers {
+			mergeConfigRoot(merged, l.root)
+		}
+		warnings, err := validateConfigRoot(m.ConfigSchema, merged, configCheckMode() == configCheckStrict)
+		for _, w := range warnings {
+			sylog.Warningf("Plugin %q configuration %s: %s", m.Name, configDescription(layers), w)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	return writeFileAtomic(m.configName(), perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// renderConfig returns the configuration file of the plugin holding
+// the decoded content root, encoded by c. With a schema, the file is
+// generated from the schema as done at installation, the unknown keys
+// being dropped.
+func (m *Meta) renderConfig(c configCodec, root map[interface{}]interface{}) ([]byte, error) {
+	if len(m.ConfigSchema) == 0 {
+		return c.encode(root)
+	}
+
+	s := newConfigSchema(m.ConfigSchema)
+	entries := s.entries(root)
+	values := make(map[string]interface{}, len(entries))
+	for k, e := range entries {
+		if _, ok := s.options[k]; ok && e != nil {
+			values[k] = e
+		} else if !s.sections[k] {
+			sylog.Warningf("Dropping unknown key %q from the configuration of plugin %q", k, m.Name)
+		}
+	}
+	return c.render(m.Name, m.ConfigSchema, values)
+}
+
+// droppedComments returns whether comment lines of the configuration
+// file data are missing from the regenerated file rendered.
+func droppedComments(data, rendered []byte) bool {
+	kept := make(map[string]bool)
+	for _, line := range strings.Split(string(rendered), "\n") {
+		kept[strings.TrimSpace(line)] = true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") && !kept[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// lock takes the lock of the plugin, serializing the changes of its
+// files, and returns the function releasing it.
+func (m *Meta) lock() (func(), error) {
+	fd, err := lock.Exclusive(m.path())
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Release(fd) }, nil
+}
+
+// sameConfig returns whether the configuration files a and b decode to
+// the same content.
+func sameConfig(a, b []byte) bool {
+	var ma, mb map[interface{}]interface{}
+	if yaml.Unmarshal(a, &ma) != nil || yaml.Unmarshal(b, &mb) != nil {
+		return false
+	}
+	if len(ma) == 0 && len(mb) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(ma, mb)
+}
+
+// freeFormConfigEntries returns the values of the decoded configuration
+// root by dotted key, walking through all the mappings.
+func freeFormConfigEntries(root map[interface{}]interface{}) map[string]interface{} {
+	entries := make(map[string]interface{})
+
+	var walk func(prefix string, m map[interface{}]interface{})
+	walk = func(prefix string, m map[interface{}]interface{}) {
+		for k, v := range m {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if sub, ok := v.(map[interface{}]interface{}); ok {
+				walk(key, sub)
+				continue
+			}
+			entries[key] = v
+		}
+	}
+	walk("", root)
+
+	return entries
+}
+
+// configNodeKey returns the key of m named elem.
+func configNodeKey(m map[interface{}]interface{}, elem string) (interface{}, bool) {
+	for k := range m {
+		if fmt.Sprint(k) == elem {
+			return k, true
+		}
+	}
+	return elem, false
+}
+
+// setConfigNode sets the key elems of the decoded configuration root to
+// v, creating the missing sections.
+func setConfigNode(root map[interface{}]interface{}, elems []string, v interface{}) error {
+	m := root
+	for i, elem := range elems[:len(elems)-1] {
+		k, ok := configNodeKey(m, elem)
+		if !ok || m[k] == nil {
+			sub := make(map[interface{}]interface{})
+			m[k] = sub
+			m = sub
+			continue
+		}
+		sub, ok := m[k].(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("key %q is not a section", strings.Join(elems[:i+1], "."))
+		}
+		m = sub
+	}
+	k, _ := configNodeKey(m, elems[len(elems)-1])
+	if _, ok := m[k].(map[interface{}]interface{}); ok {
+		return fmt.Errorf("key %q is a section", strings.Join(elems, "."))
+	}
+	m[k] = v
+	return nil
+}
+
+// unsetConfigNode removes the key elems of the decoded configuration
+// root along with the sections left empty, it returns false if the key
+// isn't set.
+func unsetConfigNode(m map[interface{}]interface{}, elems []string) bool {
+	k, ok := configNodeKey(m, elems[0])
+	if !ok {
+		return false
+	}
+	if len(elems) == 1 {
+		delete(m, k)
+		return true
+	}
+	sub, ok := m[k].(map[interface{}]interface{})
+	if !ok || !unsetConfigNode(sub, elems[1:]) {
+		return false
+	}
+	if len(sub) == 0 {
+		delete(m, k)
+	}
+	return true
+}
+
+// configEditError reports a configuration file which can't be edited
+// line by line.
+type configEditError struct {
+	msg string
+}
+
+func (e *configEditError) Error() string {
+	return e.msg
+}
+
+// configFile holds the lines of a configuration file edited in place,
+// only the block mappings indented with spaces are handled.
+type configFile struct {
+	lines []string
+}
+
+func newConfigFile(data []byte) *configFile {
+	text := strings.TrimRight(string(data), "\n")
+	if text == "" {
+		return &configFile{}
+	}
+	return &configFile{lines: strings.Split(text, "\n")}
+}
+
+func (f *configFile) bytes() []byte {
+	if len(f.lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(f.lines, "\n") + "\n")
+}
+
+// isConfigContent returns whether line is neither blank nor a comment.
+func isConfigContent(line string) bool {
+	s := strings.TrimSpace(line)
+	return s != "" && !strings.HasPrefix(s, "#")
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// blockEnd returns the last content line of the block of the key at
+// line i, the items of a list may share the indentation of the key.
+func (f *configFile) blockEnd(i int) int {
+	indent := lineIndent(f.lines[i])
+	end := i
+	for j := i + 1; j < len(f.lines); j++ {
+		line := f.lines[j]
+		if !isConfigContent(line) {
+			continue
+		}
+		d := lineIndent(line)
+		item := strings.HasPrefix(strings.TrimSpace(line)+" ", "- ")
+		if d <= indent && !(d == indent && item) {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+// childIndent returns the indentation of the content lines from start
+// to end, or def if there is none.
+func (f *configFile) childIndent(start, end, def int) int {
+	for j := start; j <= end; j++ {
+		if isConfigContent(f.lines[j]) {
+			return lineIndent(f.lines[j])
+		}
+	}
+	return def
+}
+
+// find returns the line of the deepest key of elems found in the file,
+// along with the number of elements matched, it returns -1 when the
+// first element isn't found.
+func (f *configFile) find(elems []string) (int, int) {
+	line, depth := -1, 0
+	start, end := 0, len(f.lines)-1
+	for depth < len(elems) {
+		indent := f.childIndent(start, end, 0)
+		found := -1
+		for j := start; j <= end; j++ {
+			if lineIndent(f.lines[j]) != indent {
+				continue
+			}
+			m := configKeyLineRegexp.FindStringSubmatch(f.lines[j])
+			if m != nil && m[2] == elems[depth] {
+				found = j
+				break
+			}
+		}
+		if found < 0 {
+			break
+		}
+		line, depth = found, depth+1
+		start, end = found+1, f.blockEnd(found)
+	}
+	return line, depth
+}
+
+// replace replaces the lines from start to end excluded with lines.
+func (f *configFile) replace(start, end int, lines []string) {
+	f.lines = append(f.lines[:start], append(lines, f.lines[end:]...)...)
+}
+
+// set sets the key elems to v. A missing key is inserted at the end of
+// its section, or right after its commented out default generated at
+// installation if any.
+func (f *configFile) set(elems []string, v interface{}) error {
+	leaf := elems[len(elems)-1]
+	data, err := yaml.Marshal(map[string]interface{}{leaf: v})
+	if err != nil {
+		return fmt.Errorf("while encoding key %q: %s", strings.Join(elems, "."), err)
+	}
+	value := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	indented := func(lines []string, indent int) []string {
+		prefix := strings.Repeat(" ", indent)
+		out := make([]string, len(lines))
+		for i, l := range lines {
+			out[i] = prefix + l
+		}
+		return out
+	}
+
+	line, depth := f.find(elems)
+	if depth == len(elems) {
+		f.replace(line, f.blockEnd(line)+1, indented(value, lineIndent(f.lines[line])))
+		return nil
+	}
+
+	start, end, indent := 0, len(f.lines)-1, 0
+	if line >= 0 {
+		m := configKeyLineRegexp.FindStringSubmatch(f.lines[line])
+		if s := strings.TrimSpace(m[3]); s != "" && !strings.HasPrefix(s, "#") {
+			return &configEditError{fmt.Sprintf("section %q holds an inline value", strings.Join(elems[:depth], "."))}
+		}
+		start, end = line+1, f.blockEnd(line)
+		indent = f.childIndent(start, end, lineIndent(f.lines[line])+2)
+	}
+
+	pos := end + 1
+	if depth == len(elems)-1 {
+		// the comments following the block belong to the
+		// section up to the next content line
+		last := pos
+		for last < len(f.lines) && !isConfigContent(f.lines[last]) {
+			last++
+		}
+		commented := regexp.MustCompile(`^ {` + fmt.Sprint(indent) + `}# ` + regexp.QuoteMeta(leaf) + `:( .*)?$`)
+		for j := start; j < last; j++ {
+			if commented.MatchString(f.lines[j]) {
+				pos = j + 1
+				break
+			}
+		}
+	}
+
+	var lines []string
+	for _, elem := range elems[depth : len(elems)-1] {
+		lines = append(lines, strings.Repeat(" ", indent)+elem+":")
+		indent += 2
+	}
+	lines = append(lines, indented(value, indent)...)
+	f.replace(pos, pos, lines)
+	return nil
+}
+
+// unset removes the key elems along with the sections left empty, the
+// key is replaced by the placeholder comment if any.
+func (f *configFile) unset(elems []string, placeholder string) error {
+	line, depth := f.find(elems)
+	if depth != len(elems) {
+		return &configEditError{fmt.Sprintf("key %q not found", strings.Join(elems, "."))}
+	}
+	var lines []string
+	if placeholder != "" {
+		lines = []string{strings.Repeat(" ", lineIndent(f.lines[line])) + placeholder}
+	}
+	f.replace(line, f.blockEnd(line)+1, lines)
+
+	for n := len(elems) - 1; n > 0; n-- {
+		line, depth := f.find(elems[:n])
+		if depth != n || f.blockEnd(line) != line {
+			break
+		}
+		f.replace(line, line+1, nil)
+	}
+	return nil
+}
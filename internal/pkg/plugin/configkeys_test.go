@@ -0,0 +1,317 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConfigFileEdit(t *testing.T) {
+	const config = `# header
+verbose: false # inline
+
+server:
+  # the port
+  port: 80
+  hosts:
+  - a.example.com
+  - b.example.com
+
+  # timeout: 30s
+`
+
+	tests := []struct {
+		name     string
+		edit     func(f *configFile) error
+		expected string
+	}{
+		{
+			name: "Replace",
+			edit: func(f *configFile) error { return f.set([]string{"server", "port"}, 8080) },
+			expected: `# header
+verbose: false # inline
+
+server:
+  # the port
+  port: 8080
+  hosts:
+  - a.example.com
+  - b.example.com
+
+  # timeout: 30s
+`,
+		},
+		{
+			name: "ReplaceList",
+			edit: func(f *configFile) error { return f.set([]string{"server", "hosts"}, []string{"c"}) },
+			expected: `# header
+verbose: false # inline
+
+server:
+  # the port
+  port: 80
+  hosts:
+  - c
+
+  # timeout: 30s
+`,
+		},
+		{
+			name: "InsertAfterDefault",
+			edit: func(f *configFile) error { return f.set([]string{"server", "timeout"}, "1m") },
+			expected: `# header
+verbose: false # inline
+
+server:
+  # the port
+  port: 80
+  hosts:
+  - a.example.com
+  - b.example.com
+
+  # timeout: 30s
+  timeout: 1m
+`,
+		},
+		{
+			name: "InsertSections",
+			edit: func(f *configFile) error { return f.set([]string{"log", "file", "path"}, "/var/log/x") },
+			expected: config + `log:
+  file:
+    path: /var/log/x
+`,
+		},
+		{
+			name: "Unset",
+			edit: func(f *configFile) error { return f.unset([]string{"server", "hosts"}, "") },
+			expected: `# header
+verbose: false # inline
+
+server:
+  # the port
+  port: 80
+
+  # timeout: 30s
+`,
+		},
+		{
+			name: "UnsetPlaceholder",
+			edit: func(f *configFile) error { return f.unset([]string{"verbose"}, "# verbose: false") },
+			expected: `# header
+# verbose: false
+
+server:
+  # the port
+  port: 80
+  hosts:
+  - a.example.com
+  - b.example.com
+
+  # timeout: 30s
+`,
+		},
+		{
+			name: "UnsetLastOfSection",
+			edit: func(f *configFile) error {
+				if err := f.unset([]string{"server", "hosts"}, ""); err != nil {
+					return err
+				}
+				return f.unset([]string{"server", "port"}, "")
+			},
+			expected: `# header
+verbose: false # inline
+
+  # the port
+
+  # timeout: 30s
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newConfigFile([]byte(config))
+			if err := tt.edit(f); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := string(f.bytes()); got != tt.expected {
+				t.Errorf("got:\n%s\nexpected:\n%s", got, tt.expected)
+			}
+		})
+	}
+
+	f := newConfigFile([]byte("server: {port: 80}\n"))
+	if err := f.set([]string{"server", "port"}, 8080); err == nil {
+		t.Errorf("unexpected success editing a flow mapping")
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+
+	const config = "# keep me\nserver:\n  # and me\n  port: 80\n"
+	if err := ioutil.WriteFile(m.configName(), []byte(config), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+
+	for _, kv := range [][2]string{
+		{"verbose", "true"},
+		{"server.port", "8080"},
+		{"server.name", "example"},
+		{"ratio", "0.5"},
+	} {
+		if err := SetConfigKey(m.Name, kv[0], kv[1]); err != nil {
+			t.Fatalf("unexpected error setting %s: %s", kv[0], err)
+		}
+	}
+	if err := SetConfigKey(m.Name, "server", "x"); err == nil {
+		t.Errorf("unexpected success replacing a section")
+	}
+	if err := SetConfigKey(m.Name, "server.port.number", "1"); err == nil {
+		t.Errorf("unexpected success setting a key below a value")
+	}
+	if err := UnsetConfigKey(m.Name, "ratio"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := UnsetConfigKey(m.Name, "missing"); err != nil {
+		t.Errorf("unexpected error unsetting a missing key: %s", err)
+	}
+
+	values, err := GetConfig(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"verbose":     true,
+		"server.port": 8080,
+		"server.name": "example",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v, expected %v", values, expected)
+	}
+
+	data, err := ioutil.ReadFile(m.configName())
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	for _, s := range []string{"# keep me\n", "  # and me\n  port: 8080\n"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("configuration doesn't contain %q:\n%s", s, data)
+		}
+	}
+
+	// a flow mapping is regenerated
+	if err := ioutil.WriteFile(m.configName(), []byte("server: {port: 80}\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+	if err := SetConfigKey(m.Name, "server.port", "8080"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if values, _ := GetConfig(m.Name); values["server.port"] != 8080 {
+		t.Errorf("got %v, expected server.port 8080", values)
+	}
+}
+
+func TestConfigKeysSchema(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = testConfigSchema
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	// a string key holding "true" stays a string
+	if err := SetConfigKey(m.Name, "name", "true"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetConfigKey(m.Name, "verbose", "true"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetConfigKey(m.Name, "verbose", "maybe"); err == nil {
+		t.Errorf("unexpected success with a value of the wrong type")
+	}
+
+	values, err := GetConfig(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if values["name"] != "true" || values["verbose"] != true {
+		t.Errorf("unexpected configuration %v", values)
+	}
+
+	data, err := ioutil.ReadFile(m.configName())
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	if !strings.Contains(string(data), "# Enable verbose logging\n") {
+		t.Errorf("option description dropped:\n%s", data)
+	}
+
+	// unset keys are commented out with their
+	// default, where they are set again
+	if err := UnsetConfigKey(m.Name, "verbose"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := UnsetConfigKey(m.Name, "ratio"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if values, _ := GetConfig(m.Name); values["verbose"] != nil {
+		t.Errorf("verbose still set: %v", values)
+	}
+	if err := SetConfigKey(m.Name, "verbose", "false"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, err = ioutil.ReadFile(m.configName())
+	if err != nil {
+		t.Fatalf("while reading configuration: %s", err)
+	}
+	if !strings.Contains(string(data), "# verbose: false\nverbose: false\n") {
+		t.Errorf("verbose not set after its default:\n%s", data)
+	}
+}
+
+func TestConfigKeysConcurrent(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+
+	const n = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- SetConfigKey(m.Name, fmt.Sprintf("key%d", i), fmt.Sprint(i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	values, err := GetConfig(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(values) != n {
+		t.Errorf("got %d keys, expected %d: %v", len(values), n, values)
+	}
+}
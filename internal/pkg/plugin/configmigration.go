@@ -0,0 +1,257 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// namePreMigrationConfig is the prefix of the name of the copy of the
+// configuration file kept when it's migrated, it's followed by the
+// version of the plugin the configuration belonged to.
+const namePreMigrationConfig = "config.pre-"
+
+// configMigration is the configuration of the installed plugin
+// transformed by the migration declared in the manifest of the
+// upgrade.
+type configMigration struct {
+	// original is the configuration file before the migration.
+	original []byte
+	// data is the migrated configuration file.
+	data []byte
+	// changes lists the transformations applied.
+	changes []string
+}
+
+// checkConfigMigration returns the violations found in the configuration
+// migration of manifest, the keys it renames to and the keys it removes
+// are checked against the configuration schema.
+func checkConfigMigration(manifest pluginapi.Manifest) []string {
+	mig := manifest.ConfigMigration
+	if mig == nil {
+		return nil
+	}
+
+	var violations []string
+	s := newConfigSchema(manifest.Config)
+
+	from := make([]string, 0, len(mig.Renamed))
+	for k := range mig.Renamed {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+	for _, k := range from {
+		to := mig.Renamed[k]
+		if err := checkConfigKey(k); err != nil {
+			violations = append(violations, fmt.Sprintf("config migration: %s", err))
+		} else if err := checkConfigKey(to); err != nil {
+			violations = append(violations, fmt.Sprintf("config migration: %s", err))
+		} else if k == to {
+			violations = append(violations, fmt.Sprintf("config migration renames key %q to itself", k))
+		} else if _, ok := s.options[k]; ok {
+			violations = append(violations, fmt.Sprintf("config migration renames key %q which is in the config schema", k))
+		} else if _, ok := s.options[to]; !ok && len(manifest.Config) > 0 {
+			violations = append(violations, fmt.Sprintf("config migration renames key %q to %q which is not in the config schema", k, to))
+		}
+	}
+
+	for i, k := range mig.Removed {
+		if err := checkConfigKey(k); err != nil {
+			violations = append(violations, fmt.Sprintf("config migration: %s", err))
+		} else if containsString(mig.Removed[:i], k) {
+			violations = append(violations, fmt.Sprintf("config migration removes key %q twice", k))
+		} else if _, ok := mig.Renamed[k]; ok {
+			violations = append(violations, fmt.Sprintf("config migration both renames and removes key %q", k))
+		} else if _, ok := s.options[k]; ok {
+			violations = append(violations, fmt.Sprintf("config migration removes key %q which is in the config schema", k))
+		}
+	}
+
+	return violations
+}
+
+// migrateConfig applies the configuration migration declared in the
+// manifest to the configuration file of the previous version of the
+// plugin. Nothing is written, the migrated configuration is installed
+// by installConfig, so that a migration failing aborts the upgrade
+// before the installed plugin is modified. It returns nil if no
+// migration is declared or if it doesn't transform the configuration.
+func (m *Meta) migrateConfig(previous *Meta) (*configMigration, error) {
+	if m.configMigration == nil || previous == nil || !previous.hasConfig() {
+		return nil, nil
+	}
+
+	pc, err := previous.configCodec()
+	if err != nil {
+		return nil, err
+	}
+	original, err := ioutil.ReadFile(previous.configName())
+	if err != nil {
+		return nil, fmt.Errorf("while reading configuration of the installed plugin: %s", err)
+	}
+	root, err := pc.decode(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", previous.configName(), configSyntaxError(err))
+	}
+	if root == nil {
+		root = make(map[interface{}]interface{})
+	}
+
+	changes, err := m.migrateConfigRoot(previous, root)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	c, err := m.configCodec()
+	if err != nil {
+		return nil, err
+	}
+	data, err := m.renderConfig(c, root)
+	if err != nil {
+		return nil, fmt.Errorf("while writing migrated configuration: %s", err)
+	}
+
+	return &configMigration{original: original, data: data, changes: changes}, nil
+}
+
+// migrateConfigRoot applies the configuration migration declared in the
+// manifest to the decoded configuration root of the previous version of
+// the plugin, in place, and returns the transformations applied. The
+// options added since the previous version are set to their default.
+func (m *Meta) migrateConfigRoot(previous *Meta, root map[interface{}]interface{}) ([]string, error) {
+	var changes []string
+
+	from := make([]string, 0, len(m.configMigration.Renamed))
+	for k := range m.configMigration.Renamed {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+	for _, k := range from {
+		to := m.configMigration.Renamed[k]
+		v, ok := configNodeValue(root, strings.Split(k, "."))
+		if !ok {
+			continue
+		}
+		if _, ok := configNodeValue(root, strings.Split(to, ".")); ok {
+			return nil, fmt.Errorf("key %q renamed to %q is set under both names", k, to)
+		}
+		unsetConfigNode(root, strings.Split(k, "."))
+		if err := setConfigNode(root, strings.Split(to, "."), v); err != nil {
+			return nil, fmt.Errorf("while renaming key %q: %s", k, err)
+		}
+		changes = append(changes, fmt.Sprintf("renamed key %q to %q", k, to))
+	}
+
+	for _, k := range m.configMigration.Removed {
+		v, ok := configNodeValue(root, strings.Split(k, "."))
+		if !ok {
+			continue
+		}
+		unsetConfigNode(root, strings.Split(k, "."))
+		changes = append(changes, fmt.Sprintf("removed key %q, it was set to %v", k, v))
+	}
+
+	known := newConfigSchema(previous.ConfigSchema)
+	for _, o := range m.ConfigSchema {
+		if _, ok := known.options[o.Key]; ok || o.Default == "" {
+			continue
+		}
+		if _, ok := configNodeValue(root, strings.Split(o.Key, ".")); ok {
+			continue
+		}
+		// defaults are validated with the manifest
+		v, err := parseConfigValue(o.Type, o.Default)
+		if err != nil {
+			continue
+		}
+		if err := setConfigNode(root, strings.Split(o.Key, "."), v); err != nil {
+			return nil, fmt.Errorf("while adding key %q: %s", o.Key, err)
+		}
+		changes = append(changes, fmt.Sprintf("added key %q with default %s", o.Key, o.Default))
+	}
+
+	return changes, nil
+}
+
+// installMigratedConfig replaces the configuration file of the previous
+// version of the plugin by the migrated configuration, the configuration
+// before the migration is preserved as config.pre-<version>.
+func (m *Meta) installMigratedConfig(previous *Meta, migration *configMigration) error {
+	backup := filepath.Join(m.path(), preMigrationConfigName(previous.Version))
+	if err := ioutil.WriteFile(backup, migration.original, 0644); err != nil {
+		return fmt.Errorf("while preserving configuration of the installed plugin: %s", err)
+	}
+
+	if err := ioutil.WriteFile(m.ConfigPath, migration.data, 0644); err != nil {
+		return err
+	}
+	if previous.configName() != m.ConfigPath {
+		if err := os.Remove(previous.configName()); err != nil {
+			sylog.Warningf("Could not remove the configuration file %s: %s", previous.configName(), err)
+		}
+	}
+	// the migrated configuration is a customized one
+	// unless it's the default of this version
+	if fmt.Sprintf("%x", sha256.Sum256(migration.data)) != m.ConfigDefaultHash {
+		m.ConfigDefaultHash = previous.ConfigDefaultHash
+	}
+
+	sylog.Infof("Migrated the configuration file %s, the previous configuration is preserved in %s:\n  - %s",
+		m.ConfigPath, backup, strings.Join(migration.changes, "\n  - "))
+
+	if err := m.checkConfig(); err != nil {
+		sylog.Warningf("Plugin %q must be reconfigured: %s", m.Name, err)
+	}
+	return nil
+}
+
+// preMigrationConfigName returns the name of the copy of the
+// configuration file of the version of the plugin kept when it's
+// migrated.
+func preMigrationConfigName(version string) string {
+	version = strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, version)
+	if version == "" {
+		version = "unknown"
+	}
+	return namePreMigrationConfig + version
+}
+
+// configNodeValue returns the value of the key elems of the decoded
+// configuration root and whether it's set.
+func configNodeValue(root map[interface{}]interface{}, elems []string) (interface{}, bool) {
+	m := root
+	for _, elem := range elems[:len(elems)-1] {
+		k, ok := configNodeKey(m, elem)
+		if !ok {
+			return nil, false
+		}
+		if m, ok = m[k].(map[interface{}]interface{}); !ok {
+			return nil, false
+		}
+	}
+	k, ok := configNodeKey(m, elems[len(elems)-1])
+	if !ok {
+		return nil, false
+	}
+	return m[k], true
+}
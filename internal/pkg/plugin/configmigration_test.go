@@ -0,0 +1,152 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestCheckConfigMigration(t *testing.T) {
+	tests := []struct {
+		name       string
+		migration  *pluginapi.ConfigMigration
+		violations int
+	}{
+		{"None", nil, 0},
+		{"Valid", &pluginapi.ConfigMigration{Renamed: map[string]string{"listen-port": "server.port"}, Removed: []string{"debug"}}, 0},
+		{"InvalidKey", &pluginapi.ConfigMigration{Renamed: map[string]string{"listen..port": "server.port"}, Removed: []string{"de bug"}}, 2},
+		{"RenamedToItself", &pluginapi.ConfigMigration{Renamed: map[string]string{"server.port": "server.port"}}, 1},
+		{"RenamedFromSchema", &pluginapi.ConfigMigration{Renamed: map[string]string{"verbose": "server.port"}}, 1},
+		{"RenamedOutOfSchema", &pluginapi.ConfigMigration{Renamed: map[string]string{"listen-port": "port"}}, 1},
+		{"RemovedFromSchema", &pluginapi.ConfigMigration{Removed: []string{"verbose"}}, 1},
+		{"RemovedTwice", &pluginapi.ConfigMigration{Removed: []string{"debug", "debug"}}, 1},
+		{"RenamedAndRemoved", &pluginapi.ConfigMigration{Renamed: map[string]string{"debug": "verbose"}, Removed: []string{"debug"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := pluginapi.Manifest{Config: testConfigSchema, ConfigMigration: tt.migration}
+			if v := checkConfigMigration(manifest); len(v) != tt.violations {
+				t.Errorf("got violations %q, expected %d", v, tt.violations)
+			}
+		})
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	previous := installTestMeta(t, "example.org/test")
+	previous.Version = "1.0.0"
+	previous.ConfigSchema = []pluginapi.ConfigOption{
+		{Key: "listen-port", Type: pluginapi.ConfigTypeInt, Default: "8080"},
+		{Key: "debug", Type: pluginapi.ConfigTypeBool},
+		{Key: "server.timeout", Type: pluginapi.ConfigTypeDuration},
+	}
+	if err := previous.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	custom := []byte("listen-port: 9090\ndebug: true\nserver:\n  timeout: 10s\n")
+	if err := ioutil.WriteFile(previous.ConfigPath, custom, 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	newUpgrade := func(migration *pluginapi.ConfigMigration) *Meta {
+		return &Meta{
+			Name:            previous.Name,
+			Version:         "2.0.0",
+			ConfigSchema:    testConfigSchema,
+			configMigration: migration,
+		}
+	}
+
+	// no migration declared
+	migration, err := newUpgrade(nil).migrateConfig(previous)
+	if err != nil || migration != nil {
+		t.Errorf("unexpected migration %v: %v", migration, err)
+	}
+
+	// a key set under both names aborts the migration
+	if err := ioutil.WriteFile(previous.ConfigPath, append(custom, "  port: 80\n"...), 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+	upgrade := newUpgrade(&pluginapi.ConfigMigration{Renamed: map[string]string{"listen-port": "server.port"}})
+	if _, err := upgrade.migrateConfig(previous); err == nil {
+		t.Errorf("unexpected success with a key set under both names")
+	}
+	if err := ioutil.WriteFile(previous.ConfigPath, custom, 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	upgrade = newUpgrade(&pluginapi.ConfigMigration{
+		Renamed: map[string]string{"listen-port": "server.port"},
+		Removed: []string{"debug"},
+	})
+	migration, err = upgrade.migrateConfig(previous)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if migration == nil {
+		t.Fatalf("configuration not migrated")
+	}
+	// renamed, removed and the 3 options with a default added
+	if len(migration.changes) != 5 {
+		t.Errorf("unexpected changes %q", migration.changes)
+	}
+
+	if err := upgrade.installConfig(previous, migration); err != nil {
+		t.Fatalf("while upgrading configuration: %s", err)
+	}
+	checkConfigStatus(t, upgrade, ConfigCustomized)
+	if err := upgrade.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	values, err := GetConfig(upgrade.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]interface{}{
+		"verbose":        false,
+		"name":           "yes",
+		"server.port":    9090,
+		"server.timeout": "10s",
+		"server.hosts":   []interface{}{"a.example.com", "b.example.com"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %v, expected %v", values, expected)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(upgrade.path(), "config.pre-1.0.0"))
+	if err != nil {
+		t.Fatalf("previous configuration not preserved: %s", err)
+	}
+	if string(data) != string(custom) {
+		t.Errorf("got previous configuration %q, expected %q", data, custom)
+	}
+
+	// nothing left to migrate
+	if migration, err := upgrade.migrateConfig(upgrade); err != nil || migration != nil {
+		t.Errorf("unexpected migration %v: %v", migration, err)
+	}
+}
+
+func TestPreMigrationConfigName(t *testing.T) {
+	for version, expected := range map[string]string{
+		"v1.2.0": "config.pre-v1.2.0",
+		"":       "config.pre-unknown",
+		"1/2":    "config.pre-1_2",
+	} {
+		if name := preMigrationConfigName(version); name != expected {
+			t.Errorf("got %q for version %q, expected %q", name, version, expected)
+		}
+	}
+}
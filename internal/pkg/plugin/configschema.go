@@ -0,0 +1,473 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// maxConfigOptions is the maximum number of configuration options.
+	maxConfigOptions = 128
+	// maxConfigDescription is the maximum length of an option description.
+	maxConfigDescription = 1024
+
+	// configCheckWarn is the "plugin config check" directive value
+	// reporting the unknown configuration keys with a warning.
+	configCheckWarn = "warn"
+	// configCheckStrict is the "plugin config check" directive
+	// value refusing the unknown configuration keys.
+	configCheckStrict = "strict"
+)
+
+// configKeyElemRegexp matches the dot separated elements of a configuration key.
+var configKeyElemRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+
+// ConfigError reports all the violations of the configuration
+// schema found in a plugin configuration file.
+type ConfigError struct {
+	Violations []string
+}
+
+func (e *ConfigError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// configCheckMode returns the plugin configuration check
+// mode set in singularity.conf, the tests replace it.
+var configCheckMode = func() string {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, using %s plugin config check: %s", buildcfg.SINGULARITY_CONF_FILE, configCheckWarn, err)
+		return configCheckWarn
+	}
+	return c.PluginConfigCheck
+}
+
+// checkConfigSchema returns the violations found in the configuration
+// options declared in a manifest.
+func checkConfigSchema(options []pluginapi.ConfigOption) []string {
+	if len(options) > maxConfigOptions {
+		return []string{fmt.Sprintf("%d config options exceed the maximum of %d", len(options), maxConfigOptions)}
+	}
+
+	var violations []string
+	for i, o := range options {
+		if err := checkConfigKey(o.Key); err != nil {
+			violations = append(violations, err.Error())
+			continue
+		}
+		for _, prev := range options[:i] {
+			if prev.Key == o.Key {
+				violations = append(violations, fmt.Sprintf("duplicate config key %q", o.Key))
+			} else if strings.HasPrefix(o.Key, prev.Key+".") || strings.HasPrefix(prev.Key, o.Key+".") {
+				violations = append(violations, fmt.Sprintf("config keys %q and %q conflict, a key can't be both a value and a section", prev.Key, o.Key))
+			}
+		}
+		if !o.Type.Valid() {
+			violations = append(violations, fmt.Sprintf("config key %q has an unknown type %q", o.Key, o.Type))
+		} else if o.Default != "" {
+			if _, err := parseConfigValue(o.Type, o.Default); err != nil {
+				violations = append(violations, fmt.Sprintf("config key %q default: %s", o.Key, err))
+			}
+		}
+		if len(o.Description) > maxConfigDescription {
+			violations = append(violations, fmt.Sprintf("config key %q description length of %d bytes exceeds %d bytes", o.Key, len(o.Description), maxConfigDescription))
+		}
+		for _, r := range o.Description {
+			if r != '\n' && unicode.IsControl(r) {
+				violations = append(violations, fmt.Sprintf("config key %q description contains control characters", o.Key))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// checkConfigKey returns an error if key is not a valid configuration key.
+func checkConfigKey(key string) error {
+	for _, elem := range strings.Split(key, ".") {
+		if !configKeyElemRegexp.MatchString(elem) {
+			return fmt.Errorf("invalid config key %q", key)
+		}
+	}
+	return nil
+}
+
+// parseConfigValue converts value, with the syntax of the environment
+// variables overriding the configuration keys, to a value of type t as
+// written in the configuration file.
+func parseConfigValue(t pluginapi.ConfigType, value string) (interface{}, error) {
+	var v interface{}
+	var err error
+
+	switch t {
+	case pluginapi.ConfigTypeString:
+		v = value
+	case pluginapi.ConfigTypeBool:
+		v, err = strconv.ParseBool(value)
+	case pluginapi.ConfigTypeInt:
+		v, err = strconv.ParseInt(value, 0, 64)
+	case pluginapi.ConfigTypeFloat:
+		v, err = strconv.ParseFloat(value, 64)
+	case pluginapi.ConfigTypeDuration:
+		_, err = time.ParseDuration(value)
+		v = value
+	case pluginapi.ConfigTypeList:
+		list := []string{}
+		if value != "" {
+			for _, e := range strings.Split(value, ",") {
+				list = append(list, strings.TrimSpace(e))
+			}
+		}
+		v = list
+	default:
+		return nil, fmt.Errorf("unknown type %q", t)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("expected %s, got %q", t, value)
+	}
+	return v, nil
+}
+
+// checkConfigValue returns an error if the value v decoded from
+// the configuration file is not of type t. A missing value is valid.
+func checkConfigValue(t pluginapi.ConfigType, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+
+	valid := false
+	switch t {
+	case pluginapi.ConfigTypeString:
+		valid = isScalar(v)
+	case pluginapi.ConfigTypeBool:
+		_, valid = v.(bool)
+	case pluginapi.ConfigTypeInt:
+		valid = isInt(v)
+	case pluginapi.ConfigTypeFloat:
+		_, valid = v.(float64)
+		valid = valid || isInt(v)
+	case pluginapi.ConfigTypeDuration:
+		if s, ok := v.(string); ok {
+			_, err := time.ParseDuration(s)
+			valid = err == nil
+		}
+	case pluginapi.ConfigTypeList:
+		if list, ok := v.([]interface{}); ok {
+			valid = true
+			for _, e := range list {
+				valid = valid && isScalar(e)
+			}
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf("expected %s, got %s", t, describeConfigValue(v))
+	}
+	return nil
+}
+
+func isInt(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, uint64:
+		return true
+	}
+	return false
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// describeConfigValue returns a short description
+// of a value decoded from the configuration file.
+func describeConfigValue(v interface{}) string {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return "a section"
+	case []interface{}:
+		return "a list"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// configSchema indexes the configuration options by key
+// along with the sections containing them.
+type configSchema struct {
+	options  map[string]pluginapi.ConfigOption
+	sections map[string]bool
+}
+
+func newConfigSchema(options []pluginapi.ConfigOption) configSchema {
+	s := configSchema{
+		options:  make(map[string]pluginapi.ConfigOption, len(options)),
+		sections: make(map[string]bool),
+	}
+	for _, o := range options {
+		s.options[o.Key] = o
+		elems := strings.Split(o.Key, ".")
+		for i := 1; i < len(elems); i++ {
+			s.sections[strings.Join(elems[:i], ".")] = true
+		}
+	}
+	return s
+}
+
+// entries returns the entries of the decoded configuration root by
+// dotted key, the sections of the schema are walked through so the
+// entries returned are either options, unknown keys or sections with
+// an unexpected value.
+func (s configSchema) entries(root map[interface{}]interface{}) map[string]interface{} {
+	entries := make(map[string]interface{})
+
+	var walk func(prefix string, m map[interface{}]interface{})
+	walk = func(prefix string, m map[interface{}]interface{}) {
+		for k, v := range m {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if sub, ok := v.(map[interface{}]interface{}); ok && s.sections[key] {
+				walk(key, sub)
+				continue
+			}
+			entries[key] = v
+		}
+	}
+	walk("", root)
+
+	return entries
+}
+
+// configSyntaxError returns the decoding error err, which names
+// the offending line, as a ConfigError.
+func configSyntaxError(err error) *ConfigError {
+	return &ConfigError{Violations: []string{strings.TrimPrefix(err.Error(), "yaml: ")}}
+}
+
+// validateConfig validates the configuration file data, decoded by c,
+// against the options. Values of the wrong type are violations, unknown
+// keys are violations when strict is true and are returned as warnings
+// otherwise.
+func validateConfig(c configCodec, options []pluginapi.ConfigOption, data []byte, strict bool) ([]string, error) {
+	root, err := c.decode(data)
+	if err != nil {
+		return nil, configSyntaxError(err)
+	}
+	return validateConfigRoot(options, root, strict)
+}
+
+// validateConfigRoot validates the decoded configuration root against
+// the options as validateConfig does.
+func validateConfigRoot(options []pluginapi.ConfigOption, root map[interface{}]interface{}, strict bool) ([]string, error) {
+	s := newConfigSchema(options)
+	entries := s.entries(root)
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var warnings, violations []string
+	for _, k := range keys {
+		v := entries[k]
+		if o, ok := s.options[k]; ok {
+			if err := checkConfigValue(o.Type, v); err != nil {
+				violations = append(violations, fmt.Sprintf("key %q: %s", k, err))
+			}
+		} else if s.sections[k] {
+			if v != nil {
+				violations = append(violations, fmt.Sprintf("key %q: expected a section, got %s", k, describeConfigValue(v)))
+			}
+		} else if strict {
+			violations = append(violations, fmt.Sprintf("unknown key %q", k))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("unknown key %q", k))
+		}
+	}
+
+	if len(violations) > 0 {
+		return warnings, &ConfigError{Violations: violations}
+	}
+	return warnings, nil
+}
+
+// defaultConfigValues returns the default values of the options.
+func defaultConfigValues(options []pluginapi.ConfigOption) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, o := range options {
+		if o.Default == "" {
+			continue
+		}
+		// defaults are validated with the manifest
+		if v, err := parseConfigValue(o.Type, o.Default); err == nil {
+			values[o.Key] = v
+		}
+	}
+	return values
+}
+
+// configNode is a section or an option of the configuration
+// file, in the order of declaration of the options.
+type configNode struct {
+	name     string
+	option   *pluginapi.ConfigOption
+	children []*configNode
+}
+
+func (n *configNode) child(name string) *configNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &configNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// newConfigTree returns the tree of the sections and options
+// in the order of declaration of the options.
+func newConfigTree(options []pluginapi.ConfigOption) *configNode {
+	root := &configNode{}
+	for i := range options {
+		n := root
+		for _, elem := range strings.Split(options[i].Key, ".") {
+			n = n.child(elem)
+		}
+		n.option = &options[i]
+	}
+	return root
+}
+
+// writeConfigDescription writes the description of the option o at
+// path as comments indented by indent.
+func writeConfigDescription(b *bytes.Buffer, indent, name string, path []string, o *pluginapi.ConfigOption) {
+	if o.Description != "" {
+		for _, line := range strings.Split(strings.TrimSpace(o.Description), "\n") {
+			fmt.Fprintf(b, "%s# %s\n", indent, strings.TrimSpace(line))
+		}
+	}
+	fmt.Fprintf(b, "%s# Type: %s, environment variable: %s\n", indent, o.Type, ConfigEnvKey(name, path...))
+}
+
+// renderConfig returns the YAML configuration file of the plugin "name"
+// documenting the options, the options without value are commented
+// out with their default value if any.
+func renderConfig(name string, options []pluginapi.ConfigOption, values map[string]interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, defaultConfig, name, ConfigEnvKey(name))
+
+	root := newConfigTree(options)
+
+	var render func(n *configNode, path []string) error
+	render = func(n *configNode, path []string) error {
+		indent := strings.Repeat("  ", len(path))
+		path = append(path, n.name)
+
+		if n.option == nil {
+			fmt.Fprintf(&b, "\n%s%s:\n", indent, n.name)
+			for _, c := range n.children {
+				if err := render(c, path); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		o := n.option
+		b.WriteString("\n")
+		writeConfigDescription(&b, indent, name, path, o)
+
+		if v, ok := values[o.Key]; ok {
+			data, err := yaml.Marshal(map[string]interface{}{n.name: v})
+			if err != nil {
+				return fmt.Errorf("while encoding key %q: %s", o.Key, err)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+				fmt.Fprintf(&b, "%s%s\n", indent, line)
+			}
+		} else if o.Default != "" {
+			fmt.Fprintf(&b, "%s# %s: %s\n", indent, n.name, o.Default)
+		} else {
+			fmt.Fprintf(&b, "%s# %s:\n", indent, n.name)
+		}
+		return nil
+	}
+
+	for _, c := range root.children {
+		if err := render(c, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// checkConfig validates the configuration of the plugin, its
+// configuration file merged with its drop-in files, against the schema
+// declared in its manifest, the unknown keys are reported with a warning
+// unless the strict mode is set in singularity.conf. Plugins without a
+// schema have a free-form configuration, only the syntax of the files
+// is checked.
+func (m *Meta) checkConfig() error {
+	c, err := m.configCodec()
+	if err != nil {
+		return err
+	}
+
+	root, layers, err := m.mergedConfig(c)
+	if err != nil {
+		return err
+	}
+	if len(m.ConfigSchema) == 0 {
+		return nil
+	}
+
+	warnings, err := validateConfigRoot(m.ConfigSchema, root, configCheckMode() == configCheckStrict)
+	for _, w := range warnings {
+		sylog.Warningf("Plugin %q configuration %s: %s", m.Name, configDescription(layers), w)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid configuration %s: %w", configDescription(layers), err)
+	}
+	return nil
+}
+
+// ValidateConfig validates the configuration file of the installed
+// plugin "name", merged with its drop-in files, as done when enabling
+// it, so a configuration edited by hand can be checked ahead of time,
+// enabled plugin or not.
+func ValidateConfig(name string) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	return meta.checkConfig()
+}
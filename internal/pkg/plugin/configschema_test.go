@@ -0,0 +1,277 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"gopkg.in/yaml.v2"
+)
+
+var testConfigSchema = []pluginapi.ConfigOption{
+	{Key: "verbose", Type: pluginapi.ConfigTypeBool, Default: "false", Description: "Enable verbose logging"},
+	{Key: "server.port", Type: pluginapi.ConfigTypeInt, Default: "8080", Description: "Port to listen on"},
+	{Key: "server.timeout", Type: pluginapi.ConfigTypeDuration, Default: "30s"},
+	{Key: "server.hosts", Type: pluginapi.ConfigTypeList, Default: "a.example.com, b.example.com"},
+	{Key: "ratio", Type: pluginapi.ConfigTypeFloat},
+	{Key: "name", Type: pluginapi.ConfigTypeString, Default: "yes"},
+}
+
+func TestCheckConfigSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []pluginapi.ConfigOption
+		violations int
+	}{
+		{"Valid", testConfigSchema, 0},
+		{"InvalidKey", []pluginapi.ConfigOption{{Key: "server..port", Type: pluginapi.ConfigTypeInt}}, 1},
+		{"UnknownType", []pluginapi.ConfigOption{{Key: "port", Type: "integer"}}, 1},
+		{"InvalidDefault", []pluginapi.ConfigOption{{Key: "port", Type: pluginapi.ConfigTypeInt, Default: "http"}}, 1},
+		{"Duplicate", []pluginapi.ConfigOption{{Key: "port", Type: pluginapi.ConfigTypeInt}, {Key: "port", Type: pluginapi.ConfigTypeInt}}, 1},
+		{"Conflict", []pluginapi.ConfigOption{{Key: "server", Type: pluginapi.ConfigTypeString}, {Key: "server.port", Type: pluginapi.ConfigTypeInt}}, 1},
+		{"Description", []pluginapi.ConfigOption{{Key: "port", Type: pluginapi.ConfigTypeInt, Description: "\x1b[31m"}}, 1},
+		{"TooMany", make([]pluginapi.ConfigOption, maxConfigOptions+1), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := checkConfigSchema(tt.options); len(v) != tt.violations {
+				t.Errorf("got violations %q, expected %d", v, tt.violations)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		strict     bool
+		warnings   int
+		violations []string
+	}{
+		{
+			name: "Valid",
+			data: "verbose: true\nserver:\n  port: 80\n  timeout: 1m\n  hosts: [a, b]\nratio: 1\nname: 42\n",
+		},
+		{
+			name: "Empty",
+			data: "# nothing set\n",
+		},
+		{
+			name:       "WrongTypes",
+			data:       "verbose: maybe\nserver:\n  port: http\n  timeout: 10\n  hosts: {a: b}\n",
+			violations: []string{`key "server.hosts": expected list, got a section`, `key "server.port": expected int, got "http"`, `key "server.timeout": expected duration, got 10`, `key "verbose": expected bool, got "maybe"`},
+		},
+		{
+			name:       "NotSection",
+			data:       "server: 80\n",
+			violations: []string{`key "server": expected a section, got 80`},
+		},
+		{
+			name:     "UnknownKeys",
+			data:     "verbos: true\nserver:\n  prot: 80\n",
+			warnings: 2,
+		},
+		{
+			name:       "UnknownKeysStrict",
+			data:       "verbos: true\n",
+			strict:     true,
+			violations: []string{`unknown key "verbos"`},
+		},
+		{
+			name:       "Malformed",
+			data:       "server: [\n",
+			violations: []string{"line 1: did not find expected node content"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := validateConfig(yamlConfigCodec{}, testConfigSchema, []byte(tt.data), tt.strict)
+			if len(warnings) != tt.warnings {
+				t.Errorf("got warnings %q, expected %d", warnings, tt.warnings)
+			}
+
+			var violations []string
+			if err != nil {
+				cerr, ok := err.(*ConfigError)
+				if !ok {
+					t.Fatalf("unexpected error type %T: %s", err, err)
+				}
+				violations = cerr.Violations
+			}
+			if !reflect.DeepEqual(violations, tt.violations) {
+				t.Errorf("got violations %q, expected %q", violations, tt.violations)
+			}
+		})
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	data, err := renderConfig("example.org/test", testConfigSchema, defaultConfigValues(testConfigSchema))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := validateConfig(yamlConfigCodec{}, testConfigSchema, data, true); err != nil {
+		t.Fatalf("generated configuration is invalid: %s\n%s", err, data)
+	}
+
+	var c struct {
+		Verbose bool
+		Server  struct {
+			Port    int
+			Timeout string
+			Hosts   []string
+		}
+		Ratio *float64
+		Name  string
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		t.Fatalf("while decoding generated configuration: %s", err)
+	}
+	if c.Verbose || c.Server.Port != 8080 || c.Server.Timeout != "30s" || c.Ratio != nil || c.Name != "yes" {
+		t.Errorf("unexpected generated values %+v", c)
+	}
+	if expected := []string{"a.example.com", "b.example.com"}; !reflect.DeepEqual(c.Server.Hosts, expected) {
+		t.Errorf("got hosts %q, expected %q", c.Server.Hosts, expected)
+	}
+
+	for _, s := range []string{
+		"# Port to listen on\n",
+		"# Type: int, environment variable: SINGULARITY_PLUGINCFG_EXAMPLE_ORG_TEST_SERVER_PORT\n",
+		"# ratio:\n",
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("generated configuration doesn't contain %q:\n%s", s, data)
+		}
+	}
+}
+
+func TestSetConfigKey(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+
+	// free-form configuration
+	if err := SetConfigKey(m.Name, "verbose", "true"); err != nil {
+		t.Errorf("unexpected error without configuration schema: %s", err)
+	}
+
+	m.ConfigSchema = testConfigSchema
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing configuration: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	if err := SetConfigKey(m.Name, "server.port", "9090"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetConfigKey(m.Name, "ratio", "0.5"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetConfigKey(m.Name, "server.port", "http"); err == nil || !strings.Contains(err.Error(), `key "server.port": expected int`) {
+		t.Errorf("unexpected error for a value of the wrong type: %v", err)
+	}
+	if err := SetConfigKey(m.Name, "server.prot", "80"); err == nil {
+		t.Errorf("unexpected success with an unknown key")
+	}
+
+	var c struct {
+		Verbose bool
+		Server  struct {
+			Port int
+		}
+		Ratio float64
+	}
+	if err := m.LoadConfig(&c); err != nil {
+		t.Fatalf("while loading configuration: %s", err)
+	}
+	if c.Verbose || c.Server.Port != 9090 || c.Ratio != 0.5 {
+		t.Errorf("unexpected configuration %+v", c)
+	}
+	checkConfigStatus(t, m, ConfigCustomized)
+
+	// a configuration edited by hand with a value of
+	// the wrong type prevents the plugin to be enabled
+	if err := Disable(m.Name); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := ioutil.WriteFile(m.configName(), []byte("server:\n  port: http\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+	if err := Enable(m.Name, false); err == nil {
+		t.Errorf("unexpected success enabling a plugin with an invalid configuration")
+	} else if !strings.Contains(err.Error(), `key "server.port"`) {
+		t.Errorf("error %q doesn't name the invalid key", err)
+	}
+	if err := ValidateConfig(m.Name); err == nil {
+		t.Errorf("unexpected success checking an invalid configuration")
+	}
+
+	// forced, and disabled whatever its configuration
+	if err := Enable(m.Name, true); err != nil {
+		t.Errorf("unexpected error forcing an invalid configuration: %s", err)
+	}
+	if err := Disable(m.Name); err != nil {
+		t.Errorf("unexpected error disabling a plugin with an invalid configuration: %s", err)
+	}
+
+	if err := ioutil.WriteFile(m.configName(), []byte("server:\n  port: 80\n  prot: 80\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+	if err := Enable(m.Name, false); err != nil {
+		t.Errorf("unexpected error enabling a plugin with an unknown key: %s", err)
+	}
+}
+
+func TestValidateFreeFormConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+	if err := Disable(m.Name); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"Valid", "verbose: true\nserver:\n  port: 80\n", ""},
+		{"Empty", "# comments only\n", ""},
+		{"BadSyntax", "verbose: true\nserver: [80\n", "line"},
+		{"NotAMapping", "- verbose\n", "cannot unmarshal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(m.configName(), []byte(tt.data), 0644); err != nil {
+				t.Fatalf("while writing configuration: %s", err)
+			}
+			err := ValidateConfig(m.Name)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, expected an error containing %q", err, tt.wantErr)
+			}
+			if err := Enable(m.Name, false); err == nil {
+				t.Errorf("unexpected success enabling a plugin with an invalid configuration")
+			}
+		})
+	}
+}
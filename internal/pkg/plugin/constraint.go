@@ -0,0 +1,169 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// comparison operators of a version constraint, the
+// longest operators must be matched first
+var constraintOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// versionComparison compares a version to a possibly partial one.
+type versionComparison struct {
+	op string
+	// lo is the lowest version matching the partial version
+	lo semver.Version
+	// hi is the lowest version above the partial version, it's
+	// unset when the partial version is a full version
+	hi *semver.Version
+}
+
+// versionConstraint is a list of comparisons which must all be
+// satisfied by a version, an empty constraint is satisfied by any
+// version.
+type versionConstraint []versionComparison
+
+// parseConstraint parses a version constraint as documented by
+// the Version field of the plugin Dependency type.
+func parseConstraint(s string) (versionConstraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var c versionConstraint
+
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("invalid version constraint %q: empty comparison", s)
+		}
+
+		op := "="
+		for _, o := range constraintOperators {
+			if strings.HasPrefix(term, o) {
+				op = o
+				term = strings.TrimSpace(term[len(o):])
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+
+		cmp, err := parsePartialVersion(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %s", s, err)
+		}
+		cmp.op = op
+		c = append(c, cmp)
+	}
+
+	return c, nil
+}
+
+// parsePartialVersion parses a version which may have its minor
+// and patch numbers missing, optionally prefixed by "v".
+func parsePartialVersion(s string) (versionComparison, error) {
+	var cmp versionComparison
+
+	v := strings.TrimPrefix(s, "v")
+	core := v
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		core = v[:i]
+	}
+
+	switch n := len(strings.Split(core, ".")); {
+	case n == 3:
+		lo, err := semver.Parse(v)
+		if err != nil {
+			return cmp, fmt.Errorf("%q is not a semantic version: %s", s, err)
+		}
+		cmp.lo = lo
+	case n < 3 && len(core) == len(v):
+		lo, err := semver.Parse(v + strings.Repeat(".0", 3-n))
+		if err != nil {
+			return cmp, fmt.Errorf("%q is not a semantic version: %s", s, err)
+		}
+		hi := semver.Version{Major: lo.Major + 1}
+		if n == 2 {
+			hi = semver.Version{Major: lo.Major, Minor: lo.Minor + 1}
+		}
+		cmp.lo, cmp.hi = lo, &hi
+	default:
+		return cmp, fmt.Errorf("%q is not a semantic version", s)
+	}
+
+	return cmp, nil
+}
+
+// check returns whether the version v satisfies the comparison.
+func (c versionComparison) check(v semver.Version) bool {
+	if c.hi == nil {
+		switch c.op {
+		case "=":
+			return v.EQ(c.lo)
+		case "!=":
+			return !v.EQ(c.lo)
+		case ">":
+			return v.GT(c.lo)
+		case ">=":
+			return v.GTE(c.lo)
+		case "<":
+			return v.LT(c.lo)
+		case "<=":
+			return v.LTE(c.lo)
+		}
+		return false
+	}
+
+	in := v.GTE(c.lo) && v.LT(*c.hi)
+	switch c.op {
+	case "=":
+		return in
+	case "!=":
+		return !in
+	case ">":
+		return v.GTE(*c.hi)
+	case ">=":
+		return v.GTE(c.lo)
+	case "<":
+		return v.LT(c.lo)
+	case "<=":
+		return v.LT(*c.hi)
+	}
+	return false
+}
+
+// check returns whether the version v satisfies all the comparisons
+// of the constraint. A pre-release version is only considered if one
+// of the comparisons is against a pre-release of the same version.
+func (c versionConstraint) check(v semver.Version) bool {
+	if len(v.Pre) > 0 {
+		allowed := false
+		for _, cmp := range c {
+			lo := cmp.lo
+			if len(lo.Pre) > 0 && lo.Major == v.Major && lo.Minor == v.Minor && lo.Patch == v.Patch {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	for _, cmp := range c {
+		if !cmp.check(v) {
+			return false
+		}
+	}
+	return true
+}
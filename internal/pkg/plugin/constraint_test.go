@@ -0,0 +1,152 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"testing"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint  string
+		expectError bool
+	}{
+		{constraint: ""},
+		{constraint: "  "},
+		{constraint: "1.2.0"},
+		{constraint: "v1.2.0"},
+		{constraint: "=1.2.0"},
+		{constraint: "== 1.2.0"},
+		{constraint: ">=1.2.0, <2"},
+		{constraint: ">= 1.2.0-rc.1,<=1.3"},
+		{constraint: "!=1.2.1"},
+		{constraint: "1"},
+		{constraint: "1.2.0+build.1"},
+		{constraint: ">=1.2.0,", expectError: true},
+		{constraint: ",1.2.0", expectError: true},
+		{constraint: ">=", expectError: true},
+		{constraint: "~1.2", expectError: true},
+		{constraint: "=>1.2", expectError: true},
+		{constraint: "1.2-rc.1", expectError: true},
+		{constraint: "1.2.3.4", expectError: true},
+		{constraint: "1.x", expectError: true},
+		{constraint: "latest", expectError: true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseConstraint(tt.constraint)
+		if err != nil && !tt.expectError {
+			t.Errorf("unexpected error for constraint %q: %s", tt.constraint, err)
+		} else if err == nil && tt.expectError {
+			t.Errorf("unexpected success for constraint %q", tt.constraint)
+		}
+	}
+}
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		// empty constraint
+		{constraint: "", version: "0.0.1", expected: true},
+		{constraint: "", version: "1.0.0-rc.1", expected: true},
+		// exact versions
+		{constraint: "1.2.0", version: "1.2.0", expected: true},
+		{constraint: "1.2.0", version: "v1.2.0", expected: true},
+		{constraint: "=1.2.0", version: "1.2.1", expected: false},
+		{constraint: "1.2.0", version: "1.2.0+build.2", expected: true},
+		{constraint: "!=1.2.0", version: "1.2.0", expected: false},
+		{constraint: "!=1.2.0", version: "1.2.1", expected: true},
+		// full version comparisons
+		{constraint: ">1.2.0", version: "1.2.0", expected: false},
+		{constraint: ">1.2.0", version: "1.2.1", expected: true},
+		{constraint: ">=1.2.0", version: "1.2.0", expected: true},
+		{constraint: ">=1.2.0", version: "1.1.9", expected: false},
+		{constraint: "<1.2.0", version: "1.1.9", expected: true},
+		{constraint: "<1.2.0", version: "1.2.0", expected: false},
+		{constraint: "<=1.2.0", version: "1.2.0", expected: true},
+		{constraint: "<=1.2.0", version: "1.2.1", expected: false},
+		// partial versions
+		{constraint: "1.2", version: "1.2.0", expected: true},
+		{constraint: "1.2", version: "1.2.9", expected: true},
+		{constraint: "1.2", version: "1.3.0", expected: false},
+		{constraint: "1", version: "1.9.0", expected: true},
+		{constraint: "1", version: "2.0.0", expected: false},
+		{constraint: "!=1.2", version: "1.2.5", expected: false},
+		{constraint: "!=1.2", version: "1.3.0", expected: true},
+		{constraint: ">1.2", version: "1.2.9", expected: false},
+		{constraint: ">1.2", version: "1.3.0", expected: true},
+		{constraint: ">=1.2", version: "1.2.0", expected: true},
+		{constraint: ">=1.2", version: "1.1.9", expected: false},
+		{constraint: "<1.2", version: "1.1.9", expected: true},
+		{constraint: "<1.2", version: "1.2.0", expected: false},
+		{constraint: "<=1.2", version: "1.2.9", expected: true},
+		{constraint: "<=1.2", version: "1.3.0", expected: false},
+		{constraint: "<2", version: "1.99.99", expected: true},
+		{constraint: "<2", version: "2.0.0", expected: false},
+		// ranges
+		{constraint: ">=1.2.0, <2", version: "1.2.0", expected: true},
+		{constraint: ">=1.2.0, <2", version: "1.9.3", expected: true},
+		{constraint: ">=1.2.0, <2", version: "2.0.0", expected: false},
+		{constraint: ">=1.2.0, <2", version: "1.1.0", expected: false},
+		{constraint: ">=1.2.0, <2, !=1.5.0", version: "1.5.0", expected: false},
+		{constraint: ">1.2.0, <1.2.0", version: "1.2.0", expected: false},
+		// open-ended ranges
+		{constraint: ">=1.2.0", version: "42.0.0", expected: true},
+		{constraint: ">0", version: "1.0.0", expected: true},
+		{constraint: ">0", version: "0.9.0", expected: false},
+		{constraint: "<1", version: "0.0.1", expected: true},
+		// pre-releases
+		{constraint: ">=1.2.0", version: "1.3.0-rc.1", expected: false},
+		{constraint: "<2", version: "2.0.0-rc.1", expected: false},
+		{constraint: "1.3", version: "1.3.0-rc.1", expected: false},
+		{constraint: ">=1.3.0-rc.0", version: "1.3.0-rc.1", expected: true},
+		{constraint: ">=1.3.0-rc.2", version: "1.3.0-rc.1", expected: false},
+		{constraint: ">=1.3.0-rc.0", version: "1.3.0", expected: true},
+		{constraint: ">=1.3.0-rc.0", version: "1.4.0-rc.1", expected: false},
+		{constraint: ">=1.3.0-rc.0, <1.3.0", version: "1.3.0-beta", expected: false},
+		{constraint: ">=1.3.0-alpha, <1.3.0", version: "1.3.0-beta", expected: true},
+		{constraint: "1.3.0-rc.1", version: "1.3.0-rc.1", expected: true},
+		{constraint: "<1.3.0", version: "1.3.0-rc.1", expected: false},
+	}
+
+	for _, tt := range tests {
+		c, err := parseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("unexpected error for constraint %q: %s", tt.constraint, err)
+		}
+		if got := satisfies(tt.version, c); got != tt.expected {
+			t.Errorf("version %q with constraint %q: got %v, expected %v", tt.version, tt.constraint, got, tt.expected)
+		}
+	}
+}
+
+func TestConstraintMissingVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{constraint: "", version: "", expected: true},
+		{constraint: "", version: "latest", expected: true},
+		{constraint: ">=1.0.0", version: "", expected: false},
+		{constraint: ">=1.0.0", version: "latest", expected: false},
+		{constraint: "!=1.0.0", version: "", expected: false},
+		{constraint: "<2", version: "1.2", expected: false},
+	}
+
+	for _, tt := range tests {
+		c, err := parseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("unexpected error for constraint %q: %s", tt.constraint, err)
+		}
+		if got := satisfies(tt.version, c); got != tt.expected {
+			t.Errorf("version %q with constraint %q: got %v, expected %v", tt.version, tt.constraint, got, tt.expected)
+		}
+	}
+}
@@ -30,14 +30,26 @@ import (
 	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
 
+// version is the semantic version of the plugin, it can be
+// stamped with "singularity plugin compile --version".
+var version = "0.1.0"
+
 // Plugin is the only variable which a plugin MUST export.
 // This symbol is accessed by the plugin framework to initialize the plugin
 var Plugin = pluginapi.Plugin{
 	Manifest: pluginapi.Manifest{
 		Name:        "%s",
 		Author:      "Put your name or mail here",
-		Version:     "0.1.0",
+		Version:     version,
 		Description: "Put a nice description",
+		APIVersion:  pluginapi.APIVersion,
+		// Declare the capabilities covering the callbacks registered
+		// below (eg: pluginapi.CapabilityCLICommands) and the callbacks
+		// themselves (eg: "cli.Command")
+		Capabilities: []pluginapi.Capability{},
+		Hooks:        []string{},
+		// Tag the plugin with lowercase keywords (eg: "gpu")
+		Keywords: []string{},
 	},
 	Callbacks: []pluginapi.Callback{},
 	Install:   installCallback,
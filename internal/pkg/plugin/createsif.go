@@ -0,0 +1,281 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/signing"
+	"golang.org/x/crypto/openpgp"
+)
+
+// createOptions are the options of CreateSIF.
+type createOptions struct {
+	compression string
+	changelog   string
+	config      string
+	sourceDir   string
+	assets      []createAsset
+	signers     []*openpgp.Entity
+}
+
+// createAsset is a file added as an asset by CreateSIF.
+type createAsset struct {
+	file string
+	path string
+}
+
+// CreateOp is an option of CreateSIF.
+type CreateOp func(*createOptions)
+
+// WithCompression stores the plugin binary object compressed with
+// compression, either gzip or zstd.
+func WithCompression(compression string) CreateOp {
+	return func(o *createOptions) {
+		o.compression = compression
+	}
+}
+
+// WithChangelog ships the file as the plugin release notes.
+func WithChangelog(file string) CreateOp {
+	return func(o *createOptions) {
+		o.changelog = file
+	}
+}
+
+// WithConfig ships the file as the template of the configuration file
+// generated when the plugin is installed, written in the configuration
+// format declared by the manifest.
+func WithConfig(file string) CreateOp {
+	return func(o *createOptions) {
+		o.config = file
+	}
+}
+
+// WithSourceDir records dir as the plugin source directory, it's
+// used to recompile the installed plugin (see Rebuild).
+func WithSourceDir(dir string) CreateOp {
+	return func(o *createOptions) {
+		o.sourceDir = dir
+	}
+}
+
+// WithAsset adds the file as an asset extracted to path, relative
+// to the plugin assets directory, when the plugin is installed.
+func WithAsset(file, path string) CreateOp {
+	return func(o *createOptions) {
+		o.assets = append(o.assets, createAsset{file: file, path: path})
+	}
+}
+
+// WithSigner signs the plugin image with the key e, its private
+// key must be decrypted. The option can be repeated.
+func WithSigner(e *openpgp.Entity) CreateOp {
+	return func(o *createOptions) {
+		o.signers = append(o.signers, e)
+	}
+}
+
+// CreateSIF creates the plugin image outPath from the compiled plugin
+// object binaryPath and its manifest. The object is stored for the
+// architecture found in its ELF header, compressed when requested, and
+// the binary compression fields of the manifest are set accordingly,
+// as well as the declared assets. The manifest and the configuration
+// template are validated as they would be at installation. The image
+// is signed after its creation when signers are given.
+func CreateSIF(binaryPath string, manifest pluginapi.Manifest, outPath string, ops ...CreateOp) error {
+	opts := createOptions{}
+	for _, op := range ops {
+		op(&opts)
+	}
+
+	if err := CheckCompression(opts.compression); err != nil {
+		return err
+	}
+	arch, err := elfArch(binaryPath)
+	if err != nil {
+		return err
+	}
+
+	obj, err := os.Open(binaryPath)
+	if err != nil {
+		return fmt.Errorf("while opening plugin object %s: %s", binaryPath, err)
+	}
+	defer obj.Close()
+
+	fi, err := obj.Stat()
+	if err != nil {
+		return fmt.Errorf("while getting size of plugin object %s: %s", binaryPath, err)
+	}
+
+	var objReader io.Reader = obj
+	objSize := fi.Size()
+
+	if opts.compression != "" {
+		// the uncompressed size bounds the decompression at installation
+		manifest.BinaryCompression = opts.compression
+		manifest.BinarySizes = map[string]int64{arch: objSize}
+
+		tmp, err := ioutil.TempFile(fs.TempDir(), "plugin-object-")
+		if err != nil {
+			return fmt.Errorf("while creating temporary file: %s", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if err := CompressBinary(tmp, obj, opts.compression); err != nil {
+			return err
+		}
+		if objSize, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		objReader = tmp
+	} else {
+		manifest.BinaryCompression = ""
+		manifest.BinarySizes = nil
+	}
+
+	objInput := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    primaryBinaryName(manifest),
+		Fp:       objReader,
+		Size:     objSize,
+	}
+	if err := objInput.SetPartExtra(sif.FsRaw, sif.PartData, sif.GetSIFArch(arch)); err != nil {
+		return err
+	}
+	inputs := []sif.DescriptorInput{objInput}
+
+	var assetInputs []sif.DescriptorInput
+	for i, a := range opts.assets {
+		data, err := ioutil.ReadFile(a.file)
+		if err != nil {
+			return fmt.Errorf("while reading plugin asset %s: %s", a.file, err)
+		}
+		descr := fmt.Sprintf("asset%d", i)
+		manifest.Assets = append(manifest.Assets, pluginapi.Asset{Descriptor: descr, Path: a.path})
+		assetInputs = append(assetInputs, sif.DescriptorInput{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    descr,
+			Data:     data,
+			Size:     int64(len(data)),
+		})
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("while encoding plugin manifest: %s", err)
+	}
+	if _, violations := validateManifest(data, false); len(violations) > 0 {
+		return fmt.Errorf("invalid plugin manifest: %w", &ManifestError{Violations: violations})
+	}
+	if err := checkArchitectures(manifest, []string{arch}, true); err != nil {
+		return fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+	inputs = append(inputs, sif.DescriptorInput{
+		Datatype: sif.DataGenericJSON,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    pluginManifestName,
+		Data:     data,
+		Size:     int64(len(data)),
+	})
+
+	if opts.changelog != "" {
+		data, err := ioutil.ReadFile(opts.changelog)
+		if err != nil {
+			return fmt.Errorf("while reading plugin release notes %s: %s", opts.changelog, err)
+		}
+		if len(data) > maxChangelogSize {
+			sylog.Warningf("Plugin release notes %s are larger than %d bytes, they will be truncated when displayed", opts.changelog, maxChangelogSize)
+		}
+		// the release notes are stored as plain text and only displayed
+		inputs = append(inputs, sif.DescriptorInput{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    pluginChangelogName,
+			Data:     data,
+			Size:     int64(len(data)),
+		})
+	}
+	if opts.config != "" {
+		data, err := ioutil.ReadFile(opts.config)
+		if err != nil {
+			return fmt.Errorf("while reading plugin configuration template %s: %s", opts.config, err)
+		}
+		c, err := newConfigCodec(manifest.ConfigFormat)
+		if err != nil {
+			return fmt.Errorf("invalid plugin manifest: %s", err)
+		}
+		if err := checkConfigTemplate(c, manifest.Config, data); err != nil {
+			return fmt.Errorf("invalid plugin configuration template %s: %w", opts.config, err)
+		}
+		inputs = append(inputs, sif.DescriptorInput{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    pluginConfigName,
+			Data:     data,
+			Size:     int64(len(data)),
+		})
+	}
+	if opts.sourceDir != "" {
+		if !filepath.IsAbs(opts.sourceDir) {
+			return fmt.Errorf("plugin source directory %s is not an absolute path", opts.sourceDir)
+		}
+		inputs = append(inputs, sif.DescriptorInput{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    pluginSourceName,
+			Data:     []byte(opts.sourceDir),
+			Size:     int64(len(opts.sourceDir)),
+		})
+	}
+	inputs = append(inputs, assetInputs...)
+
+	os.RemoveAll(outPath)
+
+	_, err = sif.CreateContainer(sif.CreateInfo{
+		Pathname:   outPath,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	})
+	if err != nil {
+		return fmt.Errorf("while creating plugin image %s: %s", outPath, err)
+	}
+
+	// the plugin objects are in the default group,
+	// one signature covers all of them
+	for _, e := range opts.signers {
+		if err := signing.SignWithEntity(outPath, sif.DescrDefaultGroup&^sif.DescrGroupMask, true, false, e); err != nil {
+			os.Remove(outPath)
+			return fmt.Errorf("while signing plugin image %s: %s", outPath, err)
+		}
+	}
+
+	return nil
+}
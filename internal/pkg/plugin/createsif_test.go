@@ -0,0 +1,241 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestCreateSIF(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+	exeData, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("while reading test executable: %s", err)
+	}
+	exeSum := sha256.Sum256(exeData)
+
+	dir, err := ioutil.TempDir("", "plugin-createsif-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	assetFile := filepath.Join(dir, "table.csv")
+	if err := ioutil.WriteFile(assetFile, []byte("a,b"), 0644); err != nil {
+		t.Fatalf("while writing asset: %s", err)
+	}
+	changelogFile := filepath.Join(dir, "CHANGELOG.md")
+	if err := ioutil.WriteFile(changelogFile, []byte("# v1.0.0\n"), 0644); err != nil {
+		t.Fatalf("while writing release notes: %s", err)
+	}
+
+	configFile := filepath.Join(dir, "config.default.yaml")
+	if err := ioutil.WriteFile(configFile, []byte("url: https://example.org\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration template: %s", err)
+	}
+	invalidConfigFile := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalidConfigFile, []byte("url: [\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration template: %s", err)
+	}
+
+	signer, err := openpgp.NewEntity("Signer", "", "signer@example.org", nil)
+	if err != nil {
+		t.Fatalf("while creating key: %s", err)
+	}
+
+	manifest := pluginapi.Manifest{Name: "example.com/created", Version: "1.0.0"}
+
+	tests := []struct {
+		name        string
+		binary      string
+		manifest    pluginapi.Manifest
+		ops         []CreateOp
+		expectError bool
+	}{
+		{
+			name:     "Uncompressed",
+			binary:   exe,
+			manifest: manifest,
+		},
+		{
+			name:     "Gzip",
+			binary:   exe,
+			manifest: manifest,
+			ops:      []CreateOp{WithCompression(CompressionGzip)},
+		},
+		{
+			name:     "Zstd",
+			binary:   exe,
+			manifest: manifest,
+			ops:      []CreateOp{WithCompression(CompressionZstd)},
+		},
+		{
+			name:     "Complete",
+			binary:   exe,
+			manifest: manifest,
+			ops: []CreateOp{
+				WithCompression(CompressionZstd),
+				WithChangelog(changelogFile),
+				WithConfig(configFile),
+				WithAsset(assetFile, "data/table.csv"),
+				WithSigner(signer),
+			},
+		},
+		{
+			name:        "UnknownCompression",
+			binary:      exe,
+			manifest:    manifest,
+			ops:         []CreateOp{WithCompression("lzma")},
+			expectError: true,
+		},
+		{
+			name:        "NotELF",
+			binary:      assetFile,
+			manifest:    manifest,
+			expectError: true,
+		},
+		{
+			name:        "InvalidManifest",
+			binary:      exe,
+			manifest:    pluginapi.Manifest{Name: "example.com/../created"},
+			expectError: true,
+		},
+		{
+			name:        "InvalidConfig",
+			binary:      exe,
+			manifest:    manifest,
+			ops:         []CreateOp{WithConfig(invalidConfigFile)},
+			expectError: true,
+		},
+		{
+			name:        "MissingAsset",
+			binary:      exe,
+			manifest:    manifest,
+			ops:         []CreateOp{WithAsset(filepath.Join(dir, "missing"), "missing")},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setTestRootDir(t)()
+
+			sifPath := filepath.Join(dir, tt.name+".sif")
+
+			err := CreateSIF(tt.binary, tt.manifest, sifPath, tt.ops...)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				if _, err := os.Stat(sifPath); err == nil {
+					t.Errorf("unexpected plugin image created on error")
+				}
+				return
+			}
+
+			inspected, err := Inspect(sifPath)
+			if err != nil {
+				t.Fatalf("while inspecting plugin image: %s", err)
+			}
+			if inspected.Name != tt.manifest.Name || inspected.Version != tt.manifest.Version {
+				t.Errorf("got manifest %s %s, expected %s %s", inspected.Name, inspected.Version, tt.manifest.Name, tt.manifest.Version)
+			}
+
+			fimg, err := sif.LoadContainer(sifPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			if err := checkPluginFile(newSifFileImageReader(&fimg)); err != nil {
+				t.Errorf("invalid plugin image: %s", err)
+			}
+			if archs := binaryArchitectures(&fimg); len(archs) != 1 || archs[0] != runtime.GOARCH {
+				t.Errorf("got binary architectures %v, expected [%s]", archs, runtime.GOARCH)
+			}
+
+			var b bytes.Buffer
+			if err := extractBinary(&b, &fimg); err != nil {
+				t.Fatalf("while extracting plugin binary: %s", err)
+			}
+			if sha256.Sum256(b.Bytes()) != exeSum {
+				t.Errorf("extracted plugin binary differs from the compiled object")
+			}
+
+			m := &Meta{Name: tt.manifest.Name, sifFile: &fimg}
+			if err := os.MkdirAll(m.path(), 0755); err != nil {
+				t.Fatalf("while creating plugin directory: %s", err)
+			}
+			if err := m.installAssets(); err != nil {
+				t.Fatalf("while installing plugin assets: %s", err)
+			}
+
+			results := verifySignatures(context.Background(), &fimg, openpgp.EntityList{signer}, SignatureOptions{})
+
+			if tt.name != "Complete" {
+				if m.AssetsPath != "" {
+					t.Errorf("unexpected assets for plugin %s", tt.name)
+				}
+				if len(results) != 0 {
+					t.Errorf("unexpected signatures %+v", results)
+				}
+				return
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(m.AssetsPath, "data/table.csv"))
+			if err != nil {
+				t.Errorf("while reading asset: %s", err)
+			} else if string(content) != "a,b" {
+				t.Errorf("got asset content %q, expected %q", content, "a,b")
+			}
+			if n := findDescriptor(newSifFileImageReader(&fimg), pluginChangelogName); n < 0 {
+				t.Errorf("no release notes in plugin image")
+			}
+			if n := findDescriptor(newSifFileImageReader(&fimg), pluginConfigName); n < 0 {
+				t.Errorf("no configuration template in plugin image")
+			}
+			if len(results) != 1 || results[0].Status != SignatureValid {
+				t.Errorf("got signatures %+v, expected one valid signature", results)
+			}
+		})
+	}
+}
+
+func TestCreateSIFManifestError(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "plugin-createsif-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = CreateSIF(exe, pluginapi.Manifest{Name: "example.com/../created"}, filepath.Join(dir, "invalid.sif"))
+
+	var merr *ManifestError
+	if !errors.As(err, &merr) {
+		t.Errorf("got error %v, expected a manifest error", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// DependencyStatus describes whether a plugin dependency is
+// satisfied by the installed plugins.
+type DependencyStatus struct {
+	pluginapi.Dependency
+	// Installed is true if the required plugin is installed.
+	Installed bool
+	// Enabled is true if the required plugin is enabled.
+	Enabled bool
+	// InstalledVersion is the version of the installed plugin.
+	InstalledVersion string
+	// Satisfied is true if the installed plugin version satisfies
+	// the version constraint.
+	Satisfied bool
+}
+
+// String returns a short description of the dependency status.
+func (s DependencyStatus) String() string {
+	version := s.InstalledVersion
+	if version == "" {
+		version = "unknown version"
+	}
+	switch {
+	case !s.Installed:
+		return "not installed"
+	case !s.Satisfied:
+		return fmt.Sprintf("not satisfied, %s installed", version)
+	case !s.Enabled:
+		return fmt.Sprintf("satisfied by %s, disabled", version)
+	}
+	return fmt.Sprintf("satisfied by %s", version)
+}
+
+// Dependencies returns the status of the dependencies declared by
+// manifest against the plugins installed under rootDir.
+func Dependencies(manifest pluginapi.Manifest) ([]DependencyStatus, error) {
+	status := make([]DependencyStatus, 0, len(manifest.Dependencies))
+
+	for _, dep := range manifest.Dependencies {
+		s, err := dependencyStatus(dep)
+		if err != nil {
+			return nil, err
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// dependencyStatus returns the status of the dependency dep.
+func dependencyStatus(dep pluginapi.Dependency) (DependencyStatus, error) {
+	s := DependencyStatus{Dependency: dep}
+
+	c, err := parseConstraint(dep.Version)
+	if err != nil {
+		return s, fmt.Errorf("dependency %q: %s", dep.Name, err)
+	}
+
+	m, err := loadMetaByName(dep.Name)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, fmt.Errorf("could not load required plugin %q: %w", dep.Name, err)
+	}
+
+	s.Installed = true
+	s.Enabled = m.Enabled
+	s.InstalledVersion = m.installedVersion()
+	s.Satisfied = satisfies(s.InstalledVersion, c)
+
+	return s, nil
+}
+
+// satisfies returns whether version satisfies the constraint c. A
+// version which is not a semantic version only satisfies an empty
+// constraint.
+func satisfies(version string, c versionConstraint) bool {
+	if len(c) == 0 {
+		return true
+	}
+	v, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	return c.check(v)
+}
+
+// checkDependencies returns an error if one of the dependencies
+// declared by the manifest of the plugin "name" is not satisfied.
+func checkDependencies(name string, manifest pluginapi.Manifest) error {
+	var unsatisfied []string
+
+	for _, dep := range manifest.Dependencies {
+		if strings.EqualFold(normalizeName(dep.Name), name) {
+			return fmt.Errorf("plugin %q can't depend on itself", name)
+		}
+
+		s, err := dependencyStatus(dep)
+		if err != nil {
+			return err
+		}
+
+		if !s.Installed || !s.Satisfied {
+			unsatisfied = append(unsatisfied, describeDependency(dep)+": "+s.String())
+		} else if !s.Enabled {
+			sylog.Warningf("Plugin %q requires the disabled plugin %q", name, dep.Name)
+		}
+	}
+
+	if len(unsatisfied) > 0 {
+		return fmt.Errorf("unsatisfied dependencies: %s", strings.Join(unsatisfied, "; "))
+	}
+	return nil
+}
+
+// dependents returns the names of the installed plugins, other than
+// "name", requiring the plugin "name" along with their dependency on it.
+func dependents(name string) ([]string, []pluginapi.Dependency, error) {
+	metas, err := List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	var deps []pluginapi.Dependency
+
+	for _, m := range metas {
+		if m.Name == name {
+			continue
+		}
+		manifest, err := m.manifest()
+		if err != nil {
+			sylog.Debugf("Could not read manifest of plugin %q: %s", m.Name, err)
+			continue
+		}
+		for _, dep := range manifest.Dependencies {
+			if strings.EqualFold(normalizeName(dep.Name), name) {
+				names = append(names, m.Name)
+				deps = append(deps, dep)
+				break
+			}
+		}
+	}
+
+	return names, deps, nil
+}
+
+// checkDependents returns an error if the version of the plugin
+// "name" doesn't satisfy the constraints of the installed plugins
+// requiring it.
+func checkDependents(name, version string) error {
+	names, deps, err := dependents(name)
+	if err != nil {
+		return err
+	}
+
+	var broken []string
+
+	for i, dep := range deps {
+		c, err := parseConstraint(dep.Version)
+		if err != nil {
+			sylog.Debugf("Plugin %q dependency %q: %s", names[i], dep.Name, err)
+			continue
+		}
+		if !satisfies(version, c) {
+			broken = append(broken, fmt.Sprintf("%s requires %s", names[i], describeDependency(dep)))
+		}
+	}
+
+	if len(broken) > 0 {
+		return fmt.Errorf("version %s would break installed plugins: %s", describeVersion(version), strings.Join(broken, "; "))
+	}
+	return nil
+}
+
+// describeDependency returns the dependency name followed by its
+// version constraint if any.
+func describeDependency(dep pluginapi.Dependency) string {
+	if dep.Version == "" {
+		return dep.Name
+	}
+	return dep.Name + " " + dep.Version
+}
+
+func describeVersion(version string) string {
+	if version == "" {
+		return "(none)"
+	}
+	return version
+}
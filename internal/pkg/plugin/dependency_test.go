@@ -0,0 +1,194 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// installTestVersion installs a test plugin recording version
+// as its installed version.
+func installTestVersion(t *testing.T, dir string, manifest pluginapi.Manifest, enabled bool) {
+	sifPath := createTestPlugin(t, dir, manifest)
+	installTestPlugin(t, sifPath, manifest.Name, enabled)
+
+	m, err := loadMetaByName(manifest.Name)
+	if err != nil {
+		t.Fatalf("while loading plugin meta: %s", err)
+	}
+	m.Version = manifest.Version
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing plugin meta: %s", err)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-dependency-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	installTestVersion(t, dir, pluginapi.Manifest{Name: "example.com/base", Version: "1.4.2"}, true)
+	installTestVersion(t, dir, pluginapi.Manifest{Name: "example.com/disabled", Version: "v2.0.0"}, false)
+	installTestVersion(t, dir, pluginapi.Manifest{Name: "example.com/unversioned"}, true)
+
+	tests := []struct {
+		name         string
+		dependencies []pluginapi.Dependency
+		expectError  string
+	}{
+		{
+			name: "NoDependencies",
+		},
+		{
+			name:         "AnyVersion",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/base"}},
+		},
+		{
+			name:         "Satisfied",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/base", Version: ">=1.2.0, <2"}},
+		},
+		{
+			name:         "CaseInsensitive",
+			dependencies: []pluginapi.Dependency{{Name: "Example.com/Base", Version: "1.4"}},
+		},
+		{
+			name:         "Disabled",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/disabled", Version: "2"}},
+		},
+		{
+			name:         "NotSatisfied",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/base", Version: ">=1.5.0"}},
+			expectError:  "example.com/base >=1.5.0: not satisfied, 1.4.2 installed",
+		},
+		{
+			name:         "NotInstalled",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/missing"}},
+			expectError:  "example.com/missing: not installed",
+		},
+		{
+			name:         "UnversionedAny",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/unversioned"}},
+		},
+		{
+			name:         "UnversionedConstraint",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/unversioned", Version: ">=1.0.0"}},
+			expectError:  "not satisfied, unknown version installed",
+		},
+		{
+			name:         "InvalidConstraint",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/base", Version: "~1.2"}},
+			expectError:  "invalid version constraint",
+		},
+		{
+			name:         "Itself",
+			dependencies: []pluginapi.Dependency{{Name: "example.com/new/"}},
+			expectError:  "can't depend on itself",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := pluginapi.Manifest{Name: "example.com/new", Dependencies: tt.dependencies}
+			err := checkDependencies("example.com/new", manifest)
+			if tt.expectError == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tt.expectError != "" && (err == nil || !strings.Contains(err.Error(), tt.expectError)) {
+				t.Errorf("got error %v, expected %q", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-dependency-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	installTestVersion(t, dir, pluginapi.Manifest{Name: "example.com/base", Version: "1.4.2"}, false)
+
+	manifest := pluginapi.Manifest{
+		Name: "example.com/new",
+		Dependencies: []pluginapi.Dependency{
+			{Name: "example.com/base", Version: "1"},
+			{Name: "example.com/base", Version: "2"},
+			{Name: "example.com/missing"},
+		},
+	}
+	status, err := Dependencies(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"satisfied by 1.4.2, disabled",
+		"not satisfied, 1.4.2 installed",
+		"not installed",
+	}
+	if len(status) != len(expected) {
+		t.Fatalf("got %d dependencies, expected %d", len(status), len(expected))
+	}
+	for i, s := range status {
+		if s.String() != expected[i] {
+			t.Errorf("dependency %d: got status %q, expected %q", i, s, expected[i])
+		}
+	}
+}
+
+func TestUninstallDependents(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-dependency-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	installTestVersion(t, dir, pluginapi.Manifest{Name: "example.com/base", Version: "1.4.2"}, true)
+	installTestVersion(t, dir, pluginapi.Manifest{
+		Name:         "example.com/user",
+		Version:      "0.1.0",
+		Dependencies: []pluginapi.Dependency{{Name: "Example.com/Base", Version: ">=1.4, <2"}},
+	}, true)
+
+	err = Uninstall("example.com/base")
+	if err == nil || !strings.Contains(err.Error(), "required by example.com/user") {
+		t.Errorf("unexpected error for a required plugin: %v", err)
+	}
+	if _, err := loadMetaByName("example.com/base"); err != nil {
+		t.Errorf("required plugin was uninstalled: %s", err)
+	}
+
+	if err := checkDependents("example.com/base", "1.9.0"); err != nil {
+		t.Errorf("unexpected error for a compatible upgrade: %s", err)
+	}
+	err = checkDependents("example.com/base", "2.0.0")
+	if err == nil || !strings.Contains(err.Error(), "example.com/user requires Example.com/Base >=1.4, <2") {
+		t.Errorf("unexpected error for an incompatible upgrade: %v", err)
+	}
+	if err := checkDependents("example.com/base", ""); err == nil {
+		t.Errorf("unexpected success for an upgrade without version")
+	}
+
+	if err := Uninstall("example.com/user"); err != nil {
+		t.Fatalf("unexpected error while uninstalling dependent plugin: %s", err)
+	}
+	if err := Uninstall("example.com/base"); err != nil {
+		t.Errorf("unexpected error once dependent plugin is uninstalled: %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// checkDeprecation returns the violations found in the deprecation
+// fields of manifest, their length is checked by checkManifestFields.
+func checkDeprecation(manifest pluginapi.Manifest) []string {
+	if manifest.ReplacedBy == "" {
+		return nil
+	}
+	if !manifest.Deprecated.IsDeprecated() {
+		return []string{"replacedBy is set but the plugin is not deprecated"}
+	}
+
+	var err error
+	if strings.Contains(manifest.ReplacedBy, "://") {
+		err = checkURL(manifest.ReplacedBy)
+	} else {
+		err = checkPluginName(manifest.ReplacedBy)
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("replacedBy: %s", err)}
+	}
+	return nil
+}
+
+// deprecationMessage returns the deprecation message of d, d may be nil.
+func deprecationMessage(d *pluginapi.Deprecation) string {
+	if d == nil {
+		return ""
+	}
+	return d.Message
+}
+
+// deprecationNotice returns the notice displayed to the users of the
+// deprecated plugin name.
+func deprecationNotice(name string, d *pluginapi.Deprecation, replacedBy string) string {
+	notice := fmt.Sprintf("Plugin %q is deprecated", name)
+	if msg := deprecationMessage(d); msg != "" {
+		notice += ": " + msg
+	}
+	if replacedBy != "" {
+		notice += fmt.Sprintf(", it is replaced by %q", replacedBy)
+	}
+	return notice
+}
+
+// IsDeprecated returns whether the manifest of the installed
+// plugin marks it as deprecated.
+func (m *Meta) IsDeprecated() bool {
+	return m.Deprecated.IsDeprecated()
+}
+
+// DeprecationNotice returns the notice displayed to the users of
+// the plugin, it's empty when the plugin is not deprecated.
+func (m *Meta) DeprecationNotice() string {
+	if !m.IsDeprecated() {
+		return ""
+	}
+	return deprecationNotice(m.Name, m.Deprecated, m.ReplacedBy)
+}
+
+// noticeDeprecation informs the user that the plugin being
+// loaded is deprecated. It's called once per plugin when the
+// plugin is loaded, the plugin is loaded anyway.
+func (m *Meta) noticeDeprecation() {
+	if notice := m.DeprecationNotice(); notice != "" {
+		sylog.Infof("%s", notice)
+	}
+}
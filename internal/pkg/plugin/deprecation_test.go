@@ -0,0 +1,179 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestManifestDeprecation(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		deprecated bool
+		message    string
+		violations int
+	}{
+		{
+			name: "NotDeprecated",
+			data: `{"name": "example.com/foo"}`,
+		},
+		{
+			name:       "Deprecated",
+			data:       `{"name": "example.com/foo", "deprecated": true}`,
+			deprecated: true,
+		},
+		{
+			name: "NotDeprecatedExplicitly",
+			data: `{"name": "example.com/foo", "deprecated": false}`,
+		},
+		{
+			name:       "Message",
+			data:       `{"name": "example.com/foo", "deprecated": "no longer maintained"}`,
+			deprecated: true,
+			message:    "no longer maintained",
+		},
+		{
+			name:       "ReplacedByName",
+			data:       `{"name": "example.com/foo", "deprecated": true, "replacedBy": "example.com/bar"}`,
+			deprecated: true,
+		},
+		{
+			name:       "ReplacedByURL",
+			data:       `{"name": "example.com/foo", "deprecated": true, "replacedBy": "https://example.com/bar"}`,
+			deprecated: true,
+		},
+		{
+			name:       "InvalidReplacedBy",
+			data:       `{"name": "example.com/foo", "deprecated": true, "replacedBy": "ftp://example.com/bar"}`,
+			deprecated: true,
+			violations: 1,
+		},
+		{
+			name:       "ReplacedByWithoutDeprecation",
+			data:       `{"name": "example.com/foo", "replacedBy": "example.com/bar"}`,
+			violations: 1,
+		},
+		{
+			name:       "InvalidType",
+			data:       `{"name": "example.com/foo", "deprecated": 1}`,
+			violations: 1,
+		},
+		{
+			name:       "ControlCharacters",
+			data:       `{"name": "example.com/foo", "deprecated": "line\nbreak"}`,
+			deprecated: true,
+			message:    "line\nbreak",
+			violations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, violations := validateManifest([]byte(tt.data), false)
+			if len(violations) != tt.violations {
+				t.Errorf("got violations %q, expected %d", violations, tt.violations)
+			}
+			if manifest.Deprecated.IsDeprecated() != tt.deprecated {
+				t.Errorf("got deprecated %v, expected %v", manifest.Deprecated.IsDeprecated(), tt.deprecated)
+			}
+			if msg := deprecationMessage(manifest.Deprecated); msg != tt.message {
+				t.Errorf("got message %q, expected %q", msg, tt.message)
+			}
+		})
+	}
+}
+
+func TestDeprecationMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest pluginapi.Manifest
+		expected string
+	}{
+		{"NotDeprecated", pluginapi.Manifest{}, `{}`},
+		{"Deprecated", pluginapi.Manifest{Deprecated: &pluginapi.Deprecation{}}, `{"deprecated":true}`},
+		{"Message", pluginapi.Manifest{Deprecated: &pluginapi.Deprecation{Message: "use bar"}}, `{"deprecated":"use bar"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields map[string]json.RawMessage
+
+			data, err := json.Marshal(tt.manifest)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got := "{}"
+			if v, ok := fields["deprecated"]; ok {
+				got = `{"deprecated":` + string(v) + `}`
+			}
+			if got != tt.expected {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeprecationNotice(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     Meta
+		expected string
+	}{
+		{
+			name: "NotDeprecated",
+			meta: Meta{Name: "example.com/foo"},
+		},
+		{
+			name:     "Deprecated",
+			meta:     Meta{Name: "example.com/foo", Deprecated: &pluginapi.Deprecation{}},
+			expected: `Plugin "example.com/foo" is deprecated`,
+		},
+		{
+			name:     "Message",
+			meta:     Meta{Name: "example.com/foo", Deprecated: &pluginapi.Deprecation{Message: "no longer maintained"}},
+			expected: `Plugin "example.com/foo" is deprecated: no longer maintained`,
+		},
+		{
+			name:     "ReplacedBy",
+			meta:     Meta{Name: "example.com/foo", Deprecated: &pluginapi.Deprecation{Message: "no longer maintained"}, ReplacedBy: "example.com/bar"},
+			expected: `Plugin "example.com/foo" is deprecated: no longer maintained, it is replaced by "example.com/bar"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if notice := tt.meta.DeprecationNotice(); notice != tt.expected {
+				t.Errorf("got notice %q, expected %q", notice, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMetaDeprecation(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.com/foo")
+	m.Deprecated = &pluginapi.Deprecation{Message: "no longer maintained"}
+	m.ReplacedBy = "example.com/bar"
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	m, err := Lookup("example.com/foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !m.IsDeprecated() || m.Deprecated.Message != "no longer maintained" || m.ReplacedBy != "example.com/bar" {
+		t.Errorf("deprecation not recorded: %v, %q", m.Deprecated, m.ReplacedBy)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// InstallDir installs the unpacked plugin directory dir, holding the
+// compiled plugin object plugin.so and its JSON manifest plugin.manifest,
+// as name, see Install, for the development of the plugin. The plugin
+// image is packaged from the directory at installation, it's unsigned,
+// and the plugin is marked as a development install (see IsDevInstall)
+// until it's installed again from an image.
+func InstallDir(dir string, name string, ops ...InstallOp) error {
+	opts := installOptions{}
+	for _, op := range ops {
+		op(&opts)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("while getting absolute path of %s: %s", dir, err)
+	}
+
+	staging, err := ioutil.TempDir(fs.TempDir(), "plugin-dev-")
+	if err != nil {
+		return fmt.Errorf("while creating staging directory: %s", err)
+	}
+	defer os.RemoveAll(staging)
+
+	image := filepath.Join(staging, nameImage)
+	if err := packDir(abs, image); err != nil {
+		return err
+	}
+
+	opts.source = abs
+	opts.devDir = abs
+	_, err = installSIF(image, name, opts)
+	return err
+}
+
+// packDir packages the unpacked plugin directory dir, see InstallDir,
+// into the plugin image outPath.
+func packDir(dir, outPath string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("could not read plugin directory: %s", err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s is not a plugin directory", dir)
+	}
+
+	manifest, err := readDirManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	// the object is stored as is, the compression
+	// fields of the manifest are reset accordingly
+	object := filepath.Join(dir, pluginBinaryName)
+	if err := CreateSIF(object, manifest, outPath); err != nil {
+		return fmt.Errorf("while packaging plugin directory %s: %w", dir, err)
+	}
+	return nil
+}
+
+// IsDevInstall returns whether the plugin was installed from an
+// unpacked plugin directory by InstallDir, its image isn't a signed
+// artifact.
+func (m *Meta) IsDevInstall() bool {
+	return m.DevDir != ""
+}
+
+// readDirManifest reads and validates the manifest of the unpacked
+// plugin directory dir.
+func readDirManifest(dir string) (pluginapi.Manifest, error) {
+	path := filepath.Join(dir, pluginManifestName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return pluginapi.Manifest{}, fmt.Errorf("could not read plugin manifest: %s", err)
+	}
+	manifest, violations := validateManifest(data, allowUnknownFields())
+	if len(violations) > 0 {
+		return pluginapi.Manifest{}, fmt.Errorf("invalid plugin manifest %s: %w", path, &ManifestError{Violations: violations})
+	}
+	return manifest, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackDir(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+	exeData, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("while reading test executable: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "plugin-dev-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pluginDir := filepath.Join(dir, "build")
+	if err := os.Mkdir(pluginDir, 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	image := filepath.Join(dir, "image.sif")
+
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(pluginDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("while writing %s: %s", name, err)
+		}
+	}
+
+	// nothing to install
+	if err := packDir(pluginDir, image); err == nil {
+		t.Errorf("unexpected success without a manifest")
+	}
+	if err := packDir(filepath.Join(dir, "missing"), image); err == nil {
+		t.Errorf("unexpected success with a missing directory")
+	}
+
+	writeFile(pluginManifestName, `{"Name": "example.com/dev", "Version": "1.0.0-dev"}`)
+	if err := packDir(pluginDir, image); err == nil {
+		t.Errorf("unexpected success without a plugin object")
+	}
+
+	writeFile(pluginBinaryName, string(exeData))
+	if err := packDir(filepath.Join(pluginDir, pluginBinaryName), image); err == nil {
+		t.Errorf("unexpected success with a file")
+	}
+	if err := packDir(pluginDir, image); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	manifest, err := Inspect(image)
+	if err != nil {
+		t.Fatalf("while inspecting plugin image: %s", err)
+	}
+	if manifest.Name != "example.com/dev" || manifest.Version != "1.0.0-dev" {
+		t.Errorf("got manifest %s %s, expected example.com/dev 1.0.0-dev", manifest.Name, manifest.Version)
+	}
+
+	writeFile(pluginManifestName, `{"Name": "example.com/../dev"}`)
+	var merr *ManifestError
+	if err := packDir(pluginDir, image); !errors.As(err, &merr) {
+		t.Errorf("got error %v, expected a manifest error", err)
+	}
+}
+
+func TestIsDevInstall(t *testing.T) {
+	if (&Meta{Name: "example.com/test"}).IsDevInstall() {
+		t.Errorf("plugin installed from an image reported as a development install")
+	}
+	if !(&Meta{Name: "example.com/test", DevDir: "/src/build"}).IsDevInstall() {
+		t.Errorf("development install not reported")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+)
+
+// elfArchs maps the ELF machines to the Go architectures, those
+// depending on the byte order are suffixed with "le" when little endian.
+var elfArchs = map[elf.Machine]string{
+	elf.EM_386:     "386",
+	elf.EM_X86_64:  "amd64",
+	elf.EM_ARM:     "arm",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_PPC64:   "ppc64",
+	elf.EM_MIPS:    "mips",
+	elf.EM_S390:    "s390x",
+}
+
+// elfFileArch returns the Go architecture of the ELF object f.
+func elfFileArch(f *elf.File) (string, error) {
+	arch, ok := elfArchs[f.Machine]
+	if !ok {
+		return "", fmt.Errorf("unsupported architecture %s", f.Machine)
+	}
+	if f.Machine == elf.EM_MIPS && f.Class == elf.ELFCLASS64 {
+		arch += "64"
+	}
+	if (f.Machine == elf.EM_PPC64 || f.Machine == elf.EM_MIPS) && f.ByteOrder == binary.LittleEndian {
+		arch += "le"
+	}
+	return arch, nil
+}
+
+// elfArch returns the Go architecture of the ELF object file.
+func elfArch(file string) (string, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("while reading ELF header of %s: %s", file, err)
+	}
+	defer f.Close()
+
+	arch, err := elfFileArch(f)
+	if err != nil {
+		return "", fmt.Errorf("%s of %s", err, file)
+	}
+	return arch, nil
+}
+
+// sharedObjectArch returns the Go architecture of the ELF shared
+// object data, or an error if data is not an ELF shared object.
+func sharedObjectArch(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte(elf.ELFMAG)) {
+		return "", fmt.Errorf("no ELF magic")
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("while reading ELF header: %s", err)
+	}
+	defer f.Close()
+
+	if f.Type != elf.ET_DYN {
+		return "", fmt.Errorf("ELF object of type %s instead of %s", f.Type, elf.ET_DYN)
+	}
+	return elfFileArch(f)
+}
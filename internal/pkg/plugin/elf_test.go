@@ -0,0 +1,117 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// testELFObject returns the ELF header of an x86_64 object of type typ,
+// without sections nor segments.
+func testELFObject(t *testing.T, typ elf.Type) string {
+	hdr := elf.Header64{
+		Type:    uint16(typ),
+		Machine: uint16(elf.EM_X86_64),
+		Version: uint32(elf.EV_CURRENT),
+		Ehsize:  uint16(binary.Size(elf.Header64{})),
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, hdr); err != nil {
+		t.Fatalf("while writing ELF header: %s", err)
+	}
+	return b.String()
+}
+
+func TestElfArch(t *testing.T) {
+	// the test binary is an ELF object of the host
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+	arch, err := elfArch(exe)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if arch != runtime.GOARCH {
+		t.Errorf("got architecture %s, expected %s", arch, runtime.GOARCH)
+	}
+
+	f, err := ioutil.TempFile("", "plugin-elf-")
+	if err != nil {
+		t.Fatalf("while creating temporary file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("#!/bin/sh\n")
+	f.Close()
+
+	if _, err := elfArch(f.Name()); err == nil {
+		t.Errorf("unexpected success for a non ELF file")
+	}
+}
+
+func TestSharedObjectArch(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		arch        string
+		expectError bool
+	}{
+		{
+			name: "SharedObject",
+			data: testELFObject(t, elf.ET_DYN),
+			arch: "amd64",
+		},
+		{
+			name:        "Executable",
+			data:        testELFObject(t, elf.ET_EXEC),
+			expectError: true,
+		},
+		{
+			name:        "Relocatable",
+			data:        testELFObject(t, elf.ET_REL),
+			expectError: true,
+		},
+		{
+			name:        "Truncated",
+			data:        testELFObject(t, elf.ET_DYN)[:20],
+			expectError: true,
+		},
+		{
+			name:        "NotELF",
+			data:        "dummy plugin object",
+			expectError: true,
+		},
+		{
+			name:        "Empty",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arch, err := sharedObjectArch([]byte(tt.data))
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+			if arch != tt.arch {
+				t.Errorf("got architecture %q, expected %q", arch, tt.arch)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// configEnvPrefix is the prefix of the environment variables
+	// overriding plugin configuration keys.
+	configEnvPrefix = "SINGULARITY_PLUGINCFG_"
+	// legacyConfigEnvPrefix is the prefix of the variables of the
+	// previous releases, still honoured when the variable with
+	// configEnvPrefix isn't set.
+	legacyConfigEnvPrefix = "SINGULARITY_PLUGIN_"
+)
+
+// LoadConfig loads the configuration of the installed plugin "name"
+// into v, which must be a pointer to a struct. The schema of the
+// configuration is given by the struct type and its yaml tags, whatever
+// the configuration format, the values already set in v are the defaults,
+// they are overridden by the configuration file merged with the drop-in
+// files of config.d, then by the user configuration overlay (see
+// GetUserConfig), which is overridden by the environment variables (see
+// ConfigEnvKey). The overrides are checked against the configuration
+// schema of the plugin, if any, the user overlay is ignored and only the
+// options marked as overridable are overridden in privileged processes.
+// An invalid configuration is reported with a warning, v is then left
+// with its defaults, or an error is returned if the "plugin config
+// check" directive of singularity.conf is set to strict.
+func LoadConfig(name string, v interface{}) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	return meta.LoadConfig(v)
+}
+
+// LoadConfig loads the configuration of the plugin into v with
+// the same precedence rules as the LoadConfig function.
+func (m *Meta) LoadConfig(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("configuration must be a pointer to a struct, got %T", v)
+	}
+
+	mode := configCheckMode()
+	if f := m.loadConfigFault(mode); f != nil {
+		if mode == configCheckStrict {
+			return f
+		}
+		return nil
+	}
+
+	c, err := m.configCodec()
+	if err != nil {
+		return err
+	}
+
+	// the merged content is converted to YAML so the
+	// struct is decoded along its yaml tags
+	root, layers, err := m.mergedConfig(c)
+	if err != nil {
+		return err
+	}
+	if overlay, _ := m.userConfig(c); overlay != nil {
+		mergeConfigRoot(root, overlay)
+	}
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		return fmt.Errorf("while converting configuration %s: %s", configDescription(layers), err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("while decoding configuration %s: %s", configDescription(layers), err)
+	}
+
+	return applyConfigEnv(configEnvPrefix+mangleConfigKey(m.Name), "", rv.Elem(), m.configEnv(os.LookupEnv))
+}
+
+// privileged is set by SetPrivileged.
+var privileged bool
+
+// SetPrivileged marks the process as part of a privileged flow, the
+// starter does so for all the stages of the setuid workflow, whatever
+// their effective IDs at the time the plugins load their configuration.
+func SetPrivileged(p bool) {
+	privileged = p
+}
+
+// isPrivileged returns whether the process runs with elevated
+// privileges: the stages of a setuid starter, or any process run
+// as root or with effective IDs differing from the real ones.
+var isPrivileged = func() bool {
+	return privileged || os.Geteuid() == 0 || os.Geteuid() != os.Getuid() || os.Getegid() != os.Getgid()
+}
+
+// configEnv returns the overrides of the configuration keys
+// of the plugin, lookup returns the environment variables.
+func (m *Meta) configEnv(lookup func(string) (string, bool)) configEnv {
+	return configEnv{
+		schema:     newConfigSchema(m.ConfigSchema),
+		lookup:     lookup,
+		privileged: isPrivileged(),
+	}
+}
+
+// configEnv looks up the environment variables overriding the
+// configuration keys of a plugin along its configuration schema.
+type configEnv struct {
+	schema     configSchema
+	lookup     func(string) (string, bool)
+	privileged bool
+	// overridden is called for each override applied
+	overridden func()
+}
+
+// value returns the value of the environment variable env overriding
+// the dotted configuration key, and whether it's set. The variable with
+// the legacy SINGULARITY_PLUGIN_ prefix is used when env isn't set. The
+// value must be of the type of the option declared by the schema for the
+// key. In privileged processes, the variable is ignored unless the option
+// is overridable.
+func (e configEnv) value(key, env string) (string, bool, error) {
+	value, ok := e.lookup(env)
+	if !ok {
+		env = legacyConfigEnvPrefix + strings.TrimPrefix(env, configEnvPrefix)
+		if value, ok = e.lookup(env); !ok {
+			return "", false, nil
+		}
+	}
+
+	o, declared := e.schema.options[key]
+	if e.privileged && !o.Overridable {
+		sylog.Debugf("Ignoring %s in a privileged process, plugin configuration key %q is not overridable", env, key)
+		return "", false, nil
+	}
+	if declared {
+		if _, err := parseConfigValue(o.Type, value); err != nil {
+			return "", false, fmt.Errorf("invalid value for %s: %s", env, err)
+		}
+	}
+
+	sylog.Debugf("Overriding plugin configuration key %q with %s", key, env)
+	if e.overridden != nil {
+		e.overridden()
+	}
+	return value, true, nil
+}
+
+// ConfigEnvKey returns the name of the environment variable overriding
+// the configuration key of the plugin "name". Nested keys are given from
+// the outermost to the innermost, and are joined by an underscore. The
+// plugin name and the keys are upper-cased with any character other than
+// a letter or a digit replaced by an underscore, so the key "port" of the
+// "server" section of the plugin "example.org/my-plugin" is overridden by
+// SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_SERVER_PORT. The same
+// variable prefixed by SINGULARITY_PLUGIN_ instead, as in the previous
+// releases, is honoured when this one isn't set.
+func ConfigEnvKey(name string, keys ...string) string {
+	env := configEnvPrefix + mangleConfigKey(name)
+	for _, k := range keys {
+		env += "_" + mangleConfigKey(k)
+	}
+	return env
+}
+
+func mangleConfigKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+}
+
+// configKey returns the configuration key of a struct field
+// following the yaml package naming rules, an empty key is
+// returned for fields not part of the configuration.
+func configKey(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+
+	tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	switch tag {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(f.Name)
+	}
+	return tag
+}
+
+// applyConfigEnv sets the fields of the struct v, holding the section
+// section of the configuration, from the environment variables prefixed
+// by prefix.
+func applyConfigEnv(prefix, section string, v reflect.Value, e configEnv) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		key := configKey(t.Field(i))
+		if key == "" {
+			continue
+		}
+
+		env := prefix + "_" + mangleConfigKey(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				// only allocate the section if it's overridden
+				nv := reflect.New(fv.Type().Elem())
+				set, err := hasConfigEnv(env, key, nv.Elem(), e)
+				if err != nil {
+					return err
+				} else if !set {
+					continue
+				}
+				fv.Set(nv)
+			}
+			fv = fv.Elem()
+		}
+
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err := applyConfigEnv(env, key, fv, e); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok, err := e.value(key, env)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if err := setConfigValue(fv, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %s", env, err)
+		}
+	}
+
+	return nil
+}
+
+// hasConfigEnv returns whether at least one field of the struct v
+// is overridden by an environment variable.
+func hasConfigEnv(prefix, section string, v reflect.Value, e configEnv) (bool, error) {
+	set := false
+	e.overridden = func() { set = true }
+	err := applyConfigEnv(prefix, section, v, e)
+	return set, err
+}
+
+// setConfigValue converts value to the type of v and sets it. Lists
+// are given as comma separated values.
+func setConfigValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Ptr {
+		nv := reflect.New(v.Type().Elem())
+		if err := setConfigValue(nv.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var elems []string
+		if value != "" {
+			elems = strings.Split(value, ",")
+		}
+		s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := setConfigValue(s.Index(i), strings.TrimSpace(e)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
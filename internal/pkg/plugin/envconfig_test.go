@@ -0,0 +1,227 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+type testServerConfig struct {
+	Host    string        `yaml:"host"`
+	Port    int           `yaml:"port"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type testConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	LogLevel string            `yaml:"log-level"`
+	Ratio    float64           `yaml:"ratio"`
+	Paths    []string          `yaml:"paths"`
+	Server   testServerConfig  `yaml:"server"`
+	Proxy    *testServerConfig `yaml:"proxy"`
+	Retries  uint
+	Ignored  string `yaml:"-"`
+}
+
+func TestConfigEnvKey(t *testing.T) {
+	cases := []struct {
+		name     string
+		keys     []string
+		expected string
+	}{
+		{"example.org/test", []string{"enabled"}, "SINGULARITY_PLUGINCFG_EXAMPLE_ORG_TEST_ENABLED"},
+		{"example.org/my-plugin", []string{"log-level"}, "SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_LOG_LEVEL"},
+		{"example.org/my-plugin", []string{"server", "port"}, "SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_SERVER_PORT"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expected, func(t *testing.T) {
+			if actual := ConfigEnvKey(tc.name, tc.keys...); actual != tc.expected {
+				t.Errorf("got %q, expected %q", actual, tc.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/my-plugin"}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+
+	file := `enabled: true
+log-level: info
+ratio: 0.5
+server:
+  host: file.example.org
+  port: 80
+`
+	if err := ioutil.WriteFile(m.configName(), []byte(file), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+
+	env := map[string]string{
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_LOG_LEVEL":      "debug",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_PATHS":          "/a, /b",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_SERVER_PORT":    "8080",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_SERVER_TIMEOUT": "5s",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_PROXY_HOST":     "proxy.example.org",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_IGNORED":        "set",
+		// the legacy prefix is honoured unless the variable
+		// with the current prefix is set
+		"SINGULARITY_PLUGIN_EXAMPLE_ORG_MY_PLUGIN_RETRIES":     "3",
+		"SINGULARITY_PLUGIN_EXAMPLE_ORG_MY_PLUGIN_SERVER_PORT": "9090",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	// the suite may run as root, where the options
+	// not marked as overridable are not overridden
+	defer func(orig func() bool) { isPrivileged = orig }(isPrivileged)
+	isPrivileged = func() bool { return false }
+
+	// defaults
+	c := testConfig{
+		Ratio:  1,
+		Server: testServerConfig{Host: "default.example.org", Timeout: time.Second},
+	}
+
+	if err := m.LoadConfig(&c); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := testConfig{
+		Enabled:  true,
+		LogLevel: "debug",
+		Ratio:    0.5,
+		Paths:    []string{"/a", "/b"},
+		Server: testServerConfig{
+			Host:    "file.example.org",
+			Port:    8080,
+			Timeout: 5 * time.Second,
+		},
+		Proxy:   &testServerConfig{Host: "proxy.example.org"},
+		Retries: 3,
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("got configuration %+v, expected %+v", c, expected)
+	}
+
+	os.Setenv("SINGULARITY_PLUGINCFG_EXAMPLE_ORG_MY_PLUGIN_SERVER_PORT", "http")
+	if err := m.LoadConfig(&c); err == nil {
+		t.Errorf("unexpected success with an invalid integer value")
+	}
+
+	if err := m.LoadConfig(c); err == nil {
+		t.Errorf("unexpected success with a non pointer configuration")
+	}
+}
+
+func TestLoadConfigSchema(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = []pluginapi.ConfigOption{
+		{Key: "server.port", Type: pluginapi.ConfigTypeInt, Overridable: true},
+		{Key: "server.host", Type: pluginapi.ConfigTypeString},
+		{Key: "log-level", Type: pluginapi.ConfigTypeString},
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+	if err := ioutil.WriteFile(m.configName(), []byte("server:\n  host: file.example.org\n  port: 80\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+
+	env := map[string]string{
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_TEST_SERVER_PORT": "8080",
+		"SINGULARITY_PLUGINCFG_EXAMPLE_ORG_TEST_SERVER_HOST": "env.example.org",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	defer func(orig func() bool) { isPrivileged = orig }(isPrivileged)
+
+	tests := []struct {
+		name       string
+		privileged bool
+		expected   testServerConfig
+	}{
+		{"Unprivileged", false, testServerConfig{Host: "env.example.org", Port: 8080}},
+		{"Privileged", true, testServerConfig{Host: "file.example.org", Port: 8080}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isPrivileged = func() bool { return tt.privileged }
+
+			var c testConfig
+			if err := m.LoadConfig(&c); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.Server != tt.expected {
+				t.Errorf("got server configuration %+v, expected %+v", c.Server, tt.expected)
+			}
+
+			values, err := GetEffectiveConfig(m.Name, lookup)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expected := map[string]interface{}{"server.host": tt.expected.Host, "server.port": tt.expected.Port}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("got effective configuration %v, expected %v", values, expected)
+			}
+		})
+	}
+
+	// the file values are left untouched
+	values, err := GetConfig(m.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if values["server.port"] != 80 {
+		t.Errorf("got file configuration %v", values)
+	}
+
+	// the overrides are checked against the schema
+	isPrivileged = func() bool { return false }
+	env["SINGULARITY_PLUGINCFG_EXAMPLE_ORG_TEST_SERVER_PORT"] = "http"
+	if _, err := GetEffectiveConfig(m.Name, lookup); err == nil {
+		t.Errorf("unexpected success with an invalid override")
+	}
+}
+
+func TestIsPrivileged(t *testing.T) {
+	defer SetPrivileged(false)
+
+	// a setuid starter stage after dropping its privileges
+	SetPrivileged(true)
+	if !isPrivileged() {
+		t.Errorf("setuid workflow not reported as privileged")
+	}
+
+	SetPrivileged(false)
+	expected := os.Geteuid() == 0 || os.Geteuid() != os.Getuid() || os.Getegid() != os.Getgid()
+	if isPrivileged() != expected {
+		t.Errorf("got privileged %v, expected %v", isPrivileged(), expected)
+	}
+}
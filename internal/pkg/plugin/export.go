@@ -0,0 +1,397 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+const (
+	// exportVersion is the version of the export archive format.
+	exportVersion = 1
+	// exportIndexName is the name of the index within an export archive.
+	exportIndexName = "index.json"
+	// exportPluginsDir is the directory holding the plugins within
+	// an export archive.
+	exportPluginsDir = "plugins"
+	// exportMetaName is the name of the meta file of a plugin within
+	// an export archive.
+	exportMetaName = "meta.json"
+	// exportFilesDir is the directory holding the files of a plugin
+	// within an export archive.
+	exportFilesDir = "files"
+)
+
+// exportIndex describes the content of an export archive.
+type exportIndex struct {
+	Version int           `json:"version"`
+	Plugins []exportEntry `json:"plugins"`
+}
+
+// exportEntry describes a plugin stored in an export archive.
+type exportEntry struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// exportDir returns the directory of the plugin "name" within an
+// export archive, the plugin ID is used to not depend on the name
+// content.
+func exportDir(name string) string {
+	return path.Join(exportPluginsDir, pluginIDFromName(name))
+}
+
+// Export writes to w a tar archive of all the plugins installed in
+// rootDir: their image, meta information and the content of their
+// directory, like the configuration file. The plugin object is not
+// exported as it's extracted from the image during Import.
+func Export(w io.Writer) error {
+	metas, err := List()
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	index := exportIndex{Version: exportVersion}
+	for _, m := range metas {
+		index.Plugins = append(index.Plugins, exportEntry{Name: m.Name, Enabled: m.Enabled})
+	}
+
+	data, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return fmt.Errorf("while encoding export index: %s", err)
+	}
+	if err := writeTarData(tw, exportIndexName, data); err != nil {
+		return err
+	}
+
+	for _, m := range metas {
+		sylog.Debugf("Exporting plugin %q", m.Name)
+
+		if err := m.export(tw); err != nil {
+			return fmt.Errorf("while exporting plugin %q: %s", m.Name, err)
+		}
+	}
+
+	return tw.Close()
+}
+
+func (m *Meta) export(tw *tar.Writer) error {
+	dir := exportDir(m.Name)
+
+	// the meta file is copied as is to not lose any data
+	data, err := ioutil.ReadFile(m.metaName())
+	if err != nil {
+		return err
+	}
+	if err := writeTarData(tw, path.Join(dir, exportMetaName), data); err != nil {
+		return err
+	}
+
+	root := m.path()
+	files := path.Join(dir, exportFilesDir)
+
+	// a compressed image is exported as the original image,
+	// it's compressed again on import as recorded in the meta
+	image, release, err := m.openImage()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == m.binaryName() || p == metaPath(m.Name) {
+			return nil
+		}
+		if !fi.IsDir() && !fi.Mode().IsRegular() {
+			sylog.Warningf("Skipping %s: not a regular file", p)
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		if m.ImageCompression != "" && p == m.storedImageName() {
+			ifi, err := os.Stat(image)
+			if err != nil {
+				return err
+			}
+			rel = nameImage
+			hdr.Size = ifi.Size()
+			p = image
+		}
+		hdr.Name = path.Join(files, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		// ownership is not meaningful across nodes
+		hdr.Uid, hdr.Gid = 0, 0
+		hdr.Uname, hdr.Gname = "", ""
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+func writeTarData(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import restores into rootDir the plugins exported by Export from
+// the tar archive read from r. Each plugin is installed from its image
+// with the same checks as Install, its meta file is written last and
+// a plugin failing to install is removed. Plugins already
+// installed are not modified and reported as conflicts in the
+// returned error, other plugins are imported anyway.
+func Import(r io.Reader) error {
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+
+	staging, err := ioutil.TempDir(rootDir, ".import-")
+	if err != nil {
+		return fmt.Errorf("while creating staging directory: %s", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractArchive(r, staging); err != nil {
+		return fmt.Errorf("while extracting archive: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(staging, exportIndexName))
+	if err != nil {
+		return fmt.Errorf("while reading archive index: %s", err)
+	}
+
+	var index exportIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("while decoding archive index: %s", err)
+	}
+	if index.Version != exportVersion {
+		return fmt.Errorf("unsupported archive version %d", index.Version)
+	}
+
+	var errs []error
+
+	// a plugin depending on another plugin of the archive is only
+	// installed after it, the failing plugins are imported again
+	// as long as the previous pass imported a plugin
+	pending := index.Plugins
+	for len(pending) > 0 {
+		var failed []exportEntry
+		errs = nil
+
+		for _, e := range pending {
+			sylog.Debugf("Importing plugin %q", e.Name)
+
+			dir := filepath.Join(staging, filepath.FromSlash(exportDir(e.Name)))
+			if err := importPlugin(e.Name, dir); err != nil {
+				failed = append(failed, e)
+				errs = append(errs, fmt.Errorf("plugin %q: %s", e.Name, err))
+			}
+		}
+
+		if len(failed) == len(pending) {
+			break
+		}
+		pending = failed
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+
+	case 1:
+		return errs[0]
+
+	default:
+		var b strings.Builder
+		for i, err := range errs {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			b.WriteString(err.Error())
+		}
+		return errors.New(b.String())
+	}
+}
+
+// importPlugin installs the plugin "name" staged in dir. The plugin is
+// installed from its image with the checks of Install, the archived
+// meta only provides the state of the plugin on the exporting node:
+// its enable state, history, labels and configuration.
+func importPlugin(name, dir string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, exportMetaName))
+	if err != nil {
+		return err
+	}
+	archived, err := loadVerifiedMeta(exportMetaName, data)
+	if err != nil {
+		return err
+	}
+	if archived.Name != name {
+		return fmt.Errorf("unexpected plugin name %q in meta", archived.Name)
+	}
+
+	// never trust the name to stay within rootDir
+	if rel, err := filepath.Rel(rootDir, archived.path()); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("invalid plugin name")
+	}
+
+	if _, err := loadMetaByName(name); err == nil {
+		return fmt.Errorf("conflicts with the installed plugin")
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	files := filepath.Join(dir, exportFilesDir)
+
+	// archives exported with the legacy layout
+	legacyImage := filepath.Join(files, legacyNameImage)
+	if _, err := os.Stat(legacyImage); err == nil {
+		if err := os.Rename(legacyImage, filepath.Join(files, nameImage)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(archived.path()); err == nil {
+		return fmt.Errorf("plugin directory %s already exists", archived.path())
+	}
+	if err := os.MkdirAll(filepath.Dir(archived.path()), 0755); err != nil {
+		return err
+	}
+
+	// the files are copied to keep them staged if the plugin
+	// is imported again once its dependencies are installed
+	err = copyTree(files, archived.path())
+	if err == nil {
+		_, err = installSIF(filepath.Join(files, nameImage), name, installOptions{
+			compressImage: archived.ImageCompression != "",
+			keepState:     true,
+			restored:      archived,
+		})
+	}
+	if err != nil {
+		// nothing is left of a plugin failing to install
+		os.RemoveAll(archived.path())
+		if err := removeNameDirs(name); err != nil {
+			sylog.Debugf("While removing directories of plugin %q: %s", name, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// extractArchive extracts the tar archive read from r into dir. Only
+// regular files and directories are accepted and any path which could
+// end up outside of dir is rejected. The modes recorded in the archive
+// are ignored, files are created with mode 0644 and directories with
+// mode 0755.
+func extractArchive(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: unsupported file type", hdr.Name)
+		}
+	}
+}
+
+// archivePath validates the path of an archive entry and returns
+// it as a relative local path.
+func archivePath(name string) (string, error) {
+	if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("%s: invalid path", name)
+	}
+	for _, elem := range strings.Split(strings.TrimSuffix(name, "/"), "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%s: path traversal is not allowed", name)
+		}
+	}
+
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", fmt.Errorf("%s: invalid path", name)
+	}
+
+	return filepath.FromSlash(clean), nil
+}
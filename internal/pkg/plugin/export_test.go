@@ -0,0 +1,372 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// createTestPlugin creates a plugin SIF image in dir with a dummy
+// plugin object and returns its path.
+func createTestPlugin(t *testing.T, dir string, manifest pluginapi.Manifest) string {
+	return createArchTestPlugin(t, dir, manifest, runtime.GOARCH)
+}
+
+// createArchTestPlugin creates a plugin SIF image in dir with a dummy
+// plugin object for each of the architectures archs and returns its
+// path. The object content is "dummy plugin object for <arch>".
+func createArchTestPlugin(t *testing.T, dir string, manifest pluginapi.Manifest, archs ...string) string {
+	binaries := make([]testBinary, len(archs))
+	for i, arch := range archs {
+		binaries[i] = testBinary{name: pluginBinaryName, arch: arch}
+	}
+	return createBinariesTestPlugin(t, dir, manifest, binaries...)
+}
+
+// testBinary is a dummy binary object of a test plugin image.
+type testBinary struct {
+	name string
+	arch string
+}
+
+// content returns the content of the dummy binary object, "dummy
+// plugin object for <arch>" for the plugin.so objects and "dummy
+// <name> object for <arch>" for the other ones.
+func (b testBinary) content() string {
+	if b.name == pluginBinaryName {
+		return "dummy plugin object for " + b.arch
+	}
+	return "dummy " + b.name + " object for " + b.arch
+}
+
+// createBinariesTestPlugin creates a plugin SIF image in dir with the
+// dummy binary objects binaries and returns its path.
+func createBinariesTestPlugin(t *testing.T, dir string, manifest pluginapi.Manifest, binaries ...testBinary) string {
+	var inputs []sif.DescriptorInput
+
+	for _, b := range binaries {
+		obj := []byte(b.content())
+		objInput := sif.DescriptorInput{
+			Datatype: sif.DataPartition,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    b.name,
+			Data:     obj,
+			Size:     int64(len(obj)),
+		}
+		if err := objInput.SetPartExtra(sif.FsRaw, sif.PartData, sif.GetSIFArch(b.arch)); err != nil {
+			t.Fatalf("while setting partition information: %s", err)
+		}
+		inputs = append(inputs, objInput)
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("while encoding manifest: %s", err)
+	}
+	inputs = append(inputs, sif.DescriptorInput{
+		Datatype: sif.DataGenericJSON,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    pluginManifestName,
+		Data:     data,
+		Size:     int64(len(data)),
+	})
+
+	sifPath := filepath.Join(dir, "plugin.sif")
+	_, err = sif.CreateContainer(sif.CreateInfo{
+		Pathname:   sifPath,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	})
+	if err != nil {
+		t.Fatalf("while creating plugin image: %s", err)
+	}
+
+	return sifPath
+}
+
+// installTestPlugin installs the plugin image sifPath without
+// loading the plugin object, which is not a real one.
+func installTestPlugin(t *testing.T, sifPath string, name string, enabled bool) {
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	m := &Meta{
+		Name:        name,
+		Enabled:     enabled,
+		Callbacks:   []string{"cli.Command"},
+		InstalledBy: currentActor(),
+		ImageID:     fimg.Header.ID.String(),
+		sifFile:     &fimg,
+	}
+
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installImage(); err != nil {
+		t.Fatalf("while installing plugin image: %s", err)
+	}
+	if err := m.installBinary(); err != nil {
+		t.Fatalf("while installing plugin object: %s", err)
+	}
+	if err := m.installConfig(nil, nil); err != nil {
+		t.Fatalf("while installing plugin configuration: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing plugin meta: %s", err)
+	}
+}
+
+// stubInstallObject replaces the installation of the dummy plugin
+// objects, which can't be loaded, and returns a function restoring it.
+func stubInstallObject() func() {
+	orig := installObject
+	installObject = func(m *Meta) error {
+		m.Callbacks = []string{"cli.Command"}
+		return nil
+	}
+	return func() { installObject = orig }
+}
+
+func TestExportImport(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer stubInstallObject()()
+
+	dir, err := ioutil.TempDir("", "plugin-export-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+
+	installTestPlugin(t, sifPath, "example.org/test", true)
+	installTestPlugin(t, sifPath, "example.org/other", false)
+
+	// customized configuration must be restored
+	m, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if err := ioutil.WriteFile(m.ConfigPath, []byte("key: value\n"), 0644); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+
+	expected, err := List()
+	if err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	}
+
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+
+	// wipe the installation directory
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+
+	if err := Import(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+
+	actual, err := List()
+	if err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("got plugins %+v, expected %+v", actual, expected)
+	}
+
+	m, err = Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	checkConfigStatus(t, m, ConfigCustomized)
+	for _, m := range actual {
+		if _, err := os.Stat(m.binaryName()); err != nil {
+			t.Errorf("plugin object of %q not extracted: %s", m.Name, err)
+		}
+	}
+
+	// importing again reports conflicts
+	if err := Import(bytes.NewReader(archive.Bytes())); err == nil {
+		t.Errorf("unexpected success while importing installed plugins")
+	}
+}
+
+func TestImportReservedNamespace(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer stubInstallObject()()
+
+	dir, err := ioutil.TempDir("", "plugin-export-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// installed bypassing the checks of Install, the
+	// image is not signed by a key trusted for sylabs.io
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "sylabs.io/test"})
+	installTestPlugin(t, sifPath, "sylabs.io/test", true)
+
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+
+	err = Import(&archive)
+	if err == nil || !strings.Contains(err.Error(), "reserved namespace") {
+		t.Errorf("unexpected error for a plugin of a reserved namespace: %v", err)
+	}
+	if _, err := os.Stat((&Meta{Name: "sylabs.io/test"}).path()); !os.IsNotExist(err) {
+		t.Errorf("plugin of a reserved namespace was imported")
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer stubInstallObject()()
+
+	dir, err := ioutil.TempDir("", "plugin-export-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	// files created by the plugin with modes not to be restored
+	m := &Meta{Name: "example.org/test"}
+	data := filepath.Join(m.path(), "data")
+	file := filepath.Join(data, "file")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatalf("while creating directory: %s", err)
+	}
+	if err := ioutil.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatalf("while writing file: %s", err)
+	}
+	if err := os.Chmod(file, 0777|os.ModeSetuid); err != nil {
+		t.Fatalf("while changing file mode: %s", err)
+	}
+	if err := os.Chmod(data, 0777); err != nil {
+		t.Fatalf("while changing directory mode: %s", err)
+	}
+
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+	if err := Import(&archive); err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+
+	for path, expected := range map[string]os.FileMode{
+		file: 0644,
+		data: os.ModeDir | 0755,
+	} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("while getting %s information: %s", path, err)
+		}
+		if fi.Mode() != expected {
+			t.Errorf("got mode %s for %s, expected %s", fi.Mode(), path, expected)
+		}
+	}
+}
+
+func TestImportFailure(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-export-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+
+	// the installation fails once the plugin files are in place
+	defer func(orig func(*Meta) error) { installObject = orig }(installObject)
+	installObject = func(m *Meta) error {
+		return errors.New("install failure")
+	}
+
+	if err := Import(&archive); err == nil {
+		t.Fatalf("unexpected success while importing a failing plugin")
+	}
+
+	entries, err := ioutil.ReadDir(rootDir)
+	if err != nil {
+		t.Fatalf("while reading %s: %s", rootDir, err)
+	}
+	for _, e := range entries {
+		t.Errorf("unexpected %s left in %s", e.Name(), rootDir)
+	}
+}
+
+func TestImportPathTraversal(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	cases := []string{
+		"../evil",
+		"plugins/../../evil",
+		"/etc/evil",
+	}
+
+	for _, name := range cases {
+		t.Run(name, func(t *testing.T) {
+			var archive bytes.Buffer
+
+			tw := tar.NewWriter(&archive)
+			if err := writeTarData(tw, name, []byte("evil")); err != nil {
+				t.Fatalf("while writing archive: %s", err)
+			}
+			tw.Close()
+
+			if err := Import(&archive); err == nil {
+				t.Errorf("unexpected success while importing %q", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// HistoryInstall is the action recorded for a first installation.
+	HistoryInstall = "install"
+	// HistoryUpgrade is the action recorded for an installation
+	// replacing an installed plugin.
+	HistoryUpgrade = "upgrade"
+	// HistoryRebuild is the action recorded for the reinstallation
+	// of a plugin recompiled from its source, see Rebuild.
+	HistoryRebuild = "rebuild"
+)
+
+// defaultHistorySize is the number of history entries kept when
+// singularity.conf can't be read.
+const defaultHistorySize = 10
+
+// HistoryEntry records an installation of a plugin.
+type HistoryEntry struct {
+	// Time is the time of the installation.
+	Time time.Time `json:"Time"`
+	// Action is the kind of installation, HistoryInstall,
+	// HistoryUpgrade or HistoryRebuild.
+	Action string `json:"Action"`
+	// Source is the path of the installed SIF image, or of the
+	// plugin source directory for HistoryRebuild.
+	Source string `json:"Source"`
+	// Digest is the sha256 of the installed SIF image.
+	Digest string `json:"Digest"`
+	// Version is the plugin version from the manifest.
+	Version string `json:"Version"`
+	// Actor identifies the user who installed the plugin.
+	Actor *Actor `json:"Actor"`
+}
+
+// History holds the installation history of a plugin, oldest entry
+// first. It's informational only, entries which can't be decoded are
+// skipped instead of failing to load the plugin meta.
+type History []HistoryEntry
+
+// UnmarshalJSON decodes the history, skipping invalid entries.
+func (h *History) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		sylog.Warningf("Ignoring invalid plugin history: %s", err)
+		*h = nil
+		return nil
+	}
+
+	entries := make(History, 0, len(raw))
+	for i, r := range raw {
+		var e HistoryEntry
+		if err := json.Unmarshal(r, &e); err != nil {
+			sylog.Warningf("Ignoring invalid plugin history entry %d: %s", i, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	*h = entries
+
+	return nil
+}
+
+// add returns the history with e appended, the oldest entries
+// are evicted to keep at most size entries.
+func (h History) add(e HistoryEntry, size int) History {
+	entries := append(History{}, h...)
+	entries = append(entries, e)
+	if size > 0 && len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	return entries
+}
+
+// historySize returns the number of history entries kept per
+// plugin set in singularity.conf.
+func historySize() int {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, keeping %d plugin history entries: %s", buildcfg.SINGULARITY_CONF_FILE, defaultHistorySize, err)
+		return defaultHistorySize
+	}
+	return int(c.PluginHistorySize)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	var h History
+
+	for i := 0; i < 5; i++ {
+		h = h.add(HistoryEntry{Version: string(rune('a' + i))}, 3)
+	}
+
+	var versions []string
+	for _, e := range h {
+		versions = append(versions, e.Version)
+	}
+	if expected := []string{"c", "d", "e"}; !reflect.DeepEqual(versions, expected) {
+		t.Errorf("got history %v, expected %v", versions, expected)
+	}
+
+	// a zero size keeps the whole history
+	if h = h.add(HistoryEntry{Version: "f"}, 0); len(h) != 4 {
+		t.Errorf("got %d entries, expected 4", len(h))
+	}
+}
+
+func TestHistoryCorruptEntries(t *testing.T) {
+	cases := []struct {
+		description string
+		meta        string
+		versions    []string
+	}{
+		{
+			description: "invalid entry",
+			meta:        `{"Name":"example.org/test","History":[{"Version":"1.0.0"},{"Time":42},{"Version":"2.0.0"}]}`,
+			versions:    []string{"1.0.0", "2.0.0"},
+		},
+		{
+			description: "invalid history",
+			meta:        `{"Name":"example.org/test","History":{"Version":"1.0.0"}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			m, err := loadFromJSON(bytes.NewReader([]byte(tc.meta)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var versions []string
+			for _, e := range m.History {
+				versions = append(versions, e.Version)
+			}
+			if !reflect.DeepEqual(versions, tc.versions) {
+				t.Errorf("got history %v, expected %v", versions, tc.versions)
+			}
+		})
+	}
+}
+
+func TestHistoryExportImport(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer stubInstallObject()()
+
+	dir, err := ioutil.TempDir("", "plugin-history-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+
+	m, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	m.History = m.History.add(HistoryEntry{
+		Time:    time.Date(2020, time.March, 2, 10, 30, 0, 0, time.UTC),
+		Action:  HistoryInstall,
+		Source:  sifPath,
+		Digest:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		Version: "1.0.0",
+		Actor:   currentActor(),
+	}, defaultHistorySize)
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	var archive bytes.Buffer
+	if err := Export(&archive); err != nil {
+		t.Fatalf("unexpected export error: %s", err)
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Fatalf("while removing %s: %s", rootDir, err)
+	}
+	if err := Import(&archive); err != nil {
+		t.Fatalf("unexpected import error: %s", err)
+	}
+
+	imported, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if !reflect.DeepEqual(imported.History, m.History) {
+		t.Errorf("got history %+v, expected %+v", imported.History, m.History)
+	}
+}
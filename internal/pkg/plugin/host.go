@@ -0,0 +1,438 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/plugin/callback"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	clicallback "github.com/sylabs/singularity/pkg/plugin/callback/cli"
+	singularitycallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/engine/singularity"
+	fakerootcallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/fakeroot"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// IsolationManifest is the plugin isolation policy running
+	// a plugin in an isolated process only when its manifest
+	// requests it.
+	IsolationManifest = "manifest"
+	// IsolationAlways is the plugin isolation policy running
+	// all plugins in an isolated process.
+	IsolationAlways = "always"
+)
+
+const (
+	// hostRequestFd is the file descriptor from which the plugin
+	// host process reads the requests.
+	hostRequestFd = 3
+	// hostResponseFd is the file descriptor to which the plugin
+	// host process writes the responses.
+	hostResponseFd = 4
+)
+
+// hostBinary is the binary executed to run isolated plugins.
+var hostBinary = filepath.Join(buildcfg.BINDIR, "singularity")
+
+// isolationPolicy returns the plugin isolation policy set
+// in singularity.conf.
+func isolationPolicy() string {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, using %s plugin isolation: %s", buildcfg.SINGULARITY_CONF_FILE, IsolationManifest, err)
+		return IsolationManifest
+	}
+	return c.PluginIsolation
+}
+
+// HostConfig is the representation of the engine configuration
+// passed to and returned by an isolated plugin.
+type HostConfig struct {
+	EngineName   string
+	ContainerID  string
+	EngineConfig json.RawMessage
+	// File is not part of the engine configuration JSON,
+	// it's passed along for plugins relying on it.
+	File *singularityconf.File
+}
+
+// UserMappingArgs holds the arguments of a UserMapping callback.
+type UserMappingArgs struct {
+	Path string
+	UID  uint32
+}
+
+// PostStartProcessArgs holds the arguments of a PostStartProcess callback.
+type PostStartProcessArgs struct {
+	Config *HostConfig
+	Pid    int
+}
+
+// ContainerStartupReply holds the result of a ContainerStartup callback.
+type ContainerStartupReply struct {
+	Commands []singularityConfig.StartupCommand
+}
+
+func encodeConfig(c *config.Common) (*HostConfig, error) {
+	data, err := json.Marshal(c.EngineConfig)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding engine configuration: %s", err)
+	}
+
+	hc := &HostConfig{
+		EngineName:   c.EngineName,
+		ContainerID:  c.ContainerID,
+		EngineConfig: data,
+	}
+	if ec, ok := c.EngineConfig.(*singularityConfig.EngineConfig); ok {
+		hc.File = ec.File
+	}
+
+	return hc, nil
+}
+
+func decodeConfig(hc *HostConfig) (*config.Common, error) {
+	if hc.EngineName != singularityConfig.Name {
+		return nil, fmt.Errorf("engine %q is not supported by isolated plugins", hc.EngineName)
+	}
+
+	ec := singularityConfig.NewConfig()
+	if err := json.Unmarshal(hc.EngineConfig, ec); err != nil {
+		return nil, fmt.Errorf("while decoding engine configuration: %s", err)
+	}
+	if hc.File != nil {
+		ec.File = hc.File
+	}
+
+	return &config.Common{
+		EngineName:   hc.EngineName,
+		ContainerID:  hc.ContainerID,
+		EngineConfig: ec,
+	}, nil
+}
+
+// hostService exposes the callbacks of a plugin object
+// over RPC, it runs in the plugin host process.
+type hostService struct {
+	pl *pluginapi.Plugin
+}
+
+// Install runs the plugin Install function with the plugin
+// directory and returns the names of the plugin callbacks.
+func (s *hostService) Install(dir string, callbacks *[]string) error {
+	if s.pl.Install != nil {
+		if err := s.pl.Install(dir); err != nil {
+			return err
+		}
+	}
+	*callbacks = callback.Names(s.pl.Callbacks)
+	return nil
+}
+
+// Callbacks returns the names of the plugin callbacks
+// without running any plugin code.
+func (s *hostService) Callbacks(_ struct{}, callbacks *[]string) error {
+	*callbacks = callback.Names(s.pl.Callbacks)
+	return nil
+}
+
+// EngineConfig calls the SingularityEngineConfig callbacks
+// of the plugin and returns the modified configuration.
+func (s *hostService) EngineConfig(args *HostConfig, reply *HostConfig) error {
+	c, err := decodeConfig(args)
+	if err != nil {
+		return err
+	}
+
+	for _, cb := range s.pl.Callbacks {
+		if fn, ok := cb.(clicallback.SingularityEngineConfig); ok {
+			fn(c)
+		}
+	}
+
+	hc, err := encodeConfig(c)
+	if err != nil {
+		return err
+	}
+	*reply = *hc
+
+	return nil
+}
+
+// UserMapping calls the UserMapping callback of the plugin.
+func (s *hostService) UserMapping(args *UserMappingArgs, reply *specs.LinuxIDMapping) error {
+	for _, cb := range s.pl.Callbacks {
+		if fn, ok := cb.(fakerootcallback.UserMapping); ok {
+			m, err := fn(args.Path, args.UID)
+			if err != nil {
+				return err
+			}
+			*reply = *m
+			return nil
+		}
+	}
+	return fmt.Errorf("no user mapping callback registered")
+}
+
+// PostStartProcess calls the PostStartProcess callbacks of the plugin.
+func (s *hostService) PostStartProcess(args *PostStartProcessArgs, reply *bool) error {
+	c, err := decodeConfig(args.Config)
+	if err != nil {
+		return err
+	}
+
+	for _, cb := range s.pl.Callbacks {
+		if fn, ok := cb.(singularitycallback.PostStartProcess); ok {
+			if err := fn(c, args.Pid); err != nil {
+				return err
+			}
+		}
+	}
+	*reply = true
+
+	return nil
+}
+
+// ContainerStartup calls the ContainerStartup callbacks of the plugin.
+func (s *hostService) ContainerStartup(args *HostConfig, reply *ContainerStartupReply) error {
+	c, err := decodeConfig(args)
+	if err != nil {
+		return err
+	}
+
+	for _, cb := range s.pl.Callbacks {
+		if fn, ok := cb.(singularitycallback.ContainerStartup); ok {
+			commands, err := fn(c)
+			if err != nil {
+				return err
+			}
+			reply.Commands = append(reply.Commands, commands...)
+		}
+	}
+
+	return nil
+}
+
+// pipeConn joins the two pipes used to communicate with
+// the plugin host process.
+type pipeConn struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (p pipeConn) Close() error {
+	rerr := p.ReadCloser.Close()
+	werr := p.WriteCloser.Close()
+	if rerr != nil {
+		return rerr
+	}
+	return werr
+}
+
+// ServeHost loads the plugin object located at path and serves
+// its callbacks to the parent process until it closes the request
+// pipe. This is the entry point of the plugin host process.
+func ServeHost(path string) error {
+	pl, err := LoadObject(path)
+	if err != nil {
+		return fmt.Errorf("while loading plugin %s: %s", path, err)
+	}
+	pl.AssetsPath = assetsPath(path)
+
+	conn := pipeConn{
+		ReadCloser:  os.NewFile(hostRequestFd, "plugin-request"),
+		WriteCloser: os.NewFile(hostResponseFd, "plugin-response"),
+	}
+
+	return serveHost(pl, conn)
+}
+
+func serveHost(pl *pluginapi.Plugin, conn io.ReadWriteCloser) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Host", &hostService{pl: pl}); err != nil {
+		return fmt.Errorf("while registering plugin host service: %s", err)
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// host is the client side of a plugin running in an
+// isolated process.
+type host struct {
+	name   string
+	cmd    *exec.Cmd
+	client *rpc.Client
+}
+
+// startHost starts a plugin host process for the plugin m.
+func startHost(m *Meta) (*host, error) {
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("while creating plugin request pipe: %s", err)
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, fmt.Errorf("while creating plugin response pipe: %s", err)
+	}
+
+	cmd := exec.Command(hostBinary, "plugin", "host", m.binaryName())
+	// the standard output is reserved to singularity, anything
+	// printed by the plugin goes to the standard error
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// the plugin message levels set on the command line
+	cmd.Env = append(os.Environ(), sylog.GetNamedEnvVar())
+	cmd.ExtraFiles = []*os.File{reqR, respW}
+
+	err = cmd.Start()
+	// the child ends of the pipes are not needed anymore
+	reqR.Close()
+	respW.Close()
+	if err != nil {
+		reqW.Close()
+		respR.Close()
+		return nil, fmt.Errorf("while starting plugin host process: %s", err)
+	}
+
+	sylog.Debugf("Plugin %q running in isolated process %d", m.Name, cmd.Process.Pid)
+
+	h := newHost(m.Name, pipeConn{ReadCloser: respR, WriteCloser: reqW})
+	h.cmd = cmd
+
+	return h, nil
+}
+
+func newHost(name string, conn io.ReadWriteCloser) *host {
+	return &host{
+		name:   name,
+		client: jsonrpc.NewClient(conn),
+	}
+}
+
+// call calls the method of the plugin host service, an error is
+// returned if the plugin failed or if the plugin process died.
+func (h *host) call(method string, args interface{}, reply interface{}) error {
+	if err := h.client.Call("Host."+method, args, reply); err != nil {
+		if err == rpc.ErrShutdown || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("isolated plugin %q terminated unexpectedly", h.name)
+		}
+		return err
+	}
+	return nil
+}
+
+// close terminates the plugin host process.
+func (h *host) close() error {
+	err := h.client.Close()
+	if h.cmd != nil {
+		if werr := h.cmd.Wait(); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+func (h *host) install(dir string) ([]string, error) {
+	var callbacks []string
+	if err := h.call("Install", dir, &callbacks); err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
+
+func (h *host) callbacks() ([]string, error) {
+	var callbacks []string
+	if err := h.call("Callbacks", struct{}{}, &callbacks); err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
+
+func (h *host) engineConfig(c *config.Common) {
+	args, err := encodeConfig(c)
+	if err != nil {
+		sylog.Warningf("Plugin %q: %s", h.name, err)
+		return
+	}
+
+	var reply HostConfig
+	if err := h.call("EngineConfig", args, &reply); err != nil {
+		sylog.Warningf("Plugin %q: %s", h.name, err)
+		return
+	}
+
+	nc, err := decodeConfig(&reply)
+	if err != nil {
+		sylog.Warningf("Plugin %q: %s", h.name, err)
+		return
+	}
+
+	c.ContainerID = nc.ContainerID
+	if ec, ok := c.EngineConfig.(*singularityConfig.EngineConfig); ok {
+		*ec = *nc.EngineConfig.(*singularityConfig.EngineConfig)
+	}
+}
+
+func (h *host) userMapping(path string, uid uint32) (*specs.LinuxIDMapping, error) {
+	var m specs.LinuxIDMapping
+	if err := h.call("UserMapping", &UserMappingArgs{Path: path, UID: uid}, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (h *host) postStartProcess(c *config.Common, pid int) error {
+	hc, err := encodeConfig(c)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	return h.call("PostStartProcess", &PostStartProcessArgs{Config: hc, Pid: pid}, &ok)
+}
+
+func (h *host) containerStartup(c *config.Common) ([]singularityConfig.StartupCommand, error) {
+	hc, err := encodeConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	var reply ContainerStartupReply
+	if err := h.call("ContainerStartup", hc, &reply); err != nil {
+		return nil, err
+	}
+	return reply.Commands, nil
+}
+
+// callback returns a callback of the type identified by name
+// forwarding the calls to the isolated plugin, nil is returned
+// if the callback can't be served by an isolated plugin.
+func (h *host) callback(name string) pluginapi.Callback {
+	switch name {
+	case callback.Name((clicallback.SingularityEngineConfig)(nil)):
+		return clicallback.SingularityEngineConfig(h.engineConfig)
+	case callback.Name((fakerootcallback.UserMapping)(nil)):
+		return fakerootcallback.UserMapping(h.userMapping)
+	case callback.Name((singularitycallback.PostStartProcess)(nil)):
+		return singularitycallback.PostStartProcess(h.postStartProcess)
+	case callback.Name((singularitycallback.ContainerStartup)(nil)):
+		return singularitycallback.ContainerStartup(h.containerStartup)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	clicallback "github.com/sylabs/singularity/pkg/plugin/callback/cli"
+	singularitycallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/engine/singularity"
+	fakerootcallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/fakeroot"
+	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
+)
+
+// testHost serves pl through an in-memory connection and
+// returns the client side.
+func testHost(t *testing.T, pl *pluginapi.Plugin) *host {
+	server, client := net.Pipe()
+
+	go serveHost(pl, server)
+
+	return newHost("example.org/test", client)
+}
+
+func TestHost(t *testing.T) {
+	installDir := ""
+	postStartPid := 0
+
+	pl := &pluginapi.Plugin{
+		Callbacks: []pluginapi.Callback{
+			(clicallback.SingularityEngineConfig)(func(c *config.Common) {
+				c.EngineConfig.(*singularityConfig.EngineConfig).SetImage("/plugin.sif")
+			}),
+			(fakerootcallback.UserMapping)(func(path string, uid uint32) (*specs.LinuxIDMapping, error) {
+				if path == "" {
+					return nil, fmt.Errorf("empty path")
+				}
+				return &specs.LinuxIDMapping{HostID: uid, ContainerID: 1, Size: 65536}, nil
+			}),
+			(singularitycallback.PostStartProcess)(func(c *config.Common, pid int) error {
+				postStartPid = pid
+				return nil
+			}),
+		},
+		Install: func(dir string) error {
+			installDir = dir
+			return nil
+		},
+	}
+
+	h := testHost(t, pl)
+	defer h.close()
+
+	registered, err := h.callbacks()
+	if err != nil {
+		t.Fatalf("unexpected callbacks error: %s", err)
+	}
+	if installDir != "" {
+		t.Errorf("install called while getting callbacks")
+	}
+
+	callbacks, err := h.install("/plugin/dir")
+	if err != nil {
+		t.Fatalf("unexpected install error: %s", err)
+	}
+	if installDir != "/plugin/dir" {
+		t.Errorf("install called with %q", installDir)
+	}
+	if len(callbacks) != len(pl.Callbacks) {
+		t.Fatalf("got callbacks %v", callbacks)
+	}
+	if !reflect.DeepEqual(registered, callbacks) {
+		t.Errorf("got callbacks %v, expected %v", registered, callbacks)
+	}
+	for _, name := range callbacks {
+		if h.callback(name) == nil {
+			t.Errorf("callback %s not served by isolated plugin", name)
+		}
+	}
+
+	ec := singularityConfig.NewConfig()
+	common := &config.Common{
+		EngineName:   singularityConfig.Name,
+		ContainerID:  "test",
+		EngineConfig: ec,
+	}
+	h.callback(callbacks[0]).(clicallback.SingularityEngineConfig)(common)
+	if ec.GetImage() != "/plugin.sif" {
+		t.Errorf("engine configuration not updated, got image %q", ec.GetImage())
+	}
+
+	m, err := h.callback(callbacks[1]).(fakerootcallback.UserMapping)("/etc/subuid", 1000)
+	if err != nil {
+		t.Fatalf("unexpected user mapping error: %s", err)
+	}
+	expected := &specs.LinuxIDMapping{HostID: 1000, ContainerID: 1, Size: 65536}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got mapping %+v, expected %+v", m, expected)
+	}
+	if _, err := h.callback(callbacks[1]).(fakerootcallback.UserMapping)("", 1000); err == nil {
+		t.Errorf("plugin error not reported")
+	}
+
+	if err := h.callback(callbacks[2]).(singularitycallback.PostStartProcess)(common, 42); err != nil {
+		t.Fatalf("unexpected post start process error: %s", err)
+	}
+	if postStartPid != 42 {
+		t.Errorf("post start process called with pid %d", postStartPid)
+	}
+}
+
+func TestHostCallback(t *testing.T) {
+	h := &host{}
+
+	cases := []struct {
+		description string
+		callback    pluginapi.Callback
+		isolated    bool
+	}{
+		{"command", (clicallback.Command)(nil), false},
+		{"monitor", (singularitycallback.MonitorContainer)(nil), false},
+		{"engine config", (clicallback.SingularityEngineConfig)(nil), true},
+		{"user mapping", (fakerootcallback.UserMapping)(nil), true},
+		{"post start", (singularitycallback.PostStartProcess)(nil), true},
+		{"container startup", (singularitycallback.ContainerStartup)(nil), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			c := h.callback(fmt.Sprintf("%T", tc.callback))
+			if (c != nil) != tc.isolated {
+				t.Errorf("got isolated callback %v, expected %v", c != nil, tc.isolated)
+			}
+		})
+	}
+}
+
+func TestHostTerminated(t *testing.T) {
+	server, client := net.Pipe()
+	server.Close()
+
+	h := newHost("example.org/test", client)
+	if _, err := h.install("/plugin/dir"); err == nil {
+		t.Errorf("unexpected success with a terminated plugin process")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ShortIDLength is the length of the short form of plugin IDs. With
+// 12 hexadecimal digits, the probability of a collision between two
+// plugins among a thousand installed is below one in five hundred
+// million, ambiguous prefixes are reported by LookupByID anyway.
+const ShortIDLength = 12
+
+// ID returns the unique ID of the plugin, derived from its name. It's
+// the name of the legacy meta file and of the plugin directory within
+// export archives.
+func (m *Meta) ID() string {
+	return pluginIDFromName(m.Name)
+}
+
+// ShortID returns the short form of the plugin ID.
+func (m *Meta) ShortID() string {
+	return m.ID()[:ShortIDLength]
+}
+
+// LookupByID returns the Meta information of the plugin installed under
+// rootDir whose ID is id, or starts with id. An error is returned if the
+// prefix matches more than one plugin.
+func LookupByID(id string) (*Meta, error) {
+	if id == "" || strings.Trim(strings.ToLower(id), "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("invalid plugin ID %q", id)
+	}
+	id = strings.ToLower(id)
+
+	metas, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	return lookupByID(metas, id)
+}
+
+func lookupByID(metas []*Meta, id string) (*Meta, error) {
+	var matches []*Meta
+
+	for _, m := range metas {
+		if m.ID() == id {
+			return m, nil
+		}
+		if strings.HasPrefix(m.ID(), id) {
+			matches = append(matches, m)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no plugin installed with ID %q", id)
+	case 1:
+		return matches[0], nil
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+
+	return nil, fmt.Errorf("plugin ID %q is ambiguous, it matches: %s", id, strings.Join(names, ", "))
+}
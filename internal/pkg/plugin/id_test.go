@@ -0,0 +1,96 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// collidingNames returns two plugin names whose IDs share
+// the same first n characters.
+func collidingNames(t *testing.T, n int) (string, string) {
+	seen := make(map[string]string)
+	for i := 0; i < 100000; i++ {
+		name := fmt.Sprintf("example.org/plugin-%d", i)
+		prefix := pluginIDFromName(name)[:n]
+		if other, ok := seen[prefix]; ok {
+			return other, name
+		}
+		seen[prefix] = name
+	}
+	t.Fatalf("no names with colliding ID prefix of length %d found", n)
+	return "", ""
+}
+
+func TestLookupByID(t *testing.T) {
+	first, second := collidingNames(t, 3)
+	a := &Meta{Name: first}
+	b := &Meta{Name: second}
+	metas := []*Meta{a, b}
+
+	// first index where the IDs differ
+	diff := 3
+	for a.ID()[diff] == b.ID()[diff] {
+		diff++
+	}
+
+	cases := []struct {
+		description string
+		id          string
+		expected    *Meta
+	}{
+		{"full ID", a.ID(), a},
+		{"short ID", b.ShortID(), b},
+		{"unambiguous prefix", a.ID()[:diff+1], a},
+		{"ambiguous prefix", a.ID()[:3], nil},
+		{"ambiguous longest prefix", a.ID()[:diff], nil},
+		{"unknown ID", "not-an-id", nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			m, err := lookupByID(metas, tc.id)
+			if tc.expected == nil {
+				if err == nil {
+					t.Errorf("unexpected match %q for ID %q", m.Name, tc.id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if m != tc.expected {
+				t.Errorf("got plugin %q, expected %q", m.Name, tc.expected.Name)
+			}
+		})
+	}
+}
+
+func TestLookupByIDInstalled(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/test", Enabled: true}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	found, err := LookupByID(m.ShortID())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if found.Name != m.Name {
+		t.Errorf("got plugin %q, expected %q", found.Name, m.Name)
+	}
+
+	if _, err := LookupByID("../" + m.ShortID()); err == nil {
+		t.Errorf("unexpected success with an invalid ID")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/image"
+)
+
+// ImageInfo is the information carried by the SIF format of a plugin
+// image, as displayed by siftool header.
+type ImageInfo struct {
+	// ID is the unique identifier of the image.
+	ID string
+	// Version is the version of the SIF format.
+	Version string
+	// Arch is the architecture of the primary partition recorded
+	// in the header, with Go naming, plugin images have none.
+	Arch string
+	// Created and Modified are the creation and last
+	// modification times of the image.
+	Created  time.Time
+	Modified time.Time
+	// CreatorUID and CreatorGID are the user and group owning the
+	// first data object of the image, the creator of the image.
+	CreatorUID int64
+	CreatorGID int64
+	// Fingerprints are the fingerprints of the keys recorded
+	// in the signature objects of the image.
+	Fingerprints []string
+	// InstalledID is the image identifier recorded when the plugin
+	// was installed, it's empty for image files and for the plugins
+	// installed before it was recorded.
+	InstalledID string
+}
+
+// IDMismatch returns true if the identifier of an installed plugin
+// image differs from the one recorded at installation.
+func (i ImageInfo) IDMismatch() bool {
+	return i.InstalledID != "" && i.InstalledID != i.ID
+}
+
+// Image returns the SIF information of the plugin image.
+//
+// "name" can be either the name of plugin installed under rootDir
+// or the name of an image file corresponding to a plugin, see
+// LookupImage.
+func Image(name string) (ImageInfo, error) {
+	var info ImageInfo
+
+	path := name
+	meta, err := LookupImage(name)
+	if err != nil {
+		return info, err
+	} else if meta != nil {
+		image, release, err := meta.openImage()
+		if err != nil {
+			return info, err
+		}
+		defer release()
+		path = image
+		info.InstalledID = meta.ImageID
+	}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return info, err
+	}
+	defer fimg.UnloadContainer()
+
+	h := fimg.Header
+	info.ID = h.ID.String()
+	info.Version = trimZeros(h.Version[:])
+	info.Arch = image.SIFArch(h.Arch[:])
+	info.Created = time.Unix(h.Ctime, 0)
+	info.Modified = time.Unix(h.Mtime, 0)
+
+	creator := true
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used {
+			continue
+		}
+		if creator {
+			info.CreatorUID = d.UID
+			info.CreatorGID = d.Gid
+			creator = false
+		}
+		if d.Datatype != sif.DataSignature {
+			continue
+		}
+		fp, err := d.GetEntityString()
+		if err != nil {
+			sylog.Debugf("Could not read fingerprint of signature %d: %s", d.ID, err)
+			continue
+		}
+		info.Fingerprints = append(info.Fingerprints, fp)
+	}
+
+	return info, nil
+}
+
+// trimZeros returns the string stored in the fixed size header field b.
+func trimZeros(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
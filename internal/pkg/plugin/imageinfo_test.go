@@ -0,0 +1,103 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestImage(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-image-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	signer, err := openpgp.NewEntity("Signer", "", "signer@example.org", nil)
+	if err != nil {
+		t.Fatalf("while creating key: %s", err)
+	}
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/image"})
+	signTestPlugin(t, sifPath, signer)
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	id := fimg.Header.ID.String()
+	fimg.UnloadContainer()
+
+	info, err := Image(sifPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.ID != id || info.Version != sif.HdrVersion || info.Arch != "" {
+		t.Errorf("got header %s %s %q, expected %s %s \"\"", info.ID, info.Version, info.Arch, id, sif.HdrVersion)
+	}
+	if info.Created.IsZero() || info.Modified.Before(info.Created) {
+		t.Errorf("got creation time %s and modification time %s", info.Created, info.Modified)
+	}
+	if info.CreatorUID != int64(os.Getuid()) || info.CreatorGID != int64(os.Getgid()) {
+		t.Errorf("got creator %d:%d, expected %d:%d", info.CreatorUID, info.CreatorGID, os.Getuid(), os.Getgid())
+	}
+	fp := []string{fmt.Sprintf("%0X", signer.PrimaryKey.Fingerprint[:])}
+	if !reflect.DeepEqual(info.Fingerprints, fp) {
+		t.Errorf("got fingerprints %v, expected %v", info.Fingerprints, fp)
+	}
+	if info.InstalledID != "" || info.IDMismatch() {
+		t.Errorf("unexpected installed image ID %q for an image file", info.InstalledID)
+	}
+
+	installTestPlugin(t, sifPath, "example.com/image", true)
+
+	info, err = Image("example.com/image")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.ID != id || info.InstalledID != id || info.IDMismatch() {
+		t.Errorf("got image ID %s and installed ID %s, expected %s", info.ID, info.InstalledID, id)
+	}
+
+	// swap the installed image with another one
+	m, err := loadMetaByName("example.com/image")
+	if err != nil {
+		t.Fatalf("while loading plugin meta: %s", err)
+	}
+	other := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/image"})
+	data, err := ioutil.ReadFile(other)
+	if err != nil {
+		t.Fatalf("while reading plugin image: %s", err)
+	}
+	if err := ioutil.WriteFile(m.imageName(), data, 0644); err != nil {
+		t.Fatalf("while replacing plugin image: %s", err)
+	}
+
+	info, err = Image("example.com/image")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info.InstalledID != id || !info.IDMismatch() {
+		t.Errorf("replaced image not detected: got image ID %s and installed ID %s", info.ID, info.InstalledID)
+	}
+	if len(info.Fingerprints) != 0 {
+		t.Errorf("unexpected fingerprints %v for an unsigned image", info.Fingerprints)
+	}
+
+	if _, err := Image("example.com/missing"); err == nil {
+		t.Errorf("unexpected success for a missing plugin")
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/signing"
+)
+
+// IntegrityReport holds the findings of CheckIntegrity for a plugin
+// image, the image is consistent when there is none.
+type IntegrityReport struct {
+	Path     string
+	Findings []Finding
+}
+
+// OK returns if no problem was found in the plugin image.
+func (r *IntegrityReport) OK() bool {
+	return len(r.Findings) == 0
+}
+
+func (r *IntegrityReport) add(kind FindingKind, n int, format string, a ...interface{}) {
+	r.Findings = append(r.Findings, Finding{kind, n, fmt.Sprintf(format, a...)})
+}
+
+// IntegrityError reports a plugin image failing CheckIntegrity.
+type IntegrityError struct {
+	Report *IntegrityReport
+}
+
+func (e *IntegrityError) Error() string {
+	findings := make([]string, len(e.Report.Findings))
+	for i, f := range e.Report.Findings {
+		findings[i] = f.String()
+	}
+	return fmt.Sprintf("integrity check of %s failed: %s", e.Report.Path, strings.Join(findings, "; "))
+}
+
+// CheckIntegrity checks the internal consistency of the plugin image
+// path and reports all the problems found:
+//   - the header, the descriptors and the data objects lie within
+//     the file and the data objects don't overlap
+//   - the data objects match the hashes recorded by the signatures
+//   - the image holds the plugin descriptors, see pluginFileFindings
+//   - the manifest is valid, see validateManifest
+//   - the primary plugin binary objects are ELF shared objects of the
+//     architecture recorded in their descriptor, declared in the manifest
+//
+// The layout is checked from the raw file before the image is loaded,
+// the content of an image with a corrupted layout isn't checked. An
+// error is only returned when the image can't be read.
+func CheckIntegrity(path string) (*IntegrityReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	r := &IntegrityReport{Path: path}
+	if !r.checkLayout(f, fi.Size()) {
+		return r, nil
+	}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		r.add(FindingCorrupted, -1, "could not load image: %s", err)
+		return r, nil
+	}
+	defer fimg.UnloadContainer()
+
+	r.checkSignatures(&fimg)
+	r.checkContent(&fimg)
+
+	return r, nil
+}
+
+// region is the data of a descriptor within the image file.
+type region struct {
+	n        int
+	off, end int64
+}
+
+// checkLayout checks the layout of the SIF file f of size bytes read
+// from the raw file, it returns false if the image can't be safely
+// loaded.
+func (r *IntegrityReport) checkLayout(f io.ReaderAt, size int64) bool {
+	var h sif.Header
+	hdrSize := int64(binary.Size(h))
+	if size < hdrSize {
+		r.add(FindingTruncated, -1, "file of %d bytes is shorter than the SIF header of %d bytes", size, hdrSize)
+		return false
+	}
+	if err := binary.Read(io.NewSectionReader(f, 0, hdrSize), binary.LittleEndian, &h); err != nil {
+		r.add(FindingCorrupted, -1, "could not read SIF header: %s", err)
+		return false
+	}
+	if magic := string(bytes.TrimRight(h.Magic[:], "\x00")); magic != sif.HdrMagic {
+		r.add(FindingCorrupted, -1, "SIF magic %q instead of %q", magic, sif.HdrMagic)
+		return false
+	}
+	if version := string(bytes.TrimRight(h.Version[:], "\x00")); version > sif.HdrVersion {
+		r.add(FindingCorrupted, -1, "unsupported SIF version %q", version)
+		return false
+	}
+
+	descrSize := int64(binary.Size(sif.Descriptor{}))
+	if h.Descroff < hdrSize || h.Descroff > size {
+		r.add(FindingOutOfBounds, -1, "descriptors at offset %d outside of the file of %d bytes after the header", h.Descroff, size)
+		return false
+	}
+	if h.Dtotal < 0 || h.Dtotal > (size-h.Descroff)/descrSize {
+		r.add(FindingTruncated, -1, "%d descriptors at offset %d exceed the file size of %d bytes", h.Dtotal, h.Descroff, size)
+		return false
+	}
+	descrEnd := h.Descroff + h.Dtotal*descrSize
+
+	descrs := make([]sif.Descriptor, h.Dtotal)
+	if err := binary.Read(io.NewSectionReader(f, h.Descroff, h.Dtotal*descrSize), binary.LittleEndian, descrs); err != nil {
+		r.add(FindingCorrupted, -1, "could not read descriptors: %s", err)
+		return false
+	}
+
+	if h.Dataoff < descrEnd || h.Dataoff > size {
+		r.add(FindingOutOfBounds, -1, "data section at offset %d overlaps the descriptors or exceeds the file size of %d bytes", h.Dataoff, size)
+		return false
+	}
+	if h.Datalen < 0 || h.Datalen > size-h.Dataoff {
+		r.add(FindingTruncated, -1, "data section of %d bytes at offset %d exceeds the file size of %d bytes", h.Datalen, h.Dataoff, size)
+	}
+
+	safe := true
+	var regions []region
+	for n, d := range descrs {
+		if !d.Used {
+			continue
+		}
+		if d.Fileoff < h.Dataoff || d.Filelen < 0 || d.Fileoff > size || d.Filelen > size-d.Fileoff {
+			r.add(FindingOutOfBounds, n, "data of %d bytes at offset %d outside of the data section of the file of %d bytes", d.Filelen, d.Fileoff, size)
+			safe = false
+			continue
+		}
+		if d.Filelen > 0 {
+			regions = append(regions, region{n, d.Fileoff, d.Fileoff + d.Filelen})
+		}
+	}
+
+	// each region is compared to the previous one ending last
+	sort.Slice(regions, func(i, j int) bool { return regions[i].off < regions[j].off })
+	for i := 1; i < len(regions); i++ {
+		last := regions[i-1]
+		if regions[i].off < last.end {
+			r.add(FindingOverlap, regions[i].n, "data at offset %d overlaps the data of descriptor %d", regions[i].off, last.n)
+		}
+		if last.end > regions[i].end {
+			regions[i].end, regions[i].n = last.end, last.n
+		}
+	}
+
+	return safe
+}
+
+// checkSignatures checks that the data objects of fimg match the
+// hashes recorded by its signatures.
+func (r *IntegrityReport) checkSignatures(fimg *sif.FileImage) {
+	for n := range fimg.DescrArr {
+		d := &fimg.DescrArr[n]
+		if !d.Used || d.Datatype != sif.DataSignature {
+			continue
+		}
+		if _, err := signing.CheckSignatureHash(fimg, d); err != nil {
+			r.add(FindingHashMismatch, n, "signature %d: %s", d.ID, err)
+		}
+	}
+}
+
+// checkContent checks the plugin descriptors, the manifest and the
+// primary binary objects of fimg.
+func (r *IntegrityReport) checkContent(fimg *sif.FileImage) {
+	sr := newSifFileImageReader(fimg)
+
+	r.Findings = append(r.Findings, pluginFileFindings(sr)...)
+
+	n := findDescriptor(sr, pluginManifestName)
+	if n < 0 || sr.GetDatatype(n) != sif.DataGenericJSON || !json.Valid(sr.GetData(n)) {
+		// reported by pluginFileFindings
+		return
+	}
+	manifest, violations := validateManifest(sr.GetData(n), allowUnknownFields())
+	for _, v := range violations {
+		r.add(FindingBadManifest, n, "%s: %s", pluginManifestName, v)
+	}
+
+	var present []string
+	name := primaryBinaryName(manifest)
+	for n := range fimg.DescrArr {
+		d := &fimg.DescrArr[n]
+		if !isBinaryDescriptor(sr, n) || d.GetName() != name {
+			continue
+		}
+		arch, err := checkBinaryObject(fimg, n, manifest)
+		if err != nil {
+			r.add(FindingBadBinary, n, "%s: %s", name, err)
+		} else if !containsString(present, arch) {
+			present = append(present, arch)
+		}
+	}
+
+	missing, undeclared := archDiscrepancies(manifest, present)
+	if len(missing) > 0 {
+		r.add(FindingBadManifest, n, "architectures declared without binary: %s", strings.Join(missing, ", "))
+	}
+	if len(undeclared) > 0 {
+		r.add(FindingBadBinary, -1, "binaries for architectures not declared in the manifest: %s", strings.Join(undeclared, ", "))
+	}
+}
+
+// checkBinaryObject checks that the binary object of the descriptor n of
+// fimg, decompressed as declared by manifest, is an ELF shared object
+// of the architecture recorded by the descriptor, which it returns.
+func checkBinaryObject(fimg *sif.FileImage, n int, manifest pluginapi.Manifest) (string, error) {
+	d := &fimg.DescrArr[n]
+	sr := newSifFileImageReader(fimg)
+
+	arch, err := binaryArch(sr, d, n)
+	if err != nil {
+		return "", err
+	}
+
+	data := sr.GetData(n)
+	if compression := detectCompression(data); compression != manifest.BinaryCompression {
+		if manifest.BinaryCompression == "" {
+			return "", fmt.Errorf("%s compressed but the manifest declares no compression", compression)
+		}
+		return "", fmt.Errorf("not %s compressed as declared by the manifest", manifest.BinaryCompression)
+	} else if compression != "" {
+		size, ok := manifest.BinarySizes[arch]
+		if !ok {
+			return "", fmt.Errorf("no binary size declared in the manifest for the compressed %s binary", arch)
+		}
+		var b bytes.Buffer
+		if err := decompressBinary(&b, bytes.NewReader(data), compression, size); err != nil {
+			return "", err
+		}
+		data = b.Bytes()
+	}
+
+	elfArch, err := sharedObjectArch(data)
+	if err != nil {
+		return "", err
+	}
+	if elfArch != arch {
+		return "", fmt.Errorf("ELF object built for %s but the descriptor records %s", elfArch, arch)
+	}
+	return arch, nil
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+)
+
+// sifLayout returns the header and the descriptors of the SIF image data.
+func sifLayout(t *testing.T, data []byte) (sif.Header, []sif.Descriptor) {
+	var h sif.Header
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		t.Fatalf("while reading SIF header: %s", err)
+	}
+	descrs := make([]sif.Descriptor, h.Dtotal)
+	r = bytes.NewReader(data[h.Descroff:])
+	if err := binary.Read(r, binary.LittleEndian, descrs); err != nil {
+		t.Fatalf("while reading SIF descriptors: %s", err)
+	}
+	return h, descrs
+}
+
+// setDescriptor replaces the descriptor n of the SIF image data with d.
+func setDescriptor(t *testing.T, data []byte, n int, d sif.Descriptor) {
+	h, _ := sifLayout(t, data)
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, d); err != nil {
+		t.Fatalf("while writing SIF descriptor: %s", err)
+	}
+	copy(data[h.Descroff+int64(n*b.Len()):], b.Bytes())
+}
+
+// descriptorIndex returns the index of the descriptor named name of
+// the SIF image data.
+func descriptorIndex(t *testing.T, data []byte, name string) int {
+	_, descrs := sifLayout(t, data)
+	for n, d := range descrs {
+		if d.Used && d.GetName() == name {
+			return n
+		}
+	}
+	t.Fatalf("no descriptor %s", name)
+	return -1
+}
+
+// createIntegrityTestPlugin creates a signed plugin image in dir whose
+// plugin object is an ELF shared object and returns its content.
+func createIntegrityTestPlugin(t *testing.T, dir string) []byte {
+	if runtime.GOARCH != "amd64" {
+		t.Skipf("test ELF object is built for amd64")
+	}
+
+	manifest := pluginapi.Manifest{Name: "example.org/integrity", Version: "1.0.0"}
+	path := createObjectTestPlugin(t, dir, manifest, []byte(testELFObject(t, elf.ET_DYN)))
+
+	signer, err := openpgp.NewEntity("Signer", "", "signer@example.org", nil)
+	if err != nil {
+		t.Fatalf("while creating key: %s", err)
+	}
+	signTestPlugin(t, path, signer)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("while reading plugin image: %s", err)
+	}
+	return data
+}
+
+func hasFinding(findings []Finding, kind FindingKind) bool {
+	for _, f := range findings {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-integrity-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := createIntegrityTestPlugin(t, dir)
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, data []byte) []byte
+		kind    FindingKind
+	}{
+		{
+			name:    "Valid",
+			corrupt: func(t *testing.T, data []byte) []byte { return data },
+		},
+		{
+			name:    "TruncatedHeader",
+			corrupt: func(t *testing.T, data []byte) []byte { return data[:100] },
+			kind:    FindingTruncated,
+		},
+		{
+			name: "TruncatedData",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				_, descrs := sifLayout(t, data)
+				last := descrs[0]
+				for _, d := range descrs {
+					if d.Used && d.Fileoff > last.Fileoff {
+						last = d
+					}
+				}
+				return data[:last.Fileoff+last.Filelen/2]
+			},
+			kind: FindingOutOfBounds,
+		},
+		{
+			name: "BadMagic",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				data[sif.HdrLaunchLen] ^= 0xff
+				return data
+			},
+			kind: FindingCorrupted,
+		},
+		{
+			name: "TooManyDescriptors",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				h, _ := sifLayout(t, data)
+				h.Dtotal = 1 << 40
+				var b bytes.Buffer
+				binary.Write(&b, binary.LittleEndian, h)
+				copy(data, b.Bytes())
+				return data
+			},
+			kind: FindingTruncated,
+		},
+		{
+			name: "Overlap",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				_, descrs := sifLayout(t, data)
+				n := descriptorIndex(t, data, pluginManifestName)
+				d := descrs[n]
+				d.Fileoff = descrs[descriptorIndex(t, data, pluginBinaryName)].Fileoff
+				setDescriptor(t, data, n, d)
+				return data
+			},
+			kind: FindingOverlap,
+		},
+		{
+			name: "BinaryFlipped",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				_, descrs := sifLayout(t, data)
+				data[descrs[descriptorIndex(t, data, pluginBinaryName)].Fileoff] ^= 0x01
+				return data
+			},
+			kind: FindingBadBinary,
+		},
+		{
+			name: "ManifestFlipped",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				_, descrs := sifLayout(t, data)
+				data[descrs[descriptorIndex(t, data, pluginManifestName)].Fileoff] ^= 0x01
+				return data
+			},
+			kind: FindingBadManifest,
+		},
+		{
+			name: "ManifestValueFlipped",
+			corrupt: func(t *testing.T, data []byte) []byte {
+				_, descrs := sifLayout(t, data)
+				d := descrs[descriptorIndex(t, data, pluginManifestName)]
+				i := bytes.Index(data[d.Fileoff:d.Fileoff+d.Filelen], []byte("integrity"))
+				data[d.Fileoff+int64(i)] = 'I'
+				return data
+			},
+			kind: FindingHashMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.corrupt(t, append([]byte(nil), valid...))
+			path := filepath.Join(dir, tt.name+".sif")
+			if err := ioutil.WriteFile(path, data, 0644); err != nil {
+				t.Fatalf("while writing plugin image: %s", err)
+			}
+
+			report, err := CheckIntegrity(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.kind == 0 {
+				if !report.OK() {
+					t.Errorf("unexpected findings %v", report.Findings)
+				}
+				return
+			}
+			if !hasFinding(report.Findings, tt.kind) {
+				t.Errorf("no finding of kind %d in %v", tt.kind, report.Findings)
+			}
+		})
+	}
+}
+
+func TestCheckIntegrityCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-integrity-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := createIntegrityTestPlugin(t, dir)
+	h, descrs := sifLayout(t, valid)
+	descrSize := int64(binary.Size(sif.Descriptor{}))
+	path := filepath.Join(dir, "corrupted.sif")
+
+	check := func(data []byte) {
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("while writing plugin image: %s", err)
+		}
+		if _, err := CheckIntegrity(path); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// every byte of the header and of the used descriptors,
+	// and a sample of the data, must never cause a panic
+	used := func(i int64) bool {
+		n := (i - h.Descroff) / descrSize
+		return i >= h.Descroff && n < int64(len(descrs)) && descrs[n].Used
+	}
+	for i := int64(sif.HdrLaunchLen); i < int64(len(valid)); i++ {
+		switch {
+		case i < h.Descroff, used(i):
+		case i >= h.Dataoff && i%61 == 0:
+		default:
+			continue
+		}
+		for _, mask := range []byte{0x01, 0xff} {
+			data := append([]byte(nil), valid...)
+			data[i] ^= mask
+			check(data)
+		}
+	}
+	for size := 0; size < len(valid); size += 97 {
+		check(valid[:size])
+	}
+}
+
+func TestInstallIntegrityCheck(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-integrity-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := createIntegrityTestPlugin(t, dir)
+	_, descrs := sifLayout(t, data)
+	data[descrs[descriptorIndex(t, data, pluginBinaryName)].Fileoff] ^= 0x01
+
+	path := filepath.Join(dir, "corrupted.sif")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("while writing plugin image: %s", err)
+	}
+
+	var ierr *IntegrityError
+	if err := Install(path, "", WithIntegrityCheck()); !errors.As(err, &ierr) {
+		t.Fatalf("got error %v, expected an integrity error", err)
+	}
+	if !hasFinding(ierr.Report.Findings, FindingBadBinary) {
+		t.Errorf("no bad binary finding in %v", ierr.Report.Findings)
+	}
+	if _, err := Lookup("example.org/integrity"); err == nil {
+		t.Errorf("corrupted plugin installed")
+	}
+}
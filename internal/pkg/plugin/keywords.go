@@ -0,0 +1,90 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxKeywords is the maximum number of manifest keywords.
+	maxKeywords = 16
+	// maxKeywordLength is the maximum length of a keyword.
+	maxKeywordLength = 32
+)
+
+// keywordRegexp matches the keywords: lowercase letters, digits
+// and dashes, starting with a letter or a digit.
+var keywordRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// checkKeyword returns an error if k is not a valid keyword.
+func checkKeyword(k string) error {
+	if len(k) > maxKeywordLength {
+		return fmt.Errorf("keyword %q length of %d bytes exceeds %d bytes", k, len(k), maxKeywordLength)
+	}
+	if !keywordRegexp.MatchString(k) {
+		return fmt.Errorf("keyword %q must only contain lowercase letters, digits and dashes", k)
+	}
+	return nil
+}
+
+// checkKeywords returns the violations found in the manifest keywords.
+func checkKeywords(keywords []string) []string {
+	if len(keywords) > maxKeywords {
+		return []string{fmt.Sprintf("%d keywords exceed the maximum of %d", len(keywords), maxKeywords)}
+	}
+
+	var violations []string
+	for i, k := range keywords {
+		if err := checkKeyword(k); err != nil {
+			violations = append(violations, err.Error())
+		} else if containsString(keywords[:i], k) {
+			violations = append(violations, fmt.Sprintf("duplicate keyword %q", k))
+		}
+	}
+	return violations
+}
+
+// ParseKeywordSelector parses the keywords used to filter the
+// plugins, as accepted by FilterByKeywords. Keywords are lowercase,
+// the selectors are matched case-insensitively.
+func ParseKeywordSelector(selectors []string) ([]string, error) {
+	keywords := make([]string, 0, len(selectors))
+
+	for _, s := range selectors {
+		k := strings.ToLower(strings.TrimSpace(s))
+		if err := checkKeyword(k); err != nil {
+			return nil, fmt.Errorf("invalid keyword selector %q: %s", s, err)
+		}
+		keywords = append(keywords, k)
+	}
+
+	return keywords, nil
+}
+
+// FilterByKeywords returns the plugins of metas having all the
+// keywords of selector, the keywords recorded in the metas are
+// used so that the plugin images are not opened.
+func FilterByKeywords(metas []*Meta, selector []string) []*Meta {
+	var matches []*Meta
+	for _, m := range metas {
+		if m.hasKeywords(selector) {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func (m *Meta) hasKeywords(selector []string) bool {
+	for _, k := range selector {
+		if !containsString(m.Keywords, k) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckKeywords(t *testing.T) {
+	tests := []struct {
+		name       string
+		keywords   []string
+		violations int
+	}{
+		{"None", nil, 0},
+		{"Valid", []string{"gpu", "mpi-4", "2d"}, 0},
+		{"Uppercase", []string{"GPU"}, 1},
+		{"LeadingDash", []string{"-gpu"}, 1},
+		{"Space", []string{"high performance"}, 1},
+		{"Empty", []string{""}, 1},
+		{"TooLong", []string{strings.Repeat("a", maxKeywordLength+1)}, 1},
+		{"Duplicate", []string{"gpu", "mpi", "gpu"}, 1},
+		{"TooMany", make([]string, maxKeywords+1), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := checkKeywords(tt.keywords); len(v) != tt.violations {
+				t.Errorf("got violations %q, expected %d", v, tt.violations)
+			}
+		})
+	}
+}
+
+func TestParseKeywordSelector(t *testing.T) {
+	keywords, err := ParseKeywordSelector([]string{"GPU", " mpi "})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"gpu", "mpi"}; !reflect.DeepEqual(keywords, expected) {
+		t.Errorf("got keywords %q, expected %q", keywords, expected)
+	}
+
+	if _, err := ParseKeywordSelector([]string{"gpu", "a=b"}); err == nil {
+		t.Errorf("unexpected success with an invalid keyword")
+	}
+}
+
+func TestFilterByKeywords(t *testing.T) {
+	gpu := &Meta{Name: "example.com/gpu", Keywords: []string{"gpu", "cuda"}}
+	mpi := &Meta{Name: "example.com/mpi", Keywords: []string{"mpi", "gpu"}}
+	none := &Meta{Name: "example.com/none"}
+	metas := []*Meta{gpu, mpi, none}
+
+	tests := []struct {
+		name     string
+		selector []string
+		expected []*Meta
+	}{
+		{"NoSelector", nil, metas},
+		{"One", []string{"gpu"}, []*Meta{gpu, mpi}},
+		{"All", []string{"gpu", "mpi"}, []*Meta{mpi}},
+		{"NoMatch", []string{"network"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterByKeywords(metas, tt.selector); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %d plugins, expected %d", len(got), len(tt.expected))
+			}
+		})
+	}
+}
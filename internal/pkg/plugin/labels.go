@@ -0,0 +1,137 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// maxLabelsSize is the maximum size in bytes of all the keys and
+// values of the labels of a plugin.
+const maxLabelsSize = 4096
+
+// SetLabel sets the label key to value on the installed plugin "name",
+// replacing the previous value if any.
+func SetLabel(name, key, value string) error {
+	if err := checkLabel(key, value); err != nil {
+		return err
+	}
+
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(meta.Labels)+1)
+	for k, v := range meta.Labels {
+		labels[k] = v
+	}
+	labels[key] = value
+
+	if size := labelsSize(labels); size > maxLabelsSize {
+		return fmt.Errorf("labels of plugin %q would take %d bytes, the maximum is %d", meta.Name, size, maxLabelsSize)
+	}
+
+	sylog.Debugf("Setting label %q of plugin %q", key, meta.Name)
+
+	meta.Labels = labels
+	return meta.installMeta()
+}
+
+// RemoveLabel removes the label key from the installed plugin "name".
+func RemoveLabel(name, key string) error {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := meta.Labels[key]; !ok {
+		return fmt.Errorf("plugin %q has no label %q", meta.Name, key)
+	}
+
+	sylog.Debugf("Removing label %q of plugin %q", key, meta.Name)
+
+	delete(meta.Labels, key)
+	if len(meta.Labels) == 0 {
+		meta.Labels = nil
+	}
+	return meta.installMeta()
+}
+
+// ParseLabelSelector parses the key=value label selectors, as
+// accepted by ListByLabels.
+func ParseLabelSelector(selectors []string) (map[string]string, error) {
+	selector := make(map[string]string, len(selectors))
+
+	for _, s := range selectors {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid label selector %q: must be key=value", s)
+		}
+		if err := checkLabel(kv[0], kv[1]); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q: %s", s, err)
+		}
+		selector[kv[0]] = kv[1]
+	}
+
+	return selector, nil
+}
+
+// ListByLabels returns the plugins installed in rootDir having
+// all the labels of selector with the exact same value.
+func ListByLabels(selector map[string]string) ([]*Meta, error) {
+	metas, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Meta
+	for _, m := range metas {
+		if m.hasLabels(selector) {
+			matches = append(matches, m)
+		}
+	}
+
+	return matches, nil
+}
+
+func (m *Meta) hasLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := m.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// checkLabel validates a label key and its value.
+func checkLabel(key, value string) error {
+	if key == "" {
+		return fmt.Errorf("label key can't be empty")
+	}
+	if strings.Contains(key, "=") {
+		return fmt.Errorf("label key %q can't contain '='", key)
+	}
+	if strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		return fmt.Errorf("label key %q can't contain control characters", key)
+	}
+	if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return fmt.Errorf("value of label %q can't contain control characters", key)
+	}
+	return nil
+}
+
+func labelsSize(labels map[string]string) int {
+	size := 0
+	for k, v := range labels {
+		size += len(k) + len(v)
+	}
+	return size
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func installTestMeta(t *testing.T, name string) *Meta {
+	m := &Meta{Name: name, Enabled: true}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+	return m
+}
+
+func TestLabels(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	installTestMeta(t, "example.org/test")
+	installTestMeta(t, "example.org/other")
+
+	if err := SetLabel("example.org/test", "owner", "hpc-team"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetLabel("example.org/test", "tier", "experimental"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := SetLabel("example.org/other", "owner", "hpc-team"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// labels survive enable/disable
+	if err := Disable("example.org/test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	expected := map[string]string{"owner": "hpc-team", "tier": "experimental"}
+	if !reflect.DeepEqual(m.Labels, expected) {
+		t.Errorf("got labels %v, expected %v", m.Labels, expected)
+	}
+
+	cases := []struct {
+		selector []string
+		expected []string
+	}{
+		{nil, []string{"example.org/other", "example.org/test"}},
+		{[]string{"owner=hpc-team"}, []string{"example.org/other", "example.org/test"}},
+		{[]string{"owner=hpc-team", "tier=experimental"}, []string{"example.org/test"}},
+		{[]string{"owner=hpc"}, nil},
+		{[]string{"ticket=OPS-1234"}, nil},
+	}
+	for _, tc := range cases {
+		selector, err := ParseLabelSelector(tc.selector)
+		if err != nil {
+			t.Fatalf("unexpected error for selector %v: %s", tc.selector, err)
+		}
+		metas, err := ListByLabels(selector)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var names []string
+		for _, m := range metas {
+			names = append(names, m.Name)
+		}
+		if !reflect.DeepEqual(names, tc.expected) {
+			t.Errorf("selector %v: got plugins %v, expected %v", tc.selector, names, tc.expected)
+		}
+	}
+
+	if err := RemoveLabel("example.org/test", "tier"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := RemoveLabel("example.org/test", "tier"); err == nil {
+		t.Errorf("unexpected success while removing a missing label")
+	}
+	m, err = Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if _, ok := m.Labels["tier"]; ok {
+		t.Errorf("label not removed")
+	}
+}
+
+func TestLabelValidation(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	installTestMeta(t, "example.org/test")
+
+	cases := []struct {
+		description string
+		key         string
+		value       string
+	}{
+		{"empty key", "", "value"},
+		{"equal sign in key", "owner=me", "value"},
+		{"control character in key", "own\ner", "value"},
+		{"control character in value", "owner", "hpc\x00team"},
+		{"too large", "owner", strings.Repeat("x", maxLabelsSize)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			if err := SetLabel("example.org/test", tc.key, tc.value); err == nil {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+
+	if _, err := ParseLabelSelector([]string{"owner"}); err == nil {
+		t.Errorf("unexpected success with a selector without value")
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// Previous versions stored the meta file of a plugin as rootDir/<id>.meta
+// and its image as plugin.sif within the plugin directory. Both layouts
+// are understood during the deprecation period, the legacy one being
+// converted on first use when rootDir is writable.
+const (
+	// legacyMetaExt is the extension of the legacy meta files.
+	legacyMetaExt = ".meta"
+	// legacyNameImage is the legacy name of the plugin SIF image.
+	legacyNameImage = "plugin.sif"
+)
+
+// legacyMetaPath returns the path of the legacy meta file
+// of the plugin "name".
+func legacyMetaPath(name string) string {
+	return filepath.Join(rootDir, pluginIDFromName(name)+legacyMetaExt)
+}
+
+var migrateOnce sync.Once
+
+// migrateLayout converts once the plugins installed with the legacy
+// layout, it's called before accessing the installed plugins.
+func migrateLayout() {
+	migrateOnce.Do(func() {
+		if err := migrateLegacyPlugins(); err != nil {
+			sylog.Debugf("Plugins layout not migrated: %s", err)
+		}
+	})
+}
+
+// migrateLegacyPlugins converts all the plugins installed with the
+// legacy layout. Each plugin is converted independently, interrupting
+// the migration leaves every plugin either in the legacy layout, in
+// the new layout or in between, a state handled by the next run.
+func migrateLegacyPlugins() error {
+	entries, err := filepath.Glob(filepath.Join(rootDir, "*"+legacyMetaExt))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := migrateLegacyPlugin(entry); err != nil {
+			sylog.Debugf("Could not migrate plugin meta %s: %s", entry, err)
+		}
+	}
+
+	return nil
+}
+
+func migrateLegacyPlugin(legacyMeta string) error {
+	data, err := ioutil.ReadFile(legacyMeta)
+	if err != nil {
+		return err
+	}
+
+	m, err := loadMetaByFilename(legacyMeta)
+	if err != nil {
+		return err
+	}
+	if legacyMetaPath(m.Name) != legacyMeta {
+		return fmt.Errorf("unexpected plugin name %q", m.Name)
+	}
+
+	sylog.Debugf("Migrating plugin %q to the per-plugin directory layout", m.Name)
+
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		return err
+	}
+
+	// renames are atomic, the image is either at the
+	// legacy location or at the new one
+	legacyImage := filepath.Join(m.path(), legacyNameImage)
+	newImage := filepath.Join(m.path(), nameImage)
+	if _, err := os.Stat(newImage); os.IsNotExist(err) {
+		if err := os.Rename(legacyImage, newImage); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// the meta file is only written if not already there
+	// from an interrupted migration, a newly written one
+	// always takes precedence over the legacy content
+	if _, err := os.Stat(metaPath(m.Name)); os.IsNotExist(err) {
+		err := writeFileAtomic(metaPath(m.Name), 0644, func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(legacyMeta)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// toLegacyLayout moves the files of an installed plugin
+// to their legacy location.
+func toLegacyLayout(t *testing.T, name string) {
+	m := &Meta{Name: name}
+	if err := os.Rename(metaPath(name), legacyMetaPath(name)); err != nil {
+		t.Fatalf("while moving meta file: %s", err)
+	}
+	if err := os.Rename(filepath.Join(m.path(), nameImage), filepath.Join(m.path(), legacyNameImage)); err != nil {
+		t.Fatalf("while moving image file: %s", err)
+	}
+}
+
+func checkLayout(t *testing.T, name string, legacy bool) {
+	t.Helper()
+
+	m := &Meta{Name: name}
+
+	paths := map[string]bool{
+		metaPath(name):                           !legacy,
+		filepath.Join(m.path(), nameImage):       !legacy,
+		legacyMetaPath(name):                     legacy,
+		filepath.Join(m.path(), legacyNameImage): legacy,
+		filepath.Join(m.path(), nameBinary):      true,
+		filepath.Join(m.path(), nameConfig):      true,
+	}
+	for path, exists := range paths {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("%s: got existence %v, expected %v", path, err == nil, exists)
+		}
+	}
+}
+
+func TestMigrateLayout(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	// migration is run by the test
+	migrateOnce.Do(func() {})
+
+	dir, err := ioutil.TempDir("", "plugin-layout-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+
+	installTestPlugin(t, sifPath, "example.org/new", true)
+	installTestPlugin(t, sifPath, "example.org/legacy", true)
+	installTestPlugin(t, sifPath, "example.org/interrupted", false)
+
+	expected, err := List()
+	if err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	}
+
+	toLegacyLayout(t, "example.org/legacy")
+	toLegacyLayout(t, "example.org/interrupted")
+	checkLayout(t, "example.org/legacy", true)
+
+	// simulate a migration interrupted after the image rename
+	m := &Meta{Name: "example.org/interrupted"}
+	if err := os.Rename(filepath.Join(m.path(), legacyNameImage), filepath.Join(m.path(), nameImage)); err != nil {
+		t.Fatalf("while moving image file: %s", err)
+	}
+
+	// both layouts are understood before the migration
+	for _, exp := range expected {
+		m, err := Lookup(exp.Name)
+		if err != nil {
+			t.Fatalf("while looking up %q: %s", exp.Name, err)
+		}
+		if !reflect.DeepEqual(m, exp) {
+			t.Errorf("got meta %+v, expected %+v", m, exp)
+		}
+		if _, err := os.Stat(m.imageName()); err != nil {
+			t.Errorf("image of %q not found: %s", m.Name, err)
+		}
+	}
+	if metas, err := List(); err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	} else if len(metas) != len(expected) {
+		t.Errorf("got %d plugins, expected %d", len(metas), len(expected))
+	}
+
+	// migration must be idempotent
+	for i := 0; i < 2; i++ {
+		if err := migrateLegacyPlugins(); err != nil {
+			t.Fatalf("unexpected migration error: %s", err)
+		}
+		for _, exp := range expected {
+			checkLayout(t, exp.Name, false)
+		}
+	}
+
+	actual, err := List()
+	if err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("got %d plugins, expected %d", len(actual), len(expected))
+	}
+	for _, exp := range expected {
+		m, err := Lookup(exp.Name)
+		if err != nil {
+			t.Fatalf("while looking up %q: %s", exp.Name, err)
+		}
+		if !reflect.DeepEqual(m, exp) {
+			t.Errorf("got meta %+v, expected %+v", m, exp)
+		}
+	}
+}
@@ -13,12 +13,17 @@ import (
 	"sync"
 
 	callback "github.com/sylabs/singularity/internal/pkg/plugin/callback"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
 	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
 
 type loadedPlugins struct {
-	metas   []*Meta
-	plugins map[string]struct{}
+	metas        []*Meta
+	plugins      map[string]struct{}
+	policy       string
+	capabilities capabilityPolicy
+	checkMode    string
+	configMode   string
 	sync.Mutex
 }
 
@@ -41,7 +46,31 @@ func LoadCallbacks(cb pluginapi.Callback) ([]pluginapi.Callback, error) {
 
 		for _, name := range meta.Callbacks {
 			if name == callbackName {
-				if err := loadCallbacks(meta.binaryName()); err != nil {
+				// refuse to load a plugin object which
+				// would fail with a symbol mismatch
+				if err := checkAPIVersion(meta.APIVersion); err != nil {
+					errs = append(errs, fmt.Errorf("while initializing plugin %q: %s", meta.Name, err))
+					break
+				}
+				// the policy may have changed since installation
+				if err := lp.capabilities.check(meta.Name, meta.Capabilities); err != nil {
+					errs = append(errs, fmt.Errorf("while initializing plugin %q: %s", meta.Name, err))
+					break
+				}
+				if err := meta.checkedCallbacks(lp.checkMode); err != nil {
+					errs = append(errs, fmt.Errorf("while initializing plugin %q: %s", meta.Name, err))
+					break
+				}
+				// the plugin is otherwise loaded with its
+				// default configuration
+				if f := meta.loadConfigFault(lp.configMode); f != nil && lp.configMode == configCheckStrict {
+					break
+				}
+				load := loadCallbacks
+				if meta.isolated(lp.policy) {
+					load = loadIsolatedCallbacks
+				}
+				if err := load(meta); err != nil {
 					// This might be destroying information by
 					// grabbing only the textual description of the
 					// error
@@ -101,15 +130,20 @@ func initMetaPlugin() error {
 	if err != nil {
 		return fmt.Errorf("while getting plugin's metadata: %s", err)
 	}
+	lp.policy = isolationPolicy()
+	lp.capabilities = readCapabilityPolicy()
+	lp.checkMode = callbackCheckMode()
+	lp.configMode = configCheckMode()
 
 	return nil
 }
 
 // loadCallbacks loads the plugin and the plugin callbacks.
-func loadCallbacks(path string) error {
+func loadCallbacks(m *Meta) error {
 	lp.Lock()
 	defer lp.Unlock()
 
+	path := m.binaryName()
 	if _, ok := lp.plugins[path]; ok {
 		return nil
 	}
@@ -120,8 +154,50 @@ func loadCallbacks(path string) error {
 	}
 
 	lp.plugins[path] = struct{}{}
+	pl.AssetsPath = m.AssetsPath
+	m.noticeDeprecation()
 
 	for _, c := range pl.Callbacks {
+		if err := callbackAllowed(m.Capabilities, callback.Name(c)); err != nil {
+			sylog.Warningf("Plugin %q %s, ignoring it", m.Name, err)
+			continue
+		}
+		callback.Load(c)
+	}
+
+	return nil
+}
+
+// loadIsolatedCallbacks starts the plugin in an isolated process
+// and loads callbacks forwarding the calls to this process. The
+// process lives until the singularity process exits.
+func loadIsolatedCallbacks(m *Meta) error {
+	lp.Lock()
+	defer lp.Unlock()
+
+	path := m.binaryName()
+	if _, ok := lp.plugins[path]; ok {
+		return nil
+	}
+
+	h, err := startHost(m)
+	if err != nil {
+		return err
+	}
+
+	lp.plugins[path] = struct{}{}
+	m.noticeDeprecation()
+
+	for _, name := range m.Callbacks {
+		if err := callbackAllowed(m.Capabilities, name); err != nil {
+			sylog.Warningf("Plugin %q %s, ignoring it", m.Name, err)
+			continue
+		}
+		c := h.callback(name)
+		if c == nil {
+			sylog.Warningf("Plugin %q callback %s can't be run in an isolated process, ignoring it", m.Name, name)
+			continue
+		}
 		callback.Load(c)
 	}
 
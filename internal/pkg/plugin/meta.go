@@ -6,44 +6,237 @@
 package plugin
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/plugin/callback"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
 
+// rootDir is the root directory for the plugin
+// installation, typically located within LIBEXECDIR.
+var rootDir = buildcfg.PLUGIN_ROOTDIR
+
 const (
-	// rootDir is the root directory for the plugin
-	// installation, typically located within LIBEXECDIR.
-	rootDir = buildcfg.PLUGIN_ROOTDIR
 	// nameImage is the name of the SIF image of the plugin
-	nameImage = "plugin.sif"
+	nameImage = "image.sif"
 	// nameBinary is the name of the plugin object
 	nameBinary = "object.so"
+	// nameMeta is the name of the plugin meta file
+	nameMeta = "meta.json"
 )
 
 // Meta is an internal representation of a plugin binary
 // and all of its artifacts. This represents the on-disk
 // location of the SIF, shared library, config file, etc...
-// This struct is written as JSON into the rootDir directory.
+// This struct is written as JSON into the plugin directory,
+// every persisted field must have an explicit json tag.
 type Meta struct {
 	// Name is the name of the plugin.
-	Name string
+	Name string `json:"Name"`
 	// Enabled reports whether or not the plugin should be loaded.
-	Enabled bool
+	Enabled bool `json:"Enabled"`
 	// Callbacks contains callbacks name registered by the plugin.
-	Callbacks []string
-
+	Callbacks []string `json:"Callbacks"`
+	// InstalledBy identifies the user who installed the plugin.
+	InstalledBy *Actor `json:"InstalledBy"`
+	// LastModifiedBy identifies the user who performed the
+	// last install, enable or disable operation on the plugin.
+	LastModifiedBy *Actor `json:"LastModifiedBy"`
+	// ConfigPath is the path of the plugin configuration file.
+	ConfigPath string `json:"ConfigPath"`
+	// ConfigDefaultHash is the sha256 of the default configuration
+	// file the configuration file was generated from, it's the one
+	// of the installed version unless the configuration file was
+	// preserved on upgrade.
+	ConfigDefaultHash string `json:"ConfigDefaultHash"`
+	// AssetsPath is the path of the directory holding the assets
+	// extracted from the plugin image, it's unset for plugins
+	// without assets.
+	AssetsPath string `json:"AssetsPath,omitempty"`
+	// BinaryDigest is the sha256 of the plugin object computed
+	// while it was extracted.
+	BinaryDigest string `json:"BinaryDigest,omitempty"`
+	// SourceDir is the directory holding the plugin source code
+	// as recorded in the plugin image when it was compiled, or
+	// given when the plugin was recompiled, see Rebuild.
+	SourceDir string `json:"SourceDir,omitempty"`
+	// DevDir is the unpacked plugin directory the plugin was
+	// installed from by InstallDir, its image was packaged at
+	// installation. It's unset for plugins installed from an image.
+	DevDir string `json:"DevDir,omitempty"`
+	// ImageID is the unique identifier found in the SIF header of
+	// the plugin image at installation, a different identifier in
+	// the stored image reveals an image replaced since.
+	ImageID string `json:"ImageID,omitempty"`
+	// ImageCompression is the compression of the stored plugin
+	// image, it's empty for an uncompressed image (see Compact).
+	ImageCompression string `json:"ImageCompression,omitempty"`
+	// ImageDigest is the sha256 of the uncompressed plugin image,
+	// it's recorded for a compressed image to verify it's restored
+	// unchanged when decompressed.
+	ImageDigest string `json:"ImageDigest,omitempty"`
+	// Isolated reports whether or not the plugin manifest requests
+	// the plugin to be run in an isolated process.
+	Isolated bool `json:"Isolated"`
+	// Version is the version of the installed plugin as
+	// found in its manifest.
+	Version string `json:"Version,omitempty"`
+	// APIVersion is the plugin API version the installed plugin
+	// was compiled against as found in its manifest.
+	APIVersion int `json:"APIVersion,omitempty"`
+	// Capabilities lists the capabilities declared in the plugin
+	// manifest, the callbacks loaded must be covered by them.
+	Capabilities []pluginapi.Capability `json:"Capabilities,omitempty"`
+	// DeclaredCallbacks lists the callbacks declared in the plugin
+	// manifest, unlike Callbacks they are not read from the object.
+	DeclaredCallbacks []string `json:"DeclaredCallbacks,omitempty"`
+	// CallbackCheck is the result of the last comparison of the
+	// declared callbacks with the registered ones, it's cached so
+	// the plugin object doesn't have to be examined on every load.
+	CallbackCheck *CallbackCheck `json:"CallbackCheck,omitempty"`
+	// Keywords are the keywords found in the plugin manifest,
+	// they are recorded to filter the plugins without reading
+	// the image.
+	Keywords []string `json:"Keywords,omitempty"`
+	// ConfigSchema is the configuration schema declared in the
+	// plugin manifest, the configuration file is validated against
+	// it without reading the image.
+	ConfigSchema []pluginapi.ConfigOption `json:"ConfigSchema,omitempty"`
+	// ConfigFormat is the syntax of the configuration file declared
+	// in the plugin manifest, YAML when empty.
+	ConfigFormat pluginapi.ConfigFormat `json:"ConfigFormat,omitempty"`
+	// Deprecated and ReplacedBy are the deprecation information
+	// found in the plugin manifest, they are recorded so the
+	// deprecation is noticed without reading the image.
+	Deprecated *pluginapi.Deprecation `json:"Deprecated,omitempty"`
+	ReplacedBy string                 `json:"ReplacedBy,omitempty"`
+	// License, Homepage, Repository and MaintainerEmail are the
+	// provenance information found in the plugin manifest, they
+	// are recorded to be displayed without reading the image.
+	License         string `json:"License,omitempty"`
+	Homepage        string `json:"Homepage,omitempty"`
+	Repository      string `json:"Repository,omitempty"`
+	MaintainerEmail string `json:"MaintainerEmail,omitempty"`
+	// EnabledAt is the time at which the plugin was last enabled,
+	// it's unset while the plugin is disabled.
+	EnabledAt *time.Time `json:"EnabledAt,omitempty"`
+	// DisabledAt is the time at which the plugin was last disabled,
+	// it's unset while the plugin is enabled.
+	DisabledAt *time.Time `json:"DisabledAt,omitempty"`
+	// Labels holds the labels set on the plugin by the
+	// administrators, they are kept across upgrades.
+	Labels map[string]string `json:"Labels,omitempty"`
+	// History records the installations of the plugin, it's
+	// informational only and kept across upgrades.
+	History History `json:"History,omitempty"`
+	// Checksum is the sha256 of the content of the meta file,
+	// it's verified when the meta is loaded (see metaChecksum).
+	Checksum string `json:"Checksum,omitempty"`
+
+	// unknown holds the fields of the meta file unknown to
+	// this version, they are written back untouched.
+	unknown map[string]json.RawMessage
 	// sifFile is the SIF file handle containing plugin.
 	sifFile *sif.FileImage
+	// configMigration is the configuration migration declared in
+	// the manifest of the plugin image being installed.
+	configMigration *pluginapi.ConfigMigration
+}
+
+// persistedMeta has the same fields as Meta without its methods,
+// it's the type actually encoded to and decoded from JSON.
+type persistedMeta Meta
+
+// MarshalJSON encodes the persisted fields of the meta, followed
+// by the unknown fields found when the meta was loaded.
+func (m Meta) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(persistedMeta(m))
+	if err != nil || len(m.unknown) == 0 {
+		return data, err
+	}
+
+	keys := make([]string, 0, len(m.unknown))
+	for k := range m.unknown {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.Write(data[:len(data)-1])
+	for _, k := range keys {
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteByte(',')
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(m.unknown[k])
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the meta and keeps the fields unknown
+// to this version, so a meta written by a newer version doesn't
+// lose data when modified by an older one.
+func (m *Meta) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*persistedMeta)(m)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, k := range metaFields() {
+		delete(fields, k)
+	}
+	if len(fields) > 0 {
+		m.unknown = fields
+	}
+
+	return nil
+}
+
+// metaFields returns the JSON keys of the persisted Meta fields.
+func metaFields() []string {
+	var keys []string
+
+	t := reflect.TypeOf(persistedMeta{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported field
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch tag {
+		case "-":
+		case "":
+			keys = append(keys, f.Name)
+		default:
+			keys = append(keys, tag)
+		}
+	}
+
+	return keys
 }
 
 // loadFromJSON loads a Meta type from an io.Reader containing
@@ -57,20 +250,60 @@ func loadFromJSON(r io.Reader) (*Meta, error) {
 	return &m, nil
 }
 
+// loadMetaByName loads the Meta of the installed plugin "name", the
+// name is normalized first and matched case-insensitively when no
+// plugin is installed under the exact name.
 func loadMetaByName(name string) (*Meta, error) {
+	migrateLayout()
+
+	name = normalizeName(name)
+
 	m, err := loadMetaByFilename(metaPath(name))
-	if err != nil {
+	if os.IsNotExist(err) {
+		// not migrated yet
+		m, err = loadMetaByFilename(legacyMetaPath(name))
+	}
+	if os.IsNotExist(err) {
+		return loadMetaByFoldedName(name, err)
+	} else if err != nil {
 		return nil, err
 	}
 
-	// make sure we loaded the right thing
-	if m.Name != name {
+	// make sure we loaded the right thing, names
+	// recorded by previous versions are not normalized
+	if normalizeName(m.Name) != name {
 		return nil, fmt.Errorf("unexpected plugin name %q when loading plugin %q", m.Name, name)
 	}
 
 	return m, nil
 }
 
+// loadMetaByFoldedName loads the Meta of the installed plugin whose
+// name matches "name" case-insensitively, notExistErr is returned
+// when there is none.
+func loadMetaByFoldedName(name string, notExistErr error) (*Meta, error) {
+	metas, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Meta
+	for _, m := range metas {
+		if !strings.EqualFold(normalizeName(m.Name), name) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("plugin name %q matches both %q and %q", name, found.Name, m.Name)
+		}
+		found = m
+	}
+	if found == nil {
+		return nil, notExistErr
+	}
+
+	return found, nil
+}
+
 func loadMetaByFilename(filename string) (*Meta, error) {
 	fh, err := os.Open(filename)
 	if err != nil {
@@ -79,22 +312,39 @@ func loadMetaByFilename(filename string) (*Meta, error) {
 	}
 	defer fh.Close()
 
-	return loadFromJSON(fh)
+	data, err := ioutil.ReadAll(fh)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadVerifiedMeta(filename, data)
 }
 
 // metaPath returns the path to the meta file based on the
 // the name of the corresponding plugin.
 func metaPath(name string) string {
-	return filepath.Join(rootDir, pluginIDFromName(name)+".meta")
+	return filepath.Join(rootDir, pathFromName(name), nameMeta)
 }
 
 // install installs the plugin represented by m into the plugin installation
-// directory. This should normally only be called in InstallFromSIF.
-func (m *Meta) install() error {
+// directory, previous is the Meta of the plugin being upgraded if any. This
+// should normally only be called in InstallFromSIF.
+func (m *Meta) install(previous *Meta) error {
 	if err := os.MkdirAll(m.path(), 0755); err != nil {
 		return err
 	}
 
+	if previous != nil {
+		m.Labels = previous.Labels
+	}
+
+	// the configuration is migrated first, an upgrade
+	// failing there leaves the installed plugin untouched
+	migration, err := m.migrateConfig(previous)
+	if err != nil {
+		return fmt.Errorf("while migrating configuration: %w", err)
+	}
+
 	if err := m.installImage(); err != nil {
 		return err
 	}
@@ -103,9 +353,17 @@ func (m *Meta) install() error {
 		return err
 	}
 
+	if err := m.installAssets(); err != nil {
+		return err
+	}
+
+	if err := m.installConfig(previous, migration); err != nil {
+		return err
+	}
+
 	// must be called before installMeta to also
 	// get plugin callbacks name
-	if err := m.runInstall(); err != nil {
+	if err := installObject(m); err != nil {
 		return err
 	}
 
@@ -116,8 +374,39 @@ func (m *Meta) install() error {
 	return nil
 }
 
+// installImage stores the plugin image, compressed when
+// ImageCompression is set, replacing the image previously stored.
 func (m *Meta) installImage() error {
-	fh, err := os.Create(m.imageName())
+	// an image left by the legacy layout is replaced
+	legacy := filepath.Join(m.path(), legacyNameImage)
+	if err := os.Remove(legacy); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	image := filepath.Join(m.path(), nameImage)
+	compressed := filepath.Join(m.path(), nameCompressedImage)
+
+	if m.ImageCompression != "" {
+		err := writeFileAtomic(compressed, 0644, func(w io.Writer) error {
+			digest, err := compressImage(w, m.sifFile.Filedata, m.ImageCompression)
+			m.ImageDigest = digest
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(image); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	m.ImageDigest = ""
+	if err := os.Remove(compressed); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	fh, err := os.Create(image)
 	if err != nil {
 		return err
 	}
@@ -127,27 +416,47 @@ func (m *Meta) installImage() error {
 	return err
 }
 
+// installBinary extracts the plugin binary object, it's hashed while
+// written and replaces the previous object only once synced to disk.
 func (m *Meta) installBinary() error {
-	fh, err := os.Create(m.binaryName())
+	h := sha256.New()
+
+	err := writeFileAtomic(m.binaryName(), 0644, func(w io.Writer) error {
+		return extractBinary(io.MultiWriter(w, h), m.sifFile)
+	})
 	if err != nil {
 		return err
 	}
-	defer fh.Close()
-
-	start := m.sifFile.DescrArr[0].Fileoff
-	end := start + m.sifFile.DescrArr[0].Filelen
-	_, err = fh.Write(m.sifFile.Filedata[start:end])
 
-	return err
+	m.BinaryDigest = fmt.Sprintf("%x", h.Sum(nil))
+	return nil
 }
 
+// installObject runs the Install function of the plugin object of m,
+// it's replaced by tests as their dummy plugin objects can't be loaded.
+var installObject = (*Meta).runInstall
+
 func (m *Meta) runInstall() error {
+	if m.isolated(isolationPolicy()) {
+		return m.runIsolatedInstall()
+	}
+
 	binary := m.binaryName()
 
 	pl, err := LoadObject(binary)
 	if err != nil {
 		return fmt.Errorf("while loading plugin %s: %s", binary, err)
 	}
+	pl.AssetsPath = m.AssetsPath
+
+	// the callbacks are checked before running any plugin code
+	callbacks := callback.Names(pl.Callbacks)
+	if err := checkCallbacks(m.Capabilities, callbacks); err != nil {
+		return fmt.Errorf("plugin registers callbacks outside its declared capabilities: %s", err)
+	}
+	if err := enforceCallbackCheck(m.Name, m.recordCallbackCheck(callbacks), callbackCheckMode()); err != nil {
+		return err
+	}
 
 	if pl.Install != nil {
 		if err := pl.Install(m.path()); err != nil {
@@ -155,30 +464,108 @@ func (m *Meta) runInstall() error {
 		}
 	}
 
-	m.Callbacks = callback.Names(pl.Callbacks)
+	m.Callbacks = callbacks
+
+	return nil
+}
+
+// runIsolatedInstall runs the plugin Install function in an isolated
+// process, the installation fails if the plugin registers callbacks
+// which can't be served by an isolated process.
+func (m *Meta) runIsolatedInstall() error {
+	h, err := startHost(m)
+	if err != nil {
+		return err
+	}
+	defer h.close()
+
+	callbacks, err := h.install(m.path())
+	if err != nil {
+		return fmt.Errorf("while running plugin Install: %s", err)
+	}
+
+	for _, name := range callbacks {
+		if h.callback(name) == nil {
+			return fmt.Errorf("plugin callback %s can't be run in an isolated process", name)
+		}
+	}
+	if err := checkCallbacks(m.Capabilities, callbacks); err != nil {
+		return fmt.Errorf("plugin registers callbacks outside its declared capabilities: %s", err)
+	}
+	if err := enforceCallbackCheck(m.Name, m.recordCallbackCheck(callbacks), callbackCheckMode()); err != nil {
+		return err
+	}
+
+	m.Callbacks = callbacks
 
 	return nil
 }
 
+// isolated returns whether or not the plugin must be run in
+// an isolated process for the isolation policy.
+func (m *Meta) isolated(policy string) bool {
+	return m.Isolated || policy == IsolationAlways
+}
+
 func (m *Meta) installMeta() error {
-	fn := metaPath(m.Name)
+	data, err := m.marshalWithChecksum()
+	if err != nil {
+		return err
+	}
 
-	fh, err := os.Create(fn)
+	// the meta file is replaced atomically, a plugin
+	// never becomes unloadable because of a partial write
+	err = writeFileAtomic(metaPath(m.Name), 0644, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	defer fh.Close()
 
-	data, err := json.Marshal(m)
+	// the legacy meta file is superseded
+	if err := os.Remove(legacyMetaPath(m.Name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// writeFileAtomic calls write with a temporary file which is synced
+// to disk and renamed to filename once write succeeded, so filename is
+// either the previous file or the complete new one, even if the process
+// is killed or on power loss.
+func writeFileAtomic(filename string, perm os.FileMode, write func(io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"-")
 	if err != nil {
 		return err
 	}
+	tmp := f.Name()
 
-	_, err = fh.Write(data)
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
 	if err != nil {
+		os.Remove(tmp)
 		return err
 	}
 
+	// sync the directory to persist the rename
+	if d, err := os.Open(filepath.Dir(filename)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
 	return nil
 }
 
@@ -199,18 +586,8 @@ func (m *Meta) uninstall() error {
 		errs = append(errs, err)
 	}
 
-	for dir := filepath.Dir(m.Name); dir != "."; dir = filepath.Dir(dir) {
-		d := filepath.Join(rootDir, dir)
-		sylog.Debugf("Removing directory %q", d)
-		if err := os.Remove(d); err != nil {
-			// directory is not empty, stop here
-			if os.IsExist(err) {
-				sylog.Debugf("Directory %q wasn't empty", d)
-				break
-			}
-			errs = append(errs, err)
-			break
-		}
+	if err := removeNameDirs(m.Name); err != nil {
+		errs = append(errs, err)
 	}
 
 	switch len(errs) {
@@ -243,23 +620,57 @@ func (m *Meta) removeDir() error {
 	if _, err := os.Stat(m.binaryName()); err != nil {
 		return err
 	}
-	if _, err := os.Stat(m.imageName()); err != nil {
+	if _, err := os.Stat(m.storedImageName()); err != nil {
 		return err
 	}
 	return os.RemoveAll(m.path())
 }
 
+// removeNameDirs removes the directories of rootDir holding the
+// directory of the plugin "name", up to the first one not empty.
+func removeNameDirs(name string) error {
+	for dir := filepath.Dir(name); dir != "."; dir = filepath.Dir(dir) {
+		d := filepath.Join(rootDir, dir)
+		sylog.Debugf("Removing directory %q", d)
+		if err := os.Remove(d); err != nil {
+			// directory is not empty, stop here
+			if os.IsExist(err) {
+				sylog.Debugf("Directory %q wasn't empty", d)
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Meta) uninstallMeta() error {
-	return os.Remove(metaPath(m.Name))
+	if err := os.Remove(legacyMetaPath(m.Name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(metaPath(m.Name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 func (m *Meta) enable() error {
+	now := time.Now()
+
 	m.Enabled = true
+	m.EnabledAt = &now
+	m.DisabledAt = nil
+	m.LastModifiedBy = currentActor()
 	return m.installMeta()
 }
 
 func (m *Meta) disable() error {
+	now := time.Now()
+
 	m.Enabled = false
+	m.EnabledAt = nil
+	m.DisabledAt = &now
+	m.LastModifiedBy = currentActor()
 	return m.installMeta()
 }
 
@@ -268,13 +679,49 @@ func (m *Meta) disable() error {
 //
 
 func (m *Meta) imageName() string {
-	return filepath.Join(m.path(), nameImage)
+	image := filepath.Join(m.path(), nameImage)
+	if _, err := os.Stat(image); os.IsNotExist(err) {
+		// not migrated yet
+		legacy := filepath.Join(m.path(), legacyNameImage)
+		if _, err := os.Stat(legacy); err == nil {
+			return legacy
+		}
+	}
+	return image
+}
+
+// metaName returns the path of the meta file of the plugin,
+// which is at the legacy location until the plugin is migrated.
+func (m *Meta) metaName() string {
+	meta := metaPath(m.Name)
+	if _, err := os.Stat(meta); os.IsNotExist(err) {
+		if _, err := os.Stat(legacyMetaPath(m.Name)); err == nil {
+			return legacyMetaPath(m.Name)
+		}
+	}
+	return meta
 }
 
 func (m *Meta) binaryName() string {
 	return filepath.Join(m.path(), nameBinary)
 }
 
+func (m *Meta) assetsName() string {
+	return filepath.Join(m.path(), nameAssets)
+}
+
+func (m *Meta) configName() string {
+	return filepath.Join(m.path(), configFileName(m.ConfigFormat))
+}
+
+func (m *Meta) configDirName() string {
+	return filepath.Join(m.path(), nameConfigDir)
+}
+
+func (m *Meta) configDefaultName() string {
+	return filepath.Join(m.path(), nameConfigDefault)
+}
+
 func (m *Meta) path() string {
 	return filepath.Join(rootDir, pathFromName(m.Name))
 }
@@ -0,0 +1,179 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("while reading golden file: %s", err)
+	}
+	return data
+}
+
+func TestMetaMarshal(t *testing.T) {
+	enabledAt := time.Date(2020, time.March, 2, 10, 30, 0, 0, time.UTC)
+
+	m := &Meta{
+		Name:              "example.org/test",
+		Enabled:           true,
+		Callbacks:         []string{"cli.Command"},
+		InstalledBy:       &Actor{UID: 0, EUID: 0, Username: "alice", Sudo: true, SudoUID: 1000},
+		LastModifiedBy:    &Actor{UID: 1000, EUID: 1000, Username: "bob"},
+		ConfigPath:        "/usr/local/libexec/singularity/plugin/example.org/test/config.yaml",
+		ConfigDefaultHash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		EnabledAt:         &enabledAt,
+		// internal fields must never be persisted
+		sifFile: &sif.FileImage{},
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	golden := readGolden(t, "meta.golden")
+	if string(data) != string(golden) {
+		t.Errorf("unexpected meta encoding:\ngot:      %s\nexpected: %s", data, golden)
+	}
+
+	loaded, err := loadFromJSON(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m.sifFile = nil
+	if !reflect.DeepEqual(loaded, m) {
+		t.Errorf("got meta %+v, expected %+v", loaded, m)
+	}
+}
+
+func TestMetaUnknownFields(t *testing.T) {
+	golden := readGolden(t, "meta_unknown.golden")
+
+	m, err := loadFromJSON(bytes.NewReader(golden))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if m.Name != "example.org/test" {
+		t.Errorf("unexpected name %q", m.Name)
+	}
+
+	// unknown fields written by a newer version are preserved
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(data) != string(golden) {
+		t.Errorf("unknown fields not preserved:\ngot:      %s\nexpected: %s", data, golden)
+	}
+}
+
+func TestMetaEnableDisable(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/test", Enabled: true}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+
+	if err := m.disable(); err != nil {
+		t.Fatalf("unexpected disable error: %s", err)
+	}
+	loaded, err := loadMetaByName(m.Name)
+	if err != nil {
+		t.Fatalf("while loading meta: %s", err)
+	}
+	if loaded.Enabled || loaded.DisabledAt == nil || loaded.EnabledAt != nil {
+		t.Errorf("unexpected state after disable: enabled=%v enabledAt=%v disabledAt=%v", loaded.Enabled, loaded.EnabledAt, loaded.DisabledAt)
+	}
+
+	if err := loaded.enable(); err != nil {
+		t.Fatalf("unexpected enable error: %s", err)
+	}
+	loaded, err = loadMetaByName(m.Name)
+	if err != nil {
+		t.Fatalf("while loading meta: %s", err)
+	}
+	if !loaded.Enabled || loaded.EnabledAt == nil || loaded.DisabledAt != nil {
+		t.Errorf("unexpected state after enable: enabled=%v enabledAt=%v disabledAt=%v", loaded.Enabled, loaded.EnabledAt, loaded.DisabledAt)
+	}
+}
+
+func TestInstallMetaInterrupted(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	m := &Meta{Name: "example.org/test", Enabled: true}
+	if err := os.MkdirAll(m.path(), 0755); err != nil {
+		t.Fatalf("while creating plugin directory: %s", err)
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+
+	data, err := json.Marshal(&Meta{Name: m.Name})
+	if err != nil {
+		t.Fatalf("while encoding meta: %s", err)
+	}
+
+	// the write is interrupted after half of the data
+	err = writeFileAtomic(metaPath(m.Name), 0644, func(w io.Writer) error {
+		if _, err := w.Write(data[:len(data)/2]); err != nil {
+			return err
+		}
+		return fmt.Errorf("interrupted")
+	})
+	if err == nil {
+		t.Fatalf("unexpected success of an interrupted write")
+	}
+
+	loaded, err := loadMetaByName(m.Name)
+	if err != nil {
+		t.Fatalf("previous meta didn't survive the interrupted write: %s", err)
+	}
+	if !loaded.Enabled {
+		t.Errorf("previous meta content was modified")
+	}
+
+	// no temporary file is left behind
+	entries, err := ioutil.ReadDir(m.path())
+	if err != nil {
+		t.Fatalf("while reading plugin directory: %s", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != nameMeta {
+		for _, e := range entries {
+			t.Errorf("unexpected file %s in plugin directory", e.Name())
+		}
+	}
+
+	// a temporary file left by a killed process is ignored
+	tmp := filepath.Join(m.path(), "."+nameMeta+"-killed")
+	if err := ioutil.WriteFile(tmp, data[:len(data)/2], 0644); err != nil {
+		t.Fatalf("while writing temporary file: %s", err)
+	}
+	metas, err := List()
+	if err != nil {
+		t.Fatalf("while listing plugins: %s", err)
+	}
+	if len(metas) != 1 || !metas[0].Enabled {
+		t.Errorf("unexpected plugins %+v", metas)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+	"golang.org/x/crypto/openpgp"
+)
+
+// builtinReservedNamespaces are the plugin namespaces always reserved,
+// singularity.conf may reserve others.
+var builtinReservedNamespaces = []string{"sylabs.io/", "singularity/"}
+
+// ErrReservedNamespace is the error when a plugin of a reserved
+// namespace isn't signed by a key trusted for this namespace.
+var ErrReservedNamespace = errors.New("reserved plugin namespace")
+
+// NamespaceError reports a plugin name falling under a reserved
+// namespace without a signature by a key trusted for it.
+type NamespaceError struct {
+	Name      string
+	Namespace string
+	Reason    string
+}
+
+func (e *NamespaceError) Error() string {
+	return fmt.Sprintf("plugin name %q is in the reserved namespace %q: %s", e.Name, e.Namespace, e.Reason)
+}
+
+// Is allows errors.Is(err, ErrReservedNamespace).
+func (e *NamespaceError) Is(target error) bool {
+	return target == ErrReservedNamespace
+}
+
+// namespacePolicy holds the reserved namespaces and the
+// fingerprints of the keys trusted for each of them.
+type namespacePolicy struct {
+	namespaces []string
+	keys       map[string][]string
+}
+
+// normalizeNamespace returns the lowercase form of the namespace
+// prefix ns ending with a slash.
+func normalizeNamespace(ns string) string {
+	ns = normalizeName(ns)
+	if ns == "" {
+		return ""
+	}
+	return strings.ToLower(ns) + "/"
+}
+
+// newNamespacePolicy returns the policy reserving the builtin namespaces
+// and the namespaces of reserved, trusting the keys of the
+// <namespace>:<fingerprint> entries of keys. Invalid entries are
+// ignored with a warning.
+func newNamespacePolicy(reserved, keys []string) namespacePolicy {
+	p := namespacePolicy{keys: make(map[string][]string)}
+
+	for _, list := range [][]string{builtinReservedNamespaces, reserved} {
+		for _, ns := range list {
+			if ns = normalizeNamespace(ns); ns != "" && !containsString(p.namespaces, ns) {
+				p.namespaces = append(p.namespaces, ns)
+			}
+		}
+	}
+
+	for _, k := range keys {
+		i := strings.LastIndex(k, ":")
+		if i < 0 {
+			sylog.Warningf("Ignoring invalid plugin namespace key %q: expected <namespace>:<fingerprint>", k)
+			continue
+		}
+		ns := normalizeNamespace(k[:i])
+		fp := strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(k[i+1:]), "0x"))
+		if ns == "" || len(fp) != 40 || strings.Trim(fp, "0123456789ABCDEF") != "" {
+			sylog.Warningf("Ignoring invalid plugin namespace key %q: expected <namespace>:<fingerprint>", k)
+			continue
+		}
+		if !containsString(p.namespaces, ns) {
+			sylog.Warningf("Ignoring plugin namespace key %q: %q is not a reserved namespace", k, ns)
+			continue
+		}
+		p.keys[ns] = append(p.keys[ns], fp)
+	}
+
+	return p
+}
+
+// readNamespacePolicy returns the plugin namespace policy
+// set in singularity.conf.
+func readNamespacePolicy() namespacePolicy {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, only reserving the builtin plugin namespaces: %s", buildcfg.SINGULARITY_CONF_FILE, err)
+		return newNamespacePolicy(nil, nil)
+	}
+	return newNamespacePolicy(c.PluginReservedNames, c.PluginNamespaceKeys)
+}
+
+// namespace returns the reserved namespace name falls under,
+// or an empty string if name is not reserved.
+func (p namespacePolicy) namespace(name string) string {
+	name = strings.ToLower(normalizeName(name)) + "/"
+	for _, ns := range p.namespaces {
+		if strings.HasPrefix(name, ns) {
+			return ns
+		}
+	}
+	return ""
+}
+
+// check returns a NamespaceError if one of names falls under a reserved
+// namespace and the data objects of the plugin image sifPath are not
+// signed by a key trusted for this namespace. The keys are searched in
+// the public keyring of the current user. Other errors are returned when
+// a trusted key signature doesn't verify.
+func (p namespacePolicy) check(sifPath string, names ...string) error {
+	var checked []string
+
+	for _, name := range names {
+		ns := p.namespace(name)
+		if ns == "" || containsString(checked, ns) {
+			continue
+		}
+		checked = append(checked, ns)
+
+		if err := p.checkSigned(sifPath, name, ns); err != nil {
+			return err
+		}
+		sylog.Debugf("Plugin %q of the reserved namespace %q is signed by a trusted key", name, ns)
+	}
+	return nil
+}
+
+// checkSigned checks that the plugin image sifPath is signed by one
+// of the keys trusted for the namespace ns of the plugin name, with a
+// signature covering all its data objects.
+func (p namespacePolicy) checkSigned(sifPath, name, ns string) error {
+	trusted := p.keys[ns]
+	if len(trusted) == 0 {
+		return &NamespaceError{Name: name, Namespace: ns, Reason: "no trusted key is configured for this namespace"}
+	}
+
+	keys, err := sypgp.NewHandle("").LoadPubKeyring()
+	if err != nil {
+		sylog.Debugf("Could not load the public keyring: %s", err)
+	}
+	var el openpgp.EntityList
+	for _, e := range keys {
+		if containsString(trusted, fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)) {
+			el = append(el, e)
+		}
+	}
+	if len(el) == 0 {
+		return &NamespaceError{Name: name, Namespace: ns, Reason: fmt.Sprintf("none of the trusted keys %s is in the public keyring", strings.Join(trusted, ", "))}
+	}
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		return &NamespaceError{Name: name, Namespace: ns, Reason: "the plugin image is not signed"}
+	}
+	defer fimg.UnloadContainer()
+
+	results := verifySignatures(context.Background(), &fimg, el, SignatureOptions{})
+	if len(results) == 0 {
+		return &NamespaceError{Name: name, Namespace: ns, Reason: "the plugin image is not signed"}
+	}
+
+	var signers []string
+	partial := false
+	for _, r := range results {
+		switch r.Status {
+		case SignatureValid:
+			return nil
+		case SignaturePartial:
+			partial = true
+		case SignatureInvalid:
+			return fmt.Errorf("while verifying the plugin image signatures: signature %d by %s: %s", r.ID, r.Fingerprint, r.Detail)
+		}
+		if !containsString(signers, r.Fingerprint) {
+			signers = append(signers, r.Fingerprint)
+		}
+	}
+	if partial {
+		return &NamespaceError{Name: name, Namespace: ns, Reason: "the plugin image is only partially signed by a trusted key"}
+	}
+	return &NamespaceError{Name: name, Namespace: ns, Reason: fmt.Sprintf("the plugin image is signed by %s, not by a trusted key", strings.Join(signers, ", "))}
+}
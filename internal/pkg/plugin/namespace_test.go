@@ -0,0 +1,78 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testFingerprint = "8883491F4268F173C6E5DC49EDECE4F3F38D871E"
+
+func TestNamespacePolicy(t *testing.T) {
+	p := newNamespacePolicy(
+		[]string{"example.com/Reserved", "/sylabs.io/", ""},
+		[]string{
+			"example.com/reserved:0x" + testFingerprint,
+			"sylabs.io:8883491f4268f173c6e5dc49edece4f3f38d871e",
+			"example.com/other:" + testFingerprint,
+			"example.com/reserved:8883491F",
+			"invalid",
+		},
+	)
+
+	namespaces := []string{"sylabs.io/", "singularity/", "example.com/reserved/"}
+	if !reflect.DeepEqual(p.namespaces, namespaces) {
+		t.Errorf("got namespaces %q, expected %q", p.namespaces, namespaces)
+	}
+	keys := map[string][]string{
+		"example.com/reserved/": {testFingerprint},
+		"sylabs.io/":            {testFingerprint},
+	}
+	if !reflect.DeepEqual(p.keys, keys) {
+		t.Errorf("got keys %q, expected %q", p.keys, keys)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+	}{
+		{"sylabs.io/test-plugin", "sylabs.io/"},
+		{"Sylabs.IO/test-plugin", "sylabs.io/"},
+		{"//singularity//test-plugin", "singularity/"},
+		{"singularity", "singularity/"},
+		{"example.com/reserved/foo", "example.com/reserved/"},
+		{"example.com/reservedfoo", ""},
+		{"sylabs.iox/test-plugin", ""},
+		{"github.com/sylabs.io/test-plugin", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ns := p.namespace(tt.name); ns != tt.namespace {
+				t.Errorf("got namespace %q, expected %q", ns, tt.namespace)
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyCheck(t *testing.T) {
+	p := newNamespacePolicy(nil, nil)
+
+	if err := p.check("/nonexistent.sif", "example.com/foo"); err != nil {
+		t.Errorf("unexpected error for an unreserved name: %s", err)
+	}
+
+	err := p.check("/nonexistent.sif", "example.com/foo", "sylabs.io/foo")
+	if !errors.Is(err, ErrReservedNamespace) {
+		t.Fatalf("got error %v, expected %v", err, ErrReservedNamespace)
+	}
+	var nerr *NamespaceError
+	if !errors.As(err, &nerr) || nerr.Name != "sylabs.io/foo" || nerr.Namespace != "sylabs.io/" {
+		t.Errorf("unexpected namespace error: %#v", nerr)
+	}
+}
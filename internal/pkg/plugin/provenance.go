@@ -0,0 +1,203 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// spdxLicenses lists the commonly used SPDX license identifiers,
+// see https://spdx.org/licenses/ for the complete list.
+var spdxLicenses = []string{
+	"0BSD", "AFL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1",
+	"Apache-2.0", "APSL-2.0", "Artistic-1.0", "Artistic-2.0", "BSD-1-Clause",
+	"BSD-2-Clause", "BSD-2-Clause-Patent", "BSD-3-Clause", "BSD-3-Clause-Clear",
+	"BSD-4-Clause", "BSL-1.0", "CC-BY-4.0", "CC-BY-SA-4.0", "CC0-1.0",
+	"CDDL-1.0", "CDDL-1.1", "CECILL-2.1", "CPL-1.0", "ECL-2.0", "EPL-1.0",
+	"EPL-2.0", "EUPL-1.1", "EUPL-1.2", "GPL-2.0-only", "GPL-2.0-or-later",
+	"GPL-3.0-only", "GPL-3.0-or-later", "ISC", "LGPL-2.0-only",
+	"LGPL-2.0-or-later", "LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only",
+	"LGPL-3.0-or-later", "LPPL-1.3c", "MIT", "MIT-0", "MPL-1.1", "MPL-2.0",
+	"MS-PL", "MS-RL", "MulanPSL-2.0", "NCSA", "OFL-1.1", "OpenSSL", "OSL-3.0",
+	"PostgreSQL", "Python-2.0", "Unlicense", "UPL-1.0", "W3C", "WTFPL", "X11",
+	"Zlib", "ZPL-2.1",
+	// deprecated identifiers still widely used
+	"AGPL-3.0", "GPL-2.0", "GPL-3.0", "LGPL-2.0", "LGPL-2.1", "LGPL-3.0",
+}
+
+// spdxExceptions lists the commonly used SPDX license exceptions.
+var spdxExceptions = []string{
+	"Autoconf-exception-3.0", "Bison-exception-2.2", "Classpath-exception-2.0",
+	"Font-exception-2.0", "GCC-exception-3.1", "Linux-syscall-note",
+	"LLVM-exception", "OpenJDK-assembly-exception-1.0", "Qt-GPL-exception-1.0",
+	"Universal-FOSS-exception-1.0",
+}
+
+var (
+	spdxIDRegexp     = regexp.MustCompile(`^[A-Za-z0-9.-]+$`)
+	spdxRefRegexp    = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.-]+:)?LicenseRef-[A-Za-z0-9.-]+$`)
+	spdxTokensRegexp = regexp.MustCompile(`\(|\)|[^\s()]+`)
+)
+
+// knownID returns whether id is in the list, SPDX identifiers
+// are matched case-insensitively.
+func knownID(list []string, id string) bool {
+	for _, known := range list {
+		if strings.EqualFold(known, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseParser parses a SPDX license expression:
+//
+//	expression = term { ("AND" | "OR") term }
+//	term       = "(" expression ")" | license [ "WITH" exception ]
+type licenseParser struct {
+	tokens  []string
+	unknown []string
+}
+
+func (p *licenseParser) next() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	t := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return t
+}
+
+func (p *licenseParser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *licenseParser) expression() error {
+	if err := p.term(); err != nil {
+		return err
+	}
+	for op := strings.ToUpper(p.peek()); op == "AND" || op == "OR"; op = strings.ToUpper(p.peek()) {
+		p.next()
+		if err := p.term(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *licenseParser) term() error {
+	t := p.next()
+	switch strings.ToUpper(t) {
+	case "":
+		return fmt.Errorf("missing license identifier")
+	case "(":
+		if err := p.expression(); err != nil {
+			return err
+		}
+		if p.next() != ")" {
+			return fmt.Errorf("missing closing parenthesis")
+		}
+		return nil
+	case ")", "AND", "OR", "WITH":
+		return fmt.Errorf("unexpected %q", t)
+	}
+
+	if !spdxRefRegexp.MatchString(t) {
+		id := strings.TrimSuffix(t, "+")
+		if !spdxIDRegexp.MatchString(id) {
+			return fmt.Errorf("invalid license identifier %q", t)
+		}
+		if !knownID(spdxLicenses, id) {
+			p.unknown = append(p.unknown, t)
+		}
+	}
+
+	if strings.ToUpper(p.peek()) == "WITH" {
+		p.next()
+		e := p.next()
+		if !spdxIDRegexp.MatchString(e) {
+			return fmt.Errorf("invalid license exception %q", e)
+		}
+		if !knownID(spdxExceptions, e) {
+			p.unknown = append(p.unknown, e)
+		}
+	}
+	return nil
+}
+
+// parseLicense checks the syntax of the SPDX license expression and
+// returns the license identifiers and exceptions which are not known.
+func parseLicense(license string) ([]string, error) {
+	p := &licenseParser{tokens: spdxTokensRegexp.FindAllString(license, -1)}
+	if err := p.expression(); err != nil {
+		return nil, fmt.Errorf("invalid license expression %q: %s", license, err)
+	}
+	if len(p.tokens) > 0 {
+		return nil, fmt.Errorf("invalid license expression %q: unexpected %q", license, p.tokens[0])
+	}
+	return p.unknown, nil
+}
+
+// checkEmail returns an error if s is not a bare email address.
+func checkEmail(s string) error {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return fmt.Errorf("%q is not an email address: %s", s, err)
+	}
+	if addr.Name != "" || addr.Address != s {
+		return fmt.Errorf("%q is not a bare email address", s)
+	}
+	return nil
+}
+
+// CheckProvenance returns an error if the license, URLs or maintainer
+// email of the manifest are malformed, all of them being optional. A
+// license identifier unknown to the SPDX list is only reported with
+// a warning as the list embedded is not exhaustive.
+func CheckProvenance(manifest pluginapi.Manifest) error {
+	if manifest.License != "" {
+		unknown, err := parseLicense(manifest.License)
+		if err != nil {
+			return err
+		}
+		for _, id := range unknown {
+			sylog.Warningf("Plugin %q license %q is not a known SPDX identifier", manifest.Name, id)
+		}
+	}
+
+	urls := []struct {
+		name  string
+		value string
+	}{
+		{"homepage", manifest.Homepage},
+		{"repository", manifest.Repository},
+	}
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		if err := checkURL(u.value); err != nil {
+			return fmt.Errorf("invalid %s URL: %s", u.name, err)
+		}
+	}
+
+	if manifest.MaintainerEmail != "" {
+		if err := checkEmail(manifest.MaintainerEmail); err != nil {
+			return fmt.Errorf("invalid maintainer email: %s", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestParseLicense(t *testing.T) {
+	tests := []struct {
+		license     string
+		unknown     []string
+		expectError bool
+	}{
+		{license: "BSD-3-Clause"},
+		{license: "mit"},
+		{license: "GPL-2.0+"},
+		{license: "MIT OR Apache-2.0"},
+		{license: "(MIT OR Apache-2.0) AND BSD-2-Clause"},
+		{license: "GPL-2.0-only WITH Classpath-exception-2.0"},
+		{license: "LicenseRef-Proprietary"},
+		{license: "DocumentRef-spdx-tool:LicenseRef-Custom"},
+		{license: "Foo-1.0", unknown: []string{"Foo-1.0"}},
+		{license: "MIT and Bar", unknown: []string{"Bar"}},
+		{license: "GPL-3.0-only WITH Foo-exception", unknown: []string{"Foo-exception"}},
+		{license: "", expectError: true},
+		{license: "MIT OR", expectError: true},
+		{license: "OR MIT", expectError: true},
+		{license: "MIT Apache-2.0", expectError: true},
+		{license: "(MIT", expectError: true},
+		{license: "MIT)", expectError: true},
+		{license: "MIT WITH", expectError: true},
+		{license: "BSD 3-Clause", expectError: true},
+		{license: "MIT/X11", expectError: true},
+	}
+
+	for _, tt := range tests {
+		unknown, err := parseLicense(tt.license)
+		if err != nil && !tt.expectError {
+			t.Errorf("unexpected error for license %q: %s", tt.license, err)
+		} else if err == nil && tt.expectError {
+			t.Errorf("unexpected success for license %q", tt.license)
+		} else if !reflect.DeepEqual(unknown, tt.unknown) {
+			t.Errorf("license %q: got unknown identifiers %v, expected %v", tt.license, unknown, tt.unknown)
+		}
+	}
+}
+
+func TestCheckProvenance(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    pluginapi.Manifest
+		expectError bool
+	}{
+		{
+			name: "Unset",
+		},
+		{
+			name: "Valid",
+			manifest: pluginapi.Manifest{
+				License:         "BSD-3-Clause",
+				Homepage:        "https://example.com/plugin",
+				Repository:      "https://github.com/example/plugin",
+				MaintainerEmail: "maintainer@example.com",
+			},
+		},
+		{
+			name:     "UnknownLicense",
+			manifest: pluginapi.Manifest{License: "Example-License"},
+		},
+		{
+			name:        "InvalidLicense",
+			manifest:    pluginapi.Manifest{License: "MIT OR OR BSD"},
+			expectError: true,
+		},
+		{
+			name:        "RelativeHomepage",
+			manifest:    pluginapi.Manifest{Homepage: "example.com/plugin"},
+			expectError: true,
+		},
+		{
+			name:        "GitRepository",
+			manifest:    pluginapi.Manifest{Repository: "git@github.com:example/plugin.git"},
+			expectError: true,
+		},
+		{
+			name:        "NoHost",
+			manifest:    pluginapi.Manifest{Repository: "https:///plugin"},
+			expectError: true,
+		},
+		{
+			name:        "InvalidEmail",
+			manifest:    pluginapi.Manifest{MaintainerEmail: "maintainer"},
+			expectError: true,
+		},
+		{
+			name:        "EmailWithName",
+			manifest:    pluginapi.Manifest{MaintainerEmail: "Maintainer <maintainer@example.com>"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckProvenance(tt.manifest)
+			if err != nil && !tt.expectError {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestInstallInvalidProvenance(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-provenance-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{Name: "example.com/foo", Homepage: "ftp://example.com"}
+	sifPath := createTestPlugin(t, dir, manifest)
+
+	if err := Install(sifPath, ""); err == nil {
+		t.Errorf("unexpected success with an invalid homepage")
+	}
+	if _, err := os.Stat(metaPath(manifest.Name)); !os.IsNotExist(err) {
+		t.Errorf("plugin with an invalid manifest was installed")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// Rebuild replaces the plugin binary object of the installed plugin
+// name for the host architecture with the compiled object objectPath,
+// and reinstalls the plugin in place. The plugin image is repackaged
+// with its manifest, only the API version and the binary size are
+// updated, and its signatures are removed as the new object invalidates
+// them. The configuration and the enable state of the plugin are kept,
+// sourceDir is recorded as the plugin source directory when set. On
+// failure the installed plugin is restored untouched. It returns the
+// sha256 of the previous and of the new plugin object.
+func Rebuild(name, objectPath, sourceDir string) (string, string, error) {
+	m, err := loadMetaByName(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	oldDigest := m.BinaryDigest
+	if oldDigest == "" {
+		// installed before the digest was recorded
+		if oldDigest, err = fileHash(m.binaryName()); err != nil {
+			sylog.Debugf("Could not compute digest of %s: %s", m.binaryName(), err)
+		}
+	}
+
+	// staged in rootDir so the backup is restored by a rename
+	staging, err := ioutil.TempDir(rootDir, ".rebuild-")
+	if err != nil {
+		return "", "", fmt.Errorf("while creating staging directory: %s", err)
+	}
+	defer os.RemoveAll(staging)
+
+	path, release, err := m.openImage()
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+
+	image := filepath.Join(staging, nameImage)
+	if err := rebuildImage(path, objectPath, image); err != nil {
+		return "", "", fmt.Errorf("while repackaging plugin image: %s", err)
+	}
+
+	backup := filepath.Join(staging, "backup")
+	if err := copyTree(m.path(), backup); err != nil {
+		return "", "", fmt.Errorf("while saving plugin directory: %s", err)
+	}
+
+	source := sourceDir
+	if source == "" {
+		source = objectPath
+	}
+	opts := installOptions{
+		action:    HistoryRebuild,
+		source:    source,
+		sourceDir: sourceDir,
+		keepState: true,
+		// the image stays compressed
+		compressImage: m.ImageCompression != "",
+	}
+	rebuilt, err := installSIF(image, m.Name, opts)
+	if err != nil {
+		if rerr := restoreTree(backup, m.path()); rerr != nil {
+			return "", "", fmt.Errorf("%s, and restoring the plugin failed: %s", err, rerr)
+		}
+		return "", "", err
+	}
+
+	return oldDigest, rebuilt.BinaryDigest, nil
+}
+
+// rebuildImage writes to outPath the plugin image path with the plugin
+// binary object for the host architecture replaced by objectPath.
+func rebuildImage(path, objectPath, outPath string) error {
+	arch, err := elfArch(objectPath)
+	if err != nil {
+		return err
+	}
+	if arch != runtime.GOARCH {
+		return fmt.Errorf("plugin object %s is built for %s instead of %s", objectPath, arch, runtime.GOARCH)
+	}
+
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	err = copyImage(out, path)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("while copying plugin image %s: %s", path, err)
+	}
+
+	fimg, err := sif.LoadContainer(outPath, false)
+	if err != nil {
+		return fmt.Errorf("could not load plugin: %w", err)
+	}
+	manifest, err := replaceBinary(&fimg, objectPath, arch)
+	fimg.UnloadContainer()
+	if err != nil {
+		return err
+	}
+
+	// the object was loaded by the caller, it's
+	// compatible with the API of this singularity
+	manifest.APIVersion = pluginapi.APIVersion
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("while encoding plugin manifest: %s", err)
+	}
+	return replaceManifest(outPath, data, true)
+}
+
+// replaceBinary replaces the primary plugin binary objects of fimg for
+// arch with objectPath, compressed as declared by the manifest, and
+// returns the manifest with the binary size updated accordingly.
+func replaceBinary(fimg *sif.FileImage, objectPath, arch string) (pluginapi.Manifest, error) {
+	sr := newSifFileImageReader(fimg)
+	if findDescriptor(sr, pluginManifestName) < 0 {
+		return pluginapi.Manifest{}, fmt.Errorf("no plugin manifest found")
+	}
+	manifest := getManifest(sr)
+
+	signatures := 0
+	for _, d := range fimg.DescrArr {
+		if d.Used && d.Datatype == sif.DataSignature {
+			signatures++
+		}
+	}
+	if signatures > 0 {
+		sylog.Warningf("The %d signature(s) of the plugin image are removed, the recompiled plugin is unsigned", signatures)
+	}
+
+	obj, err := os.Open(objectPath)
+	if err != nil {
+		return pluginapi.Manifest{}, fmt.Errorf("while opening plugin object %s: %s", objectPath, err)
+	}
+	defer obj.Close()
+
+	fi, err := obj.Stat()
+	if err != nil {
+		return pluginapi.Manifest{}, fmt.Errorf("while getting size of plugin object %s: %s", objectPath, err)
+	}
+
+	var objReader io.Reader = obj
+	objSize := fi.Size()
+
+	if manifest.BinaryCompression != "" {
+		sizes := map[string]int64{arch: objSize}
+		for a, size := range manifest.BinarySizes {
+			if a != arch {
+				sizes[a] = size
+			}
+		}
+		manifest.BinarySizes = sizes
+
+		tmp, err := ioutil.TempFile(fs.TempDir(), "plugin-object-")
+		if err != nil {
+			return pluginapi.Manifest{}, fmt.Errorf("while creating temporary file: %s", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if err := CompressBinary(tmp, obj, manifest.BinaryCompression); err != nil {
+			return pluginapi.Manifest{}, err
+		}
+		if objSize, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+			return pluginapi.Manifest{}, err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return pluginapi.Manifest{}, err
+		}
+		objReader = tmp
+	}
+
+	var ids []uint32
+	for _, b := range primaryBinaries(fimg) {
+		if b.arch == arch {
+			ids = append(ids, b.descr.ID)
+		}
+	}
+	for _, id := range ids {
+		if err := deleteObject(fimg, id); err != nil {
+			return pluginapi.Manifest{}, fmt.Errorf("while removing plugin binary %d: %s", id, err)
+		}
+	}
+
+	input := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    primaryBinaryName(manifest),
+		Fp:       objReader,
+		Size:     objSize,
+	}
+	if err := input.SetPartExtra(sif.FsRaw, sif.PartData, sif.GetSIFArch(arch)); err != nil {
+		return pluginapi.Manifest{}, err
+	}
+	if err := fimg.AddObject(input); err != nil {
+		return pluginapi.Manifest{}, fmt.Errorf("while adding plugin binary: %s", err)
+	}
+
+	return manifest, nil
+}
+
+// copyTree copies the directory tree src to dst, which must not exist,
+// preserving the permissions. Only directories, regular files and
+// symbolic links are copied.
+func copyTree(src, dst string) error {
+	var dirs []string
+	var modes []os.FileMode
+
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := fi.Mode(); {
+		case mode.IsDir():
+			// writable until their content is copied
+			dirs = append(dirs, target)
+			modes = append(modes, mode.Perm())
+			return os.Mkdir(target, 0700)
+		case mode.IsRegular():
+			if err := fs.CopyFile(path, target, 0600); err != nil {
+				return err
+			}
+			// set after the creation to not depend on the umask
+			return os.Chmod(target, mode.Perm())
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreTree replaces the directory dir with its copy backup.
+func restoreTree(backup, dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(backup, dir)
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+)
+
+// readTree returns the content of the regular files of the directory
+// tree dir along with their permissions, indexed by relative path.
+func readTree(t *testing.T, dir string) map[string]string {
+	tree := make(map[string]string)
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if !fi.Mode().IsRegular() {
+			tree[rel] = fi.Mode().String()
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree[rel] = fi.Mode().String() + " " + string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("while reading directory %s: %s", dir, err)
+	}
+	return tree
+}
+
+func TestRebuildImage(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+	exeData, err := ioutil.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("while reading test executable: %s", err)
+	}
+
+	signer, err := openpgp.NewEntity("Signer", "", "signer@example.org", nil)
+	if err != nil {
+		t.Fatalf("while creating key: %s", err)
+	}
+
+	var compressed bytes.Buffer
+	if err := CompressBinary(&compressed, bytes.NewReader([]byte("plugin object")), CompressionZstd); err != nil {
+		t.Fatalf("while compressing plugin object: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		create func(t *testing.T, dir string) string
+	}{
+		{
+			name: "Uncompressed",
+			create: func(t *testing.T, dir string) string {
+				path := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/rebuild", Version: "1.0.0"})
+				signTestPlugin(t, path, signer)
+				return path
+			},
+		},
+		{
+			name: "Compressed",
+			create: func(t *testing.T, dir string) string {
+				manifest := pluginapi.Manifest{
+					Name:              "example.com/rebuild",
+					Version:           "1.0.0",
+					BinaryCompression: CompressionZstd,
+					BinarySizes:       map[string]int64{runtime.GOARCH: int64(len("plugin object"))},
+				}
+				return createObjectTestPlugin(t, dir, manifest, compressed.Bytes())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-rebuild-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := tt.create(t, dir)
+			outPath := filepath.Join(dir, "rebuilt.sif")
+
+			if err := rebuildImage(path, exe, outPath); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			manifest, err := Inspect(outPath)
+			if err != nil {
+				t.Fatalf("while inspecting plugin image: %s", err)
+			}
+			if manifest.Name != "example.com/rebuild" || manifest.Version != "1.0.0" {
+				t.Errorf("got manifest %s %s, expected example.com/rebuild 1.0.0", manifest.Name, manifest.Version)
+			}
+			if manifest.APIVersion != pluginapi.APIVersion {
+				t.Errorf("got API version %d, expected %d", manifest.APIVersion, pluginapi.APIVersion)
+			}
+			if manifest.BinaryCompression != "" && manifest.BinarySizes[runtime.GOARCH] != int64(len(exeData)) {
+				t.Errorf("got binary size %d, expected %d", manifest.BinarySizes[runtime.GOARCH], len(exeData))
+			}
+
+			fimg, err := sif.LoadContainer(outPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			if binaries := pluginBinaries(&fimg); len(binaries) != 1 {
+				t.Errorf("got %d plugin binaries, expected 1", len(binaries))
+			}
+			var b bytes.Buffer
+			if err := extractBinary(&b, &fimg); err != nil {
+				t.Fatalf("while extracting plugin binary: %s", err)
+			}
+			if !bytes.Equal(b.Bytes(), exeData) {
+				t.Errorf("extracted plugin binary differs from the compiled object")
+			}
+			if results := verifySignatures(context.Background(), &fimg, openpgp.EntityList{signer}, SignatureOptions{}); len(results) != 0 {
+				t.Errorf("unexpected signatures %+v", results)
+			}
+		})
+	}
+}
+
+func TestRebuildImageError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-rebuild-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/rebuild"})
+	notELF := filepath.Join(dir, "plugin.so")
+	if err := ioutil.WriteFile(notELF, []byte("not an object"), 0644); err != nil {
+		t.Fatalf("while writing object: %s", err)
+	}
+
+	if err := rebuildImage(path, notELF, filepath.Join(dir, "rebuilt.sif")); err == nil {
+		t.Errorf("unexpected success with an object which is not ELF")
+	}
+}
+
+func TestRebuildRestore(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "plugin-rebuild-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/rebuild", Version: "1.0.0"})
+	installTestPlugin(t, sifPath, "example.com/rebuild", false)
+
+	m, err := Lookup("example.com/rebuild")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if err := ioutil.WriteFile(m.ConfigPath, []byte("# customized\n"), 0640); err != nil {
+		t.Fatalf("while customizing configuration: %s", err)
+	}
+	before := readTree(t, rootDir)
+
+	// the test executable is not a plugin, it can't be installed
+	if _, _, err := Rebuild("example.com/rebuild", exe, dir); err == nil {
+		t.Fatalf("unexpected success rebuilding with a non plugin object")
+	}
+
+	if after := readTree(t, rootDir); !reflect.DeepEqual(before, after) {
+		t.Errorf("installed plugin was modified by the failed rebuild")
+	}
+}
+
+func TestSourceDir(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("while getting test executable: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "plugin-rebuild-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{Name: "example.com/source"}
+	sifPath := filepath.Join(dir, "source.sif")
+	if err := CreateSIF(exe, manifest, sifPath, WithSourceDir("/src/plugin")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if src := readSourceDir(newSifFileImageReader(&fimg)); src != "/src/plugin" {
+		t.Errorf("got source directory %q, expected /src/plugin", src)
+	}
+
+	if err := CreateSIF(exe, manifest, filepath.Join(dir, "relative.sif"), WithSourceDir("src/plugin")); err == nil {
+		t.Errorf("unexpected success with a relative source directory")
+	}
+}
@@ -7,6 +7,9 @@ package plugin
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/sylabs/sif/pkg/sif"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
@@ -20,71 +23,351 @@ const (
 	// pluginManifestName is the name of the plugin manifest within
 	// the SIF file
 	pluginManifestName = "plugin.manifest"
+	// pluginChangelogName is the name of the optional plugin
+	// release notes within the SIF file
+	pluginChangelogName = "plugin.changelog"
+	// pluginSourceName is the name of the optional path of the
+	// plugin source directory within the SIF file
+	pluginSourceName = "plugin.source"
+	// pluginConfigName is the name of the optional default
+	// configuration file template within the SIF file
+	pluginConfigName = "plugin.config"
 )
 
-// sifReader defines helper functions fimg *sif.FileImage.
+// sifReader defines helper functions fimg *sif.FileImage, the
+// descriptors are addressed by their index.
 type sifReader interface {
 	Descriptors() int
-	IsUsed(name string) bool
-	GetDatatype(name string) sif.Datatype
-	GetFsType(name string) (sif.Fstype, error)
-	GetPartType(name string) (sif.Parttype, error)
-	GetData(name string) []byte
+	IsUsed(n int) bool
+	GetName(n int) string
+	GetDatatype(n int) sif.Datatype
+	GetFsType(n int) (sif.Fstype, error)
+	GetPartType(n int) (sif.Parttype, error)
+	GetData(n int) []byte
 }
 
-// isPluginFile checks if the sif.FileImage contains the sections which
-// make up a valid plugin. A plugin sif file should have the following
-// format:
+// findDescriptor returns the index of the first used descriptor of
+// fimg named name, or -1 if there is none.
+func findDescriptor(fimg sifReader, name string) int {
+	for n := 0; n < fimg.Descriptors(); n++ {
+		if fimg.IsUsed(n) && fimg.GetName(n) == name {
+			return n
+		}
+	}
+	return -1
+}
+
+// binaryLayout is a descriptor layout accepted for the plugin
+// binary objects.
+type binaryLayout struct {
+	datatype sif.Datatype
+	// partition is false for the layouts without partition
+	// information, fstype and parttype are then ignored
+	partition bool
+	fstype    sif.Fstype
+	parttype  sif.Parttype
+	// historical layouts were written by former packaging tools,
+	// they are only accepted for descriptors named pluginBinaryName
+	// holding an uncompressed ELF shared object
+	historical bool
+}
+
+// binaryLayouts are the descriptor layouts accepted for the plugin
+// binary objects, the documented one first.
+var binaryLayouts = []binaryLayout{
+	{datatype: sif.DataPartition, partition: true, fstype: sif.FsRaw, parttype: sif.PartData},
+	{datatype: sif.DataPartition, partition: true, fstype: sif.FsRaw, parttype: sif.PartSystem, historical: true},
+	{datatype: sif.DataGeneric, historical: true},
+}
+
+// descriptorLayout returns the binary layout matching the descriptor
+// n of fimg, if any.
+func descriptorLayout(fimg sifReader, n int) (binaryLayout, bool) {
+	for _, l := range binaryLayouts {
+		if fimg.GetDatatype(n) != l.datatype {
+			continue
+		}
+		if !l.partition {
+			return l, true
+		}
+		if fstype, err := fimg.GetFsType(n); err != nil || fstype != l.fstype {
+			continue
+		}
+		if partype, err := fimg.GetPartType(n); err != nil || partype != l.parttype {
+			continue
+		}
+		return l, true
+	}
+	return binaryLayout{}, false
+}
+
+// isBinaryDescriptor returns if the descriptor n of fimg holds
+// a plugin binary object, see binaryLayouts.
+func isBinaryDescriptor(fimg sifReader, n int) bool {
+	if !fimg.IsUsed(n) {
+		return false
+	}
+	l, ok := descriptorLayout(fimg, n)
+	if !ok {
+		return false
+	}
+	if !l.historical {
+		return true
+	}
+	if fimg.GetName(n) != pluginBinaryName {
+		return false
+	}
+	_, err := sharedObjectArch(fimg.GetData(n))
+	return err == nil
+}
+
+// fsTypeName returns the name of the file system type fstype.
+func fsTypeName(fstype sif.Fstype) string {
+	switch fstype {
+	case sif.FsSquash:
+		return "Squashfs"
+	case sif.FsExt3:
+		return "Ext3"
+	case sif.FsImmuObj:
+		return "Archive"
+	case sif.FsRaw:
+		return "Raw"
+	case sif.FsEncryptedSquashfs:
+		return "Encrypted squashfs"
+	}
+	return fmt.Sprintf("unknown (%d)", fstype)
+}
+
+// partTypeName returns the name of the partition type partype.
+func partTypeName(partype sif.Parttype) string {
+	switch partype {
+	case sif.PartSystem:
+		return "System"
+	case sif.PartPrimSys:
+		return "Primary system"
+	case sif.PartData:
+		return "Data"
+	case sif.PartOverlay:
+		return "Overlay"
+	}
+	return fmt.Sprintf("unknown (%d)", partype)
+}
+
+// binaryFinding returns the finding explaining why the descriptor n
+// of fimg, named pluginBinaryName, is not a plugin binary object.
+func binaryFinding(fimg sifReader, n int) Finding {
+	if l, ok := descriptorLayout(fimg, n); ok {
+		// only historical layouts are rejected for their content
+		_, err := sharedObjectArch(fimg.GetData(n))
+		if !l.partition {
+			return Finding{FindingWrongDatatype, n, fmt.Sprintf("%s has data type %s instead of %s and holds no ELF shared object: %s", pluginBinaryName, l.datatype, sif.DataPartition, err)}
+		}
+		return Finding{FindingWrongPartType, n, fmt.Sprintf("%s is a %s partition instead of a %s one and holds no ELF shared object: %s", pluginBinaryName, partTypeName(l.parttype), partTypeName(sif.PartData), err)}
+	}
+
+	if dt := fimg.GetDatatype(n); dt != sif.DataPartition {
+		return Finding{FindingWrongDatatype, n, fmt.Sprintf("%s has data type %s instead of %s", pluginBinaryName, dt, sif.DataPartition)}
+	}
+	fstype, err := fimg.GetFsType(n)
+	if err != nil {
+		return Finding{FindingWrongFsType, n, fmt.Sprintf("%s file system type: %s", pluginBinaryName, err)}
+	} else if fstype != sif.FsRaw {
+		return Finding{FindingWrongFsType, n, fmt.Sprintf("%s is a %s partition instead of a %s one", pluginBinaryName, fsTypeName(fstype), fsTypeName(sif.FsRaw))}
+	}
+	partype, err := fimg.GetPartType(n)
+	if err != nil {
+		return Finding{FindingWrongPartType, n, fmt.Sprintf("%s partition type: %s", pluginBinaryName, err)}
+	}
+	return Finding{FindingWrongPartType, n, fmt.Sprintf("%s is a %s partition instead of a %s one", pluginBinaryName, partTypeName(partype), partTypeName(sif.PartData))}
+}
+
+// FindingKind identifies a problem making an image an invalid
+// plugin image.
+type FindingKind int
+
+const (
+	// FindingNoManifest reports a missing manifest descriptor.
+	FindingNoManifest FindingKind = iota + 1
+	// FindingBadManifest reports a manifest which is not valid JSON.
+	FindingBadManifest
+	// FindingNoBinary reports a missing plugin binary object.
+	FindingNoBinary
+	// FindingWrongDatatype reports a descriptor with a wrong data type.
+	FindingWrongDatatype
+	// FindingWrongFsType reports a binary descriptor with a wrong
+	// file system type.
+	FindingWrongFsType
+	// FindingWrongPartType reports a binary descriptor with a wrong
+	// partition type.
+	FindingWrongPartType
+	// FindingCorrupted reports an image which is not a readable SIF.
+	FindingCorrupted
+	// FindingTruncated reports an image shorter than its layout.
+	FindingTruncated
+	// FindingOutOfBounds reports a data object outside of the data
+	// section of the image.
+	FindingOutOfBounds
+	// FindingOverlap reports data objects sharing bytes.
+	FindingOverlap
+	// FindingHashMismatch reports a data object not matching the
+	// hash recorded by a signature.
+	FindingHashMismatch
+	// FindingBadBinary reports a plugin binary object which is not
+	// an ELF shared object of a declared architecture.
+	FindingBadBinary
+)
+
+// Finding is a problem found in a plugin image.
+type Finding struct {
+	Kind FindingKind
+	// Descriptor is the index of the SIF descriptor concerned,
+	// or -1 when the finding concerns the whole image.
+	Descriptor int
+	// Detail describes the problem.
+	Detail string
+}
+
+func (f Finding) String() string {
+	if f.Descriptor < 0 {
+		return f.Detail
+	}
+	return fmt.Sprintf("descriptor %d: %s", f.Descriptor, f.Detail)
+}
+
+// PluginFileError reports the findings making an image an
+// invalid plugin image.
+type PluginFileError struct {
+	Findings []Finding
+	// ContainerImage is set when the image looks like a
+	// container image (see looksLikeContainerImage).
+	ContainerImage bool
+}
+
+func (e *PluginFileError) Error() string {
+	if e.ContainerImage {
+		return "this looks like a container image, not a plugin: plugins must be built with 'singularity plugin compile'"
+	}
+	findings := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		findings[i] = f.String()
+	}
+	return "not a valid plugin: " + strings.Join(findings, "; ")
+}
+
+// pluginFileFindings checks if the sif.FileImage contains the sections
+// which make up a valid plugin and returns the problems found. A plugin
+// sif file should have the following format:
 //
 // DESCR[0]: Sifplugin
 //   - Datatype: sif.DataPartition
 //   - Fstype:   sif.FsRaw
 //   - Parttype: sif.PartData
+//   - Data:     raw, gzip or zstd compressed object
+//               (see Manifest.BinaryCompression)
 // DESCR[1]: Sifmanifest
 //   - Datatype: sif.DataGenericJSON
-func isPluginFile(fimg sifReader) bool {
-	if fimg.Descriptors() < 2 {
-		return false
-	}
+//   - Data:     JSON manifest
+// DESCR[2]: Sifchangelog (optional)
+//   - Datatype: sif.DataGeneric
+// DESCR[3]: Sifsource (optional)
+//   - Datatype: sif.DataGeneric
+//   - Data:     path of the plugin source directory
+//
+// Images written by former packaging tools store the binary object
+// as a sif.PartSystem partition or as sif.DataGeneric data, those are
+// accepted when the data is an uncompressed ELF shared object.
+//
+// An image may hold several binary objects, one per architecture
+// and possibly helper objects next to the plugin one, the binary
+// loaded is selected at installation (see binaryForHost).
+func pluginFileFindings(fimg sifReader) []Finding {
+	var findings []Finding
 
-	if !fimg.IsUsed(pluginBinaryName) {
-		return false
+	// the descriptors using the plugin binary name which are not
+	// binary objects explain a missing binary, helper objects can
+	// be any data
+	var binaryFindings []Finding
+	binaries := 0
+	for n := 0; n < fimg.Descriptors(); n++ {
+		if isBinaryDescriptor(fimg, n) {
+			binaries++
+			continue
+		}
+		if !fimg.IsUsed(n) || fimg.GetName(n) != pluginBinaryName {
+			continue
+		}
+		binaryFindings = append(binaryFindings, binaryFinding(fimg, n))
 	}
-
-	if fimg.GetDatatype(pluginBinaryName) != sif.DataPartition {
-		return false
+	if binaries == 0 {
+		findings = append(findings, Finding{FindingNoBinary, -1, "no plugin binary object"})
+		findings = append(findings, binaryFindings...)
 	}
 
-	if fstype, err := fimg.GetFsType(pluginBinaryName); err != nil {
-		return false
-	} else if fstype != sif.FsRaw {
-		return false
+	n := findDescriptor(fimg, pluginManifestName)
+	if n < 0 {
+		findings = append(findings, Finding{FindingNoManifest, -1, fmt.Sprintf("no %s descriptor", pluginManifestName)})
+	} else if dt := fimg.GetDatatype(n); dt != sif.DataGenericJSON {
+		findings = append(findings, Finding{FindingWrongDatatype, n, fmt.Sprintf("%s has data type %s instead of %s", pluginManifestName, dt, sif.DataGenericJSON)})
+	} else if !json.Valid(fimg.GetData(n)) {
+		findings = append(findings, Finding{FindingBadManifest, n, fmt.Sprintf("%s is not valid JSON", pluginManifestName)})
 	}
 
-	if partype, err := fimg.GetPartType(pluginBinaryName); err != nil {
-		return false
-	} else if partype != sif.PartData {
-		return false
-	}
+	return findings
+}
 
-	if !fimg.IsUsed(pluginManifestName) {
-		return false
+// looksLikeContainerImage returns if fimg holds a primary system
+// partition with a squashfs or ext3 file system along with a
+// definition file, as the container images built by singularity.
+func looksLikeContainerImage(fimg sifReader) bool {
+	rootfs, deffile := false, false
+
+	for n := 0; n < fimg.Descriptors(); n++ {
+		if !fimg.IsUsed(n) {
+			continue
+		}
+		switch fimg.GetDatatype(n) {
+		case sif.DataDeffile:
+			deffile = true
+		case sif.DataPartition:
+			if partype, err := fimg.GetPartType(n); err != nil || partype != sif.PartPrimSys {
+				continue
+			}
+			switch fstype, _ := fimg.GetFsType(n); fstype {
+			case sif.FsSquash, sif.FsExt3, sif.FsEncryptedSquashfs:
+				rootfs = true
+			}
+		}
 	}
 
-	if fimg.GetDatatype(pluginManifestName) != sif.DataGenericJSON {
-		return false
+	return rootfs && deffile
+}
+
+// checkPluginFile returns a PluginFileError holding the findings
+// of pluginFileFindings, if any.
+func checkPluginFile(fimg sifReader) error {
+	if findings := pluginFileFindings(fimg); len(findings) > 0 {
+		return &PluginFileError{
+			Findings:       findings,
+			ContainerImage: looksLikeContainerImage(fimg),
+		}
 	}
+	return nil
+}
 
-	return true
+// isPluginFile checks if the sif.FileImage is a valid plugin
+// image, see pluginFileFindings.
+func isPluginFile(fimg sifReader) bool {
+	return len(pluginFileFindings(fimg)) == 0
 }
 
 // getManifest will extract the Manifest data from the input FileImage.
 func getManifest(fimg sifReader) pluginapi.Manifest {
-	if fimg.Descriptors() < 2 || !fimg.IsUsed(pluginManifestName) {
+	n := findDescriptor(fimg, pluginManifestName)
+	if fimg.Descriptors() < 2 || n < 0 {
 		return pluginapi.Manifest{}
 	}
 
-	data := fimg.GetData(pluginManifestName)
+	data := fimg.GetData(n)
 	if data == nil {
 		return pluginapi.Manifest{}
 	}
@@ -98,49 +381,54 @@ func getManifest(fimg sifReader) pluginapi.Manifest {
 	return manifest
 }
 
+// readSourceDir returns the path of the plugin source directory
+// recorded in fimg, or an empty string if there is none.
+func readSourceDir(fimg sifReader) string {
+	n := findDescriptor(fimg, pluginSourceName)
+	if n < 0 || fimg.GetDatatype(n) != sif.DataGeneric {
+		return ""
+	}
+	dir := string(fimg.GetData(n))
+	if !filepath.IsAbs(dir) || strings.ContainsAny(dir, "\x00\n") {
+		return ""
+	}
+	return filepath.Clean(dir)
+}
+
 type sifFileImageReader struct {
-	fi          *sif.FileImage
-	descriptors map[string]int
+	fi *sif.FileImage
 }
 
 func newSifFileImageReader(fi *sif.FileImage) *sifFileImageReader {
-	r := &sifFileImageReader{fi: fi, descriptors: make(map[string]int)}
-	for n, desc := range fi.DescrArr {
-		if !desc.Used {
-			continue
-		}
-		r.descriptors[fi.DescrArr[n].GetName()] = n
-	}
-	return r
+	return &sifFileImageReader{fi: fi}
 }
 
 func (r *sifFileImageReader) Descriptors() int {
 	return len(r.fi.DescrArr)
 }
 
-func (r *sifFileImageReader) IsUsed(name string) bool {
-	n := r.descriptors[name]
+func (r *sifFileImageReader) IsUsed(n int) bool {
 	return r.fi.DescrArr[n].Used
 }
 
-func (r *sifFileImageReader) GetDatatype(name string) sif.Datatype {
-	n := r.descriptors[name]
+func (r *sifFileImageReader) GetName(n int) string {
+	return r.fi.DescrArr[n].GetName()
+}
+
+func (r *sifFileImageReader) GetDatatype(n int) sif.Datatype {
 	return r.fi.DescrArr[n].Datatype
 }
 
-func (r *sifFileImageReader) GetFsType(name string) (sif.Fstype, error) {
-	n := r.descriptors[name]
+func (r *sifFileImageReader) GetFsType(n int) (sif.Fstype, error) {
 	return r.fi.DescrArr[n].GetFsType()
 }
 
-func (r *sifFileImageReader) GetPartType(name string) (sif.Parttype, error) {
-	n := r.descriptors[name]
+func (r *sifFileImageReader) GetPartType(n int) (sif.Parttype, error) {
 	return r.fi.DescrArr[n].GetPartType()
 }
 
-func (r *sifFileImageReader) GetData(name string) []byte {
+func (r *sifFileImageReader) GetData(n int) []byte {
 	var (
-		n     = r.descriptors[name]
 		start = r.fi.DescrArr[n].Fileoff
 		end   = start + r.fi.DescrArr[n].Filelen
 		data  = r.fi.Filedata[start:end]
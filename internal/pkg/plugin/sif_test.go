@@ -6,9 +6,17 @@
 package plugin
 
 import (
+	"debug/elf"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 
+	uuid "github.com/satori/go.uuid"
 	"github.com/sylabs/sif/pkg/sif"
 	pluginapi "github.com/sylabs/singularity/pkg/plugin"
 )
@@ -28,56 +36,30 @@ func (r testSifReader) Descriptors() int {
 	return len(r)
 }
 
-func (r testSifReader) IsUsed(name string) bool {
-	n := r.findByName(name)
-	if n < 0 {
-		return false
-	}
+func (r testSifReader) IsUsed(n int) bool {
 	return r[n].used
 }
 
-func (r testSifReader) GetDatatype(name string) sif.Datatype {
-	n := r.findByName(name)
-	if n < 0 {
-		return -1
-	}
+func (r testSifReader) GetName(n int) string {
+	return r[n].name
+}
+
+func (r testSifReader) GetDatatype(n int) sif.Datatype {
 	return r[n].datatype
 }
 
-func (r testSifReader) GetFsType(name string) (sif.Fstype, error) {
-	n := r.findByName(name)
-	if n < 0 {
-		return -1, r[n].fserror
-	}
+func (r testSifReader) GetFsType(n int) (sif.Fstype, error) {
 	return r[n].fstype, r[n].fserror
 }
 
-func (r testSifReader) GetPartType(name string) (sif.Parttype, error) {
-	n := r.findByName(name)
-	if n < 0 {
-		return -1, r[n].parterror
-	}
+func (r testSifReader) GetPartType(n int) (sif.Parttype, error) {
 	return r[n].parttype, r[n].parterror
 }
 
-func (r testSifReader) GetData(name string) []byte {
-	n := r.findByName(name)
-	if n < 0 {
-		return nil
-	}
+func (r testSifReader) GetData(n int) []byte {
 	return r[n].data
 }
 
-func (r testSifReader) findByName(name string) int {
-	for n, data := range r {
-		if data.name == name {
-			return n
-		}
-	}
-
-	return -1
-}
-
 func TestIsPluginFile(t *testing.T) {
 	cases := []struct {
 		description string
@@ -222,6 +204,7 @@ func TestIsPluginFile(t *testing.T) {
 					used:     true,
 					name:     pluginManifestName,
 					datatype: sif.DataGenericJSON,
+					data:     []byte("{}"),
 				},
 			},
 			expected: true,
@@ -233,6 +216,7 @@ func TestIsPluginFile(t *testing.T) {
 					used:     true,
 					name:     pluginManifestName,
 					datatype: sif.DataGenericJSON,
+					data:     []byte("{}"),
 				},
 				{
 					used:     true,
@@ -251,6 +235,7 @@ func TestIsPluginFile(t *testing.T) {
 					used:     true,
 					name:     pluginManifestName,
 					datatype: sif.DataGenericJSON,
+					data:     []byte("{}"),
 				},
 				{
 					used:     true,
@@ -267,6 +252,51 @@ func TestIsPluginFile(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			description: "good image several binaries",
+			sif: testSifReader{
+				{
+					used:     true,
+					name:     pluginBinaryName,
+					datatype: sif.DataPartition,
+					fstype:   sif.FsRaw,
+					parttype: sif.PartData,
+				},
+				{
+					used:     true,
+					name:     pluginManifestName,
+					datatype: sif.DataGenericJSON,
+					data:     []byte("{}"),
+				},
+				{
+					used:     true,
+					name:     pluginBinaryName,
+					datatype: sif.DataPartition,
+					fstype:   sif.FsSquash,
+					parttype: sif.PartData,
+				},
+			},
+			expected: true,
+		},
+		{
+			description: "good image helper binary",
+			sif: testSifReader{
+				{
+					used:     true,
+					name:     "helper.so",
+					datatype: sif.DataPartition,
+					fstype:   sif.FsRaw,
+					parttype: sif.PartData,
+				},
+				{
+					used:     true,
+					name:     pluginManifestName,
+					datatype: sif.DataGenericJSON,
+					data:     []byte("{}"),
+				},
+			},
+			expected: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -282,6 +312,326 @@ func TestIsPluginFile(t *testing.T) {
 	}
 }
 
+// fixtureDescriptor is a descriptor of a plugin image fixture, the
+// partition types are only set for sif.DataPartition descriptors.
+type fixtureDescriptor struct {
+	name     string
+	datatype sif.Datatype
+	fstype   sif.Fstype
+	parttype sif.Parttype
+	data     string
+}
+
+// createFixtureImage creates in dir a SIF image with the descriptors
+// descrs and returns its path.
+func createFixtureImage(t *testing.T, dir string, descrs []fixtureDescriptor) string {
+	var inputs []sif.DescriptorInput
+
+	for _, d := range descrs {
+		input := sif.DescriptorInput{
+			Datatype: d.datatype,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    d.name,
+			Data:     []byte(d.data),
+			Size:     int64(len(d.data)),
+		}
+		if d.datatype == sif.DataPartition {
+			if err := input.SetPartExtra(d.fstype, d.parttype, sif.GetSIFArch(runtime.GOARCH)); err != nil {
+				t.Fatalf("while setting partition information: %s", err)
+			}
+		}
+		inputs = append(inputs, input)
+	}
+
+	path := filepath.Join(dir, "fixture.sif")
+	_, err := sif.CreateContainer(sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	})
+	if err != nil {
+		t.Fatalf("while creating image: %s", err)
+	}
+	return path
+}
+
+func TestPluginFileFindings(t *testing.T) {
+	binary := fixtureDescriptor{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartData, "object"}
+	manifest := fixtureDescriptor{name: pluginManifestName, datatype: sif.DataGenericJSON, data: `{"name":"example.com/fixture"}`}
+	sharedObject := testELFObject(t, elf.ET_DYN)
+
+	cases := []struct {
+		description string
+		descrs      []fixtureDescriptor
+		findings    []Finding
+	}{
+		{
+			description: "valid",
+			descrs:      []fixtureDescriptor{binary, manifest},
+		},
+		{
+			description: "helper object",
+			descrs: []fixtureDescriptor{
+				binary,
+				{name: "helper.dat", datatype: sif.DataGeneric, data: "helper"},
+				manifest,
+			},
+		},
+		{
+			description: "missing manifest",
+			descrs:      []fixtureDescriptor{binary},
+			findings:    []Finding{{Kind: FindingNoManifest, Descriptor: -1}},
+		},
+		{
+			description: "manifest wrong data type",
+			descrs: []fixtureDescriptor{
+				binary,
+				{name: pluginManifestName, datatype: sif.DataGeneric, data: "{}"},
+			},
+			findings: []Finding{{Kind: FindingWrongDatatype, Descriptor: 1}},
+		},
+		{
+			description: "manifest unparseable",
+			descrs: []fixtureDescriptor{
+				binary,
+				{name: pluginManifestName, datatype: sif.DataGenericJSON, data: `{"name":`},
+			},
+			findings: []Finding{{Kind: FindingBadManifest, Descriptor: 1}},
+		},
+		{
+			description: "missing binary",
+			descrs:      []fixtureDescriptor{manifest},
+			findings:    []Finding{{Kind: FindingNoBinary, Descriptor: -1}},
+		},
+		{
+			description: "binary wrong data type",
+			descrs: []fixtureDescriptor{
+				{name: pluginBinaryName, datatype: sif.DataGeneric, data: "object"},
+				manifest,
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongDatatype, Descriptor: 0},
+			},
+		},
+		{
+			description: "binary wrong file system type",
+			descrs: []fixtureDescriptor{
+				manifest,
+				{pluginBinaryName, sif.DataPartition, sif.FsSquash, sif.PartData, "object"},
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongFsType, Descriptor: 1},
+			},
+		},
+		{
+			description: "binary wrong partition type",
+			descrs: []fixtureDescriptor{
+				{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartPrimSys, "object"},
+				manifest,
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongPartType, Descriptor: 0},
+			},
+		},
+		{
+			description: "historical system partition",
+			descrs: []fixtureDescriptor{
+				{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartSystem, sharedObject},
+				manifest,
+			},
+		},
+		{
+			description: "historical generic data",
+			descrs: []fixtureDescriptor{
+				{name: pluginBinaryName, datatype: sif.DataGeneric, data: sharedObject},
+				manifest,
+			},
+		},
+		{
+			description: "historical system partition not ELF",
+			descrs: []fixtureDescriptor{
+				{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartSystem, "object"},
+				manifest,
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongPartType, Descriptor: 0},
+			},
+		},
+		{
+			description: "historical generic data executable",
+			descrs: []fixtureDescriptor{
+				{name: pluginBinaryName, datatype: sif.DataGeneric, data: testELFObject(t, elf.ET_EXEC)},
+				manifest,
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongDatatype, Descriptor: 0},
+			},
+		},
+		{
+			description: "historical layout for another name",
+			descrs: []fixtureDescriptor{
+				{name: "helper.so", datatype: sif.DataGeneric, data: sharedObject},
+				manifest,
+			},
+			findings: []Finding{{Kind: FindingNoBinary, Descriptor: -1}},
+		},
+		{
+			description: "historical primary system partition",
+			descrs: []fixtureDescriptor{
+				{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartPrimSys, sharedObject},
+				manifest,
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingWrongPartType, Descriptor: 0},
+			},
+		},
+		{
+			description: "nothing",
+			descrs: []fixtureDescriptor{
+				{name: "deffile", datatype: sif.DataDeffile, data: "Bootstrap: scratch"},
+			},
+			findings: []Finding{
+				{Kind: FindingNoBinary, Descriptor: -1},
+				{Kind: FindingNoManifest, Descriptor: -1},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-fixture-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := createFixtureImage(t, dir, tc.descrs)
+			fimg, err := sif.LoadContainer(path, true)
+			if err != nil {
+				t.Fatalf("while loading image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			findings := pluginFileFindings(newSifFileImageReader(&fimg))
+			if len(findings) != len(tc.findings) {
+				t.Fatalf("got findings %v, expected %d findings", findings, len(tc.findings))
+			}
+			for i, f := range findings {
+				if f.Kind != tc.findings[i].Kind || f.Descriptor != tc.findings[i].Descriptor {
+					t.Errorf("got finding %d %q of kind %d, expected kind %d on descriptor %d", i, f, f.Kind, tc.findings[i].Kind, tc.findings[i].Descriptor)
+				}
+			}
+
+			// findings are part of the error returned by Inspect
+			_, err = Inspect(path)
+			var pfe *PluginFileError
+			if len(tc.findings) == 0 {
+				if errors.As(err, &pfe) {
+					t.Errorf("unexpected plugin file error: %s", err)
+				}
+			} else if !errors.As(err, &pfe) {
+				t.Errorf("got error %v, expected a plugin file error", err)
+			} else if !reflect.DeepEqual(pfe.Findings, findings) {
+				t.Errorf("got findings %v in error, expected %v", pfe.Findings, findings)
+			}
+		})
+	}
+}
+
+func TestContainerImageError(t *testing.T) {
+	binary := fixtureDescriptor{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartData, "object"}
+	manifest := fixtureDescriptor{name: pluginManifestName, datatype: sif.DataGenericJSON, data: `{"name":"example.com/fixture"}`}
+	deffile := fixtureDescriptor{name: "deffile", datatype: sif.DataDeffile, data: "bootstrap: library\nfrom: alpine\n"}
+	squashfs := fixtureDescriptor{"rootfs", sif.DataPartition, sif.FsSquash, sif.PartPrimSys, "squashfs"}
+	ext3 := fixtureDescriptor{"rootfs", sif.DataPartition, sif.FsExt3, sif.PartPrimSys, "ext3"}
+
+	cases := []struct {
+		description    string
+		descrs         []fixtureDescriptor
+		valid          bool
+		containerImage bool
+	}{
+		{
+			description:    "SquashfsContainer",
+			descrs:         []fixtureDescriptor{deffile, squashfs},
+			containerImage: true,
+		},
+		{
+			description:    "Ext3Container",
+			descrs:         []fixtureDescriptor{ext3, deffile},
+			containerImage: true,
+		},
+		{
+			description:    "NoDefinitionFile",
+			descrs:         []fixtureDescriptor{squashfs},
+			containerImage: false,
+		},
+		{
+			description:    "DataPartition",
+			descrs:         []fixtureDescriptor{deffile, {"rootfs", sif.DataPartition, sif.FsSquash, sif.PartData, "squashfs"}},
+			containerImage: false,
+		},
+		{
+			description:    "InvalidPlugin",
+			descrs:         []fixtureDescriptor{binary},
+			containerImage: false,
+		},
+		{
+			description: "Plugin",
+			descrs:      []fixtureDescriptor{binary, manifest},
+			valid:       true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-fixture-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := createFixtureImage(t, dir, tc.descrs)
+			fimg, err := sif.LoadContainer(path, true)
+			if err != nil {
+				t.Fatalf("while loading image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			err = checkPluginFile(newSifFileImageReader(&fimg))
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var pfe *PluginFileError
+			if !errors.As(err, &pfe) {
+				t.Fatalf("got error %v, expected a plugin file error", err)
+			}
+			if pfe.ContainerImage != tc.containerImage {
+				t.Errorf("got container image %v, expected %v", pfe.ContainerImage, tc.containerImage)
+			}
+			if hint := strings.Contains(err.Error(), "container image"); hint != tc.containerImage {
+				t.Errorf("unexpected error %q", err)
+			}
+			if len(pfe.Findings) == 0 {
+				t.Errorf("no finding reported")
+			}
+		})
+	}
+}
+
 func TestGetManifest(t *testing.T) {
 	testGoodJSON := `{"name":"test name", "author":"test author", "version":"test version", "description":"test description"}`
 	testGoodManifest := pluginapi.Manifest{
@@ -391,7 +741,7 @@ func TestGetManifest(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.description, func(t *testing.T) {
-			if actual := getManifest(tc.sif); actual != tc.expected {
+			if actual := getManifest(tc.sif); !reflect.DeepEqual(actual, tc.expected) {
 				t.Errorf("%s: getManifest retuned %#v, expected %#v",
 					tc.description,
 					actual,
@@ -0,0 +1,222 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/signing"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/openpgp"
+)
+
+// fetchPubkey is the function fetching the public keys from
+// a key server, it's also used by unit tests for mocking.
+var fetchPubkey = sypgp.FetchPubkey
+
+// SignatureStatus is the outcome of the verification of
+// a signature of a plugin image.
+type SignatureStatus int
+
+const (
+	// SignatureValid is a valid signature covering all
+	// the data objects of the plugin image.
+	SignatureValid SignatureStatus = iota
+	// SignaturePartial is a valid signature covering only
+	// some of the data objects of the plugin image.
+	SignaturePartial
+	// SignatureUnknownKey is a signature made by a key which
+	// couldn't be found, it's not verified.
+	SignatureUnknownKey
+	// SignatureInvalid is a signature which doesn't verify
+	// the data objects it covers, or a corrupted one.
+	SignatureInvalid
+)
+
+// String returns the status as displayed to users.
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureValid:
+		return "valid"
+	case SignaturePartial:
+		return "partial"
+	case SignatureUnknownKey:
+		return "unknown key"
+	}
+	return "invalid"
+}
+
+// SignatureResult is the verification result of a signature
+// of a plugin image.
+type SignatureResult struct {
+	// ID is the descriptor ID of the signature object.
+	ID uint32
+	// Fingerprint is the fingerprint of the signing key.
+	Fingerprint string
+	// Identity is the first identity of the signing key, it's
+	// empty when the key couldn't be found.
+	Identity string
+	// KeyRemote reports whether the signing key was fetched
+	// from the key server, not found in the local keyring.
+	KeyRemote bool
+	// Status is the outcome of the verification.
+	Status SignatureStatus
+	// Objects are the IDs of the data objects covered by the
+	// signature.
+	Objects []uint32
+	// Unsigned are the IDs of the data objects of the plugin
+	// image not covered by a partial signature.
+	Unsigned []uint32
+	// Detail tells why an invalid signature doesn't verify.
+	Detail string
+}
+
+// SignatureOptions are the options of the signature verification.
+type SignatureOptions struct {
+	// KeyServerURI is the key server the keys missing from the
+	// local keyring are fetched from, they are not fetched when
+	// it's empty. The fetched keys are not added to the keyring.
+	KeyServerURI string
+	// AuthToken is the key server authentication token.
+	AuthToken string
+}
+
+// VerifySignatures verifies the signatures of the plugin image sifPath
+// with the keys of the public keyring of the current user, and the keys
+// fetched from the key server of opts, and returns the result of each
+// signature. An unsigned image has no result, an error is only returned
+// when the image can't be read.
+func VerifySignatures(ctx context.Context, sifPath string, opts SignatureOptions) ([]SignatureResult, error) {
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		return nil, fmt.Errorf("could not load plugin: %w", err)
+	}
+	defer fimg.UnloadContainer()
+
+	keys, err := sypgp.NewHandle("").LoadPubKeyring()
+	if err != nil {
+		sylog.Debugf("Could not load the public keyring: %s", err)
+	}
+
+	return verifySignatures(ctx, &fimg, keys, opts), nil
+}
+
+// Signatures returns the verification result of each signature of the
+// plugin image "name", which is either the name of a plugin installed
+// under rootDir or the name of an image file corresponding to a plugin.
+// See VerifySignatures.
+func Signatures(ctx context.Context, name string, opts SignatureOptions) ([]SignatureResult, error) {
+	path, release, err := imagePath(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return VerifySignatures(ctx, path, opts)
+}
+
+// verifySignatures returns the result of the verification of each
+// signature of fimg with the keys of keyring, the keys missing from
+// keyring are fetched from the key server of opts if set.
+func verifySignatures(ctx context.Context, fimg *sif.FileImage, keyring openpgp.EntityList, opts SignatureOptions) []SignatureResult {
+	var results []SignatureResult
+
+	var objects []uint32
+	for _, d := range fimg.DescrArr {
+		if d.Used && d.Datatype != sif.DataSignature {
+			objects = append(objects, d.ID)
+		}
+	}
+
+	for i := range fimg.DescrArr {
+		sig := &fimg.DescrArr[i]
+		if !sig.Used || sig.Datatype != sif.DataSignature {
+			continue
+		}
+
+		res := SignatureResult{ID: sig.ID}
+		fingerprint, err := sig.GetEntityString()
+		if err != nil {
+			res.Status = SignatureInvalid
+			res.Detail = fmt.Sprintf("could not get the signing entity fingerprint: %s", err)
+			results = append(results, res)
+			continue
+		}
+		res.Fingerprint = fingerprint
+
+		keys := signerKeys(keyring, fingerprint)
+		if len(keys) == 0 && opts.KeyServerURI != "" {
+			el, err := fetchPubkey(ctx, http.DefaultClient, fingerprint, opts.KeyServerURI, opts.AuthToken, true)
+			if err != nil {
+				sylog.Debugf("Could not fetch key %s from %s: %s", fingerprint, opts.KeyServerURI, err)
+			} else if keys = signerKeys(el, fingerprint); len(keys) > 0 {
+				res.KeyRemote = true
+			}
+		}
+		if len(keys) > 0 {
+			res.Identity = getFirstIdentity(keys[0])
+		}
+
+		signed, err := signing.CheckSignature(fimg, sig, keys)
+		for _, o := range signed {
+			res.Objects = append(res.Objects, o.ID)
+		}
+		switch {
+		case errors.Is(err, signing.ErrUnknownSigner):
+			res.Status = SignatureUnknownKey
+		case err != nil:
+			res.Status = SignatureInvalid
+			res.Detail = err.Error()
+		default:
+			res.Status = SignatureValid
+			for _, id := range objects {
+				if !containsID(res.Objects, id) {
+					res.Unsigned = append(res.Unsigned, id)
+				}
+			}
+			if len(res.Unsigned) > 0 {
+				res.Status = SignaturePartial
+			}
+		}
+		results = append(results, res)
+	}
+
+	return results
+}
+
+// signerKeys returns the keys of el with the fingerprint.
+func signerKeys(el openpgp.EntityList, fingerprint string) openpgp.EntityList {
+	var keys openpgp.EntityList
+
+	for _, e := range el {
+		if fmt.Sprintf("%X", e.PrimaryKey.Fingerprint) == fingerprint {
+			keys = append(keys, e)
+		}
+	}
+	return keys
+}
+
+// getFirstIdentity returns the first identity of the key e.
+func getFirstIdentity(e *openpgp.Entity) string {
+	for _, i := range e.Identities {
+		return i.Name
+	}
+	return ""
+}
+
+// containsID returns if ids contains id.
+func containsID(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
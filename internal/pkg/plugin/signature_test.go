@@ -0,0 +1,230 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// signTestPlugin signs the plugin image sifPath with the key e. The data
+// object ids are signed one by one, the default group is signed when
+// no id is given.
+func signTestPlugin(t *testing.T, sifPath string, e *openpgp.Entity, ids ...uint32) {
+	fimg, err := sif.LoadContainer(sifPath, false)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	sign := func(descrs []*sif.Descriptor, groupid, link uint32) {
+		hash := sha512.New384()
+		for _, d := range descrs {
+			hash.Write(d.GetData(&fimg))
+		}
+
+		var b bytes.Buffer
+		w, err := clearsign.Encode(&b, e.PrivateKey, nil)
+		if err != nil {
+			t.Fatalf("while creating signature: %s", err)
+		}
+		fmt.Fprintf(w, "SIFHASH:\n%x", hash.Sum(nil))
+		if err := w.Close(); err != nil {
+			t.Fatalf("while creating signature: %s", err)
+		}
+
+		input := sif.DescriptorInput{
+			Datatype: sif.DataSignature,
+			Groupid:  groupid,
+			Link:     link,
+			Fname:    "part-signature",
+			Data:     b.Bytes(),
+			Size:     int64(b.Len()),
+		}
+		if err := input.SetSignExtra(sif.HashSHA384, hex.EncodeToString(e.PrimaryKey.Fingerprint[:])); err != nil {
+			t.Fatalf("while setting signature information: %s", err)
+		}
+		if err := fimg.AddObject(input); err != nil {
+			t.Fatalf("while adding signature: %s", err)
+		}
+	}
+
+	if len(ids) == 0 {
+		var descrs []*sif.Descriptor
+		for i := range fimg.DescrArr {
+			if d := &fimg.DescrArr[i]; d.Used && d.Groupid == sif.DescrDefaultGroup && d.Datatype != sif.DataSignature {
+				descrs = append(descrs, d)
+			}
+		}
+		sign(descrs, sif.DescrUnusedGroup, sif.DescrDefaultGroup)
+		return
+	}
+	for _, id := range ids {
+		d, _, err := fimg.GetFromDescrID(id)
+		if err != nil {
+			t.Fatalf("while getting descriptor %d: %s", id, err)
+		}
+		sign([]*sif.Descriptor{d}, d.Groupid, d.ID)
+	}
+}
+
+// corruptTestPlugin modifies the data of the descriptor id of
+// the plugin image sifPath.
+func corruptTestPlugin(t *testing.T, sifPath string, id uint32) {
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	d, _, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		t.Fatalf("while getting descriptor %d: %s", id, err)
+	}
+	off := d.Fileoff
+	fimg.UnloadContainer()
+
+	f, err := os.OpenFile(sifPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("while opening plugin image: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt([]byte("X"), off); err != nil {
+		t.Fatalf("while corrupting plugin image: %s", err)
+	}
+}
+
+func TestVerifySignatures(t *testing.T) {
+	known, err := openpgp.NewEntity("Known", "", "known@example.org", nil)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+	unknown, err := openpgp.NewEntity("Unknown", "", "unknown@example.org", nil)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+	fp := func(e *openpgp.Entity) string {
+		return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+	}
+
+	// the test plugin images hold the plugin
+	// object (1) and the manifest (2)
+	tests := []struct {
+		name      string
+		sign      func(t *testing.T, path string)
+		keyServer string
+		expected  []SignatureResult
+	}{
+		{
+			name:     "Unsigned",
+			sign:     func(t *testing.T, path string) {},
+			expected: nil,
+		},
+		{
+			name: "Valid",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, known)
+			},
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(known), Identity: "Known <known@example.org>", Status: SignatureValid, Objects: []uint32{1, 2}},
+			},
+		},
+		{
+			name: "UnknownKey",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, unknown)
+			},
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(unknown), Status: SignatureUnknownKey, Objects: []uint32{1, 2}},
+			},
+		},
+		{
+			name: "KeyServer",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, unknown)
+			},
+			keyServer: "https://keys.example.org",
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(unknown), Identity: "Unknown <unknown@example.org>", KeyRemote: true, Status: SignatureValid, Objects: []uint32{1, 2}},
+			},
+		},
+		{
+			name: "Multiple",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, known)
+				signTestPlugin(t, path, unknown)
+			},
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(known), Identity: "Known <known@example.org>", Status: SignatureValid, Objects: []uint32{1, 2}},
+				{ID: 4, Fingerprint: fp(unknown), Status: SignatureUnknownKey, Objects: []uint32{1, 2}},
+			},
+		},
+		{
+			name: "Partial",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, known, 1)
+			},
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(known), Identity: "Known <known@example.org>", Status: SignaturePartial, Objects: []uint32{1}, Unsigned: []uint32{2}},
+			},
+		},
+		{
+			name: "Corrupted",
+			sign: func(t *testing.T, path string) {
+				signTestPlugin(t, path, known)
+				corruptTestPlugin(t, path, 1)
+			},
+			expected: []SignatureResult{
+				{ID: 3, Fingerprint: fp(known), Identity: "Known <known@example.org>", Status: SignatureInvalid, Objects: []uint32{1, 2}, Detail: "hash differs, data may be corrupted"},
+			},
+		},
+	}
+
+	origFetchPubkey := fetchPubkey
+	defer func() { fetchPubkey = origFetchPubkey }()
+	fetchPubkey = func(ctx context.Context, c *http.Client, fingerprint, uri, token string, noPrompt bool) (openpgp.EntityList, error) {
+		if fingerprint == fp(unknown) {
+			return openpgp.EntityList{unknown}, nil
+		}
+		return nil, fmt.Errorf("no matching keys found for fingerprint")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-signature-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/signed"})
+			tt.sign(t, sifPath)
+
+			fimg, err := sif.LoadContainer(sifPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			opts := SignatureOptions{KeyServerURI: tt.keyServer}
+			results := verifySignatures(context.Background(), &fimg, openpgp.EntityList{known}, opts)
+			if !reflect.DeepEqual(results, tt.expected) {
+				t.Errorf("got results %+v, expected %+v", results, tt.expected)
+			}
+		})
+	}
+}
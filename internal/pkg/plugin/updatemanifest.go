@@ -0,0 +1,196 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// UpdateManifest replaces the manifest of the plugin image path with
+// the JSON manifest data, or adds it if the image has none, and writes
+// the result to outPath, or updates path when outPath is empty. The
+// manifest is validated as it would be at installation, against the
+// binary objects of the image which are left untouched. The signatures
+// of the image, invalidated by the new manifest, are removed when
+// stripSignatures is true and kept with a warning otherwise.
+func UpdateManifest(path string, data []byte, outPath string, stripSignatures bool) error {
+	if outPath == "" {
+		outPath = path
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return fmt.Errorf("could not load plugin: %w", err)
+	}
+	err = checkNewManifest(&fimg, data)
+	fimg.UnloadContainer()
+	if err != nil {
+		return err
+	}
+
+	// the image is updated in a copy renamed over outPath
+	// once complete, so path is never left half updated
+	tmp, err := ioutil.TempFile(filepath.Dir(outPath), "."+filepath.Base(outPath)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = copyImage(tmp, path)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("while copying plugin image %s: %s", path, err)
+	}
+
+	if err := replaceManifest(tmp.Name(), data, stripSignatures); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outPath)
+}
+
+// checkNewManifest validates the manifest data against the binary
+// objects of the plugin image fimg.
+func checkNewManifest(fimg *sif.FileImage, data []byte) error {
+	manifest, violations := validateManifest(data, false)
+	if len(violations) > 0 {
+		return fmt.Errorf("invalid plugin manifest: %w", &ManifestError{Violations: violations})
+	}
+
+	// the primary binary may be renamed by the new manifest
+	var binaries []pluginBinary
+	var archs []string
+	for _, b := range pluginBinaries(fimg) {
+		if b.name != primaryBinaryName(manifest) {
+			continue
+		}
+		binaries = append(binaries, b)
+		if !containsString(archs, b.arch) {
+			archs = append(archs, b.arch)
+		}
+	}
+	if len(binaries) == 0 {
+		return fmt.Errorf("no plugin binary named %q found", primaryBinaryName(manifest))
+	}
+	if err := checkArchitectures(manifest, archs, true); err != nil {
+		return fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+
+	return checkManifestCompression(fimg, manifest, binaries)
+}
+
+// checkManifestCompression returns an error if the binary compression
+// declared by manifest doesn't match the compression of the binaries,
+// the manifest describing the binaries couldn't install them otherwise.
+func checkManifestCompression(fimg *sif.FileImage, manifest pluginapi.Manifest, binaries []pluginBinary) error {
+	for _, b := range binaries {
+		magic := make([]byte, len(zstdMagic))
+		n, _ := io.ReadFull(objectReader(fimg, b.descr), magic)
+
+		compression := detectCompression(magic[:n])
+		if compression != manifest.BinaryCompression {
+			if compression == "" {
+				return fmt.Errorf("manifest declares %s compression but the %s plugin binary is not compressed", manifest.BinaryCompression, b.arch)
+			}
+			return fmt.Errorf("the %s plugin binary is %s compressed but the manifest doesn't declare it", b.arch, compression)
+		}
+		if _, ok := manifest.BinarySizes[b.arch]; compression != "" && !ok {
+			return fmt.Errorf("no binary size declared in the manifest for the compressed %s plugin binary", b.arch)
+		}
+	}
+	return nil
+}
+
+// copyImage copies the plugin image path to w.
+func copyImage(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// replaceManifest replaces the manifest of the plugin image path with
+// data and removes its signatures if stripSignatures is true.
+func replaceManifest(path string, data []byte, stripSignatures bool) error {
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return fmt.Errorf("could not load plugin: %w", err)
+	}
+	defer fimg.UnloadContainer()
+
+	var signatures []uint32
+	for _, d := range fimg.DescrArr {
+		if d.Used && d.Datatype == sif.DataSignature {
+			signatures = append(signatures, d.ID)
+		}
+	}
+	if stripSignatures {
+		// deleted from the last one, objects at the end
+		// of the image are removed from the file
+		for i := len(signatures) - 1; i >= 0; i-- {
+			if err := deleteObject(&fimg, signatures[i]); err != nil {
+				return fmt.Errorf("while removing signature %d: %s", signatures[i], err)
+			}
+		}
+	} else if len(signatures) > 0 {
+		sylog.Warningf("The %d signature(s) of the plugin image are invalidated by the new manifest, the image must be signed again", len(signatures))
+	}
+
+	if n := findDescriptor(newSifFileImageReader(&fimg), pluginManifestName); n >= 0 {
+		if err := deleteObject(&fimg, fimg.DescrArr[n].ID); err != nil {
+			return fmt.Errorf("while removing plugin manifest: %s", err)
+		}
+	}
+
+	err = fimg.AddObject(sif.DescriptorInput{
+		Datatype: sif.DataGenericJSON,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    pluginManifestName,
+		Data:     data,
+		Size:     int64(len(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("while adding plugin manifest: %s", err)
+	}
+	return nil
+}
+
+// deleteObject removes the data object id from fimg. DeleteObject only
+// frees the descriptor in the file, it's freed in fimg as well so it's
+// not written back by the next AddObject.
+func deleteObject(fimg *sif.FileImage, id uint32) error {
+	_, index, err := fimg.GetFromDescrID(id)
+	if err != nil {
+		return err
+	}
+	if err := fimg.DeleteObject(id, 0); err != nil {
+		return err
+	}
+	fimg.DescrArr[index] = sif.Descriptor{}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestUpdateManifest(t *testing.T) {
+	signer, err := openpgp.NewEntity("Signer", "", "signer@example.org", nil)
+	if err != nil {
+		t.Fatalf("while creating key: %s", err)
+	}
+
+	otherArch := "s390x"
+	if runtime.GOARCH == otherArch {
+		otherArch = "amd64"
+	}
+
+	var compressed bytes.Buffer
+	if err := CompressBinary(&compressed, bytes.NewReader([]byte("plugin object")), CompressionZstd); err != nil {
+		t.Fatalf("while compressing plugin object: %s", err)
+	}
+	compressedManifest := pluginapi.Manifest{
+		Name:              "example.com/update",
+		BinaryCompression: CompressionZstd,
+		BinarySizes:       map[string]int64{runtime.GOARCH: int64(len("plugin object"))},
+	}
+
+	original := pluginapi.Manifest{Name: "example.com/update", Version: "1.0.0"}
+
+	tests := []struct {
+		name        string
+		create      func(t *testing.T, dir string) string
+		manifest    string
+		out         bool
+		strip       bool
+		signatures  int
+		expectError bool
+	}{
+		{
+			name: "InPlace",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest: `{"name": "example.com/update", "version": "1.0.1", "description": "Fixed"}`,
+		},
+		{
+			name: "Out",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest: `{"name": "example.com/update", "version": "1.0.1", "description": "Fixed"}`,
+			out:      true,
+		},
+		{
+			name: "Inject",
+			create: func(t *testing.T, dir string) string {
+				return createFixtureImage(t, dir, []fixtureDescriptor{
+					{pluginBinaryName, sif.DataPartition, sif.FsRaw, sif.PartData, "dummy plugin object for " + runtime.GOARCH},
+				})
+			},
+			manifest: `{"name": "example.com/update", "version": "1.0.1"}`,
+		},
+		{
+			name: "SignedKept",
+			create: func(t *testing.T, dir string) string {
+				path := createTestPlugin(t, dir, original)
+				signTestPlugin(t, path, signer)
+				return path
+			},
+			manifest:   `{"name": "example.com/update", "version": "1.0.1"}`,
+			signatures: 1,
+		},
+		{
+			name: "SignedStripped",
+			create: func(t *testing.T, dir string) string {
+				path := createTestPlugin(t, dir, original)
+				signTestPlugin(t, path, signer)
+				return path
+			},
+			manifest: `{"name": "example.com/update", "version": "1.0.1"}`,
+			strip:    true,
+		},
+		{
+			name: "Compressed",
+			create: func(t *testing.T, dir string) string {
+				return createObjectTestPlugin(t, dir, compressedManifest, compressed.Bytes())
+			},
+			manifest: `{"name": "example.com/update", "version": "1.0.1", "binaryCompression": "zstd", "binarySizes": {"` + runtime.GOARCH + `": 13}}`,
+		},
+		{
+			name: "CompressionMissing",
+			create: func(t *testing.T, dir string) string {
+				return createObjectTestPlugin(t, dir, compressedManifest, compressed.Bytes())
+			},
+			manifest:    `{"name": "example.com/update", "version": "1.0.1"}`,
+			expectError: true,
+		},
+		{
+			name: "CompressionUndeclared",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest:    `{"name": "example.com/update", "binaryCompression": "gzip", "binarySizes": {"` + runtime.GOARCH + `": 1}}`,
+			expectError: true,
+		},
+		{
+			name: "Architecture",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest:    `{"name": "example.com/update", "architectures": ["` + otherArch + `"]}`,
+			expectError: true,
+		},
+		{
+			name: "PrimaryBinary",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest:    `{"name": "example.com/update", "primaryBinary": "missing.so"}`,
+			expectError: true,
+		},
+		{
+			name: "Invalid",
+			create: func(t *testing.T, dir string) string {
+				return createTestPlugin(t, dir, original)
+			},
+			manifest:    `{"name": "example.com/update", "unknown": true}`,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "plugin-manifest-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := tt.create(t, dir)
+			before, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("while reading plugin image: %s", err)
+			}
+
+			outPath := ""
+			resultPath := path
+			if tt.out {
+				outPath = filepath.Join(dir, "out.sif")
+				resultPath = outPath
+			}
+
+			err = UpdateManifest(path, []byte(tt.manifest), outPath, tt.strip)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+
+			if tt.out || tt.expectError {
+				after, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatalf("while reading plugin image: %s", err)
+				}
+				if !bytes.Equal(before, after) {
+					t.Errorf("plugin image %s was modified", path)
+				}
+			}
+			if tt.expectError {
+				if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+					t.Errorf("unexpected temporary files left in %s", dir)
+				}
+				return
+			}
+
+			fimg, err := sif.LoadContainer(resultPath, true)
+			if err != nil {
+				t.Fatalf("while loading plugin image: %s", err)
+			}
+			defer fimg.UnloadContainer()
+
+			r := newSifFileImageReader(&fimg)
+			if n := findDescriptor(r, pluginManifestName); n < 0 || string(r.GetData(n)) != tt.manifest {
+				t.Errorf("manifest of the plugin image wasn't replaced")
+			}
+			manifest, err := Inspect(resultPath)
+			if err != nil {
+				t.Fatalf("while inspecting plugin image: %s", err)
+			}
+			if manifest.Name != "example.com/update" || manifest.Version != "1.0.1" {
+				t.Errorf("got manifest %s %s, expected example.com/update 1.0.1", manifest.Name, manifest.Version)
+			}
+
+			var b bytes.Buffer
+			if err := extractBinary(&b, &fimg); err != nil {
+				t.Fatalf("while extracting plugin binary: %s", err)
+			}
+			if s := b.String(); s != "dummy plugin object for "+runtime.GOARCH && s != "plugin object" {
+				t.Errorf("got plugin binary %q", s)
+			}
+
+			results := verifySignatures(context.Background(), &fimg, openpgp.EntityList{signer}, SignatureOptions{})
+			if len(results) != tt.signatures {
+				t.Fatalf("got %d signatures, expected %d", len(results), tt.signatures)
+			}
+			for _, res := range results {
+				if res.Status == SignatureValid {
+					t.Errorf("unexpected valid signature after manifest update")
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateManifestError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-manifest-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.com/update"})
+
+	err = UpdateManifest(path, []byte(`{"name": "example.com/../update"}`), "", false)
+
+	var merr *ManifestError
+	if !errors.As(err, &merr) {
+		t.Errorf("got error %v, expected a manifest error", err)
+	}
+}
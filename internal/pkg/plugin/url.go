@@ -0,0 +1,87 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxDisplayURLLength is the number of characters of a URL
+	// displayed, longer URLs are truncated for display only.
+	maxDisplayURLLength = 256
+	// invalidURL is displayed in place of an invalid URL.
+	invalidURL = "(invalid URL)"
+)
+
+// ansiEscapeRegexp matches the ANSI CSI and OSC escape sequences.
+var ansiEscapeRegexp = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)?)`)
+
+// checkURL returns an error if s is not an absolute http(s) URL or
+// contains control or invisible formatting characters. It's used both
+// to validate the manifests and before displaying a URL so that they
+// always agree.
+func checkURL(s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("%q is not valid UTF-8", s)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return fmt.Errorf("%q contains control characters", s)
+		}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q is not an http or https URL", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", s)
+	}
+	return nil
+}
+
+// sanitizeDisplay returns s without ANSI escape sequences, control and
+// invisible formatting characters so that it can't drive the terminal
+// it's displayed on.
+func sanitizeDisplay(s string) string {
+	s = strings.ToValidUTF8(ansiEscapeRegexp.ReplaceAllString(s, ""), "�")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// DisplayURL returns the URL s, coming from a plugin manifest, ready to
+// be displayed in a terminal. A URL rejected by the manifest validation
+// is displayed as "(invalid URL)", unless raw is true in which case its
+// value is displayed stripped of control characters and escape
+// sequences. URLs longer than 256 characters are truncated. The
+// manifest and the installation metadata keep the URL intact.
+func DisplayURL(s string, raw bool) string {
+	if s == "" {
+		return ""
+	}
+	if checkURL(s) != nil {
+		if !raw {
+			return invalidURL
+		}
+		s = sanitizeDisplay(s)
+	}
+	if utf8.RuneCountInString(s) > maxDisplayURLLength {
+		s = string([]rune(s)[:maxDisplayURLLength-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectError bool
+	}{
+		{"HTTPS", "https://example.com/plugin", false},
+		{"HTTP", "http://example.com/plugin?tab=readme#install", false},
+		{"FTP", "ftp://example.com/plugin", true},
+		{"Javascript", "javascript:alert(1)", true},
+		{"Relative", "example.com/plugin", true},
+		{"NoHost", "https:///plugin", true},
+		{"ANSIEscape", "https://example.com/\x1b[2J", true},
+		{"C1Control", "https://example.com/\u009b2J", true},
+		{"BidiOverride", "https://example.com/‮gnp.exe", true},
+		{"InvalidUTF8", "https://example.com/\xff", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkURL(tt.url)
+			if err != nil && !tt.expectError {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", 300)
+
+	tests := []struct {
+		name     string
+		url      string
+		raw      bool
+		expected string
+	}{
+		{"Empty", "", false, ""},
+		{"Valid", "https://example.com/plugin", false, "https://example.com/plugin"},
+		{"ValidRaw", "https://example.com/plugin", true, "https://example.com/plugin"},
+		{"Invalid", "ftp://example.com/plugin", false, invalidURL},
+		{"InvalidRaw", "ftp://example.com/plugin", true, "ftp://example.com/plugin"},
+		{"Escape", "https://example.com/\x1b[31mred\x1b[0m", false, invalidURL},
+		{"EscapeRaw", "https://example.com/\x1b[31mred\x1b[0m\x1b]0;title\x07", true, "https://example.com/red"},
+		{"ControlRaw", "https://example.com/\r\nfake‮", true, "https://example.com/fake"},
+		{"Long", long, false, long[:maxDisplayURLLength-3] + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DisplayURL(tt.url, tt.raw); got != tt.expected {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
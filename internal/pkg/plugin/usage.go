@@ -0,0 +1,108 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// PluginUsage reports the disk space in bytes used by an
+// installed plugin.
+type PluginUsage struct {
+	// Name is the name of the plugin.
+	Name string
+	// Image is the size of the plugin SIF image.
+	Image int64
+	// Binary is the size of the extracted plugin object.
+	Binary int64
+	// Config is the size of the plugin configuration file and
+	// of its default.
+	Config int64
+	// Meta is the size of the plugin meta file.
+	Meta int64
+	// Data is the size of any other file stored by the plugin
+	// in its directory.
+	Data int64
+	// Total is the sum of all the above.
+	Total int64
+}
+
+// Usage returns the disk space used by each plugin installed in
+// rootDir. Missing files are reported with a zero size.
+func Usage() ([]PluginUsage, error) {
+	metas, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make([]PluginUsage, 0, len(metas))
+	for _, m := range metas {
+		usages = append(usages, m.usage())
+	}
+
+	return usages, nil
+}
+
+// TotalUsage returns the disk space used by all the plugins
+// reported in usages.
+func TotalUsage(usages []PluginUsage) int64 {
+	var total int64
+	for _, u := range usages {
+		total += u.Total
+	}
+	return total
+}
+
+func (m *Meta) usage() PluginUsage {
+	u := PluginUsage{
+		Name:   m.Name,
+		Image:  fileSize(m.storedImageName()),
+		Binary: fileSize(m.binaryName()),
+		Config: fileSize(m.configName()) + fileSize(m.configDefaultName()),
+		Meta:   fileSize(m.metaName()),
+	}
+
+	known := map[string]bool{
+		m.storedImageName():   true,
+		m.binaryName():        true,
+		m.configName():        true,
+		m.configDefaultName(): true,
+		metaPath(m.Name):      true,
+	}
+
+	err := filepath.Walk(m.path(), func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() && !known[path] {
+			u.Data += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		sylog.Debugf("While computing disk usage of plugin %q: %s", m.Name, err)
+	}
+
+	u.Total = u.Image + u.Binary + u.Config + u.Meta + u.Data
+
+	return u
+}
+
+// fileSize returns the size of the file at path, or zero
+// if it can't be determined.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
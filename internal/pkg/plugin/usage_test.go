@@ -0,0 +1,81 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestUsage(t *testing.T) {
+	defer setTestRootDir(t)()
+
+	dir, err := ioutil.TempDir("", "plugin-usage-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sifPath := createTestPlugin(t, dir, pluginapi.Manifest{Name: "example.org/test"})
+	installTestPlugin(t, sifPath, "example.org/test", true)
+	installTestPlugin(t, sifPath, "example.org/other", true)
+
+	m, err := Lookup("example.org/test")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(m.path(), "data"), make([]byte, 100), 0644); err != nil {
+		t.Fatalf("while writing plugin data: %s", err)
+	}
+
+	// missing files are reported with a zero size
+	other, err := Lookup("example.org/other")
+	if err != nil {
+		t.Fatalf("while looking up plugin: %s", err)
+	}
+	if err := os.Remove(other.binaryName()); err != nil {
+		t.Fatalf("while removing plugin object: %s", err)
+	}
+
+	usages, err := Usage()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("got %d plugin usages, expected 2", len(usages))
+	}
+
+	for _, u := range usages {
+		if u.Image == 0 || u.Meta == 0 || u.Config == 0 {
+			t.Errorf("%s: unexpected zero image, meta or config size", u.Name)
+		}
+		if u.Total != u.Image+u.Binary+u.Config+u.Meta+u.Data {
+			t.Errorf("%s: inconsistent total %d", u.Name, u.Total)
+		}
+
+		switch u.Name {
+		case "example.org/test":
+			if u.Data != 100 {
+				t.Errorf("%s: got data size %d, expected 100", u.Name, u.Data)
+			}
+			if u.Binary == 0 {
+				t.Errorf("%s: unexpected zero binary size", u.Name)
+			}
+		case "example.org/other":
+			if u.Data != 0 || u.Binary != 0 {
+				t.Errorf("%s: got data/binary size %d/%d, expected 0/0", u.Name, u.Data, u.Binary)
+			}
+		}
+	}
+
+	if total := TotalUsage(usages); total != usages[0].Total+usages[1].Total {
+		t.Errorf("unexpected total usage %d", total)
+	}
+}
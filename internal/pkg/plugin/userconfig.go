@@ -0,0 +1,114 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/syfs"
+)
+
+// userConfigDir returns the configuration directory of the current
+// user, the tests replace it.
+var userConfigDir = syfs.ConfigDir
+
+// userConfigName returns the path of the user configuration overlay of
+// the plugin: ~/.singularity/plugins/<name>/config.yaml, or the name of
+// the configuration file in the format of the plugin.
+func (m *Meta) userConfigName() string {
+	return filepath.Join(userConfigDir(), "plugins", m.Name, configFileName(m.ConfigFormat))
+}
+
+// userConfig returns the user configuration overlay of the plugin,
+// decoded by c, to be merged over the system configuration, and the
+// path of the overlay file. Only the keys of the options marked as
+// overridable by the configuration schema are kept, the other keys are
+// ignored with a warning. An invalid overlay is ignored as a whole with
+// a warning, so it never prevents the plugin from loading the system
+// configuration. A nil root is returned when the user has no overlay and
+// in privileged processes, which ignore it.
+func (m *Meta) userConfig(c configCodec) (map[interface{}]interface{}, string) {
+	path := m.userConfigName()
+	if isPrivileged() {
+		sylog.Debugf("Ignoring user configuration %s of plugin %q in a privileged process", path, m.Name)
+		return nil, path
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, path
+	} else if err != nil {
+		sylog.Warningf("Ignoring user configuration of plugin %q: %s", m.Name, err)
+		return nil, path
+	}
+
+	root, err := c.decode(data)
+	if err != nil {
+		sylog.Warningf("Ignoring invalid user configuration %s of plugin %q: %s", path, m.Name, configSyntaxError(err))
+		return nil, path
+	}
+
+	s := newConfigSchema(m.ConfigSchema)
+	entries := s.entries(root)
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	overlay := make(map[interface{}]interface{})
+	var ignored []string
+	for _, k := range keys {
+		v := entries[k]
+		o, ok := s.options[k]
+		if !ok || !o.Overridable {
+			ignored = append(ignored, fmt.Sprintf("%q", k))
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		if err := checkConfigValue(o.Type, v); err != nil {
+			sylog.Warningf("Ignoring invalid user configuration %s of plugin %q: key %q: %s", path, m.Name, k, err)
+			return nil, path
+		}
+		if err := setConfigNode(overlay, strings.Split(k, "."), v); err != nil {
+			sylog.Warningf("Ignoring invalid user configuration %s of plugin %q: key %q: %s", path, m.Name, k, err)
+			return nil, path
+		}
+	}
+	if len(ignored) > 0 {
+		sylog.Warningf("Ignoring keys %s of the user configuration %s of plugin %q, they are not user overridable",
+			strings.Join(ignored, ", "), path, m.Name)
+	}
+
+	return overlay, path
+}
+
+// GetUserConfig returns the values set by the user configuration overlay
+// of the installed plugin "name" by dotted key, as they are merged over
+// the system configuration when the plugin loads it, along with the path
+// of the overlay file. There are none in privileged processes.
+func GetUserConfig(name string) (map[string]interface{}, string, error) {
+	meta, err := loadMetaByName(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, err := meta.configCodec()
+	if err != nil {
+		return nil, "", err
+	}
+
+	overlay, path := meta.userConfig(c)
+	return newConfigSchema(meta.ConfigSchema).entries(overlay), path, nil
+}
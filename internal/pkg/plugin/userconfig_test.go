@@ -0,0 +1,139 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestUserConfig(t *testing.T) {
+	defer setTestRootDir(t)()
+	defer func(orig func() bool) { isPrivileged = orig }(isPrivileged)
+	isPrivileged = func() bool { return false }
+
+	m := installTestMeta(t, "example.org/test")
+	m.ConfigSchema = []pluginapi.ConfigOption{
+		{Key: "server.port", Type: pluginapi.ConfigTypeInt, Overridable: true},
+		{Key: "server.host", Type: pluginapi.ConfigTypeString},
+		{Key: "log-level", Type: pluginapi.ConfigTypeString, Overridable: true},
+	}
+	if err := m.installMeta(); err != nil {
+		t.Fatalf("while installing meta: %s", err)
+	}
+	if err := ioutil.WriteFile(m.configName(), []byte("log-level: info\nserver:\n  host: file.example.org\n  port: 80\n"), 0644); err != nil {
+		t.Fatalf("while writing configuration: %s", err)
+	}
+
+	userConfig := m.userConfigName()
+	if userConfig != filepath.Join(userConfigDir(), "plugins", "example.org/test", "config.yaml") {
+		t.Errorf("unexpected user configuration path %s", userConfig)
+	}
+	if err := os.MkdirAll(filepath.Dir(userConfig), 0755); err != nil {
+		t.Fatalf("while creating user configuration directory: %s", err)
+	}
+
+	system := testConfig{LogLevel: "info", Server: testServerConfig{Host: "file.example.org", Port: 80}}
+
+	tests := []struct {
+		name       string
+		overlay    string
+		privileged bool
+		expected   testConfig
+		user       map[string]interface{}
+	}{
+		{
+			name:     "None",
+			expected: system,
+			user:     map[string]interface{}{},
+		},
+		{
+			name:     "Overlay",
+			overlay:  "log-level: debug\nserver:\n  port: 8080\n",
+			expected: testConfig{LogLevel: "debug", Server: testServerConfig{Host: "file.example.org", Port: 8080}},
+			user:     map[string]interface{}{"log-level": "debug", "server.port": 8080},
+		},
+		{
+			name:       "Privileged",
+			overlay:    "log-level: debug\nserver:\n  port: 8080\n",
+			privileged: true,
+			expected:   system,
+			user:       map[string]interface{}{},
+		},
+		{
+			name:     "NotOverridable",
+			overlay:  "server:\n  host: user.example.org\n  port: 8080\nunknown: 1\n",
+			expected: testConfig{LogLevel: "info", Server: testServerConfig{Host: "file.example.org", Port: 8080}},
+			user:     map[string]interface{}{"server.port": 8080},
+		},
+		{
+			name:     "InvalidValue",
+			overlay:  "log-level: debug\nserver:\n  port: http\n",
+			expected: system,
+			user:     map[string]interface{}{},
+		},
+		{
+			name:     "InvalidSyntax",
+			overlay:  "server: [\n",
+			expected: system,
+			user:     map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isPrivileged = func() bool { return tt.privileged }
+
+			os.Remove(userConfig)
+			if tt.overlay != "" {
+				if err := ioutil.WriteFile(userConfig, []byte(tt.overlay), 0644); err != nil {
+					t.Fatalf("while writing user configuration: %s", err)
+				}
+			}
+
+			var c testConfig
+			if err := m.LoadConfig(&c); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(c, tt.expected) {
+				t.Errorf("got configuration %+v, expected %+v", c, tt.expected)
+			}
+
+			user, path, err := GetUserConfig(m.Name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if path != userConfig {
+				t.Errorf("got path %s, expected %s", path, userConfig)
+			}
+			if !reflect.DeepEqual(user, tt.user) {
+				t.Errorf("got user values %v, expected %v", user, tt.user)
+			}
+
+			// the system values are left untouched
+			values, err := GetConfig(m.Name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if values["log-level"] != "info" || values["server.port"] != 80 {
+				t.Errorf("unexpected system values %v", values)
+			}
+
+			effective, err := GetEffectiveConfig(m.Name, func(string) (string, bool) { return "", false })
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if effective["server.port"] != tt.expected.Server.Port || effective["log-level"] != tt.expected.LogLevel {
+				t.Errorf("unexpected effective values %v", effective)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/buildcfg"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// maxManifestSize is the maximum size of the manifest object.
+	maxManifestSize = 64 * 1024
+	// maxManifestDepth is the maximum nesting of the manifest JSON,
+	// the manifest itself only uses 3 levels.
+	maxManifestDepth = 8
+	// maxDependencies is the maximum number of plugin dependencies.
+	maxDependencies = 64
+	// maxArchitectures is the maximum number of architectures.
+	maxArchitectures = 32
+	// maxCapabilities is the maximum number of capabilities.
+	maxCapabilities = 32
+	// maxHooks is the maximum number of declared hooks.
+	maxHooks = 32
+
+	// unknownFieldsAllow is the "plugin manifest unknown fields"
+	// directive value tolerating unknown fields.
+	unknownFieldsAllow = "allow"
+)
+
+// maxFieldLength is the maximum length of the manifest string fields.
+var maxFieldLength = map[string]int{
+	"name":            256,
+	"author":          256,
+	"version":         64,
+	"description":     4096,
+	"license":         256,
+	"homepage":        2048,
+	"repository":      2048,
+	"maintainerEmail": 254,
+	"deprecated":      1024,
+	"replacedBy":      2048,
+	"primaryBinary":   sif.DescrNameLen - 1,
+}
+
+// pluginNameElemRegexp matches the slash separated elements of a plugin name.
+var pluginNameElemRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._~+-]*$`)
+
+// ManifestError reports all the violations found while validating
+// a plugin manifest.
+type ManifestError struct {
+	Violations []string
+}
+
+func (e *ManifestError) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+// allowUnknownFields returns whether manifest fields unknown to
+// this version are tolerated according to singularity.conf.
+func allowUnknownFields() bool {
+	c, err := singularityconf.Parse(buildcfg.SINGULARITY_CONF_FILE)
+	if err != nil {
+		sylog.Debugf("Could not parse %s, denying unknown plugin manifest fields: %s", buildcfg.SINGULARITY_CONF_FILE, err)
+		return false
+	}
+	return c.PluginUnknownFields == unknownFieldsAllow
+}
+
+// readManifest extracts and validates the manifest of the plugin image
+// fimg, see validateManifest.
+func readManifest(fimg sifReader, allowUnknown bool) (pluginapi.Manifest, []string) {
+	n := findDescriptor(fimg, pluginManifestName)
+	if fimg.Descriptors() < 2 || n < 0 {
+		return pluginapi.Manifest{}, []string{"missing manifest"}
+	}
+	return validateManifest(fimg.GetData(n), allowUnknown)
+}
+
+// validateManifest decodes the manifest data and returns it along with
+// all the violations found: malformed JSON, duplicate keys, excessive
+// nesting, unknown fields unless allowUnknown is true, and field values
+// which are too long or malformed. The manifest returned holds the
+// fields which could be decoded, it must not be trusted when there
+// are violations.
+func validateManifest(data []byte, allowUnknown bool) (pluginapi.Manifest, []string) {
+	var manifest pluginapi.Manifest
+
+	if len(data) > maxManifestSize {
+		return manifest, []string{fmt.Sprintf("manifest size of %d bytes exceeds %d bytes", len(data), maxManifestSize)}
+	}
+
+	violations, err := checkManifestJSON(data)
+	if err != nil {
+		return manifest, append(violations, err.Error())
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if !allowUnknown {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&manifest); err != nil {
+		violations = append(violations, strings.TrimPrefix(err.Error(), "json: "))
+
+		// decode again leniently to check the known fields, type
+		// mismatches are reported by both decodings
+		manifest = pluginapi.Manifest{}
+		if lerr := json.Unmarshal(data, &manifest); lerr != nil && lerr.Error() != err.Error() {
+			violations = append(violations, strings.TrimPrefix(lerr.Error(), "json: "))
+		}
+	}
+
+	return manifest, append(violations, checkManifestFields(manifest)...)
+}
+
+// jsonLevel is an object or array being walked by checkManifestJSON.
+type jsonLevel struct {
+	path      string
+	object    bool
+	keys      map[string]bool
+	expectKey bool
+	key       string
+	index     int
+}
+
+// child returns the path of the value currently walked in l.
+func (l *jsonLevel) child() string {
+	if !l.object {
+		return fmt.Sprintf("%s[%d]", l.path, l.index)
+	}
+	if l.path == "" {
+		return l.key
+	}
+	return l.path + "." + l.key
+}
+
+// done records the end of the value currently walked in l.
+func (l *jsonLevel) done() {
+	if l.object {
+		l.expectKey = true
+	} else {
+		l.index++
+	}
+}
+
+// checkManifestJSON walks the JSON tokens of data without decoding it
+// and returns the duplicate keys found, an error is returned when data
+// is not a single JSON object or is nested too deeply.
+func checkManifestJSON(data []byte) ([]string, error) {
+	var violations []string
+	var stack []*jsonLevel
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if i == 0 {
+				return violations, fmt.Errorf("empty manifest")
+			} else if len(stack) > 0 {
+				return violations, fmt.Errorf("malformed manifest: unexpected end of JSON input")
+			}
+			return violations, nil
+		} else if err != nil {
+			return violations, fmt.Errorf("malformed manifest: %s", err)
+		}
+
+		if i == 0 && tok != json.Delim('{') {
+			return violations, fmt.Errorf("manifest is not a JSON object")
+		} else if i > 0 && len(stack) == 0 {
+			return violations, fmt.Errorf("unexpected data after the manifest object")
+		}
+
+		var top *jsonLevel
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if top != nil && top.expectKey && tok != json.Delim('}') {
+			// the decoder guarantees object keys are strings, they
+			// are compared case-insensitively like when decoding
+			key := tok.(string)
+			top.key = key
+			top.expectKey = false
+			if top.keys[strings.ToLower(key)] {
+				violations = append(violations, fmt.Sprintf("duplicate field %q", top.child()))
+			}
+			top.keys[strings.ToLower(key)] = true
+			continue
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if len(stack) == maxManifestDepth {
+				return violations, fmt.Errorf("manifest nesting exceeds %d levels", maxManifestDepth)
+			}
+			l := &jsonLevel{object: tok == json.Delim('{')}
+			if top != nil {
+				l.path = top.child()
+			}
+			if l.object {
+				l.keys = make(map[string]bool)
+				l.expectKey = true
+			}
+			stack = append(stack, l)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].done()
+			}
+		default:
+			top.done()
+		}
+	}
+}
+
+// checkString returns a violation if the value of the manifest field
+// name is too long or contains control characters, new lines and
+// tabulations being allowed when multiline is true.
+func checkString(name, value string, multiline bool) []string {
+	if max := maxFieldLength[name]; len(value) > max {
+		return []string{fmt.Sprintf("%s length of %d bytes exceeds %d bytes", name, len(value), max)}
+	}
+	for _, r := range value {
+		if multiline && (r == '\n' || r == '\t') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return []string{fmt.Sprintf("%s contains control characters", name)}
+		}
+	}
+	return nil
+}
+
+// checkPluginName returns an error if name is not a valid plugin name,
+// a slash separated list of elements using only letters, digits and
+// the characters ._~+- which don't start with a dot or a dash.
+func checkPluginName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if normalizeName(name) != name {
+		return fmt.Errorf("name %q has surrounding spaces or empty elements", name)
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if !pluginNameElemRegexp.MatchString(elem) {
+			return fmt.Errorf("name %q has an invalid element %q", name, elem)
+		}
+	}
+	return nil
+}
+
+// checkManifestFields returns the violations found in the field
+// values of manifest.
+func checkManifestFields(manifest pluginapi.Manifest) []string {
+	var violations []string
+
+	strs := []struct {
+		name      string
+		value     string
+		multiline bool
+	}{
+		{"name", manifest.Name, false},
+		{"author", manifest.Author, false},
+		{"version", manifest.Version, false},
+		{"description", manifest.Description, true},
+		{"license", manifest.License, false},
+		{"homepage", manifest.Homepage, false},
+		{"repository", manifest.Repository, false},
+		{"maintainerEmail", manifest.MaintainerEmail, false},
+		{"deprecated", deprecationMessage(manifest.Deprecated), false},
+		{"replacedBy", manifest.ReplacedBy, false},
+		{"primaryBinary", manifest.PrimaryBinary, false},
+	}
+	for _, s := range strs {
+		violations = append(violations, checkString(s.name, s.value, s.multiline)...)
+	}
+	if len(violations) > 0 {
+		// the remaining checks would only repeat these violations
+		return violations
+	}
+
+	if err := checkPluginName(manifest.Name); err != nil {
+		violations = append(violations, err.Error())
+	}
+
+	if len(manifest.Dependencies) > maxDependencies {
+		violations = append(violations, fmt.Sprintf("%d dependencies exceed the maximum of %d", len(manifest.Dependencies), maxDependencies))
+	} else {
+		for i, dep := range manifest.Dependencies {
+			if err := checkPluginName(dep.Name); err != nil {
+				violations = append(violations, fmt.Sprintf("dependency %d: %s", i, err))
+			}
+			if len(dep.Version) > maxFieldLength["version"] {
+				violations = append(violations, fmt.Sprintf("dependency %d: version length of %d bytes exceeds %d bytes", i, len(dep.Version), maxFieldLength["version"]))
+			} else if _, err := parseConstraint(dep.Version); err != nil {
+				violations = append(violations, fmt.Sprintf("dependency %d: %s", i, err))
+			}
+		}
+	}
+
+	if len(manifest.Architectures) > maxArchitectures {
+		violations = append(violations, fmt.Sprintf("%d architectures exceed the maximum of %d", len(manifest.Architectures), maxArchitectures))
+	} else {
+		for _, arch := range manifest.Architectures {
+			if sif.GetSIFArch(arch) == sif.HdrArchUnknown {
+				violations = append(violations, fmt.Sprintf("unknown architecture %q", arch))
+			}
+		}
+	}
+
+	if len(manifest.Capabilities) > maxCapabilities {
+		violations = append(violations, fmt.Sprintf("%d capabilities exceed the maximum of %d", len(manifest.Capabilities), maxCapabilities))
+	} else {
+		seen := make(map[pluginapi.Capability]bool)
+		for _, c := range manifest.Capabilities {
+			if !c.Valid() {
+				violations = append(violations, fmt.Sprintf("unknown capability %q", c))
+			} else if seen[c] {
+				violations = append(violations, fmt.Sprintf("duplicate capability %q", c))
+			}
+			seen[c] = true
+		}
+	}
+
+	if len(manifest.Hooks) > maxHooks {
+		violations = append(violations, fmt.Sprintf("%d hooks exceed the maximum of %d", len(manifest.Hooks), maxHooks))
+	} else {
+		for i, name := range manifest.Hooks {
+			if _, ok := callbackCapabilities[name]; !ok {
+				violations = append(violations, fmt.Sprintf("unknown hook %q", name))
+			} else if containsString(manifest.Hooks[:i], name) {
+				violations = append(violations, fmt.Sprintf("duplicate hook %q", name))
+			}
+		}
+	}
+
+	violations = append(violations, checkKeywords(manifest.Keywords)...)
+	violations = append(violations, checkConfigSchema(manifest.Config)...)
+	if !manifest.ConfigFormat.Valid() {
+		violations = append(violations, fmt.Sprintf("unknown config format %q", manifest.ConfigFormat))
+	}
+	violations = append(violations, checkConfigMigration(manifest)...)
+	violations = append(violations, checkDeprecation(manifest)...)
+	violations = append(violations, checkBinaryCompression(manifest)...)
+	violations = append(violations, checkAssets(manifest)...)
+
+	if err := CheckProvenance(manifest); err != nil {
+		violations = append(violations, err.Error())
+	}
+
+	return violations
+}
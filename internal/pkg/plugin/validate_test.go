@@ -0,0 +1,282 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+func TestValidateManifest(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		allowUnknown bool
+		violations   int
+	}{
+		{
+			name: "Valid",
+			data: `{"name": "example.com/foo", "author": "Foo", "version": "1.0.0", "description": "Foo\nplugin",
+				"dependencies": [{"name": "example.com/bar", "version": ">=1.2"}], "architectures": ["amd64"],
+				"capabilities": ["cli-commands", "exec-binaries"], "hooks": ["cli.Command"], "keywords": ["gpu", "mpi-4"]}`,
+		},
+		{
+			name:       "Empty",
+			data:       ``,
+			violations: 1,
+		},
+		{
+			name:       "NotObject",
+			data:       `["example.com/foo"]`,
+			violations: 1,
+		},
+		{
+			name:       "Malformed",
+			data:       `{"name": "example.com/foo"`,
+			violations: 1,
+		},
+		{
+			name:       "TrailingData",
+			data:       `{"name": "example.com/foo"} {}`,
+			violations: 1,
+		},
+		{
+			name:       "NoName",
+			data:       `{"author": "Foo"}`,
+			violations: 1,
+		},
+		{
+			name:       "InvalidName",
+			data:       `{"name": "example.com/../foo"}`,
+			violations: 1,
+		},
+		{
+			name:       "NameWithSpaces",
+			data:       `{"name": " example.com/foo"}`,
+			violations: 1,
+		},
+		{
+			name:       "DuplicateKeys",
+			data:       `{"name": "example.com/foo", "Name": "example.com/bar", "dependencies": [{"name": "example.com/baz", "name": "example.com/qux"}]}`,
+			violations: 2,
+		},
+		{
+			name:       "UnknownField",
+			data:       `{"name": "example.com/foo", "unknown": true}`,
+			violations: 1,
+		},
+		{
+			name:         "UnknownFieldAllowed",
+			data:         `{"name": "example.com/foo", "unknown": {"nested": [1, 2]}}`,
+			allowUnknown: true,
+		},
+		{
+			name:         "DeeplyNested",
+			data:         `{"name": "example.com/foo", "unknown": ` + strings.Repeat("[", 100) + strings.Repeat("]", 100) + `}`,
+			allowUnknown: true,
+			violations:   1,
+		},
+		{
+			name:       "WrongType",
+			data:       `{"name": "example.com/foo", "isolated": "yes"}`,
+			violations: 1,
+		},
+		{
+			name:       "WrongTypeAndUnknownField",
+			data:       `{"name": "example.com/foo", "unknown": true, "isolated": "yes"}`,
+			violations: 2,
+		},
+		{
+			name:       "TooLong",
+			data:       `{"name": "example.com/foo", "author": "` + strings.Repeat("a", 257) + `", "version": "` + strings.Repeat("1", 65) + `"}`,
+			violations: 2,
+		},
+		{
+			name:       "ControlCharacters",
+			data:       `{"name": "example.com/foo", "author": "Foo\u001b[31m", "description": "Foo\tbar\u0007"}`,
+			violations: 2,
+		},
+		{
+			name:       "InvalidDependencies",
+			data:       `{"name": "example.com/foo", "dependencies": [{"name": ""}, {"name": "example.com/bar", "version": ">>1"}]}`,
+			violations: 2,
+		},
+		{
+			name:       "UnknownArchitecture",
+			data:       `{"name": "example.com/foo", "architectures": ["x86_64"]}`,
+			violations: 1,
+		},
+		{
+			name:       "InvalidCapabilities",
+			data:       `{"name": "example.com/foo", "capabilities": ["cli-commands", "root", "cli-commands"]}`,
+			violations: 2,
+		},
+		{
+			name:       "InvalidHooks",
+			data:       `{"name": "example.com/foo", "hooks": ["cli.Command", "main.Hook", "cli.Command"]}`,
+			violations: 2,
+		},
+		{
+			name:       "InvalidKeywords",
+			data:       `{"name": "example.com/foo", "keywords": ["gpu", "GPU", "-net", "gpu"]}`,
+			violations: 3,
+		},
+		{
+			name:       "InvalidConfig",
+			data:       `{"name": "example.com/foo", "config": [{"key": "port", "type": "int", "default": "http"}, {"key": "port.", "type": "string"}]}`,
+			violations: 2,
+		},
+		{
+			name: "ConfigFormat",
+			data: `{"name": "example.com/foo", "configFormat": "toml"}`,
+		},
+		{
+			name:       "UnknownConfigFormat",
+			data:       `{"name": "example.com/foo", "configFormat": "ini"}`,
+			violations: 1,
+		},
+		{
+			name:       "InvalidProvenance",
+			data:       `{"name": "example.com/foo", "homepage": "example.com"}`,
+			violations: 1,
+		},
+		{
+			name:       "TooBig",
+			data:       `{"name": "example.com/foo", "description": "` + strings.Repeat("a", maxManifestSize) + `"}`,
+			violations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, violations := validateManifest([]byte(tt.data), tt.allowUnknown)
+			if len(violations) != tt.violations {
+				t.Errorf("got %d violations, expected %d: %q", len(violations), tt.violations, violations)
+			}
+		})
+	}
+}
+
+// corruptManifest returns a copy of data randomly corrupted
+// by flipping, removing, inserting or duplicating bytes.
+func corruptManifest(r *rand.Rand, data []byte) []byte {
+	corrupted := append([]byte(nil), data...)
+
+	for n := r.Intn(4) + 1; n > 0 && len(corrupted) > 0; n-- {
+		i := r.Intn(len(corrupted))
+		switch r.Intn(4) {
+		case 0:
+			corrupted[i] ^= byte(1 << uint(r.Intn(8)))
+		case 1:
+			corrupted = append(corrupted[:i], corrupted[i+1:]...)
+		case 2:
+			tokens := []byte(`{}[]",:\0`)
+			b := tokens[r.Intn(len(tokens))]
+			corrupted = append(corrupted[:i], append([]byte{b}, corrupted[i:]...)...)
+		case 3:
+			corrupted = append(corrupted[:i], append(corrupted[i:], corrupted[i:]...)...)
+		}
+	}
+
+	return corrupted
+}
+
+func TestValidateCorruptedManifest(t *testing.T) {
+	manifest := pluginapi.Manifest{
+		Name:        "example.com/foo",
+		Author:      "Foo",
+		Version:     "1.0.0",
+		Description: "Foo plugin",
+		Dependencies: []pluginapi.Dependency{
+			{Name: "example.com/bar", Version: ">=1.2, <2"},
+		},
+		Architectures: []string{"amd64", "arm64"},
+		License:       "BSD-3-Clause",
+		Homepage:      "https://example.com/foo",
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("while encoding manifest: %s", err)
+	}
+
+	if _, violations := validateManifest(data, false); len(violations) > 0 {
+		t.Fatalf("unexpected violations for the valid manifest: %q", violations)
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		corrupted := corruptManifest(r, data)
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Fatalf("panic while validating manifest %q: %v", corrupted, p)
+				}
+			}()
+			validateManifest(corrupted, i%2 == 0)
+		}()
+	}
+}
+
+func TestInspectCorruptedManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin-validate-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest := pluginapi.Manifest{Name: "example.com/foo", Version: "1.0.0"}
+	sifPath := createTestPlugin(t, dir, manifest)
+
+	fimg, err := sif.LoadContainer(sifPath, true)
+	if err != nil {
+		t.Fatalf("while loading plugin image: %s", err)
+	}
+	var offset, length int64
+	for _, d := range fimg.DescrArr {
+		if d.Used && d.GetName() == pluginManifestName {
+			offset, length = d.Fileoff, d.Filelen
+		}
+	}
+	original := append([]byte(nil), fimg.Filedata[offset:offset+length]...)
+	fimg.UnloadContainer()
+
+	// corrupt the manifest partition in place, keeping its size
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		corrupted := corruptManifest(r, original)
+		if len(corrupted) < len(original) {
+			corrupted = append(corrupted, bytes.Repeat([]byte(" "), len(original)-len(corrupted))...)
+		}
+		corrupted = corrupted[:len(original)]
+
+		f, err := os.OpenFile(sifPath, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("while opening plugin image: %s", err)
+		}
+		_, err = f.WriteAt(corrupted, offset)
+		f.Close()
+		if err != nil {
+			t.Fatalf("while corrupting plugin image: %s", err)
+		}
+
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					t.Fatalf("panic while inspecting manifest %q: %v", corrupted, p)
+				}
+			}()
+			Inspect(sifPath)
+		}()
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	pluginapi "github.com/sylabs/singularity/pkg/plugin"
+)
+
+// parseVersion parses a plugin version, a semantic version
+// optionally prefixed by "v" like Go module versions.
+func parseVersion(version string) (semver.Version, error) {
+	return semver.Parse(strings.TrimPrefix(version, "v"))
+}
+
+// CheckVersion returns an error if version is not a semantic
+// version, optionally prefixed by "v" (eg: 1.2.0 or v1.2.0).
+func CheckVersion(version string) error {
+	if _, err := parseVersion(version); err != nil {
+		return fmt.Errorf("%q is not a semantic version: %s", version, err)
+	}
+	return nil
+}
+
+// checkManifestVersion warns about a manifest version which is not a
+// semantic version, it's not an error to keep supporting plugins built
+// before versions were checked.
+func checkManifestVersion(manifest pluginapi.Manifest) {
+	if manifest.Version == "" {
+		sylog.Debugf("Plugin %q has no version", manifest.Name)
+		return
+	}
+	if err := CheckVersion(manifest.Version); err != nil {
+		sylog.Warningf("Plugin %q version %s", manifest.Name, err)
+	}
+}
+
+// installedVersion returns the version of the installed plugin, it's
+// taken from the installation history for plugins installed before the
+// version was recorded in the meta.
+func (m *Meta) installedVersion() string {
+	if m.Version != "" || len(m.History) == 0 {
+		return m.Version
+	}
+	return m.History[len(m.History)-1].Version
+}
+
+// checkDowngrade warns when the plugin installed by previous is
+// replaced by an older version. Nothing is reported if one of the
+// versions is not a semantic version.
+func checkDowngrade(previous *Meta, version string) {
+	installed := previous.installedVersion()
+
+	from, err := parseVersion(installed)
+	if err != nil {
+		return
+	}
+	to, err := parseVersion(version)
+	if err != nil {
+		return
+	}
+	if to.LT(from) {
+		sylog.Warningf("Downgrading plugin %q from version %s to %s", previous.Name, installed, version)
+	}
+}
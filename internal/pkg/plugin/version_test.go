@@ -0,0 +1,66 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		version     string
+		expectError bool
+	}{
+		{version: "1.2.0"},
+		{version: "v1.2.0"},
+		{version: "v1.2.0-rc.1+build.5"},
+		{version: "", expectError: true},
+		{version: "1.2", expectError: true},
+		{version: "latest", expectError: true},
+		{version: "vv1.2.0", expectError: true},
+	}
+
+	for _, tt := range tests {
+		err := CheckVersion(tt.version)
+		if err != nil && !tt.expectError {
+			t.Errorf("unexpected error for version %q: %s", tt.version, err)
+		} else if err == nil && tt.expectError {
+			t.Errorf("unexpected success for version %q", tt.version)
+		}
+	}
+}
+
+func TestInstalledVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     *Meta
+		expected string
+	}{
+		{
+			name:     "Recorded",
+			meta:     &Meta{Version: "v1.1.0", History: History{{Version: "v1.0.0"}}},
+			expected: "v1.1.0",
+		},
+		{
+			name:     "FromHistory",
+			meta:     &Meta{History: History{{Version: "v0.9.0"}, {Version: "v1.0.0"}}},
+			expected: "v1.0.0",
+		},
+		{
+			name:     "Unknown",
+			meta:     &Meta{},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := tt.meta.installedVersion(); v != tt.expected {
+				t.Errorf("got version %q, expected %q", v, tt.expected)
+			}
+		})
+	}
+}
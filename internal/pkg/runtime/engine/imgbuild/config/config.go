@@ -13,9 +13,23 @@ import (
 // Name of the engine.
 const Name = "imgbuild"
 
+const (
+	// SectionEventPrefix prefixes the lines written on the standard
+	// output by the engine to report section boundaries when
+	// SectionEvents is set.
+	SectionEventPrefix = "@@singularity-build-section@@ "
+	// SectionEventStart is the marker reporting a section start.
+	SectionEventStart = "start"
+	// SectionEventEnd is the marker reporting a section end.
+	SectionEventEnd = "end"
+)
+
 // EngineConfig is the config for the Singularity engine used to
 // run a minimal image during image build process.
 type EngineConfig struct {
 	types.Bundle `json:"bundle"`
 	OciConfig    *oci.Config `json:"ociConfig"`
+	// SectionEvents instructs the engine to report section
+	// boundaries on its standard output.
+	SectionEvents bool `json:"sectionEvents"`
 }
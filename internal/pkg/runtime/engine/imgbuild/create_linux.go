@@ -343,7 +343,19 @@ func (e *EngineOperations) runScriptSection(name string, s types.Script, setEnv
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	e.sectionEvent(imgbuildConfig.SectionEventStart, name)
+
 	if err := cmd.Run(); err != nil {
 		sylog.Fatalf("failed to execute %%%s proc: %v\n", name, err)
 	}
+
+	e.sectionEvent(imgbuildConfig.SectionEventEnd, name)
+}
+
+// sectionEvent reports a section boundary to the build
+// process when requested.
+func (e *EngineOperations) sectionEvent(event, name string) {
+	if e.EngineConfig.SectionEvents {
+		fmt.Fprintf(os.Stdout, "%s%s %s\n", imgbuildConfig.SectionEventPrefix, event, name)
+	}
 }
@@ -50,7 +50,7 @@ func (e *EngineOperations) CleanupContainer(ctx context.Context, fatal error, st
 			// context and can get permission denied error during
 			// image removal, so we execute "rm -rf /tmp/image" via
 			// the fakeroot engine
-			err = fakerootCleanup(image)
+			err = fakerootCleanup(image, !e.EngineConfig.GetUsernsOnly())
 		} else {
 			err = os.RemoveAll(image)
 		}
@@ -120,7 +120,7 @@ func cleanupCrypt(path string) error {
 	return nil
 }
 
-func fakerootCleanup(path string) error {
+func fakerootCleanup(path string, suid bool) error {
 	command := []string{"/bin/rm", "-rf", path}
 
 	sylog.Debugf("Calling fakeroot engine to execute %q", strings.Join(command, " "))
@@ -134,6 +134,6 @@ func fakerootCleanup(path string) error {
 	return starter.Run(
 		"Singularity fakeroot",
 		cfg,
-		starter.UseSuid(true),
+		starter.UseSuid(suid),
 	)
 }
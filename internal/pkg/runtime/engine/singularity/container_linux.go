@@ -22,6 +22,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/cgroups"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/singularity/rpc/client"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/bin"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/files"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/layout"
@@ -79,6 +80,9 @@ type container struct {
 	skippedMount  []string
 	suidFlag      uintptr
 	devSourcePath string
+	// devices are the devices cgroup rules allowing
+	// the device nodes bound by the user
+	devices []specs.LinuxDeviceCgroup
 }
 
 func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, pid int) error {
@@ -93,6 +97,9 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		return fmt.Errorf("unable to parse singularity.conf file: %s", err)
 	}
 
+	// default mounts disabled with --no-mount
+	singularity.ApplyNoMount(engine.EngineConfig.File, engine.EngineConfig.GetNoMount())
+
 	c := &container{
 		engine:        engine,
 		rpcOps:        rpcOps,
@@ -161,6 +168,9 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 	if err := system.RunAfterTag(mount.RootfsTag, c.addActionsMount); err != nil {
 		return err
 	}
+	if err := system.RunAfterTag(mount.RootfsTag, c.addHostCertsMount); err != nil {
+		return err
+	}
 
 	if err := c.addRootfsMount(system); err != nil {
 		return err
@@ -245,7 +255,7 @@ func create(ctx context.Context, engine *EngineOperations, rpcOps *client.RPC, p
 		if path != "" {
 			cgroupPath := filepath.Join("/singularity", strconv.Itoa(pid))
 			cgroupManager = &cgroups.Manager{Pid: pid, Path: cgroupPath}
-			if err := cgroupManager.ApplyFromFile(path); err != nil {
+			if err := cgroupManager.ApplyFromFile(path, c.devices...); err != nil {
 				return fmt.Errorf("failed to apply cgroups resources restriction: %s", err)
 			}
 		}
@@ -550,6 +560,10 @@ mount:
 				sylog.Verbosef("Overlay mount failed with %s, mounting with index=off", err)
 				optsString = fmt.Sprintf("%s,index=off", optsString)
 				goto mount
+			} else if mnt.Type == "overlay" && c.userNS && (err == syscall.EPERM || err == syscall.ENODEV) {
+				// the kernel doesn't support overlay or doesn't allow
+				// it in a user namespace
+				return c.mountFuseOverlay(dest, optsString, err)
 			}
 			// mount error for other filesystems is considered fatal
 			return fmt.Errorf("can't mount %s filesystem to %s: %s", mnt.Type, mnt.Destination, err)
@@ -575,6 +589,24 @@ mount:
 	return nil
 }
 
+// mountFuseOverlay mounts the overlay filesystem on dest with the
+// fuse-overlayfs program, options being the overlay mount options.
+// It's the fallback used when the kernel overlay mount failed with
+// mountErr in a user namespace.
+func (c *container) mountFuseOverlay(dest string, options string, mountErr error) error {
+	program, err := bin.FuseOverlayfs()
+	if err != nil {
+		return fmt.Errorf("can't mount overlay filesystem to %s: kernel overlay is not usable in a user namespace (%s) and %s", dest, mountErr, err)
+	}
+
+	sylog.Verbosef("Kernel overlay mount failed with %s, mounting overlay with %s", mountErr, program)
+
+	if err := c.rpcOps.FuseOverlay(program, dest, options); err != nil {
+		return fmt.Errorf("can't mount overlay filesystem to %s: %s", dest, err)
+	}
+	return nil
+}
+
 // mount image via loop
 func (c *container) mountImage(mnt *mount.Point) error {
 	maxDevices := int(c.engine.EngineConfig.File.MaxLoopDevices)
@@ -792,12 +824,33 @@ func (c *container) addOverlayMount(system *mount.System) error {
 
 		flags := uintptr(c.suidFlag | syscall.MS_NODEV)
 
-		if err := system.Points.AddBind(mount.PreLayerTag, tmpfsPath, tmpfsPath, flags); err != nil {
-			return fmt.Errorf("failed to add %s temporary filesystem: %s", tmpfsPath, err)
-		}
+		if size := c.engine.EngineConfig.GetWritableTmpfsSize(); size > 0 {
+			// a dedicated tmpfs bounds the data written in the
+			// container, it hides the upper and work directories
+			// of the session which are created again once mounted
+			options := fmt.Sprintf("mode=1777,size=%d", size)
+			if err := system.Points.AddFS(mount.PreLayerTag, tmpfsPath, "tmpfs", flags, options); err != nil {
+				return fmt.Errorf("failed to add %s temporary filesystem: %s", tmpfsPath, err)
+			}
+			err := system.RunAfterTag(mount.PreLayerTag, func(*mount.System) error {
+				for _, dir := range []string{upper, work} {
+					if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+						return fmt.Errorf("failed to create %s: %s", dir, err)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			if err := system.Points.AddBind(mount.PreLayerTag, tmpfsPath, tmpfsPath, flags); err != nil {
+				return fmt.Errorf("failed to add %s temporary filesystem: %s", tmpfsPath, err)
+			}
 
-		if err := system.Points.AddRemount(mount.PreLayerTag, tmpfsPath, flags); err != nil {
-			return fmt.Errorf("failed to add %s temporary filesystem: %s", tmpfsPath, err)
+			if err := system.Points.AddRemount(mount.PreLayerTag, tmpfsPath, flags); err != nil {
+				return fmt.Errorf("failed to add %s temporary filesystem: %s", tmpfsPath, err)
+			}
 		}
 
 		hasUpper = true
@@ -843,8 +896,8 @@ func (c *container) addOverlayMount(system *mount.System) error {
 				}
 				ov.AddLowerDir(dst)
 			case image.SANDBOX:
-				if os.Geteuid() != 0 {
-					return fmt.Errorf("only root user can use sandbox as overlay")
+				if os.Geteuid() != 0 && !c.userNS {
+					return fmt.Errorf("only root user or user namespace can use sandbox as overlay")
 				}
 
 				flags := uintptr(c.suidFlag | syscall.MS_NODEV)
@@ -1576,6 +1629,8 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 					if src != devPrefix {
 						if err := c.addSessionDev(src, system); err != nil {
 							sylog.Warningf("Skipping %s bind mount: %s", src, err)
+						} else if err := c.addDeviceRules(src); err != nil {
+							return err
 						}
 					} else {
 						system.Points.RemoveByTag(mount.DevTag)
@@ -1586,6 +1641,12 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 				devicesMounted++
 			} else if c.engine.EngineConfig.File.MountDev == "yes" {
 				sylog.Warningf("Skipping %s bind mount: /dev is already mounted", src)
+				// the device is visible with the host /dev
+				if src != devPrefix {
+					if err := c.addDeviceRules(src); err != nil {
+						return err
+					}
+				}
 			} else {
 				sylog.Warningf("Skipping %s bind mount: disallowed by configuration", src)
 			}
@@ -1617,6 +1678,31 @@ func (c *container) addUserbindsMount(system *mount.System) error {
 	return nil
 }
 
+// addDeviceRules records the devices cgroup rules allowing the device
+// nodes bound from path, a device node or a directory, unless disabled
+// or no cgroups profile is applied. Other files are ignored.
+func (c *container) addDeviceRules(path string) error {
+	if c.engine.EngineConfig.GetCgroupsPath() == "" || c.engine.EngineConfig.GetNoDeviceCgroup() {
+		return nil
+	}
+
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("while looking for device nodes in %s: %s", path, err)
+		}
+		if fi.Mode()&os.ModeDevice == 0 {
+			return nil
+		}
+		rule, err := cgroups.DeviceRule(p)
+		if err != nil {
+			return fmt.Errorf("could not allow bound device %s, use --no-device-cgroup to bind it without a devices cgroup rule: %s", p, err)
+		}
+		sylog.Debugf("Allowing device %s (%s %d:%d %s) in devices cgroup", p, rule.Type, *rule.Major, *rule.Minor, rule.Access)
+		c.devices = append(c.devices, rule)
+		return nil
+	})
+}
+
 func (c *container) addTmpMount(system *mount.System) error {
 	const (
 		tmpPath    = "/tmp"
@@ -1753,6 +1839,10 @@ func (c *container) addCwdMount(system *mount.System) error {
 		sylog.Verbosef("Not mounting current directory: container was requested")
 		return nil
 	}
+	if c.engine.EngineConfig.IsNoMount(singularity.NoMountCwd) {
+		sylog.Verbosef("Not mounting current directory by user request")
+		return nil
+	}
 	if !c.engine.EngineConfig.File.UserBindControl {
 		sylog.Warningf("Not mounting current directory: user bind control is disabled by system administrator")
 		return nil
@@ -1980,8 +2070,14 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 		var content []byte
 
 		dns := c.engine.EngineConfig.GetDNS()
+		custom := c.engine.EngineConfig.GetResolvConf()
 
-		if dns == "" {
+		if custom != nil {
+			if err := files.CheckResolvConf(custom); err != nil {
+				return fmt.Errorf("invalid resolv.conf content: %s", err)
+			}
+			content = custom
+		} else if dns == "" {
 			r, err := os.Open(resolvConf)
 			if err != nil {
 				return err
@@ -2010,11 +2106,53 @@ func (c *container) addResolvConfMount(system *mount.System) error {
 		}
 		sylog.Verbosef("Default mount: /etc/resolv.conf:/etc/resolv.conf")
 	} else {
+		if c.engine.EngineConfig.GetResolvConf() != nil {
+			sylog.Warningf("Ignoring --resolv-conf: disabled by configuration")
+		}
 		sylog.Verbosef("Skipping bind of the host's %s", resolvConf)
 	}
 	return nil
 }
 
+// addHostCertsMount binds read-only the host CA certificates bundle
+// into a container lacking one. It's called once the root filesystem
+// is mounted to look for a bundle in the container, before the layer
+// creates the missing bind destinations.
+func (c *container) addHostCertsMount(system *mount.System) error {
+	if !c.engine.EngineConfig.File.MountHostCerts || c.engine.EngineConfig.GetNoHostCerts() {
+		sylog.Verbosef("Skipping bind of the host's CA certificates")
+		return nil
+	}
+
+	dest, exists := files.ContainerCertsBundle(c.session.RootFsPath())
+	if exists {
+		sylog.Debugf("Container provides the CA certificates bundle %s", dest)
+		return nil
+	} else if !c.isLayerEnabled() {
+		sylog.Verbosef("Skipping bind of the host's CA certificates: no layer to create %s", dest)
+		return nil
+	}
+
+	source, err := files.HostCertsBundle()
+	if err != nil {
+		sylog.Verbosef("Skipping bind of the host's CA certificates: %s", err)
+		return nil
+	}
+
+	flags := uintptr(syscall.MS_BIND | syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_RDONLY)
+
+	sylog.Debugf("Adding %s to mount list\n", dest)
+	if err := system.Points.AddBind(mount.FilesTag, source, dest, flags); err != nil {
+		return fmt.Errorf("unable to add %s to mount list: %s", dest, err)
+	}
+	if err := system.Points.AddRemount(mount.FilesTag, dest, flags); err != nil {
+		return fmt.Errorf("unable to add %s to mount list: %s", dest, err)
+	}
+	sylog.Verbosef("Default mount: %s:%s", source, dest)
+
+	return nil
+}
+
 func (c *container) addHostnameMount(system *mount.System) error {
 	hostnameFile := "/etc/hostname"
 
@@ -2150,6 +2288,14 @@ func (c *container) prepareNetworkSetup(system *mount.System, pid int) (func(con
 		return nil, fmt.Errorf("error while setting network arguments: %s", err)
 	}
 
+	family, err := network.ParseIPFamily(c.engine.EngineConfig.GetNetworkIPFamily())
+	if err != nil {
+		return nil, err
+	}
+	if err := networkSetup.SetIPFamily(family); err != nil {
+		return nil, fmt.Errorf("error while setting network IP family: %s", err)
+	}
+
 	return func(ctx context.Context) error {
 		if fakeroot {
 			// prevent port hijacking between user processes
@@ -0,0 +1,121 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// containerInit is the init program of the container run as PID 1 with
+// the init mode InitContainer.
+const containerInit = "/sbin/init"
+
+// sinit is the built-in init process run as PID 1 of a container with a
+// PID namespace, the parent of the container process. It reaps all the
+// exited processes of the container, the orphaned ones reparented to it
+// included, and forwards the signals it receives to the container process.
+type sinit struct {
+	// pid is the PID of the container process.
+	pid int
+	// group forwards the signals to the process group of the container
+	// process, as done for instances. The init process then outlives
+	// the container process.
+	group bool
+	// propagate forwards the signals to the container process, they
+	// are otherwise delivered to it by the terminal.
+	propagate bool
+	// exited is called once the container process exited.
+	exited func()
+}
+
+// wait handles the signals received on signals, which must be notified
+// of all signals, until the init process must exit and returns its exit
+// code: the exit status of the container process, or 128 plus the number
+// of the signal killing it, or 128 plus the number of the signal received
+// once no process is left to forward it to.
+func (s *sinit) wait(signals <-chan os.Signal) int {
+	// the container process may have exited before the signal
+	// handler was installed
+	if code, ok := s.reap(); ok {
+		return code
+	}
+
+	for sig := range signals {
+		sylog.Debugf("Received signal %s", sig.String())
+
+		// a SIGCHLD is dropped when another signal is pending
+		// in signals, the exited children are reaped whatever
+		// the signal received
+		if code, ok := s.reap(); ok {
+			return code
+		}
+
+		signal := sig.(syscall.Signal)
+		if signal == syscall.SIGCHLD {
+			continue
+		}
+
+		// EPERM and EINVAL are deliberately ignored because they can't be
+		// returned in this context, this process is PID 1, so it has the
+		// permissions to send signals to its childs and EINVAL would
+		// mean to update the Go runtime or the kernel to something more
+		// stable :)
+		var err error
+		if s.group {
+			err = syscall.Kill(-s.pid, signal)
+		} else if s.propagate {
+			err = syscall.Kill(s.pid, signal)
+		}
+		if err == syscall.ESRCH {
+			sylog.Debugf("No child process, exiting ...")
+			return 128 + int(signal)
+		}
+	}
+	return 0
+}
+
+// reap reaps the exited children and returns the exit code of the
+// init process and true if it must exit along with the container
+// process.
+func (s *sinit) reap() (int, bool) {
+	status, ok := reapChildren(s.pid)
+	if !ok {
+		return 0, false
+	}
+	if s.exited != nil {
+		s.exited()
+	}
+	if s.group {
+		return 0, false
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal()), true
+	}
+	return status.ExitStatus(), true
+}
+
+// reapChildren reaps the exited children of the current process, it
+// returns the wait status of the process pid if it's among them.
+func reapChildren(pid int) (syscall.WaitStatus, bool) {
+	var exitStatus syscall.WaitStatus
+	exited := false
+
+	for {
+		var status syscall.WaitStatus
+
+		wpid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if wpid <= 0 || err != nil {
+			return exitStatus, exited
+		}
+		if wpid == pid {
+			exitStatus = status
+			exited = true
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// startInit starts the shell script as the container process of a
+// sinit running in the background, the test process being the subreaper
+// of the orphaned processes as PID 1 is. It returns the channel the
+// signals are sent to, the channel the exit code of sinit is sent to and
+// a channel closed once the container process exited. The script must
+// write a line on its standard output once ready.
+func startInit(t *testing.T, script string, group, propagate bool) (chan os.Signal, chan int, chan struct{}) {
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		t.Fatalf("while setting child subreaper: %s", err)
+	}
+
+	signals := make(chan os.Signal, 10)
+	signal.Notify(signals, syscall.SIGCHLD)
+	t.Cleanup(func() { signal.Stop(signals) })
+
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: group}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("while creating pipe: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("while starting %q: %s", script, err)
+	}
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("while waiting for %q: %s", script, err)
+	}
+
+	exited := make(chan struct{})
+	code := make(chan int, 1)
+	s := &sinit{
+		pid:       cmd.Process.Pid,
+		group:     group,
+		propagate: propagate,
+		exited:    func() { close(exited) },
+	}
+	go func() {
+		code <- s.wait(signals)
+	}()
+	return signals, code, exited
+}
+
+func waitInit(t *testing.T, code chan int) int {
+	select {
+	case c := <-code:
+		return c
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the init process")
+	}
+	return -1
+}
+
+func TestSinitSignal(t *testing.T) {
+	const script = `trap "exit 7" USR1 TERM; echo ready; while :; do sleep 0.05; done`
+
+	// the signal is forwarded to the container process
+	signals, code, _ := startInit(t, script, false, true)
+	signals <- syscall.SIGUSR1
+	if c := waitInit(t, code); c != 7 {
+		t.Errorf("got exit code %d, expected 7", c)
+	}
+
+	// the signal is left to the terminal
+	signals, code, exited := startInit(t, `echo ready; exec sleep 0.5`, false, false)
+	signals <- syscall.SIGUSR1
+	select {
+	case <-exited:
+		t.Errorf("signal forwarded")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if c := waitInit(t, code); c != 0 {
+		t.Errorf("got exit code %d, expected 0", c)
+	}
+
+	// a container process killed by a signal
+	signals, code, _ = startInit(t, `echo ready; exec sleep 10`, false, true)
+	signals <- syscall.SIGUSR2
+	if c := waitInit(t, code); c != 128+int(syscall.SIGUSR2) {
+		t.Errorf("got exit code %d, expected %d", c, 128+int(syscall.SIGUSR2))
+	}
+}
+
+func TestSinitSignalGroup(t *testing.T) {
+	// the signal is forwarded to the process group, the init
+	// process outlives the container process until the group
+	// is empty
+	signals, code, exited := startInit(t, `trap "exit 7" USR1; echo ready; while :; do sleep 0.05; done`, true, true)
+	signals <- syscall.SIGUSR1
+	select {
+	case <-exited:
+	case c := <-code:
+		t.Fatalf("init process exited with code %d along with the container process", c)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out waiting for the container process")
+	}
+
+	signals <- syscall.SIGTERM
+	if c := waitInit(t, code); c != 128+int(syscall.SIGTERM) {
+		t.Errorf("got exit code %d, expected %d", c, 128+int(syscall.SIGTERM))
+	}
+}
+
+func TestSinitReap(t *testing.T) {
+	// the orphaned processes are reparented to the init process,
+	// which reaps them along with the container process
+	const script = `/bin/sh -c "sleep 0.1 & sleep 0.2 &"; echo ready; sleep 0.5; exit 3`
+
+	_, code, _ := startInit(t, script, false, true)
+	if c := waitInit(t, code); c != 3 {
+		t.Errorf("got exit code %d, expected 3", c)
+	}
+
+	var status syscall.WaitStatus
+	if pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil); err != syscall.ECHILD {
+		t.Errorf("process %d left unreaped: %v", pid, err)
+	}
+}
+
+func TestSinitLostSigchld(t *testing.T) {
+	// no SIGCHLD is received for the container process
+	// which already exited
+	cmd := exec.Command("/bin/sh", "-c", "exit 5")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("while starting container process: %s", err)
+	}
+	stat := fmt.Sprintf("/proc/%d/stat", cmd.Process.Pid)
+	for {
+		data, err := ioutil.ReadFile(stat)
+		if err != nil {
+			t.Fatalf("while reading %s: %s", stat, err)
+		}
+		// the state follows the command name
+		if fields := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:])); fields[0] == "Z" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	signals := make(chan os.Signal)
+	code := make(chan int, 1)
+	s := &sinit{pid: cmd.Process.Pid, propagate: true}
+	go func() {
+		code <- s.wait(signals)
+	}()
+	if c := waitInit(t, code); c != 5 {
+		t.Errorf("got exit code %d, expected 5", c)
+	}
+}
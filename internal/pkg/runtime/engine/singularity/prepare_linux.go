@@ -27,11 +27,13 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/security/seccomp"
 	"github.com/sylabs/singularity/internal/pkg/syecl"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/bin"
 	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/internal/pkg/util/fs/overlay"
 	"github.com/sylabs/singularity/internal/pkg/util/mainthread"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	"github.com/sylabs/singularity/pkg/image"
+	singularitycallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/engine/singularity"
 	fakerootcallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/fakeroot"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
 	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
@@ -79,6 +81,10 @@ func (e *EngineOperations) PrepareConfig(starterConfig *starter.Config) error {
 		return fmt.Errorf("suid workflow disabled by administrator")
 	}
 
+	if e.EngineConfig.GetUsernsOnly() && starterConfig.GetIsSUID() {
+		return fmt.Errorf("suid workflow disabled by --userns-only")
+	}
+
 	if starterConfig.GetIsSUID() {
 		// check for ownership of singularity.conf
 		if !fs.IsOwner(configurationFile, 0) {
@@ -191,9 +197,9 @@ func (e *EngineOperations) prepareUserCaps(enforced bool) error {
 		return err
 	}
 
-	caps, ignoredCaps := capabilities.Split(e.EngineConfig.GetAddCaps())
-	if len(ignoredCaps) > 0 {
-		sylog.Warningf("won't add unknown capability: %s", strings.Join(ignoredCaps, ","))
+	caps, err := capabilities.Parse(e.EngineConfig.GetAddCaps())
+	if err != nil {
+		return fmt.Errorf("while parsing capabilities to add: %s", err)
 	}
 	caps = append(caps, e.EngineConfig.OciConfig.Process.Capabilities.Permitted...)
 
@@ -248,19 +254,14 @@ func (e *EngineOperations) prepareUserCaps(enforced bool) error {
 		sylog.Warningf("not authorized to add capability: %s", strings.Join(commonUnauthorizedCaps, ","))
 	}
 
-	caps, ignoredCaps = capabilities.Split(e.EngineConfig.GetDropCaps())
-	if len(ignoredCaps) > 0 {
-		sylog.Warningf("won't drop unknown capability: %s", strings.Join(ignoredCaps, ","))
+	dropCaps, err := capabilities.Parse(e.EngineConfig.GetDropCaps())
+	if err != nil {
+		return fmt.Errorf("while parsing capabilities to drop: %s", err)
 	}
-	for _, cap := range caps {
-		for i, c := range commonCaps {
-			if c == cap {
-				sylog.Debugf("Capability %s dropped", cap)
-				commonCaps = append(commonCaps[:i], commonCaps[i+1:]...)
-				break
-			}
-		}
+	if len(dropCaps) > 0 {
+		sylog.Debugf("Capabilities %s dropped", strings.Join(dropCaps, ","))
 	}
+	commonCaps = capabilities.Apply(commonCaps, nil, dropCaps)
 
 	e.EngineConfig.OciConfig.Process.Capabilities.Permitted = commonCaps
 	e.EngineConfig.OciConfig.Process.Capabilities.Effective = commonCaps
@@ -333,39 +334,21 @@ func (e *EngineOperations) prepareRootCaps() error {
 		e.EngineConfig.OciConfig.SetProcessNoNewPrivileges(true)
 	}
 
-	caps, ignoredCaps := capabilities.Split(e.EngineConfig.GetAddCaps())
-	if len(ignoredCaps) > 0 {
-		sylog.Warningf("won't add unknown capability: %s", strings.Join(ignoredCaps, ","))
+	addCaps, err := capabilities.Parse(e.EngineConfig.GetAddCaps())
+	if err != nil {
+		return fmt.Errorf("while parsing capabilities to add: %s", err)
 	}
-	for _, cap := range caps {
-		found := false
-		for _, c := range commonCaps {
-			if c == cap {
-				found = true
-				break
-			}
-		}
-		if !found {
-			sylog.Debugf("Root capability %s added", cap)
-			commonCaps = append(commonCaps, cap)
-		}
+	dropCaps, err := capabilities.Parse(e.EngineConfig.GetDropCaps())
+	if err != nil {
+		return fmt.Errorf("while parsing capabilities to drop: %s", err)
 	}
-
-	commonCaps = capabilities.RemoveDuplicated(commonCaps)
-
-	caps, ignoredCaps = capabilities.Split(e.EngineConfig.GetDropCaps())
-	if len(ignoredCaps) > 0 {
-		sylog.Warningf("won't add unknown capability: %s", strings.Join(ignoredCaps, ","))
+	if len(addCaps) > 0 {
+		sylog.Debugf("Root capabilities %s added", strings.Join(addCaps, ","))
 	}
-	for _, cap := range caps {
-		for i, c := range commonCaps {
-			if c == cap {
-				sylog.Debugf("Root capability %s dropped", cap)
-				commonCaps = append(commonCaps[:i], commonCaps[i+1:]...)
-				break
-			}
-		}
+	if len(dropCaps) > 0 {
+		sylog.Debugf("Root capabilities %s dropped", strings.Join(dropCaps, ","))
 	}
+	commonCaps = capabilities.Apply(commonCaps, addCaps, dropCaps)
 
 	e.EngineConfig.OciConfig.Process.Capabilities.Permitted = commonCaps
 	e.EngineConfig.OciConfig.Process.Capabilities.Effective = commonCaps
@@ -497,6 +480,10 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		}
 	}
 
+	if err := singularityConfig.CheckNoMount(e.EngineConfig.GetNoMount()); err != nil {
+		return err
+	}
+
 	if os.Getuid() == 0 {
 		if err := e.prepareRootCaps(); err != nil {
 			return err
@@ -606,10 +593,54 @@ func (e *EngineOperations) prepareContainerConfig(starterConfig *starter.Config)
 		}
 	}
 
+	if err := e.prepareStartupCommands(); err != nil {
+		return err
+	}
+
 	// open file descriptors (autofs bug path)
 	return e.prepareAutofs(starterConfig)
 }
 
+// prepareStartupCommands gathers the startup commands registered
+// by plugins and stores them in dependency order in the engine
+// configuration, they are executed by StartProcess.
+func (e *EngineOperations) prepareStartupCommands() error {
+	callbackType := (singularitycallback.ContainerStartup)(nil)
+	callbacks, err := plugin.LoadCallbacks(callbackType)
+	if err != nil {
+		return fmt.Errorf("while loading plugins callbacks '%T': %s", callbackType, err)
+	}
+
+	var commands []singularityConfig.StartupCommand
+
+	for _, cb := range callbacks {
+		c, err := cb.(singularitycallback.ContainerStartup)(e.CommonConfig)
+		if err != nil {
+			return fmt.Errorf("while getting plugin startup commands: %s", err)
+		}
+		commands = append(commands, c...)
+	}
+
+	for _, c := range commands {
+		if len(c.Args) == 0 || !filepath.IsAbs(c.Args[0]) {
+			return fmt.Errorf("startup command %q must be an absolute path", c.Name)
+		}
+		for _, w := range c.Writable {
+			if !filepath.IsAbs(w) {
+				return fmt.Errorf("writable path %s of startup command %q must be an absolute path", w, c.Name)
+			}
+		}
+	}
+
+	ordered, err := singularityConfig.OrderStartupCommands(commands)
+	if err != nil {
+		return err
+	}
+	e.EngineConfig.SetStartupCommands(ordered)
+
+	return nil
+}
+
 // prepareInstanceJoinConfig is responsible for getting and
 // applying configuration to join a running instance.
 func (e *EngineOperations) prepareInstanceJoinConfig(starterConfig *starter.Config) error {
@@ -957,6 +988,25 @@ func (e *EngineOperations) checkSignalPropagation() {
 	}
 }
 
+// setWritableTmpfsSize checks the requested writable tmpfs size
+// against the maximum size set by the administrator, which is also
+// the size used when none is requested.
+func (e *EngineOperations) setWritableTmpfsSize() error {
+	maxSize := uint64(e.EngineConfig.File.WritableTmpfsMaxSize) * 1024 * 1024
+	if maxSize == 0 {
+		return nil
+	}
+
+	size := e.EngineConfig.GetWritableTmpfsSize()
+	if size == 0 {
+		e.EngineConfig.SetWritableTmpfsSize(maxSize)
+	} else if size > maxSize {
+		return fmt.Errorf("--writable-tmpfs-size of %d bytes exceeds the maximum of %dMB set by administrator", size, e.EngineConfig.File.WritableTmpfsMaxSize)
+	}
+
+	return nil
+}
+
 // setSessionLayer will test if overlay is supported/allowed.
 func (e *EngineOperations) setSessionLayer(img *image.Image) error {
 	e.EngineConfig.SetSessionLayer(singularityConfig.DefaultLayer)
@@ -969,10 +1019,21 @@ func (e *EngineOperations) setSessionLayer(img *image.Image) error {
 		return fmt.Errorf("you could not use --overlay in conjunction with --writable")
 	}
 
+	if writableTmpfs {
+		if err := e.setWritableTmpfsSize(); err != nil {
+			return err
+		}
+	}
+
 	// NEED FIX: on ubuntu until 4.15 kernel it was possible to mount overlay
 	// with the current workflow, since 4.18 we get an operation not permitted
 	for _, ns := range e.EngineConfig.OciConfig.Linux.Namespaces {
 		if ns.Type == specs.UserNamespace {
+			// overlays are only used in a user namespace when requested,
+			// with fuse-overlayfs if the kernel refuses the overlay mount
+			if writableTmpfs || hasOverlayImage {
+				return e.setUserNamespaceOverlay(writableTmpfs)
+			}
 			if !e.EngineConfig.File.EnableUnderlay {
 				sylog.Debugf("Not attempting to use underlay with user namespace: disabled by configuration ('enable underlay = no')")
 				return nil
@@ -1049,6 +1110,31 @@ func (e *EngineOperations) setSessionLayer(img *image.Image) error {
 	return nil
 }
 
+// setUserNamespaceOverlay validates the use of an overlay layer in a user
+// namespace, it requires either overlay kernel support or fuse-overlayfs.
+func (e *EngineOperations) setUserNamespaceOverlay(writableTmpfs bool) error {
+	option := "overlay images"
+	if writableTmpfs {
+		option = "--writable-tmpfs"
+	}
+
+	if e.EngineConfig.File.EnableOverlay == "no" {
+		return fmt.Errorf("%s requires 'enable overlay = yes': set to 'no' by administrator", option)
+	}
+
+	if has, _ := proc.HasFilesystem("overlay"); !has {
+		if _, err := bin.FuseOverlayfs(); err != nil {
+			return fmt.Errorf("%s requires overlay kernel support or fuse-overlayfs with user namespace: your kernel doesn't support overlay and %s", option, err)
+		}
+		sylog.Debugf("Overlay not supported by kernel, using fuse-overlayfs with user namespace")
+	} else {
+		sylog.Debugf("Attempting to use overlayfs with user namespace, falling back to fuse-overlayfs")
+	}
+
+	e.EngineConfig.SetSessionLayer(singularityConfig.OverlayLayer)
+	return nil
+}
+
 func (e *EngineOperations) loadImages(starterConfig *starter.Config) error {
 	images := make([]image.Image, 0)
 
@@ -28,9 +28,11 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/security"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/env"
+	"github.com/sylabs/singularity/internal/pkg/util/fs/mount"
 	"github.com/sylabs/singularity/internal/pkg/util/machine"
 	"github.com/sylabs/singularity/internal/pkg/util/user"
 	singularitycallback "github.com/sylabs/singularity/pkg/plugin/callback/runtime/engine/singularity"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
 	"github.com/sylabs/singularity/pkg/util/rlimit"
 	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/sys/unix"
@@ -103,18 +105,34 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 	args := e.EngineConfig.OciConfig.Process.Args
 	env := e.EngineConfig.OciConfig.Process.Env
 
+	pidNamespace := false
 	if e.EngineConfig.OciConfig.Linux != nil {
 		namespaces := e.EngineConfig.OciConfig.Linux.Namespaces
 		for _, ns := range namespaces {
 			if ns.Type == specs.PIDNamespace {
-				if !e.EngineConfig.GetNoInit() {
-					shimProcess = true
-				}
+				pidNamespace = true
 				break
 			}
 		}
 	}
 
+	// the init process of a booted instance is already /sbin/init,
+	// processes joining an instance are run by the instance init
+	execInit := false
+	if pidNamespace && !bootInstance && !e.EngineConfig.GetInstanceJoin() {
+		switch e.EngineConfig.GetInit() {
+		case singularityConfig.InitBuiltin:
+			shimProcess = true
+		case singularityConfig.InitContainer:
+			if _, err := os.Stat(containerInit); err != nil {
+				return fmt.Errorf("could not use the init of the container: %s", err)
+			}
+			sylog.Debugf("Running %s as init process", containerInit)
+			args = append([]string{containerInit}, args...)
+			execInit = true
+		}
+	}
+
 	for _, img := range e.EngineConfig.GetImageList() {
 		// bad file descriptor error is ignored because
 		// the file descriptor has been previously closed
@@ -148,7 +166,13 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 		return fmt.Errorf("failed to apply security configuration: %s", err)
 	}
 
-	if (!isInstance && !shimProcess) || bootInstance || e.EngineConfig.GetInstanceJoin() {
+	if !e.EngineConfig.GetInstanceJoin() {
+		if err := e.runStartupCommands(env); err != nil {
+			return err
+		}
+	}
+
+	if (!isInstance && !shimProcess) || bootInstance || execInit || e.EngineConfig.GetInstanceJoin() {
 		err := syscall.Exec(args[0], args, env)
 		if err != nil {
 			// We know the shell exists at this point, so let's inspect its architecture
@@ -184,17 +208,10 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 		Setpgid: isInstance,
 	}
 
-	errChan := make(chan error, 1)
-	statusChan := make(chan syscall.WaitStatus, 1)
-
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("exec %s failed: %s", args[0], err)
 	}
 
-	go func() {
-		errChan <- cmd.Wait()
-	}()
-
 	// Modify argv argument and program name shown in /proc/self/comm
 	name := "sinit"
 
@@ -216,73 +233,16 @@ func (e *EngineOperations) StartProcess(masterConn net.Conn) error {
 
 	masterConn.Close()
 
-	for {
-		select {
-		case s := <-signals:
-			sylog.Debugf("Received signal %s", s.String())
-			switch s {
-			case syscall.SIGCHLD:
-				for {
-					var status syscall.WaitStatus
-
-					wpid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
-					if wpid <= 0 || err != nil {
-						// We break the loop since an error occurred
-						break
-					}
-
-					if wpid == cmd.Process.Pid {
-						e.stopFuseDrivers()
-						statusChan <- status
-					}
-				}
-			default:
-				signal := s.(syscall.Signal)
-				// EPERM and EINVAL are deliberately ignored because they can't be
-				// returned in this context, this process is PID 1, so it has the
-				// permissions to send signals to its childs and EINVAL would
-				// mean to update the Go runtime or the kernel to something more
-				// stable :)
-				if isInstance {
-					if err := syscall.Kill(-cmd.Process.Pid, signal); err == syscall.ESRCH {
-						sylog.Debugf("No child process, exiting ...")
-						os.Exit(128 + int(signal))
-					}
-				} else if e.EngineConfig.GetSignalPropagation() {
-					if err := syscall.Kill(cmd.Process.Pid, signal); err == syscall.ESRCH {
-						sylog.Debugf("No child process, exiting ...")
-						os.Exit(128 + int(signal))
-					}
-				}
-			}
-		case err := <-errChan:
-			if e, ok := err.(*exec.ExitError); ok {
-				status, ok := e.Sys().(syscall.WaitStatus)
-				if !ok {
-					return fmt.Errorf("command exit with error: %s", err)
-				}
-				statusChan <- status
-			} else if e, ok := err.(*os.SyscallError); ok {
-				// handle possible race with Wait4 call above by ignoring ECHILD
-				// error because child process was already catched
-				if e.Err.(syscall.Errno) != syscall.ECHILD {
-					sylog.Fatalf("error while waiting container process: %s", e.Error())
-				}
-			}
-			if !isInstance {
-				if len(statusChan) > 0 {
-					status := <-statusChan
-					if status.Signaled() {
-						os.Exit(128 + int(status.Signal()))
-					}
-					os.Exit(status.ExitStatus())
-				} else if err == nil {
-					os.Exit(0)
-				}
-				sylog.Fatalf("command exited with unknown error: %s", err)
-			}
-		}
+	shim := &sinit{
+		pid:       cmd.Process.Pid,
+		group:     isInstance,
+		propagate: e.EngineConfig.GetSignalPropagation(),
+		exited:    e.stopFuseDrivers,
 	}
+	// the init process never returns, it exits along with the
+	// container process
+	os.Exit(shim.wait(signals))
+	panic("unreachable")
 }
 
 // PostStartProcess is called from master after successful
@@ -330,11 +290,14 @@ func (e *EngineOperations) PostStartProcess(ctx context.Context, pid int) error
 		file.PPid = os.Getpid()
 		file.Image = e.EngineConfig.GetImage()
 
-		ip, err := e.getIP()
+		ips, err := e.getIPs()
 		if err != nil {
 			sylog.Warningf("Could not get ip for %s: %s", pw.Name, err)
 		}
-		file.IP = ip
+		if len(ips) > 0 {
+			file.IP = ips[0]
+		}
+		file.IPs = ips
 
 		// by default we add all namespaces except the user namespace which
 		// is added conditionally. This delegates checks to the C starter code
@@ -371,6 +334,8 @@ func (e *EngineOperations) PostStartProcess(ctx context.Context, pid int) error
 			return err
 		}
 
+		file.HealthCheck = e.EngineConfig.GetHealthCheck()
+
 		err = file.Update()
 
 		// send SIGUSR1 to the parent process in order to tell it
@@ -381,11 +346,27 @@ func (e *EngineOperations) PostStartProcess(ctx context.Context, pid int) error
 			return err
 		}
 
+		// the health check runs in master for the
+		// whole instance life
+		if err == nil && file.HealthCheck != nil {
+			go instance.MonitorHealth(ctx, file, *file.HealthCheck, notifyHealth)
+		}
+
 		return err
 	}
 	return nil
 }
 
+// notifyHealth reports the health status changes of an instance
+// in the instance logs.
+func notifyHealth(file *instance.File, previous instance.HealthStatus, health instance.Health) {
+	if health.Status == instance.HealthUnhealthy {
+		sylog.Warningf("Instance %s is unhealthy: %s", file.Name, health.Output)
+		return
+	}
+	sylog.Infof("Instance %s health changed from %s to %s", file.Name, previous, health.Status)
+}
+
 func (e *EngineOperations) setPathEnv() {
 	env := e.EngineConfig.OciConfig.Process.Env
 	for _, keyval := range env {
@@ -599,24 +580,71 @@ func (e *EngineOperations) stopFuseDrivers() {
 	}
 }
 
-func (e *EngineOperations) getIP() (string, error) {
+// getIPs returns the IP addresses assigned to the first
+// configured network, IPv4 addresses first.
+func (e *EngineOperations) getIPs() ([]string, error) {
 	if networkSetup == nil {
-		return "", nil
+		return nil, nil
 	}
 
 	net := strings.Split(e.EngineConfig.GetNetwork(), ",")
 
-	ip, err := networkSetup.GetNetworkIP(net[0], "4")
-	if err == nil {
-		return ip.String(), nil
+	ips, err := networkSetup.GetNetworkIPs(net[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not get ip: %s", err)
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
 	}
-	sylog.Warningf("Could not get ipv4 %s", err)
 
-	ip, err = networkSetup.GetNetworkIP(net[0], "6")
-	if err == nil {
-		return ip.String(), nil
+	return addrs, nil
+}
+
+// runStartupCommands executes the startup commands registered by
+// plugins in the order set during PrepareConfig.
+func (e *EngineOperations) runStartupCommands(env []string) error {
+	for _, c := range e.EngineConfig.GetStartupCommands() {
+		if err := runStartupCommand(c, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStartupCommand executes the startup command c, its writable
+// mount points are remounted read-write while it runs and read-only
+// once it exits, see mount.Remount.
+func runStartupCommand(c singularityConfig.StartupCommand, env []string) (err error) {
+	var writable []string
+	defer func() {
+		for _, w := range writable {
+			// the container must not start with a mount
+			// point left writable
+			if rerr := mount.Remount(w, []string{"ro"}); rerr != nil && err == nil {
+				err = fmt.Errorf("while restoring read-only %s after startup command %s: %s", w, c.Name, rerr)
+			}
+		}
+	}()
+
+	for _, w := range c.Writable {
+		if err := mount.Remount(w, []string{"rw"}); err != nil {
+			return fmt.Errorf("while making %s writable for startup command %s: %s", w, c.Name, err)
+		}
+		writable = append(writable, w)
 	}
-	sylog.Warningf("Could not get ipv6 %s", err)
 
-	return "", errors.New("could not get ip")
+	sylog.Debugf("Running startup command %s: %v", c.Name, c.Args)
+
+	cmd := exec.Command(c.Args[0], c.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Env = env
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("startup command %s failed: %s", c.Name, err)
+	}
+	return nil
 }
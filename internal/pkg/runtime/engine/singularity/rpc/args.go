@@ -82,3 +82,11 @@ type SendFdArgs struct {
 	Socket int
 	Fds    []int
 }
+
+// FuseOverlayArgs defines the arguments to mount an overlay
+// filesystem with fuse-overlayfs.
+type FuseOverlayArgs struct {
+	Program string
+	Target  string
+	Options string
+}
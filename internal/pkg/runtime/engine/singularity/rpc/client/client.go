@@ -148,6 +148,18 @@ func (t *RPC) SendFd(socket int, fds []int) error {
 	return err
 }
 
+// FuseOverlay calls the FuseOverlay RPC using the supplied arguments.
+func (t *RPC) FuseOverlay(program string, target string, options string) error {
+	arguments := &args.FuseOverlayArgs{
+		Program: program,
+		Target:  target,
+		Options: options,
+	}
+	var reply int
+	err := t.Client.Call(t.Name+".FuseOverlay", arguments, &reply)
+	return err
+}
+
 func init() {
 	var sysErrnoType syscall.Errno
 	// register syscall.Errno as a type we need to get back
@@ -8,6 +8,7 @@ package server
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
@@ -233,3 +234,17 @@ func (t *Methods) SendFd(arguments *args.SendFdArgs, reply *int) error {
 	rights := unix.UnixRights(append(arguments.Fds, usernsFd)...)
 	return unix.Sendmsg(arguments.Socket, nil, rights, nil, 0)
 }
+
+// FuseOverlay mounts an overlay filesystem with fuse-overlayfs, the
+// program returns once the filesystem is mounted and keeps serving it
+// in background.
+func (t *Methods) FuseOverlay(arguments *args.FuseOverlayArgs, reply *int) error {
+	cmd := exec.Command(arguments.Program, "-o", arguments.Options, arguments.Target)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %s", arguments.Program, err)
+	}
+	return nil
+}
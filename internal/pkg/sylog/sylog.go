@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -67,6 +68,19 @@ var colorReset = "\x1b[0m"
 
 var loggerLevel messageLevel
 
+// namedLevelsEnv is the environment variable holding the message
+// levels of the named loggers, see SetNamedLevels.
+const namedLevelsEnv = "SINGULARITY_PLUGIN_MESSAGELEVEL"
+
+// namedLevels holds the message levels set for named loggers,
+// indexed by logger name.
+var namedLevels = map[string]messageLevel{}
+
+// errorValue is the error type, shadowed by the error message level.
+type errorValue = interface {
+	Error() string
+}
+
 func init() {
 	_levelint := int(messageLevel(info))
 	_levelstr, ok := os.LookupEnv("SINGULARITY_MESSAGELEVEL")
@@ -77,6 +91,10 @@ func init() {
 		}
 	}
 	SetLevel(_levelint)
+
+	if spec, ok := os.LookupEnv(namedLevelsEnv); ok {
+		SetNamedLevels(spec)
+	}
 }
 
 func prefix(level messageLevel) string {
@@ -196,6 +214,130 @@ func GetEnvVar() string {
 	return fmt.Sprintf("SINGULARITY_MESSAGELEVEL=%d", loggerLevel)
 }
 
+// SetNamedLevels sets the message levels of the named loggers from
+// spec, a comma separated list of name=level where level is either
+// an integer as for SetLevel or one of debug, verbose, info, log,
+// warning, error or fatal. A named logger with a level set writes its
+// messages up to this level whatever the global level is. As soon as
+// a level is set for a name, the other named loggers don't write
+// messages above the info level so that only the messages of the
+// loggers debugged are kept. An empty spec clears the levels.
+func SetNamedLevels(spec string) errorValue {
+	levels := map[string]messageLevel{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid message level %q: must be name=level", entry)
+		}
+		level, err := parseLevel(entry[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid message level for %s: %s", entry[:i], err)
+		}
+		levels[entry[:i]] = level
+	}
+
+	namedLevels = levels
+	return nil
+}
+
+// parseLevel returns the message level s, given as an integer
+// or as the lowercase label of the level.
+func parseLevel(s string) (messageLevel, errorValue) {
+	if l, err := strconv.Atoi(s); err == nil {
+		return messageLevel(l), nil
+	}
+	for l := fatal; l <= debug; l++ {
+		if label, ok := messageLabels[l]; ok && strings.ToLower(label) == s {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+// GetNamedEnvVar returns a formatted environment variable string
+// holding the message levels of the named loggers which can later
+// be interpreted by init() in a child proc.
+func GetNamedEnvVar() string {
+	entries := make([]string, 0, len(namedLevels))
+	for name, level := range namedLevels {
+		entries = append(entries, fmt.Sprintf("%s=%d", name, level))
+	}
+	sort.Strings(entries)
+	return namedLevelsEnv + "=" + strings.Join(entries, ",")
+}
+
+// Logger writes messages carrying the name of their emitter, typically
+// a plugin, filtered by the message level set for this name with
+// SetNamedLevels. A nil Logger writes unnamed messages.
+type Logger struct {
+	name string
+}
+
+// NewLogger returns a logger for the messages emitted by name.
+func NewLogger(name string) *Logger {
+	return &Logger{name: name}
+}
+
+// level returns the message level of the logger.
+func (l *Logger) level() messageLevel {
+	if l == nil || l.name == "" {
+		return loggerLevel
+	}
+	if level, ok := namedLevels[l.name]; ok {
+		return level
+	}
+	if len(namedLevels) > 0 && loggerLevel > info {
+		return info
+	}
+	return loggerLevel
+}
+
+func (l *Logger) writef(w io.Writer, level messageLevel, format string, a ...interface{}) {
+	if l.level() < level {
+		return
+	}
+
+	message := fmt.Sprintf(format, a...)
+	message = strings.TrimSuffix(message, "\n")
+
+	name := ""
+	if l != nil && l.name != "" {
+		name = "[" + l.name + "] "
+	}
+
+	fmt.Fprintf(w, "%s%s%s\n", prefix(level), name, message)
+}
+
+// Errorf writes an ERROR level message to the log.
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.writef(os.Stderr, error, format, a...)
+}
+
+// Warningf writes a WARNING level message to the log.
+func (l *Logger) Warningf(format string, a ...interface{}) {
+	l.writef(os.Stderr, warn, format, a...)
+}
+
+// Infof writes an INFO level message to the log.
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.writef(os.Stderr, info, format, a...)
+}
+
+// Verbosef writes a VERBOSE level message to the log.
+func (l *Logger) Verbosef(format string, a ...interface{}) {
+	l.writef(os.Stderr, verbose, format, a...)
+}
+
+// Debugf writes a DEBUG level message to the log.
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.writef(os.Stderr, debug, format, a...)
+}
+
 // Writer returns an io.Writer to pass to an external packages logging utility.
 // i.e when --quiet option is set, this function returns ioutil.Discard writer to ignore output
 func Writer() io.Writer {
@@ -52,6 +52,40 @@ func GetEnvVar() string {
 	return fmt.Sprintf("SINGULARITY_MESSAGELEVEL=-1")
 }
 
+// SetNamedLevels is a dummy function doing nothing.
+func SetNamedLevels(spec string) error {
+	return nil
+}
+
+// GetNamedEnvVar is a dummy function returning environment
+// variable without message level for the named loggers.
+func GetNamedEnvVar() string {
+	return "SINGULARITY_PLUGIN_MESSAGELEVEL="
+}
+
+// Logger is a dummy logger.
+type Logger struct{}
+
+// NewLogger is a dummy function returning a dummy logger.
+func NewLogger(name string) *Logger {
+	return &Logger{}
+}
+
+// Errorf is a dummy function doing nothing.
+func (l *Logger) Errorf(format string, a ...interface{}) {}
+
+// Warningf is a dummy function doing nothing.
+func (l *Logger) Warningf(format string, a ...interface{}) {}
+
+// Infof is a dummy function doing nothing.
+func (l *Logger) Infof(format string, a ...interface{}) {}
+
+// Verbosef is a dummy function doing nothing.
+func (l *Logger) Verbosef(format string, a ...interface{}) {}
+
+// Debugf is a dummy function doing nothing.
+func (l *Logger) Debugf(format string, a ...interface{}) {}
+
 // Writer is a dummy function returning ioutil.Discard writer.
 func Writer() io.Writer {
 	return ioutil.Discard
@@ -70,9 +70,19 @@ func TestNoOps(t *testing.T) {
 			Infof(tt.str)
 			Verbosef(tt.str)
 			Debugf(tt.str)
+
+			l := NewLogger(tt.str)
+			l.Errorf(tt.str)
+			l.Warningf(tt.str)
+			l.Infof(tt.str)
+			l.Verbosef(tt.str)
+			l.Debugf(tt.str)
 		})
 	}
 
 	SetLevel(0)
 	DisableColor()
+	if err := SetNamedLevels("dummy=debug"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
 }
@@ -208,6 +208,169 @@ func TestWritef(t *testing.T) {
 	}
 }
 
+func TestSetNamedLevels(t *testing.T) {
+	defer SetNamedLevels("")
+
+	tests := []struct {
+		name        string
+		spec        string
+		levels      map[string]messageLevel
+		expectError bool
+	}{
+		{
+			name:   "empty",
+			spec:   "",
+			levels: map[string]messageLevel{},
+		},
+		{
+			name: "labels and numbers",
+			spec: "example.com/a=debug, example.com/b=-1,example.com/c=warning",
+			levels: map[string]messageLevel{
+				"example.com/a": debug,
+				"example.com/b": log,
+				"example.com/c": warn,
+			},
+		},
+		{
+			name:        "missing level",
+			spec:        "example.com/a",
+			expectError: true,
+		},
+		{
+			name:        "missing name",
+			spec:        "=debug",
+			expectError: true,
+		},
+		{
+			name:        "unknown level",
+			spec:        "example.com/a=loud",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetNamedLevels(tt.spec)
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("unexpected success with %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(namedLevels) != len(tt.levels) {
+				t.Fatalf("got %d levels, expected %d", len(namedLevels), len(tt.levels))
+			}
+			for name, level := range tt.levels {
+				if namedLevels[name] != level {
+					t.Errorf("got level %d for %s, expected %d", namedLevels[name], name, level)
+				}
+			}
+		})
+	}
+
+	if err := SetNamedLevels("example.com/b=1,example.com/a=5"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := "SINGULARITY_PLUGIN_MESSAGELEVEL=example.com/a=5,example.com/b=1"
+	if env := GetNamedEnvVar(); env != expected {
+		t.Errorf("got %s instead of %s", env, expected)
+	}
+}
+
+func TestLoggerWritef(t *testing.T) {
+	const str = "just a test"
+
+	defer SetNamedLevels("")
+	DisableColor()
+
+	tests := []struct {
+		name    string
+		logger  *Logger
+		global  messageLevel
+		levels  string
+		lvl     messageLevel
+		written bool
+	}{
+		{
+			name:    "no level set",
+			logger:  NewLogger("example.com/a"),
+			global:  debug,
+			lvl:     debug,
+			written: true,
+		},
+		{
+			name:    "level set",
+			logger:  NewLogger("example.com/a"),
+			global:  info,
+			levels:  "example.com/a=debug",
+			lvl:     debug,
+			written: true,
+		},
+		{
+			name:    "level set lower",
+			logger:  NewLogger("example.com/a"),
+			global:  debug,
+			levels:  "example.com/a=warning",
+			lvl:     info,
+			written: false,
+		},
+		{
+			name:    "other debugged",
+			logger:  NewLogger("example.com/b"),
+			global:  debug,
+			levels:  "example.com/a=debug",
+			lvl:     debug,
+			written: false,
+		},
+		{
+			name:    "other debugged info",
+			logger:  NewLogger("example.com/b"),
+			global:  debug,
+			levels:  "example.com/a=debug",
+			lvl:     info,
+			written: true,
+		},
+		{
+			name:    "nil logger",
+			logger:  nil,
+			global:  info,
+			levels:  "example.com/a=debug",
+			lvl:     info,
+			written: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			SetLevel(int(tt.global))
+			if err := SetNamedLevels(tt.levels); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			tt.logger.writef(&buf, tt.lvl, "%s", str)
+			if !tt.written {
+				if buf.Len() != 0 {
+					t.Fatalf("unexpected message %q", buf.String())
+				}
+				return
+			}
+
+			expectedResult := str + "\n"
+			if tt.logger != nil {
+				expectedResult = "[" + tt.logger.name + "] " + expectedResult
+			}
+			if !strings.HasSuffix(buf.String(), expectedResult) {
+				t.Fatalf("got message %q instead of %q", buf.String(), expectedResult)
+			}
+		})
+	}
+}
+
 func TestGetLevel(t *testing.T) {
 	tests := []struct {
 		name           string
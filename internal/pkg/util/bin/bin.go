@@ -15,12 +15,15 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/internal/pkg/util/env"
 	"github.com/sylabs/singularity/pkg/util/singularityconf"
 )
 
 var (
 	// errCryptsetupNotFound is returned when cryptsetup is not found
 	errCryptsetupNotFound = errors.New("cryptsetup not found")
+	// errFuseOverlayfsNotFound is returned when fuse-overlayfs is not found
+	errFuseOverlayfsNotFound = errors.New("fuse-overlayfs not found")
 
 	cache struct {
 		sync.Once
@@ -83,3 +86,23 @@ func cryptsetup(cfgpath string) (string, error) {
 	// use exec.LookPath to verify it's an executable.
 	return exec.LookPath(path)
 }
+
+// FuseOverlayfs looks for the "fuse-overlayfs" program in the default
+// PATH returning the absolute path to it. If the fuse-overlayfs program
+// is not available, this function returns a non-nil error.
+func FuseOverlayfs() (string, error) {
+	return fuseOverlayfs(env.DefaultPath)
+}
+
+// fuseOverlayfs looks for fuse-overlayfs in the list of directories
+// searchPath, using the PATH environment variable format.
+//
+// This function is the test-friendly version of FuseOverlayfs above.
+func fuseOverlayfs(searchPath string) (string, error) {
+	for _, dir := range filepath.SplitList(searchPath) {
+		if path, err := exec.LookPath(filepath.Join(dir, "fuse-overlayfs")); err == nil {
+			return path, nil
+		}
+	}
+	return "", errFuseOverlayfsNotFound
+}
@@ -92,3 +92,63 @@ func TestCryptsetup(t *testing.T) {
 		})
 	}
 }
+
+func TestFuseOverlayfs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bin-")
+	if err != nil {
+		t.Fatalf("cannot create temporary directory: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	bindir := filepath.Join(dir, "bin")
+	emptydir := filepath.Join(dir, "empty")
+	noexecdir := filepath.Join(dir, "noexec")
+	for _, d := range []string{bindir, emptydir, noexecdir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("cannot create directory %s: %+v", d, err)
+		}
+	}
+	program := filepath.Join(bindir, "fuse-overlayfs")
+	if err := ioutil.WriteFile(program, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("cannot create %s: %+v", program, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(noexecdir, "fuse-overlayfs"), []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatalf("cannot create non executable fuse-overlayfs: %+v", err)
+	}
+
+	cases := map[string]struct {
+		searchPath    string
+		expectSuccess bool
+	}{
+		"found": {
+			searchPath:    emptydir + ":" + bindir,
+			expectSuccess: true,
+		},
+		"not executable": {
+			searchPath:    noexecdir + ":" + bindir,
+			expectSuccess: true,
+		},
+		"not found": {
+			searchPath:    emptydir + ":" + noexecdir,
+			expectSuccess: false,
+		},
+		"empty path": {
+			searchPath:    "",
+			expectSuccess: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path, err := fuseOverlayfs(tc.searchPath)
+			switch {
+			case tc.expectSuccess && err != nil:
+				t.Errorf("unexpected error looking for fuse-overlayfs in %q: %+v", tc.searchPath, err)
+			case tc.expectSuccess && path != program:
+				t.Errorf("looking for fuse-overlayfs in %q, expecting %q, got %q", tc.searchPath, program, path)
+			case !tc.expectSuccess && err == nil:
+				t.Errorf("unexpected result looking for fuse-overlayfs in %q, got path = %s", tc.searchPath, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package delta computes binary deltas between a base file and a target,
+// and reconstructs the target from the base and a delta.
+//
+// The blocks of the base are matched at any offset of the target with a
+// rolling checksum, confirmed by a SHA256 sum, like rsync does, so that
+// data inserted or removed in the target doesn't prevent the following
+// blocks from being matched. A delta starts with a magic followed by
+// operations, each one being a byte identifying it followed by its
+// unsigned varint encoded arguments:
+//
+//	'C' offset length   copies length bytes of the base at offset
+//	'D' length data     writes the length bytes of data following it
+//	'E'                 ends the delta
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	opCopy = 'C'
+	opData = 'D'
+	opEnd  = 'E'
+)
+
+// magic identifies a delta and its format version.
+var magic = []byte("SDELTA01")
+
+// blockSize is the size of the base blocks matched in the target.
+var blockSize = 64 * 1024
+
+// maxData is the maximum length of the data of an operation.
+const maxData = 1024 * 1024
+
+// ErrInvalidDelta is returned when a delta is malformed or doesn't
+// correspond to the base it's applied to.
+var ErrInvalidDelta = errors.New("invalid delta")
+
+// rollingSum is the rsync rolling checksum of a block.
+type rollingSum struct {
+	a, b uint32
+	n    uint32
+}
+
+// newRollingSum returns the rolling checksum of block.
+func newRollingSum(block []byte) rollingSum {
+	s := rollingSum{n: uint32(len(block))}
+	for i, c := range block {
+		s.a += uint32(c)
+		s.b += uint32(len(block)-i) * uint32(c)
+	}
+	return s
+}
+
+// roll removes the byte out leading the block and appends the byte in.
+func (s *rollingSum) roll(out, in byte) {
+	s.a += uint32(in) - uint32(out)
+	s.b += s.a - s.n*uint32(out)
+}
+
+// sum returns the value of the checksum.
+func (s rollingSum) sum() uint32 {
+	return s.a&0xffff | s.b<<16
+}
+
+// blockIndex maps the rolling checksum of the blocks of the base to
+// their SHA256 sums and offsets.
+type blockIndex map[uint32][]struct {
+	sum    [sha256.Size]byte
+	offset int64
+}
+
+// newBlockIndex returns the index of the full blocks of base.
+func newBlockIndex(base io.ReaderAt, baseSize int64) (blockIndex, error) {
+	index := make(blockIndex)
+	block := make([]byte, blockSize)
+
+	for offset := int64(0); offset+int64(blockSize) <= baseSize; offset += int64(blockSize) {
+		if _, err := base.ReadAt(block, offset); err != nil {
+			return nil, fmt.Errorf("while reading base: %s", err)
+		}
+		weak := newRollingSum(block).sum()
+		index[weak] = append(index[weak], struct {
+			sum    [sha256.Size]byte
+			offset int64
+		}{sha256.Sum256(block), offset})
+	}
+	return index, nil
+}
+
+// lookup returns the offset in the base of block, if found.
+func (index blockIndex) lookup(weak uint32, block []byte) (int64, bool) {
+	candidates, ok := index[weak]
+	if !ok {
+		return 0, false
+	}
+	sum := sha256.Sum256(block)
+	for _, c := range candidates {
+		if c.sum == sum {
+			return c.offset, true
+		}
+	}
+	return 0, false
+}
+
+// encoder writes the operations of a delta, merging the contiguous
+// copies and buffering the data.
+type encoder struct {
+	w       *bufio.Writer
+	data    []byte
+	copyOff int64
+	copyLen int64
+}
+
+func (e *encoder) writeOp(op byte, args ...uint64) error {
+	buf := make([]byte, 1, 1+len(args)*binary.MaxVarintLen64)
+	buf[0] = op
+	for _, a := range args {
+		buf = buf[:len(buf)+binary.PutUvarint(buf[len(buf):cap(buf)], a)]
+	}
+	_, err := e.w.Write(buf)
+	return err
+}
+
+func (e *encoder) flushCopy() error {
+	if e.copyLen == 0 {
+		return nil
+	}
+	err := e.writeOp(opCopy, uint64(e.copyOff), uint64(e.copyLen))
+	e.copyLen = 0
+	return err
+}
+
+func (e *encoder) flushData() error {
+	if len(e.data) == 0 {
+		return nil
+	}
+	if err := e.writeOp(opData, uint64(len(e.data))); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.data)
+	e.data = e.data[:0]
+	return err
+}
+
+func (e *encoder) copy(offset, length int64) error {
+	if err := e.flushData(); err != nil {
+		return err
+	}
+	if e.copyLen > 0 && e.copyOff+e.copyLen == offset {
+		e.copyLen += length
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	e.copyOff, e.copyLen = offset, length
+	return nil
+}
+
+func (e *encoder) write(data []byte) error {
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := maxData - len(e.data)
+		if n > len(data) {
+			n = len(data)
+		}
+		e.data = append(e.data, data[:n]...)
+		data = data[n:]
+		if len(e.data) == maxData {
+			if err := e.flushData(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Compute writes to w the delta reconstructing target from the base of
+// baseSize bytes.
+func Compute(w io.Writer, base io.ReaderAt, baseSize int64, target io.Reader) error {
+	index, err := newBlockIndex(base, baseSize)
+	if err != nil {
+		return err
+	}
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	if _, err := e.w.Write(magic); err != nil {
+		return err
+	}
+
+	// buf holds the target data being matched from i, the window
+	// being the block starting at i whose rolling checksum is sum
+	buf := make([]byte, 0, 4*blockSize)
+	i := 0
+	eof := false
+	var sum rollingSum
+	rolling := false
+
+	for {
+		// the window and the byte following it must be available
+		if !eof && len(buf)-i <= blockSize {
+			n := copy(buf[:cap(buf)], buf[i:])
+			buf, i = buf[:n], 0
+			for !eof && len(buf) < cap(buf) {
+				n, err := target.Read(buf[len(buf):cap(buf)])
+				buf = buf[:len(buf)+n]
+				if err == io.EOF {
+					eof = true
+				} else if err != nil {
+					return fmt.Errorf("while reading target: %s", err)
+				}
+			}
+		}
+
+		if len(buf)-i < blockSize {
+			// the tail can't match a full block
+			if err := e.write(buf[i:]); err != nil {
+				return err
+			}
+			break
+		}
+
+		window := buf[i : i+blockSize]
+		if !rolling {
+			sum = newRollingSum(window)
+			rolling = true
+		}
+		if offset, ok := index.lookup(sum.sum(), window); ok {
+			if err := e.copy(offset, int64(blockSize)); err != nil {
+				return err
+			}
+			i += blockSize
+			rolling = false
+			continue
+		}
+
+		if err := e.write(buf[i : i+1]); err != nil {
+			return err
+		}
+		if i+blockSize < len(buf) {
+			sum.roll(buf[i], buf[i+blockSize])
+		} else {
+			rolling = false
+		}
+		i++
+	}
+
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	if err := e.flushData(); err != nil {
+		return err
+	}
+	if err := e.writeOp(opEnd); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Apply writes to w the target reconstructed from the base of baseSize
+// bytes and delta. An error wrapping ErrInvalidDelta is returned when
+// delta is malformed or refers to data beyond the base.
+func Apply(w io.Writer, base io.ReaderAt, baseSize int64, delta io.Reader) error {
+	r := bufio.NewReader(delta)
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header, magic) {
+		return fmt.Errorf("%w: bad magic", ErrInvalidDelta)
+	}
+
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: truncated", ErrInvalidDelta)
+		}
+
+		switch op {
+		case opCopy:
+			offset, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("%w: truncated copy", ErrInvalidDelta)
+			}
+			length, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("%w: truncated copy", ErrInvalidDelta)
+			}
+			if offset > uint64(baseSize) || length > uint64(baseSize)-offset {
+				return fmt.Errorf("%w: copy of %d bytes at offset %d beyond the base", ErrInvalidDelta, length, offset)
+			}
+			if _, err := io.Copy(w, io.NewSectionReader(base, int64(offset), int64(length))); err != nil {
+				return fmt.Errorf("while copying base data: %s", err)
+			}
+		case opData:
+			length, err := binary.ReadUvarint(r)
+			if err != nil || length > maxData {
+				return fmt.Errorf("%w: bad data length", ErrInvalidDelta)
+			}
+			if n, err := io.CopyN(w, r, int64(length)); err != nil {
+				if n < int64(length) && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+					return fmt.Errorf("%w: truncated data", ErrInvalidDelta)
+				}
+				return fmt.Errorf("while writing data: %s", err)
+			}
+		case opEnd:
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown operation %q", ErrInvalidDelta, op)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func randomData(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestComputeApply(t *testing.T) {
+	defer func(size int) { blockSize = size }(blockSize)
+	blockSize = 64
+
+	r := rand.New(rand.NewSource(1))
+	base := randomData(r, 64*100+10)
+	inserted := randomData(r, 100)
+
+	tests := []struct {
+		name   string
+		target []byte
+		// maxSize is the maximum size of the delta
+		maxSize int
+	}{
+		{name: "Identical", target: base, maxSize: 64},
+		{name: "Empty", target: nil, maxSize: 16},
+		{name: "EmptyBase", target: inserted, maxSize: 128},
+		{name: "Appended", target: concat(base, inserted), maxSize: 256},
+		{name: "Inserted", target: concat(base[:1000], inserted, base[1000:]), maxSize: 256},
+		{name: "Removed", target: concat(base[:1000], base[3000:]), maxSize: 256},
+		{name: "Modified", target: concat(base[:5000], []byte{^base[5000]}, base[5001:]), maxSize: 256},
+		{name: "Different", target: randomData(r, 3000), maxSize: 3100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := base
+			if tt.name == "EmptyBase" {
+				b = nil
+			}
+
+			var delta bytes.Buffer
+			if err := Compute(&delta, bytes.NewReader(b), int64(len(b)), bytes.NewReader(tt.target)); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if delta.Len() > tt.maxSize {
+				t.Errorf("got delta of %d bytes, expected at most %d", delta.Len(), tt.maxSize)
+			}
+
+			var target bytes.Buffer
+			if err := Apply(&target, bytes.NewReader(b), int64(len(b)), &delta); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(target.Bytes(), tt.target) {
+				t.Errorf("reconstructed target differs")
+			}
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	defer func(size int) { blockSize = size }(blockSize)
+	blockSize = 64
+
+	r := rand.New(rand.NewSource(2))
+	base := randomData(r, 64*10)
+	target := concat(base[100:], randomData(r, 10))
+
+	var delta bytes.Buffer
+	if err := Compute(&delta, bytes.NewReader(base), int64(len(base)), bytes.NewReader(target)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	valid := delta.Bytes()
+
+	tests := []struct {
+		name  string
+		base  []byte
+		delta []byte
+	}{
+		{name: "BadMagic", base: base, delta: append([]byte("XDELTA01"), valid[len(magic):]...)},
+		{name: "Truncated", base: base, delta: valid[:len(valid)-1]},
+		{name: "TruncatedData", base: base, delta: valid[:len(valid)-5]},
+		{name: "UnknownOperation", base: base, delta: append(append([]byte{}, magic...), 'X')},
+		{name: "ShortBase", base: base[:64], delta: valid},
+		{name: "CopyOverflow", base: base, delta: append(append([]byte{}, magic...), opCopy, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 0x01, opEnd)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target bytes.Buffer
+			err := Apply(&target, bytes.NewReader(tt.base), int64(len(tt.base)), bytes.NewReader(tt.delta))
+			if !errors.Is(err, ErrInvalidDelta) {
+				t.Errorf("got error %v, expected %v", err, ErrInvalidDelta)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@
 package env
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/config/oci/generate"
@@ -31,6 +33,68 @@ var alwaysOmitKeys = map[string]struct{}{
 	"ld_library_path": {},
 }
 
+// HostEnvFilter selects the host environment variables inherited
+// by the container with glob patterns matched against their names
+// (eg: AWS_*), the variables setting the container environment
+// (SINGULARITYENV_*) are not concerned.
+type HostEnvFilter struct {
+	// Allow are the patterns of the variables inherited, all
+	// the variables are inherited when empty.
+	Allow []string
+	// Deny are the patterns of the variables never inherited,
+	// they take precedence over Allow.
+	Deny []string
+}
+
+// Check returns an error if a pattern of the filter is malformed.
+func (f HostEnvFilter) Check() error {
+	for _, pattern := range append(f.Allow[:len(f.Allow):len(f.Allow)], f.Deny...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid environment variable pattern %q: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+// inherited returns whether the host environment variable key
+// passes the filter.
+func (f HostEnvFilter) inherited(key string) bool {
+	if matchAny(f.Deny, key) {
+		return false
+	}
+	return len(f.Allow) == 0 || matchAny(f.Allow, key)
+}
+
+// matchAny returns whether key matches one of the patterns.
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterHostEnv returns the host environment variables hostEnvs
+// passing the filter f, the variables dropped are reported at
+// debug level.
+func FilterHostEnv(hostEnvs []string, f HostEnvFilter) []string {
+	if len(f.Allow) == 0 && len(f.Deny) == 0 {
+		return hostEnvs
+	}
+
+	envs := make([]string, 0, len(hostEnvs))
+	for _, env := range hostEnvs {
+		key := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(key, "SINGULARITYENV_") && !f.inherited(key) {
+			sylog.Debugf("Not forwarding %s from host to container environment", key)
+			continue
+		}
+		envs = append(envs, env)
+	}
+	return envs
+}
+
 // SetContainerEnv cleans environment variables before running the container.
 func SetContainerEnv(g *generate.Generator, hostEnvs []string, cleanEnv bool, homeDest string) {
 	for _, env := range hostEnvs {
@@ -198,6 +198,70 @@ func TestSetContainerEnv(t *testing.T) {
 	}
 }
 
+func TestFilterHostEnv(t *testing.T) {
+	hostEnvs := []string{
+		"TERM=xterm",
+		"LC_ALL=C",
+		"LC_TIME=C",
+		"AWS_SECRET_ACCESS_KEY=secret",
+		"GITHUB_TOKEN=token",
+		"SINGULARITYENV_AWS_REGION=eu-west-1",
+	}
+
+	tt := []struct {
+		name      string
+		filter    HostEnvFilter
+		resultEnv []string
+	}{
+		{
+			name:      "no filter",
+			resultEnv: hostEnvs,
+		},
+		{
+			name:   "deny",
+			filter: HostEnvFilter{Deny: []string{"AWS_*", "*_TOKEN"}},
+			resultEnv: []string{
+				"TERM=xterm",
+				"LC_ALL=C",
+				"LC_TIME=C",
+				"SINGULARITYENV_AWS_REGION=eu-west-1",
+			},
+		},
+		{
+			name:   "allow",
+			filter: HostEnvFilter{Allow: []string{"TERM", "LC_*"}},
+			resultEnv: []string{
+				"TERM=xterm",
+				"LC_ALL=C",
+				"LC_TIME=C",
+				"SINGULARITYENV_AWS_REGION=eu-west-1",
+			},
+		},
+		{
+			name:   "deny takes precedence",
+			filter: HostEnvFilter{Allow: []string{"LC_*", "AWS_*"}, Deny: []string{"LC_TIME", "AWS_*"}},
+			resultEnv: []string{
+				"LC_ALL=C",
+				"SINGULARITYENV_AWS_REGION=eu-west-1",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.filter.Check(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if envs := FilterHostEnv(hostEnvs, tc.filter); !equal(t, envs, tc.resultEnv) {
+				t.Fatalf("unexpected envs:\n want: %v\ngot: %v", tc.resultEnv, envs)
+			}
+		})
+	}
+
+	if err := (HostEnvFilter{Deny: []string{"AWS_["}}).Check(); err == nil {
+		t.Errorf("unexpected success with a malformed pattern")
+	}
+}
+
 // equal tells whether a and b contain the same elements in the
 // same order. A nil argument is equivalent to an empty slice.
 func equal(t *testing.T, a, b []string) bool {
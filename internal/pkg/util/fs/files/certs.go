@@ -0,0 +1,130 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
+)
+
+const (
+	// debianCertsBundle is the CA certificates bundle location of
+	// Debian, Ubuntu, Alpine, Arch and Gentoo based distributions
+	debianCertsBundle = "/etc/ssl/certs/ca-certificates.crt"
+	// redhatCertsBundle is the CA certificates bundle location of
+	// Fedora, RHEL and CentOS based distributions
+	redhatCertsBundle = "/etc/pki/tls/certs/ca-bundle.crt"
+	// suseCertsBundle is the CA certificates bundle location of
+	// openSUSE and SLES based distributions
+	suseCertsBundle = "/etc/ssl/ca-bundle.pem"
+)
+
+// certsBundles lists the usual CA certificates bundle locations
+var certsBundles = []string{
+	debianCertsBundle,
+	redhatCertsBundle,
+	suseCertsBundle,
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// distribution IDs as found in os-release files mapped
+// to their CA certificates bundle location
+var distroCertsBundles = map[string]string{
+	"debian":   debianCertsBundle,
+	"ubuntu":   debianCertsBundle,
+	"alpine":   debianCertsBundle,
+	"arch":     debianCertsBundle,
+	"gentoo":   debianCertsBundle,
+	"fedora":   redhatCertsBundle,
+	"rhel":     redhatCertsBundle,
+	"centos":   redhatCertsBundle,
+	"suse":     suseCertsBundle,
+	"opensuse": suseCertsBundle,
+	"sles":     suseCertsBundle,
+}
+
+// isCertsBundle returns whether path is a non empty file
+func isCertsBundle(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() > 0
+}
+
+// HostCertsBundle returns the path of the host CA certificates bundle.
+func HostCertsBundle() (string, error) {
+	for _, path := range certsBundles {
+		if isCertsBundle(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no CA certificates bundle found on the host")
+}
+
+// ContainerCertsBundle returns the path of the CA certificates bundle
+// for the container root filesystem rootfs and whether a bundle is
+// already present. When the container has no bundle, the path is
+// chosen according to the distribution found in the container.
+func ContainerCertsBundle(rootfs string) (string, bool) {
+	for _, path := range certsBundles {
+		if isCertsBundle(filepath.Join(rootfs, fs.EvalRelative(path, rootfs))) {
+			return path, true
+		}
+	}
+
+	for _, id := range containerDistroIDs(rootfs) {
+		if path, ok := distroCertsBundles[id]; ok {
+			return path, false
+		}
+	}
+
+	switch {
+	case fs.IsFile(filepath.Join(rootfs, "/etc/redhat-release")):
+		return redhatCertsBundle, false
+	case fs.IsFile(filepath.Join(rootfs, "/etc/SuSE-release")):
+		return suseCertsBundle, false
+	}
+	return debianCertsBundle, false
+}
+
+// containerDistroIDs returns the ID followed by the ID_LIKE values
+// of the os-release file of the container root filesystem rootfs.
+func containerDistroIDs(rootfs string) []string {
+	var ids []string
+
+	for _, p := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		f, err := os.Open(filepath.Join(rootfs, fs.EvalRelative(p, rootfs)))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		var like []string
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.ToLower(strings.Trim(kv[1], `"'`))
+			switch kv[0] {
+			case "ID":
+				ids = append(ids, value)
+			case "ID_LIKE":
+				like = strings.Fields(value)
+			}
+		}
+		return append(ids, like...)
+	}
+
+	return ids
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/test"
@@ -114,3 +115,155 @@ func TestResolvConf(t *testing.T) {
 		t.Errorf("ResolvConf returns a bad content")
 	}
 }
+
+func TestCheckResolvConf(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{
+			name:    "Nameserver",
+			content: "nameserver 10.0.0.53\n",
+		},
+		{
+			name:    "Full",
+			content: "# internal resolver\n; comment\n\nsearch example.org corp.example.org\nnameserver 10.0.0.53\nnameserver fe80::1%eth0\noptions ndots:2 timeout:1\n",
+		},
+		{
+			name:        "Empty",
+			content:     "",
+			expectError: true,
+		},
+		{
+			name:        "NoNameserver",
+			content:     "search example.org\n",
+			expectError: true,
+		},
+		{
+			name:        "BadNameserver",
+			content:     "nameserver dns.example.org\n",
+			expectError: true,
+		},
+		{
+			name:        "MissingArgument",
+			content:     "nameserver 10.0.0.53\nsearch\n",
+			expectError: true,
+		},
+		{
+			name:        "UnknownKeyword",
+			content:     "nameserver 10.0.0.53\nresolver 10.0.0.54\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckResolvConf([]byte(tt.content))
+			if err != nil && !tt.expectError {
+				t.Errorf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestContainerCertsBundle(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		symlinks map[string]string
+		expected string
+		exists   bool
+	}{
+		{
+			name:     "Empty",
+			expected: "/etc/ssl/certs/ca-certificates.crt",
+		},
+		{
+			name:     "Debian",
+			files:    map[string]string{"/etc/ssl/certs/ca-certificates.crt": "CERTS"},
+			expected: "/etc/ssl/certs/ca-certificates.crt",
+			exists:   true,
+		},
+		{
+			name:     "CentOS",
+			files:    map[string]string{"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem": "CERTS"},
+			expected: "/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+			exists:   true,
+		},
+		{
+			name:     "EmptyBundle",
+			files:    map[string]string{"/etc/ssl/certs/ca-certificates.crt": "", "/etc/os-release": "ID=fedora\n"},
+			expected: "/etc/pki/tls/certs/ca-bundle.crt",
+		},
+		{
+			name:     "SymlinkInContainer",
+			files:    map[string]string{"/etc/pki/tls/certs/bundle": "CERTS"},
+			symlinks: map[string]string{"/etc/pki/tls/certs/ca-bundle.crt": "/etc/pki/tls/certs/bundle"},
+			expected: "/etc/pki/tls/certs/ca-bundle.crt",
+			exists:   true,
+		},
+		{
+			name:     "DanglingSymlink",
+			files:    map[string]string{"/etc/os-release": "ID=\"opensuse-leap\"\nID_LIKE=\"suse opensuse\"\n"},
+			symlinks: map[string]string{"/etc/ssl/cert.pem": "/nonexistent"},
+			expected: "/etc/ssl/ca-bundle.pem",
+		},
+		{
+			name:     "OsReleaseID",
+			files:    map[string]string{"/etc/os-release": "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\n"},
+			expected: "/etc/ssl/certs/ca-certificates.crt",
+		},
+		{
+			name:     "OsReleaseIDLike",
+			files:    map[string]string{"/usr/lib/os-release": "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n"},
+			expected: "/etc/pki/tls/certs/ca-bundle.crt",
+		},
+		{
+			name:     "RedhatRelease",
+			files:    map[string]string{"/etc/redhat-release": "CentOS release 6.10 (Final)\n"},
+			expected: "/etc/pki/tls/certs/ca-bundle.crt",
+		},
+		{
+			name:     "Unknown",
+			files:    map[string]string{"/etc/os-release": "ID=unknown\n"},
+			expected: "/etc/ssl/certs/ca-certificates.crt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs, err := ioutil.TempDir("", "certs-rootfs-")
+			if err != nil {
+				t.Fatalf("while creating temporary directory: %s", err)
+			}
+			defer os.RemoveAll(rootfs)
+
+			for path, content := range tt.files {
+				path = filepath.Join(rootfs, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("while creating directory: %s", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("while writing file: %s", err)
+				}
+			}
+			for path, target := range tt.symlinks {
+				path = filepath.Join(rootfs, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("while creating directory: %s", err)
+				}
+				if err := os.Symlink(target, path); err != nil {
+					t.Fatalf("while creating symlink: %s", err)
+				}
+			}
+
+			path, exists := ContainerCertsBundle(rootfs)
+			if path != tt.expected || exists != tt.exists {
+				t.Errorf("got %s (exists: %v), expected %s (exists: %v)", path, exists, tt.expected, tt.exists)
+			}
+		})
+	}
+}
@@ -6,8 +6,11 @@
 package files
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 )
@@ -27,3 +30,46 @@ func ResolvConf(dns []string) (content []byte, err error) {
 	}
 	return content, nil
 }
+
+// CheckResolvConf checks that content is a valid resolv.conf content
+// defining at least one name server, as described by resolv.conf(5).
+func CheckResolvConf(content []byte) error {
+	nameservers := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch keyword, args := fields[0], fields[1:]; keyword {
+		case "nameserver":
+			if len(args) != 1 {
+				return fmt.Errorf("line %d: nameserver requires an IP address", n)
+			}
+			// IPv6 link-local addresses may have a zone
+			ip := strings.SplitN(args[0], "%", 2)[0]
+			if net.ParseIP(ip) == nil {
+				return fmt.Errorf("line %d: nameserver %s is not a valid IP address", n, args[0])
+			}
+			nameservers++
+		case "domain", "search", "sortlist", "options":
+			if len(args) == 0 {
+				return fmt.Errorf("line %d: %s requires an argument", n, keyword)
+			}
+		default:
+			return fmt.Errorf("line %d: unknown keyword %q", n, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if nameservers == 0 {
+		return fmt.Errorf("no nameserver defined")
+	}
+
+	return nil
+}
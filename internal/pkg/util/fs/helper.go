@@ -258,10 +258,13 @@ func Touch(path string) error {
 }
 
 // MakeTmpDir creates a temporary directory with provided mode
-// in os.TempDir if basedir is "". This function assumes that
+// in TempDir if basedir is "". This function assumes that
 // basedir exists, so it's the caller's responsibility to create
 // it before calling it.
 func MakeTmpDir(basedir, pattern string, mode os.FileMode) (string, error) {
+	if basedir == "" {
+		basedir = TempDir()
+	}
 	name, err := ioutil.TempDir(basedir, pattern)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary directory: %s", err)
@@ -273,10 +276,13 @@ func MakeTmpDir(basedir, pattern string, mode os.FileMode) (string, error) {
 }
 
 // MakeTmpFile creates a temporary file with provided mode
-// in os.TempDir if basedir is "". This function assumes that
+// in TempDir if basedir is "". This function assumes that
 // basedir exists, so it's the caller's responsibility to create
 // it before calling it.
 func MakeTmpFile(basedir, pattern string, mode os.FileMode) (*os.File, error) {
+	if basedir == "" {
+		basedir = TempDir()
+	}
 	f, err := ioutil.TempFile(basedir, pattern)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary file: %s", err)
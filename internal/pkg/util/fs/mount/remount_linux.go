@@ -0,0 +1,160 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sylabs/singularity/pkg/util/capabilities"
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+)
+
+const (
+	// ErrNotMountPoint indicates a remount of a path which is not a mount point
+	ErrNotMountPoint = mountError("path is not a mount point")
+	// ErrRemountEscalation indicates a remount dropping a security restriction
+	ErrRemountEscalation = mountError("remount would drop a security restriction")
+)
+
+// mountInfoPath is the mountinfo file of the current mount namespace.
+var mountInfoPath = "/proc/self/mountinfo"
+
+// atimeFlags are the mutually exclusive access time update flags.
+const atimeFlags = syscall.MS_NOATIME | syscall.MS_RELATIME | syscall.MS_STRICTATIME
+
+// remountOptions are the options accepted by Remount, with the flag
+// they set and the flag they clear. Options clearing nosuid, nodev or
+// noexec are rejected when the flag is set on the mount point.
+var remountOptions = map[string]struct {
+	set   uintptr
+	clear uintptr
+}{
+	"ro":          {syscall.MS_RDONLY, 0},
+	"rw":          {0, syscall.MS_RDONLY},
+	"nosuid":      {syscall.MS_NOSUID, 0},
+	"suid":        {0, syscall.MS_NOSUID},
+	"nodev":       {syscall.MS_NODEV, 0},
+	"dev":         {0, syscall.MS_NODEV},
+	"noexec":      {syscall.MS_NOEXEC, 0},
+	"exec":        {0, syscall.MS_NOEXEC},
+	"nodiratime":  {syscall.MS_NODIRATIME, 0},
+	"diratime":    {0, syscall.MS_NODIRATIME},
+	"noatime":     {syscall.MS_NOATIME, atimeFlags},
+	"relatime":    {syscall.MS_RELATIME, atimeFlags},
+	"strictatime": {syscall.MS_STRICTATIME, atimeFlags},
+}
+
+// restrictionFlags are the flags a remount can't clear.
+const restrictionFlags = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC
+
+// mountInfoFlags returns the per mount point flags of the mountinfo
+// options of a mount point.
+func mountInfoFlags(options []string) uintptr {
+	var flags uintptr = syscall.MS_STRICTATIME
+
+	for _, o := range options {
+		if o == "rw" || o == "strictatime" {
+			continue
+		}
+		if opt, ok := remountOptions[o]; ok && opt.set != 0 {
+			flags &^= opt.clear
+			flags |= opt.set
+		}
+	}
+	return flags
+}
+
+// remountFlags returns the flags of the mount point with the current
+// mountinfo options once modified by options. The flags not changed by
+// options are preserved as the kernel refuses to change the flags
+// locked by a user namespace.
+func remountFlags(current []string, options []string) (uintptr, error) {
+	flags := mountInfoFlags(current)
+
+	for _, o := range options {
+		o = strings.TrimSpace(o)
+		opt, ok := remountOptions[o]
+		if !ok {
+			return 0, fmt.Errorf("mount option %q not supported for remount", o)
+		}
+		if opt.clear&restrictionFlags&flags != 0 {
+			return 0, fmt.Errorf("%w: %s", ErrRemountEscalation, o)
+		}
+		flags &^= opt.clear
+		flags |= opt.set
+	}
+	return flags, nil
+}
+
+// findMountPoint returns the topmost mountinfo entry mounted on path.
+func findMountPoint(path string, entries []proc.MountInfoEntry) (*proc.MountInfoEntry, error) {
+	var entry *proc.MountInfoEntry
+
+	for i := range entries {
+		if entries[i].Point == path {
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotMountPoint, path)
+	}
+	return entry, nil
+}
+
+// Remount changes the flags of the mount point path of the current mount
+// namespace with options, like "rw" to make a read-only bind mount
+// writable and "ro" to restore it. Only the ro, rw, nosuid, nodev, noexec,
+// atime related options and their negation are accepted, the other flags
+// of the mount point are preserved. Remount requires CAP_SYS_ADMIN and
+// never clears nosuid, nodev or noexec. Flags locked by the kernel, like
+// a read-only bind mount inherited from a less privileged user namespace,
+// can't be changed either.
+func Remount(path string, options []string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path %s must be an absolute path", path)
+	}
+	if len(options) == 0 {
+		return fmt.Errorf("no remount option specified")
+	}
+
+	p, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("while resolving path %s: %s", path, err)
+	}
+
+	entries, err := proc.GetMountInfoEntry(mountInfoPath)
+	if err != nil {
+		return fmt.Errorf("while reading mount points: %s", err)
+	}
+	entry, err := findMountPoint(p, entries)
+	if err != nil {
+		return err
+	}
+
+	flags, err := remountFlags(entry.Options, options)
+	if err != nil {
+		return err
+	}
+
+	caps, err := capabilities.GetProcessEffective()
+	if err != nil {
+		return err
+	}
+	if caps&(uint64(1)<<capabilities.Map["CAP_SYS_ADMIN"].Value) == 0 {
+		return fmt.Errorf("remount of %s requires CAP_SYS_ADMIN", p)
+	}
+
+	err = syscall.Mount("", p, "", syscall.MS_REMOUNT|syscall.MS_BIND|flags, "")
+	if err == syscall.EPERM {
+		return fmt.Errorf("remount of %s not permitted, the mount flags may be locked: %s", p, err)
+	} else if err != nil {
+		return fmt.Errorf("while remounting %s: %s", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package mount
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/util/fs/proc"
+)
+
+func TestRemountFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		options []string
+		flags   uintptr
+		wantErr error
+	}{
+		{
+			name:    "DropReadOnly",
+			current: []string{"ro", "nosuid", "nodev", "relatime"},
+			options: []string{"rw"},
+			flags:   syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_RELATIME,
+		},
+		{
+			name:    "SetReadOnly",
+			current: []string{"rw", "nosuid", "relatime"},
+			options: []string{"ro"},
+			flags:   syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_RELATIME,
+		},
+		{
+			name:    "StrictAtime",
+			current: []string{"rw"},
+			options: []string{"noexec"},
+			flags:   syscall.MS_NOEXEC | syscall.MS_STRICTATIME,
+		},
+		{
+			name:    "Atime",
+			current: []string{"rw", "relatime"},
+			options: []string{"noatime"},
+			flags:   syscall.MS_NOATIME,
+		},
+		{
+			name:    "DropNosuid",
+			current: []string{"ro", "nosuid"},
+			options: []string{"rw", "suid"},
+			wantErr: ErrRemountEscalation,
+		},
+		{
+			name:    "DropNodev",
+			current: []string{"rw", "nodev"},
+			options: []string{"dev"},
+			wantErr: ErrRemountEscalation,
+		},
+		{
+			name:    "ExecNotRestricted",
+			current: []string{"rw", "relatime"},
+			options: []string{"exec"},
+			flags:   syscall.MS_RELATIME,
+		},
+		{
+			name:    "Unsupported",
+			current: []string{"rw"},
+			options: []string{"bind"},
+			wantErr: errors.New(""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := remountFlags(tt.current, tt.options)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("got err %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrRemountEscalation && !errors.Is(err, ErrRemountEscalation) {
+				t.Fatalf("got err %v, expected %v", err, ErrRemountEscalation)
+			}
+			if flags != tt.flags {
+				t.Errorf("got flags %#x, expected %#x", flags, tt.flags)
+			}
+		})
+	}
+}
+
+func TestFindMountPoint(t *testing.T) {
+	entries := []proc.MountInfoEntry{
+		{ID: "1", Point: "/"},
+		{ID: "2", Point: "/data", Options: []string{"ro"}},
+		{ID: "3", Point: "/data", Options: []string{"rw"}},
+	}
+
+	entry, err := findMountPoint("/data", entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if entry.ID != "3" {
+		t.Errorf("got mount point %s, expected the topmost one", entry.ID)
+	}
+
+	if _, err := findMountPoint("/data/dir", entries); !errors.Is(err, ErrNotMountPoint) {
+		t.Errorf("got err %v, expected %v", err, ErrNotMountPoint)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = map[string]uint64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+}
+
+// ParseSize parses a size in bytes with an optional binary unit
+// suffix (k, m, g or t, case insensitive, optionally followed by
+// "b" or "ib"), like "512m" or "2GiB", and returns it in bytes.
+// A zero size is rejected.
+func ParseSize(s string) (uint64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit := str[i:]
+	switch {
+	case unit == "b":
+		unit = ""
+	case len(unit) == 2 && unit[1] == 'b':
+		unit = unit[:1]
+	case len(unit) == 3 && unit[1:] == "ib":
+		unit = unit[:1]
+	}
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+
+	n, err := strconv.ParseUint(str[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %s", s, err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("size %q must be greater than zero", s)
+	}
+	if n > ^uint64(0)/mult {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+
+	return n * mult, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fs
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		size       string
+		expected   uint64
+		shouldFail bool
+	}{
+		{size: "1024", expected: 1024},
+		{size: "512b", expected: 512},
+		{size: "4k", expected: 4 << 10},
+		{size: "512m", expected: 512 << 20},
+		{size: "2g", expected: 2 << 30},
+		{size: "2G", expected: 2 << 30},
+		{size: "2GB", expected: 2 << 30},
+		{size: "2GiB", expected: 2 << 30},
+		{size: " 1t ", expected: 1 << 40},
+		{size: "", shouldFail: true},
+		{size: "g", shouldFail: true},
+		{size: "0", shouldFail: true},
+		{size: "-1g", shouldFail: true},
+		{size: "1.5g", shouldFail: true},
+		{size: "2x", shouldFail: true},
+		{size: "2ib", shouldFail: true},
+		{size: "99999999999999999999", shouldFail: true},
+		{size: "17179869184g", shouldFail: true},
+	}
+
+	for _, tc := range cases {
+		size, err := ParseSize(tc.size)
+		if tc.shouldFail {
+			if err == nil {
+				t.Errorf("unexpected success for %q: got %d", tc.size, size)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tc.size, err)
+		} else if size != tc.expected {
+			t.Errorf("got %d for %q, expected %d", size, tc.size, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fs
+
+import (
+	"os"
+	"sync"
+)
+
+var tempDir struct {
+	sync.Mutex
+	path string
+}
+
+// SetTempDir sets the directory returned by TempDir, overriding
+// the environment. An empty dir restores the environment lookup.
+func SetTempDir(dir string) {
+	tempDir.Lock()
+	defer tempDir.Unlock()
+
+	tempDir.path = dir
+}
+
+// TempDir returns the directory to use for temporary files: the one
+// set with SetTempDir, otherwise SINGULARITY_TMPDIR, otherwise TMPDIR,
+// and /tmp when none is set.
+func TempDir() string {
+	tempDir.Lock()
+	defer tempDir.Unlock()
+
+	if tempDir.path != "" {
+		return tempDir.path
+	}
+	if dir := os.Getenv("SINGULARITY_TMPDIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package fs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	for _, env := range []string{"SINGULARITY_TMPDIR", "TMPDIR"} {
+		if v, ok := os.LookupEnv(env); ok {
+			defer os.Setenv(env, v)
+		} else {
+			defer os.Unsetenv(env)
+		}
+	}
+	defer SetTempDir("")
+
+	cases := []struct {
+		name        string
+		set         string
+		singularity string
+		tmpdir      string
+		expected    string
+	}{
+		{name: "Default", expected: "/tmp"},
+		{name: "TMPDIR", tmpdir: "/scratch", expected: "/scratch"},
+		{name: "SINGULARITY_TMPDIR", singularity: "/data/tmp", tmpdir: "/scratch", expected: "/data/tmp"},
+		{name: "Set", set: "/var/tmp", singularity: "/data/tmp", tmpdir: "/scratch", expected: "/var/tmp"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Setenv("SINGULARITY_TMPDIR", tc.singularity)
+			os.Setenv("TMPDIR", tc.tmpdir)
+			SetTempDir(tc.set)
+
+			if dir := TempDir(); dir != tc.expected {
+				t.Errorf("got temporary directory %q, expected %q", dir, tc.expected)
+			}
+		})
+	}
+}
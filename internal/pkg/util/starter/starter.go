@@ -16,6 +16,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	"github.com/sylabs/singularity/pkg/util/copy"
 	"golang.org/x/sys/unix"
 )
 
@@ -41,6 +42,26 @@ func WithStderr(stderr io.Writer) CommandOp {
 	}
 }
 
+// WithOutputStreams allows to route the output and error streams of
+// starter command, and so those of the container, to separate writers.
+// Unless timeFormat is empty, each line is prefixed with the time at
+// which it was written formatted with timeFormat (eg: time.RFC3339Nano).
+// Output streams are ignored for Exec as it uses the caller streams.
+func WithOutputStreams(stdout, stderr io.Writer, timeFormat string) CommandOp {
+	return func(c *Command) {
+		c.stdout = stdout
+		c.stderr = stderr
+		if timeFormat != "" {
+			if stdout != nil {
+				c.stdout = copy.NewTimestampWriter(stdout, timeFormat)
+			}
+			if stderr != nil {
+				c.stderr = copy.NewTimestampWriter(stderr, timeFormat)
+			}
+		}
+	}
+}
+
 // WithStdin allows to pass a custom input stream to starter
 // command. Input stream is ignored for Exec as it uses the
 // caller stream.
@@ -137,7 +158,7 @@ func (c *Command) init(config *config.Common, ops ...CommandOp) error {
 		return fmt.Errorf("while sending configuration data: %s", err)
 	}
 
-	env := []string{sylog.GetEnvVar(), fmt.Sprintf("PIPE_EXEC_FD=%d", pipeFd)}
+	env := []string{sylog.GetEnvVar(), sylog.GetNamedEnvVar(), fmt.Sprintf("PIPE_EXEC_FD=%d", pipeFd)}
 	c.env = append(c.env, env...)
 
 	return nil
@@ -52,6 +52,15 @@ type Options struct {
 	ImgCache *cache.Handle
 	// NoTest indicates if build should skip running the test script.
 	NoTest bool `json:"noTest"`
+	// ChecksumManifest indicates if build should record the sha256 of
+	// every file of the final root filesystem within the image.
+	ChecksumManifest bool `json:"checksumManifest"`
+	// SquashfsCompression is the compression algorithm of the squashfs
+	// filesystem of a SIF image, gzip is used when empty.
+	SquashfsCompression string `json:"squashfsCompression"`
+	// SquashfsCompressionLevel is the level of SquashfsCompression,
+	// the algorithm default level is used when zero.
+	SquashfsCompressionLevel int `json:"squashfsCompressionLevel"`
 	// Force automatically deletes an existing container at build destination while performing build.
 	Force bool `json:"force"`
 	// Update detects and builds using an existing sandbox container at build destination.
@@ -147,6 +156,9 @@ func cleanupDir(path string) {
 func newBundle(rootfs, tempDir string, keyInfo *crypt.KeyInfo) (*Bundle, error) {
 	rootfsPath := rootfs
 
+	if tempDir == "" {
+		tempDir = fs.TempDir()
+	}
 	tmpPath, err := ioutil.TempDir(tempDir, "bundle-temp-")
 	if err != nil {
 		return nil, fmt.Errorf("could not create temp dir in %q: %v", tempDir, err)
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
 	"gopkg.in/cheggaaa/pb.v1"
 )
@@ -47,7 +48,8 @@ func DownloadImage(filePath string, libraryURL string) error {
 	sylog.Debugf("Pulling from URL: %s\n", url)
 
 	client := &http.Client{
-		Timeout: pullTimeout * time.Second,
+		Transport: proxy.NewTransport(),
+		Timeout:   pullTimeout * time.Second,
 	}
 
 	req, err := http.NewRequest(http.MethodGet, url, nil)
@@ -0,0 +1,196 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package proxy selects the HTTP proxy used by the image pull clients from
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, or from a
+// configuration set programmatically, including the proxy credentials.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Config is a proxy configuration set programmatically, its non empty
+// fields take precedence over the proxy environment variables.
+type Config struct {
+	// HTTPProxy is the proxy URL of the http requests.
+	HTTPProxy string
+	// HTTPSProxy is the proxy URL of the https requests.
+	HTTPSProxy string
+	// NoProxy is a comma separated list of hosts, domains and
+	// networks not proxied.
+	NoProxy string
+	// Username and Password are the proxy credentials, they replace
+	// the credentials of the proxy URLs when Username is not empty.
+	Username string
+	Password string
+}
+
+var (
+	mutex  sync.RWMutex
+	config *Config
+)
+
+// envVars are the environment variables read by http.ProxyFromEnvironment,
+// the upper case names take precedence.
+var envVars = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// SetConfig sets the proxy configuration of the clients returned by this
+// package, nil restores the environment configuration.
+//
+// The OCI registry clients only use http.ProxyFromEnvironment, which reads
+// the environment once per process, the configuration is passed to them
+// during this call without leaving the credentials in the environment of
+// the processes started later. It must then be called before any request
+// is sent with http.ProxyFromEnvironment, an error is returned otherwise.
+func SetConfig(c *Config) error {
+	if c == nil {
+		mutex.Lock()
+		config = nil
+		mutex.Unlock()
+		return nil
+	}
+
+	for _, u := range []string{c.HTTPProxy, c.HTTPSProxy} {
+		if _, err := parseProxyURL(u); err != nil {
+			return err
+		}
+	}
+
+	cc := *c
+	mutex.Lock()
+	config = &cc
+	mutex.Unlock()
+
+	return exportConfig(currentConfig())
+}
+
+// Func returns the proxy URL to use for req, nil if req is not proxied. It
+// behaves like http.ProxyFromEnvironment but honors the configuration set
+// with SetConfig and reads the environment on each call.
+func Func(req *http.Request) (*url.URL, error) {
+	return currentConfig().ProxyFunc()(req.URL)
+}
+
+// NewTransport returns a copy of http.DefaultTransport using Func to
+// select the proxy, the proxy credentials are sent in the
+// Proxy-Authorization header of the requests and tunnels.
+func NewTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = Func
+	return t
+}
+
+// currentConfig returns the environment configuration
+// overridden by the configuration set with SetConfig.
+func currentConfig() *httpproxy.Config {
+	pc := httpproxy.FromEnvironment()
+
+	mutex.RLock()
+	c := config
+	mutex.RUnlock()
+	if c == nil {
+		return pc
+	}
+
+	if c.HTTPProxy != "" {
+		pc.HTTPProxy = c.HTTPProxy
+	}
+	if c.HTTPSProxy != "" {
+		pc.HTTPSProxy = c.HTTPSProxy
+	}
+	if c.NoProxy != "" {
+		pc.NoProxy = c.NoProxy
+	}
+	if c.Username != "" {
+		pc.HTTPProxy = withCredentials(pc.HTTPProxy, c.Username, c.Password)
+		pc.HTTPSProxy = withCredentials(pc.HTTPSProxy, c.Username, c.Password)
+	}
+	return pc
+}
+
+// parseProxyURL parses a proxy URL, a missing scheme defaults
+// to http as with http.ProxyFromEnvironment.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %s", proxy, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: no host", proxy)
+	}
+	return u, nil
+}
+
+// withCredentials returns the proxy URL with its credentials replaced,
+// an empty or invalid URL is returned unchanged.
+func withCredentials(proxy, username, password string) string {
+	u, err := parseProxyURL(proxy)
+	if err != nil || u == nil {
+		return proxy
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String()
+}
+
+// exportConfig makes http.ProxyFromEnvironment use pc by setting the
+// proxy environment variables for its first call, and restores them.
+func exportConfig(pc *httpproxy.Config) error {
+	saved := make(map[string]*string)
+	for _, name := range envVars {
+		if value, ok := os.LookupEnv(name); ok {
+			saved[name] = &value
+		} else {
+			saved[name] = nil
+		}
+	}
+	defer func() {
+		for name, value := range saved {
+			if value != nil {
+				os.Setenv(name, *value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}()
+
+	os.Setenv("HTTP_PROXY", pc.HTTPProxy)
+	os.Setenv("HTTPS_PROXY", pc.HTTPSProxy)
+	os.Setenv("NO_PROXY", pc.NoProxy)
+
+	proxyFunc := pc.ProxyFunc()
+	for _, scheme := range []string{"http", "https"} {
+		req := &http.Request{URL: &url.URL{Scheme: scheme, Host: "registry.invalid"}}
+		got, err := http.ProxyFromEnvironment(req)
+		if err != nil {
+			return fmt.Errorf("while checking the %s proxy: %s", scheme, err)
+		}
+		expected, _ := proxyFunc(req.URL)
+		if urlString(got) != urlString(expected) {
+			return fmt.Errorf("the proxy environment variables were already read, the %s proxy configuration doesn't apply to all clients", scheme)
+		}
+	}
+	return nil
+}
+
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
 )
 
@@ -52,7 +53,8 @@ type ShubAPIResponse struct {
 func GetManifest(uri ShubURI, noHTTPS bool) (ShubAPIResponse, error) {
 	// Create a new http Hub client
 	httpc := http.Client{
-		Timeout: 30 * time.Second,
+		Transport: proxy.NewTransport(),
+		Timeout:   30 * time.Second,
 	}
 
 	if uri.registry != defaultRegistry+shubAPIRoute {
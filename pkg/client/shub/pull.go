@@ -14,6 +14,7 @@ import (
 
 	jsonresp "github.com/sylabs/json-resp"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/client/proxy"
 	useragent "github.com/sylabs/singularity/pkg/util/user-agent"
 	pb "gopkg.in/cheggaaa/pb.v1"
 )
@@ -48,7 +49,8 @@ func DownloadImage(manifest ShubAPIResponse, filePath, shubRef string, force, no
 
 	// Get the image based on the manifest
 	httpc := http.Client{
-		Timeout: pullTimeout * time.Second,
+		Transport: proxy.NewTransport(),
+		Timeout:   pullTimeout * time.Second,
 	}
 
 	req, err := http.NewRequest(http.MethodGet, manifest.Image, nil)
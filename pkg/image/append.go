@@ -0,0 +1,110 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// appendableTypes lists the types of the data objects which can be
+// appended by AppendDataObject, partitions, signatures and encrypted
+// messages are created along with the data they depend on.
+var appendableTypes = map[sif.Datatype]bool{
+	sif.DataDeffile:     true,
+	sif.DataEnvVar:      true,
+	sif.DataLabels:      true,
+	sif.DataGenericJSON: true,
+	sif.DataGeneric:     true,
+}
+
+// DataObject describes a data object appended to a SIF image.
+type DataObject struct {
+	// Type is the SIF data type of the object.
+	Type sif.Datatype
+	// Name is the descriptor name of the object.
+	Name string
+	// Data is the content of the object.
+	Data []byte
+	// Group is the number of the object group the object is added
+	// to, 1 being the default group of the image objects. The object
+	// doesn't belong to any group if Group is 0.
+	Group uint32
+}
+
+// AppendDataObject appends the data object obj at the end of the SIF
+// image at path, and returns its descriptor ID. The existing objects
+// are left untouched so their signatures remain valid, unless obj is
+// added to a group with signatures: those are invalidated, as they no
+// longer cover the whole group, and a warning is logged. The new object
+// itself isn't signed.
+func AppendDataObject(path string, obj DataObject) (uint32, error) {
+	if !appendableTypes[obj.Type] {
+		return 0, fmt.Errorf("data objects of type %s can't be appended", obj.Type)
+	}
+	if obj.Name == "" {
+		return 0, fmt.Errorf("data object has no name")
+	} else if len(obj.Name) >= sif.DescrNameLen {
+		return 0, fmt.Errorf("data object name %q is longer than %d characters", obj.Name, sif.DescrNameLen-1)
+	}
+
+	// the data is read from input.Fp if input.Data is nil
+	if obj.Data == nil {
+		obj.Data = []byte{}
+	}
+
+	groupID := uint32(sif.DescrUnusedGroup)
+	if obj.Group != 0 {
+		groupID = obj.Group | sif.DescrGroupMask
+	}
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load SIF image %s: %s", path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	// AddObject uses the first free descriptor
+	id := uint32(0)
+	for i, d := range fimg.DescrArr {
+		if !d.Used {
+			id = uint32(i) + 1
+			break
+		}
+	}
+	if id == 0 || fimg.Header.Dfree == 0 {
+		return 0, fmt.Errorf("no free descriptor left in SIF image %s", path)
+	}
+
+	signatures := 0
+	if obj.Group != 0 {
+		for _, d := range fimg.DescrArr {
+			if d.Used && d.Datatype == sif.DataSignature && d.Link == groupID {
+				signatures++
+			}
+		}
+	}
+
+	err = fimg.AddObject(sif.DescriptorInput{
+		Datatype: obj.Type,
+		Groupid:  groupID,
+		Link:     sif.DescrUnusedLink,
+		Fname:    obj.Name,
+		Data:     obj.Data,
+		Size:     int64(len(obj.Data)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("while adding data object to %s: %s", path, err)
+	}
+
+	if signatures > 0 {
+		sylog.Warningf("The %d signature(s) of object group %d are invalidated by the new data object, %s must be signed again", signatures, obj.Group, path)
+	}
+
+	return id, nil
+}
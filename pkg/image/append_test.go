@@ -0,0 +1,153 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+func TestAppendDataObject(t *testing.T) {
+	fp, err := os.Open(testSquash)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", testSquash, err)
+	}
+	defer fp.Close()
+
+	fi, err := fp.Stat()
+	if err != nil {
+		t.Fatalf("failed to stat %s: %s", testSquash, err)
+	}
+
+	// the size must be set for the data length of
+	// the image to be recorded, objects are appended
+	// after it
+	primPart := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    "primPart",
+		Fp:       fp,
+		Size:     fi.Size(),
+		Extra: *bytes.NewBuffer([]byte{
+			0x01, 0x00, 0x00, 0x00, // fstype
+			0x02, 0x00, 0x00, 0x00, // part type
+		}),
+	}
+	primPart.Extra.WriteString(sif.GetSIFArch(runtime.GOARCH))
+
+	path := createSIF(t, []sif.DescriptorInput{primPart}, false)
+	defer os.Remove(path)
+
+	before, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatalf("failed to load %s: %s", path, err)
+	}
+	rootfs := before.DescrArr[0]
+	rootfsData := append([]byte(nil), rootfs.GetData(&before)...)
+	before.UnloadContainer()
+
+	tests := []struct {
+		name            string
+		obj             DataObject
+		expectedSuccess bool
+	}{
+		{
+			name:            "Provenance",
+			obj:             DataObject{Type: sif.DataGenericJSON, Name: "provenance.json", Data: []byte(`{"builder":"ci"}`)},
+			expectedSuccess: true,
+		},
+		{
+			name:            "DefaultGroup",
+			obj:             DataObject{Type: sif.DataGeneric, Name: "dataset", Data: []byte("data"), Group: 1},
+			expectedSuccess: true,
+		},
+		{
+			name:            "Empty",
+			obj:             DataObject{Type: sif.DataGeneric, Name: "empty"},
+			expectedSuccess: true,
+		},
+		{
+			name: "Partition",
+			obj:  DataObject{Type: sif.DataPartition, Name: "part", Data: []byte("data")},
+		},
+		{
+			name: "Signature",
+			obj:  DataObject{Type: sif.DataSignature, Name: "sig", Data: []byte("data")},
+		},
+		{
+			name: "NoName",
+			obj:  DataObject{Type: sif.DataGeneric, Data: []byte("data")},
+		},
+		{
+			name: "LongName",
+			obj:  DataObject{Type: sif.DataGeneric, Name: strings.Repeat("x", sif.DescrNameLen), Data: []byte("data")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := AppendDataObject(path, tt.obj)
+			if err != nil && tt.expectedSuccess {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && !tt.expectedSuccess {
+				t.Fatalf("unexpected success")
+			} else if err != nil {
+				return
+			}
+
+			img, err := Init(path, false)
+			if err != nil {
+				t.Fatalf("failed to open %s: %s", path, err)
+			}
+			defer img.File.Close()
+
+			var section *Section
+			for i, s := range img.Sections {
+				if s.ID == id {
+					section = &img.Sections[i]
+				}
+			}
+			if section == nil {
+				t.Fatalf("no object with ID %d found", id)
+			}
+			if section.Name != tt.obj.Name || section.Type != uint32(tt.obj.Type) {
+				t.Errorf("got object %s of type %d, expected %s of type %d", section.Name, section.Type, tt.obj.Name, tt.obj.Type)
+			}
+			r, err := NewSectionReader(img, tt.obj.Name, -1)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if data, _ := ioutil.ReadAll(r); !bytes.Equal(data, tt.obj.Data) {
+				t.Errorf("got data %q, expected %q", data, tt.obj.Data)
+			}
+
+			// existing objects are left untouched
+			fimg, err := sif.LoadContainer(path, true)
+			if err != nil {
+				t.Fatalf("failed to load %s: %s", path, err)
+			}
+			defer fimg.UnloadContainer()
+
+			d, _, err := fimg.GetFromDescrID(rootfs.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if d.Fileoff != rootfs.Fileoff || !bytes.Equal(d.GetData(&fimg), rootfsData) {
+				t.Errorf("root filesystem partition modified")
+			}
+			if d, _, _ := fimg.GetFromDescrID(id); d.Groupid&^sif.DescrGroupMask != tt.obj.Group {
+				t.Errorf("got group %d, expected %d", d.Groupid&^sif.DescrGroupMask, tt.obj.Group)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// Feature is a runtime feature an image may require to run.
+type Feature string
+
+const (
+	// FeatureOverlay is the overlay file system.
+	FeatureOverlay Feature = "overlay"
+	// FeatureSetuid is the setuid workflow, required to mount
+	// file systems unprivileged users can't mount.
+	FeatureSetuid Feature = "setuid"
+	// FeatureFakeroot is the fakeroot feature.
+	FeatureFakeroot Feature = "fakeroot"
+)
+
+// Features lists the runtime features reported by RequiredFeatures.
+var Features = []Feature{FeatureOverlay, FeatureSetuid, FeatureFakeroot}
+
+// RequiresLabel is the image label listing, comma separated, the runtime
+// features required by an image on top of those deduced from its format.
+const RequiresLabel = "org.sylabs.singularity.requires"
+
+// labelsPath is the path of the labels file in the image root filesystem.
+const labelsPath = "/.singularity.d/labels.json"
+
+// FeatureRequirement states whether an image requires a runtime feature.
+type FeatureRequirement int
+
+const (
+	// FeatureUnknown is reported when the image lacks the
+	// metadata telling if the feature is required.
+	FeatureUnknown FeatureRequirement = iota
+	// FeatureNotRequired is reported when the feature is not required.
+	FeatureNotRequired
+	// FeatureRequired is reported when the feature is required.
+	FeatureRequired
+)
+
+// String returns the requirement as displayed to users.
+func (r FeatureRequirement) String() string {
+	switch r {
+	case FeatureNotRequired:
+		return "not required"
+	case FeatureRequired:
+		return "required"
+	}
+	return "unknown"
+}
+
+// RequiredFeatures returns the runtime features required to run the image
+// at path. The requirements are deduced from the image partitions: ext3
+// and encrypted partitions are mounted through the setuid workflow and
+// overlay partitions require the overlay file system. They are completed
+// by the features listed in the RequiresLabel label of the image. A
+// feature which can't be deduced from the partitions is reported as
+// unknown when the labels of the image can't be read, an error is only
+// returned when the image can't be opened.
+func RequiredFeatures(path string) (map[Feature]FeatureRequirement, error) {
+	img, err := Init(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer img.File.Close()
+
+	features := make(map[Feature]FeatureRequirement, len(Features))
+	for _, f := range Features {
+		features[f] = FeatureUnknown
+	}
+
+	if known := partitionsFeatures(img, features); known {
+		features[FeatureOverlay] = maxRequirement(features[FeatureOverlay], FeatureNotRequired)
+		features[FeatureSetuid] = maxRequirement(features[FeatureSetuid], FeatureNotRequired)
+	}
+
+	labels, err := readLabels(img)
+	if err != nil {
+		sylog.Debugf("Could not read labels of image %s: %s", path, err)
+		return features, nil
+	}
+
+	required := make(map[Feature]bool)
+	for _, f := range strings.Split(labels[RequiresLabel], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			required[Feature(f)] = true
+		}
+	}
+	for _, f := range Features {
+		if required[f] {
+			features[f] = FeatureRequired
+		} else {
+			features[f] = maxRequirement(features[f], FeatureNotRequired)
+		}
+	}
+
+	return features, nil
+}
+
+// maxRequirement returns the strongest of the requirements a and b.
+func maxRequirement(a, b FeatureRequirement) FeatureRequirement {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// partitionsFeatures sets in features the features required by the
+// partitions of img and returns if the partitions are all of a known
+// type, so that the features not required can be told.
+func partitionsFeatures(img *Image, features map[Feature]FeatureRequirement) bool {
+	if img.Type == SANDBOX {
+		return true
+	}
+
+	known := true
+	for _, p := range img.Partitions {
+		switch p.Type {
+		case SQUASHFS:
+		case EXT3, ENCRYPTSQUASHFS:
+			features[FeatureSetuid] = FeatureRequired
+		default:
+			known = false
+		}
+		// standalone images are usable as root filesystem and
+		// as overlay, only overlay partitions require overlay
+		if p.AllowedUsage == OverlayUsage {
+			features[FeatureOverlay] = FeatureRequired
+		}
+	}
+	return known
+}
+
+// readLabels returns the labels of the image, they are read from
+// the root filesystem of sandbox images and of images with a squashfs
+// root filesystem.
+func readLabels(img *Image) (map[string]string, error) {
+	var data []byte
+
+	if img.Type == SANDBOX {
+		b, err := ioutil.ReadFile(filepath.Join(img.Path, labelsPath))
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	} else {
+		part, err := img.GetRootFsPartition()
+		if err != nil {
+			return nil, err
+		}
+		if part.Type != SQUASHFS {
+			return nil, fmt.Errorf("labels can only be read from a squashfs root filesystem")
+		}
+		r, err := newSquashfsReader(io.NewSectionReader(img.File, int64(part.Offset), int64(part.Size)), int64(part.Size))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = r.ReadFile(labelsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	labels := make(map[string]string)
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRequiredFeatures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "features-")
+	if err != nil {
+		t.Fatalf("impossible to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sandbox := func(name, labels string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Join(path, ".singularity.d"), 0755); err != nil {
+			t.Fatalf("while creating directory: %s", err)
+		}
+		if labels != "" {
+			if err := ioutil.WriteFile(filepath.Join(path, labelsPath), []byte(labels), 0644); err != nil {
+				t.Fatalf("while writing labels: %s", err)
+			}
+		}
+		return path
+	}
+
+	tests := []struct {
+		name        string
+		image       string
+		expected    map[Feature]FeatureRequirement
+		expectError bool
+	}{
+		{
+			name:  "SandboxNoLabels",
+			image: sandbox("nolabels", ""),
+			expected: map[Feature]FeatureRequirement{
+				FeatureOverlay:  FeatureNotRequired,
+				FeatureSetuid:   FeatureNotRequired,
+				FeatureFakeroot: FeatureUnknown,
+			},
+		},
+		{
+			name:  "SandboxLabels",
+			image: sandbox("labels", `{"org.label-schema.schema-version": "1.0"}`),
+			expected: map[Feature]FeatureRequirement{
+				FeatureOverlay:  FeatureNotRequired,
+				FeatureSetuid:   FeatureNotRequired,
+				FeatureFakeroot: FeatureNotRequired,
+			},
+		},
+		{
+			name:  "SandboxRequires",
+			image: sandbox("requires", `{"org.sylabs.singularity.requires": "fakeroot, overlay,gpu"}`),
+			expected: map[Feature]FeatureRequirement{
+				FeatureOverlay:  FeatureRequired,
+				FeatureSetuid:   FeatureNotRequired,
+				FeatureFakeroot: FeatureRequired,
+			},
+		},
+		{
+			name:  "SandboxBadLabels",
+			image: sandbox("badlabels", `{"org.sylabs.singularity.requires": ["fakeroot"]}`),
+			expected: map[Feature]FeatureRequirement{
+				FeatureOverlay:  FeatureNotRequired,
+				FeatureSetuid:   FeatureNotRequired,
+				FeatureFakeroot: FeatureUnknown,
+			},
+		},
+		{
+			name:  "Squashfs",
+			image: "testdata/squashfs.v4",
+			expected: map[Feature]FeatureRequirement{
+				FeatureOverlay:  FeatureNotRequired,
+				FeatureSetuid:   FeatureNotRequired,
+				FeatureFakeroot: FeatureUnknown,
+			},
+		},
+		{
+			name:        "NotExist",
+			image:       filepath.Join(dir, "notexist"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features, err := RequiredFeatures(tt.image)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+			if !reflect.DeepEqual(features, tt.expected) && !tt.expectError {
+				t.Errorf("got features %v, expected %v", features, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPartitionsFeatures(t *testing.T) {
+	tests := []struct {
+		name       string
+		partitions []Section
+		setuid     FeatureRequirement
+		overlay    FeatureRequirement
+		known      bool
+	}{
+		{
+			name:       "Squashfs",
+			partitions: []Section{{Type: SQUASHFS, AllowedUsage: RootFsUsage}},
+			known:      true,
+		},
+		{
+			name:       "Ext3",
+			partitions: []Section{{Type: EXT3, AllowedUsage: RootFsUsage}},
+			setuid:     FeatureRequired,
+			known:      true,
+		},
+		{
+			name:       "Encrypted",
+			partitions: []Section{{Type: ENCRYPTSQUASHFS, AllowedUsage: RootFsUsage}},
+			setuid:     FeatureRequired,
+			known:      true,
+		},
+		{
+			name: "Overlay",
+			partitions: []Section{
+				{Type: SQUASHFS, AllowedUsage: RootFsUsage},
+				{Type: EXT3, AllowedUsage: OverlayUsage},
+			},
+			setuid:  FeatureRequired,
+			overlay: FeatureRequired,
+			known:   true,
+		},
+		{
+			name:       "Raw",
+			partitions: []Section{{Type: RAW, AllowedUsage: RootFsUsage}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := make(map[Feature]FeatureRequirement)
+			known := partitionsFeatures(&Image{Type: SIF, Partitions: tt.partitions}, features)
+			if known != tt.known {
+				t.Errorf("got known %v, expected %v", known, tt.known)
+			}
+			if features[FeatureSetuid] != tt.setuid {
+				t.Errorf("got setuid %s, expected %s", features[FeatureSetuid], tt.setuid)
+			}
+			if features[FeatureOverlay] != tt.overlay {
+				t.Errorf("got overlay %s, expected %s", features[FeatureOverlay], tt.overlay)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"fmt"
+	"io"
+)
+
+// ReadFile returns the content of the file at innerPath within the
+// root filesystem of the image at imagePath. The file is read from
+// the squashfs root filesystem of a SIF or squashfs image directly,
+// without mounting it, so no privileges are required. Symbolic links
+// are resolved within the image. An error satisfying os.IsNotExist is
+// returned if there is no such file.
+func ReadFile(imagePath, innerPath string) ([]byte, error) {
+	img, err := Init(imagePath, false)
+	if err != nil {
+		return nil, err
+	}
+	defer img.File.Close()
+
+	part, err := img.GetRootFsPartition()
+	if err != nil {
+		return nil, err
+	}
+	if part.Type != SQUASHFS {
+		return nil, fmt.Errorf("reading files is only supported from a squashfs root filesystem")
+	}
+
+	r, err := newSquashfsReader(io.NewSectionReader(img.File, int64(part.Offset), int64(part.Size)), int64(part.Size))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ReadFile(innerPath)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		path        string
+		expected    []byte
+		expectError bool
+		notExist    bool
+	}{
+		{
+			name:     "File",
+			image:    "testdata/squashfs.v4",
+			path:     "/examplefile",
+			expected: []byte("Example File Contents\n"),
+		},
+		{
+			name:     "RelativePath",
+			image:    "testdata/squashfs.v4",
+			path:     "../examplefile",
+			expected: []byte("Example File Contents\n"),
+		},
+		{
+			name:        "NotExist",
+			image:       "testdata/squashfs.v4",
+			path:        "/etc/os-release",
+			expectError: true,
+			notExist:    true,
+		},
+		{
+			name:        "Directory",
+			image:       "testdata/squashfs.v4",
+			path:        "/",
+			expectError: true,
+		},
+		{
+			name:        "UnsupportedCompression",
+			image:       "testdata/squashfs.lzo",
+			path:        "/examplefile",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := ReadFile(tt.image, tt.path)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+			if tt.notExist && !os.IsNotExist(err) {
+				t.Errorf("got error %q, expected a not exist error", err)
+			}
+			if !bytes.Equal(content, tt.expected) {
+				t.Errorf("got content %q, expected %q", content, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadFileSquashfs(t *testing.T) {
+	mksquashfs, err := exec.LookPath("mksquashfs")
+	if err != nil {
+		t.Skipf("mksquashfs is not available, skipping the test...")
+	}
+
+	dir, err := ioutil.TempDir("", "squashfsReadFile-")
+	if err != nil {
+		t.Fatalf("impossible to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootfs := filepath.Join(dir, "rootfs")
+	osRelease := []byte("NAME=\"Test Linux\"\nVERSION_ID=\"1.0\"\n")
+	// spans several blocks and ends in a fragment
+	large := bytes.Repeat([]byte("0123456789abcdef"), 4096*3/16+5)
+
+	files := map[string][]byte{
+		"usr/lib/os-release": osRelease,
+		"usr/share/large":    large,
+		"usr/share/empty":    {},
+	}
+	for name, content := range files {
+		path := filepath.Join(rootfs, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("while creating directory: %s", err)
+		}
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("while writing file: %s", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(rootfs, "etc"), 0755); err != nil {
+		t.Fatalf("while creating directory: %s", err)
+	}
+	links := map[string]string{
+		"etc/os-release": "../usr/lib/os-release",
+		"lib":            "/usr/lib",
+		"loop":           "loop",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(rootfs, name)); err != nil {
+			t.Fatalf("while creating symlink: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		expected    []byte
+		expectError bool
+	}{
+		{name: "RelativeSymlink", path: "/etc/os-release", expected: osRelease},
+		{name: "AbsoluteSymlinkInPath", path: "/lib/os-release", expected: osRelease},
+		{name: "LargeFile", path: "/usr/share/large", expected: large},
+		{name: "EmptyFile", path: "/usr/share/empty", expected: []byte{}},
+		{name: "SymlinkLoop", path: "/loop", expectError: true},
+		{name: "NotDirectory", path: "/usr/lib/os-release/file", expectError: true},
+	}
+
+	for _, comp := range []string{"gzip", "xz"} {
+		image := filepath.Join(dir, comp+".sqfs")
+		cmd := exec.Command(mksquashfs, rootfs, image, "-noappend", "-all-root", "-b", "4096", "-comp", comp)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Logf("mksquashfs output: %s", out)
+			t.Fatalf("cannot create squashfs image: %s", err)
+		}
+
+		for _, tt := range tests {
+			t.Run(comp+"/"+tt.name, func(t *testing.T) {
+				content, err := ReadFile(image, tt.path)
+				if err != nil && !tt.expectError {
+					t.Fatalf("unexpected error: %s", err)
+				} else if err == nil && tt.expectError {
+					t.Fatalf("unexpected success")
+				}
+				if !tt.expectError && !bytes.Equal(content, tt.expected) {
+					t.Errorf("unexpected content for %s", tt.path)
+				}
+			})
+		}
+	}
+}
@@ -240,11 +240,29 @@ func (i *Image) GetRootFsPartition() (*Section, error) {
 	if err != nil {
 		return nil, err
 	} else if len(partitions) == 0 {
+		if i.looksLikePlugin() {
+			return nil, fmt.Errorf("no root filesystem found, this looks like a plugin image, not a container: plugins must be installed with 'singularity plugin install'")
+		}
 		return nil, fmt.Errorf("no root filesystem found")
 	}
 	return &partitions[0], nil
 }
 
+// looksLikePlugin returns if the image holds the manifest of
+// a plugin image, as created by singularity plugin compile.
+func (i *Image) looksLikePlugin() bool {
+	if i.Type != SIF {
+		return false
+	}
+	for _, s := range i.Sections {
+		// see pluginManifestName in internal/pkg/plugin
+		if s.Name == "plugin.manifest" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetRootFsPartitions returns root filesystem partitions found
 // in the image.
 func (i *Image) GetRootFsPartitions() ([]Section, error) {
@@ -0,0 +1,124 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/util/loop"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+// ErrNotMountable represents an error returned when a SIF object
+// can't be mounted.
+var ErrNotMountable = errors.New("object is not mountable")
+
+// mountFsType maps the mountable partition types to their filesystem name.
+var mountFsType = map[uint32]string{
+	SQUASHFS: "squashfs",
+	EXT3:     "ext3",
+}
+
+// MountDataPartition mounts read-only on dest the data or overlay
+// partition with the descriptor ID id of the SIF image at imagePath.
+// The partition is attached to a loop device and must be a squashfs
+// or an ext3 filesystem, otherwise an error wrapping ErrNotMountable
+// is returned. The image is kept open until the returned function is
+// called to unmount the partition and release the loop device. The
+// loop device is allocated among the "max loop devices" of conf.
+func MountDataPartition(imagePath string, id uint32, dest string, conf *singularityconf.File) (func() error, error) {
+	img, err := Init(imagePath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	part, err := mountablePartition(img, id)
+	if err != nil {
+		img.File.Close()
+		return nil, err
+	}
+
+	loopDev := &loop.Device{
+		MaxLoopDevices: int(conf.MaxLoopDevices),
+		Shared:         true,
+		Info: &loop.Info64{
+			SizeLimit: part.Size,
+			Offset:    part.Offset,
+			Flags:     loop.FlagsAutoClear | loop.FlagsReadOnly,
+		},
+	}
+	idx := 0
+	if err := loopDev.AttachFromFile(img.File, os.O_RDONLY, &idx); err != nil {
+		img.File.Close()
+		return nil, fmt.Errorf("failed to attach image %s: %s", img.Path, err)
+	}
+	path := fmt.Sprintf("/dev/loop%d", idx)
+
+	sylog.Debugf("Mounting partition %d of %s (%s) on %s", id, img.Path, path, dest)
+
+	flags := uintptr(syscall.MS_RDONLY | syscall.MS_NOSUID | syscall.MS_NODEV)
+	if err := syscall.Mount(path, dest, mountFsType[part.Type], flags, ""); err != nil {
+		detachLoop(path)
+		img.File.Close()
+		return nil, fmt.Errorf("failed to mount %s on %s: %s", path, dest, err)
+	}
+
+	return func() error {
+		defer img.File.Close()
+		if err := syscall.Unmount(dest, 0); err != nil {
+			return fmt.Errorf("failed to unmount %s: %s", dest, err)
+		}
+		detachLoop(path)
+		return nil
+	}, nil
+}
+
+// mountablePartition returns the partition with the descriptor ID id
+// of img if it can be mounted by MountDataPartition.
+func mountablePartition(img *Image, id uint32) (*Section, error) {
+	if img.Type != SIF {
+		return nil, fmt.Errorf("%s is not a SIF image", img.Path)
+	}
+
+	for _, s := range img.Sections {
+		if s.ID == id {
+			return nil, fmt.Errorf("object %d is not a partition: %w", id, ErrNotMountable)
+		}
+	}
+	for i, p := range img.Partitions {
+		if p.ID != id {
+			continue
+		}
+		if p.AllowedUsage&RootFsUsage != 0 {
+			return nil, fmt.Errorf("object %d is the root filesystem partition: %w", id, ErrNotMountable)
+		}
+		if _, ok := mountFsType[p.Type]; !ok {
+			return nil, fmt.Errorf("partition %d is neither squashfs nor ext3: %w", id, ErrNotMountable)
+		}
+		return &img.Partitions[i], nil
+	}
+	return nil, fmt.Errorf("no partition with ID %d found in %s", id, img.Path)
+}
+
+// detachLoop releases the loop device at path, once it is no
+// longer used when it's shared with other mounts.
+func detachLoop(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		sylog.Debugf("Could not open loop device %s: %s", path, err)
+		return
+	}
+	defer f.Close()
+
+	_, _, esys := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), loop.CmdClrFd, 0)
+	if esys != 0 && esys != syscall.ENXIO && esys != syscall.EBUSY {
+		sylog.Debugf("Could not release loop device %s: %s", path, esys)
+	}
+}
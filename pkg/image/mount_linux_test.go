@@ -0,0 +1,116 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/test"
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+func TestMountDataPartition(t *testing.T) {
+	// each object reads the data from its own file
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	open := func() *os.File {
+		fp, err := os.Open(testSquash)
+		if err != nil {
+			t.Fatalf("failed to open %s: %s", testSquash, err)
+		}
+		files = append(files, fp)
+		return fp
+	}
+
+	partition := func(name string, fstype, ptype byte) sif.DescriptorInput {
+		fp := open()
+		d := sif.DescriptorInput{
+			Datatype: sif.DataPartition,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    name,
+			Fp:       fp,
+			Extra: *bytes.NewBuffer([]byte{
+				fstype, 0x00, 0x00, 0x00, // fstype
+				ptype, 0x00, 0x00, 0x00, // part type
+			}),
+		}
+		d.Extra.WriteString(sif.GetSIFArch(runtime.GOARCH))
+		return d
+	}
+
+	path := createSIF(t, []sif.DescriptorInput{
+		partition("primPart", byte(sif.FsSquash), byte(sif.PartPrimSys)),
+		partition("dataPart", byte(sif.FsSquash), byte(sif.PartData)),
+		partition("rawPart", byte(sif.FsRaw), byte(sif.PartData)),
+		{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    "oneSection",
+			Fp:       open(),
+		},
+	}, false)
+	defer os.Remove(path)
+
+	// descriptor IDs are allocated in order from 1
+	const (
+		primID = iota + 1
+		dataID
+		rawID
+		sectionID
+		missingID
+	)
+
+	conf, err := singularityconf.GetConfig(nil)
+	if err != nil {
+		t.Fatalf("failed to get default configuration: %s", err)
+	}
+
+	for _, id := range []uint32{primID, rawID, sectionID} {
+		if _, err := MountDataPartition(path, id, "/mnt", conf); !errors.Is(err, ErrNotMountable) {
+			t.Errorf("got error %v mounting object %d, expected %v", err, id, ErrNotMountable)
+		}
+	}
+	if _, err := MountDataPartition(path, missingID, "/mnt", conf); err == nil || errors.Is(err, ErrNotMountable) {
+		t.Errorf("got error %v mounting a missing object", err)
+	}
+
+	t.Run("Mount", test.WithPrivilege(func(t *testing.T) {
+		dest, err := ioutil.TempDir("", "mount-data-")
+		if err != nil {
+			t.Fatalf("failed to create temporary directory: %s", err)
+		}
+		defer os.RemoveAll(dest)
+
+		cleanup, err := MountDataPartition(path, dataID, dest, conf)
+		if err != nil {
+			t.Skipf("could not mount data partition: %s", err)
+		}
+		fis, err := ioutil.ReadDir(dest)
+		if err != nil {
+			t.Errorf("failed to read mounted partition: %s", err)
+		} else if len(fis) == 0 {
+			t.Errorf("mounted partition is empty")
+		}
+		if err := cleanup(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if fis, _ := ioutil.ReadDir(dest); len(fis) != 0 {
+			t.Errorf("partition still mounted on %s", dest)
+		}
+	}))
+}
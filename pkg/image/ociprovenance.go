@@ -0,0 +1,107 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The labels recording the OCI image an image was converted from,
+// see OCIProvenance.
+const (
+	// OCISourceLabel is the label holding the reference of
+	// the OCI image (eg: docker://alpine:3.12).
+	OCISourceLabel = "org.sylabs.singularity.oci.source"
+	// OCIManifestDigestLabel is the label holding the digest of
+	// the OCI image manifest.
+	OCIManifestDigestLabel = "org.sylabs.singularity.oci.manifest-digest"
+	// OCIIndexDigestLabel is the label holding the digest of the
+	// multi-architecture manifest list the manifest was selected from.
+	OCIIndexDigestLabel = "org.sylabs.singularity.oci.index-digest"
+	// OCIPlatformLabel is the label holding the platform of the
+	// OCI image (eg: linux/arm64).
+	OCIPlatformLabel = "org.sylabs.singularity.oci.platform"
+	// OCILayersLabel is the label holding the digests of the OCI
+	// image layers, comma separated, from the base layer.
+	OCILayersLabel = "org.sylabs.singularity.oci.layers"
+)
+
+// ErrNoOCIProvenance is returned by ReadOCIProvenance for images
+// not converted from an OCI image, or converted before the OCI
+// provenance was recorded.
+var ErrNoOCIProvenance = errors.New("no OCI provenance recorded in image")
+
+// OCIProvenance identifies the OCI image an image was converted from,
+// it's recorded in the image labels during the conversion.
+type OCIProvenance struct {
+	// Source is the reference of the OCI image.
+	Source string
+	// ManifestDigest is the digest of the OCI image manifest.
+	ManifestDigest string
+	// IndexDigest is the digest of the manifest list, or OCI index,
+	// of a multi-architecture image, the manifest of ManifestDigest
+	// being the one selected for the platform. It's empty for single
+	// architecture images.
+	IndexDigest string
+	// Platform is the platform of the OCI image as os/architecture.
+	Platform string
+	// Layers are the digests of the OCI image layers, from the base
+	// layer.
+	Layers []string
+}
+
+// Labels returns the image labels recording the provenance.
+func (p OCIProvenance) Labels() map[string]string {
+	labels := map[string]string{
+		OCISourceLabel:         p.Source,
+		OCIManifestDigestLabel: p.ManifestDigest,
+		OCIPlatformLabel:       p.Platform,
+		OCILayersLabel:         strings.Join(p.Layers, ","),
+	}
+	if p.IndexDigest != "" {
+		labels[OCIIndexDigestLabel] = p.IndexDigest
+	}
+	return labels
+}
+
+// ociProvenanceFromLabels returns the provenance recorded in the
+// image labels, or ErrNoOCIProvenance.
+func ociProvenanceFromLabels(labels map[string]string) (*OCIProvenance, error) {
+	if labels[OCIManifestDigestLabel] == "" {
+		return nil, ErrNoOCIProvenance
+	}
+
+	p := &OCIProvenance{
+		Source:         labels[OCISourceLabel],
+		ManifestDigest: labels[OCIManifestDigestLabel],
+		IndexDigest:    labels[OCIIndexDigestLabel],
+		Platform:       labels[OCIPlatformLabel],
+	}
+	if layers := labels[OCILayersLabel]; layers != "" {
+		p.Layers = strings.Split(layers, ",")
+	}
+	return p, nil
+}
+
+// ReadOCIProvenance returns the provenance of the image at path, a SIF
+// image with a squashfs root filesystem or a sandbox, converted from an
+// OCI image. ErrNoOCIProvenance is returned if the image labels don't
+// record it.
+func ReadOCIProvenance(path string) (*OCIProvenance, error) {
+	img, err := Init(path, false)
+	if err != nil {
+		return nil, err
+	}
+	defer img.File.Close()
+
+	labels, err := readLabels(img)
+	if err != nil {
+		return nil, fmt.Errorf("while reading labels of image %s: %s", path, err)
+	}
+	return ociProvenanceFromLabels(labels)
+}
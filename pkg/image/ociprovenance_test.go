@@ -0,0 +1,87 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadOCIProvenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ociprovenance-")
+	if err != nil {
+		t.Fatalf("impossible to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sandbox := func(name string, labels map[string]string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Join(path, ".singularity.d"), 0755); err != nil {
+			t.Fatalf("while creating directory: %s", err)
+		}
+		data, err := json.Marshal(labels)
+		if err != nil {
+			t.Fatalf("while encoding labels: %s", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, labelsPath), data, 0644); err != nil {
+			t.Fatalf("while writing labels: %s", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		provenance OCIProvenance
+	}{
+		{
+			name: "SingleArch",
+			provenance: OCIProvenance{
+				Source:         "docker://alpine:3.12",
+				ManifestDigest: "sha256:a15790640a6690aa1730c38cf0a440e2aa44aaca9b0e8931a9f2b0d7cc90fd65",
+				Platform:       "linux/amd64",
+				Layers:         []string{"sha256:df20fa9351a15782c64e6dddb2d4a6f50bf6d3688060a34c4014b0d9a752eb4c"},
+			},
+		},
+		{
+			name: "MultiArch",
+			provenance: OCIProvenance{
+				Source:         "docker://alpine:3.12",
+				ManifestDigest: "sha256:1ed8a8c5e2a8b7b1a9c1b8e2f3e6f0e4f7a2c1c4e0d0c5e1a8b7c6d5e4f3a2b1",
+				IndexDigest:    "sha256:185518070891758909c9f839cf4ca393ee977ac378609f700f60a771a2dfe321",
+				Platform:       "linux/arm64",
+				Layers: []string{
+					"sha256:b538f80385f9b48122e3da068c932a96ea5018afa3c7be79da00437414bd18cd",
+					"sha256:1b930d010525941c1d56ec53b97bd057a67ae1865eebf042686d2a2d18271ced",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := tt.provenance.Labels()
+			labels["org.label-schema.schema-version"] = "1.0"
+
+			p, err := ReadOCIProvenance(sandbox(tt.name, labels))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(*p, tt.provenance) {
+				t.Errorf("got provenance %+v, expected %+v", *p, tt.provenance)
+			}
+		})
+	}
+
+	path := sandbox("none", map[string]string{"org.label-schema.schema-version": "1.0"})
+	if _, err := ReadOCIProvenance(path); !errors.Is(err, ErrNoOCIProvenance) {
+		t.Errorf("got error %v, expected %v", err, ErrNoOCIProvenance)
+	}
+}
@@ -9,8 +9,48 @@ import (
 	"bytes"
 	"fmt"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// compressionLevels holds the squashfs compression algorithms which can
+// be selected, along with the range of their compression level. The
+// algorithms without a configurable level have an empty range.
+var compressionLevels = map[string]struct{ min, max int }{
+	"gzip": {1, 9},
+	"lz4":  {0, 0},
+	"xz":   {0, 0},
+	"zstd": {1, 22},
+}
+
+// CompressionFlags returns the mksquashfs options creating a squashfs
+// filesystem compressed with the algorithm comp at level, the default
+// level of the algorithm is used when level is zero.
+func CompressionFlags(comp string, level int) ([]string, error) {
+	levels, ok := compressionLevels[comp]
+	if !ok {
+		var supported []string
+		for c := range compressionLevels {
+			supported = append(supported, c)
+		}
+		sort.Strings(supported)
+		return nil, fmt.Errorf("unsupported squashfs compression %q, supported compressions are %s", comp, strings.Join(supported, ", "))
+	}
+
+	flags := []string{"-comp", comp}
+	if level == 0 {
+		return flags, nil
+	}
+	if levels.max == 0 {
+		return nil, fmt.Errorf("squashfs compression %s has no compression level", comp)
+	}
+	if level < levels.min || level > levels.max {
+		return nil, fmt.Errorf("invalid %s compression level %d, it must be between %d and %d", comp, level, levels.min, levels.max)
+	}
+	return append(flags, "-Xcompression-level", strconv.Itoa(level)), nil
+}
+
 // Squashfs represents a squashfs packer
 type Squashfs struct {
 	MksquashfsPath string
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -118,3 +119,38 @@ func TestSquashfs(t *testing.T) {
 	t.Run("non-zero exit code", testNonZeroExitCode)
 	t.Run("happy path", testHappyPath)
 }
+
+func TestCompressionFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		comp        string
+		level       int
+		flags       []string
+		expectError bool
+	}{
+		{"Gzip", "gzip", 0, []string{"-comp", "gzip"}, false},
+		{"GzipLevel", "gzip", 9, []string{"-comp", "gzip", "-Xcompression-level", "9"}, false},
+		{"GzipInvalidLevel", "gzip", 10, nil, true},
+		{"Lz4", "lz4", 0, []string{"-comp", "lz4"}, false},
+		{"Lz4Level", "lz4", 1, nil, true},
+		{"Xz", "xz", 0, []string{"-comp", "xz"}, false},
+		{"Zstd", "zstd", 0, []string{"-comp", "zstd"}, false},
+		{"ZstdLevel", "zstd", 19, []string{"-comp", "zstd", "-Xcompression-level", "19"}, false},
+		{"ZstdNegativeLevel", "zstd", -1, nil, true},
+		{"Unsupported", "lzma", 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := CompressionFlags(tt.comp, tt.level)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+			if !reflect.DeepEqual(flags, tt.flags) {
+				t.Errorf("got flags %v, expected %v", flags, tt.flags)
+			}
+		})
+	}
+}
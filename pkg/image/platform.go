@@ -0,0 +1,94 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// PlatformUnknown is reported by ReadPlatform for the architecture
+// or the operating system not recorded in the image.
+const PlatformUnknown = "unknown"
+
+// osLabels are the labels recording the operating system of the
+// image, in order of precedence.
+var osLabels = []string{
+	"org.opencontainers.image.os",
+	"org.label-schema.os",
+	"os",
+}
+
+// Platform is the target platform of a SIF image.
+type Platform struct {
+	// Arch is the architecture of the primary partition, with Go
+	// naming, or PlatformUnknown.
+	Arch string
+	// OS is the operating system recorded by the image labels,
+	// or PlatformUnknown.
+	OS string
+}
+
+// SIFArch returns the architecture stored in the SIF architecture field
+// of a header or a partition descriptor with Go naming, or an empty
+// string if the architecture is unknown.
+func SIFArch(field []byte) string {
+	arch := strings.TrimRight(string(field), "\x00")
+	if arch == "" || arch == sif.HdrArchUnknown {
+		return ""
+	}
+	return sif.GetGoArch(arch)
+}
+
+// ReadPlatform returns the target platform of the SIF image at path
+// without mounting it. The architecture is read from the primary
+// partition descriptor, or from the image header if the image has no
+// primary partition, the operating system from the first OS label
+// found in the labels object, see osLabels. A field absent from the
+// image is PlatformUnknown.
+func ReadPlatform(path string) (Platform, error) {
+	p := Platform{Arch: PlatformUnknown, OS: PlatformUnknown}
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		return p, fmt.Errorf("failed to load SIF image %s: %s", path, err)
+	}
+	defer fimg.UnloadContainer()
+
+	field := fimg.Header.Arch[:]
+	if d, _, err := fimg.GetPartPrimSys(); err == nil {
+		if arch, err := d.GetArch(); err == nil {
+			field = arch[:]
+		}
+	}
+	if arch := SIFArch(field); arch != "" {
+		p.Arch = arch
+	}
+
+	for _, d := range fimg.DescrArr {
+		if !d.Used || d.Datatype != sif.DataLabels {
+			continue
+		}
+		var labels map[string]interface{}
+		if err := json.Unmarshal(d.GetData(&fimg), &labels); err != nil {
+			sylog.Debugf("Could not decode labels of %s: %s", path, err)
+			break
+		}
+		for _, k := range osLabels {
+			if os, ok := labels[k].(string); ok && os != "" {
+				p.OS = os
+				break
+			}
+		}
+		break
+	}
+
+	return p, nil
+}
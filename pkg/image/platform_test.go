@@ -0,0 +1,97 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+)
+
+func TestReadPlatform(t *testing.T) {
+	fp, err := os.Open(testSquash)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", testSquash, err)
+	}
+	defer fp.Close()
+
+	primPart := sif.DescriptorInput{
+		Datatype: sif.DataPartition,
+		Groupid:  sif.DescrDefaultGroup,
+		Link:     sif.DescrUnusedLink,
+		Fname:    "primPart",
+		Fp:       fp,
+		Extra: *bytes.NewBuffer([]byte{
+			0x01, 0x00, 0x00, 0x00, // fstype
+			0x02, 0x00, 0x00, 0x00, // part type
+		}),
+	}
+	primPart.Extra.WriteString(sif.GetSIFArch("arm64"))
+
+	labels := func(data string) sif.DescriptorInput {
+		return sif.DescriptorInput{
+			Datatype: sif.DataLabels,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    "labels",
+			Data:     []byte(data),
+			Size:     int64(len(data)),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		inputs   []sif.DescriptorInput
+		expected Platform
+	}{
+		{
+			name:     "ArchAndOS",
+			inputs:   []sif.DescriptorInput{primPart, labels(`{"org.label-schema.os": "linux", "os": "other"}`)},
+			expected: Platform{Arch: "arm64", OS: "linux"},
+		},
+		{
+			name:     "NoLabels",
+			inputs:   []sif.DescriptorInput{primPart},
+			expected: Platform{Arch: "arm64", OS: PlatformUnknown},
+		},
+		{
+			name:     "NoOSLabel",
+			inputs:   []sif.DescriptorInput{primPart, labels(`{"maintainer": "me"}`)},
+			expected: Platform{Arch: "arm64", OS: PlatformUnknown},
+		},
+		{
+			name:     "InvalidLabels",
+			inputs:   []sif.DescriptorInput{primPart, labels(`{`)},
+			expected: Platform{Arch: "arm64", OS: PlatformUnknown},
+		},
+		{
+			name:     "NoPartition",
+			inputs:   []sif.DescriptorInput{labels(`{"os": "linux"}`)},
+			expected: Platform{Arch: PlatformUnknown, OS: "linux"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createSIF(t, tt.inputs, false)
+			defer os.Remove(path)
+
+			p, err := ReadPlatform(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if p != tt.expected {
+				t.Errorf("got platform %+v, expected %+v", p, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ReadPlatform(testSquash); err == nil {
+		t.Errorf("unexpected success with a squashfs image")
+	}
+}
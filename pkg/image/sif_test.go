@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	uuid "github.com/satori/go.uuid"
@@ -256,3 +257,55 @@ func TestSIFOpenMode(t *testing.T) {
 		t.Fatal("openMode(false) returned the wrong value")
 	}
 }
+
+func TestPluginImageHint(t *testing.T) {
+	section := func(name string) sif.DescriptorInput {
+		data := []byte(`{"name":"example.com/plugin"}`)
+		return sif.DescriptorInput{
+			Datatype: sif.DataGenericJSON,
+			Groupid:  sif.DescrDefaultGroup,
+			Link:     sif.DescrUnusedLink,
+			Fname:    name,
+			Data:     data,
+			Size:     int64(len(data)),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		descriptor sif.DescriptorInput
+		hint       bool
+	}{
+		{
+			name:       "PluginSIF",
+			descriptor: section("plugin.manifest"),
+			hint:       true,
+		},
+		{
+			name:       "SectionSIF",
+			descriptor: section("oneSection"),
+			hint:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createSIF(t, []sif.DescriptorInput{tt.descriptor}, false)
+			defer os.Remove(path)
+
+			img, err := Init(path, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer img.File.Close()
+
+			_, err = img.GetRootFsPartition()
+			if err == nil {
+				t.Fatalf("unexpected root filesystem found")
+			}
+			if hint := strings.Contains(err.Error(), "plugin image"); hint != tt.hint {
+				t.Errorf("unexpected error %q", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,723 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// squashfsMetadataSize is the maximum uncompressed size
+	// of a metadata block.
+	squashfsMetadataSize = 8192
+	// squashfsMetadataUncompressed is set in the header of an
+	// uncompressed metadata block.
+	squashfsMetadataUncompressed = 1 << 15
+	// squashfsBlockUncompressed is set in the size of an
+	// uncompressed data block or fragment.
+	squashfsBlockUncompressed = 1 << 24
+	// squashfsNoFragment is the fragment index of a file
+	// without fragment.
+	squashfsNoFragment = 0xffffffff
+	// squashfsMaxSymlinks is the maximum number of symbolic
+	// links followed while resolving a path.
+	squashfsMaxSymlinks = 40
+	// squashfsMinBlockSize and squashfsMaxBlockSize are the
+	// bounds of the data block size.
+	squashfsMinBlockSize = 4096
+	squashfsMaxBlockSize = 1 << 20
+)
+
+// squashfs inode types read by squashfsReader, the "L" types
+// are the extended versions of the basic inode types
+const (
+	squashfsDirType      = 1
+	squashfsFileType     = 2
+	squashfsSymlinkType  = 3
+	squashfsLDirType     = 8
+	squashfsLFileType    = 9
+	squashfsLSymlinkType = 10
+)
+
+// squashfsSuperBlock is the superblock of a v4 squashfs image.
+type squashfsSuperBlock struct {
+	Info             squashfsInfo
+	RootInode        uint64
+	BytesUsed        uint64
+	IDTableStart     uint64
+	XattrTableStart  uint64
+	InodeTableStart  uint64
+	DirTableStart    uint64
+	FragTableStart   uint64
+	ExportTableStart uint64
+}
+
+// squashfsInodeHeader is the header common to all inodes.
+type squashfsInodeHeader struct {
+	Type        uint16
+	Mode        uint16
+	UID         uint16
+	GUID        uint16
+	Mtime       uint32
+	InodeNumber uint32
+}
+
+// squashfsInode holds the inode fields needed to read
+// directories, regular files and symbolic links.
+type squashfsInode struct {
+	typ uint16
+	// directory
+	dirBlock  uint32
+	dirOffset uint16
+	dirSize   uint32
+	// regular file
+	blocksStart uint64
+	fileSize    uint64
+	fragment    uint32
+	fragOffset  uint32
+	blockSizes  []uint32
+	// symbolic link
+	target string
+}
+
+func (i *squashfsInode) isDir() bool {
+	return i.typ == squashfsDirType || i.typ == squashfsLDirType
+}
+
+func (i *squashfsInode) isSymlink() bool {
+	return i.typ == squashfsSymlinkType || i.typ == squashfsLSymlinkType
+}
+
+func (i *squashfsInode) isRegular() bool {
+	return i.typ == squashfsFileType || i.typ == squashfsLFileType
+}
+
+// squashfsReader reads files from a v4 squashfs filesystem
+// without mounting it.
+type squashfsReader struct {
+	r          io.ReaderAt
+	sb         squashfsSuperBlock
+	decompress func(data []byte, max int) ([]byte, error)
+}
+
+// newSquashfsReader returns a reader for the squashfs filesystem
+// read from r, size is the size of the partition holding it.
+func newSquashfsReader(r io.ReaderAt, size int64) (*squashfsReader, error) {
+	s := &squashfsReader{r: r}
+
+	header := make([]byte, binary.Size(s.sb))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("can't read the squashfs super block: %s", err)
+	}
+	if err := binary.Read(bytes.NewReader(header), binary.LittleEndian, &s.sb); err != nil {
+		return nil, fmt.Errorf("can't read the squashfs super block: %s", err)
+	}
+	if !bytes.Equal(s.sb.Info.Magic[:], []byte(squashfsMagic)) {
+		return nil, fmt.Errorf("not a valid squashfs image")
+	}
+	if s.sb.Info.Major != 4 {
+		return nil, fmt.Errorf("squashfs version %d.%d is not supported", s.sb.Info.Major, s.sb.Info.Minor)
+	}
+	if err := s.checkSuperBlock(size); err != nil {
+		return nil, err
+	}
+
+	switch s.sb.Info.Compression {
+	case squashfsZlib:
+		s.decompress = func(data []byte, max int) ([]byte, error) {
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return readLimited(zr, max)
+		}
+	case squashfsXzComp:
+		s.decompress = func(data []byte, max int) ([]byte, error) {
+			xr, err := xz.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return readLimited(xr, max)
+		}
+	case squashfsLz4Comp:
+		s.decompress = lz4Decompress
+	case squashfsZstdComp:
+		s.decompress = func(data []byte, max int) ([]byte, error) {
+			zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			return readLimited(zr, max)
+		}
+	default:
+		comp, _ := GetSquashfsComp(header)
+		if comp == "" {
+			comp = fmt.Sprintf("%d", s.sb.Info.Compression)
+		}
+		return nil, fmt.Errorf("unsupported squashfs compression %s", comp)
+	}
+
+	return s, nil
+}
+
+// checkSuperBlock checks the fields of the super block the reader
+// relies on, size is the size of the partition holding the filesystem.
+func (s *squashfsReader) checkSuperBlock(size int64) error {
+	bs := s.sb.Info.BlockSize
+	if bs < squashfsMinBlockSize || bs > squashfsMaxBlockSize || bs&(bs-1) != 0 {
+		return fmt.Errorf("corrupted image: bad block size %d", bs)
+	}
+	if s.sb.Info.BlockLog >= 32 || bs != 1<<s.sb.Info.BlockLog {
+		return fmt.Errorf("corrupted image: block size %d doesn't match block log %d", bs, s.sb.Info.BlockLog)
+	}
+	if size < 0 || s.sb.BytesUsed > uint64(size) {
+		return fmt.Errorf("corrupted image: filesystem size %d exceeds partition size %d", s.sb.BytesUsed, size)
+	}
+	if s.sb.InodeTableStart >= s.sb.DirTableStart || s.sb.DirTableStart >= s.sb.BytesUsed {
+		return fmt.Errorf("corrupted image: bad inode or directory table location")
+	}
+	return nil
+}
+
+// readAt reads exactly len(b) bytes at offset off,
+// any data past the filesystem end is an error.
+func (s *squashfsReader) readAt(b []byte, off uint64) error {
+	if off > s.sb.BytesUsed || uint64(len(b)) > s.sb.BytesUsed-off {
+		return fmt.Errorf("corrupted image: read past the filesystem end")
+	}
+	_, err := s.r.ReadAt(b, int64(off))
+	return err
+}
+
+// errBlockTooLarge is returned when a block decompresses to more
+// than the maximum size of its kind.
+var errBlockTooLarge = errors.New("corrupted image: decompressed block too large")
+
+// readLimited reads r up to EOF, reading more than max bytes
+// is an error.
+func readLimited(r io.Reader, max int) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > max {
+		return nil, errBlockTooLarge
+	}
+	return data, nil
+}
+
+// lz4Decompress decodes the LZ4 block src, as written by the squashfs
+// lz4 compressor, reading more than max bytes is an error.
+func lz4Decompress(src []byte, max int) ([]byte, error) {
+	errCorrupted := errors.New("corrupted image: bad lz4 block")
+
+	// length reads the extension bytes of the length n
+	// of a literal or match found at offset i of src
+	length := func(n int, i *int) (int, error) {
+		if n != 15 {
+			return n, nil
+		}
+		for {
+			if *i >= len(src) {
+				return 0, errCorrupted
+			} else if n > max {
+				return 0, errBlockTooLarge
+			}
+			b := src[*i]
+			*i++
+			n += int(b)
+			if b != 255 {
+				return n, nil
+			}
+		}
+	}
+
+	var dst []byte
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literals, err := length(int(token>>4), &i)
+		if err != nil {
+			return nil, err
+		}
+		if literals > len(src)-i {
+			return nil, errCorrupted
+		}
+		if literals > max-len(dst) {
+			return nil, errBlockTooLarge
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		// the last sequence only holds literals
+		if i == len(src) {
+			return dst, nil
+		}
+
+		if len(src)-i < 2 {
+			return nil, errCorrupted
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errCorrupted
+		}
+		match, err := length(int(token&15), &i)
+		if err != nil {
+			return nil, err
+		}
+		match += 4
+		if match > max-len(dst) {
+			return nil, errBlockTooLarge
+		}
+		// the match may overlap the bytes it produces
+		start := len(dst) - offset
+		for j := 0; j < match; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	return nil, errCorrupted
+}
+
+// readMetadataBlock reads the metadata block at offset off and
+// returns its uncompressed content along with the offset of the
+// next metadata block.
+func (s *squashfsReader) readMetadataBlock(off uint64) ([]byte, uint64, error) {
+	var header [2]byte
+	if err := s.readAt(header[:], off); err != nil {
+		return nil, 0, err
+	}
+	h := binary.LittleEndian.Uint16(header[:])
+	size := h &^ squashfsMetadataUncompressed
+	if size == 0 || size > squashfsMetadataSize {
+		return nil, 0, fmt.Errorf("corrupted image: bad metadata block size %d", size)
+	}
+
+	data := make([]byte, size)
+	if err := s.readAt(data, off+2); err != nil {
+		return nil, 0, err
+	}
+	next := off + 2 + uint64(size)
+
+	if h&squashfsMetadataUncompressed != 0 {
+		return data, next, nil
+	}
+	data, err := s.decompress(data, squashfsMetadataSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("while decompressing metadata block: %s", err)
+	}
+	return data, next, nil
+}
+
+// metadataReader reads the content of consecutive metadata
+// blocks as a stream.
+type metadataReader struct {
+	s    *squashfsReader
+	next uint64
+	end  uint64
+	buf  []byte
+}
+
+// newMetadataReader returns a reader of the metadata stored
+// from the block at offset block, starting from offset within
+// the uncompressed block, up to the metadata table end at
+// offset end.
+func (s *squashfsReader) newMetadataReader(block uint64, offset uint16, end uint64) (*metadataReader, error) {
+	m := &metadataReader{s: s, next: block, end: end}
+	if err := m.fill(); err != nil {
+		return nil, err
+	}
+	if int(offset) > len(m.buf) {
+		return nil, fmt.Errorf("corrupted image: bad metadata offset %d", offset)
+	}
+	m.buf = m.buf[offset:]
+	return m, nil
+}
+
+func (m *metadataReader) fill() error {
+	if m.next >= m.end {
+		return fmt.Errorf("corrupted image: read past the metadata table end")
+	}
+	data, next, err := m.s.readMetadataBlock(m.next)
+	if err != nil {
+		return err
+	}
+	m.buf = data
+	m.next = next
+	return nil
+}
+
+// remaining returns the maximum number of bytes left to read up
+// to the metadata table end, each of the metadata blocks left
+// holds a 2 bytes header and at least one byte of data.
+func (m *metadataReader) remaining() uint64 {
+	n := uint64(len(m.buf))
+	if m.next < m.end {
+		n += (m.end - m.next + 2) / 3 * squashfsMetadataSize
+	}
+	return n
+}
+
+func (m *metadataReader) Read(b []byte) (int, error) {
+	if len(m.buf) == 0 {
+		if err := m.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// readInode reads the inode referenced by ref, the upper bits of
+// ref are the offset of the metadata block within the inode table
+// and the lower 16 bits the offset of the inode within the block.
+func (s *squashfsReader) readInode(ref uint64) (*squashfsInode, error) {
+	if ref>>16 >= s.sb.DirTableStart-s.sb.InodeTableStart {
+		return nil, fmt.Errorf("corrupted image: bad inode reference %#x", ref)
+	}
+	m, err := s.newMetadataReader(s.sb.InodeTableStart+(ref>>16), uint16(ref), s.sb.DirTableStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var h squashfsInodeHeader
+	if err := binary.Read(m, binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
+
+	inode := &squashfsInode{typ: h.Type}
+
+	switch h.Type {
+	case squashfsDirType:
+		var d struct {
+			StartBlock  uint32
+			Nlink       uint32
+			FileSize    uint16
+			Offset      uint16
+			ParentInode uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		inode.dirBlock, inode.dirOffset, inode.dirSize = d.StartBlock, d.Offset, uint32(d.FileSize)
+	case squashfsLDirType:
+		var d struct {
+			Nlink       uint32
+			FileSize    uint32
+			StartBlock  uint32
+			ParentInode uint32
+			IndexCount  uint16
+			Offset      uint16
+			Xattr       uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		inode.dirBlock, inode.dirOffset, inode.dirSize = d.StartBlock, d.Offset, d.FileSize
+	case squashfsFileType:
+		var f struct {
+			StartBlock uint32
+			Fragment   uint32
+			Offset     uint32
+			FileSize   uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		inode.blocksStart, inode.fileSize = uint64(f.StartBlock), uint64(f.FileSize)
+		inode.fragment, inode.fragOffset = f.Fragment, f.Offset
+	case squashfsLFileType:
+		var f struct {
+			StartBlock uint64
+			FileSize   uint64
+			Sparse     uint64
+			Nlink      uint32
+			Fragment   uint32
+			Offset     uint32
+			Xattr      uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &f); err != nil {
+			return nil, err
+		}
+		inode.blocksStart, inode.fileSize = f.StartBlock, f.FileSize
+		inode.fragment, inode.fragOffset = f.Fragment, f.Offset
+	case squashfsSymlinkType, squashfsLSymlinkType:
+		var l struct {
+			Nlink      uint32
+			TargetSize uint32
+		}
+		if err := binary.Read(m, binary.LittleEndian, &l); err != nil {
+			return nil, err
+		}
+		if l.TargetSize > 4096 {
+			return nil, fmt.Errorf("corrupted image: bad symlink size %d", l.TargetSize)
+		}
+		target := make([]byte, l.TargetSize)
+		if _, err := io.ReadFull(m, target); err != nil {
+			return nil, err
+		}
+		inode.target = string(target)
+		return inode, nil
+	default:
+		return inode, nil
+	}
+
+	if inode.isRegular() {
+		blockSize := uint64(s.sb.Info.BlockSize)
+		blocks := inode.fileSize / blockSize
+		if inode.fragment == squashfsNoFragment && inode.fileSize%blockSize != 0 {
+			blocks++
+		}
+		// the block sizes are stored after the inode
+		if blocks > m.remaining()/4 {
+			return nil, fmt.Errorf("corrupted image: bad file size %d", inode.fileSize)
+		}
+		inode.blockSizes = make([]uint32, blocks)
+		if err := binary.Read(m, binary.LittleEndian, inode.blockSizes); err != nil {
+			return nil, err
+		}
+	}
+
+	return inode, nil
+}
+
+// lookup returns the reference of the inode of the entry "name"
+// within the directory dir, ok is false if there is no such entry.
+func (s *squashfsReader) lookup(dir *squashfsInode, name string) (ref uint64, ok bool, err error) {
+	// the directory size accounts for the "." and ".." entries
+	// which are not stored
+	if dir.dirSize <= 3 {
+		return 0, false, nil
+	}
+	m, err := s.newMetadataReader(s.sb.DirTableStart+uint64(dir.dirBlock), dir.dirOffset, s.sb.BytesUsed)
+	if err != nil {
+		return 0, false, err
+	}
+	r := io.LimitReader(m, int64(dir.dirSize-3))
+
+	for {
+		var h struct {
+			Count       uint32
+			StartBlock  uint32
+			InodeNumber uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &h); err == io.EOF {
+			return 0, false, nil
+		} else if err != nil {
+			return 0, false, err
+		}
+		if h.Count >= 256 {
+			return 0, false, fmt.Errorf("corrupted image: bad directory header")
+		}
+
+		for i := uint32(0); i <= h.Count; i++ {
+			var e struct {
+				Offset      uint16
+				InodeNumber int16
+				Type        uint16
+				NameSize    uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &e); err != nil {
+				return 0, false, err
+			}
+			entry := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(r, entry); err != nil {
+				return 0, false, err
+			}
+			if string(entry) == name {
+				return uint64(h.StartBlock)<<16 | uint64(e.Offset), true, nil
+			}
+		}
+	}
+}
+
+// resolve returns the inode of the file at path, symbolic links
+// are followed within the image.
+func (s *squashfsReader) resolve(p string) (*squashfsInode, error) {
+	links := 0
+	elems := splitPath(p)
+
+	for {
+		inode, err := s.readInode(s.sb.RootInode)
+		if err != nil {
+			return nil, err
+		}
+		var walked []string
+		restart := false
+
+		for i, elem := range elems {
+			if !inode.isDir() {
+				return nil, &os.PathError{Op: "open", Path: p, Err: fmt.Errorf("not a directory")}
+			}
+
+			ref, ok, err := s.lookup(inode, elem)
+			if err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+			}
+			if inode, err = s.readInode(ref); err != nil {
+				return nil, err
+			}
+
+			if !inode.isSymlink() {
+				walked = append(walked, elem)
+				continue
+			}
+			if links++; links > squashfsMaxSymlinks {
+				return nil, &os.PathError{Op: "open", Path: p, Err: fmt.Errorf("too many levels of symbolic links")}
+			}
+			target := inode.target
+			if !path.IsAbs(target) {
+				target = path.Join(append(append([]string{"/"}, walked...), target)...)
+			}
+			elems = append(splitPath(target), elems[i+1:]...)
+			restart = true
+			break
+		}
+
+		if !restart {
+			return inode, nil
+		}
+	}
+}
+
+// splitPath returns the elements of the path within the image,
+// ".." never goes above the image root.
+func splitPath(p string) []string {
+	var elems []string
+	for _, elem := range strings.Split(path.Clean("/"+p), "/") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
+// readFragment returns the uncompressed content of the fragment
+// block at index.
+func (s *squashfsReader) readFragment(index uint32) ([]byte, error) {
+	if index >= s.sb.Info.Fragments {
+		return nil, fmt.Errorf("corrupted image: bad fragment index %d", index)
+	}
+
+	// the fragment table is indexed by an array of
+	// metadata block locations
+	const entrySize = 16
+	const entries = squashfsMetadataSize / entrySize
+
+	var loc [8]byte
+	if err := s.readAt(loc[:], s.sb.FragTableStart+uint64(index/entries)*8); err != nil {
+		return nil, err
+	}
+	m, err := s.newMetadataReader(binary.LittleEndian.Uint64(loc[:]), uint16(index%entries*entrySize), s.sb.BytesUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	var e struct {
+		Start  uint64
+		Size   uint32
+		Unused uint32
+	}
+	if err := binary.Read(m, binary.LittleEndian, &e); err != nil {
+		return nil, err
+	}
+
+	return s.readDataBlock(e.Start, e.Size)
+}
+
+// readDataBlock reads the data block stored at off, size is the
+// on-disk size with the uncompressed flag.
+func (s *squashfsReader) readDataBlock(off uint64, size uint32) ([]byte, error) {
+	n := size &^ squashfsBlockUncompressed
+	if n > s.sb.Info.BlockSize {
+		return nil, fmt.Errorf("corrupted image: bad data block size %d", n)
+	}
+	data := make([]byte, n)
+	if err := s.readAt(data, off); err != nil {
+		return nil, err
+	}
+	if size&squashfsBlockUncompressed != 0 {
+		return data, nil
+	}
+	data, err := s.decompress(data, int(s.sb.Info.BlockSize))
+	if err != nil {
+		return nil, fmt.Errorf("while decompressing data block: %s", err)
+	}
+	return data, nil
+}
+
+// ReadFile returns the content of the regular file at path
+// within the squashfs filesystem.
+func (s *squashfsReader) ReadFile(p string) ([]byte, error) {
+	inode, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if !inode.isRegular() {
+		return nil, &os.PathError{Op: "read", Path: p, Err: fmt.Errorf("not a regular file")}
+	}
+
+	var content bytes.Buffer
+
+	off := inode.blocksStart
+	for _, size := range inode.blockSizes {
+		remaining := inode.fileSize - uint64(content.Len())
+		blockSize := uint64(s.sb.Info.BlockSize)
+		if remaining < blockSize {
+			blockSize = remaining
+		}
+		if size == 0 {
+			// sparse block
+			content.Write(make([]byte, blockSize))
+			continue
+		}
+		block, err := s.readDataBlock(off, size)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(block)) < blockSize {
+			return nil, fmt.Errorf("corrupted image: short data block")
+		}
+		content.Write(block[:blockSize])
+		off += uint64(size &^ squashfsBlockUncompressed)
+	}
+
+	if inode.fragment != squashfsNoFragment {
+		frag, err := s.readFragment(inode.fragment)
+		if err != nil {
+			return nil, err
+		}
+		tail := inode.fileSize - uint64(content.Len())
+		end := uint64(inode.fragOffset) + tail
+		if end > uint64(len(frag)) {
+			return nil, fmt.Errorf("corrupted image: bad fragment offset")
+		}
+		content.Write(frag[inode.fragOffset:end])
+	}
+
+	if uint64(content.Len()) != inode.fileSize {
+		return nil, fmt.Errorf("corrupted image: file size mismatch")
+	}
+
+	return content.Bytes(), nil
+}
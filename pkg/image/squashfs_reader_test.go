@@ -0,0 +1,445 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// squashfs super block field offsets
+const (
+	sbBlockSizeOffset       = 12
+	sbCompressionOffset     = 20
+	sbBlockLogOffset        = 22
+	sbBytesUsedOffset       = 40
+	sbInodeTableStartOffset = 64
+	sbDirTableStartOffset   = 72
+	sbSize                  = 96
+)
+
+func TestSquashfsReaderSuperBlock(t *testing.T) {
+	image, err := ioutil.ReadFile("testdata/squashfs.v4")
+	if err != nil {
+		t.Fatalf("while reading image: %s", err)
+	}
+	bytesUsed := binary.LittleEndian.Uint64(image[sbBytesUsedOffset:])
+
+	tests := []struct {
+		name    string
+		corrupt func(sb []byte)
+		size    int64
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			corrupt: func(sb []byte) {},
+		},
+		{
+			name:    "ZeroBlockSize",
+			corrupt: func(sb []byte) { binary.LittleEndian.PutUint32(sb[sbBlockSizeOffset:], 0) },
+			wantErr: true,
+		},
+		{
+			name:    "SmallBlockSize",
+			corrupt: func(sb []byte) { setBlockSize(sb, 1024, 10) },
+			wantErr: true,
+		},
+		{
+			name:    "LargeBlockSize",
+			corrupt: func(sb []byte) { setBlockSize(sb, 2<<20, 21) },
+			wantErr: true,
+		},
+		{
+			name:    "NotPowerOfTwo",
+			corrupt: func(sb []byte) { setBlockSize(sb, 3<<12, 13) },
+			wantErr: true,
+		},
+		{
+			name:    "BlockLogMismatch",
+			corrupt: func(sb []byte) { binary.LittleEndian.PutUint16(sb[sbBlockLogOffset:], 12) },
+			wantErr: true,
+		},
+		{
+			name:    "BlockLogOverflow",
+			corrupt: func(sb []byte) { binary.LittleEndian.PutUint16(sb[sbBlockLogOffset:], 0xffff) },
+			wantErr: true,
+		},
+		{
+			name:    "BytesUsedBeyondPartition",
+			corrupt: func(sb []byte) { binary.LittleEndian.PutUint64(sb[sbBytesUsedOffset:], 1<<62) },
+			wantErr: true,
+		},
+		{
+			name:    "TruncatedPartition",
+			corrupt: func(sb []byte) {},
+			size:    int64(bytesUsed) - 1,
+			wantErr: true,
+		},
+		{
+			name: "InodeTableAfterDirTable",
+			corrupt: func(sb []byte) {
+				binary.LittleEndian.PutUint64(sb[sbInodeTableStartOffset:], binary.LittleEndian.Uint64(sb[sbDirTableStartOffset:]))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte{}, image...)
+			tt.corrupt(data)
+			size := tt.size
+			if size == 0 {
+				size = int64(len(data))
+			}
+
+			r, err := newSquashfsReader(bytes.NewReader(data), size)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.wantErr {
+				t.Fatalf("unexpected success")
+			}
+			if r == nil {
+				return
+			}
+			if _, err := r.ReadFile("/examplefile"); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestSquashfsReaderInode(t *testing.T) {
+	const blockSize = 4096
+
+	tests := []struct {
+		name       string
+		fileSize   uint64
+		fragment   uint32
+		blockSizes int
+		wantErr    bool
+	}{
+		{name: "Blocks", fileSize: 2 * blockSize, fragment: squashfsNoFragment, blockSizes: 2},
+		{name: "BlocksAndFragment", fileSize: 2*blockSize + 10, fragment: 0, blockSizes: 2},
+		{name: "MissingBlockSizes", fileSize: 3 * blockSize, fragment: squashfsNoFragment, blockSizes: 2, wantErr: true},
+		{name: "HugeFile", fileSize: 1 << 60, fragment: squashfsNoFragment, blockSizes: 2, wantErr: true},
+		{name: "MaxFile", fileSize: 1<<64 - 1, fragment: squashfsNoFragment, blockSizes: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := createInodeTable(t, blockSize, tt.fileSize, tt.fragment, tt.blockSizes)
+			r, err := newSquashfsReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			inode, err := r.readInode(0)
+			if err != nil && !tt.wantErr {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.wantErr {
+				t.Fatalf("unexpected success")
+			}
+			if err == nil && len(inode.blockSizes) != tt.blockSizes {
+				t.Errorf("got %d block sizes, expected %d", len(inode.blockSizes), tt.blockSizes)
+			}
+
+			// references past the inode table
+			if _, err := r.readInode(uint64(len(data)) << 16); err == nil {
+				t.Errorf("unexpected success with a reference past the inode table")
+			}
+		})
+	}
+}
+
+// setBlockSize sets the block size and block log of the super block sb.
+func setBlockSize(sb []byte, size uint32, log uint16) {
+	binary.LittleEndian.PutUint32(sb[sbBlockSizeOffset:], size)
+	binary.LittleEndian.PutUint16(sb[sbBlockLogOffset:], log)
+}
+
+// createInodeTable returns a zlib squashfs image holding a super block
+// followed by an inode table made of an uncompressed metadata block with
+// an extended regular file inode of fileSize bytes and blockSizes block
+// sizes, the directory table being an empty metadata block.
+func createInodeTable(t *testing.T, blockSize uint32, fileSize uint64, fragment uint32, blockSizes int) []byte {
+	var inode bytes.Buffer
+	fields := []interface{}{
+		squashfsInodeHeader{Type: squashfsLFileType, Mode: 0644, InodeNumber: 1},
+		struct {
+			StartBlock uint64
+			FileSize   uint64
+			Sparse     uint64
+			Nlink      uint32
+			Fragment   uint32
+			Offset     uint32
+			Xattr      uint32
+		}{sbSize, fileSize, 0, 1, fragment, 0, 0xffffffff},
+		make([]uint32, blockSizes),
+	}
+	for _, f := range fields {
+		if err := binary.Write(&inode, binary.LittleEndian, f); err != nil {
+			t.Fatalf("while encoding inode: %s", err)
+		}
+	}
+
+	var table bytes.Buffer
+	binary.Write(&table, binary.LittleEndian, uint16(inode.Len())|squashfsMetadataUncompressed)
+	table.Write(inode.Bytes())
+	dirTable := uint64(sbSize + table.Len())
+	binary.Write(&table, binary.LittleEndian, uint16(1)|squashfsMetadataUncompressed)
+	table.WriteByte(0)
+
+	sb := squashfsSuperBlock{
+		Info: squashfsInfo{
+			Inodes:      1,
+			BlockSize:   blockSize,
+			Fragments:   1,
+			Compression: squashfsZlib,
+			BlockLog:    12,
+			Major:       4,
+		},
+		BytesUsed:       uint64(sbSize + table.Len()),
+		InodeTableStart: sbSize,
+		DirTableStart:   dirTable,
+	}
+	copy(sb.Info.Magic[:], squashfsMagic)
+
+	var image bytes.Buffer
+	if err := binary.Write(&image, binary.LittleEndian, sb); err != nil {
+		t.Fatalf("while encoding super block: %s", err)
+	}
+	image.Write(table.Bytes())
+	return image.Bytes()
+}
+
+func TestSquashfsReaderDecompress(t *testing.T) {
+	const max = squashfsMetadataSize
+
+	compressors := []struct {
+		name        string
+		compression uint16
+		compress    func(data []byte) []byte
+	}{
+		{
+			name:        "zlib",
+			compression: squashfsZlib,
+			compress: func(data []byte) []byte {
+				var b bytes.Buffer
+				w := zlib.NewWriter(&b)
+				w.Write(data)
+				w.Close()
+				return b.Bytes()
+			},
+		},
+		{
+			name:        "xz",
+			compression: squashfsXzComp,
+			compress: func(data []byte) []byte {
+				var b bytes.Buffer
+				w, err := xz.NewWriter(&b)
+				if err != nil {
+					t.Fatalf("while creating xz writer: %s", err)
+				}
+				w.Write(data)
+				w.Close()
+				return b.Bytes()
+			},
+		},
+		{
+			name:        "zstd",
+			compression: squashfsZstdComp,
+			compress: func(data []byte) []byte {
+				w, err := zstd.NewWriter(nil)
+				if err != nil {
+					t.Fatalf("while creating zstd writer: %s", err)
+				}
+				defer w.Close()
+				return w.EncodeAll(data, nil)
+			},
+		},
+		{
+			name:        "lz4",
+			compression: squashfsLz4Comp,
+			compress: func(data []byte) []byte {
+				// a literal byte repeated by a match at offset 1,
+				// the last byte being the final literal
+				n := len(data)
+				return append(lz4Block(data[:1], 1, n-2), lz4Block(data[n-1:], 0, 0)...)
+			},
+		},
+	}
+
+	for _, c := range compressors {
+		t.Run(c.name, func(t *testing.T) {
+			image := createInodeTable(t, 4096, 0, squashfsNoFragment, 0)
+			binary.LittleEndian.PutUint16(image[sbCompressionOffset:], c.compression)
+			r, err := newSquashfsReader(bytes.NewReader(image), int64(len(image)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			block := bytes.Repeat([]byte{'a'}, max)
+			data, err := r.decompress(c.compress(block), max)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !bytes.Equal(data, block) {
+				t.Errorf("decompressed block differs")
+			}
+
+			// a block expanding past the maximum size
+			bomb := c.compress(bytes.Repeat([]byte{'a'}, 1<<20))
+			if _, err := r.decompress(bomb, max); err != errBlockTooLarge {
+				t.Errorf("got error %v, expected %v", err, errBlockTooLarge)
+			}
+		})
+	}
+}
+
+func TestSquashfsReaderUnsupportedCompression(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		compression uint16
+	}{
+		{"lzma", squashfsLzmaComp},
+		{"lzo", squashfsLzoComp},
+	} {
+		image := createInodeTable(t, 4096, 0, squashfsNoFragment, 0)
+		binary.LittleEndian.PutUint16(image[sbCompressionOffset:], c.compression)
+		_, err := newSquashfsReader(bytes.NewReader(image), int64(len(image)))
+		if err == nil || !strings.Contains(err.Error(), "unsupported squashfs compression "+c.name) {
+			t.Errorf("got error %v, expected an unsupported %s compression error", err, c.name)
+		}
+	}
+}
+
+func TestLz4Decompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      []byte
+		max      int
+		expected []byte
+		err      bool
+	}{
+		{
+			name:     "Literals",
+			src:      lz4Block([]byte("literals only"), 0, 0),
+			max:      100,
+			expected: []byte("literals only"),
+		},
+		{
+			name:     "Match",
+			src:      append(lz4Block([]byte("abcd"), 4, 8), lz4Block([]byte("e"), 0, 0)...),
+			max:      100,
+			expected: []byte("abcdabcdabcde"),
+		},
+		{
+			name:     "LongLiterals",
+			src:      lz4Block(bytes.Repeat([]byte("x"), 300), 0, 0),
+			max:      300,
+			expected: bytes.Repeat([]byte("x"), 300),
+		},
+		{
+			name: "LiteralsTooLarge",
+			src:  lz4Block(bytes.Repeat([]byte("x"), 300), 0, 0),
+			max:  299,
+			err:  true,
+		},
+		{
+			name: "MatchTooLarge",
+			src:  lz4Block([]byte("a"), 1, 1000),
+			max:  1000,
+			err:  true,
+		},
+		{
+			name: "OffsetBeforeStart",
+			src:  lz4Block([]byte("abcd"), 5, 4),
+			max:  100,
+			err:  true,
+		},
+		{
+			name: "ZeroOffset",
+			src:  lz4Block([]byte("abcd"), 0, 4),
+			max:  100,
+			err:  true,
+		},
+		{
+			name: "TruncatedLiterals",
+			src:  lz4Block([]byte("abcd"), 0, 0)[:3],
+			max:  100,
+			err:  true,
+		},
+		{
+			name: "Empty",
+			max:  100,
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := lz4Decompress(tt.src, tt.max)
+			if err != nil && !tt.err {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.err {
+				t.Fatalf("unexpected success")
+			}
+			if !bytes.Equal(data, tt.expected) {
+				t.Errorf("got %q, expected %q", data, tt.expected)
+			}
+		})
+	}
+}
+
+// lz4Block returns an LZ4 sequence made of literals followed by a match
+// of length bytes at offset, the sequence has no match if length is 0.
+func lz4Block(literals []byte, offset, length int) []byte {
+	// extend appends the bytes following the token
+	// for a length n of at least 15
+	extend := func(b []byte, n int) []byte {
+		for n -= 15; n >= 255; n -= 255 {
+			b = append(b, 255)
+		}
+		return append(b, byte(n))
+	}
+
+	token := byte(0)
+	if len(literals) >= 15 {
+		token = 15 << 4
+	} else {
+		token = byte(len(literals)) << 4
+	}
+	match := length - 4
+	if length > 0 {
+		if match >= 15 {
+			token |= 15
+		} else {
+			token |= byte(match)
+		}
+	}
+
+	b := []byte{token}
+	if len(literals) >= 15 {
+		b = extend(b, len(literals))
+	}
+	b = append(b, literals...)
+	if length > 0 {
+		b = append(b, byte(offset), byte(offset>>8))
+		if match >= 15 {
+			b = extend(b, match)
+		}
+	}
+	return b
+}
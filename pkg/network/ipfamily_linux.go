@@ -0,0 +1,283 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types/current"
+)
+
+// IPFamily defines the IP address families requested to the
+// network plugins.
+type IPFamily string
+
+const (
+	// IPDefault requests the addresses configured by the network,
+	// without any check
+	IPDefault IPFamily = ""
+	// IPv4 requests an IPv4 address only
+	IPv4 IPFamily = "ipv4"
+	// IPv6 requests an IPv6 address only
+	IPv6 IPFamily = "ipv6"
+	// DualStack requests both an IPv4 and an IPv6 address
+	DualStack IPFamily = "dual"
+)
+
+// ParseIPFamily returns the IP family corresponding to s.
+func ParseIPFamily(s string) (IPFamily, error) {
+	switch f := IPFamily(s); f {
+	case IPDefault, IPv4, IPv6, DualStack:
+		return f, nil
+	}
+	return IPDefault, fmt.Errorf("unknown IP family %q: must be %s, %s or %s", s, IPv4, IPv6, DualStack)
+}
+
+// versions returns the CNI IP versions corresponding to the family.
+func (f IPFamily) versions() []string {
+	switch f {
+	case IPv4:
+		return []string{"4"}
+	case IPv6:
+		return []string{"6"}
+	case DualStack:
+		return []string{"4", "6"}
+	}
+	return nil
+}
+
+func versionName(version string) string {
+	return "IPv" + version
+}
+
+func ipVersion(ip net.IP) string {
+	if ip.To4() != nil {
+		return "4"
+	}
+	return "6"
+}
+
+// SetIPFamily sets the IP families requested for all configured networks.
+// Networks using the host-local IPAM plugin are configured to only allocate
+// addresses in the requested families and an error is returned if one of
+// them has no subnet for a requested family. For other IPAM plugins, the
+// addresses are checked once networks are brought up.
+func (m *Setup) SetIPFamily(family IPFamily) error {
+	if _, err := ParseIPFamily(string(family)); err != nil {
+		return err
+	}
+
+	for i, conf := range m.networkConfList {
+		filtered, err := filterNetworkIPFamily(conf, family)
+		if err != nil {
+			return fmt.Errorf("network %s: %s", conf.Name, err)
+		}
+		m.networkConfList[i] = filtered
+	}
+	m.ipFamily = family
+
+	return nil
+}
+
+// filterNetworkIPFamily returns the network configuration with the
+// host-local IPAM ranges and routes restricted to the IP family.
+func filterNetworkIPFamily(conf *libcni.NetworkConfigList, family IPFamily) (*libcni.NetworkConfigList, error) {
+	versions := family.versions()
+	if versions == nil {
+		return conf, nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(conf.Bytes, &raw); err != nil {
+		return nil, fmt.Errorf("while decoding configuration: %s", err)
+	}
+
+	plugins, _ := raw["plugins"].([]interface{})
+	for _, p := range plugins {
+		plugin, _ := p.(map[string]interface{})
+		ipam, _ := plugin["ipam"].(map[string]interface{})
+		if ipam == nil || ipam["type"] != "host-local" {
+			continue
+		}
+
+		found, err := filterIPAMRanges(ipam, versions)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if !found[v] {
+				return nil, fmt.Errorf("no %s subnet configured", versionName(v))
+			}
+		}
+		filterIPAMRoutes(ipam, versions)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding configuration: %s", err)
+	}
+	return libcni.ConfListFromBytes(data)
+}
+
+// filterIPAMRanges removes from the host-local IPAM configuration the
+// range sets not matching one of the IP versions, it returns the IP
+// versions having at least a range set.
+func filterIPAMRanges(ipam map[string]interface{}, versions []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+
+	keep := func(subnet interface{}) (bool, error) {
+		s, _ := subnet.(string)
+		ip, _, err := net.ParseCIDR(s)
+		if err != nil {
+			return false, fmt.Errorf("invalid IPAM subnet %q", s)
+		}
+		v := ipVersion(ip)
+		for _, version := range versions {
+			if v == version {
+				found[v] = true
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// legacy single subnet configuration
+	if subnet, ok := ipam["subnet"]; ok {
+		k, err := keep(subnet)
+		if err != nil {
+			return nil, err
+		} else if !k {
+			delete(ipam, "subnet")
+			delete(ipam, "rangeStart")
+			delete(ipam, "rangeEnd")
+			delete(ipam, "gateway")
+		}
+	}
+
+	rangeSets, _ := ipam["ranges"].([]interface{})
+	if rangeSets == nil {
+		return found, nil
+	}
+
+	var kept []interface{}
+	for _, rs := range rangeSets {
+		ranges, _ := rs.([]interface{})
+		if len(ranges) == 0 {
+			continue
+		}
+		// all ranges of a set are in the same family
+		r, _ := ranges[0].(map[string]interface{})
+		k, err := keep(r["subnet"])
+		if err != nil {
+			return nil, err
+		} else if k {
+			kept = append(kept, rs)
+		}
+	}
+	ipam["ranges"] = kept
+
+	return found, nil
+}
+
+// filterIPAMRoutes removes from the host-local IPAM configuration
+// the routes not matching one of the IP versions.
+func filterIPAMRoutes(ipam map[string]interface{}, versions []string) {
+	routes, _ := ipam["routes"].([]interface{})
+	if routes == nil {
+		return
+	}
+
+	var kept []interface{}
+	for _, r := range routes {
+		route, _ := r.(map[string]interface{})
+		dst, _ := route["dst"].(string)
+		ip, _, err := net.ParseCIDR(dst)
+		if err != nil {
+			// let the plugin report the error
+			kept = append(kept, r)
+			continue
+		}
+		for _, v := range versions {
+			if ipVersion(ip) == v {
+				kept = append(kept, r)
+				break
+			}
+		}
+	}
+	ipam["routes"] = kept
+}
+
+// GetNetworkIPs returns all the IPs associated with a configured network
+// in the requested IP families, IPv4 addresses first. If network is
+// empty, the function returns IPs for the first configured network.
+func (m *Setup) GetNetworkIPs(network string) ([]net.IP, error) {
+	n := network
+	if n == "" && len(m.networkConfList) > 0 {
+		n = m.networkConfList[0].Name
+	}
+
+	for i := 0; i < len(m.networkConfList); i++ {
+		if m.networkConfList[i].Name != n {
+			continue
+		}
+		if i >= len(m.result) || m.result[i] == nil {
+			break
+		}
+
+		res, err := current.NewResultFromResult(m.result[i])
+		if err != nil {
+			return nil, fmt.Errorf("could not convert result: %v", err)
+		}
+
+		versions := m.ipFamily.versions()
+		if versions == nil {
+			versions = []string{"4", "6"}
+		}
+
+		var ips []net.IP
+		for _, v := range versions {
+			for _, ipResult := range res.IPs {
+				if ipResult.Version == v {
+					ips = append(ips, ipResult.Address.IP)
+				}
+			}
+		}
+		if len(ips) == 0 {
+			break
+		}
+		return ips, nil
+	}
+
+	return nil, fmt.Errorf("no IP found for network %s", network)
+}
+
+// checkIPFamily checks that the networks brought up have an
+// address in each requested IP family.
+func (m *Setup) checkIPFamily() error {
+	for i, conf := range m.networkConfList {
+		res, err := current.NewResultFromResult(m.result[i])
+		if err != nil {
+			return fmt.Errorf("could not convert result: %v", err)
+		}
+
+		for _, v := range m.ipFamily.versions() {
+			found := false
+			for _, ipResult := range res.IPs {
+				if ipResult.Version == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("network %s: no %s address assigned, check that the network has an %s subnet", conf.Name, versionName(v), versionName(v))
+			}
+		}
+	}
+	return nil
+}
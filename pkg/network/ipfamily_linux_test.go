@@ -0,0 +1,248 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package network
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+)
+
+const dualStackConf = `{
+	"cniVersion": "0.4.0",
+	"name": "dual",
+	"plugins": [
+		{
+			"type": "bridge",
+			"bridge": "dual0",
+			"ipam": {
+				"type": "host-local",
+				"ranges": [
+					[{"subnet": "10.22.0.0/16"}],
+					[{"subnet": "fd00:22::/64"}]
+				],
+				"routes": [
+					{"dst": "0.0.0.0/0"},
+					{"dst": "::/0"}
+				]
+			}
+		}
+	]
+}`
+
+const ipv4Conf = `{
+	"cniVersion": "0.4.0",
+	"name": "ipv4",
+	"plugins": [
+		{
+			"type": "bridge",
+			"bridge": "ipv40",
+			"ipam": {
+				"type": "host-local",
+				"subnet": "10.23.0.0/16",
+				"routes": [{"dst": "0.0.0.0/0"}]
+			}
+		}
+	]
+}`
+
+func ipamConf(t *testing.T, conf *libcni.NetworkConfigList) map[string]interface{} {
+	var raw struct {
+		IPAM map[string]interface{} `json:"ipam"`
+	}
+	if err := json.Unmarshal(conf.Plugins[0].Bytes, &raw); err != nil {
+		t.Fatalf("while decoding plugin configuration: %s", err)
+	}
+	return raw.IPAM
+}
+
+func TestFilterNetworkIPFamily(t *testing.T) {
+	cases := []struct {
+		description string
+		conf        string
+		family      IPFamily
+		subnets     []string
+		routes      []string
+		shouldFail  bool
+	}{
+		{
+			description: "dual stack network with default family",
+			conf:        dualStackConf,
+			family:      IPDefault,
+			subnets:     []string{"10.22.0.0/16", "fd00:22::/64"},
+			routes:      []string{"0.0.0.0/0", "::/0"},
+		},
+		{
+			description: "dual stack network with dual family",
+			conf:        dualStackConf,
+			family:      DualStack,
+			subnets:     []string{"10.22.0.0/16", "fd00:22::/64"},
+			routes:      []string{"0.0.0.0/0", "::/0"},
+		},
+		{
+			description: "dual stack network with IPv4 only",
+			conf:        dualStackConf,
+			family:      IPv4,
+			subnets:     []string{"10.22.0.0/16"},
+			routes:      []string{"0.0.0.0/0"},
+		},
+		{
+			description: "dual stack network with IPv6 only",
+			conf:        dualStackConf,
+			family:      IPv6,
+			subnets:     []string{"fd00:22::/64"},
+			routes:      []string{"::/0"},
+		},
+		{
+			description: "IPv4 network with IPv4 only",
+			conf:        ipv4Conf,
+			family:      IPv4,
+			routes:      []string{"0.0.0.0/0"},
+		},
+		{
+			description: "IPv4 network with IPv6 only",
+			conf:        ipv4Conf,
+			family:      IPv6,
+			shouldFail:  true,
+		},
+		{
+			description: "IPv4 network with dual family",
+			conf:        ipv4Conf,
+			family:      DualStack,
+			shouldFail:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			conf, err := libcni.ConfListFromBytes([]byte(tc.conf))
+			if err != nil {
+				t.Fatalf("while parsing configuration: %s", err)
+			}
+
+			filtered, err := filterNetworkIPFamily(conf, tc.family)
+			if tc.shouldFail {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			ipam := ipamConf(t, filtered)
+
+			var subnets []string
+			rangeSets, _ := ipam["ranges"].([]interface{})
+			for _, rs := range rangeSets {
+				r := rs.([]interface{})[0].(map[string]interface{})
+				subnets = append(subnets, r["subnet"].(string))
+			}
+			if !reflect.DeepEqual(subnets, tc.subnets) {
+				t.Errorf("got subnets %v, expected %v", subnets, tc.subnets)
+			}
+
+			var routes []string
+			for _, r := range ipam["routes"].([]interface{}) {
+				routes = append(routes, r.(map[string]interface{})["dst"].(string))
+			}
+			if !reflect.DeepEqual(routes, tc.routes) {
+				t.Errorf("got routes %v, expected %v", routes, tc.routes)
+			}
+		})
+	}
+}
+
+func TestGetNetworkIPs(t *testing.T) {
+	conf, err := libcni.ConfListFromBytes([]byte(dualStackConf))
+	if err != nil {
+		t.Fatalf("while parsing configuration: %s", err)
+	}
+
+	ipConfig := func(version, cidr string) *current.IPConfig {
+		ip, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("while parsing %s: %s", cidr, err)
+		}
+		ipnet.IP = ip
+		return &current.IPConfig{Version: version, Address: *ipnet}
+	}
+
+	result := &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		IPs: []*current.IPConfig{
+			ipConfig("6", "fd00:22::2/64"),
+			ipConfig("4", "10.22.0.2/16"),
+		},
+	}
+
+	cases := []struct {
+		family     IPFamily
+		expected   []string
+		shouldFail bool
+	}{
+		{family: IPDefault, expected: []string{"10.22.0.2", "fd00:22::2"}},
+		{family: DualStack, expected: []string{"10.22.0.2", "fd00:22::2"}},
+		{family: IPv4, expected: []string{"10.22.0.2"}},
+		{family: IPv6, expected: []string{"fd00:22::2"}},
+	}
+
+	for _, tc := range cases {
+		m := &Setup{
+			networkConfList: []*libcni.NetworkConfigList{conf},
+			result:          []types.Result{result},
+			ipFamily:        tc.family,
+		}
+
+		if err := m.checkIPFamily(); err != nil {
+			t.Errorf("family %q: unexpected error: %s", tc.family, err)
+		}
+
+		ips, err := m.GetNetworkIPs("")
+		if err != nil {
+			t.Errorf("family %q: unexpected error: %s", tc.family, err)
+			continue
+		}
+		var addrs []string
+		for _, ip := range ips {
+			addrs = append(addrs, ip.String())
+		}
+		if !reflect.DeepEqual(addrs, tc.expected) {
+			t.Errorf("family %q: got IPs %v, expected %v", tc.family, addrs, tc.expected)
+		}
+	}
+
+	// network without IPv6 subnet
+	ipv4Result := &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		IPs:        []*current.IPConfig{ipConfig("4", "10.22.0.2/16")},
+	}
+	m := &Setup{
+		networkConfList: []*libcni.NetworkConfigList{conf},
+		result:          []types.Result{ipv4Result},
+		ipFamily:        DualStack,
+	}
+	if err := m.checkIPFamily(); err == nil {
+		t.Errorf("unexpected success for a network without IPv6 address")
+	}
+}
+
+func TestParseIPFamily(t *testing.T) {
+	for _, s := range []string{"", "ipv4", "ipv6", "dual"} {
+		if _, err := ParseIPFamily(s); err != nil {
+			t.Errorf("unexpected error for %q: %s", s, err)
+		}
+	}
+	if _, err := ParseIPFamily("ipv5"); err == nil {
+		t.Errorf("unexpected success for ipv5")
+	}
+}
@@ -52,6 +52,7 @@ type Setup struct {
 	containerID     string
 	netNS           string
 	envPath         string
+	ipFamily        IPFamily
 }
 
 // PortMapEntry describes a port mapping between host and container
@@ -468,6 +469,14 @@ func (m *Setup) command(ctx context.Context, command string) error {
 				return err
 			}
 		}
+		if err := m.checkIPFamily(); err != nil {
+			for j := len(m.networkConfList) - 1; j >= 0; j-- {
+				if err := config.DelNetworkList(ctx, m.networkConfList[j], m.runtimeConf[j]); err != nil {
+					return err
+				}
+			}
+			return err
+		}
 	} else if command == "DEL" {
 		for i := 0; i < len(m.networkConfList); i++ {
 			if err := config.DelNetworkList(ctx, m.networkConfList[i], m.runtimeConf[i]); err != nil {
@@ -44,30 +44,23 @@ func (s *sifBundle) writeConfig(img *image.Image, g *generate.Generator) error {
 		return fmt.Errorf("failed to decode oci-config.json: %s", err)
 	}
 
-	if len(g.Config.Process.Args) == 1 && g.Config.Process.Args[0] == tools.RunScript {
-		args := imgConfig.Entrypoint
-		args = append(args, imgConfig.Cmd...)
-		if len(args) > 0 {
-			g.SetProcessArgs(args)
-		}
+	// process arguments other than the default run script are
+	// the complete command line, they replace the image entrypoint
+	var overrides tools.ProcessOverrides
+	if len(g.Config.Process.Args) != 1 || g.Config.Process.Args[0] != tools.RunScript {
+		overrides.Entrypoint = g.Config.Process.Args
 	}
+	overrides.Cwd = g.Config.Process.Cwd
+	overrides.Env = g.Config.Process.Env
 
-	if g.Config.Process.Cwd == "" && imgConfig.WorkingDir != "" {
-		g.SetProcessCwd(imgConfig.WorkingDir)
+	settings := tools.ImageProcessSettings(imgConfig, overrides)
+	if len(settings.Args) > 0 {
+		g.SetProcessArgs(settings.Args)
 	}
-	for _, e := range imgConfig.Env {
-		found := false
-		k := strings.SplitN(e, "=", 2)
-		for _, pe := range g.Config.Process.Env {
-			if strings.HasPrefix(pe, k[0]+"=") {
-				found = true
-				break
-			}
-		}
-		if !found {
-			g.AddProcessEnv(k[0], k[1])
-		}
+	if settings.Cwd != "" {
+		g.SetProcessCwd(settings.Cwd)
 	}
+	g.Config.Process.Env = settings.Env
 
 	volumes := tools.Volumes(s.bundlePath).Path()
 	for dst := range imgConfig.Volumes {
@@ -87,7 +80,9 @@ func (s *sifBundle) writeConfig(img *image.Image, g *generate.Generator) error {
 	return tools.SaveBundleConfig(s.bundlePath, g)
 }
 
-// Create creates an OCI bundle from a SIF image
+// Create creates an OCI bundle from a SIF image, the bundle created by
+// a concurrent user of the same bundle path is shared along with its
+// configuration, ociConfig is then ignored.
 func (s *sifBundle) Create(ociConfig *specs.Spec) error {
 	if s.image == "" {
 		return fmt.Errorf("image wasn't set, need one to create bundle")
@@ -114,55 +109,85 @@ func (s *sifBundle) Create(ociConfig *specs.Spec) error {
 	offset := part.Offset
 	size := part.Size
 
-	// generate OCI bundle directory and config
-	g, err := tools.GenerateBundleConfig(s.bundlePath, ociConfig)
-	if err != nil {
-		return fmt.Errorf("failed to generate OCI bundle/config: %s", err)
-	}
-
-	// associate SIF image with a block
-	loop, err := tools.CreateLoop(img.File, offset, size)
-	if err != nil {
-		tools.DeleteBundle(s.bundlePath)
-		return fmt.Errorf("failed to find loop device: %s", err)
+	// the bundle directory holds the count of the users
+	// of the root filesystem
+	if err := os.MkdirAll(s.bundlePath, 0700); err != nil {
+		return fmt.Errorf("failed to create bundle directory %s: %s", s.bundlePath, err)
 	}
 
+	// the root filesystem, with the overlay of a writable bundle,
+	// and the OCI configuration are set up by the first user of the
+	// bundle only, they are shared by the concurrent users of the
+	// bundle and the root filesystem is only unmounted once the last
+	// one deletes it
+	first := false
 	rootFs := tools.RootFs(s.bundlePath).Path()
-	if err := syscall.Mount(loop, rootFs, "squashfs", syscall.MS_RDONLY, ""); err != nil {
-		tools.DeleteBundle(s.bundlePath)
-		return fmt.Errorf("failed to mount SIF partition: %s", err)
-	}
+	err = tools.AcquireMount(rootFs, func() error {
+		first = true
 
-	if err := s.writeConfig(img, g); err != nil {
-		// best effort to release loop device
-		syscall.Unmount(rootFs, syscall.MNT_DETACH)
-		tools.DeleteBundle(s.bundlePath)
-		return fmt.Errorf("failed to write OCI configuration: %s", err)
-	}
+		// generate OCI bundle directory and config
+		g, err := tools.GenerateBundleConfig(s.bundlePath, ociConfig)
+		if err != nil {
+			return fmt.Errorf("failed to generate OCI bundle/config: %s", err)
+		}
 
-	if s.writable {
-		if err := tools.CreateOverlay(s.bundlePath); err != nil {
+		// associate SIF image with a block
+		loop, err := tools.CreateLoop(img.File, offset, size)
+		if err != nil {
+			return fmt.Errorf("failed to find loop device: %s", err)
+		}
+		if err := syscall.Mount(loop, rootFs, "squashfs", syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("failed to mount SIF partition: %s", err)
+		}
+		if s.writable {
+			if err := tools.CreateOverlay(s.bundlePath); err != nil {
+				// best effort to release loop device
+				syscall.Unmount(rootFs, syscall.MNT_DETACH)
+				return fmt.Errorf("failed to create overlay: %s", err)
+			}
+		}
+
+		if err := s.writeConfig(img, g); err != nil {
 			// best effort to release loop device
-			syscall.Unmount(rootFs, syscall.MNT_DETACH)
+			s.unmount()
+			return fmt.Errorf("failed to write OCI configuration: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// the bundle of the other users is left in place
+		if first {
 			tools.DeleteBundle(s.bundlePath)
-			return fmt.Errorf("failed to create overlay: %s", err)
 		}
+		return err
 	}
 	return nil
 }
 
-// Delete erases OCI bundle create from SIF image
-func (s *sifBundle) Delete() error {
+// unmount unmounts the root filesystem of the bundle along
+// with its overlay.
+func (s *sifBundle) unmount() error {
 	if s.writable {
 		if err := tools.DeleteOverlay(s.bundlePath); err != nil {
-			return fmt.Errorf("delete error: %s", err)
+			return err
 		}
 	}
-	// Umount rootfs
 	rootFsDir := tools.RootFs(s.bundlePath).Path()
 	if err := syscall.Unmount(rootFsDir, syscall.MNT_DETACH); err != nil {
 		return fmt.Errorf("failed to unmount %s: %s", rootFsDir, err)
 	}
+	return nil
+}
+
+// Delete erases OCI bundle create from SIF image, the bundle is kept
+// as long as other users of its root filesystem didn't delete it.
+func (s *sifBundle) Delete() error {
+	last, err := tools.ReleaseMount(tools.RootFs(s.bundlePath).Path(), s.unmount)
+	if err != nil {
+		return fmt.Errorf("delete error: %s", err)
+	} else if !last {
+		return nil
+	}
 	// delete bundle directory
 	return tools.DeleteBundle(s.bundlePath)
 }
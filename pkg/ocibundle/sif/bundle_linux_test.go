@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/buildcfg"
@@ -18,6 +19,7 @@ import (
 	"github.com/sylabs/singularity/internal/pkg/test"
 	testCache "github.com/sylabs/singularity/internal/pkg/test/tool/cache"
 	"github.com/sylabs/singularity/internal/pkg/test/tool/require"
+	"github.com/sylabs/singularity/pkg/ocibundle"
 	"github.com/sylabs/singularity/pkg/ocibundle/tools"
 )
 
@@ -118,4 +120,46 @@ func TestFromSif(t *testing.T) {
 		})
 	}
 
+	// concurrent users share the bundle created by the first one
+	t.Run("Shared", func(t *testing.T) {
+		var bundles []ocibundle.Bundle
+		for _, args := range [][]string{{tools.RunScript, "id"}, {tools.RunScript, "true"}} {
+			b, err := FromSif(sifFile, bundlePath, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := oci.DefaultConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			g.SetProcessArgs(args)
+			if err := b.Create(g.Config); err != nil {
+				t.Fatal(err)
+			}
+			bundles = append(bundles, b)
+		}
+
+		// the configuration of the first user is kept
+		config := tools.Config(bundlePath).Path()
+		data, err := ioutil.ReadFile(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"id"`) {
+			t.Errorf("configuration of the first user replaced: %s", data)
+		}
+
+		if err := bundles[0].Delete(); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(config); err != nil {
+			t.Errorf("bundle deleted while in use: %s", err)
+		}
+		if err := bundles[1].Delete(); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(bundlePath); !os.IsNotExist(err) {
+			t.Errorf("bundle left after the last user deleted it")
+		}
+	})
 }
@@ -0,0 +1,120 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tools
+
+import (
+	"strings"
+
+	imageSpecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ProcessOverrides holds the container process settings supplied by
+// the user, they take precedence over the OCI image configuration.
+type ProcessOverrides struct {
+	// Entrypoint replaces the image entrypoint when not nil, the image
+	// command is then discarded like with "docker run --entrypoint".
+	// An empty non nil slice clears the image entrypoint.
+	Entrypoint []string
+	// Args replace the image command when not empty, they are passed
+	// as arguments to the entrypoint.
+	Args []string
+	// Env lists KEY=VALUE variables overriding the image ones.
+	Env []string
+	// Cwd replaces the image working directory when not empty.
+	Cwd string
+	// User replaces the image user when not empty.
+	User string
+}
+
+// ProcessSettings are the container process settings resulting
+// from an OCI image configuration and the user overrides.
+type ProcessSettings struct {
+	// Args is the default command, nil if neither the image nor
+	// the user set one.
+	Args []string
+	// Env lists the KEY=VALUE variables of the process.
+	Env []string
+	// Cwd is the working directory, empty means the runtime default.
+	Cwd string
+	// User is the user of the process with the image configuration
+	// syntax (user, uid, user:group or uid:gid), resolving names is
+	// left to the caller as it requires the container filesystem.
+	User string
+}
+
+// ImageProcessSettings translates the OCI image configuration into
+// the container process settings, applying the user overrides with
+// the following precedence:
+//
+//   - the command is the entrypoint followed by the arguments, the
+//     user entrypoint replaces the image one and discards the image
+//     command, the user arguments replace the image command
+//   - the user environment variables replace the image ones with the
+//     same name, image variables without a value are ignored as there
+//     is no host environment to inherit them from
+//   - the user working directory and user replace the image ones
+func ImageProcessSettings(config imageSpecs.ImageConfig, o ProcessOverrides) ProcessSettings {
+	s := ProcessSettings{
+		Cwd:  config.WorkingDir,
+		User: config.User,
+	}
+
+	entrypoint := config.Entrypoint
+	args := config.Cmd
+	if o.Entrypoint != nil {
+		entrypoint = o.Entrypoint
+		args = nil
+	}
+	if len(o.Args) > 0 {
+		args = o.Args
+	}
+	if len(entrypoint)+len(args) > 0 {
+		s.Args = make([]string, 0, len(entrypoint)+len(args))
+		s.Args = append(s.Args, entrypoint...)
+		s.Args = append(s.Args, args...)
+	}
+
+	s.Env = mergeEnv(config.Env, o.Env)
+
+	if o.Cwd != "" {
+		s.Cwd = o.Cwd
+	}
+	if o.User != "" {
+		s.User = o.User
+	}
+
+	return s
+}
+
+// mergeEnv returns the image variables, in their order, replaced by
+// the override variables with the same name, followed by the remaining
+// override variables. The last definition of a variable wins.
+func mergeEnv(image, overrides []string) []string {
+	var env []string
+	index := make(map[string]int)
+
+	set := func(e string) {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return
+		}
+		if i, ok := index[kv[0]]; ok {
+			env[i] = e
+			return
+		}
+		index[kv[0]] = len(env)
+		env = append(env, e)
+	}
+
+	for _, e := range image {
+		set(e)
+	}
+	for _, e := range overrides {
+		set(e)
+	}
+
+	return env
+}
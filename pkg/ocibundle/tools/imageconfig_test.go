@@ -0,0 +1,142 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	imageSpecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestImageProcessSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    imageSpecs.ImageConfig
+		overrides ProcessOverrides
+		expected  ProcessSettings
+	}{
+		{
+			name:     "Empty",
+			expected: ProcessSettings{},
+		},
+		{
+			name:     "EntrypointOnly",
+			config:   imageSpecs.ImageConfig{Entrypoint: []string{"/bin/app", "--serve"}},
+			expected: ProcessSettings{Args: []string{"/bin/app", "--serve"}},
+		},
+		{
+			name:      "EntrypointOnlyWithArgs",
+			config:    imageSpecs.ImageConfig{Entrypoint: []string{"/bin/app"}},
+			overrides: ProcessOverrides{Args: []string{"--port", "80"}},
+			expected:  ProcessSettings{Args: []string{"/bin/app", "--port", "80"}},
+		},
+		{
+			name:     "CmdOnly",
+			config:   imageSpecs.ImageConfig{Cmd: []string{"/bin/sh", "-c", "echo hello"}},
+			expected: ProcessSettings{Args: []string{"/bin/sh", "-c", "echo hello"}},
+		},
+		{
+			name:      "CmdOnlyWithArgs",
+			config:    imageSpecs.ImageConfig{Cmd: []string{"/bin/sh"}},
+			overrides: ProcessOverrides{Args: []string{"/bin/date", "-u"}},
+			expected:  ProcessSettings{Args: []string{"/bin/date", "-u"}},
+		},
+		{
+			name: "EntrypointAndCmd",
+			config: imageSpecs.ImageConfig{
+				Entrypoint: []string{"/docker-entrypoint.sh"},
+				Cmd:        []string{"nginx", "-g", "daemon off;"},
+			},
+			expected: ProcessSettings{Args: []string{"/docker-entrypoint.sh", "nginx", "-g", "daemon off;"}},
+		},
+		{
+			name: "EntrypointAndCmdWithArgs",
+			config: imageSpecs.ImageConfig{
+				Entrypoint: []string{"/docker-entrypoint.sh"},
+				Cmd:        []string{"nginx"},
+			},
+			overrides: ProcessOverrides{Args: []string{"nginx-debug"}},
+			expected:  ProcessSettings{Args: []string{"/docker-entrypoint.sh", "nginx-debug"}},
+		},
+		{
+			name: "EntrypointOverride",
+			config: imageSpecs.ImageConfig{
+				Entrypoint: []string{"/docker-entrypoint.sh"},
+				Cmd:        []string{"nginx"},
+			},
+			overrides: ProcessOverrides{Entrypoint: []string{"/bin/sh"}},
+			expected:  ProcessSettings{Args: []string{"/bin/sh"}},
+		},
+		{
+			name: "EntrypointOverrideWithArgs",
+			config: imageSpecs.ImageConfig{
+				Entrypoint: []string{"/docker-entrypoint.sh"},
+				Cmd:        []string{"nginx"},
+			},
+			overrides: ProcessOverrides{Entrypoint: []string{"/bin/sh"}, Args: []string{"-c", "id"}},
+			expected:  ProcessSettings{Args: []string{"/bin/sh", "-c", "id"}},
+		},
+		{
+			name: "EntrypointCleared",
+			config: imageSpecs.ImageConfig{
+				Entrypoint: []string{"/docker-entrypoint.sh"},
+				Cmd:        []string{"nginx"},
+			},
+			overrides: ProcessOverrides{Entrypoint: []string{}, Args: []string{"/bin/ls"}},
+			expected:  ProcessSettings{Args: []string{"/bin/ls"}},
+		},
+		{
+			name: "Env",
+			config: imageSpecs.ImageConfig{
+				Env: []string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8", "EMPTY=", "NOVALUE", "LANG=en_US.UTF-8"},
+			},
+			overrides: ProcessOverrides{Env: []string{"HOME=/root", "PATH=/bin"}},
+			expected: ProcessSettings{
+				Env: []string{"PATH=/bin", "LANG=en_US.UTF-8", "EMPTY=", "HOME=/root"},
+			},
+		},
+		{
+			name:     "WorkingDirAndUser",
+			config:   imageSpecs.ImageConfig{WorkingDir: "/srv", User: "nginx:nginx"},
+			expected: ProcessSettings{Cwd: "/srv", User: "nginx:nginx"},
+		},
+		{
+			name:      "WorkingDirAndUserOverride",
+			config:    imageSpecs.ImageConfig{WorkingDir: "/srv", User: "nginx"},
+			overrides: ProcessOverrides{Cwd: "/tmp", User: "1000:1000"},
+			expected:  ProcessSettings{Cwd: "/tmp", User: "1000:1000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ImageProcessSettings(tt.config, tt.overrides)
+			if !reflect.DeepEqual(s, tt.expected) {
+				t.Errorf("unexpected settings:\ngot:      %+v\nexpected: %+v", s, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImageProcessSettingsNoAliasing(t *testing.T) {
+	// the entrypoint has spare capacity which must not be
+	// overwritten by the command
+	entrypoint := make([]string, 1, 4)
+	entrypoint[0] = "/bin/app"
+	config := imageSpecs.ImageConfig{Entrypoint: entrypoint, Cmd: []string{"run"}}
+
+	s := ImageProcessSettings(config, ProcessOverrides{})
+	s.Args[0] = "/bin/other"
+	_ = append(config.Entrypoint, "modified")
+
+	if config.Entrypoint[0] != "/bin/app" {
+		t.Errorf("image entrypoint modified: %v", config.Entrypoint)
+	}
+	if !reflect.DeepEqual(s.Args, []string{"/bin/other", "run"}) {
+		t.Errorf("process arguments share the image entrypoint: %v", s.Args)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountRefs is the locked file counting the users of a mount point,
+// shared by all the processes using it.
+type mountRefs struct {
+	file  *os.File
+	count int
+}
+
+// mountRefsPath returns the path of the file counting the users of
+// the mount point target, a hidden file next to it.
+func mountRefsPath(target string) string {
+	return filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".refs")
+}
+
+// lockMountRefs opens and locks the file counting the users of the
+// mount point target, created with no user if it doesn't exist. The
+// lock is held until close is called.
+func lockMountRefs(target string) (*mountRefs, error) {
+	path := mountRefsPath(target)
+
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %s", path, err)
+		}
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %s", path, err)
+		}
+
+		// the last user may have removed the file while the lock
+		// was awaited, the counter is then a new file
+		var locked, current syscall.Stat_t
+		if err := syscall.Fstat(int(f.Fd()), &locked); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to stat %s: %s", path, err)
+		}
+		if err := syscall.Stat(path, &current); err != nil || current.Ino != locked.Ino || current.Dev != locked.Dev {
+			f.Close()
+			continue
+		}
+
+		r := &mountRefs{file: f}
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %s", path, err)
+		}
+		if s := strings.TrimSpace(string(data)); s != "" {
+			r.count, err = strconv.Atoi(s)
+			if err != nil || r.count < 0 {
+				f.Close()
+				return nil, fmt.Errorf("invalid mount reference count %q in %s", s, path)
+			}
+		}
+		return r, nil
+	}
+}
+
+// update records count users of the mount point, the file is removed
+// once there are none left.
+func (r *mountRefs) update(count int) error {
+	if count == 0 {
+		return os.Remove(r.file.Name())
+	}
+	if err := r.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := r.file.WriteAt([]byte(strconv.Itoa(count)+"\n"), 0)
+	return err
+}
+
+// close releases the lock of the file.
+func (r *mountRefs) close() error {
+	return r.file.Close()
+}
+
+// AcquireMount records one more user of the mount point target, which
+// is mounted by calling mount for the first user only, the next users
+// share it until they call ReleaseMount. The users are counted across
+// processes, so concurrent processes can share a mount point.
+//
+// A user is not tied to a process, the user of an OCI bundle spans an
+// "oci mount" run and the later "oci umount" run, so a user which never
+// calls ReleaseMount, like a crashed runtime never unmounting its bundle,
+// can't be told apart from a live one: the mount point is kept until
+// ReleaseMount is called once more for each such user.
+func AcquireMount(target string, mount func() error) error {
+	r, err := lockMountRefs(target)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+
+	if r.count == 0 {
+		if err := mount(); err != nil {
+			r.update(0)
+			return err
+		}
+	}
+	if err := r.update(r.count + 1); err != nil {
+		return fmt.Errorf("failed to record user of %s: %s", target, err)
+	}
+	return nil
+}
+
+// ReleaseMount records one less user of the mount point target, see
+// AcquireMount, and unmounts it by calling unmount once the last user
+// released it. It returns whether the mount point was unmounted. A
+// mount point without recorded users, as mounted by previous versions,
+// has a single user.
+func ReleaseMount(target string, unmount func() error) (bool, error) {
+	r, err := lockMountRefs(target)
+	if err != nil {
+		return false, err
+	}
+	defer r.close()
+
+	if r.count > 1 {
+		if err := r.update(r.count - 1); err != nil {
+			return false, fmt.Errorf("failed to record user of %s: %s", target, err)
+		}
+		return false, nil
+	}
+
+	if err := unmount(); err != nil {
+		// the remaining user may retry
+		if r.count == 0 {
+			r.update(0)
+		}
+		return false, err
+	}
+	if err := r.update(0); err != nil {
+		return true, fmt.Errorf("failed to remove %s: %s", mountRefsPath(target), err)
+	}
+	return true, nil
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package tools
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/sylabs/singularity/internal/pkg/test"
+)
+
+func TestMountRefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mountref-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "rootfs")
+	var mounts, unmounts int
+	mount := func() error { mounts++; return nil }
+	unmount := func() error { unmounts++; return nil }
+
+	// a failed mount records no user
+	if err := AcquireMount(target, func() error { return errors.New("failed") }); err == nil {
+		t.Errorf("unexpected success with a failed mount")
+	}
+	if _, err := os.Stat(mountRefsPath(target)); !os.IsNotExist(err) {
+		t.Errorf("users recorded for a failed mount")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := AcquireMount(target, mount); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if mounts != 1 {
+		t.Errorf("mounted %d times, expected once", mounts)
+	}
+
+	// a failed unmount keeps the last user
+	if last, err := ReleaseMount(target, unmount); err != nil || last {
+		t.Errorf("unexpected release result %v, %v", last, err)
+	}
+	if last, err := ReleaseMount(target, func() error { return errors.New("failed") }); err == nil || last {
+		t.Errorf("unexpected release result %v, %v with a failed unmount", last, err)
+	}
+	if last, err := ReleaseMount(target, unmount); err != nil || !last {
+		t.Errorf("unexpected release result %v, %v for the last user", last, err)
+	}
+	if unmounts != 1 {
+		t.Errorf("unmounted %d times, expected once", unmounts)
+	}
+	if _, err := os.Stat(mountRefsPath(target)); !os.IsNotExist(err) {
+		t.Errorf("users file left after the last release")
+	}
+
+	// a mount point without recorded users has a single one
+	if last, err := ReleaseMount(target, unmount); err != nil || !last {
+		t.Errorf("unexpected release result %v, %v without recorded users", last, err)
+	}
+
+	// corrupted counter
+	if err := ioutil.WriteFile(mountRefsPath(target), []byte("-1\n"), 0600); err != nil {
+		t.Fatalf("while writing users file: %s", err)
+	}
+	if err := AcquireMount(target, mount); err == nil {
+		t.Errorf("unexpected success with an invalid users file")
+	}
+}
+
+func TestMountRefsConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mountref-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "rootfs")
+
+	var mu sync.Mutex
+	mounted := false
+	mount := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if mounted {
+			return errors.New("already mounted")
+		}
+		mounted = true
+		return nil
+	}
+	unmount := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !mounted {
+			return errors.New("not mounted")
+		}
+		mounted = false
+		return nil
+	}
+
+	// each round of concurrent users mounts and unmounts once, the
+	// users file being removed and recreated across rounds
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				if err := AcquireMount(target, mount); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := ReleaseMount(target, unmount); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if mounted {
+		t.Errorf("mount point left mounted")
+	}
+}
+
+func TestSharedMount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	test.EnsurePrivilege(t)
+
+	dir, err := ioutil.TempDir("", "mountref-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	lower := filepath.Join(dir, "lower")
+	target := filepath.Join(dir, "rootfs")
+	for _, d := range []string{lower, target} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("while creating %s: %s", d, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(lower, "file"), []byte("shared"), 0644); err != nil {
+		t.Fatalf("while writing file: %s", err)
+	}
+
+	mount := func() error {
+		return syscall.Mount(lower, target, "", syscall.MS_BIND, "")
+	}
+	unmount := func() error {
+		return syscall.Unmount(target, syscall.MNT_DETACH)
+	}
+	defer syscall.Unmount(target, syscall.MNT_DETACH)
+
+	// two setups share the bind mount
+	for i := 0; i < 2; i++ {
+		if err := AcquireMount(target, mount); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// the cleanup of the first one leaves it to the second one
+	if last, err := ReleaseMount(target, unmount); err != nil || last {
+		t.Fatalf("unexpected release result %v, %v", last, err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(target, "file")); err != nil || string(data) != "shared" {
+		t.Errorf("shared mount broken by the first cleanup: %q, %v", data, err)
+	}
+
+	if last, err := ReleaseMount(target, unmount); err != nil || !last {
+		t.Fatalf("unexpected release result %v, %v", last, err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "file")); !os.IsNotExist(err) {
+		t.Errorf("mount point left mounted after the last cleanup")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"syscall"
 
 	"github.com/sylabs/singularity/pkg/runtime/engine/config"
+	singularityConfig "github.com/sylabs/singularity/pkg/runtime/engine/singularity/config"
 )
 
 // MonitorContainer callback allows to monitor container process.
@@ -26,3 +27,11 @@ type MonitorContainer func(config *config.Common, pid int, signals chan os.Signa
 // This callback is called in:
 // - internal/pkg/runtime/engine/singularity/process_linux.go
 type PostStartProcess func(config *config.Common, pid int) error
+
+// ContainerStartup callback returns the commands to execute inside
+// the container, once mounts are set up, before the user command.
+// Commands of all plugins are executed in their dependency order,
+// the container execution is aborted if one of them fails.
+// This callback is called in:
+// - internal/pkg/runtime/engine/singularity/prepare_linux.go
+type ContainerStartup func(config *config.Common) ([]singularityConfig.StartupCommand, error)
@@ -0,0 +1,51 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+// Capability identifies a privileged part of Singularity a plugin
+// intends to hook, plugins declare them in their manifest so that
+// administrators can review and restrict them.
+type Capability string
+
+const (
+	// CapabilityCLICommands allows registering command line
+	// commands and flags (cli.Command callbacks).
+	CapabilityCLICommands Capability = "cli-commands"
+	// CapabilityRuntimeConfig allows mutating the runtime engine
+	// configuration (cli.SingularityEngineConfig callbacks).
+	CapabilityRuntimeConfig Capability = "runtime-config"
+	// CapabilityUserMapping allows providing the user namespace
+	// mappings of fakeroot (fakeroot.UserMapping callbacks).
+	CapabilityUserMapping Capability = "user-mapping"
+	// CapabilityContainerMonitor allows monitoring the container
+	// processes (singularity.MonitorContainer and
+	// singularity.PostStartProcess callbacks).
+	CapabilityContainerMonitor Capability = "container-monitor"
+	// CapabilityExecBinaries allows executing external binaries,
+	// including the startup commands of the container
+	// (singularity.ContainerStartup callbacks). A plugin executing
+	// binaries from any other callback must declare it as well.
+	CapabilityExecBinaries Capability = "exec-binaries"
+)
+
+// Capabilities lists the capabilities known by this version.
+var Capabilities = []Capability{
+	CapabilityCLICommands,
+	CapabilityRuntimeConfig,
+	CapabilityUserMapping,
+	CapabilityContainerMonitor,
+	CapabilityExecBinaries,
+}
+
+// Valid returns whether c is a capability known by this version.
+func (c Capability) Valid() bool {
+	for _, known := range Capabilities {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+// ConfigType is the type of the value of a plugin configuration key.
+type ConfigType string
+
+const (
+	// ConfigTypeString is a string value, any scalar is accepted.
+	ConfigTypeString ConfigType = "string"
+	// ConfigTypeBool is a boolean value.
+	ConfigTypeBool ConfigType = "bool"
+	// ConfigTypeInt is an integer value.
+	ConfigTypeInt ConfigType = "int"
+	// ConfigTypeFloat is a floating point value, integers are accepted.
+	ConfigTypeFloat ConfigType = "float"
+	// ConfigTypeDuration is a duration with the time.ParseDuration
+	// syntax (eg: 1m30s).
+	ConfigTypeDuration ConfigType = "duration"
+	// ConfigTypeList is a list of strings, given as comma separated
+	// values for defaults and on the command line.
+	ConfigTypeList ConfigType = "list"
+)
+
+// ConfigTypes lists the configuration types known by this version.
+var ConfigTypes = []ConfigType{
+	ConfigTypeString,
+	ConfigTypeBool,
+	ConfigTypeInt,
+	ConfigTypeFloat,
+	ConfigTypeDuration,
+	ConfigTypeList,
+}
+
+// Valid returns whether t is a configuration type known by this version.
+func (t ConfigType) Valid() bool {
+	for _, known := range ConfigTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigOption describes a key of the plugin configuration file, the
+// options declared in the manifest form the schema the configuration
+// file is validated against.
+type ConfigOption struct {
+	// Key is the name of the key, the keys of nested sections are
+	// joined by a dot (eg: server.port).
+	Key string `json:"key"`
+	// Type is the type of the value.
+	Type ConfigType `json:"type"`
+	// Default is the default value written in the configuration file
+	// generated at installation, with the syntax of the environment
+	// variables overriding the key. The key is left commented out in
+	// the generated file when empty.
+	Default string `json:"default,omitempty"`
+	// Description documents the key, it's written as a comment in the
+	// generated configuration file and displayed by "plugin inspect".
+	Description string `json:"description,omitempty"`
+	// Overridable allows the environment variable overriding the key
+	// to be honored when the configuration is loaded by a privileged
+	// (setuid) process, the overrides of the other keys are ignored
+	// there. Unprivileged processes honor all the overrides. It also
+	// allows the key to be set by the user configuration overlay,
+	// ~/.singularity/plugins/<name>/config.yaml, which is ignored by
+	// privileged processes.
+	Overridable bool `json:"overridable,omitempty"`
+}
+
+// ConfigFormat is the syntax of the plugin configuration file.
+type ConfigFormat string
+
+const (
+	// ConfigFormatYAML is the YAML syntax, it's the default.
+	ConfigFormatYAML ConfigFormat = "yaml"
+	// ConfigFormatJSON is the JSON syntax, which has no comments so
+	// the generated configuration file doesn't document the keys.
+	ConfigFormatJSON ConfigFormat = "json"
+	// ConfigFormatTOML is the TOML syntax.
+	ConfigFormatTOML ConfigFormat = "toml"
+)
+
+// ConfigFormats lists the configuration formats known by this version.
+var ConfigFormats = []ConfigFormat{
+	ConfigFormatYAML,
+	ConfigFormatJSON,
+	ConfigFormatTOML,
+}
+
+// Valid returns whether f is a configuration format known by this
+// version, an empty format stands for ConfigFormatYAML.
+func (f ConfigFormat) Valid() bool {
+	if f == "" {
+		return true
+	}
+	for _, known := range ConfigFormats {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigMigration describes how the configuration file of the previous
+// versions of the plugin is transformed when the plugin is upgraded:
+// the keys are renamed and removed as declared, and the keys added to
+// the configuration schema since the previous version are set to their
+// default value. The configuration is left untouched on upgrade when
+// no migration is declared.
+type ConfigMigration struct {
+	// Renamed maps the former name of a key to its current name.
+	Renamed map[string]string `json:"renamed,omitempty"`
+	// Removed lists the keys the plugin doesn't use anymore.
+	Removed []string `json:"removed,omitempty"`
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Deprecation marks a plugin as deprecated. In the manifest JSON it's
+// either a boolean or a message explaining the deprecation, a message
+// implying the plugin is deprecated:
+//
+//	"deprecated": true
+//	"deprecated": "superseded by the v2 plugin, no longer maintained"
+type Deprecation struct {
+	// Message explains the deprecation, it may be empty.
+	Message string
+
+	// cleared is set when the manifest explicitly sets
+	// "deprecated": false.
+	cleared bool
+}
+
+// IsDeprecated returns whether d marks the plugin as deprecated,
+// d may be nil.
+func (d *Deprecation) IsDeprecated() bool {
+	return d != nil && !d.cleared
+}
+
+// MarshalJSON encodes d as a message, or as a boolean
+// when there is none.
+func (d Deprecation) MarshalJSON() ([]byte, error) {
+	if d.cleared {
+		return []byte("false"), nil
+	}
+	if d.Message == "" {
+		return []byte("true"), nil
+	}
+	return json.Marshal(d.Message)
+}
+
+// UnmarshalJSON decodes either a boolean or a message, an invalid
+// value doesn't mark the plugin as deprecated.
+func (d *Deprecation) UnmarshalJSON(data []byte) error {
+	*d = Deprecation{cleared: true}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*d = Deprecation{cleared: !v}
+	case string:
+		*d = Deprecation{Message: v}
+	default:
+		return fmt.Errorf("cannot unmarshal %s into a deprecation, expected a boolean or a string", data)
+	}
+	return nil
+}
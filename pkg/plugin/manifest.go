@@ -19,8 +19,115 @@ type Manifest struct {
 	Name string `json:"name"`
 	// Author of the plugin.
 	Author string `json:"author"`
-	// Version describes the SemVer of the plugin.
+	// Version describes the SemVer of the plugin, optionally prefixed
+	// by "v" (eg: v1.2.0). A version which is not a semantic version is
+	// accepted with a warning. It can be stamped during compilation (see
+	// "singularity plugin compile --version").
 	Version string `json:"version"`
 	// Description describes the plugin.
 	Description string `json:"description"`
+	// Isolated requests the plugin to be run in a separate process
+	// instead of being loaded into the singularity process, so that
+	// a plugin crash doesn't take singularity down with it. Only the
+	// runtime callbacks can be served by an isolated plugin.
+	Isolated bool `json:"isolated,omitempty"`
+	// Dependencies lists the plugins which must be installed
+	// for this plugin to work.
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+	// APIVersion is the version of the plugin API the plugin was
+	// compiled against, it's stamped by "singularity plugin compile"
+	// and should be set to the APIVersion constant. Plugins compiled
+	// before API versions were introduced have it unset.
+	APIVersion int `json:"apiVersion,omitempty"`
+	// License is the SPDX license expression of the plugin
+	// (eg: BSD-3-Clause or "MIT OR Apache-2.0").
+	License string `json:"license,omitempty"`
+	// Homepage is the http(s) URL of the plugin home page.
+	Homepage string `json:"homepage,omitempty"`
+	// Repository is the http(s) URL of the plugin source repository.
+	Repository string `json:"repository,omitempty"`
+	// MaintainerEmail is the email address of the plugin maintainer.
+	MaintainerEmail string `json:"maintainerEmail,omitempty"`
+	// Architectures lists the architectures, with Go naming (eg: amd64,
+	// arm64), for which the plugin image contains a binary. It's checked
+	// against the binaries actually present at installation.
+	Architectures []string `json:"architectures,omitempty"`
+	// Capabilities lists the privileged parts of Singularity the
+	// plugin hooks, each callback registered by the plugin must be
+	// covered by one of them (see Capability). Plugins declaring no
+	// capabilities are handled according to singularity.conf.
+	Capabilities []Capability `json:"capabilities,omitempty"`
+	// Hooks lists the callbacks registered by the plugin, named after
+	// their package and type (eg: cli.Command). The callbacks the
+	// plugin object actually registers are compared with this list
+	// at installation and by "singularity plugin check".
+	Hooks []string `json:"hooks,omitempty"`
+	// Keywords are the tags describing the plugin (eg: gpu, network),
+	// they are lowercase letters, digits and dashes. They are recorded
+	// at installation to filter the installed plugins, and are the
+	// field to index for searching plugins.
+	Keywords []string `json:"keywords,omitempty"`
+	// Config describes the keys of the plugin configuration file. When
+	// set, the default configuration file is generated from it and the
+	// configuration file is validated against it, otherwise the
+	// configuration file is free-form.
+	Config []ConfigOption `json:"config,omitempty"`
+	// ConfigFormat is the syntax of the plugin configuration file,
+	// YAML when unset. The default configuration file is generated
+	// in this format, LoadConfig decodes it whatever the format.
+	ConfigFormat ConfigFormat `json:"configFormat,omitempty"`
+	// ConfigMigration is applied to the configuration file of the
+	// installed plugin when it's upgraded, the configuration before
+	// the migration is preserved next to it.
+	ConfigMigration *ConfigMigration `json:"configMigration,omitempty"`
+	// Deprecated marks the plugin as deprecated, it's advisory only:
+	// users are notified at installation and when the plugin is
+	// loaded, but the plugin keeps working.
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
+	// ReplacedBy is the name, or the http(s) URL, of the plugin
+	// superseding a deprecated plugin.
+	ReplacedBy string `json:"replacedBy,omitempty"`
+	// BinaryCompression is the compression, gzip or zstd, of the plugin
+	// binary objects stored in the plugin image, they are uncompressed
+	// when unset. It's set by "singularity plugin compile --compress".
+	BinaryCompression string `json:"binaryCompression,omitempty"`
+	// BinarySizes are the uncompressed sizes in bytes of the compressed
+	// plugin binary objects by architecture, with Go naming (eg: amd64).
+	// A binary not decompressing to its declared size is rejected at
+	// installation.
+	BinarySizes map[string]int64 `json:"binarySizes,omitempty"`
+	// PrimaryBinary is the SIF descriptor name of the plugin binary
+	// object to load when the plugin image holds other binary objects,
+	// like helpers, for the same architecture. It defaults to plugin.so.
+	PrimaryBinary string `json:"primaryBinary,omitempty"`
+	// Assets are the generic data objects of the plugin image extracted
+	// at installation into the assets directory of the plugin (see
+	// Plugin.AssetsPath). The objects named with the asset: prefix are
+	// extracted too, to their name without the prefix.
+	Assets []Asset `json:"assets,omitempty"`
+}
+
+// Dependency describes a plugin required by another plugin.
+type Dependency struct {
+	// Name is the name of the required plugin.
+	Name string `json:"name"`
+	// Version is an optional constraint on the version of the
+	// required plugin. It's a comma separated list of comparisons
+	// which must all be satisfied, eg: ">=1.2.0, <2". Comparison
+	// operators are =, !=, >, >=, < and <=, = being the default one.
+	// Versions with missing minor or patch numbers match all the
+	// versions starting with the given numbers, so "1.2" is the same
+	// as ">=1.2.0, <1.3.0". A pre-release version only satisfies a
+	// constraint comparing it to a pre-release of the same version,
+	// so "1.3.0-rc.1" satisfies ">=1.3.0-rc.0" but not ">=1.2.0".
+	Version string `json:"version,omitempty"`
+}
+
+// Asset is a data file shipped in the plugin image.
+type Asset struct {
+	// Descriptor is the SIF descriptor name of the asset data object.
+	Descriptor string `json:"descriptor"`
+	// Path is the path of the extracted file relative to the assets
+	// directory, absolute paths and paths containing '..' are rejected.
+	Path string `json:"path"`
 }
@@ -9,6 +9,16 @@ package plugin
 // plugin implementations MUST define.
 const PluginSymbol = "Plugin"
 
+// APIVersion is the version of the plugin API implemented by this
+// version of Singularity. It's incremented on every incompatible change
+// of the plugin API, plugins are stamped with the version they were
+// compiled against (see Manifest.APIVersion).
+const APIVersion = 1
+
+// MinAPIVersion is the oldest plugin API version still supported, plugins
+// compiled against an older version must be recompiled to be loaded.
+const MinAPIVersion = 1
+
 // Plugin is the "meta-type" which encompasses the plugins
 // implementation through Callbacks and a Manifest
 // (potentially more to be added). The plugin implementation must
@@ -29,6 +39,9 @@ const PluginSymbol = "Plugin"
 //                     Author:      "Sylabs Team",
 //                     Version:     "v0.0.1",
 //                     Description: "This is an example plugin",
+//                     Capabilities: []pluginapi.Capability{
+//                             pluginapi.CapabilityCLICommands,
+//                     },
 //             },
 //             Callbacks: []pluginapi.Callback{
 //				       (clicallback.Command)(callbackRegisterCmd),
@@ -51,6 +64,12 @@ type Plugin struct {
 	// to store configuration files/datas needed by a
 	// plugin.
 	Install func(string) error
+	// AssetsPath is the directory holding the assets extracted
+	// from the plugin image at installation (see Manifest.Assets).
+	// It's set by Singularity when the plugin is loaded, before
+	// calling Install and any callback, and is empty for plugins
+	// without assets.
+	AssetsPath string
 }
 
 // Callback defines a plugin callback. Available callbacks are
@@ -11,6 +11,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/sylabs/singularity/internal/pkg/instance"
 	"github.com/sylabs/singularity/internal/pkg/runtime/engine/config/oci"
 	"github.com/sylabs/singularity/pkg/image"
 	"github.com/sylabs/singularity/pkg/util/singularityconf"
@@ -102,53 +103,63 @@ func (b *BindPath) Readonly() bool {
 
 // JSONConfig stores engine specific confguration that is allowed to be set by the user.
 type JSONConfig struct {
-	ScratchDir        []string      `json:"scratchdir,omitempty"`
-	OverlayImage      []string      `json:"overlayImage,omitempty"`
-	NetworkArgs       []string      `json:"networkArgs,omitempty"`
-	Security          []string      `json:"security,omitempty"`
-	FilesPath         []string      `json:"filesPath,omitempty"`
-	LibrariesPath     []string      `json:"librariesPath,omitempty"`
-	FuseMount         []FuseMount   `json:"fuseMount,omitempty"`
-	ImageList         []image.Image `json:"imageList,omitempty"`
-	BindPath          []BindPath    `json:"bindpath,omitempty"`
-	UnixSocketPair    [2]int        `json:"unixSocketPair,omitempty"`
-	OpenFd            []int         `json:"openFd,omitempty"`
-	TargetGID         []int         `json:"targetGID,omitempty"`
-	Image             string        `json:"image"`
-	Workdir           string        `json:"workdir,omitempty"`
-	CgroupsPath       string        `json:"cgroupsPath,omitempty"`
-	HomeSource        string        `json:"homedir,omitempty"`
-	HomeDest          string        `json:"homeDest,omitempty"`
-	Command           string        `json:"command,omitempty"`
-	Shell             string        `json:"shell,omitempty"`
-	TmpDir            string        `json:"tmpdir,omitempty"`
-	AddCaps           string        `json:"addCaps,omitempty"`
-	DropCaps          string        `json:"dropCaps,omitempty"`
-	Hostname          string        `json:"hostname,omitempty"`
-	Network           string        `json:"network,omitempty"`
-	DNS               string        `json:"dns,omitempty"`
-	Cwd               string        `json:"cwd,omitempty"`
-	SessionLayer      string        `json:"sessionLayer,omitempty"`
-	EncryptionKey     []byte        `json:"encryptionKey,omitempty"`
-	TargetUID         int           `json:"targetUID,omitempty"`
-	WritableImage     bool          `json:"writableImage,omitempty"`
-	WritableTmpfs     bool          `json:"writableTmpfs,omitempty"`
-	Contain           bool          `json:"container,omitempty"`
-	Nv                bool          `json:"nv,omitempty"`
-	Rocm              bool          `json:"rocm,omitempty"`
-	CustomHome        bool          `json:"customHome,omitempty"`
-	Instance          bool          `json:"instance,omitempty"`
-	InstanceJoin      bool          `json:"instanceJoin,omitempty"`
-	BootInstance      bool          `json:"bootInstance,omitempty"`
-	RunPrivileged     bool          `json:"runPrivileged,omitempty"`
-	AllowSUID         bool          `json:"allowSUID,omitempty"`
-	KeepPrivs         bool          `json:"keepPrivs,omitempty"`
-	NoPrivs           bool          `json:"noPrivs,omitempty"`
-	NoHome            bool          `json:"noHome,omitempty"`
-	NoInit            bool          `json:"noInit,omitempty"`
-	DeleteImage       bool          `json:"deleteImage,omitempty"`
-	Fakeroot          bool          `json:"fakeroot,omitempty"`
-	SignalPropagation bool          `json:"signalPropagation,omitempty"`
+	ScratchDir        []string              `json:"scratchdir,omitempty"`
+	OverlayImage      []string              `json:"overlayImage,omitempty"`
+	NetworkArgs       []string              `json:"networkArgs,omitempty"`
+	NetworkIPFamily   string                `json:"networkIPFamily,omitempty"`
+	Security          []string              `json:"security,omitempty"`
+	FilesPath         []string              `json:"filesPath,omitempty"`
+	LibrariesPath     []string              `json:"librariesPath,omitempty"`
+	FuseMount         []FuseMount           `json:"fuseMount,omitempty"`
+	StartupCommands   []StartupCommand      `json:"startupCommands,omitempty"`
+	ImageList         []image.Image         `json:"imageList,omitempty"`
+	HealthCheck       *instance.HealthCheck `json:"healthCheck,omitempty"`
+	BindPath          []BindPath            `json:"bindpath,omitempty"`
+	UnixSocketPair    [2]int                `json:"unixSocketPair,omitempty"`
+	OpenFd            []int                 `json:"openFd,omitempty"`
+	TargetGID         []int                 `json:"targetGID,omitempty"`
+	Image             string                `json:"image"`
+	Workdir           string                `json:"workdir,omitempty"`
+	CgroupsPath       string                `json:"cgroupsPath,omitempty"`
+	HomeSource        string                `json:"homedir,omitempty"`
+	HomeDest          string                `json:"homeDest,omitempty"`
+	Command           string                `json:"command,omitempty"`
+	Shell             string                `json:"shell,omitempty"`
+	TmpDir            string                `json:"tmpdir,omitempty"`
+	AddCaps           string                `json:"addCaps,omitempty"`
+	DropCaps          string                `json:"dropCaps,omitempty"`
+	Hostname          string                `json:"hostname,omitempty"`
+	Network           string                `json:"network,omitempty"`
+	DNS               string                `json:"dns,omitempty"`
+	ResolvConf        []byte                `json:"resolvConf,omitempty"`
+	Cwd               string                `json:"cwd,omitempty"`
+	SessionLayer      string                `json:"sessionLayer,omitempty"`
+	EncryptionKey     []byte                `json:"encryptionKey,omitempty"`
+	TargetUID         int                   `json:"targetUID,omitempty"`
+	WritableImage     bool                  `json:"writableImage,omitempty"`
+	WritableTmpfs     bool                  `json:"writableTmpfs,omitempty"`
+	WritableTmpfsSize uint64                `json:"writableTmpfsSize,omitempty"`
+	Contain           bool                  `json:"container,omitempty"`
+	Nv                bool                  `json:"nv,omitempty"`
+	Rocm              bool                  `json:"rocm,omitempty"`
+	CustomHome        bool                  `json:"customHome,omitempty"`
+	Instance          bool                  `json:"instance,omitempty"`
+	InstanceJoin      bool                  `json:"instanceJoin,omitempty"`
+	BootInstance      bool                  `json:"bootInstance,omitempty"`
+	RunPrivileged     bool                  `json:"runPrivileged,omitempty"`
+	AllowSUID         bool                  `json:"allowSUID,omitempty"`
+	KeepPrivs         bool                  `json:"keepPrivs,omitempty"`
+	NoPrivs           bool                  `json:"noPrivs,omitempty"`
+	NoHome            bool                  `json:"noHome,omitempty"`
+	NoInit            bool                  `json:"noInit,omitempty"`
+	Init              string                `json:"init,omitempty"`
+	NoHostCerts       bool                  `json:"noHostCerts,omitempty"`
+	NoMount           []string              `json:"noMount,omitempty"`
+	NoDeviceCgroup    bool                  `json:"noDeviceCgroup,omitempty"`
+	UsernsOnly        bool                  `json:"usernsOnly,omitempty"`
+	DeleteImage       bool                  `json:"deleteImage,omitempty"`
+	Fakeroot          bool                  `json:"fakeroot,omitempty"`
+	SignalPropagation bool                  `json:"signalPropagation,omitempty"`
 }
 
 // SetImage sets the container image path to be used by EngineConfig.JSON.
@@ -455,6 +466,16 @@ func (e *EngineConfig) GetInstance() bool {
 	return e.JSON.Instance
 }
 
+// SetHealthCheck sets the health check of the instance.
+func (e *EngineConfig) SetHealthCheck(hc *instance.HealthCheck) {
+	e.JSON.HealthCheck = hc
+}
+
+// GetHealthCheck returns the health check of the instance, if any.
+func (e *EngineConfig) GetHealthCheck() *instance.HealthCheck {
+	return e.JSON.HealthCheck
+}
+
 // SetInstanceJoin sets if process joins an instance or not.
 func (e *EngineConfig) SetInstanceJoin(join bool) {
 	e.JSON.InstanceJoin = join
@@ -545,6 +566,18 @@ func (e *EngineConfig) GetNoHome() bool {
 	return e.JSON.NoHome
 }
 
+// SetNoHostCerts sets the flag to not bind the host CA
+// certificates bundle into the container.
+func (e *EngineConfig) SetNoHostCerts(val bool) {
+	e.JSON.NoHostCerts = val
+}
+
+// GetNoHostCerts returns if the host CA certificates bundle
+// must not be bound into the container.
+func (e *EngineConfig) GetNoHostCerts() bool {
+	return e.JSON.NoHostCerts
+}
+
 // SetNoInit set noinit flag to not start shim init process.
 func (e *EngineConfig) SetNoInit(val bool) {
 	e.JSON.NoInit = val
@@ -575,6 +608,16 @@ func (e *EngineConfig) GetNetworkArgs() []string {
 	return e.JSON.NetworkArgs
 }
 
+// SetNetworkIPFamily sets the IP family requested to CNI plugins.
+func (e *EngineConfig) SetNetworkIPFamily(family string) {
+	e.JSON.NetworkIPFamily = family
+}
+
+// GetNetworkIPFamily retrieves the IP family requested to CNI plugins.
+func (e *EngineConfig) GetNetworkIPFamily() string {
+	return e.JSON.NetworkIPFamily
+}
+
 // SetDNS sets a commas separated list of DNS servers to add in resolv.conf.
 func (e *EngineConfig) SetDNS(dns string) {
 	e.JSON.DNS = dns
@@ -585,6 +628,18 @@ func (e *EngineConfig) GetDNS() string {
 	return e.JSON.DNS
 }
 
+// SetResolvConf sets the resolv.conf content to use in
+// the container instead of the host one.
+func (e *EngineConfig) SetResolvConf(content []byte) {
+	e.JSON.ResolvConf = content
+}
+
+// GetResolvConf retrieves the resolv.conf content to use
+// in the container.
+func (e *EngineConfig) GetResolvConf() []byte {
+	return e.JSON.ResolvConf
+}
+
 // SetImageList sets image list containing opened images.
 func (e *EngineConfig) SetImageList(list []image.Image) {
 	e.JSON.ImageList = list
@@ -625,6 +680,18 @@ func (e *EngineConfig) GetWritableTmpfs() bool {
 	return e.JSON.WritableTmpfs
 }
 
+// SetWritableTmpfsSize sets the size limit in bytes of the writable
+// tmpfs, 0 means no limit.
+func (e *EngineConfig) SetWritableTmpfsSize(size uint64) {
+	e.JSON.WritableTmpfsSize = size
+}
+
+// GetWritableTmpfsSize returns the size limit in bytes of the
+// writable tmpfs.
+func (e *EngineConfig) GetWritableTmpfsSize() uint64 {
+	return e.JSON.WritableTmpfsSize
+}
+
 // SetSecurity sets security feature arguments.
 func (e *EngineConfig) SetSecurity(security []string) {
 	e.JSON.Security = security
@@ -645,6 +712,30 @@ func (e *EngineConfig) GetCgroupsPath() string {
 	return e.JSON.CgroupsPath
 }
 
+// SetUsernsOnly sets if the container must run with a user namespace
+// only, without the setuid workflow.
+func (e *EngineConfig) SetUsernsOnly(usernsOnly bool) {
+	e.JSON.UsernsOnly = usernsOnly
+}
+
+// GetUsernsOnly returns if the container must run with a user namespace
+// only, without the setuid workflow.
+func (e *EngineConfig) GetUsernsOnly() bool {
+	return e.JSON.UsernsOnly
+}
+
+// SetNoDeviceCgroup sets if the device nodes bound in the container
+// are not allowed in the devices cgroup of the cgroups profile.
+func (e *EngineConfig) SetNoDeviceCgroup(noDeviceCgroup bool) {
+	e.JSON.NoDeviceCgroup = noDeviceCgroup
+}
+
+// GetNoDeviceCgroup returns if the device nodes bound in the container
+// are not allowed in the devices cgroup of the cgroups profile.
+func (e *EngineConfig) GetNoDeviceCgroup() bool {
+	return e.JSON.NoDeviceCgroup
+}
+
 // SetTargetUID sets target UID to execute the container process as user ID.
 func (e *EngineConfig) SetTargetUID(uid int) {
 	e.JSON.TargetUID = uid
@@ -803,3 +894,15 @@ func (e *EngineConfig) SetUnixSocketPair(fds [2]int) {
 func (e *EngineConfig) GetUnixSocketPair() [2]int {
 	return e.JSON.UnixSocketPair
 }
+
+// SetStartupCommands sets the commands executed inside the
+// container before the user command.
+func (e *EngineConfig) SetStartupCommands(commands []StartupCommand) {
+	e.JSON.StartupCommands = commands
+}
+
+// GetStartupCommands returns the commands executed inside the
+// container before the user command.
+func (e *EngineConfig) GetStartupCommands() []StartupCommand {
+	return e.JSON.StartupCommands
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// InitBuiltin runs the built-in init process, sinit, as PID 1 of
+	// the container: it reaps the orphaned processes and forwards the
+	// signals it receives to the container process.
+	InitBuiltin = "builtin"
+	// InitContainer runs the /sbin/init of the container as PID 1,
+	// the container process being given as its arguments, as done by
+	// init programs such as tini or dumb-init.
+	InitContainer = "container"
+	// InitNone runs the container process as PID 1.
+	InitNone = "none"
+)
+
+// InitModes lists the init processes of a container with a PID
+// namespace which can be set with SetInit.
+var InitModes = []string{
+	InitBuiltin,
+	InitContainer,
+	InitNone,
+}
+
+// CheckInit returns an error if mode is not one of InitModes.
+func CheckInit(mode string) error {
+	for _, m := range InitModes {
+		if mode == m {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown init %q, valid ones are %s", mode, strings.Join(InitModes, ", "))
+}
+
+// SetInit sets the init process of a container with a PID namespace,
+// one of InitModes.
+func (e *EngineConfig) SetInit(mode string) {
+	e.JSON.Init = mode
+}
+
+// GetInit returns the init process of a container with a PID namespace,
+// InitBuiltin by default or InitNone when the no-init flag is set.
+func (e *EngineConfig) GetInit() string {
+	if e.JSON.Init != "" {
+		return e.JSON.Init
+	} else if e.JSON.NoInit {
+		return InitNone
+	}
+	return InitBuiltin
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"testing"
+)
+
+func TestCheckInit(t *testing.T) {
+	for _, mode := range InitModes {
+		if err := CheckInit(mode); err != nil {
+			t.Errorf("unexpected error for %q: %s", mode, err)
+		}
+	}
+	for _, mode := range []string{"", "tini", "Builtin"} {
+		if err := CheckInit(mode); err == nil {
+			t.Errorf("unexpected success for %q", mode)
+		}
+	}
+}
+
+func TestGetInit(t *testing.T) {
+	cases := []struct {
+		description string
+		noInit      bool
+		init        string
+		expected    string
+	}{
+		{"default", false, "", InitBuiltin},
+		{"no-init", true, "", InitNone},
+		{"container", false, InitContainer, InitContainer},
+		{"none", true, InitNone, InitNone},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			e := NewConfig()
+			e.SetNoInit(tc.noInit)
+			e.SetInit(tc.init)
+			if mode := e.GetInit(); mode != tc.expected {
+				t.Errorf("got init %q, expected %q", mode, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+const (
+	// NoMountProc disables the /proc mount.
+	NoMountProc = "proc"
+	// NoMountSys disables the /sys mount.
+	NoMountSys = "sys"
+	// NoMountDev disables the /dev mount.
+	NoMountDev = "dev"
+	// NoMountDevPts disables the /dev/pts mount of a minimal /dev.
+	NoMountDevPts = "devpts"
+	// NoMountHome disables the home directory mount.
+	NoMountHome = "home"
+	// NoMountTmp disables the /tmp and /var/tmp mounts.
+	NoMountTmp = "tmp"
+	// NoMountHostfs disables the host file systems mounts.
+	NoMountHostfs = "hostfs"
+	// NoMountCwd disables the current working directory mount.
+	NoMountCwd = "cwd"
+	// NoMountBindPaths disables the 'bind path' entries of singularity.conf.
+	NoMountBindPaths = "bind-paths"
+)
+
+// NoMountCategories lists the default mount categories which can be
+// disabled with SetNoMount.
+var NoMountCategories = []string{
+	NoMountProc,
+	NoMountSys,
+	NoMountDev,
+	NoMountDevPts,
+	NoMountHome,
+	NoMountTmp,
+	NoMountHostfs,
+	NoMountCwd,
+	NoMountBindPaths,
+}
+
+// CheckNoMount returns an error if categories contains a name which
+// is not one of NoMountCategories.
+func CheckNoMount(categories []string) error {
+	var unknown []string
+
+	for _, c := range categories {
+		found := false
+		for _, nc := range NoMountCategories {
+			if c == nc {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, fmt.Sprintf("%q", c))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown mount categories %s, valid ones are %s", strings.Join(unknown, ", "), strings.Join(NoMountCategories, ", "))
+	}
+	return nil
+}
+
+// ApplyNoMount disables in the configuration file the default mounts
+// of categories. The cwd category has no configuration directive and
+// is left to the caller. Explicit user binds are not affected.
+func ApplyNoMount(file *singularityconf.File, categories []string) {
+	for _, c := range categories {
+		switch c {
+		case NoMountProc:
+			file.MountProc = false
+		case NoMountSys:
+			file.MountSys = false
+		case NoMountDev:
+			file.MountDev = "no"
+		case NoMountDevPts:
+			file.MountDevPts = false
+		case NoMountHome:
+			file.MountHome = false
+		case NoMountTmp:
+			file.MountTmp = false
+		case NoMountHostfs:
+			file.MountHostfs = false
+		case NoMountBindPaths:
+			file.BindPath = nil
+		}
+	}
+}
+
+// SetNoMount sets the default mount categories to disable.
+func (e *EngineConfig) SetNoMount(categories []string) {
+	e.JSON.NoMount = categories
+}
+
+// GetNoMount returns the default mount categories to disable.
+func (e *EngineConfig) GetNoMount() []string {
+	return e.JSON.NoMount
+}
+
+// IsNoMount returns if the default mount category is disabled.
+func (e *EngineConfig) IsNoMount(category string) bool {
+	for _, c := range e.JSON.NoMount {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"testing"
+
+	"github.com/sylabs/singularity/pkg/util/singularityconf"
+)
+
+func TestCheckNoMount(t *testing.T) {
+	cases := []struct {
+		description string
+		categories  []string
+		shouldFail  bool
+	}{
+		{"none", nil, false},
+		{"all", NoMountCategories, false},
+		{"some", []string{"tmp", "home", "sys"}, false},
+		{"unknown", []string{"tmp", "etc"}, true},
+		{"empty", []string{""}, true},
+		{"case", []string{"Home"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := CheckNoMount(tc.categories)
+			if err != nil && !tc.shouldFail {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tc.shouldFail {
+				t.Fatalf("unexpected success")
+			}
+		})
+	}
+}
+
+func TestApplyNoMount(t *testing.T) {
+	file := &singularityconf.File{
+		MountProc:   true,
+		MountSys:    true,
+		MountDev:    "yes",
+		MountDevPts: true,
+		MountHome:   true,
+		MountTmp:    true,
+		MountHostfs: true,
+		BindPath:    []string{"/etc/localtime", "/etc/hosts"},
+	}
+
+	ApplyNoMount(file, []string{NoMountTmp, NoMountHome, NoMountBindPaths, NoMountCwd})
+
+	if file.MountTmp || file.MountHome || len(file.BindPath) != 0 {
+		t.Errorf("tmp, home or bind paths mounts not disabled")
+	}
+	if !file.MountProc || !file.MountSys || file.MountDev != "yes" || !file.MountDevPts || !file.MountHostfs {
+		t.Errorf("unexpected disabled mounts")
+	}
+
+	ApplyNoMount(file, []string{NoMountProc, NoMountSys, NoMountDev, NoMountDevPts, NoMountHostfs})
+
+	if file.MountProc || file.MountSys || file.MountDev != "no" || file.MountDevPts || file.MountHostfs {
+		t.Errorf("proc, sys, dev, devpts or hostfs mounts not disabled")
+	}
+}
+
+func TestIsNoMount(t *testing.T) {
+	e := NewConfig()
+	e.SetNoMount([]string{NoMountCwd, NoMountTmp})
+
+	if !e.IsNoMount(NoMountCwd) || !e.IsNoMount(NoMountTmp) {
+		t.Errorf("disabled mount categories not reported")
+	}
+	if e.IsNoMount(NoMountHome) {
+		t.Errorf("home mount reported as disabled")
+	}
+}
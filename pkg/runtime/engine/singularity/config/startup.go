@@ -0,0 +1,95 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// StartupCommand is a command executed inside the container, once
+// mounts are set up, before the user command. Commands are usually
+// registered by plugins.
+type StartupCommand struct {
+	// Name identifies the command, it's referenced by the After field
+	// of other commands.
+	Name string `json:"name"`
+	// Args holds the command and its arguments, the command must be
+	// an absolute path within the container.
+	Args []string `json:"args"`
+	// After lists the names of the commands which must be executed
+	// before this one.
+	After []string `json:"after,omitempty"`
+	// Writable lists the read-only mount points of the container, as
+	// absolute paths, made writable while the command runs and made
+	// read-only again once it exits.
+	Writable []string `json:"writable,omitempty"`
+}
+
+// OrderStartupCommands returns the commands sorted so each command comes
+// after the commands it depends on, commands without dependency between
+// them keep their relative order. Unknown dependencies are ignored, an
+// error is returned for duplicated names or dependency cycles.
+func OrderStartupCommands(commands []StartupCommand) ([]StartupCommand, error) {
+	index := make(map[string]int, len(commands))
+	for i, c := range commands {
+		if c.Name == "" {
+			return nil, fmt.Errorf("startup command %v has no name", c.Args)
+		}
+		if _, ok := index[c.Name]; ok {
+			return nil, fmt.Errorf("startup command %q registered more than once", c.Name)
+		}
+		index[c.Name] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(commands))
+	ordered := make([]StartupCommand, 0, len(commands))
+
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		c := commands[i]
+		path = append(path, c.Name)
+
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("startup commands dependency cycle: %s", strings.Join(path, " -> "))
+		}
+
+		state[i] = visiting
+		for _, dep := range c.After {
+			j, ok := index[dep]
+			if !ok {
+				sylog.Warningf("Startup command %q depends on unknown command %q, ignoring it", c.Name, dep)
+				continue
+			}
+			if err := visit(j, path); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+
+		ordered = append(ordered, c)
+		return nil
+	}
+
+	for i := range commands {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package singularity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func startupNames(commands []StartupCommand) []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestOrderStartupCommands(t *testing.T) {
+	cases := []struct {
+		description string
+		commands    []StartupCommand
+		expected    []string
+		shouldFail  bool
+	}{
+		{
+			description: "no dependency",
+			commands:    []StartupCommand{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			expected:    []string{"a", "b", "c"},
+		},
+		{
+			description: "dependencies",
+			commands: []StartupCommand{
+				{Name: "a", After: []string{"c"}},
+				{Name: "b"},
+				{Name: "c", After: []string{"b"}},
+			},
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			description: "unknown dependency",
+			commands:    []StartupCommand{{Name: "a", After: []string{"z"}}, {Name: "b"}},
+			expected:    []string{"a", "b"},
+		},
+		{
+			description: "cycle",
+			commands: []StartupCommand{
+				{Name: "a", After: []string{"b"}},
+				{Name: "b", After: []string{"a"}},
+			},
+			shouldFail: true,
+		},
+		{
+			description: "duplicated name",
+			commands:    []StartupCommand{{Name: "a"}, {Name: "a"}},
+			shouldFail:  true,
+		},
+		{
+			description: "no name",
+			commands:    []StartupCommand{{Args: []string{"/bin/true"}}},
+			shouldFail:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.description, func(t *testing.T) {
+			ordered, err := OrderStartupCommands(tc.commands)
+			if tc.shouldFail {
+				if err == nil {
+					t.Errorf("unexpected success")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if names := startupNames(ordered); !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("got order %v, expected %v", names, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// pkcs11Tool is the OpenSC program performing the token operations.
+const pkcs11Tool = "pkcs11-tool"
+
+// PKCS11Config selects the private key of a PKCS#11 token used to sign
+// an image instead of a key of the private keyring.
+type PKCS11Config struct {
+	// Module is the path to the PKCS#11 provider library of the token.
+	Module string
+	// Label is the label of the key pair on the token.
+	Label string
+	// PIN is the user PIN of the token, if empty the PIN is prompted
+	// by the token tool or read from the PIN pad of the reader.
+	PIN string
+}
+
+// rsaDigestInfo holds the DER encoded DigestInfo prefixes the RSA-PKCS
+// mechanism expects in front of the digest, see RFC 8017 section 9.2.
+var rsaDigestInfo = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pkcs11Signer is a crypto.Signer delegating the private key operation
+// to a PKCS#11 token through pkcs11-tool, the private key never leaves
+// the token.
+type pkcs11Signer struct {
+	tool   string
+	cfg    PKCS11Config
+	public crypto.PublicKey
+}
+
+// newPKCS11Signer returns a signer for the key pair of the token
+// selected by cfg, reading its public key from the token.
+func newPKCS11Signer(cfg PKCS11Config) (*pkcs11Signer, error) {
+	if cfg.Module == "" {
+		return nil, fmt.Errorf("no PKCS#11 module specified")
+	}
+	if cfg.Label == "" {
+		return nil, fmt.Errorf("no PKCS#11 key label specified")
+	}
+	if _, err := os.Stat(cfg.Module); err != nil {
+		return nil, fmt.Errorf("could not access PKCS#11 module: %s", err)
+	}
+
+	tool, err := exec.LookPath(pkcs11Tool)
+	if err != nil {
+		return nil, fmt.Errorf("%s is required to sign with a PKCS#11 token: %s", pkcs11Tool, err)
+	}
+
+	s := &pkcs11Signer{tool: tool, cfg: cfg}
+
+	der, err := s.run(nil, "--read-object", "--type", "pubkey")
+	if err != nil {
+		return nil, fmt.Errorf("could not read public key %q from token: %s", cfg.Label, err)
+	}
+	if s.public, err = x509.ParsePKIXPublicKey(der); err != nil {
+		// older versions of pkcs11-tool export RSA keys as PKCS#1
+		if s.public, err = x509.ParsePKCS1PublicKey(der); err != nil {
+			return nil, fmt.Errorf("could not parse public key %q from token: %s", cfg.Label, err)
+		}
+	}
+
+	return s, nil
+}
+
+// run executes pkcs11-tool on the key of the token with args, input
+// being written to the tool input file. It returns the content of the
+// tool output file. The standard input and error of the tool are left
+// to the user to enter the token PIN when needed.
+func (s *pkcs11Signer) run(input []byte, args ...string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "pkcs11-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "input")
+	out := filepath.Join(dir, "output")
+
+	args = append([]string{"--module", s.cfg.Module, "--label", s.cfg.Label, "--output-file", out}, args...)
+	if input != nil {
+		if err := ioutil.WriteFile(in, input, 0600); err != nil {
+			return nil, err
+		}
+		args = append(args, "--input-file", in)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd := exec.Command(s.tool, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if s.cfg.PIN != "" {
+		cmd.Args = append(cmd.Args, "--pin", "env:SINGULARITY_PKCS11_PIN")
+		cmd.Env = append(os.Environ(), "SINGULARITY_PKCS11_PIN="+s.cfg.PIN)
+	}
+
+	sylog.Debugf("Running %s %v", s.tool, args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %s: %s", pkcs11Tool, err, bytes.TrimSpace(stdout.Bytes()))
+	}
+
+	return ioutil.ReadFile(out)
+}
+
+// Public returns the public key of the token key pair.
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs digest with the private key of the token. ECDSA signatures
+// are returned ASN.1 encoded as expected from a crypto.Signer.
+func (s *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch s.public.(type) {
+	case *rsa.PublicKey:
+		prefix, ok := rsaDigestInfo[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+		}
+		input := append(append([]byte{}, prefix...), digest...)
+		return s.run(input, "--login", "--sign", "--mechanism", "RSA-PKCS")
+	case *ecdsa.PublicKey:
+		return s.run(digest, "--login", "--sign", "--mechanism", "ECDSA", "--signature-format", "openssl")
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", s.public)
+	}
+}
+
+// samePublicKey returns whether the OpenPGP public key pk holds the
+// public key pub.
+func samePublicKey(pk *packet.PublicKey, pub crypto.PublicKey) bool {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k, ok := pk.PublicKey.(*rsa.PublicKey)
+		return ok && k.E == pub.E && k.N.Cmp(pub.N) == 0
+	case *ecdsa.PublicKey:
+		k, ok := pk.PublicKey.(*ecdsa.PublicKey)
+		return ok && k.Curve == pub.Curve && k.X.Cmp(pub.X) == 0 && k.Y.Cmp(pub.Y) == 0
+	}
+	return false
+}
+
+// signerPrivateKey returns an OpenPGP private key using signer for the
+// private key operation. The OpenPGP public key is looked up in el so
+// that the signature carries the fingerprint of the published key, the
+// fingerprint depending on the key creation time which is not stored
+// on the token.
+func signerPrivateKey(el openpgp.EntityList, signer crypto.Signer) (*packet.PrivateKey, error) {
+	for _, e := range el {
+		if samePublicKey(e.PrimaryKey, signer.Public()) {
+			return &packet.PrivateKey{
+				PublicKey:  *e.PrimaryKey,
+				PrivateKey: signer,
+			}, nil
+		}
+	}
+	return nil, errors.New("no public key of the keyring matches the token key, use 'key import' to import the OpenPGP public key of the token key")
+}
+
+// SignPKCS11 signs the data objects of the image cpath selected by id,
+// isGroup and signAll like Sign, but with the private key of the PKCS#11
+// token selected by cfg. The OpenPGP public key of the token key must be
+// in the public keyring, signatures are verified as any other signature.
+func SignPKCS11(cpath string, id uint32, isGroup, signAll bool, cfg PKCS11Config) error {
+	keyring := sypgp.NewHandle("")
+
+	el, err := keyring.LoadPubKeyring()
+	if err != nil {
+		return fmt.Errorf("could not load public keyring: %s", err)
+	}
+
+	signer, err := newPKCS11Signer(cfg)
+	if err != nil {
+		return err
+	}
+
+	priv, err := signerPrivateKey(el, signer)
+	if err != nil {
+		return err
+	}
+	sylog.Debugf("Signing with token key %q, fingerprint %X", cfg.Label, priv.Fingerprint)
+
+	return signImage(cpath, id, isGroup, signAll, priv)
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sylabs/sif/pkg/sif"
+	"golang.org/x/crypto/openpgp"
+)
+
+// tokenSigner is a crypto.Signer mimicking a token, signing with the
+// RSA-PKCS mechanism on the DigestInfo built by pkcs11Signer.
+type tokenSigner struct {
+	key *rsa.PrivateKey
+}
+
+func (s *tokenSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *tokenSigner) Sign(r io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	prefix, ok := rsaDigestInfo[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash function %v", opts.HashFunc())
+	}
+	return rsa.SignPKCS1v15(r, s.key, 0, append(append([]byte{}, prefix...), digest...))
+}
+
+func TestRSADigestInfo(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+	signer := &tokenSigner{key: key}
+
+	for h := range rsaDigestInfo {
+		hash := h.New()
+		hash.Write([]byte("data"))
+		digest := hash.Sum(nil)
+
+		want, err := rsa.SignPKCS1v15(nil, key, h, digest)
+		if err != nil {
+			t.Fatalf("while signing with %v: %s", h, err)
+		}
+		got, err := signer.Sign(nil, digest, h)
+		if err != nil {
+			t.Fatalf("while signing with DigestInfo for %v: %s", h, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("unexpected signature with DigestInfo for %v", h)
+		}
+	}
+}
+
+func TestSignerPrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, entity := testKeyring(t, dir)
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+	path := createSignedGroups(t, dir, other, 1, 16)
+
+	signer := &tokenSigner{key: entity.PrivateKey.PrivateKey.(*rsa.PrivateKey)}
+
+	if _, err := signerPrivateKey(openpgp.EntityList{other}, signer); err == nil {
+		t.Fatalf("unexpected success without the token public key")
+	}
+
+	priv, err := signerPrivateKey(openpgp.EntityList{other, entity}, signer)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if priv.Fingerprint != entity.PrimaryKey.Fingerprint {
+		t.Fatalf("got fingerprint %X, expected %X", priv.Fingerprint, entity.PrimaryKey.Fingerprint)
+	}
+
+	if err := signImage(path, 1, true, false, priv); err != nil {
+		t.Fatalf("while signing image: %s", err)
+	}
+
+	fp := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	otherFp := fmt.Sprintf("%X", other.PrimaryKey.Fingerprint)
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatalf("while loading image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	var trusted, signers []string
+	for i := range fimg.DescrArr {
+		sig := &fimg.DescrArr[i]
+		if !sig.Used || sig.Datatype != sif.DataSignature {
+			continue
+		}
+		signer, err := sig.GetEntityString()
+		if err != nil {
+			t.Fatalf("while getting signer: %s", err)
+		}
+		signers = append(signers, signer)
+
+		_, err = CheckSignature(&fimg, sig, openpgp.EntityList{entity})
+		if err == nil {
+			trusted = append(trusted, signer)
+		} else if !errors.Is(err, ErrUnknownSigner) {
+			t.Fatalf("while verifying image: %s", err)
+		}
+	}
+	if !reflect.DeepEqual(trusted, []string{fp}) {
+		t.Errorf("got trusted %q, expected %q", trusted, []string{fp})
+	}
+	if !reflect.DeepEqual(signers, []string{otherFp, fp}) {
+		t.Errorf("got signers %q, expected %q", signers, []string{otherFp, fp})
+	}
+}
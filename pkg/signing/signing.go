@@ -16,6 +16,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/fatih/color"
 	"github.com/sylabs/sif/pkg/sif"
@@ -23,6 +26,7 @@ import (
 	"github.com/sylabs/singularity/pkg/sypgp"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
 )
 
 // ErrVerificationFail is the error when the verify fails
@@ -44,6 +48,11 @@ type KeyEntity struct {
 	KeyLocal    bool
 	KeyCheck    bool
 	DataCheck   bool
+	// SignatureID is the descriptor ID of the signature object.
+	SignatureID uint32
+	// Verified reports whether both the signer and the data
+	// integrity were verified for the signature.
+	Verified bool
 }
 
 // KeyList is a list of one or more keys.
@@ -198,6 +207,25 @@ func Sign(cpath string, id uint32, isGroup, signAll bool, keyIdx int) error {
 		}
 	}
 
+	return signImage(cpath, id, isGroup, signAll, entity.PrivateKey)
+}
+
+// SignWithEntity signs the data objects of the image cpath selected by
+// id, isGroup and signAll like Sign, with the private key of e which
+// must be decrypted. It doesn't read the keyring nor prompt.
+func SignWithEntity(cpath string, id uint32, isGroup, signAll bool, e *openpgp.Entity) error {
+	if e.PrivateKey == nil {
+		return fmt.Errorf("key %X has no private key", e.PrimaryKey.Fingerprint)
+	}
+	if e.PrivateKey.Encrypted {
+		return fmt.Errorf("private key %X is encrypted", e.PrimaryKey.Fingerprint)
+	}
+	return signImage(cpath, id, isGroup, signAll, e.PrivateKey)
+}
+
+// signImage signs the data objects of the image cpath selected by id,
+// isGroup and signAll with the private key priv.
+func signImage(cpath string, id uint32, isGroup, signAll bool, priv *packet.PrivateKey) error {
 	// load the container
 	fimg, err := sif.LoadContainer(cpath, false)
 	if err != nil {
@@ -226,7 +254,7 @@ func Sign(cpath string, id uint32, isGroup, signAll bool, keyIdx int) error {
 
 		// create an ascii armored signature block
 		var signedmsg bytes.Buffer
-		plaintext, err := clearsign.Encode(&signedmsg, entity.PrivateKey, nil)
+		plaintext, err := clearsign.Encode(&signedmsg, priv, nil)
 		if err != nil {
 			return fmt.Errorf("could not build a signature block: %s", err)
 		}
@@ -247,7 +275,7 @@ func Sign(cpath string, id uint32, isGroup, signAll bool, keyIdx int) error {
 			groupid = de.Groupid
 			link = de.ID
 		}
-		err = sifAddSignature(&fimg, groupid, link, entity.PrimaryKey.Fingerprint, signedmsg.Bytes())
+		err = sifAddSignature(&fimg, groupid, link, priv.Fingerprint, signedmsg.Bytes())
 		if err != nil {
 			return fmt.Errorf("failed adding signature block to SIF container file: %s", err)
 		}
@@ -349,7 +377,7 @@ func getSigsGroup(fimg *sif.FileImage, id uint32) ([]signatureLink, error) {
 		return nil, fmt.Errorf("no signatures found for groupid %v", id)
 	}
 
-	sigLink := make([]signatureLink, len(sindex), len(dindex))
+	sigLink := make([]signatureLink, len(sindex))
 
 	for i, s := range sindex {
 		sigLink[i].sigIndex = s
@@ -396,12 +424,12 @@ func IsSigned(ctx context.Context, cpath, keyServerURI string, authToken string)
 // keys in the default local keyring, if non is found, it will then looks it up
 // from a key server if access is enabled, or if localVerify is false. Returns
 // a string of formatted output, or json (if jsonVerify is true), and true, if
-// theres no local key matching a signers entity.
+// theres no local key matching a signers entity. Signatures are verified
+// concurrently, the output lists them in the image order and ends with the
+// signature objects which failed the verification.
 func Verify(ctx context.Context, cpath, keyServiceURI string, id uint32, isGroup, verifyAll bool, authToken string, localVerify, jsonVerify bool) (string, bool, error) {
 	keyring := sypgp.NewHandle("")
 
-	notLocalKey := false
-
 	fimg, err := sif.LoadContainer(cpath, true)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to load SIF container file: %s", err)
@@ -414,121 +442,309 @@ func Verify(ctx context.Context, cpath, keyServiceURI string, id uint32, isGroup
 		return "", false, fmt.Errorf("error while searching for signature blocks: %s", err)
 	}
 
-	// Setup some colors.
-	green := color.New(color.FgGreen).SprintFunc()
-	yellow := color.New(color.FgYellow).SprintFunc()
-	red := color.New(color.FgRed).SprintFunc()
+	v := &verifier{
+		fimg:          &fimg,
+		keyring:       keyring,
+		keyServiceURI: keyServiceURI,
+		authToken:     authToken,
+		id:            id,
+		isGroup:       isGroup,
+		localVerify:   localVerify,
+	}
+	results := v.verifyAll(ctx, sigsLink, verifyWorkers(&fimg, len(sigsLink)))
 
 	var fail bool
 	var errRet error
-	var author string
+	var failed []string
 
-	var keySigner *Key
+	notLocalKey := false
 	keyEntityList := KeyList{}
 
-	author += fmt.Sprintf("Container is signed by %d key(s):\n\n", len(sigsLink))
+	author := fmt.Sprintf("Container is signed by %d key(s):\n\n", len(sigsLink))
 
-	// Loop through the signature link, and find the signatures and
-	// corresponding partition.
-	for _, part := range sigsLink {
-		sifhash := ""
-		if isGroup {
-			// If we are verifying a group, then collect all
-			// the group partitions.
-			var groupPart []*sif.Descriptor
-
-			for _, d := range part.groupIndex {
-				groupPart = append(groupPart, &fimg.DescrArr[d])
-			}
-			sifhash = computeHashStr(&fimg, groupPart)
-		} else {
-			sifhash = computeHashStr(&fimg, []*sif.Descriptor{&fimg.DescrArr[part.dataIndex]})
+	// Aggregate the results in the signature order, the output
+	// is the same than with a serial verification.
+	for _, r := range results {
+		author += r.output
+		if r.key != nil {
+			keyEntityList.SignerKeys = append(keyEntityList.SignerKeys, r.key)
 		}
-		sylog.Debugf("Verifying hash: %s\n", sifhash)
-
-		dataCheck := true
-		// get the entity fingerprint for the signature block
-		fingerprint, err := fimg.DescrArr[part.sigIndex].GetEntityString()
-		if err != nil {
-			sylog.Errorf("could not get the signing entity fingerprint from partition ID: %d: %s", part.sigIndex, err)
+		if r.notLocal {
+			notLocalKey = true
+		}
+		if r.fail {
 			fail = true
-			continue
+			failed = append(failed, fmt.Sprintf("%d (%s)", r.sigID, r.partition))
 		}
+	}
 
-		verifyPartition := ""
-		if isGroup {
-			verifyPartition = fmt.Sprintf("group: %d", id)
-		} else {
-			verifyPartition = fimg.DescrArr[part.dataIndex].Datatype.String()
+	keyEntityList.Signatures = len(sigsLink)
+
+	if jsonVerify {
+		jsonData, err := json.MarshalIndent(keyEntityList, "", "  ")
+		if err != nil {
+			return "", notLocalKey, fmt.Errorf("unable to parse json: %s", err)
 		}
-		author += fmt.Sprintf("Verifying partition: %s:\n", verifyPartition)
-		author += fingerprint + "\n"
+		author = string(jsonData) + "\n"
+	} else if fail {
+		author += fmt.Sprintf("Failed signature object(s): %s\n", strings.Join(failed, ", "))
+	}
+
+	if fail {
+		errRet = ErrVerificationFail
+	}
+
+	return author, notLocalKey, errRet
+}
 
-		// Extract hash string from signature block
-		data := fimg.DescrArr[part.sigIndex].GetData(&fimg)
-		block, _ := clearsign.Decode(data)
-		if block == nil {
-			sylog.Verbosef("%s signature key (%s) corrupted, unable to read data", red("error:"), fingerprint)
-			author += fmt.Sprintf("%-18s Signature corrupted, unable to read data\n\n", red("[FAIL]"))
+// SignerMismatchError is the error when signatures of an image were
+// made by keys other than the required one.
+type SignerMismatchError struct {
+	// Required is the fingerprint of the required signer key.
+	Required string
+	// Signers lists the fingerprints of the keys which made
+	// the signatures checked.
+	Signers []string
+}
 
-			keySigner = makeKeyEntity("", verifyPartition, fingerprint, false, false, false)
-			keyEntityList.SignerKeys = append(keyEntityList.SignerKeys, keySigner)
+func (e *SignerMismatchError) Error() string {
+	if len(e.Signers) == 0 {
+		return fmt.Sprintf("image must be signed by %s, found no signature", e.Required)
+	}
+	return fmt.Sprintf("image must only be signed by %s, found signature(s) by %s", e.Required, strings.Join(e.Signers, ", "))
+}
 
-			fail = true
-			continue
-		}
+// normalizeFingerprint returns the uppercase hexadecimal form of a
+// full key fingerprint, given with or without the 0x prefix.
+func normalizeFingerprint(fingerprint string) (string, error) {
+	fp := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(fingerprint)), "0x")
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != 20 {
+		return "", fmt.Errorf("invalid key fingerprint %q: expected 40 hexadecimal characters", fingerprint)
+	}
+	return strings.ToUpper(fp), nil
+}
+
+// CheckSigners returns a SignerMismatchError unless all the signatures
+// of cpath for the selected partitions, like for Verify, were made by
+// the key with the given fingerprint. It only checks the signer keys
+// recorded in the image, the signatures themselves must be checked
+// with Verify.
+func CheckSigners(cpath string, id uint32, isGroup, verifyAll bool, fingerprint string) error {
+	fp, err := normalizeFingerprint(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	fimg, err := sif.LoadContainer(cpath, true)
+	if err != nil {
+		return fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	sigsLink, err := getSigsForSelection(&fimg, id, isGroup, verifyAll)
+	if err != nil {
+		return fmt.Errorf("error while searching for signature blocks: %s", err)
+	}
 
-		// (1) try to get identity of signer
-		i, local, err := getSignerIdentity(ctx, keyring, &fimg.DescrArr[part.sigIndex], block, data, fingerprint, keyServiceURI, authToken, localVerify)
+	return checkSigners(&fimg, sigsLink, fp)
+}
+
+// checkSigners returns a SignerMismatchError unless all the signatures
+// of sigsLink were made by the key with the fingerprint fp.
+func checkSigners(fimg *sif.FileImage, sigsLink []signatureLink, fp string) error {
+	var signers []string
+	seen := make(map[string]bool)
+	mismatch := len(sigsLink) == 0
+
+	for _, part := range sigsLink {
+		signer, err := fimg.DescrArr[part.sigIndex].GetEntityString()
 		if err != nil {
-			// use [MISSING] if we get an error we expect
-			if err == errNotFound || err == errNotFoundLocal {
-				author += fmt.Sprintf("%-18s %s\n", red("[MISSING]"), err)
-			} else {
-				author += fmt.Sprintf("%-18s %s\n", red("[FAIL]"), err)
-			}
-			fail = true
-		} else {
-			prefix := green("[LOCAL]")
-			if !local {
-				prefix = yellow("[REMOTE]")
-				notLocalKey = true
+			return fmt.Errorf("could not get the signing entity fingerprint from partition ID: %d: %s", part.sigIndex, err)
+		}
+		if signer != fp {
+			mismatch = true
+		}
+		if !seen[signer] {
+			signers = append(signers, signer)
+			seen[signer] = true
+		}
+	}
+
+	if mismatch {
+		return &SignerMismatchError{Required: fp, Signers: signers}
+	}
+	return nil
+}
+
+// verifier holds the parameters shared by the verification of all the
+// signatures of an image.
+type verifier struct {
+	fimg          *sif.FileImage
+	keyring       *sypgp.Handle
+	keyServiceURI string
+	authToken     string
+	id            uint32
+	isGroup       bool
+	localVerify   bool
+}
+
+// signatureResult is the result of the verification of one signature.
+type signatureResult struct {
+	sigID     uint32
+	partition string
+	output    string
+	key       *Key
+	fail      bool
+	notLocal  bool
+}
+
+// verifyWorkers returns the number of signatures verified concurrently.
+// When the image couldn't be mapped in memory, reading the data objects
+// moves the file offset and the signatures are verified one by one.
+func verifyWorkers(fimg *sif.FileImage, signatures int) int {
+	if fimg.Amodebuf {
+		return 1
+	}
+	n := runtime.NumCPU()
+	if n > signatures {
+		n = signatures
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// verifyAll verifies the signatures with a pool of workers and returns
+// their results in the same order as sigsLink.
+func (v *verifier) verifyAll(ctx context.Context, sigsLink []signatureLink, workers int) []signatureResult {
+	results := make([]signatureResult, len(sigsLink))
+
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = v.verifySignature(ctx, sigsLink[idx])
 			}
+		}()
+	}
 
-			author += fmt.Sprintf("%-18s %s\n", prefix, i)
-		}
+	for idx := range sigsLink {
+		jobs <- idx
+	}
+	close(jobs)
 
-		// (2) Verify data integrity by comparing hashes
-		if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(sifhash)) {
-			sylog.Verbosef("%s key (%s) hash differs, data may be corrupted", red("error:"), fingerprint)
-			author += fmt.Sprintf("%-18s system partition hash differs, data may be corrupted\n", red("[FAIL]"))
-			dataCheck = false
-			fail = true
-		} else {
-			author += fmt.Sprintf("%-18s Data integrity verified\n", green("[OK]"))
+	wg.Wait()
+
+	return results
+}
+
+// verifySignature verifies the signature part, it returns the formatted
+// verification output along with the signer key information.
+func (v *verifier) verifySignature(ctx context.Context, part signatureLink) signatureResult {
+	fimg := v.fimg
+
+	// Setup some colors.
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	var res signatureResult
+	var author strings.Builder
+
+	res.sigID = fimg.DescrArr[part.sigIndex].ID
+
+	sifhash := ""
+	if v.isGroup {
+		// If we are verifying a group, then collect all
+		// the group partitions.
+		var groupPart []*sif.Descriptor
+
+		for _, d := range part.groupIndex {
+			groupPart = append(groupPart, &fimg.DescrArr[d])
 		}
-		author += fmt.Sprintf("\n")
+		sifhash = computeHashStr(fimg, groupPart)
+	} else {
+		sifhash = computeHashStr(fimg, []*sif.Descriptor{&fimg.DescrArr[part.dataIndex]})
+	}
+	sylog.Debugf("Verifying hash: %s\n", sifhash)
 
-		keySigner = makeKeyEntity(i, verifyPartition, fingerprint, local, true, dataCheck)
-		keyEntityList.SignerKeys = append(keyEntityList.SignerKeys, keySigner)
+	verifyPartition := ""
+	if v.isGroup {
+		verifyPartition = fmt.Sprintf("group: %d", v.id)
+	} else {
+		verifyPartition = fimg.DescrArr[part.dataIndex].Datatype.String()
+	}
+	res.partition = verifyPartition
 
+	dataCheck := true
+	// get the entity fingerprint for the signature block
+	fingerprint, err := fimg.DescrArr[part.sigIndex].GetEntityString()
+	if err != nil {
+		sylog.Errorf("could not get the signing entity fingerprint from partition ID: %d: %s", part.sigIndex, err)
+		res.fail = true
+		return res
 	}
 
-	keyEntityList.Signatures = len(sigsLink)
+	author.WriteString(fmt.Sprintf("Verifying partition: %s:\n", verifyPartition))
+	author.WriteString(fingerprint + "\n")
 
-	if jsonVerify {
-		jsonData, err := json.MarshalIndent(keyEntityList, "", "  ")
-		if err != nil {
-			return "", notLocalKey, fmt.Errorf("unable to parse json: %s", err)
+	// Extract hash string from signature block
+	data := fimg.DescrArr[part.sigIndex].GetData(fimg)
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		sylog.Verbosef("%s signature key (%s) corrupted, unable to read data", red("error:"), fingerprint)
+		author.WriteString(fmt.Sprintf("%-18s Signature corrupted, unable to read data\n\n", red("[FAIL]")))
+
+		res.key = makeKeyEntity("", verifyPartition, fingerprint, false, false, false)
+		res.key.Signer.SignatureID = res.sigID
+		res.output = author.String()
+		res.fail = true
+		return res
+	}
+
+	// (1) try to get identity of signer
+	i, local, err := getSignerIdentity(ctx, v.keyring, &fimg.DescrArr[part.sigIndex], block, data, fingerprint, v.keyServiceURI, v.authToken, v.localVerify)
+	if err != nil {
+		// use [MISSING] if we get an error we expect
+		if err == errNotFound || err == errNotFoundLocal {
+			author.WriteString(fmt.Sprintf("%-18s %s\n", red("[MISSING]"), err))
+		} else {
+			author.WriteString(fmt.Sprintf("%-18s %s\n", red("[FAIL]"), err))
 		}
-		author = string(jsonData) + "\n"
+		res.fail = true
+	} else {
+		prefix := green("[LOCAL]")
+		if !local {
+			prefix = yellow("[REMOTE]")
+			res.notLocal = true
+		}
+
+		author.WriteString(fmt.Sprintf("%-18s %s\n", prefix, i))
 	}
 
-	if fail {
-		errRet = ErrVerificationFail
+	// (2) Verify data integrity by comparing hashes
+	if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(sifhash)) {
+		sylog.Verbosef("%s key (%s) hash differs, data may be corrupted", red("error:"), fingerprint)
+		author.WriteString(fmt.Sprintf("%-18s system partition hash differs, data may be corrupted\n", red("[FAIL]")))
+		dataCheck = false
+		res.fail = true
+	} else {
+		author.WriteString(fmt.Sprintf("%-18s Data integrity verified\n", green("[OK]")))
 	}
+	author.WriteString("\n")
 
-	return author, notLocalKey, errRet
+	res.key = makeKeyEntity(i, verifyPartition, fingerprint, local, true, dataCheck)
+	res.key.Signer.SignatureID = res.sigID
+	res.key.Signer.Verified = !res.fail
+	res.output = author.String()
+
+	return res
 }
 
 func makeKeyEntity(name, partition, fingerprint string, local, corrupted, dataCheck bool) *Key {
@@ -680,3 +896,202 @@ func GetSignEntitiesFp(fp *os.File) ([]string, error) {
 
 	return getSignEntities(&fimg)
 }
+
+// Signature describes a signature object of a SIF image and the key
+// which produced it.
+type Signature struct {
+	// ID is the descriptor ID of the signature object.
+	ID uint32
+	// Fingerprint is the fingerprint of the signing key.
+	Fingerprint string
+	// Signer is the first identity of the signing key, it's empty
+	// when the key is not in the local keyring.
+	Signer string
+	// KeyLocal reports whether the signing key is in the local keyring.
+	KeyLocal bool
+	// Group is the ID of the signed group, it's zero when a single
+	// data object is signed.
+	Group uint32
+	// Objects lists the data objects covered by the signature.
+	Objects []SignedObject
+}
+
+// SignedObject describes a data object covered by a signature.
+type SignedObject struct {
+	ID       uint32
+	Datatype string
+}
+
+// signedDescriptors returns the descriptors of the data objects
+// covered by the signature sig along with the signed group ID, which
+// is zero when a single data object is signed.
+func signedDescriptors(fimg *sif.FileImage, sig *sif.Descriptor) ([]*sif.Descriptor, uint32, error) {
+	var descrs []*sif.Descriptor
+
+	if sig.Link&sif.DescrGroupMask == 0 {
+		d, _, err := fimg.GetFromDescrID(sig.Link)
+		if err != nil {
+			return nil, 0, fmt.Errorf("no descriptor found for id %d", sig.Link)
+		}
+		return append(descrs, d), 0, nil
+	}
+
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used || d.Datatype == sif.DataSignature || d.Groupid != sig.Link {
+			continue
+		}
+		descrs = append(descrs, d)
+	}
+	if len(descrs) == 0 {
+		return nil, 0, fmt.Errorf("no descriptors found for groupid %d", sig.Link&^sif.DescrGroupMask)
+	}
+
+	return descrs, sig.Link &^ sif.DescrGroupMask, nil
+}
+
+// signedObjects returns the data objects covered by the signature sig.
+func signedObjects(fimg *sif.FileImage, sig *sif.Descriptor) ([]SignedObject, uint32, error) {
+	descrs, group, err := signedDescriptors(fimg, sig)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	objects := make([]SignedObject, len(descrs))
+	for i, d := range descrs {
+		objects[i] = SignedObject{d.ID, d.Datatype.String()}
+	}
+	return objects, group, nil
+}
+
+// ErrUnknownSigner is the error when the key which made a
+// signature is not among the keys it's verified with.
+var ErrUnknownSigner = errors.New("signing key not found")
+
+// CheckSignature verifies the signature object sig of fimg with the
+// public keys of el only. It returns the data objects covered by the
+// signature, even when the verification fails, along with an error
+// wrapping ErrUnknownSigner if the signing key is not in el, or an
+// error if the signature is corrupted, invalid or doesn't match the
+// data objects.
+func CheckSignature(fimg *sif.FileImage, sig *sif.Descriptor, el openpgp.EntityList) ([]SignedObject, error) {
+	descrs, _, err := signedDescriptors(fimg, sig)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]SignedObject, len(descrs))
+	for i, d := range descrs {
+		objects[i] = SignedObject{d.ID, d.Datatype.String()}
+	}
+
+	fingerprint, err := sig.GetEntityString()
+	if err != nil {
+		return objects, fmt.Errorf("could not get the signing entity fingerprint: %s", err)
+	}
+	var keys openpgp.EntityList
+	for _, e := range el {
+		if fmt.Sprintf("%X", e.PrimaryKey.Fingerprint) == fingerprint {
+			keys = append(keys, e)
+		}
+	}
+	if len(keys) == 0 {
+		return objects, fmt.Errorf("%w: %s", ErrUnknownSigner, fingerprint)
+	}
+
+	block, _ := clearsign.Decode(sig.GetData(fimg))
+	if block == nil {
+		return objects, fmt.Errorf("signature corrupted, unable to read data")
+	}
+	if _, err := openpgp.CheckDetachedSignature(keys, bytes.NewBuffer(block.Bytes), block.ArmoredSignature.Body); err != nil {
+		return objects, fmt.Errorf("signature invalid: %s", err)
+	}
+	if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(computeHashStr(fimg, descrs))) {
+		return objects, fmt.Errorf("hash differs, data may be corrupted")
+	}
+	return objects, nil
+}
+
+// CheckSignatureHash checks that the hash recorded by the signature
+// object sig of fimg matches the data objects it covers, without
+// verifying the signature itself. It returns the data objects covered
+// by the signature along with an error if the signature is corrupted
+// or doesn't match the data objects.
+func CheckSignatureHash(fimg *sif.FileImage, sig *sif.Descriptor) ([]SignedObject, error) {
+	descrs, _, err := signedDescriptors(fimg, sig)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]SignedObject, len(descrs))
+	for i, d := range descrs {
+		objects[i] = SignedObject{d.ID, d.Datatype.String()}
+	}
+
+	block, _ := clearsign.Decode(sig.GetData(fimg))
+	if block == nil {
+		return objects, fmt.Errorf("signature corrupted, unable to read data")
+	}
+	if !bytes.Equal(bytes.TrimRight(block.Plaintext, "\n"), []byte(computeHashStr(fimg, descrs))) {
+		return objects, fmt.Errorf("hash differs, data may be corrupted")
+	}
+	return objects, nil
+}
+
+// getSignatures returns all the signatures of fimg, signers are
+// looked up by fingerprint in keyring.
+func getSignatures(fimg *sif.FileImage, keyring *sypgp.Handle) ([]Signature, error) {
+	elist, err := keyring.LoadPubKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("could not load public keyring: %s", err)
+	}
+
+	var signatures []Signature
+
+	for i := range fimg.DescrArr {
+		d := &fimg.DescrArr[i]
+		if !d.Used || d.Datatype != sif.DataSignature {
+			continue
+		}
+
+		fingerprint, err := d.GetEntityString()
+		if err != nil {
+			return nil, fmt.Errorf("could not get the signing entity fingerprint from descriptor %d: %s", d.ID, err)
+		}
+
+		objects, group, err := signedObjects(fimg, d)
+		if err != nil {
+			return nil, fmt.Errorf("signature object %d: %s", d.ID, err)
+		}
+
+		s := Signature{
+			ID:          d.ID,
+			Fingerprint: fingerprint,
+			Group:       group,
+			Objects:     objects,
+		}
+		for _, e := range elist {
+			if fmt.Sprintf("%X", e.PrimaryKey.Fingerprint) == fingerprint {
+				s.Signer = getFirstIdentity(e)
+				s.KeyLocal = true
+				break
+			}
+		}
+		signatures = append(signatures, s)
+	}
+
+	return signatures, nil
+}
+
+// GetSignatures returns all the signatures of the SIF image cpath along
+// with the data objects they cover. The signer of a signature is found
+// by looking up the key fingerprint in the local keyring, signatures
+// made with a key not in the keyring only report the fingerprint. The
+// signatures aren't verified, see Verify.
+func GetSignatures(cpath string) ([]Signature, error) {
+	fimg, err := sif.LoadContainer(cpath, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SIF container file: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	return getSignatures(&fimg, sypgp.NewHandle(""))
+}
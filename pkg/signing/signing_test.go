@@ -0,0 +1,368 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package signing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sylabs/sif/pkg/sif"
+	"github.com/sylabs/singularity/pkg/sypgp"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// testKeyring returns a keyring in dir holding the public key of
+// a newly generated entity, along with the entity.
+func testKeyring(tb testing.TB, dir string) (*sypgp.Handle, *openpgp.Entity) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.org", nil)
+	if err != nil {
+		tb.Fatalf("while generating key: %s", err)
+	}
+
+	keyring := sypgp.NewHandle(dir)
+	if err := keyring.PathsCheck(); err != nil {
+		tb.Fatalf("while creating keyring: %s", err)
+	}
+	f, err := os.OpenFile(keyring.PublicPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		tb.Fatalf("while creating public keyring: %s", err)
+	}
+	defer f.Close()
+	if err := entity.Serialize(f); err != nil {
+		tb.Fatalf("while writing public keyring: %s", err)
+	}
+
+	return keyring, entity
+}
+
+// createSignedGroups creates a SIF image with groups data objects
+// of size bytes, each one in its own group signed by entity. The
+// data of the groups listed in corrupted is modified after signing.
+func createSignedGroups(tb testing.TB, dir string, entity *openpgp.Entity, groups, size int, corrupted ...int) string {
+	path := filepath.Join(dir, "groups.sif")
+
+	var inputs []sif.DescriptorInput
+	for i := 0; i < groups; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, size)
+		inputs = append(inputs, sif.DescriptorInput{
+			Datatype: sif.DataGeneric,
+			Groupid:  sif.DescrGroupMask | uint32(i+1),
+			Link:     sif.DescrUnusedLink,
+			Fname:    fmt.Sprintf("data-%d", i),
+			Data:     data,
+			Size:     int64(len(data)),
+		})
+	}
+
+	_, err := sif.CreateContainer(sif.CreateInfo{
+		Pathname:   path,
+		Launchstr:  sif.HdrLaunch,
+		Sifversion: sif.HdrVersion,
+		ID:         uuid.NewV4(),
+		InputDescr: inputs,
+	})
+	if err != nil {
+		tb.Fatalf("while creating image: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		tb.Fatalf("while loading image: %s", err)
+	}
+
+	var offsets []int64
+	for _, i := range corrupted {
+		offsets = append(offsets, fimg.DescrArr[i].Fileoff)
+	}
+
+	for i := 0; i < groups; i++ {
+		descr, _, err := fimg.GetFromDescrID(uint32(i + 1))
+		if err != nil {
+			tb.Fatalf("while getting descriptor: %s", err)
+		}
+
+		var signed bytes.Buffer
+		plaintext, err := clearsign.Encode(&signed, entity.PrivateKey, nil)
+		if err != nil {
+			tb.Fatalf("while creating signature block: %s", err)
+		}
+		if _, err := plaintext.Write([]byte(computeHashStr(&fimg, []*sif.Descriptor{descr}))); err != nil {
+			tb.Fatalf("while writing signature block: %s", err)
+		}
+		if err := plaintext.Close(); err != nil {
+			tb.Fatalf("while closing signature block: %s", err)
+		}
+
+		err = sifAddSignature(&fimg, sif.DescrUnusedGroup, descr.Groupid, entity.PrimaryKey.Fingerprint, signed.Bytes())
+		if err != nil {
+			tb.Fatalf("while adding signature: %s", err)
+		}
+	}
+
+	fimg.UnloadContainer()
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatalf("while opening image: %s", err)
+	}
+	defer f.Close()
+	for _, off := range offsets {
+		if _, err := f.WriteAt([]byte{0xff}, off); err != nil {
+			tb.Fatalf("while corrupting data: %s", err)
+		}
+	}
+
+	return path
+}
+
+// groupSignatures returns the signatures of all the groups of fimg.
+func groupSignatures(tb testing.TB, fimg *sif.FileImage, groups int) []signatureLink {
+	var links []signatureLink
+	for i := 0; i < groups; i++ {
+		l, err := getSigsGroup(fimg, uint32(i+1))
+		if err != nil {
+			tb.Fatalf("while getting group signatures: %s", err)
+		}
+		links = append(links, l...)
+	}
+	return links
+}
+
+func TestVerifyAllWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const groups = 8
+
+	keyring, entity := testKeyring(t, dir)
+	path := createSignedGroups(t, dir, entity, groups, 4096, 2, 5)
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		t.Fatalf("while loading image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	v := &verifier{
+		fimg:        &fimg,
+		keyring:     keyring,
+		isGroup:     true,
+		localVerify: true,
+	}
+	links := groupSignatures(t, &fimg, groups)
+
+	serial := v.verifyAll(context.Background(), links, 1)
+	parallel := v.verifyAll(context.Background(), links, 4)
+
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Errorf("parallel results differ from serial results:\nserial:   %+v\nparallel: %+v", serial, parallel)
+	}
+
+	if len(parallel) != groups {
+		t.Fatalf("got %d results, expected %d", len(parallel), groups)
+	}
+	for i, r := range parallel {
+		corrupted := i == 2 || i == 5
+		if r.fail != corrupted {
+			t.Errorf("signature %d: got failure %v, expected %v", r.sigID, r.fail, corrupted)
+		}
+		if r.key == nil {
+			t.Fatalf("signature %d: no key information", r.sigID)
+		}
+		if !r.key.Signer.KeyLocal {
+			t.Errorf("signature %d: signer not found in local keyring", r.sigID)
+		}
+		if r.key.Signer.DataCheck == corrupted || r.key.Signer.Verified == corrupted {
+			t.Errorf("signature %d: unexpected data check %v, verified %v", r.sigID, r.key.Signer.DataCheck, r.key.Signer.Verified)
+		}
+		if r.key.Signer.SignatureID != uint32(groups+i+1) {
+			t.Errorf("signature %d: reported as signature %d", r.sigID, r.key.Signer.SignatureID)
+		}
+	}
+}
+
+func BenchmarkVerifyGroups(b *testing.B) {
+	dir, err := ioutil.TempDir("", "signing-")
+	if err != nil {
+		b.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a SIF image has 48 descriptors, one for the
+	// data and one for the signature of each group
+	const groups = 24
+
+	keyring, entity := testKeyring(b, dir)
+	path := createSignedGroups(b, dir, entity, groups, 1<<20)
+
+	fimg, err := sif.LoadContainer(path, true)
+	if err != nil {
+		b.Fatalf("while loading image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	v := &verifier{
+		fimg:        &fimg,
+		keyring:     keyring,
+		isGroup:     true,
+		localVerify: true,
+	}
+	links := groupSignatures(b, &fimg, groups)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, r := range v.verifyAll(context.Background(), links, workers) {
+					if r.fail {
+						b.Fatalf("verification of signature %d failed", r.sigID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGetSignatures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const groups = 2
+
+	keyring, entity := testKeyring(t, dir)
+	path := createSignedGroups(t, dir, entity, groups, 16)
+
+	// sign the first data object with a key not in the keyring
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		t.Fatalf("while loading image: %s", err)
+	}
+	defer fimg.UnloadContainer()
+
+	if err := sifAddSignature(&fimg, sif.DescrUnusedGroup, 1, other.PrimaryKey.Fingerprint, []byte("signature")); err != nil {
+		t.Fatalf("while adding signature: %s", err)
+	}
+
+	signatures, err := getSignatures(&fimg, keyring)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fingerprint := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	expected := []Signature{
+		{
+			ID:          3,
+			Fingerprint: fingerprint,
+			Signer:      getFirstIdentity(entity),
+			KeyLocal:    true,
+			Group:       1,
+			Objects:     []SignedObject{{1, sif.DataGeneric.String()}},
+		},
+		{
+			ID:          4,
+			Fingerprint: fingerprint,
+			Signer:      getFirstIdentity(entity),
+			KeyLocal:    true,
+			Group:       2,
+			Objects:     []SignedObject{{2, sif.DataGeneric.String()}},
+		},
+		{
+			ID:          5,
+			Fingerprint: fmt.Sprintf("%X", other.PrimaryKey.Fingerprint),
+			Objects:     []SignedObject{{1, sif.DataGeneric.String()}},
+		},
+	}
+	if !reflect.DeepEqual(signatures, expected) {
+		t.Errorf("unexpected signatures:\ngot:      %+v\nexpected: %+v", signatures, expected)
+	}
+}
+
+func TestCheckSigners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing-")
+	if err != nil {
+		t.Fatalf("while creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, entity := testKeyring(t, dir)
+	path := createSignedGroups(t, dir, entity, 2, 16)
+
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatalf("while generating key: %s", err)
+	}
+
+	// sign the second group with another key as well
+	fimg, err := sif.LoadContainer(path, false)
+	if err != nil {
+		t.Fatalf("while loading image: %s", err)
+	}
+	err = sifAddSignature(&fimg, sif.DescrUnusedGroup, sif.DescrGroupMask|2, other.PrimaryKey.Fingerprint, []byte("signature"))
+	fimg.UnloadContainer()
+	if err != nil {
+		t.Fatalf("while adding signature: %s", err)
+	}
+
+	fp := fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+	otherFp := fmt.Sprintf("%X", other.PrimaryKey.Fingerprint)
+
+	tests := []struct {
+		name        string
+		id          uint32
+		fingerprint string
+		signers     []string
+		wantErr     bool
+	}{
+		{"Match", 1, fp, nil, false},
+		{"MatchLowercase", 1, "0x" + strings.ToLower(fp), nil, false},
+		{"OtherSigner", 1, otherFp, []string{fp}, true},
+		{"AdditionalSigner", 2, fp, []string{fp, otherFp}, true},
+		{"InvalidFingerprint", 1, "8883491F", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSigners(path, tt.id, true, false, tt.fingerprint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want error %v", err, tt.wantErr)
+			}
+			if tt.signers == nil {
+				return
+			}
+
+			var serr *SignerMismatchError
+			if !errors.As(err, &serr) {
+				t.Fatalf("unexpected error type %T: %s", err, err)
+			}
+			if !reflect.DeepEqual(serr.Signers, tt.signers) {
+				t.Errorf("got signers %q, expected %q", serr.Signers, tt.signers)
+			}
+			if !strings.Contains(err.Error(), tt.signers[len(tt.signers)-1]) {
+				t.Errorf("error %q doesn't show the signers", err)
+			}
+		})
+	}
+}
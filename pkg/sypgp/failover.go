@@ -0,0 +1,64 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sylabs/scs-key-client/client"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// splitKeyServerURIs returns the key server endpoints of uris, a comma
+// separated list of URIs in the order they are tried. An empty list
+// returns a single empty URI designating the default key server.
+func splitKeyServerURIs(uris string) []string {
+	var endpoints []string
+	for _, uri := range strings.Split(uris, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			endpoints = append(endpoints, uri)
+		}
+	}
+	if len(endpoints) == 0 {
+		return []string{""}
+	}
+	return endpoints
+}
+
+// withFailover calls op with a Key Service client for each endpoint of
+// uris in order, as returned by splitKeyServerURIs, until one of them
+// succeeds. Each endpoint is retried according to the retry policy, the
+// next one is only tried when the failure is transient. The error of
+// the last endpoint tried is returned.
+func withFailover(ctx context.Context, name string, cfg client.Config, uris string, op func(c *client.Client) error) error {
+	endpoints := splitKeyServerURIs(uris)
+
+	var err error
+	for i, uri := range endpoints {
+		cfg.BaseURL = uri
+
+		c, cerr := client.NewClient(&cfg)
+		if cerr != nil {
+			return cerr
+		}
+
+		err = withRetry(ctx, name, func() error {
+			return op(c)
+		})
+		if err == nil {
+			sylog.Debugf("Key server %s served by %s", name, c.BaseURL)
+			return nil
+		}
+		if !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		if i < len(endpoints)-1 {
+			sylog.Warningf("Key server %s failed on %s, trying %s: %v", name, c.BaseURL, endpoints[i+1], err)
+		}
+	}
+	return err
+}
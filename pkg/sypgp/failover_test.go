@@ -0,0 +1,85 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestSplitKeyServerURIs(t *testing.T) {
+	tests := []struct {
+		name     string
+		uris     string
+		expected []string
+	}{
+		{"Empty", "", []string{""}},
+		{"Single", "https://keys.example.com", []string{"https://keys.example.com"}},
+		{"Multiple", "https://keys.example.com, https://mirror.example.com", []string{"https://keys.example.com", "https://mirror.example.com"}},
+		{"EmptyEntries", ",https://keys.example.com,,", []string{"https://keys.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitKeyServerURIs(tt.uris); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFetchPubkeyFailover(t *testing.T) {
+	defer setTestRetryPolicy(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	})()
+
+	fp := hex.EncodeToString(testEntity.PrimaryKey.Fingerprint[:])
+
+	tests := []struct {
+		name             string
+		code             int
+		wantErr          bool
+		expectedPrimary  int
+		expectedFailover int
+	}{
+		{"Transient", http.StatusServiceUnavailable, false, 2, 1},
+		{"NotFound", http.StatusNotFound, true, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := &mockPKSLookup{code: http.StatusOK, el: openpgp.EntityList{testEntity}}
+
+			primary := &flakyServer{failures: 100, code: tt.code, handler: lookup}
+			psrv := httptest.NewTLSServer(primary)
+			defer psrv.Close()
+
+			failover := &flakyServer{handler: lookup}
+			fsrv := httptest.NewTLSServer(failover)
+			defer fsrv.Close()
+
+			uris := psrv.URL + "," + fsrv.URL
+			_, err := FetchPubkey(context.Background(), psrv.Client(), fp, uris, "", false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want error %v", err, tt.wantErr)
+			}
+			if primary.requests != tt.expectedPrimary {
+				t.Errorf("got %d requests on the primary key server, expected %d", primary.requests, tt.expectedPrimary)
+			}
+			if failover.requests != tt.expectedFailover {
+				t.Errorf("got %d requests on the failover key server, expected %d", failover.requests, tt.expectedFailover)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"github.com/sylabs/singularity/internal/pkg/sylog"
+)
+
+// RetryPolicy is the retry policy of the Key Service operations, the
+// delay between two attempts starts at InitialBackoff and doubles after
+// each failed attempt up to MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of an operation,
+	// values lower than 2 disable the retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between two attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy used unless
+// another one is set with SetRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     8 * time.Second,
+}
+
+var (
+	retryMutex  sync.RWMutex
+	retryPolicy = DefaultRetryPolicy
+)
+
+// SetRetryPolicy sets the retry policy of the Key Service operations.
+func SetRetryPolicy(p RetryPolicy) {
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+	retryPolicy = p
+}
+
+// GetRetryPolicy returns the retry policy of the Key Service operations.
+func GetRetryPolicy() RetryPolicy {
+	retryMutex.RLock()
+	defer retryMutex.RUnlock()
+	return retryPolicy
+}
+
+// backoff returns the delay before the retry following
+// the failed attempt number attempt, starting at 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// isTransient returns whether err is a transient error worth retrying:
+// a server error (5xx status) or a network error. Errors due to the
+// request itself, to TLS verification or to the context are not.
+func isTransient(err error) bool {
+	var jerr *jsonresp.Error
+	if errors.As(err, &jerr) {
+		return jerr.Code >= http.StatusInternalServerError
+	}
+
+	// url.Error implements net.Error, look at the underlying error
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// withRetry calls op until it succeeds, fails with a non transient
+// error or the attempts of the retry policy are exhausted, the last
+// error is returned.
+func withRetry(ctx context.Context, name string, op func() error) error {
+	p := GetRetryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= p.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		d := p.backoff(attempt)
+		sylog.Debugf("Key server %s failed (attempt %d/%d), retrying in %s: %v", name, attempt, p.MaxAttempts, d, err)
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
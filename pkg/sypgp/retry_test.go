@@ -0,0 +1,193 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"golang.org/x/crypto/openpgp"
+)
+
+// flakyServer fails the first requests with the failure
+// status code before handing them to handler.
+type flakyServer struct {
+	mutex    sync.Mutex
+	failures int
+	code     int
+	requests int
+	handler  http.Handler
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	fail := s.requests <= s.failures
+	s.mutex.Unlock()
+
+	if fail {
+		w.WriteHeader(s.code)
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+func setTestRetryPolicy(p RetryPolicy) func() {
+	old := GetRetryPolicy()
+	SetRetryPolicy(p)
+	return func() { SetRetryPolicy(old) }
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, e := range expected {
+		if d := p.backoff(i + 1); d != e {
+			t.Errorf("got backoff %s after attempt %d, expected %s", d, i+1, e)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"ServiceUnavailable", jsonresp.NewError(http.StatusServiceUnavailable, ""), true},
+		{"InternalServerError", jsonresp.NewError(http.StatusInternalServerError, ""), true},
+		{"NotFound", jsonresp.NewError(http.StatusNotFound, ""), false},
+		{"Unauthorized", jsonresp.NewError(http.StatusUnauthorized, ""), false},
+		{"ConnectionRefused", &url.Error{Op: "Get", URL: "https://keys", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, true},
+		{"ConnectionClosed", &url.Error{Op: "Get", URL: "https://keys", Err: io.EOF}, true},
+		{"Certificate", &url.Error{Op: "Get", URL: "https://keys", Err: x509.UnknownAuthorityError{}}, false},
+		{"Canceled", &url.Error{Op: "Get", URL: "https://keys", Err: context.Canceled}, false},
+		{"Other", errors.New("invalid key text"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.transient {
+				t.Errorf("got transient %v, expected %v", got, tt.transient)
+			}
+		})
+	}
+}
+
+func TestFetchPubkeyRetry(t *testing.T) {
+	defer setTestRetryPolicy(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})()
+
+	fp := hex.EncodeToString(testEntity.PrimaryKey.Fingerprint[:])
+
+	tests := []struct {
+		name             string
+		failures         int
+		code             int
+		wantErr          bool
+		expectedRequests int
+	}{
+		{"NoFailure", 0, http.StatusServiceUnavailable, false, 1},
+		{"Recovered", 2, http.StatusServiceUnavailable, false, 3},
+		{"Exhausted", 3, http.StatusBadGateway, true, 3},
+		{"NotRetried", 1, http.StatusNotFound, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &flakyServer{
+				failures: tt.failures,
+				code:     tt.code,
+				handler:  &mockPKSLookup{code: http.StatusOK, el: openpgp.EntityList{testEntity}},
+			}
+			srv := httptest.NewTLSServer(ms)
+			defer srv.Close()
+
+			_, err := FetchPubkey(context.Background(), srv.Client(), fp, srv.URL, "", false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want error %v", err, tt.wantErr)
+			}
+			if ms.requests != tt.expectedRequests {
+				t.Errorf("got %d requests, expected %d", ms.requests, tt.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestPushPubkeyRetry(t *testing.T) {
+	defer setTestRetryPolicy(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	})()
+
+	keyText, err := serializeEntity(testEntity, openpgp.PublicKeyType)
+	if err != nil {
+		t.Fatalf("failed to serialize entity: %v", err)
+	}
+
+	ms := &flakyServer{
+		failures: 1,
+		code:     http.StatusServiceUnavailable,
+		handler:  &mockPKSAdd{t: t, keyText: keyText, code: http.StatusOK},
+	}
+	srv := httptest.NewTLSServer(ms)
+	defer srv.Close()
+
+	if err := PushPubkey(context.Background(), srv.Client(), testEntity, srv.URL, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ms.requests != 2 {
+		t.Errorf("got %d requests, expected 2", ms.requests)
+	}
+}
+
+func TestWithRetryCanceled(t *testing.T) {
+	defer setTestRetryPolicy(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Hour,
+	})()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := withRetry(ctx, "fetch", func() error {
+		attempts++
+		return jsonresp.NewError(http.StatusServiceUnavailable, "")
+	})
+	if err == nil {
+		t.Fatalf("unexpected success")
+	}
+	if attempts != 1 {
+		t.Errorf("got %d attempts with a canceled context, expected 1", attempts)
+	}
+}
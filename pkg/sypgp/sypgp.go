@@ -51,6 +51,10 @@ var (
 	// ErrEmptyKeyring is the error when the public, or private keyring
 	// empty.
 	ErrEmptyKeyring = errors.New("keyring is empty")
+
+	// ErrKeyNotFound is the error when no key of the keyring matches
+	// the requested fingerprint.
+	ErrKeyNotFound = errors.New("key not found in keyring")
 )
 
 // KeyExistsError is a type representing an error associated to a specific key.
@@ -455,20 +459,15 @@ func (keyring *Handle) genKeyPair(opts GenKeyPairOptions) (*openpgp.Entity, erro
 	return entity, nil
 }
 
-// GenKeyPair generates an PGP key pair and store them in the sypgp home folder
+// GenKeyPair generates an PGP key pair with the identity of opts and
+// stores both keys in the keyring, without prompting the user. The
+// private key is encrypted with opts.Password unless it's empty.
 func (keyring *Handle) GenKeyPair(opts GenKeyPairOptions) (*openpgp.Entity, error) {
 	if err := keyring.PathsCheck(); err != nil {
 		return nil, err
 	}
 
-	entity, err := keyring.genKeyPair(opts)
-	if err != nil {
-		// Print the missing newline if there’s an error
-		fmt.Printf("\n")
-		return nil, err
-	}
-
-	return entity, nil
+	return keyring.genKeyPair(opts)
 }
 
 // DecryptKey decrypts a private key provided a pass phrase.
@@ -573,7 +572,10 @@ func formatMROutput(mrString string) (int, []byte, error) {
 	return count, retList.Bytes(), nil
 }
 
-// SearchPubkey connects to a key server and searches for a specific key
+// SearchPubkey connects to a key server and searches for a specific key,
+// transient errors are retried according to the retry policy. keyserverURI
+// may be a comma separated list of key servers tried in order, the next
+// one being used when a transient error persists.
 func SearchPubkey(ctx context.Context, httpClient *http.Client, search, keyserverURI, authToken string, longOutput bool) error {
 	// If the search term is 8+ hex chars then it's a fingerprint, and
 	// we need to prefix with 0x for the search.
@@ -582,14 +584,9 @@ func SearchPubkey(ctx context.Context, httpClient *http.Client, search, keyserve
 		search = "0x" + search
 	}
 
-	// Get a Key Service client.
-	c, err := client.NewClient(&client.Config{
-		BaseURL:    keyserverURI,
+	cfg := client.Config{
 		AuthToken:  authToken,
 		HTTPClient: httpClient,
-	})
-	if err != nil {
-		return err
 	}
 
 	// the max entities to print.
@@ -601,7 +598,11 @@ func SearchPubkey(ctx context.Context, httpClient *http.Client, search, keyserve
 	// set the machine readable output on
 	var options = []string{client.OptionMachineReadable}
 	// Retrieve first page of search results from Key Service.
-	keyText, err := c.PKSLookup(ctx, &pd, search, client.OperationIndex, true, false, options)
+	var keyText string
+	err := withFailover(ctx, "search", cfg, keyserverURI, func(c *client.Client) (err error) {
+		keyText, err = c.PKSLookup(ctx, &pd, search, client.OperationIndex, true, false, options)
+		return err
+	})
 	if err != nil {
 		if jerr, ok := err.(*jsonresp.Error); ok && jerr.Code == http.StatusUnauthorized {
 			// The request failed with HTTP code unauthorized. Guide user to fix that.
@@ -773,7 +774,10 @@ func formatMROutputLongList(mrString string) (int, []byte, error) {
 	return keyList.keyCount, retList.Bytes(), nil
 }
 
-// FetchPubkey pulls a public key from the Key Service.
+// FetchPubkey pulls a public key from the Key Service, transient errors
+// are retried according to the retry policy. keyserverURI may be a comma
+// separated list of key servers tried in order, the next one being used
+// when a transient error persists.
 func FetchPubkey(ctx context.Context, httpClient *http.Client, fingerprint, keyserverURI, authToken string, noPrompt bool) (openpgp.EntityList, error) {
 
 	// Decode fingerprint and ensure proper length.
@@ -788,18 +792,17 @@ func FetchPubkey(ctx context.Context, httpClient *http.Client, fingerprint, keys
 		return nil, fmt.Errorf("not a valid key lenth: only accepts 8, or 40 chars")
 	}
 
-	// Get a Key Service client.
-	c, err := client.NewClient(&client.Config{
-		BaseURL:    keyserverURI,
+	cfg := client.Config{
 		AuthToken:  authToken,
 		HTTPClient: httpClient,
-	})
-	if err != nil {
-		return nil, err
 	}
 
 	// Pull key from Key Service.
-	keyText, err := c.GetKey(ctx, fp)
+	var keyText string
+	err = withFailover(ctx, "fetch", cfg, keyserverURI, func(c *client.Client) (err error) {
+		keyText, err = c.GetKey(ctx, fp)
+		return err
+	})
 	if err != nil {
 		if jerr, ok := err.(*jsonresp.Error); ok && jerr.Code == http.StatusUnauthorized {
 			// The request failed with HTTP code unauthorized. Guide user to fix that.
@@ -970,6 +973,39 @@ func (keyring *Handle) ExportPubKey(kpath string, armor bool) error {
 	return nil
 }
 
+// PubKey returns the public key of the keyring matching fingerprint,
+// given in hexadecimal with or without the 0x prefix. ErrKeyNotFound
+// is returned if there is no such key.
+func (keyring *Handle) PubKey(fingerprint string) (*openpgp.Entity, error) {
+	localEntityList, err := loadKeyring(keyring.PublicPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to open local keyring: %v", err)
+	}
+
+	fingerprint = strings.ToUpper(strings.TrimPrefix(fingerprint, "0x"))
+
+	e := findKeyByFingerprint(localEntityList, fingerprint)
+	if e == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, fingerprint)
+	}
+	return e, nil
+}
+
+// ExportArmoredPubKey returns the public key of the keyring matching
+// fingerprint in ASCII armored form, without prompting the user.
+func (keyring *Handle) ExportArmoredPubKey(fingerprint string) (string, error) {
+	e, err := keyring.PubKey(fingerprint)
+	if err != nil {
+		return "", err
+	}
+
+	keyText, err := serializeEntity(e, openpgp.PublicKeyType)
+	if err != nil {
+		return "", fmt.Errorf("unable to serialize public key: %v", err)
+	}
+	return keyText, nil
+}
+
 func findEntityByFingerprint(entities openpgp.EntityList, fingerprint [20]byte) *openpgp.Entity {
 	for _, entity := range entities {
 		if entity.PrimaryKey.Fingerprint == fingerprint {
@@ -1091,25 +1127,26 @@ func (keyring *Handle) ImportKey(kpath string, setNewPassword bool) error {
 	return nil
 }
 
-// PushPubkey pushes a public key to the Key Service.
+// PushPubkey pushes a public key to the Key Service, transient errors
+// are retried according to the retry policy. keyserverURI may be a comma
+// separated list of key servers tried in order, the next one being used
+// when a transient error persists.
 func PushPubkey(ctx context.Context, httpClient *http.Client, e *openpgp.Entity, keyserverURI, authToken string) error {
 	keyText, err := serializeEntity(e, openpgp.PublicKeyType)
 	if err != nil {
 		return err
 	}
 
-	// Get a Key Service client.
-	c, err := client.NewClient(&client.Config{
-		BaseURL:    keyserverURI,
+	cfg := client.Config{
 		AuthToken:  authToken,
 		HTTPClient: httpClient,
-	})
-	if err != nil {
-		return err
 	}
 
 	// Push key to Key Service.
-	if err := c.PKSAdd(ctx, keyText); err != nil {
+	err = withFailover(ctx, "push", cfg, keyserverURI, func(c *client.Client) error {
+		return c.PKSAdd(ctx, keyText)
+	})
+	if err != nil {
 		if jerr, ok := err.(*jsonresp.Error); ok && jerr.Code == http.StatusUnauthorized {
 			// The request failed with HTTP code unauthorized. Guide user to fix that.
 			sylog.Infof(helpAuth+helpPush, e.PrimaryKey.Fingerprint)
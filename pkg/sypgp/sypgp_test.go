@@ -9,12 +9,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sylabs/singularity/internal/pkg/test"
@@ -495,6 +498,63 @@ func TestGenKeyPair(t *testing.T) {
 	}
 }
 
+func TestExportArmoredPubKey(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	keyring := NewHandle(dir)
+
+	if _, err := keyring.ExportArmoredPubKey("0x0123456789ABCDEF0123456789ABCDEF01234567"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("unexpected error with an empty keyring: %v", err)
+	}
+
+	e, err := keyring.GenKeyPair(GenKeyPairOptions{Name: "signer", Email: "signer@my.info", KeyLength: 1024})
+	if err != nil {
+		t.Fatalf("failed to generate key pair: %s", err)
+	}
+
+	// the fingerprint is matched case-insensitively, with or without 0x
+	fingerprint := fmt.Sprintf("0x%x", e.PrimaryKey.Fingerprint)
+
+	keyText, err := keyring.ExportArmoredPubKey(fingerprint)
+	if err != nil {
+		t.Fatalf("failed to export public key: %s", err)
+	}
+
+	el, err := openpgp.ReadArmoredKeyRing(strings.NewReader(keyText))
+	if err != nil {
+		t.Fatalf("failed to read exported public key: %s", err)
+	}
+	if len(el) != 1 || el[0].PrimaryKey.Fingerprint != e.PrimaryKey.Fingerprint {
+		t.Fatalf("unexpected exported keys")
+	}
+	if el[0].PrivateKey != nil {
+		t.Errorf("exported public key contains the private key")
+	}
+	if _, ok := el[0].Identities["signer <signer@my.info>"]; !ok {
+		t.Errorf("exported public key doesn't have the generated identity")
+	}
+
+	// the private key is stored in the keyring too
+	privKeys, err := keyring.LoadPrivKeyring()
+	if err != nil {
+		t.Fatalf("failed to load private keyring: %s", err)
+	}
+	if findEntityByFingerprint(privKeys, e.PrimaryKey.Fingerprint) == nil {
+		t.Errorf("generated private key not found in the keyring")
+	}
+}
+
 func TestCompareKeyEntity(t *testing.T) {
 	cases := []struct {
 		name        string
@@ -0,0 +1,235 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// MaxTrustDepth is the maximum number of certifications of a trust
+// chain, as the default of the GnuPG web of trust.
+const MaxTrustDepth = 5
+
+// sigTypeCertRevocation is the type of the signatures revoking a
+// certification, missing from the openpgp package.
+const sigTypeCertRevocation packet.SignatureType = 0x30
+
+// TrustChainError is the error when no chain of certifications links
+// a key to a trust anchor.
+type TrustChainError struct {
+	// Fingerprint is the fingerprint of the key checked.
+	Fingerprint string
+	// Reasons are the problems preventing a chain, the certifications
+	// rejected and the keys which couldn't be followed.
+	Reasons []string
+}
+
+func (e *TrustChainError) Error() string {
+	msg := fmt.Sprintf("no trust chain from key %s to a trust anchor", e.Fingerprint)
+	if len(e.Reasons) > 0 {
+		msg += ": " + strings.Join(e.Reasons, "; ")
+	}
+	return msg
+}
+
+// entityFingerprint returns the fingerprint of e in hexadecimal.
+func entityFingerprint(e *openpgp.Entity) string {
+	return fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)
+}
+
+// keyProblem returns why the key e can't be part of a trust chain
+// at now, or an empty string if it can.
+func keyProblem(e *openpgp.Entity, now time.Time) string {
+	if len(e.Revocations) > 0 {
+		return fmt.Sprintf("key %s is revoked", entityFingerprint(e))
+	}
+	for _, ident := range e.Identities {
+		if ident.SelfSignature != nil && ident.SelfSignature.KeyExpired(now) {
+			return fmt.Sprintf("key %s is expired", entityFingerprint(e))
+		}
+	}
+	return ""
+}
+
+// isCertification returns if sig certifies a user identity.
+func isCertification(sig *packet.Signature) bool {
+	switch sig.SigType {
+	case packet.SigTypeGenericCert, packet.SigTypePersonaCert, packet.SigTypeCasualCert, packet.SigTypePositiveCert:
+		return true
+	}
+	return false
+}
+
+// sigExpired returns if the signature sig is expired at now.
+func sigExpired(sig *packet.Signature, now time.Time) bool {
+	if sig.SigLifetimeSecs == nil || *sig.SigLifetimeSecs == 0 {
+		return false
+	}
+	return now.After(sig.CreationTime.Add(time.Duration(*sig.SigLifetimeSecs) * time.Second))
+}
+
+// certifiers returns the keys of el with a valid certification of an
+// identity of e at now, along with the reasons the other certifications
+// made by keys of el are rejected. Self-signatures are not certifications.
+func certifiers(el openpgp.EntityList, e *openpgp.Entity, now time.Time) ([]*openpgp.Entity, []string) {
+	var found []*openpgp.Entity
+	var reasons []string
+
+	certified := make(map[*openpgp.Entity]bool)
+	for name, ident := range e.Identities {
+		for _, sig := range ident.Signatures {
+			if !isCertification(sig) || sig.IssuerKeyId == nil || *sig.IssuerKeyId == e.PrimaryKey.KeyId {
+				continue
+			}
+			keys := el.KeysById(*sig.IssuerKeyId)
+			if len(keys) == 0 {
+				continue
+			}
+			issuer := keys[0].Entity
+			if certified[issuer] {
+				continue
+			}
+
+			prefix := fmt.Sprintf("certification of %s by %s", entityFingerprint(e), entityFingerprint(issuer))
+			if err := issuer.PrimaryKey.VerifyUserIdSignature(name, e.PrimaryKey, sig); err != nil {
+				reasons = append(reasons, fmt.Sprintf("%s is invalid: %s", prefix, err))
+				continue
+			}
+			if sigExpired(sig, now) {
+				reasons = append(reasons, prefix+" is expired")
+				continue
+			}
+			if certRevoked(ident, name, e, issuer, sig) {
+				reasons = append(reasons, prefix+" is revoked")
+				continue
+			}
+			certified[issuer] = true
+			found = append(found, issuer)
+		}
+	}
+
+	return found, reasons
+}
+
+// certRevoked returns if the certification sig of the identity name
+// of e by issuer is revoked by a later revocation of issuer.
+func certRevoked(ident *openpgp.Identity, name string, e, issuer *openpgp.Entity, sig *packet.Signature) bool {
+	for _, rev := range ident.Signatures {
+		if rev.SigType != sigTypeCertRevocation || rev.IssuerKeyId == nil || *rev.IssuerKeyId != issuer.PrimaryKey.KeyId {
+			continue
+		}
+		if rev.CreationTime.Before(sig.CreationTime) {
+			continue
+		}
+		if issuer.PrimaryKey.VerifyUserIdSignature(name, e.PrimaryKey, rev) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustChain returns the chain of keys of el linking the key with the
+// fingerprint signer to one of the trust anchors, given by fingerprint.
+// Each key of the chain is certified by the next one, the first key is
+// the signer and the last one a trust anchor, the signer being its own
+// chain when it is a trust anchor. Only valid certifications, neither
+// expired nor revoked, made by keys of el which are neither expired nor
+// revoked are followed. The shortest chain of at most MaxTrustDepth
+// certifications is returned. ErrKeyNotFound is returned if the signer
+// is not in el, and a TrustChainError holding the reasons if there is
+// no chain.
+func TrustChain(el openpgp.EntityList, signer string, anchors []string) ([]*openpgp.Entity, error) {
+	signer = strings.ToUpper(strings.TrimPrefix(signer, "0x"))
+
+	start := findKeyByFingerprint(el, signer)
+	if start == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, signer)
+	}
+
+	now := time.Now()
+	chainErr := &TrustChainError{Fingerprint: signer}
+
+	isAnchor := make(map[string]bool)
+	for _, a := range anchors {
+		a = strings.ToUpper(strings.TrimPrefix(a, "0x"))
+		if findKeyByFingerprint(el, a) == nil {
+			chainErr.Reasons = append(chainErr.Reasons, fmt.Sprintf("trust anchor %s is not in the keyring", a))
+		}
+		isAnchor[a] = true
+	}
+	if len(isAnchor) == 0 {
+		chainErr.Reasons = append(chainErr.Reasons, "no trust anchor configured")
+		return nil, chainErr
+	}
+
+	// breadth first walk from the signer to the keys certifying it,
+	// certifies records the key certified by each key reached
+	certifies := map[*openpgp.Entity]*openpgp.Entity{start: nil}
+	level := []*openpgp.Entity{start}
+	truncated := false
+
+	for depth := 0; len(level) > 0; depth++ {
+		var certified []*openpgp.Entity
+
+		for _, e := range level {
+			if problem := keyProblem(e, now); problem != "" {
+				chainErr.Reasons = append(chainErr.Reasons, problem)
+				continue
+			}
+			if isAnchor[entityFingerprint(e)] {
+				// certifies leads back from the anchor to the signer
+				var chain []*openpgp.Entity
+				for k := e; k != nil; k = certifies[k] {
+					chain = append(chain, k)
+				}
+				for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+					chain[i], chain[j] = chain[j], chain[i]
+				}
+				return chain, nil
+			}
+			if depth == MaxTrustDepth {
+				truncated = true
+				continue
+			}
+
+			issuers, reasons := certifiers(el, e, now)
+			chainErr.Reasons = append(chainErr.Reasons, reasons...)
+			if len(issuers) == 0 && len(reasons) == 0 {
+				chainErr.Reasons = append(chainErr.Reasons, fmt.Sprintf("key %s is not certified by a key of the keyring", entityFingerprint(e)))
+			}
+			for _, issuer := range issuers {
+				if _, ok := certifies[issuer]; ok {
+					continue
+				}
+				certifies[issuer] = e
+				certified = append(certified, issuer)
+			}
+		}
+		level = certified
+	}
+
+	if truncated {
+		chainErr.Reasons = append(chainErr.Reasons, fmt.Sprintf("no trust anchor within %d certifications", MaxTrustDepth))
+	}
+	return nil, chainErr
+}
+
+// TrustChain returns the chain of keys of the public keyring linking
+// the key with the fingerprint signer to one of the trust anchors,
+// see TrustChain.
+func (keyring *Handle) TrustChain(signer string, anchors []string) ([]*openpgp.Entity, error) {
+	el, err := loadKeyring(keyring.PublicPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to open local keyring: %v", err)
+	}
+	return TrustChain(el, signer, anchors)
+}
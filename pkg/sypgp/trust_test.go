@@ -0,0 +1,213 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package sypgp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// newTrustEntity returns a new key pair with a single identity name.
+func newTrustEntity(t *testing.T, name string) *openpgp.Entity {
+	e, err := openpgp.NewEntity(name, "", name+"@example.org", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("failed to create key %s: %s", name, err)
+	}
+	return e
+}
+
+// certify adds to e a certification by signer of type sigType, created
+// at created and valid for lifetime seconds if not zero.
+func certify(t *testing.T, e, signer *openpgp.Entity, sigType packet.SignatureType, created time.Time, lifetime uint32) {
+	for name, ident := range e.Identities {
+		sig := &packet.Signature{
+			SigType:      sigType,
+			PubKeyAlgo:   signer.PrivateKey.PubKeyAlgo,
+			Hash:         (&packet.Config{}).Hash(),
+			CreationTime: created,
+			IssuerKeyId:  &signer.PrivateKey.KeyId,
+		}
+		if lifetime != 0 {
+			sig.SigLifetimeSecs = &lifetime
+		}
+		if err := sig.SignUserId(name, e.PrimaryKey, signer.PrivateKey, nil); err != nil {
+			t.Fatalf("failed to certify key: %s", err)
+		}
+		ident.Signatures = append(ident.Signatures, sig)
+	}
+}
+
+func TestTrustChain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	past := time.Now().Add(-time.Hour)
+
+	anchor := newTrustEntity(t, "anchor")
+	intermediate := newTrustEntity(t, "intermediate")
+	signer := newTrustEntity(t, "signer")
+	deep := newTrustEntity(t, "deep")
+	expired := newTrustEntity(t, "expired")
+	revoked := newTrustEntity(t, "revoked")
+	outsider := newTrustEntity(t, "outsider")
+	stranger := newTrustEntity(t, "stranger")
+
+	certify(t, intermediate, anchor, packet.SigTypeGenericCert, past, 0)
+	certify(t, signer, intermediate, packet.SigTypePositiveCert, past, 0)
+	certify(t, expired, anchor, packet.SigTypeGenericCert, past, 60)
+	certify(t, revoked, anchor, packet.SigTypeGenericCert, past, 0)
+	certify(t, revoked, anchor, sigTypeCertRevocation, past.Add(time.Minute), 0)
+	certify(t, stranger, outsider, packet.SigTypeGenericCert, past, 0)
+
+	// a chain one certification too long
+	chain := []*openpgp.Entity{deep}
+	for i := 0; i < MaxTrustDepth; i++ {
+		k := newTrustEntity(t, fmt.Sprintf("link%d", i))
+		certify(t, chain[len(chain)-1], k, packet.SigTypeGenericCert, past, 0)
+		chain = append(chain, k)
+	}
+	certify(t, chain[len(chain)-1], anchor, packet.SigTypeGenericCert, past, 0)
+
+	el := openpgp.EntityList{anchor, intermediate, signer, deep, expired, revoked, stranger}
+	el = append(el, chain[1:]...)
+
+	tests := []struct {
+		name        string
+		signer      *openpgp.Entity
+		anchors     []string
+		chain       []*openpgp.Entity
+		expectError bool
+	}{
+		{
+			name:    "Anchor",
+			signer:  anchor,
+			anchors: []string{entityFingerprint(anchor)},
+			chain:   []*openpgp.Entity{anchor},
+		},
+		{
+			name:    "Direct",
+			signer:  intermediate,
+			anchors: []string{entityFingerprint(anchor)},
+			chain:   []*openpgp.Entity{intermediate, anchor},
+		},
+		{
+			name:    "Transitive",
+			signer:  signer,
+			anchors: []string{fmt.Sprintf("0x%x", anchor.PrimaryKey.Fingerprint)},
+			chain:   []*openpgp.Entity{signer, intermediate, anchor},
+		},
+		{
+			name:    "Shortest",
+			signer:  signer,
+			anchors: []string{entityFingerprint(anchor), entityFingerprint(intermediate)},
+			chain:   []*openpgp.Entity{signer, intermediate},
+		},
+		{
+			name:        "NoAnchor",
+			signer:      signer,
+			expectError: true,
+		},
+		{
+			name:        "NotCertified",
+			signer:      anchor,
+			anchors:     []string{entityFingerprint(signer)},
+			expectError: true,
+		},
+		{
+			name:        "ExpiredCertification",
+			signer:      expired,
+			anchors:     []string{entityFingerprint(anchor)},
+			expectError: true,
+		},
+		{
+			name:        "RevokedCertification",
+			signer:      revoked,
+			anchors:     []string{entityFingerprint(anchor)},
+			expectError: true,
+		},
+		{
+			name:        "CertifierNotInKeyring",
+			signer:      stranger,
+			anchors:     []string{entityFingerprint(outsider)},
+			expectError: true,
+		},
+		{
+			name:        "TooDeep",
+			signer:      deep,
+			anchors:     []string{entityFingerprint(anchor)},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := TrustChain(el, entityFingerprint(tt.signer), tt.anchors)
+			if tt.expectError {
+				var tce *TrustChainError
+				if !errors.As(err, &tce) {
+					t.Fatalf("got error %v, expected a trust chain error", err)
+				}
+				if len(tce.Reasons) == 0 {
+					t.Errorf("no reason reported for the missing trust chain")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(chain) != len(tt.chain) {
+				t.Fatalf("got chain of %d keys, expected %d", len(chain), len(tt.chain))
+			}
+			for i := range chain {
+				if chain[i] != tt.chain[i] {
+					t.Errorf("got key %s at %d in chain, expected %s", entityFingerprint(chain[i]), i, entityFingerprint(tt.chain[i]))
+				}
+			}
+		})
+	}
+
+	if _, err := TrustChain(el, entityFingerprint(outsider), []string{entityFingerprint(anchor)}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("got error %v for a signer missing from the keyring, expected %v", err, ErrKeyNotFound)
+	}
+}
+
+func TestHandleTrustChain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	anchor := newTrustEntity(t, "anchor")
+	signer := newTrustEntity(t, "signer")
+	certify(t, signer, anchor, packet.SigTypeGenericCert, time.Now().Add(-time.Hour), 0)
+
+	// the certifications are stored along with the public keys
+	keyring := NewHandle(dir)
+	if err := keyring.storePubKeyring(openpgp.EntityList{anchor, signer}); err != nil {
+		t.Fatalf("failed to store public keys: %s", err)
+	}
+
+	chain, err := keyring.TrustChain(entityFingerprint(signer), []string{entityFingerprint(anchor)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(chain) != 2 || chain[0].PrimaryKey.Fingerprint != signer.PrimaryKey.Fingerprint || chain[1].PrimaryKey.Fingerprint != anchor.PrimaryKey.Fingerprint {
+		t.Errorf("unexpected trust chain")
+	}
+}
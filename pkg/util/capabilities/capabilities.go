@@ -5,7 +5,10 @@
 
 package capabilities
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 const (
 	// Permitted capability string constant.
@@ -468,6 +471,48 @@ func Split(caps string) ([]string, []string) {
 	return Normalize(strings.Split(caps, ","))
 }
 
+// Parse takes a list of capabilities separated by commas, like Split,
+// and returns the normalized capability names. Empty elements are
+// ignored and an error listing them is returned if some capabilities
+// are unknown.
+func Parse(caps string) ([]string, error) {
+	var list []string
+	for _, c := range strings.Split(caps, ",") {
+		if strings.TrimSpace(c) != "" {
+			list = append(list, c)
+		}
+	}
+	if len(list) == 0 {
+		return []string{}, nil
+	}
+
+	included, excluded := Normalize(list)
+	if len(excluded) > 0 {
+		return nil, fmt.Errorf("unknown capabilities: %s", strings.Join(excluded, ","))
+	}
+	return included, nil
+}
+
+// Apply returns the capabilities of caps with the capabilities of add
+// added and then the capabilities of drop removed, a capability both
+// added and dropped is thus removed. The provided lists are not
+// modified.
+func Apply(caps, add, drop []string) []string {
+	result := make([]string, 0, len(caps)+len(add))
+	result = append(result, caps...)
+	result = RemoveDuplicated(append(result, add...))
+
+	for _, d := range drop {
+		for i, c := range result {
+			if c == d {
+				result = append(result[:i], result[i+1:]...)
+				break
+			}
+		}
+	}
+	return result
+}
+
 // RemoveDuplicated removes duplicated capabilities from provided list.
 // It does not make copy of a passed list.
 func RemoveDuplicated(caps []string) []string {
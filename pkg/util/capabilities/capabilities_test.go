@@ -7,6 +7,7 @@ package capabilities
 
 import (
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -167,3 +168,92 @@ func TestRemoveDuplicated(t *testing.T) {
 		})
 	}
 }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		caps        string
+		expected    []string
+		expectError bool
+	}{
+		{"Empty", "", []string{}, false},
+		{"Single", "net_bind_service", []string{"CAP_NET_BIND_SERVICE"}, false},
+		{"Multiple", "CAP_CHOWN, sys_admin,", []string{"CAP_CHOWN", "CAP_SYS_ADMIN"}, false},
+		{"Unknown", "chown,cap_fake", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps, err := Parse(tt.caps)
+			if err != nil && !tt.expectError {
+				t.Fatalf("unexpected error: %s", err)
+			} else if err == nil && tt.expectError {
+				t.Fatalf("unexpected success")
+			}
+			sort.Strings(caps)
+			if strings.Join(caps, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("got %v, expected %v", caps, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	all, _ := Split("all")
+
+	tests := []struct {
+		name     string
+		caps     []string
+		add      string
+		drop     string
+		expected []string
+	}{
+		{
+			name:     "AddOnly",
+			add:      "net_bind_service",
+			expected: []string{"CAP_NET_BIND_SERVICE"},
+		},
+		{
+			name:     "AddExisting",
+			caps:     []string{"CAP_CHOWN"},
+			add:      "chown,kill",
+			expected: []string{"CAP_CHOWN", "CAP_KILL"},
+		},
+		{
+			name:     "DropFromFull",
+			caps:     all,
+			drop:     "all",
+			expected: []string{},
+		},
+		{
+			name:     "AddAndDrop",
+			caps:     []string{"CAP_CHOWN", "CAP_SYS_ADMIN"},
+			add:      "net_bind_service,kill",
+			drop:     "sys_admin,kill",
+			expected: []string{"CAP_CHOWN", "CAP_NET_BIND_SERVICE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, err := Parse(tt.add)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			drop, err := Parse(tt.drop)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			orig := strings.Join(tt.caps, ",")
+
+			caps := Apply(tt.caps, add, drop)
+			sort.Strings(caps)
+			if strings.Join(caps, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("got %v, expected %v", caps, tt.expected)
+			}
+			if strings.Join(tt.caps, ",") != orig {
+				t.Errorf("original capabilities modified")
+			}
+		})
+	}
+}
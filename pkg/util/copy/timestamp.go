@@ -0,0 +1,73 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package copy
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// TimestampWriter is a writer prefixing each line written to the
+// underlying writer with the time at which the line started. Data
+// is written through without buffering, a partial line is completed
+// by the next writes.
+type TimestampWriter struct {
+	mutex   sync.Mutex
+	w       io.Writer
+	format  string
+	now     func() time.Time
+	midLine bool
+}
+
+// NewTimestampWriter returns a TimestampWriter writing to w, the
+// timestamps are formatted with format (eg: time.RFC3339Nano)
+// and separated from the line by a space.
+func NewTimestampWriter(w io.Writer, format string) *TimestampWriter {
+	return &TimestampWriter{
+		w:      w,
+		format: format,
+		now:    time.Now,
+	}
+}
+
+// Write implements the standard Write interface, the returned
+// count doesn't include the timestamps.
+func (tw *TimestampWriter) Write(p []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+
+	written := 0
+
+	for len(p) > 0 {
+		if !tw.midLine {
+			if _, err := io.WriteString(tw.w, tw.now().Format(tw.format)+" "); err != nil {
+				return written, err
+			}
+			tw.midLine = true
+		}
+
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+
+		n, err := tw.w.Write(line)
+		written += n
+		if err != nil {
+			return written, err
+		} else if n != len(line) {
+			return written, io.ErrShortWrite
+		}
+		if line[len(line)-1] == '\n' {
+			tw.midLine = false
+		}
+		p = p[len(line):]
+	}
+
+	return written, nil
+}
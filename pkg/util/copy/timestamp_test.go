@@ -0,0 +1,53 @@
+// Copyright (c) 2020, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package copy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTimestampWriter(t *testing.T) {
+	cases := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"Empty", []string{""}, ""},
+		{"Line", []string{"hello\n"}, "T1 hello\n"},
+		{"Lines", []string{"hello\nworld\n"}, "T1 hello\nT2 world\n"},
+		{"PartialLine", []string{"hel", "lo\nwor", "ld"}, "T1 hello\nT2 world"},
+		{"EmptyLines", []string{"\n\n"}, "T1 \nT2 \n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			tw := NewTimestampWriter(&b, "T5")
+			tick := 0
+			tw.now = func() time.Time {
+				tick++
+				return time.Date(2020, 1, 1, 0, 0, tick, 0, time.UTC)
+			}
+
+			for _, w := range tc.writes {
+				n, err := tw.Write([]byte(w))
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if n != len(w) {
+					t.Errorf("got %d bytes written, expected %d", n, len(w))
+				}
+			}
+
+			if b.String() != tc.expected {
+				t.Errorf("got %q, expected %q", b.String(), tc.expected)
+			}
+		})
+	}
+}
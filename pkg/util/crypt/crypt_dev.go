@@ -18,6 +18,7 @@ import (
 	uuid "github.com/satori/go.uuid"
 	"github.com/sylabs/singularity/internal/pkg/sylog"
 	"github.com/sylabs/singularity/internal/pkg/util/bin"
+	"github.com/sylabs/singularity/internal/pkg/util/fs"
 	"github.com/sylabs/singularity/pkg/util/fs/lock"
 	"github.com/sylabs/singularity/pkg/util/loop"
 )
@@ -116,7 +117,7 @@ func (crypt *Device) EncryptFilesystem(path string, key []byte) (string, error)
 	fSize := f.Size()
 
 	// Create a temporary file to format with crypt header
-	cryptF, err := ioutil.TempFile("", "crypt-")
+	cryptF, err := ioutil.TempFile(fs.TempDir(), "crypt-")
 	if err != nil {
 		sylog.Debugf("Error creating temporary crypt file")
 		return "", err
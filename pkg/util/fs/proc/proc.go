@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -149,6 +150,109 @@ func GetMountInfoEntry(path string) ([]MountInfoEntry, error) {
 	return entries, nil
 }
 
+// MountEntry contains parsed fields of a mounts line.
+type MountEntry struct {
+	Source  string
+	Point   string
+	FSType  string
+	Options []string
+}
+
+// unescapeMountField replaces the octal escape sequences used by
+// the kernel for spaces, tabs, newlines and backslashes in mounts.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, "\\") {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if v, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// GetMounts parses a mounts file like /proc/<pid>/mounts and returns
+// all parsed entries as an array of MountEntry.
+func GetMounts(path string) ([]MountEntry, error) {
+	p, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't open %s: %s", path, err)
+	}
+	defer p.Close()
+
+	entries := make([]MountEntry, 0)
+	scanner := bufio.NewScanner(p)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("malformed mount entry in %s: %q", path, scanner.Text())
+		}
+		entries = append(entries, MountEntry{
+			Source:  unescapeMountField(fields[0]),
+			Point:   unescapeMountField(fields[1]),
+			FSType:  fields[2],
+			Options: strings.Split(fields[3], ","),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("while reading %s: %s", path, err)
+	}
+
+	return entries, nil
+}
+
+// OpenFile describes a file descriptor opened by a process.
+type OpenFile struct {
+	Pid int
+	Fd  int
+	// Path is the target of the /proc/<pid>/fd/<fd> link,
+	// like socket:[1234] for files without path.
+	Path string
+}
+
+// GetOpenFiles returns the files opened by the process pid
+// sorted by file descriptor.
+func GetOpenFiles(pid int) ([]OpenFile, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("can't open %s: %s", dir, err)
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, fmt.Errorf("while reading %s: %s", dir, err)
+	}
+
+	files := make([]OpenFile, 0, len(names))
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		path, err := os.Readlink(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			// closed since the directory was read
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		files = append(files, OpenFile{Pid: pid, Fd: fd, Path: path})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Fd < files[j].Fd })
+
+	return files, nil
+}
+
 // FindParentMountEntry finds the parent mount point entry associated
 // to the provided path among the entry list provided in argument.
 func FindParentMountEntry(path string, entries []MountInfoEntry) (*MountInfoEntry, error) {
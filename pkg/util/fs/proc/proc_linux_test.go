@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"reflect"
 	"syscall"
 	"testing"
 
@@ -242,6 +243,70 @@ func TestGetMountInfo(t *testing.T) {
 	}
 }
 
+func TestGetMounts(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	if _, err := GetMounts("/bad/path"); err == nil {
+		t.Fatalf("unexpected success while parsing bad path")
+	}
+
+	tmpfile, err := ioutil.TempFile("", "mounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	data := "proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0\n" +
+		"/dev/sda1 /mnt/my\\040dir ext4 ro,relatime 0 0\n"
+	if _, err := tmpfile.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := GetMounts(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("unexpected error while parsing %s: %s", tmpfile.Name(), err)
+	}
+	expected := []MountEntry{
+		{Source: "proc", Point: "/proc", FSType: "proc", Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}},
+		{Source: "/dev/sda1", Point: "/mnt/my dir", FSType: "ext4", Options: []string{"ro", "relatime"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("got entries %+v, expected %+v", entries, expected)
+	}
+}
+
+func TestGetOpenFiles(t *testing.T) {
+	test.DropPrivilege(t)
+	defer test.ResetPrivilege(t)
+
+	if _, err := GetOpenFiles(0); err == nil {
+		t.Fatalf("no error reported with PID 0")
+	}
+
+	f, err := ioutil.TempFile("", "openfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	files, err := GetOpenFiles(os.Getpid())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := OpenFile{Pid: os.Getpid(), Fd: int(f.Fd()), Path: f.Name()}
+	for _, of := range files {
+		if of == expected {
+			return
+		}
+	}
+	t.Errorf("open file %+v not found in %+v", expected, files)
+}
+
 func TestGetMountPointMap(t *testing.T) {
 	test.DropPrivilege(t)
 	defer test.ResetPrivilege(t)
@@ -18,6 +18,7 @@ type File struct {
 	MountHome               bool     `default:"yes" authorized:"yes,no" directive:"mount home"`
 	MountTmp                bool     `default:"yes" authorized:"yes,no" directive:"mount tmp"`
 	MountHostfs             bool     `default:"no" authorized:"yes,no" directive:"mount hostfs"`
+	MountHostCerts          bool     `default:"yes" authorized:"yes,no" directive:"mount host certs"`
 	UserBindControl         bool     `default:"yes" authorized:"yes,no" directive:"user bind control"`
 	EnableFusemount         bool     `default:"yes" authorized:"yes,no" directive:"enable fusemount"`
 	EnableUnderlay          bool     `default:"yes" authorized:"yes,no" directive:"enable underlay"`
@@ -31,6 +32,7 @@ type File struct {
 	SharedLoopDevices       bool     `default:"no" authorized:"yes,no" directive:"shared loop devices"`
 	MaxLoopDevices          uint     `default:"256" directive:"max loop devices"`
 	SessiondirMaxSize       uint     `default:"16" directive:"sessiondir max size"`
+	WritableTmpfsMaxSize    uint     `default:"0" directive:"writable tmpfs max size"`
 	MountDev                string   `default:"yes" authorized:"yes,no,minimal" directive:"mount dev"`
 	EnableOverlay           string   `default:"try" authorized:"yes,no,try" directive:"enable overlay"`
 	BindPath                []string `default:"/etc/localtime,/etc/hosts" directive:"bind path"`
@@ -39,6 +41,15 @@ type File struct {
 	LimitContainerPaths     []string `directive:"limit container paths"`
 	RootDefaultCapabilities string   `default:"full" authorized:"full,file,no" directive:"root default capabilities"`
 	MemoryFSType            string   `default:"tmpfs" authorized:"tmpfs,ramfs" directive:"memory fs type"`
+	PluginIsolation         string   `default:"manifest" authorized:"manifest,always" directive:"plugin isolation"`
+	PluginHistorySize       uint     `default:"10" directive:"plugin history size"`
+	PluginUnknownFields     string   `default:"deny" authorized:"deny,allow" directive:"plugin manifest unknown fields"`
+	PluginCapabilities      []string `directive:"plugin allowed capabilities"`
+	PluginUndeclaredCaps    string   `default:"allow" authorized:"allow,warn,deny" directive:"plugin undeclared capabilities"`
+	PluginCallbackCheck     string   `default:"warn" authorized:"warn,strict" directive:"plugin callback check"`
+	PluginConfigCheck       string   `default:"warn" authorized:"warn,strict" directive:"plugin config check"`
+	PluginReservedNames     []string `directive:"plugin reserved namespaces"`
+	PluginNamespaceKeys     []string `directive:"plugin namespace key"`
 	CniConfPath             string   `directive:"cni configuration path"`
 	CniPluginPath           string   `directive:"cni plugin path"`
 	MksquashfsPath          string   `directive:"mksquashfs path"`
@@ -142,6 +153,14 @@ mount tmp = {{ if eq .MountTmp true }}yes{{ else }}no{{ end }}
 # those into the container?
 mount hostfs = {{ if eq .MountHostfs true }}yes{{ else }}no{{ end }}
 
+# MOUNT HOST CERTS: [BOOL]
+# DEFAULT: yes
+# If the container doesn't provide a CA certificates bundle, bind the host's
+# one read-only at the location expected by the container distribution so
+# that TLS clients work in minimal images. Users can disable it with
+# --no-host-certs.
+mount host certs = {{ if eq .MountHostCerts true }}yes{{ else }}no{{ end }}
+
 # BIND PATH: [STRING]
 # DEFAULT: Undefined
 # Define a list of files/directories that should be made available from within
@@ -200,6 +219,14 @@ mount slave = {{ if eq .MountSlave true }}yes{{ else }}no{{ end }}
 # location to do default read/writes to (e.g. "--workdir" or "--home").
 sessiondir max size = {{ .SessiondirMaxSize }}
 
+# WRITABLE TMPFS MAXSIZE: [STRING]
+# DEFAULT: 0
+# This specifies the maximum size (in MB) of the temporary filesystem holding
+# the data written in a container run with "--writable-tmpfs". Sizes requested
+# with "--writable-tmpfs-size" above this value are rejected, and this value
+# is used when no size is requested. The default of 0 means no limit.
+writable tmpfs max size = {{ .WritableTmpfsMaxSize }}
+
 # LIMIT CONTAINER OWNERS: [STRING]
 # DEFAULT: NULL
 # Only allow containers to be used that are owned by a given user. If this
@@ -273,6 +300,97 @@ root default capabilities = {{ .RootDefaultCapabilities }}
 # kernel panic
 memory fs type = {{ .MemoryFSType }}
 
+# PLUGIN ISOLATION: [manifest/always]
+# DEFAULT: manifest
+# Define how plugins are loaded by Singularity
+# - manifest: plugins run in an isolated process only when their manifest
+#   requests it, others are loaded into the Singularity process
+# - always: all plugins run in an isolated process, a plugin crash doesn't
+#   affect Singularity but command line and container monitor callbacks
+#   registered by plugins are ignored
+plugin isolation = {{ .PluginIsolation }}
+
+# PLUGIN HISTORY SIZE: [STRING]
+# DEFAULT: 10
+# This specifies how many installations are recorded in the history of each
+# plugin displayed by "plugin inspect --history", the oldest ones being
+# discarded first. A value of 0 keeps the whole history.
+plugin history size = {{ .PluginHistorySize }}
+
+# PLUGIN MANIFEST UNKNOWN FIELDS: [deny/allow]
+# DEFAULT: deny
+# Define how the fields of a plugin manifest unknown to this version of
+# Singularity are handled by "plugin install"
+# - deny: the plugin installation is refused
+# - allow: the unknown fields are ignored, as plugins compiled for a newer
+#   version of Singularity may carry fields this version doesn't know
+plugin manifest unknown fields = {{ .PluginUnknownFields }}
+
+# PLUGIN ALLOWED CAPABILITIES: [STRING]
+# DEFAULT: NULL
+# Only allow plugins declaring these capabilities in their manifest to be
+# installed and loaded. If this configuration is undefined (commented or set
+# to NULL), all capabilities are allowed. Known capabilities are cli-commands,
+# runtime-config, user-mapping, container-monitor and exec-binaries.
+#plugin allowed capabilities = cli-commands, runtime-config
+{{ range $index, $capability := .PluginCapabilities }}
+{{- if eq $index 0 }}plugin allowed capabilities = {{ else }}, {{ end }}{{$capability}}
+{{- end }}
+
+# PLUGIN UNDECLARED CAPABILITIES: [allow/warn/deny]
+# DEFAULT: allow
+# Define how plugins declaring no capabilities in their manifest, like those
+# built for previous versions of Singularity, are handled
+# - allow: the plugins are installed and loaded without restriction
+# - warn: the plugins are installed and loaded with a warning
+# - deny: the plugins are refused
+plugin undeclared capabilities = {{ .PluginUndeclaredCaps }}
+
+# PLUGIN CALLBACK CHECK: [warn/strict]
+# DEFAULT: warn
+# Define how the callbacks declared in a plugin manifest are compared with
+# the callbacks the plugin actually registers, at installation and by
+# "plugin check"
+# - warn: the differences are reported with a warning
+# - strict: plugins registering other callbacks than the declared ones, or
+#   declaring none, are refused at installation and are not loaded
+plugin callback check = {{ .PluginCallbackCheck }}
+
+# PLUGIN CONFIG CHECK: [warn/strict]
+# DEFAULT: warn
+# Define how the configuration keys unknown to the configuration schema
+# declared in a plugin manifest are handled, values of the wrong type are
+# always refused, and how the plugins are loaded with an invalid
+# configuration
+# - warn: the unknown keys are reported with a warning, the plugins with
+#   an invalid configuration are loaded with their default configuration
+# - strict: plugins with unknown configuration keys can't be enabled, the
+#   plugins with an invalid configuration are not loaded
+plugin config check = {{ .PluginConfigCheck }}
+
+# PLUGIN RESERVED NAMESPACES: [STRING]
+# DEFAULT: NULL
+# Plugin name prefixes reserved in addition to sylabs.io/ and singularity/,
+# which are always reserved. A plugin whose manifest name or installation
+# name falls under a reserved namespace is only installed if its image is
+# signed by a key trusted for this namespace with "plugin namespace key".
+#plugin reserved namespaces = example.org/
+{{ range $index, $namespace := .PluginReservedNames }}
+{{- if eq $index 0 }}plugin reserved namespaces = {{ else }}, {{ end }}{{$namespace}}
+{{- end }}
+
+# PLUGIN NAMESPACE KEY: [STRING]
+# DEFAULT: NULL
+# Trust the key with the given fingerprint to sign the plugins of a reserved
+# namespace, given as <namespace>:<fingerprint>. The public key must be in
+# the keyring of the user installing the plugin (see "key import" and "key
+# pull"). This directive can be repeated to trust several keys.
+#plugin namespace key = sylabs.io/:8883491F4268F173C6E5DC49EDECE4F3F38D871E
+{{ range $key := .PluginNamespaceKeys }}
+{{- if ne $key "" -}}
+plugin namespace key = {{$key}}
+{{ end -}}
+{{ end }}
 # CNI CONFIGURATION PATH: [STRING]
 # DEFAULT: Undefined
 # Defines path from where CNI configuration files are stored